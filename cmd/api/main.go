@@ -11,6 +11,7 @@ var version = "dev"
 
 func main() {
 	cfg := config.FromEnv()
+	cfg.AppVersion = version
 	srv, err := httpapi.NewServer(cfg)
 	if err != nil {
 		log.Fatalf("failed to initialize server: %v", err)
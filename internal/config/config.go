@@ -13,12 +13,18 @@ import (
 
 // Config holds runtime configuration for the API service.
 type Config struct {
+	// AppVersion is the build version, set by main rather than the environment.
+	AppVersion string
+
 	ListenAddr            string
 	ReadTimeout           time.Duration
 	WriteTimeout          time.Duration
 	ShutdownTimeout       time.Duration
 	DefaultRunningLimit   int
 	DefaultCustomerReport string
+	FiscalYearStartMonth  int
+	ReportBrandName       string
+	ReportBrandColor      string
 
 	DBEnabled         bool
 	DBHost            string
@@ -28,7 +34,9 @@ type Config struct {
 	DBName            string
 	DBConnTimeout     time.Duration
 	DBQueryTimeout    time.Duration
+	DBExportTimeout   time.Duration
 	RunningStuckAfter time.Duration
+	MCPClientWorkers  int
 
 	CustomerMapSQLitePath string
 
@@ -55,6 +63,24 @@ type Config struct {
 	ESAIPIndex    string
 	ESAIPPageSize int
 
+	JobWorkers     int
+	JobQueueSize   int
+	JobMaxAttempts int
+	JobRetryDelay  time.Duration
+	JobTimeout     time.Duration
+	JobResultTTL   time.Duration
+	JobMaxRows     int
+
+	ReportScheduleTick       time.Duration
+	ReportScheduleMaxCatchUp int
+	ReportDeliveryDir        string
+	ReportWebhookTimeout     time.Duration
+	SMTPHost                 string
+	SMTPPort                 int
+	SMTPUser                 string
+	SMTPPassword             string
+	SMTPFrom                 string
+
 	RiskUnknownHotRate         float64
 	RiskUnknownHotAbs          int
 	RiskMissingIDsHotRate      float64
@@ -85,6 +111,9 @@ func FromEnv() Config {
 		ShutdownTimeout:       time.Duration(getEnvInt("APP_SHUTDOWN_TIMEOUT_SEC", 10)) * time.Second,
 		DefaultRunningLimit:   getEnvInt("APP_DEFAULT_RUNNING_LIMIT", 50),
 		DefaultCustomerReport: getEnv("APP_DEFAULT_CUSTOMER_ID", "default"),
+		FiscalYearStartMonth:  getEnvInt("APP_FISCAL_YEAR_START_MONTH", 1),
+		ReportBrandName:       getEnv("APP_REPORT_BRAND_NAME", "Archivematica Transfer Operations"),
+		ReportBrandColor:      getEnv("APP_REPORT_BRAND_COLOR", "#0e5d8f"),
 		DBEnabled:             getEnvBool("APP_DB_ENABLED", false),
 		DBHost:                getEnv("APP_DB_HOST", "127.0.0.1"),
 		DBPort:                getEnvInt("APP_DB_PORT", 62001),
@@ -93,7 +122,9 @@ func FromEnv() Config {
 		DBName:                getEnv("APP_DB_NAME", "MCP"),
 		DBConnTimeout:         time.Duration(getEnvInt("APP_DB_CONN_TIMEOUT_SEC", 5)) * time.Second,
 		DBQueryTimeout:        time.Duration(getEnvInt("APP_DB_QUERY_TIMEOUT_SEC", 10)) * time.Second,
+		DBExportTimeout:       time.Duration(getEnvInt("APP_DB_EXPORT_TIMEOUT_SEC", 600)) * time.Second,
 		RunningStuckAfter:     time.Duration(getEnvInt("APP_RUNNING_STUCK_MINUTES", 30)) * time.Minute,
+		MCPClientWorkers:      getEnvInt("APP_MCP_CLIENT_WORKERS", 0),
 		CustomerMapSQLitePath: getEnv("APP_CUSTOMER_MAP_SQLITE_PATH", ""),
 		SSDBEnabled:           getEnvBool("APP_SS_DB_ENABLED", false),
 		SSDBHost:              getEnv("APP_SS_DB_HOST", "127.0.0.1"),
@@ -115,6 +146,22 @@ func FromEnv() Config {
 		ESLookupLimit:         getEnvInt("APP_ES_LOOKUP_LIMIT", 5),
 		ESAIPIndex:            getEnv("APP_ES_AIP_INDEX", "aipfiles"),
 		ESAIPPageSize:         getEnvInt("APP_ES_AIP_PAGE_SIZE", 500),
+		JobWorkers:            getEnvInt("APP_JOB_WORKERS", 2),
+		JobQueueSize:          getEnvInt("APP_JOB_QUEUE_SIZE", 100),
+		JobMaxAttempts:        getEnvInt("APP_JOB_MAX_ATTEMPTS", 3),
+		JobRetryDelay:         time.Duration(getEnvInt("APP_JOB_RETRY_DELAY_SEC", 30)) * time.Second,
+		JobTimeout:            time.Duration(getEnvInt("APP_JOB_TIMEOUT_SEC", 1800)) * time.Second,
+		JobResultTTL:          time.Duration(getEnvInt("APP_JOB_RESULT_TTL_HOURS", 24)) * time.Hour,
+		JobMaxRows:            getEnvInt("APP_JOB_MAX_ROWS", 100000),
+		ReportScheduleTick:       time.Duration(getEnvInt("APP_REPORT_SCHEDULE_TICK_SEC", 30)) * time.Second,
+		ReportScheduleMaxCatchUp: getEnvInt("APP_REPORT_SCHEDULE_MAX_CATCH_UP", 3),
+		ReportDeliveryDir:        getEnv("APP_REPORT_DELIVERY_DIR", "/var/lib/am-ops-observer/reports"),
+		ReportWebhookTimeout:     time.Duration(getEnvInt("APP_REPORT_WEBHOOK_TIMEOUT_SEC", 30)) * time.Second,
+		SMTPHost:                 getEnv("APP_SMTP_HOST", ""),
+		SMTPPort:                 getEnvInt("APP_SMTP_PORT", 587),
+		SMTPUser:                 getEnv("APP_SMTP_USER", ""),
+		SMTPPassword:             getEnv("APP_SMTP_PASSWORD", ""),
+		SMTPFrom:                 getEnv("APP_SMTP_FROM", "am-ops-observer@localhost"),
 		RiskUnknownHotRate:         getEnvFloat("APP_RISK_UNKNOWN_HOT_RATE", 0.01),
 		RiskUnknownHotAbs:          getEnvInt("APP_RISK_UNKNOWN_HOT_ABS", 5),
 		RiskMissingIDsHotRate:      getEnvFloat("APP_RISK_MISSING_IDS_HOT_RATE", 0.10),
@@ -239,7 +286,9 @@ func (c Config) MySQLDSN() string {
 	params := url.Values{}
 	params.Set("parseTime", "true")
 	params.Set("timeout", c.DBConnTimeout.String())
-	params.Set("readTimeout", c.DBQueryTimeout.String())
+	// Streaming exports wait on reads for up to the export timeout; other queries
+	// are still bounded by DBQueryTimeout through their context.
+	params.Set("readTimeout", max(c.DBQueryTimeout, c.DBExportTimeout).String())
 	params.Set("writeTimeout", c.DBQueryTimeout.String())
 	params.Set("charset", "utf8mb4")
 	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?%s", c.DBUser, c.DBPassword, c.DBHost, c.DBPort, c.DBName, params.Encode())
@@ -250,7 +299,8 @@ func (c Config) SSMySQLDSN() string {
 	params := url.Values{}
 	params.Set("parseTime", "true")
 	params.Set("timeout", c.SSDBConnTimeout.String())
-	params.Set("readTimeout", c.SSDBQueryTimeout.String())
+	// Report jobs run Storage Service queries under the export timeout too.
+	params.Set("readTimeout", max(c.SSDBQueryTimeout, c.DBExportTimeout).String())
 	params.Set("writeTimeout", c.SSDBQueryTimeout.String())
 	params.Set("charset", "utf8mb4")
 	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?%s", c.SSDBUser, c.SSDBPassword, c.SSDBHost, c.SSDBPort, c.SSDBName, params.Encode())
@@ -0,0 +1,340 @@
+package customermap
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Billing run statuses. Approved runs are frozen.
+const (
+	BillingRunDraft    = "draft"
+	BillingRunApproved = "approved"
+)
+
+// ErrBillingRunApproved is returned when changing or deleting an approved run.
+var ErrBillingRunApproved = errors.New("billing run is approved and can no longer be changed")
+
+// BillingLineItem is one charge on a customer's bill. Prices are copied from the
+// plan at run time so later plan edits do not change existing runs.
+type BillingLineItem struct {
+	CustomerID  string  `json:"customer_id"`
+	PlanID      int64   `json:"plan_id"`
+	PlanName    string  `json:"plan_name"`
+	Item        string  `json:"item"`
+	Description string  `json:"description"`
+	Quantity    float64 `json:"quantity"`
+	Unit        string  `json:"unit"`
+	UnitPrice   float64 `json:"unit_price"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+}
+
+// BillingRun is the set of line items for one reporting period. There is at
+// most one run per period; SummaryJSON holds the usage the lines were priced from.
+type BillingRun struct {
+	ID          int64             `json:"id"`
+	PeriodKind  string            `json:"period_kind"`
+	PeriodLabel string            `json:"period_label"`
+	Timezone    string            `json:"timezone"`
+	PeriodStart time.Time         `json:"period_start"`
+	PeriodEnd   time.Time         `json:"period_end"`
+	Status      string            `json:"status"`
+	SummaryJSON string            `json:"summary_json"`
+	ApprovedBy  string            `json:"approved_by"`
+	ApprovedAt  *time.Time        `json:"approved_at,omitempty"`
+	CreatedAt   *time.Time        `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time        `json:"updated_at,omitempty"`
+	Lines       []BillingLineItem `json:"lines"`
+}
+
+func createBillingRunSchema(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS billing_runs (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  period_kind TEXT NOT NULL,
+  period_label TEXT NOT NULL,
+  timezone TEXT NOT NULL DEFAULT 'UTC',
+  period_start DATETIME NOT NULL,
+  period_end DATETIME NOT NULL,
+  status TEXT NOT NULL DEFAULT 'draft',
+  summary_json TEXT NOT NULL DEFAULT '{}',
+  approved_by TEXT NOT NULL DEFAULT '',
+  approved_at DATETIME,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (period_kind, period_label, timezone)
+);
+`); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS billing_line_items (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  run_id INTEGER NOT NULL REFERENCES billing_runs(id) ON DELETE CASCADE,
+  customer_id TEXT NOT NULL,
+  plan_id INTEGER NOT NULL DEFAULT 0,
+  plan_name TEXT NOT NULL DEFAULT '',
+  item TEXT NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  quantity REAL NOT NULL DEFAULT 0,
+  unit TEXT NOT NULL DEFAULT '',
+  unit_price REAL NOT NULL DEFAULT 0,
+  amount REAL NOT NULL DEFAULT 0,
+  currency TEXT NOT NULL DEFAULT ''
+);
+`)
+	return err
+}
+
+const billingRunColumns = `id, period_kind, period_label, timezone, period_start, period_end, status, summary_json, approved_by, approved_at, created_at, updated_at`
+
+// ListBillingRuns returns runs newest period first, without line items.
+func (s *Store) ListBillingRuns(ctx context.Context, limit int) ([]BillingRun, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT `+billingRunColumns+`
+FROM billing_runs
+ORDER BY period_start DESC, id DESC
+LIMIT ?;
+`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]BillingRun, 0)
+	for rows.Next() {
+		item, err := scanBillingRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetBillingRun returns a run with its line items ordered by customer.
+func (s *Store) GetBillingRun(ctx context.Context, id int64) (*BillingRun, error) {
+	row := s.db.QueryRowContext(ctx, `
+SELECT `+billingRunColumns+`
+FROM billing_runs
+WHERE id = ?;
+`, id)
+	run, err := scanBillingRun(row)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT customer_id, plan_id, plan_name, item, description, quantity, unit, unit_price, amount, currency
+FROM billing_line_items
+WHERE run_id = ?
+ORDER BY customer_id ASC, id ASC;
+`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	run.Lines = make([]BillingLineItem, 0)
+	for rows.Next() {
+		var line BillingLineItem
+		if err := rows.Scan(
+			&line.CustomerID,
+			&line.PlanID,
+			&line.PlanName,
+			&line.Item,
+			&line.Description,
+			&line.Quantity,
+			&line.Unit,
+			&line.UnitPrice,
+			&line.Amount,
+			&line.Currency,
+		); err != nil {
+			return nil, err
+		}
+		run.Lines = append(run.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// SaveBillingRunDraft stores run as the draft for its period, replacing an existing
+// draft and its lines. It returns ErrBillingRunApproved when the period already has
+// an approved run.
+func (s *Store) SaveBillingRunDraft(ctx context.Context, run BillingRun) (int64, error) {
+	run.PeriodKind = strings.TrimSpace(run.PeriodKind)
+	run.PeriodLabel = strings.TrimSpace(run.PeriodLabel)
+	if run.PeriodKind == "" || run.PeriodLabel == "" {
+		return 0, fmt.Errorf("period is required")
+	}
+	if strings.TrimSpace(run.Timezone) == "" {
+		run.Timezone = "UTC"
+	}
+	if strings.TrimSpace(run.SummaryJSON) == "" {
+		run.SummaryJSON = "{}"
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var (
+		id     int64
+		status string
+	)
+	err = tx.QueryRowContext(ctx, `
+SELECT id, status
+FROM billing_runs
+WHERE period_kind = ? AND period_label = ? AND timezone = ?;
+`, run.PeriodKind, run.PeriodLabel, run.Timezone).Scan(&id, &status)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		res, err := tx.ExecContext(ctx, `
+INSERT INTO billing_runs (period_kind, period_label, timezone, period_start, period_end, status, summary_json, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+`, run.PeriodKind, run.PeriodLabel, run.Timezone, run.PeriodStart.UTC(), run.PeriodEnd.UTC(), BillingRunDraft, run.SummaryJSON)
+		if err != nil {
+			return 0, err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return 0, err
+		}
+	case err != nil:
+		return 0, err
+	case status == BillingRunApproved:
+		return 0, ErrBillingRunApproved
+	default:
+		if _, err := tx.ExecContext(ctx, `
+UPDATE billing_runs
+SET period_start = ?, period_end = ?, summary_json = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+`, run.PeriodStart.UTC(), run.PeriodEnd.UTC(), run.SummaryJSON, id); err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM billing_line_items WHERE run_id = ?`, id); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, line := range run.Lines {
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO billing_line_items (run_id, customer_id, plan_id, plan_name, item, description, quantity, unit, unit_price, amount, currency)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+`, id, line.CustomerID, line.PlanID, line.PlanName, line.Item, line.Description, line.Quantity, line.Unit, line.UnitPrice, line.Amount, line.Currency); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// ApproveBillingRun freezes a draft run. Approving an approved run returns
+// ErrBillingRunApproved; an unknown id returns sql.ErrNoRows.
+func (s *Store) ApproveBillingRun(ctx context.Context, id int64, approvedBy string) error {
+	res, err := s.db.ExecContext(ctx, `
+UPDATE billing_runs
+SET status = ?, approved_by = ?, approved_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = ? AND status = ?;
+`, BillingRunApproved, strings.TrimSpace(approvedBy), id, BillingRunDraft)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+	var status string
+	if err := s.db.QueryRowContext(ctx, `SELECT status FROM billing_runs WHERE id = ?`, id).Scan(&status); err != nil {
+		return err
+	}
+	return ErrBillingRunApproved
+}
+
+// DeleteBillingRun deletes a draft run and its lines. Deleting an approved run
+// returns ErrBillingRunApproved.
+func (s *Store) DeleteBillingRun(ctx context.Context, id int64) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM billing_runs WHERE id = ?`, id).Scan(&status)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return 0, nil
+	case err != nil:
+		return 0, err
+	case status == BillingRunApproved:
+		return 0, ErrBillingRunApproved
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM billing_line_items WHERE run_id = ?`, id); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM billing_runs WHERE id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanBillingRun(row rowScanner) (*BillingRun, error) {
+	var (
+		item       BillingRun
+		approvedAt sql.NullTime
+		createdAt  sql.NullTime
+		updatedAt  sql.NullTime
+	)
+	if err := row.Scan(
+		&item.ID,
+		&item.PeriodKind,
+		&item.PeriodLabel,
+		&item.Timezone,
+		&item.PeriodStart,
+		&item.PeriodEnd,
+		&item.Status,
+		&item.SummaryJSON,
+		&item.ApprovedBy,
+		&approvedAt,
+		&createdAt,
+		&updatedAt,
+	); err != nil {
+		return nil, err
+	}
+	item.PeriodStart = item.PeriodStart.UTC()
+	item.PeriodEnd = item.PeriodEnd.UTC()
+	if approvedAt.Valid {
+		t := approvedAt.Time.UTC()
+		item.ApprovedAt = &t
+	}
+	if createdAt.Valid {
+		t := createdAt.Time.UTC()
+		item.CreatedAt = &t
+	}
+	if updatedAt.Valid {
+		t := updatedAt.Time.UTC()
+		item.UpdatedAt = &t
+	}
+	return &item, nil
+}
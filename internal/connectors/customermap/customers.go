@@ -0,0 +1,235 @@
+package customermap
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Customer is a customer entity. Mappings, rules and reports refer to it by ID;
+// a customer with a ParentID is a member of that customer, and reports for the
+// parent include its members.
+type Customer struct {
+	ID          string            `json:"id"`
+	DisplayName string            `json:"display_name"`
+	ParentID    string            `json:"parent_id"`
+	Contact     string            `json:"contact"`
+	Metadata    map[string]string `json:"metadata"`
+	CreatedAt   *time.Time        `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time        `json:"updated_at,omitempty"`
+}
+
+// ErrCustomerCycle is returned when a parent would make a customer its own ancestor.
+var ErrCustomerCycle = errors.New("parent_id would make the customer its own ancestor")
+
+func createCustomerSchema(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS customers (
+  id TEXT PRIMARY KEY,
+  display_name TEXT NOT NULL DEFAULT '',
+  parent_id TEXT NOT NULL DEFAULT '',
+  contact TEXT NOT NULL DEFAULT '',
+  metadata_json TEXT NOT NULL DEFAULT '{}',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_customers_parent_id ON customers(parent_id);`); err != nil {
+		return err
+	}
+	// Customers that so far only existed as customer_id strings become entities.
+	_, err := db.ExecContext(ctx, `
+INSERT OR IGNORE INTO customers (id)
+SELECT customer_id FROM customer_transfer_sources
+UNION
+SELECT customer_id FROM customer_mapping_rules;
+`)
+	return err
+}
+
+// NormalizeCustomer trims and validates a customer. "all" and "default" are
+// reserved by the report filters and cannot be used as IDs.
+func NormalizeCustomer(item Customer) (Customer, error) {
+	item.ID = strings.TrimSpace(item.ID)
+	item.DisplayName = strings.TrimSpace(item.DisplayName)
+	item.ParentID = strings.TrimSpace(item.ParentID)
+	item.Contact = strings.TrimSpace(item.Contact)
+	if item.ID == "" {
+		return item, fmt.Errorf("id is required")
+	}
+	if strings.EqualFold(item.ID, "all") || strings.EqualFold(item.ID, "default") {
+		return item, fmt.Errorf("id %q is reserved", item.ID)
+	}
+	if item.ParentID == item.ID {
+		return item, ErrCustomerCycle
+	}
+	metadata := make(map[string]string, len(item.Metadata))
+	for k, v := range item.Metadata {
+		if k = strings.TrimSpace(k); k != "" {
+			metadata[k] = v
+		}
+	}
+	item.Metadata = metadata
+	return item, nil
+}
+
+const customerColumns = `id, display_name, parent_id, contact, metadata_json, created_at, updated_at`
+
+// GetCustomer returns a customer; an unknown id returns sql.ErrNoRows.
+func (s *Store) GetCustomer(ctx context.Context, id string) (*Customer, error) {
+	row := s.db.QueryRowContext(ctx, `
+SELECT `+customerColumns+`
+FROM customers
+WHERE id = ?;
+`, strings.TrimSpace(id))
+	return scanCustomer(row)
+}
+
+// SaveCustomer inserts or updates a customer. The parent must exist and must not
+// be the customer or one of its members.
+func (s *Store) SaveCustomer(ctx context.Context, item Customer) error {
+	item, err := NormalizeCustomer(item)
+	if err != nil {
+		return err
+	}
+	metadataJSON, err := json.Marshal(item.Metadata)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Walk up from the new parent; reaching the customer means a cycle.
+	seen := map[string]bool{}
+	for parent := item.ParentID; parent != ""; {
+		if parent == item.ID {
+			return ErrCustomerCycle
+		}
+		if seen[parent] {
+			break
+		}
+		seen[parent] = true
+		var next string
+		err := tx.QueryRowContext(ctx, `SELECT parent_id FROM customers WHERE id = ?`, parent).Scan(&next)
+		if errors.Is(err, sql.ErrNoRows) && parent == item.ParentID {
+			return fmt.Errorf("parent customer %q not found", item.ParentID)
+		}
+		if err != nil {
+			return err
+		}
+		parent = next
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+INSERT INTO customers (id, display_name, parent_id, contact, metadata_json, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT(id) DO UPDATE SET
+  display_name = excluded.display_name,
+  parent_id = excluded.parent_id,
+  contact = excluded.contact,
+  metadata_json = excluded.metadata_json,
+  updated_at = CURRENT_TIMESTAMP;
+`, item.ID, item.DisplayName, item.ParentID, item.Contact, string(metadataJSON)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteCustomer removes a customer entity. Its members move up to its parent;
+// its mappings and rules are kept.
+func (s *Store) DeleteCustomer(ctx context.Context, id string) (int64, error) {
+	id = strings.TrimSpace(id)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `
+UPDATE customers
+SET parent_id = (SELECT parent_id FROM customers WHERE id = ?), updated_at = CURRENT_TIMESTAMP
+WHERE parent_id = ?;
+`, id, id); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM customers WHERE id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return affected, tx.Commit()
+}
+
+// CustomerDescendants returns the IDs of all direct and indirect members of a
+// customer, sorted.
+func (s *Store) CustomerDescendants(ctx context.Context, id string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+WITH RECURSIVE members(id) AS (
+  SELECT id FROM customers WHERE parent_id = ?
+  UNION
+  SELECT c.id FROM customers c JOIN members m ON c.parent_id = m.id
+)
+SELECT id FROM members ORDER BY id;
+`, strings.TrimSpace(id))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]string, 0)
+	for rows.Next() {
+		var member string
+		if err := rows.Scan(&member); err != nil {
+			return nil, err
+		}
+		out = append(out, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ensureCustomer creates a bare entity for a customer ID first seen in a mapping
+// or rule.
+func ensureCustomer(ctx context.Context, db execer, id string) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO customers (id) VALUES (?) ON CONFLICT(id) DO NOTHING;`, id)
+	return err
+}
+
+func scanCustomer(row rowScanner) (*Customer, error) {
+	var (
+		item         Customer
+		metadataJSON string
+		createdAt    sql.NullTime
+		updatedAt    sql.NullTime
+	)
+	if err := row.Scan(&item.ID, &item.DisplayName, &item.ParentID, &item.Contact, &metadataJSON, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	item.Metadata = map[string]string{}
+	if err := json.Unmarshal([]byte(metadataJSON), &item.Metadata); err != nil {
+		return nil, err
+	}
+	if createdAt.Valid {
+		t := createdAt.Time.UTC()
+		item.CreatedAt = &t
+	}
+	if updatedAt.Valid {
+		t := updatedAt.Time.UTC()
+		item.UpdatedAt = &t
+	}
+	return &item, nil
+}
@@ -0,0 +1,201 @@
+package customermap
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FailureKnowledge is an operator-maintained explanation and remediation for a failure pattern.
+// Entries match on a failure signature ID, on a case-insensitive text fragment, or both.
+type FailureKnowledge struct {
+	ID                int64      `json:"id"`
+	SignatureID       string     `json:"signature_id"`
+	MatchText         string     `json:"match_text"`
+	MicroserviceGroup string     `json:"microservice_group"`
+	Title             string     `json:"title"`
+	Explanation       string     `json:"explanation"`
+	Remediation       string     `json:"remediation"`
+	Links             []string   `json:"links"`
+	Severity          string     `json:"severity"`
+	CreatedAt         *time.Time `json:"created_at,omitempty"`
+	UpdatedAt         *time.Time `json:"updated_at,omitempty"`
+}
+
+var failureKnowledgeSeverities = map[string]struct{}{
+	"info":     {},
+	"low":      {},
+	"medium":   {},
+	"high":     {},
+	"critical": {},
+}
+
+func createFailureKnowledgeSchema(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS failure_knowledge (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  signature_id TEXT NOT NULL DEFAULT '',
+  match_text TEXT NOT NULL DEFAULT '',
+  microservice_group TEXT NOT NULL DEFAULT '',
+  title TEXT NOT NULL,
+  explanation TEXT NOT NULL DEFAULT '',
+  remediation TEXT NOT NULL DEFAULT '',
+  links_json TEXT NOT NULL DEFAULT '[]',
+  severity TEXT NOT NULL DEFAULT 'medium',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_fk_signature_id ON failure_knowledge(signature_id);`)
+	return err
+}
+
+func (s *Store) ListFailureKnowledge(ctx context.Context, limit int) ([]FailureKnowledge, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT id, signature_id, match_text, microservice_group, title, explanation, remediation, links_json, severity, created_at, updated_at
+FROM failure_knowledge
+ORDER BY title ASC, id ASC
+LIMIT ?;
+`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]FailureKnowledge, 0)
+	for rows.Next() {
+		item, err := scanFailureKnowledge(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *Store) GetFailureKnowledge(ctx context.Context, id int64) (*FailureKnowledge, error) {
+	row := s.db.QueryRowContext(ctx, `
+SELECT id, signature_id, match_text, microservice_group, title, explanation, remediation, links_json, severity, created_at, updated_at
+FROM failure_knowledge
+WHERE id = ?;
+`, id)
+	return scanFailureKnowledge(row)
+}
+
+// SaveFailureKnowledge inserts a new entry when item.ID is 0, otherwise updates it.
+func (s *Store) SaveFailureKnowledge(ctx context.Context, item FailureKnowledge) (int64, error) {
+	item.SignatureID = strings.ToLower(strings.TrimSpace(item.SignatureID))
+	item.MatchText = strings.TrimSpace(item.MatchText)
+	item.MicroserviceGroup = strings.TrimSpace(item.MicroserviceGroup)
+	item.Title = strings.TrimSpace(item.Title)
+	item.Explanation = strings.TrimSpace(item.Explanation)
+	item.Remediation = strings.TrimSpace(item.Remediation)
+	item.Severity = strings.ToLower(strings.TrimSpace(item.Severity))
+	if item.Title == "" {
+		return 0, fmt.Errorf("title is required")
+	}
+	if item.SignatureID == "" && item.MatchText == "" {
+		return 0, fmt.Errorf("signature_id or match_text is required")
+	}
+	if item.Severity == "" {
+		item.Severity = "medium"
+	}
+	if _, ok := failureKnowledgeSeverities[item.Severity]; !ok {
+		return 0, fmt.Errorf("unsupported severity: %s", item.Severity)
+	}
+	links := make([]string, 0, len(item.Links))
+	for _, l := range item.Links {
+		if l = strings.TrimSpace(l); l != "" {
+			links = append(links, l)
+		}
+	}
+	linksJSON, err := json.Marshal(links)
+	if err != nil {
+		return 0, err
+	}
+
+	if item.ID > 0 {
+		res, err := s.db.ExecContext(ctx, `
+UPDATE failure_knowledge
+SET signature_id = ?, match_text = ?, microservice_group = ?, title = ?, explanation = ?, remediation = ?, links_json = ?, severity = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+`, item.SignatureID, item.MatchText, item.MicroserviceGroup, item.Title, item.Explanation, item.Remediation, string(linksJSON), item.Severity, item.ID)
+		if err != nil {
+			return 0, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if affected == 0 {
+			return 0, sql.ErrNoRows
+		}
+		return item.ID, nil
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+INSERT INTO failure_knowledge (signature_id, match_text, microservice_group, title, explanation, remediation, links_json, severity, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+`, item.SignatureID, item.MatchText, item.MicroserviceGroup, item.Title, item.Explanation, item.Remediation, string(linksJSON), item.Severity)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *Store) DeleteFailureKnowledge(ctx context.Context, id int64) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM failure_knowledge WHERE id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanFailureKnowledge(row rowScanner) (*FailureKnowledge, error) {
+	var (
+		item      FailureKnowledge
+		linksJSON string
+		createdAt sql.NullTime
+		updatedAt sql.NullTime
+	)
+	if err := row.Scan(
+		&item.ID,
+		&item.SignatureID,
+		&item.MatchText,
+		&item.MicroserviceGroup,
+		&item.Title,
+		&item.Explanation,
+		&item.Remediation,
+		&linksJSON,
+		&item.Severity,
+		&createdAt,
+		&updatedAt,
+	); err != nil {
+		return nil, err
+	}
+	item.Links = []string{}
+	if strings.TrimSpace(linksJSON) != "" {
+		_ = json.Unmarshal([]byte(linksJSON), &item.Links)
+	}
+	if createdAt.Valid {
+		t := createdAt.Time.UTC()
+		item.CreatedAt = &t
+	}
+	if updatedAt.Valid {
+		t := updatedAt.Time.UTC()
+		item.UpdatedAt = &t
+	}
+	return &item, nil
+}
@@ -0,0 +1,269 @@
+package customermap
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Mapping is one customer/source mapping with its validity window. A nil bound is
+// open; EffectiveFrom is inclusive and EffectiveTo exclusive.
+type Mapping struct {
+	CustomerID          string     `json:"customer_id"`
+	SourceOfAcquisition string     `json:"source_of_acquisition"`
+	EffectiveFrom       *time.Time `json:"effective_from"`
+	EffectiveTo         *time.Time `json:"effective_to"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// ValidAt reports whether the mapping applies at t.
+func (m Mapping) ValidAt(t time.Time) bool {
+	return (m.EffectiveFrom == nil || !t.Before(*m.EffectiveFrom)) && (m.EffectiveTo == nil || t.Before(*m.EffectiveTo))
+}
+
+// MappingEvent is one change to a customer's mappings. The window is the one the
+// mapping had after the change, or before it for removals.
+type MappingEvent struct {
+	ID                  int64      `json:"id"`
+	CustomerID          string     `json:"customer_id"`
+	SourceOfAcquisition string     `json:"source_of_acquisition"`
+	Action              string     `json:"action"`
+	EffectiveFrom       *time.Time `json:"effective_from"`
+	EffectiveTo         *time.Time `json:"effective_to"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// Mapping event actions.
+const (
+	MappingAdded           = "added"
+	MappingRemoved         = "removed"
+	MappingValidityChanged = "validity_changed"
+)
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func createMappingHistorySchema(ctx context.Context, db *sql.DB) error {
+	if err := addColumn(ctx, db, "customer_transfer_sources", "effective_from", "DATETIME"); err != nil {
+		return err
+	}
+	if err := addColumn(ctx, db, "customer_transfer_sources", "effective_to", "DATETIME"); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS customer_mapping_events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  customer_id TEXT NOT NULL,
+  source_of_acquisition TEXT NOT NULL,
+  action TEXT NOT NULL,
+  effective_from DATETIME,
+  effective_to DATETIME,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_cme_customer_id ON customer_mapping_events(customer_id, id);`)
+	return err
+}
+
+// MappingsForCustomer returns the customer's mappings with their windows.
+func (s *Store) MappingsForCustomer(ctx context.Context, customerID string) ([]Mapping, error) {
+	return queryMappings(ctx, s.db, `customer_id = ?`, strings.TrimSpace(customerID))
+}
+
+// ListAllMappings returns the mappings of all customers with their windows.
+func (s *Store) ListAllMappings(ctx context.Context) ([]Mapping, error) {
+	return queryMappings(ctx, s.db, `1 = 1`)
+}
+
+// AddMapping inserts a mapping with an optional window. created is false when the
+// customer already had the source; its window is left unchanged.
+func (s *Store) AddMapping(ctx context.Context, item Mapping) (bool, error) {
+	item.CustomerID = strings.TrimSpace(item.CustomerID)
+	item.SourceOfAcquisition = strings.TrimSpace(item.SourceOfAcquisition)
+	if err := validateMappingWindow(item.EffectiveFrom, item.EffectiveTo); err != nil {
+		return false, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	created, err := insertMapping(ctx, tx, item)
+	if err != nil {
+		return false, err
+	}
+	return created, tx.Commit()
+}
+
+// SetMappingValidity changes the window of an existing mapping. It returns
+// sql.ErrNoRows when the customer has no mapping for the source.
+func (s *Store) SetMappingValidity(ctx context.Context, customerID, source string, from, to *time.Time) (*Mapping, error) {
+	customerID = strings.TrimSpace(customerID)
+	source = strings.TrimSpace(source)
+	if err := validateMappingWindow(from, to); err != nil {
+		return nil, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `
+UPDATE customer_transfer_sources
+SET effective_from = ?, effective_to = ?
+WHERE customer_id = ? AND source_of_acquisition = ?;
+`, nullTimeArg(from), nullTimeArg(to), customerID, source)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, sql.ErrNoRows
+	}
+	if err := recordMappingEvent(ctx, tx, customerID, source, MappingValidityChanged, from, to); err != nil {
+		return nil, err
+	}
+	items, err := queryMappings(ctx, tx, `customer_id = ? AND source_of_acquisition = ?`, customerID, source)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &items[0], nil
+}
+
+// ListMappingEvents returns a customer's mapping changes, newest first.
+func (s *Store) ListMappingEvents(ctx context.Context, customerID string, limit int) ([]MappingEvent, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT id, customer_id, source_of_acquisition, action, effective_from, effective_to, created_at
+FROM customer_mapping_events
+WHERE customer_id = ?
+ORDER BY id DESC
+LIMIT ?;
+`, strings.TrimSpace(customerID), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]MappingEvent, 0)
+	for rows.Next() {
+		var (
+			item     MappingEvent
+			from, to sql.NullTime
+		)
+		if err := rows.Scan(&item.ID, &item.CustomerID, &item.SourceOfAcquisition, &item.Action, &from, &to, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		item.EffectiveFrom, item.EffectiveTo = nullTimeUTC(from), nullTimeUTC(to)
+		item.CreatedAt = item.CreatedAt.UTC()
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func insertMapping(ctx context.Context, tx *sql.Tx, item Mapping) (bool, error) {
+	res, err := tx.ExecContext(ctx, `
+INSERT INTO customer_transfer_sources (customer_id, source_of_acquisition, effective_from, effective_to)
+VALUES (?, ?, ?, ?)
+ON CONFLICT(customer_id, source_of_acquisition) DO NOTHING;
+`, item.CustomerID, item.SourceOfAcquisition, nullTimeArg(item.EffectiveFrom), nullTimeArg(item.EffectiveTo))
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil || affected == 0 {
+		return false, err
+	}
+	if err := ensureCustomer(ctx, tx, item.CustomerID); err != nil {
+		return false, err
+	}
+	return true, recordMappingEvent(ctx, tx, item.CustomerID, item.SourceOfAcquisition, MappingAdded, item.EffectiveFrom, item.EffectiveTo)
+}
+
+// deleteMappings removes the mappings matching where and records their removal.
+func deleteMappings(ctx context.Context, tx *sql.Tx, where string, args ...any) (int64, error) {
+	removed, err := queryMappings(ctx, tx, where, args...)
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range removed {
+		if err := recordMappingEvent(ctx, tx, m.CustomerID, m.SourceOfAcquisition, MappingRemoved, m.EffectiveFrom, m.EffectiveTo); err != nil {
+			return 0, err
+		}
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM customer_transfer_sources WHERE `+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func queryMappings(ctx context.Context, db execer, where string, args ...any) ([]Mapping, error) {
+	rows, err := db.QueryContext(ctx, `
+SELECT customer_id, source_of_acquisition, effective_from, effective_to, created_at
+FROM customer_transfer_sources
+WHERE `+where+`
+ORDER BY source_of_acquisition, customer_id;
+`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Mapping, 0)
+	for rows.Next() {
+		var (
+			item     Mapping
+			from, to sql.NullTime
+		)
+		if err := rows.Scan(&item.CustomerID, &item.SourceOfAcquisition, &from, &to, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		item.EffectiveFrom, item.EffectiveTo = nullTimeUTC(from), nullTimeUTC(to)
+		item.CreatedAt = item.CreatedAt.UTC()
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func recordMappingEvent(ctx context.Context, db execer, customerID, source, action string, from, to *time.Time) error {
+	_, err := db.ExecContext(ctx, `
+INSERT INTO customer_mapping_events (customer_id, source_of_acquisition, action, effective_from, effective_to, created_at)
+VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP);
+`, customerID, source, action, nullTimeArg(from), nullTimeArg(to))
+	return err
+}
+
+func validateMappingWindow(from, to *time.Time) error {
+	if from != nil && to != nil && !to.After(*from) {
+		return fmt.Errorf("effective_to must be after effective_from")
+	}
+	return nil
+}
+
+func nullTimeUTC(nt sql.NullTime) *time.Time {
+	if !nt.Valid {
+		return nil
+	}
+	t := nt.Time.UTC()
+	return &t
+}
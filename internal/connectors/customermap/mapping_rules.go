@@ -0,0 +1,249 @@
+package customermap
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MappingRule assigns transfers to a customer by matching one transfer field
+// against a pattern. When rules of different customers match the same transfer,
+// the rule with the highest priority wins, then the oldest rule.
+type MappingRule struct {
+	ID         int64      `json:"id"`
+	CustomerID string     `json:"customer_id"`
+	Field      string     `json:"field"`
+	MatchType  string     `json:"match_type"`
+	Pattern    string     `json:"pattern"`
+	Priority   int        `json:"priority"`
+	Enabled    bool       `json:"enabled"`
+	Note       string     `json:"note"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
+
+// Supported mapping rule fields and match types.
+var (
+	MappingRuleFields     = []string{"source_of_acquisition", "accession_id", "transfer_name", "source_location"}
+	MappingRuleMatchTypes = []string{"exact", "prefix", "glob", "regex"}
+)
+
+func createMappingRuleSchema(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS customer_mapping_rules (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  customer_id TEXT NOT NULL,
+  field TEXT NOT NULL,
+  match_type TEXT NOT NULL,
+  pattern TEXT NOT NULL,
+  priority INTEGER NOT NULL DEFAULT 0,
+  enabled INTEGER NOT NULL DEFAULT 1,
+  note TEXT NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_cmr_customer_id ON customer_mapping_rules(customer_id);`)
+	return err
+}
+
+// NormalizeMappingRule trims and validates a rule. Regex patterns must compile
+// with Go's regexp syntax and stay within the subset MySQL reads the same way,
+// see portableRegexError.
+func NormalizeMappingRule(item MappingRule) (MappingRule, error) {
+	item.CustomerID = strings.TrimSpace(item.CustomerID)
+	item.Field = strings.ToLower(strings.TrimSpace(item.Field))
+	item.MatchType = strings.ToLower(strings.TrimSpace(item.MatchType))
+	item.Pattern = strings.TrimSpace(item.Pattern)
+	item.Note = strings.TrimSpace(item.Note)
+	if item.CustomerID == "" {
+		return item, fmt.Errorf("customer_id is required")
+	}
+	if item.MatchType == "" {
+		item.MatchType = "exact"
+	}
+	if !containsString(MappingRuleFields, item.Field) {
+		return item, fmt.Errorf("unsupported field %q (expected %s)", item.Field, strings.Join(MappingRuleFields, ", "))
+	}
+	if !containsString(MappingRuleMatchTypes, item.MatchType) {
+		return item, fmt.Errorf("unsupported match_type %q (expected %s)", item.MatchType, strings.Join(MappingRuleMatchTypes, ", "))
+	}
+	if item.Pattern == "" {
+		return item, fmt.Errorf("pattern is required")
+	}
+	if item.MatchType == "regex" {
+		if _, err := regexp.Compile(item.Pattern); err != nil {
+			return item, fmt.Errorf("invalid regex pattern: %w", err)
+		}
+		if err := portableRegexError(item.Pattern); err != nil {
+			return item, fmt.Errorf("invalid regex pattern: %w", err)
+		}
+	}
+	return item, nil
+}
+
+// portableRegexError rejects regex syntax that previews (Go RE2) and reports
+// (MySQL 8 REGEXP_LIKE, ICU) do not read the same way: groups starting with "(?"
+// other than "(?:", such as flags or (?P<name>...), \C, and \p or \P without
+// braces. Matching is always case-insensitive, so flags are not needed.
+func portableRegexError(pattern string) error {
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\' && i+1 < len(pattern):
+			next := pattern[i+1]
+			if next == 'C' {
+				return fmt.Errorf(`\C is not supported`)
+			}
+			if (next == 'p' || next == 'P') && (i+2 >= len(pattern) || pattern[i+2] != '{') {
+				return fmt.Errorf(`\%c needs a braced class name, e.g. \%c{L}`, next, next)
+			}
+			i++
+		case inClass:
+			if strings.HasPrefix(pattern[i:], "[:") {
+				if end := strings.Index(pattern[i+2:], ":]"); end >= 0 {
+					i += end + 3
+				}
+			} else if c == ']' {
+				inClass = false
+			}
+		case c == '[':
+			inClass = true
+			if strings.HasPrefix(pattern[i+1:], "^") {
+				i++
+			}
+			if strings.HasPrefix(pattern[i+1:], "]") {
+				i++
+			}
+		case c == '(' && strings.HasPrefix(pattern[i:], "(?") && !strings.HasPrefix(pattern[i:], "(?:"):
+			return fmt.Errorf("only (?:...) groups are supported; matching is always case-insensitive")
+		}
+	}
+	return nil
+}
+
+const mappingRuleColumns = `id, customer_id, field, match_type, pattern, priority, enabled, note, created_at, updated_at`
+
+// ListMappingRules returns rules in evaluation order: highest priority first,
+// then oldest first. An empty customerID lists the rules of all customers.
+func (s *Store) ListMappingRules(ctx context.Context, customerID string, enabledOnly bool) ([]MappingRule, error) {
+	where := []string{"1 = 1"}
+	args := []any{}
+	if v := strings.TrimSpace(customerID); v != "" {
+		where = append(where, "customer_id = ?")
+		args = append(args, v)
+	}
+	if enabledOnly {
+		where = append(where, "enabled = 1")
+	}
+	rows, err := s.db.QueryContext(ctx, `
+SELECT `+mappingRuleColumns+`
+FROM customer_mapping_rules
+WHERE `+strings.Join(where, " AND ")+`
+ORDER BY priority DESC, id ASC;
+`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]MappingRule, 0)
+	for rows.Next() {
+		item, err := scanMappingRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *Store) GetMappingRule(ctx context.Context, id int64) (*MappingRule, error) {
+	row := s.db.QueryRowContext(ctx, `
+SELECT `+mappingRuleColumns+`
+FROM customer_mapping_rules
+WHERE id = ?;
+`, id)
+	return scanMappingRule(row)
+}
+
+// SaveMappingRule inserts a new rule when item.ID is 0, otherwise updates it.
+func (s *Store) SaveMappingRule(ctx context.Context, item MappingRule) (int64, error) {
+	item, err := NormalizeMappingRule(item)
+	if err != nil {
+		return 0, err
+	}
+	enabled := 0
+	if item.Enabled {
+		enabled = 1
+	}
+
+	if item.ID > 0 {
+		res, err := s.db.ExecContext(ctx, `
+UPDATE customer_mapping_rules
+SET customer_id = ?, field = ?, match_type = ?, pattern = ?, priority = ?, enabled = ?, note = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+`, item.CustomerID, item.Field, item.MatchType, item.Pattern, item.Priority, enabled, item.Note, item.ID)
+		if err != nil {
+			return 0, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if affected == 0 {
+			return 0, sql.ErrNoRows
+		}
+		return item.ID, ensureCustomer(ctx, s.db, item.CustomerID)
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+INSERT INTO customer_mapping_rules (customer_id, field, match_type, pattern, priority, enabled, note, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+`, item.CustomerID, item.Field, item.MatchType, item.Pattern, item.Priority, enabled, item.Note)
+	if err != nil {
+		return 0, err
+	}
+	if err := ensureCustomer(ctx, s.db, item.CustomerID); err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *Store) DeleteMappingRule(ctx context.Context, id int64) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM customer_mapping_rules WHERE id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanMappingRule(row rowScanner) (*MappingRule, error) {
+	var (
+		item      MappingRule
+		enabled   int
+		createdAt sql.NullTime
+		updatedAt sql.NullTime
+	)
+	if err := row.Scan(&item.ID, &item.CustomerID, &item.Field, &item.MatchType, &item.Pattern, &item.Priority, &enabled, &item.Note, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	item.Enabled = enabled == 1
+	if createdAt.Valid {
+		t := createdAt.Time.UTC()
+		item.CreatedAt = &t
+	}
+	if updatedAt.Valid {
+		t := updatedAt.Time.UTC()
+		item.UpdatedAt = &t
+	}
+	return &item, nil
+}
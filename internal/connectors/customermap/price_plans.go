@@ -0,0 +1,241 @@
+package customermap
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PriceTier prices the GB falling between the previous tier's UpToGB and its own.
+// UpToGB 0 means unbounded and is only allowed on the last tier.
+type PriceTier struct {
+	UpToGB     float64 `json:"up_to_gb"`
+	PricePerGB float64 `json:"price_per_gb"`
+}
+
+// PricePlan is a per-customer chargeback plan. The plan with customer_id "default"
+// applies to customers without a plan of their own. Tiers, when set, replace the
+// flat per-GB price for that measure.
+type PricePlan struct {
+	ID               int64       `json:"id"`
+	CustomerID       string      `json:"customer_id"`
+	Name             string      `json:"name"`
+	Currency         string      `json:"currency"`
+	PerGBStoredMonth float64     `json:"per_gb_stored_month"`
+	PerGBIngested    float64     `json:"per_gb_ingested"`
+	PerTransfer      float64     `json:"per_transfer"`
+	StoredTiers      []PriceTier `json:"stored_tiers"`
+	IngestedTiers    []PriceTier `json:"ingested_tiers"`
+	CreatedAt        *time.Time  `json:"created_at,omitempty"`
+	UpdatedAt        *time.Time  `json:"updated_at,omitempty"`
+}
+
+func createPricePlanSchema(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS price_plans (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  customer_id TEXT NOT NULL UNIQUE,
+  name TEXT NOT NULL DEFAULT '',
+  currency TEXT NOT NULL DEFAULT 'EUR',
+  per_gb_stored_month REAL NOT NULL DEFAULT 0,
+  per_gb_ingested REAL NOT NULL DEFAULT 0,
+  per_transfer REAL NOT NULL DEFAULT 0,
+  stored_tiers_json TEXT NOT NULL DEFAULT '[]',
+  ingested_tiers_json TEXT NOT NULL DEFAULT '[]',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`)
+	return err
+}
+
+const pricePlanColumns = `id, customer_id, name, currency, per_gb_stored_month, per_gb_ingested, per_transfer, stored_tiers_json, ingested_tiers_json, created_at, updated_at`
+
+func (s *Store) ListPricePlans(ctx context.Context, limit int) ([]PricePlan, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT `+pricePlanColumns+`
+FROM price_plans
+ORDER BY customer_id ASC
+LIMIT ?;
+`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]PricePlan, 0)
+	for rows.Next() {
+		item, err := scanPricePlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *Store) GetPricePlan(ctx context.Context, id int64) (*PricePlan, error) {
+	row := s.db.QueryRowContext(ctx, `
+SELECT `+pricePlanColumns+`
+FROM price_plans
+WHERE id = ?;
+`, id)
+	return scanPricePlan(row)
+}
+
+// PricePlanForCustomer returns the customer's plan, falling back to the
+// "default" plan. It returns sql.ErrNoRows when neither exists.
+func (s *Store) PricePlanForCustomer(ctx context.Context, customerID string) (*PricePlan, error) {
+	row := s.db.QueryRowContext(ctx, `
+SELECT `+pricePlanColumns+`
+FROM price_plans
+WHERE customer_id IN (?, 'default')
+ORDER BY CASE WHEN customer_id = ? THEN 0 ELSE 1 END
+LIMIT 1;
+`, strings.TrimSpace(customerID), strings.TrimSpace(customerID))
+	return scanPricePlan(row)
+}
+
+// SavePricePlan inserts a new plan when item.ID is 0, otherwise updates it.
+func (s *Store) SavePricePlan(ctx context.Context, item PricePlan) (int64, error) {
+	item.CustomerID = strings.TrimSpace(item.CustomerID)
+	item.Name = strings.TrimSpace(item.Name)
+	item.Currency = strings.ToUpper(strings.TrimSpace(item.Currency))
+	if item.CustomerID == "" {
+		return 0, fmt.Errorf("customer_id is required")
+	}
+	if item.Currency == "" {
+		item.Currency = "EUR"
+	}
+	if len(item.Currency) != 3 {
+		return 0, fmt.Errorf("currency must be a 3-letter ISO code")
+	}
+	if item.PerGBStoredMonth < 0 || item.PerGBIngested < 0 || item.PerTransfer < 0 {
+		return 0, fmt.Errorf("prices must not be negative")
+	}
+	if err := validatePriceTiers("stored_tiers", item.StoredTiers); err != nil {
+		return 0, err
+	}
+	if err := validatePriceTiers("ingested_tiers", item.IngestedTiers); err != nil {
+		return 0, err
+	}
+	storedJSON, err := json.Marshal(nonNilTiers(item.StoredTiers))
+	if err != nil {
+		return 0, err
+	}
+	ingestedJSON, err := json.Marshal(nonNilTiers(item.IngestedTiers))
+	if err != nil {
+		return 0, err
+	}
+
+	if item.ID > 0 {
+		res, err := s.db.ExecContext(ctx, `
+UPDATE price_plans
+SET customer_id = ?, name = ?, currency = ?, per_gb_stored_month = ?, per_gb_ingested = ?, per_transfer = ?, stored_tiers_json = ?, ingested_tiers_json = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+`, item.CustomerID, item.Name, item.Currency, item.PerGBStoredMonth, item.PerGBIngested, item.PerTransfer, string(storedJSON), string(ingestedJSON), item.ID)
+		if err != nil {
+			return 0, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if affected == 0 {
+			return 0, sql.ErrNoRows
+		}
+		return item.ID, nil
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+INSERT INTO price_plans (customer_id, name, currency, per_gb_stored_month, per_gb_ingested, per_transfer, stored_tiers_json, ingested_tiers_json, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+`, item.CustomerID, item.Name, item.Currency, item.PerGBStoredMonth, item.PerGBIngested, item.PerTransfer, string(storedJSON), string(ingestedJSON))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *Store) DeletePricePlan(ctx context.Context, id int64) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM price_plans WHERE id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func validatePriceTiers(field string, tiers []PriceTier) error {
+	prev := 0.0
+	for i, t := range tiers {
+		if t.PricePerGB < 0 {
+			return fmt.Errorf("%s[%d]: price_per_gb must not be negative", field, i)
+		}
+		if t.UpToGB == 0 {
+			if i != len(tiers)-1 {
+				return fmt.Errorf("%s[%d]: only the last tier may be unbounded", field, i)
+			}
+			continue
+		}
+		if t.UpToGB <= prev {
+			return fmt.Errorf("%s[%d]: up_to_gb must be increasing", field, i)
+		}
+		prev = t.UpToGB
+	}
+	return nil
+}
+
+func nonNilTiers(tiers []PriceTier) []PriceTier {
+	if tiers == nil {
+		return []PriceTier{}
+	}
+	return tiers
+}
+
+func scanPricePlan(row rowScanner) (*PricePlan, error) {
+	var (
+		item         PricePlan
+		storedJSON   string
+		ingestedJSON string
+		createdAt    sql.NullTime
+		updatedAt    sql.NullTime
+	)
+	if err := row.Scan(
+		&item.ID,
+		&item.CustomerID,
+		&item.Name,
+		&item.Currency,
+		&item.PerGBStoredMonth,
+		&item.PerGBIngested,
+		&item.PerTransfer,
+		&storedJSON,
+		&ingestedJSON,
+		&createdAt,
+		&updatedAt,
+	); err != nil {
+		return nil, err
+	}
+	item.StoredTiers = []PriceTier{}
+	item.IngestedTiers = []PriceTier{}
+	if strings.TrimSpace(storedJSON) != "" {
+		_ = json.Unmarshal([]byte(storedJSON), &item.StoredTiers)
+	}
+	if strings.TrimSpace(ingestedJSON) != "" {
+		_ = json.Unmarshal([]byte(ingestedJSON), &item.IngestedTiers)
+	}
+	if createdAt.Valid {
+		t := createdAt.Time.UTC()
+		item.CreatedAt = &t
+	}
+	if updatedAt.Valid {
+		t := updatedAt.Time.UTC()
+		item.UpdatedAt = &t
+	}
+	return &item, nil
+}
@@ -0,0 +1,237 @@
+package customermap
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// ReportJob is a background report job persisted so that queued jobs survive a
+// restart and finished jobs can be listed until they expire.
+type ReportJob struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"`
+	ParamsJSON  string     `json:"params_json"`
+	State       string     `json:"state"`
+	Progress    float64    `json:"progress"`
+	Message     string     `json:"message"`
+	Error       string     `json:"error"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	Formats     []string   `json:"formats"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+func createReportJobSchema(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS report_jobs (
+  id TEXT PRIMARY KEY,
+  kind TEXT NOT NULL,
+  params_json TEXT NOT NULL DEFAULT '{}',
+  state TEXT NOT NULL,
+  progress REAL NOT NULL DEFAULT 0,
+  message TEXT NOT NULL DEFAULT '',
+  error TEXT NOT NULL DEFAULT '',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  max_attempts INTEGER NOT NULL DEFAULT 1,
+  formats_json TEXT NOT NULL DEFAULT '[]',
+  created_at DATETIME NOT NULL,
+  started_at DATETIME,
+  finished_at DATETIME,
+  expires_at DATETIME
+);
+`); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_rj_state ON report_jobs(state);`); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS report_job_results (
+  job_id TEXT PRIMARY KEY,
+  body BLOB NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`)
+	return err
+}
+
+const reportJobColumns = `id, kind, params_json, state, progress, message, error, attempts, max_attempts, formats_json, created_at, started_at, finished_at, expires_at`
+
+// SaveReportJob inserts or replaces a job.
+func (s *Store) SaveReportJob(ctx context.Context, job ReportJob) error {
+	formats, err := json.Marshal(job.Formats)
+	if err != nil {
+		return err
+	}
+	if job.Formats == nil {
+		formats = []byte("[]")
+	}
+	if strings.TrimSpace(job.ParamsJSON) == "" {
+		job.ParamsJSON = "{}"
+	}
+	_, err = s.db.ExecContext(ctx, `
+INSERT INTO report_jobs (`+reportJobColumns+`)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(id) DO UPDATE SET
+  state = excluded.state,
+  progress = excluded.progress,
+  message = excluded.message,
+  error = excluded.error,
+  attempts = excluded.attempts,
+  max_attempts = excluded.max_attempts,
+  formats_json = excluded.formats_json,
+  started_at = excluded.started_at,
+  finished_at = excluded.finished_at,
+  expires_at = excluded.expires_at;
+`, job.ID, job.Kind, job.ParamsJSON, job.State, job.Progress, job.Message, job.Error, job.Attempts, job.MaxAttempts, string(formats),
+		job.CreatedAt.UTC(), nullTimeArg(job.StartedAt), nullTimeArg(job.FinishedAt), nullTimeArg(job.ExpiresAt))
+	return err
+}
+
+// GetReportJob returns a job; an unknown id returns sql.ErrNoRows.
+func (s *Store) GetReportJob(ctx context.Context, id string) (*ReportJob, error) {
+	row := s.db.QueryRowContext(ctx, `
+SELECT `+reportJobColumns+`
+FROM report_jobs
+WHERE id = ?;
+`, id)
+	return scanReportJob(row)
+}
+
+// ListReportJobs returns jobs newest first, optionally only those in states. A
+// limit of 0 or less returns all jobs.
+func (s *Store) ListReportJobs(ctx context.Context, limit int, states []string) ([]ReportJob, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	where := ""
+	args := make([]any, 0, len(states)+1)
+	if len(states) > 0 {
+		where = "WHERE state IN (?" + strings.Repeat(", ?", len(states)-1) + ")"
+		for _, st := range states {
+			args = append(args, st)
+		}
+	}
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(ctx, `
+SELECT `+reportJobColumns+`
+FROM report_jobs
+`+where+`
+ORDER BY created_at DESC, id DESC
+LIMIT ?;
+`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ReportJob, 0)
+	for rows.Next() {
+		item, err := scanReportJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteReportJob deletes a job and its result.
+func (s *Store) DeleteReportJob(ctx context.Context, id string) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM report_job_results WHERE job_id = ?`, id); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM report_jobs WHERE id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SaveReportJobResult stores the encoded result of a job, replacing an earlier one.
+func (s *Store) SaveReportJobResult(ctx context.Context, id string, body []byte) error {
+	_, err := s.db.ExecContext(ctx, `
+INSERT INTO report_job_results (job_id, body, created_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT(job_id) DO UPDATE SET body = excluded.body, created_at = excluded.created_at;
+`, id, body)
+	return err
+}
+
+// GetReportJobResult returns the encoded result of a job; a job without a result
+// returns sql.ErrNoRows.
+func (s *Store) GetReportJobResult(ctx context.Context, id string) ([]byte, error) {
+	var body []byte
+	if err := s.db.QueryRowContext(ctx, `SELECT body FROM report_job_results WHERE job_id = ?`, id).Scan(&body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func nullTimeArg(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+func scanReportJob(row rowScanner) (*ReportJob, error) {
+	var (
+		item       ReportJob
+		formats    string
+		startedAt  sql.NullTime
+		finishedAt sql.NullTime
+		expiresAt  sql.NullTime
+	)
+	if err := row.Scan(
+		&item.ID,
+		&item.Kind,
+		&item.ParamsJSON,
+		&item.State,
+		&item.Progress,
+		&item.Message,
+		&item.Error,
+		&item.Attempts,
+		&item.MaxAttempts,
+		&formats,
+		&item.CreatedAt,
+		&startedAt,
+		&finishedAt,
+		&expiresAt,
+	); err != nil {
+		return nil, err
+	}
+	_ = json.Unmarshal([]byte(formats), &item.Formats)
+	item.CreatedAt = item.CreatedAt.UTC()
+	if startedAt.Valid {
+		t := startedAt.Time.UTC()
+		item.StartedAt = &t
+	}
+	if finishedAt.Valid {
+		t := finishedAt.Time.UTC()
+		item.FinishedAt = &t
+	}
+	if expiresAt.Valid {
+		t := expiresAt.Time.UTC()
+		item.ExpiresAt = &t
+	}
+	return &item, nil
+}
@@ -0,0 +1,344 @@
+package customermap
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ReportSchedule runs a report template on a cron schedule and delivers its output.
+// Range picks the reporting window relative to the scheduled time.
+type ReportSchedule struct {
+	ID         int64      `json:"id"`
+	TemplateID int64      `json:"template_id"`
+	Name       string     `json:"name"`
+	Cron       string     `json:"cron"`
+	Timezone   string     `json:"timezone"`
+	Range      string     `json:"range"`
+	Format     string     `json:"format"`
+	Delivery   string     `json:"delivery"`
+	Target     string     `json:"target"`
+	ParamsJSON string     `json:"params_json"`
+	Enabled    bool       `json:"enabled"`
+	NextRunAt  *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt  *time.Time `json:"last_run_at,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
+
+// ReportScheduleRun is one execution of a schedule: the job that built the report
+// and where its output was delivered.
+type ReportScheduleRun struct {
+	ID           int64      `json:"id"`
+	ScheduleID   int64      `json:"schedule_id"`
+	Trigger      string     `json:"trigger"`
+	ScheduledFor time.Time  `json:"scheduled_for"`
+	DateFrom     string     `json:"date_from,omitempty"`
+	DateTo       string     `json:"date_to,omitempty"`
+	State        string     `json:"state"`
+	JobID        string     `json:"job_id,omitempty"`
+	DeliveredTo  string     `json:"delivered_to,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
+
+func createReportScheduleSchema(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS report_schedules (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  template_id INTEGER NOT NULL,
+  name TEXT NOT NULL UNIQUE,
+  cron TEXT NOT NULL,
+  timezone TEXT NOT NULL DEFAULT 'UTC',
+  range_kind TEXT NOT NULL DEFAULT 'template',
+  format TEXT NOT NULL DEFAULT 'csv',
+  delivery TEXT NOT NULL,
+  target TEXT NOT NULL DEFAULT '',
+  enabled INTEGER NOT NULL DEFAULT 1,
+  next_run_at DATETIME,
+  last_run_at DATETIME,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS report_schedule_runs (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  schedule_id INTEGER NOT NULL REFERENCES report_schedules(id) ON DELETE CASCADE,
+  trigger_kind TEXT NOT NULL,
+  scheduled_for DATETIME NOT NULL,
+  date_from TEXT NOT NULL DEFAULT '',
+  date_to TEXT NOT NULL DEFAULT '',
+  state TEXT NOT NULL,
+  job_id TEXT NOT NULL DEFAULT '',
+  delivered_to TEXT NOT NULL DEFAULT '',
+  error TEXT NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL,
+  finished_at DATETIME
+);
+`); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_rsr_schedule ON report_schedule_runs(schedule_id, scheduled_for);`); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_rsr_state ON report_schedule_runs(state);`); err != nil {
+		return err
+	}
+	return addColumn(ctx, db, "report_schedules", "params_json", `TEXT NOT NULL DEFAULT '{}'`)
+}
+
+const reportScheduleColumns = `id, template_id, name, cron, timezone, range_kind, format, delivery, target, params_json, enabled, next_run_at, last_run_at, created_at, updated_at`
+
+const reportScheduleRunColumns = `id, schedule_id, trigger_kind, scheduled_for, date_from, date_to, state, job_id, delivered_to, error, created_at, finished_at`
+
+func (s *Store) ListReportSchedules(ctx context.Context, limit int) ([]ReportSchedule, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := s.db.QueryContext(ctx, `
+SELECT `+reportScheduleColumns+`
+FROM report_schedules
+ORDER BY name ASC
+LIMIT ?;
+`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ReportSchedule, 0)
+	for rows.Next() {
+		item, err := scanReportSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *Store) GetReportSchedule(ctx context.Context, id int64) (*ReportSchedule, error) {
+	row := s.db.QueryRowContext(ctx, `
+SELECT `+reportScheduleColumns+`
+FROM report_schedules
+WHERE id = ?;
+`, id)
+	return scanReportSchedule(row)
+}
+
+// SaveReportSchedule inserts a new schedule when item.ID is 0, otherwise updates it.
+// Cron, timezone, range and delivery are validated by the caller.
+func (s *Store) SaveReportSchedule(ctx context.Context, item ReportSchedule) (int64, error) {
+	item.Name = strings.TrimSpace(item.Name)
+	if item.Name == "" {
+		return 0, fmt.Errorf("name is required")
+	}
+	if item.TemplateID <= 0 {
+		return 0, fmt.Errorf("template_id is required")
+	}
+
+	if item.ID > 0 {
+		res, err := s.db.ExecContext(ctx, `
+UPDATE report_schedules
+SET template_id = ?, name = ?, cron = ?, timezone = ?, range_kind = ?, format = ?, delivery = ?, target = ?, params_json = ?, enabled = ?, next_run_at = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+`, item.TemplateID, item.Name, item.Cron, item.Timezone, item.Range, item.Format, item.Delivery, item.Target, item.ParamsJSON, item.Enabled, nullTimeArg(item.NextRunAt), item.ID)
+		if err != nil {
+			return 0, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if affected == 0 {
+			return 0, sql.ErrNoRows
+		}
+		return item.ID, nil
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+INSERT INTO report_schedules (template_id, name, cron, timezone, range_kind, format, delivery, target, params_json, enabled, next_run_at, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+`, item.TemplateID, item.Name, item.Cron, item.Timezone, item.Range, item.Format, item.Delivery, item.Target, item.ParamsJSON, item.Enabled, nullTimeArg(item.NextRunAt))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// AdvanceReportSchedule records the last run time and the next due time.
+func (s *Store) AdvanceReportSchedule(ctx context.Context, id int64, lastRunAt, nextRunAt *time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+UPDATE report_schedules
+SET last_run_at = COALESCE(?, last_run_at), next_run_at = ?
+WHERE id = ?;
+`, nullTimeArg(lastRunAt), nullTimeArg(nextRunAt), id)
+	return err
+}
+
+// DeleteReportSchedule deletes a schedule and its run history.
+func (s *Store) DeleteReportSchedule(ctx context.Context, id int64) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM report_schedule_runs WHERE schedule_id = ?`, id); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM report_schedules WHERE id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return affected, tx.Commit()
+}
+
+// SaveReportScheduleRun inserts a run when item.ID is 0, otherwise updates it.
+func (s *Store) SaveReportScheduleRun(ctx context.Context, item ReportScheduleRun) (int64, error) {
+	if item.ID > 0 {
+		_, err := s.db.ExecContext(ctx, `
+UPDATE report_schedule_runs
+SET state = ?, job_id = ?, delivered_to = ?, error = ?, finished_at = ?
+WHERE id = ?;
+`, item.State, item.JobID, item.DeliveredTo, item.Error, nullTimeArg(item.FinishedAt), item.ID)
+		return item.ID, err
+	}
+	res, err := s.db.ExecContext(ctx, `
+INSERT INTO report_schedule_runs (schedule_id, trigger_kind, scheduled_for, date_from, date_to, state, job_id, delivered_to, error, created_at, finished_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+`, item.ScheduleID, item.Trigger, item.ScheduledFor.UTC(), item.DateFrom, item.DateTo, item.State, item.JobID, item.DeliveredTo, item.Error, item.CreatedAt.UTC(), nullTimeArg(item.FinishedAt))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// ListReportScheduleRuns returns runs newest first. scheduleID 0 lists the runs of
+// all schedules; states, when given, restricts them.
+func (s *Store) ListReportScheduleRuns(ctx context.Context, scheduleID int64, limit int, states []string) ([]ReportScheduleRun, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	query := `SELECT ` + reportScheduleRunColumns + ` FROM report_schedule_runs WHERE 1 = 1`
+	args := make([]any, 0, len(states)+2)
+	if scheduleID > 0 {
+		query += ` AND schedule_id = ?`
+		args = append(args, scheduleID)
+	}
+	if len(states) > 0 {
+		query += ` AND state IN (?` + strings.Repeat(", ?", len(states)-1) + `)`
+		for _, st := range states {
+			args = append(args, st)
+		}
+	}
+	query += ` ORDER BY scheduled_for DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ReportScheduleRun, 0)
+	for rows.Next() {
+		item, err := scanReportScheduleRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func scanReportSchedule(row rowScanner) (*ReportSchedule, error) {
+	var (
+		item      ReportSchedule
+		nextRunAt sql.NullTime
+		lastRunAt sql.NullTime
+		createdAt sql.NullTime
+		updatedAt sql.NullTime
+	)
+	if err := row.Scan(
+		&item.ID,
+		&item.TemplateID,
+		&item.Name,
+		&item.Cron,
+		&item.Timezone,
+		&item.Range,
+		&item.Format,
+		&item.Delivery,
+		&item.Target,
+		&item.ParamsJSON,
+		&item.Enabled,
+		&nextRunAt,
+		&lastRunAt,
+		&createdAt,
+		&updatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if nextRunAt.Valid {
+		t := nextRunAt.Time.UTC()
+		item.NextRunAt = &t
+	}
+	if lastRunAt.Valid {
+		t := lastRunAt.Time.UTC()
+		item.LastRunAt = &t
+	}
+	if createdAt.Valid {
+		t := createdAt.Time.UTC()
+		item.CreatedAt = &t
+	}
+	if updatedAt.Valid {
+		t := updatedAt.Time.UTC()
+		item.UpdatedAt = &t
+	}
+	return &item, nil
+}
+
+func scanReportScheduleRun(row rowScanner) (*ReportScheduleRun, error) {
+	var (
+		item       ReportScheduleRun
+		finishedAt sql.NullTime
+	)
+	if err := row.Scan(
+		&item.ID,
+		&item.ScheduleID,
+		&item.Trigger,
+		&item.ScheduledFor,
+		&item.DateFrom,
+		&item.DateTo,
+		&item.State,
+		&item.JobID,
+		&item.DeliveredTo,
+		&item.Error,
+		&item.CreatedAt,
+		&finishedAt,
+	); err != nil {
+		return nil, err
+	}
+	item.ScheduledFor = item.ScheduledFor.UTC()
+	item.CreatedAt = item.CreatedAt.UTC()
+	if finishedAt.Valid {
+		t := finishedAt.Time.UTC()
+		item.FinishedAt = &t
+	}
+	return &item, nil
+}
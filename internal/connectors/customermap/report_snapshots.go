@@ -0,0 +1,247 @@
+package customermap
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ReportSnapshot is a frozen report with the inputs it was computed from. The
+// content never changes after it is stored; only the official mark moves between
+// the snapshots of one customer and period.
+type ReportSnapshot struct {
+	ID          int64      `json:"id"`
+	CustomerID  string     `json:"customer_id"`
+	PeriodKind  string     `json:"period_kind"`
+	PeriodLabel string     `json:"period_label"`
+	PeriodStart time.Time  `json:"period_start"`
+	PeriodEnd   time.Time  `json:"period_end"`
+	Timezone    string     `json:"timezone"`
+	ReportJSON  string     `json:"report_json"`
+	InputsJSON  string     `json:"inputs_json"`
+	ContentHash string     `json:"content_hash"`
+	Note        string     `json:"note"`
+	Official    bool       `json:"official"`
+	OfficialAt  *time.Time `json:"official_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ReportSnapshotFilter narrows ListReportSnapshots; empty fields match all.
+type ReportSnapshotFilter struct {
+	CustomerID   string
+	PeriodLabel  string
+	OfficialOnly bool
+}
+
+func createReportSnapshotSchema(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS report_snapshots (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  customer_id TEXT NOT NULL,
+  period_kind TEXT NOT NULL,
+  period_label TEXT NOT NULL,
+  period_start DATETIME NOT NULL,
+  period_end DATETIME NOT NULL,
+  timezone TEXT NOT NULL,
+  report_json TEXT NOT NULL,
+  inputs_json TEXT NOT NULL,
+  content_hash TEXT NOT NULL,
+  note TEXT NOT NULL DEFAULT '',
+  official INTEGER NOT NULL DEFAULT 0,
+  official_at DATETIME,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_rs_customer_period ON report_snapshots(customer_id, period_start, period_end);`); err != nil {
+		return err
+	}
+	// At most one official snapshot per customer and period.
+	_, err := db.ExecContext(ctx, `CREATE UNIQUE INDEX IF NOT EXISTS idx_rs_official ON report_snapshots(customer_id, period_start, period_end) WHERE official = 1;`)
+	return err
+}
+
+// ReportSnapshotHash is the content hash of a snapshot: the SHA-256 of the report
+// and inputs JSON separated by a newline.
+func ReportSnapshotHash(reportJSON, inputsJSON string) string {
+	sum := sha256.Sum256([]byte(reportJSON + "\n" + inputsJSON))
+	return hex.EncodeToString(sum[:])
+}
+
+const reportSnapshotColumns = `id, customer_id, period_kind, period_label, period_start, period_end, timezone, report_json, inputs_json, content_hash, note, official, official_at, created_at`
+
+// InsertReportSnapshot stores a snapshot and computes its content hash. When the
+// customer and period already have a snapshot with the same hash, that snapshot is
+// returned instead and created is false. official marks the snapshot official.
+func (s *Store) InsertReportSnapshot(ctx context.Context, item ReportSnapshot, official bool) (*ReportSnapshot, bool, error) {
+	item.CustomerID = strings.TrimSpace(item.CustomerID)
+	item.Note = strings.TrimSpace(item.Note)
+	if item.PeriodLabel == "" || !item.PeriodEnd.After(item.PeriodStart) {
+		return nil, false, fmt.Errorf("snapshot period is required")
+	}
+	if item.ReportJSON == "" || item.InputsJSON == "" {
+		return nil, false, fmt.Errorf("snapshot report and inputs are required")
+	}
+	item.ContentHash = ReportSnapshotHash(item.ReportJSON, item.InputsJSON)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	created := false
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+SELECT id
+FROM report_snapshots
+WHERE customer_id = ? AND period_start = ? AND period_end = ? AND content_hash = ?
+ORDER BY id ASC
+LIMIT 1;
+`, item.CustomerID, item.PeriodStart.UTC(), item.PeriodEnd.UTC(), item.ContentHash).Scan(&id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		res, err := tx.ExecContext(ctx, `
+INSERT INTO report_snapshots (customer_id, period_kind, period_label, period_start, period_end, timezone, report_json, inputs_json, content_hash, note, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP);
+`, item.CustomerID, item.PeriodKind, item.PeriodLabel, item.PeriodStart.UTC(), item.PeriodEnd.UTC(), item.Timezone, item.ReportJSON, item.InputsJSON, item.ContentHash, item.Note)
+		if err != nil {
+			return nil, false, err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return nil, false, err
+		}
+		created = true
+	case err != nil:
+		return nil, false, err
+	}
+	if official {
+		if err := markReportSnapshotOfficial(ctx, tx, id, item.CustomerID, item.PeriodStart, item.PeriodEnd); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	saved, err := s.GetReportSnapshot(ctx, id)
+	return saved, created, err
+}
+
+func (s *Store) GetReportSnapshot(ctx context.Context, id int64) (*ReportSnapshot, error) {
+	row := s.db.QueryRowContext(ctx, `
+SELECT `+reportSnapshotColumns+`
+FROM report_snapshots
+WHERE id = ?;
+`, id)
+	return scanReportSnapshot(row)
+}
+
+// ListReportSnapshots returns snapshots newest first.
+func (s *Store) ListReportSnapshots(ctx context.Context, filter ReportSnapshotFilter, limit int) ([]ReportSnapshot, error) {
+	where := []string{"1 = 1"}
+	args := []any{}
+	if v := strings.TrimSpace(filter.CustomerID); v != "" {
+		where = append(where, "customer_id = ?")
+		args = append(args, v)
+	}
+	if v := strings.TrimSpace(filter.PeriodLabel); v != "" {
+		where = append(where, "period_label = ?")
+		args = append(args, v)
+	}
+	if filter.OfficialOnly {
+		where = append(where, "official = 1")
+	}
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(ctx, `
+SELECT `+reportSnapshotColumns+`
+FROM report_snapshots
+WHERE `+strings.Join(where, " AND ")+`
+ORDER BY created_at DESC, id DESC
+LIMIT ?;
+`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ReportSnapshot, 0)
+	for rows.Next() {
+		item, err := scanReportSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SetReportSnapshotOfficial marks a snapshot official, replacing the official
+// snapshot of the same customer and period, or removes its mark.
+func (s *Store) SetReportSnapshotOfficial(ctx context.Context, id int64, official bool) (*ReportSnapshot, error) {
+	item, err := s.GetReportSnapshot(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if official {
+		err = markReportSnapshotOfficial(ctx, tx, id, item.CustomerID, item.PeriodStart, item.PeriodEnd)
+	} else {
+		_, err = tx.ExecContext(ctx, `UPDATE report_snapshots SET official = 0, official_at = NULL WHERE id = ?`, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetReportSnapshot(ctx, id)
+}
+
+func markReportSnapshotOfficial(ctx context.Context, tx *sql.Tx, id int64, customerID string, start, end time.Time) error {
+	if _, err := tx.ExecContext(ctx, `
+UPDATE report_snapshots
+SET official = 0, official_at = NULL
+WHERE customer_id = ? AND period_start = ? AND period_end = ? AND official = 1 AND id <> ?;
+`, customerID, start.UTC(), end.UTC(), id); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `
+UPDATE report_snapshots
+SET official = 1, official_at = COALESCE(official_at, CURRENT_TIMESTAMP)
+WHERE id = ?;
+`, id)
+	return err
+}
+
+func scanReportSnapshot(row rowScanner) (*ReportSnapshot, error) {
+	var (
+		item       ReportSnapshot
+		official   int
+		officialAt sql.NullTime
+	)
+	if err := row.Scan(&item.ID, &item.CustomerID, &item.PeriodKind, &item.PeriodLabel, &item.PeriodStart, &item.PeriodEnd, &item.Timezone, &item.ReportJSON, &item.InputsJSON, &item.ContentHash, &item.Note, &official, &officialAt, &item.CreatedAt); err != nil {
+		return nil, err
+	}
+	item.Official = official == 1
+	item.PeriodStart = item.PeriodStart.UTC()
+	item.PeriodEnd = item.PeriodEnd.UTC()
+	item.CreatedAt = item.CreatedAt.UTC()
+	if officialAt.Valid {
+		t := officialAt.Time.UTC()
+		item.OfficialAt = &t
+	}
+	return &item, nil
+}
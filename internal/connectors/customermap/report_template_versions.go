@@ -0,0 +1,124 @@
+package customermap
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ReportTemplateVersion is an immutable snapshot of a report template, recorded
+// each time its content changes.
+type ReportTemplateVersion struct {
+	TemplateID  int64     `json:"template_id"`
+	Version     int       `json:"version"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Scope       string    `json:"scope"`
+	ConfigJSON  string    `json:"config_json"`
+	ParamsJSON  string    `json:"params_json"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// createReportTemplateVersionSchema adds parameters and versions to templates. Templates
+// saved before versioning become version 1.
+func createReportTemplateVersionSchema(ctx context.Context, db *sql.DB) error {
+	if err := addColumn(ctx, db, "report_templates", "params_json", `TEXT NOT NULL DEFAULT '[]'`); err != nil {
+		return err
+	}
+	if err := addColumn(ctx, db, "report_templates", "version", `INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS report_template_versions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  template_id INTEGER NOT NULL,
+  version INTEGER NOT NULL,
+  name TEXT NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  scope TEXT NOT NULL,
+  config_json TEXT NOT NULL,
+  params_json TEXT NOT NULL DEFAULT '[]',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE(template_id, version)
+);
+`); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `
+INSERT INTO report_template_versions (template_id, version, name, description, scope, config_json, params_json, created_at)
+SELECT id, 1, name, description, scope, config_json, params_json, updated_at
+FROM report_templates
+WHERE version = 0;
+`); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `UPDATE report_templates SET version = 1 WHERE version = 0;`)
+	return err
+}
+
+// addColumn adds a column to a table created by an earlier release; it does
+// nothing when the column exists.
+func addColumn(ctx context.Context, db *sql.DB, table, column, definition string) error {
+	var n int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, `ALTER TABLE `+table+` ADD COLUMN `+column+` `+definition)
+	return err
+}
+
+func insertReportTemplateVersion(ctx context.Context, tx *sql.Tx, item ReportTemplate) error {
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO report_template_versions (template_id, version, name, description, scope, config_json, params_json, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP);
+`, item.ID, item.Version, item.Name, item.Description, item.Scope, item.ConfigJSON, item.ParamsJSON)
+	return err
+}
+
+// ListReportTemplateVersions returns the versions of a template, newest first.
+func (s *Store) ListReportTemplateVersions(ctx context.Context, templateID int64) ([]ReportTemplateVersion, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT template_id, version, name, description, scope, config_json, params_json, created_at
+FROM report_template_versions
+WHERE template_id = ?
+ORDER BY version DESC;
+`, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ReportTemplateVersion, 0)
+	for rows.Next() {
+		item, err := scanReportTemplateVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *Store) GetReportTemplateVersion(ctx context.Context, templateID int64, version int) (*ReportTemplateVersion, error) {
+	row := s.db.QueryRowContext(ctx, `
+SELECT template_id, version, name, description, scope, config_json, params_json, created_at
+FROM report_template_versions
+WHERE template_id = ? AND version = ?;
+`, templateID, version)
+	return scanReportTemplateVersion(row)
+}
+
+func scanReportTemplateVersion(row rowScanner) (*ReportTemplateVersion, error) {
+	var item ReportTemplateVersion
+	if err := row.Scan(&item.TemplateID, &item.Version, &item.Name, &item.Description, &item.Scope, &item.ConfigJSON, &item.ParamsJSON, &item.CreatedAt); err != nil {
+		return nil, err
+	}
+	item.CreatedAt = item.CreatedAt.UTC()
+	return &item, nil
+}
@@ -0,0 +1,228 @@
+package customermap
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SLAPolicy is a per-customer processing time target. The policy with
+// customer_id "default" applies to customers without a policy of their own.
+type SLAPolicy struct {
+	ID             int64      `json:"id"`
+	CustomerID     string     `json:"customer_id"`
+	Name           string     `json:"name"`
+	StartMilestone string     `json:"start_milestone"`
+	EndMilestone   string     `json:"end_milestone"`
+	TargetSeconds  int64      `json:"target_seconds"`
+	TargetPercent  float64    `json:"target_percent"`
+	Exclusions     []string   `json:"exclusions"`
+	CreatedAt      *time.Time `json:"created_at,omitempty"`
+	UpdatedAt      *time.Time `json:"updated_at,omitempty"`
+}
+
+// Supported SLA milestones and exclusion rules.
+var (
+	SLAStartMilestones = []string{"transfer_start", "sip_start"}
+	SLAEndMilestones   = []string{"transfer_completed", "aip_stored"}
+	SLAExclusions      = []string{"awaiting_decision", "failed"}
+)
+
+func createSLAPolicySchema(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS sla_policies (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  customer_id TEXT NOT NULL UNIQUE,
+  name TEXT NOT NULL DEFAULT '',
+  start_milestone TEXT NOT NULL DEFAULT 'transfer_start',
+  end_milestone TEXT NOT NULL DEFAULT 'transfer_completed',
+  target_seconds INTEGER NOT NULL,
+  target_percent REAL NOT NULL DEFAULT 100,
+  exclusions_json TEXT NOT NULL DEFAULT '[]',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`)
+	return err
+}
+
+const slaPolicyColumns = `id, customer_id, name, start_milestone, end_milestone, target_seconds, target_percent, exclusions_json, created_at, updated_at`
+
+func (s *Store) ListSLAPolicies(ctx context.Context, limit int) ([]SLAPolicy, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT `+slaPolicyColumns+`
+FROM sla_policies
+ORDER BY customer_id ASC
+LIMIT ?;
+`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]SLAPolicy, 0)
+	for rows.Next() {
+		item, err := scanSLAPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *Store) GetSLAPolicy(ctx context.Context, id int64) (*SLAPolicy, error) {
+	row := s.db.QueryRowContext(ctx, `
+SELECT `+slaPolicyColumns+`
+FROM sla_policies
+WHERE id = ?;
+`, id)
+	return scanSLAPolicy(row)
+}
+
+// SLAPolicyForCustomer returns the customer's policy, falling back to the
+// "default" policy. It returns sql.ErrNoRows when neither exists.
+func (s *Store) SLAPolicyForCustomer(ctx context.Context, customerID string) (*SLAPolicy, error) {
+	row := s.db.QueryRowContext(ctx, `
+SELECT `+slaPolicyColumns+`
+FROM sla_policies
+WHERE customer_id IN (?, 'default')
+ORDER BY CASE WHEN customer_id = ? THEN 0 ELSE 1 END
+LIMIT 1;
+`, strings.TrimSpace(customerID), strings.TrimSpace(customerID))
+	return scanSLAPolicy(row)
+}
+
+// SaveSLAPolicy inserts a new policy when item.ID is 0, otherwise updates it.
+func (s *Store) SaveSLAPolicy(ctx context.Context, item SLAPolicy) (int64, error) {
+	item.CustomerID = strings.TrimSpace(item.CustomerID)
+	item.Name = strings.TrimSpace(item.Name)
+	item.StartMilestone = strings.ToLower(strings.TrimSpace(item.StartMilestone))
+	item.EndMilestone = strings.ToLower(strings.TrimSpace(item.EndMilestone))
+	if item.CustomerID == "" {
+		return 0, fmt.Errorf("customer_id is required")
+	}
+	if item.StartMilestone == "" {
+		item.StartMilestone = SLAStartMilestones[0]
+	}
+	if item.EndMilestone == "" {
+		item.EndMilestone = SLAEndMilestones[0]
+	}
+	if !containsString(SLAStartMilestones, item.StartMilestone) {
+		return 0, fmt.Errorf("unsupported start_milestone: %s", item.StartMilestone)
+	}
+	if !containsString(SLAEndMilestones, item.EndMilestone) {
+		return 0, fmt.Errorf("unsupported end_milestone: %s", item.EndMilestone)
+	}
+	if item.TargetSeconds <= 0 {
+		return 0, fmt.Errorf("target_seconds must be positive")
+	}
+	if item.TargetPercent == 0 {
+		item.TargetPercent = 100
+	}
+	if item.TargetPercent < 0 || item.TargetPercent > 100 {
+		return 0, fmt.Errorf("target_percent must be between 0 and 100")
+	}
+	exclusions := make([]string, 0, len(item.Exclusions))
+	for _, e := range item.Exclusions {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e == "" || containsString(exclusions, e) {
+			continue
+		}
+		if !containsString(SLAExclusions, e) {
+			return 0, fmt.Errorf("unsupported exclusion: %s", e)
+		}
+		exclusions = append(exclusions, e)
+	}
+	exclusionsJSON, err := json.Marshal(exclusions)
+	if err != nil {
+		return 0, err
+	}
+
+	if item.ID > 0 {
+		res, err := s.db.ExecContext(ctx, `
+UPDATE sla_policies
+SET customer_id = ?, name = ?, start_milestone = ?, end_milestone = ?, target_seconds = ?, target_percent = ?, exclusions_json = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+`, item.CustomerID, item.Name, item.StartMilestone, item.EndMilestone, item.TargetSeconds, item.TargetPercent, string(exclusionsJSON), item.ID)
+		if err != nil {
+			return 0, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if affected == 0 {
+			return 0, sql.ErrNoRows
+		}
+		return item.ID, nil
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+INSERT INTO sla_policies (customer_id, name, start_milestone, end_milestone, target_seconds, target_percent, exclusions_json, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+`, item.CustomerID, item.Name, item.StartMilestone, item.EndMilestone, item.TargetSeconds, item.TargetPercent, string(exclusionsJSON))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *Store) DeleteSLAPolicy(ctx context.Context, id int64) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM sla_policies WHERE id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanSLAPolicy(row rowScanner) (*SLAPolicy, error) {
+	var (
+		item           SLAPolicy
+		exclusionsJSON string
+		createdAt      sql.NullTime
+		updatedAt      sql.NullTime
+	)
+	if err := row.Scan(
+		&item.ID,
+		&item.CustomerID,
+		&item.Name,
+		&item.StartMilestone,
+		&item.EndMilestone,
+		&item.TargetSeconds,
+		&item.TargetPercent,
+		&exclusionsJSON,
+		&createdAt,
+		&updatedAt,
+	); err != nil {
+		return nil, err
+	}
+	item.Exclusions = []string{}
+	if strings.TrimSpace(exclusionsJSON) != "" {
+		_ = json.Unmarshal([]byte(exclusionsJSON), &item.Exclusions)
+	}
+	if createdAt.Valid {
+		t := createdAt.Time.UTC()
+		item.CreatedAt = &t
+	}
+	if updatedAt.Valid {
+		t := updatedAt.Time.UTC()
+		item.UpdatedAt = &t
+	}
+	return &item, nil
+}
+
+func containsString(list []string, v string) bool {
+	for _, it := range list {
+		if it == v {
+			return true
+		}
+	}
+	return false
+}
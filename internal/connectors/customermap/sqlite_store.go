@@ -11,10 +11,13 @@ import (
 	_ "modernc.org/sqlite"
 )
 
-// Summary represents one customer and number of mapped sources.
+// Summary represents one customer, its parent and number of mapped sources and rules.
 type Summary struct {
 	CustomerID  string `json:"customer_id"`
+	DisplayName string `json:"display_name"`
+	ParentID    string `json:"parent_id"`
 	SourceCount int64  `json:"source_count"`
+	RuleCount   int64  `json:"rule_count"`
 }
 
 // Store manages customer/source mappings in SQLite.
@@ -29,6 +32,8 @@ type ReportTemplate struct {
 	Description string     `json:"description"`
 	Scope       string     `json:"scope"`
 	ConfigJSON  string     `json:"config_json"`
+	ParamsJSON  string     `json:"params_json"`
+	Version     int        `json:"version"`
 	CreatedAt   *time.Time `json:"created_at,omitempty"`
 	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
 }
@@ -93,6 +98,50 @@ CREATE TABLE IF NOT EXISTS report_templates (
 		_ = db.Close()
 		return nil, err
 	}
+	if err := createFailureKnowledgeSchema(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := createSLAPolicySchema(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := createPricePlanSchema(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := createBillingRunSchema(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := createReportJobSchema(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := createReportScheduleSchema(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := createReportTemplateVersionSchema(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := createReportSnapshotSchema(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := createMappingRuleSchema(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := createMappingHistorySchema(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := createCustomerSchema(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
 
 	return &Store{db: db}, nil
 }
@@ -106,10 +155,17 @@ func (s *Store) Close() error {
 
 func (s *Store) ListCustomers(ctx context.Context, limit int) ([]Summary, error) {
 	rows, err := s.db.QueryContext(ctx, `
-SELECT customer_id, COUNT(*)
-FROM customer_transfer_sources
-GROUP BY customer_id
-ORDER BY customer_id
+SELECT u.customer_id, COALESCE(c.display_name, ''), COALESCE(c.parent_id, ''), SUM(u.is_source), SUM(u.is_rule)
+FROM (
+  SELECT id AS customer_id, 0 AS is_source, 0 AS is_rule FROM customers
+  UNION ALL
+  SELECT customer_id, 1 AS is_source, 0 AS is_rule FROM customer_transfer_sources
+  UNION ALL
+  SELECT customer_id, 0 AS is_source, 1 AS is_rule FROM customer_mapping_rules
+) u
+LEFT JOIN customers c ON c.id = u.customer_id
+GROUP BY u.customer_id
+ORDER BY u.customer_id
 LIMIT ?;
 `, limit)
 	if err != nil {
@@ -120,7 +176,7 @@ LIMIT ?;
 	out := make([]Summary, 0, limit)
 	for rows.Next() {
 		var item Summary
-		if err := rows.Scan(&item.CustomerID, &item.SourceCount); err != nil {
+		if err := rows.Scan(&item.CustomerID, &item.DisplayName, &item.ParentID, &item.SourceCount, &item.RuleCount); err != nil {
 			return nil, err
 		}
 		out = append(out, item)
@@ -161,11 +217,7 @@ ORDER BY source_of_acquisition;
 }
 
 func (s *Store) CreateMapping(ctx context.Context, customerID, source string) error {
-	_, err := s.db.ExecContext(ctx, `
-INSERT INTO customer_transfer_sources (customer_id, source_of_acquisition)
-VALUES (?, ?)
-ON CONFLICT(customer_id, source_of_acquisition) DO NOTHING;
-`, strings.TrimSpace(customerID), strings.TrimSpace(source))
+	_, err := s.AddMapping(ctx, Mapping{CustomerID: customerID, SourceOfAcquisition: source})
 	return err
 }
 
@@ -177,17 +229,21 @@ func (s *Store) ReplaceMappings(ctx context.Context, customerID string, sources
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	if _, err := tx.ExecContext(ctx, `DELETE FROM customer_transfer_sources WHERE customer_id = ?`, customerID); err != nil {
+	// Kept sources keep their validity windows; only changes are recorded.
+	norm := normalizeSources(sources)
+	where := `customer_id = ?`
+	args := []any{customerID}
+	if len(norm) > 0 {
+		where += ` AND source_of_acquisition NOT IN (?` + strings.Repeat(`, ?`, len(norm)-1) + `)`
+		for _, src := range norm {
+			args = append(args, src)
+		}
+	}
+	if _, err := deleteMappings(ctx, tx, where, args...); err != nil {
 		return 0, err
 	}
-
-	norm := normalizeSources(sources)
 	for _, src := range norm {
-		if _, err := tx.ExecContext(ctx, `
-INSERT INTO customer_transfer_sources (customer_id, source_of_acquisition)
-VALUES (?, ?)
-ON CONFLICT(customer_id, source_of_acquisition) DO NOTHING;
-`, customerID, src); err != nil {
+		if _, err := insertMapping(ctx, tx, Mapping{CustomerID: customerID, SourceOfAcquisition: src}); err != nil {
 			return 0, err
 		}
 	}
@@ -199,19 +255,25 @@ ON CONFLICT(customer_id, source_of_acquisition) DO NOTHING;
 }
 
 func (s *Store) DeleteMapping(ctx context.Context, customerID, source string) (int64, error) {
-	res, err := s.db.ExecContext(ctx, `DELETE FROM customer_transfer_sources WHERE customer_id = ? AND source_of_acquisition = ?`, strings.TrimSpace(customerID), strings.TrimSpace(source))
+	return s.deleteMappings(ctx, `customer_id = ? AND source_of_acquisition = ?`, strings.TrimSpace(customerID), strings.TrimSpace(source))
+}
+
+func (s *Store) DeleteAllMappings(ctx context.Context, customerID string) (int64, error) {
+	return s.deleteMappings(ctx, `customer_id = ?`, strings.TrimSpace(customerID))
+}
+
+func (s *Store) deleteMappings(ctx context.Context, where string, args ...any) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
-	return res.RowsAffected()
-}
+	defer func() { _ = tx.Rollback() }()
 
-func (s *Store) DeleteAllMappings(ctx context.Context, customerID string) (int64, error) {
-	res, err := s.db.ExecContext(ctx, `DELETE FROM customer_transfer_sources WHERE customer_id = ?`, strings.TrimSpace(customerID))
+	n, err := deleteMappings(ctx, tx, where, args...)
 	if err != nil {
 		return 0, err
 	}
-	return res.RowsAffected()
+	return n, tx.Commit()
 }
 
 func normalizeSources(sources []string) []string {
@@ -233,7 +295,7 @@ func normalizeSources(sources []string) []string {
 
 func (s *Store) ListReportTemplates(ctx context.Context, limit int) ([]ReportTemplate, error) {
 	rows, err := s.db.QueryContext(ctx, `
-SELECT id, name, description, scope, config_json, created_at, updated_at
+SELECT id, name, description, scope, config_json, params_json, version, created_at, updated_at
 FROM report_templates
 ORDER BY name ASC
 LIMIT ?;
@@ -243,14 +305,14 @@ LIMIT ?;
 	}
 	defer rows.Close()
 
-	out := make([]ReportTemplate, 0, limit)
+	out := make([]ReportTemplate, 0, max(limit, 0))
 	for rows.Next() {
 		var (
 			item      ReportTemplate
 			createdAt sql.NullTime
 			updatedAt sql.NullTime
 		)
-		if err := rows.Scan(&item.ID, &item.Name, &item.Description, &item.Scope, &item.ConfigJSON, &createdAt, &updatedAt); err != nil {
+		if err := rows.Scan(&item.ID, &item.Name, &item.Description, &item.Scope, &item.ConfigJSON, &item.ParamsJSON, &item.Version, &createdAt, &updatedAt); err != nil {
 			return nil, err
 		}
 		if createdAt.Valid {
@@ -276,10 +338,10 @@ func (s *Store) GetReportTemplate(ctx context.Context, id int64) (*ReportTemplat
 		updatedAt sql.NullTime
 	)
 	err := s.db.QueryRowContext(ctx, `
-SELECT id, name, description, scope, config_json, created_at, updated_at
+SELECT id, name, description, scope, config_json, params_json, version, created_at, updated_at
 FROM report_templates
 WHERE id = ?;
-`, id).Scan(&item.ID, &item.Name, &item.Description, &item.Scope, &item.ConfigJSON, &createdAt, &updatedAt)
+`, id).Scan(&item.ID, &item.Name, &item.Description, &item.Scope, &item.ConfigJSON, &item.ParamsJSON, &item.Version, &createdAt, &updatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -294,52 +356,113 @@ WHERE id = ?;
 	return &item, nil
 }
 
-func (s *Store) UpsertReportTemplate(ctx context.Context, name, description, scope, configJSON string) (int64, error) {
-	name = strings.TrimSpace(name)
-	description = strings.TrimSpace(description)
-	scope = strings.ToLower(strings.TrimSpace(scope))
-	configJSON = strings.TrimSpace(configJSON)
-	if name == "" {
-		return 0, fmt.Errorf("template name is required")
-	}
-	if scope == "" {
-		scope = "transfer"
-	}
-	if scope != "transfer" {
-		return 0, fmt.Errorf("unsupported scope: %s", scope)
-	}
-	if configJSON == "" {
-		return 0, fmt.Errorf("config_json is required")
-	}
-
-	res, err := s.db.ExecContext(ctx, `
-INSERT INTO report_templates (name, description, scope, config_json, created_at, updated_at)
-VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-ON CONFLICT(name) DO UPDATE SET
-  description = excluded.description,
-  scope = excluded.scope,
-  config_json = excluded.config_json,
-  updated_at = CURRENT_TIMESTAMP;
-`, name, description, scope, configJSON)
-	if err != nil {
-		return 0, err
+// Template save outcomes.
+const (
+	TemplateCreated   = "created"
+	TemplateUpdated   = "updated"
+	TemplateUnchanged = "unchanged"
+)
+
+// UpsertReportTemplate creates or updates the template named item.Name. Every
+// change is recorded as a new immutable version; saving identical content keeps
+// the current version and reports TemplateUnchanged.
+func (s *Store) UpsertReportTemplate(ctx context.Context, item ReportTemplate) (*ReportTemplate, string, error) {
+	item.Name = strings.TrimSpace(item.Name)
+	item.Description = strings.TrimSpace(item.Description)
+	item.Scope = strings.ToLower(strings.TrimSpace(item.Scope))
+	item.ConfigJSON = strings.TrimSpace(item.ConfigJSON)
+	item.ParamsJSON = strings.TrimSpace(item.ParamsJSON)
+	if item.Name == "" {
+		return nil, "", fmt.Errorf("template name is required")
+	}
+	if item.Scope == "" {
+		item.Scope = "transfer"
+	}
+	switch item.Scope {
+	case "transfer", "sip", "aip", "file":
+	default:
+		return nil, "", fmt.Errorf("unsupported scope: %s", item.Scope)
+	}
+	if item.ConfigJSON == "" {
+		return nil, "", fmt.Errorf("config_json is required")
+	}
+	if item.ParamsJSON == "" {
+		item.ParamsJSON = "[]"
 	}
-	id, err := res.LastInsertId()
-	if err == nil && id > 0 {
-		return id, nil
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	var existingID int64
-	if err := s.db.QueryRowContext(ctx, `SELECT id FROM report_templates WHERE name = ?`, name).Scan(&existingID); err != nil {
-		return 0, err
+	var current ReportTemplate
+	err = tx.QueryRowContext(ctx, `
+SELECT id, description, scope, config_json, params_json, version
+FROM report_templates
+WHERE name = ?;
+`, item.Name).Scan(&current.ID, &current.Description, &current.Scope, &current.ConfigJSON, &current.ParamsJSON, &current.Version)
+	outcome := TemplateUpdated
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		outcome = TemplateCreated
+		res, err := tx.ExecContext(ctx, `
+INSERT INTO report_templates (name, description, scope, config_json, params_json, version, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+`, item.Name, item.Description, item.Scope, item.ConfigJSON, item.ParamsJSON)
+		if err != nil {
+			return nil, "", err
+		}
+		if item.ID, err = res.LastInsertId(); err != nil {
+			return nil, "", err
+		}
+		item.Version = 1
+	case err != nil:
+		return nil, "", err
+	case current.Description == item.Description && current.Scope == item.Scope && current.ConfigJSON == item.ConfigJSON && current.ParamsJSON == item.ParamsJSON:
+		if err := tx.Commit(); err != nil {
+			return nil, "", err
+		}
+		saved, err := s.GetReportTemplate(ctx, current.ID)
+		return saved, TemplateUnchanged, err
+	default:
+		item.ID = current.ID
+		item.Version = current.Version + 1
+		if _, err := tx.ExecContext(ctx, `
+UPDATE report_templates
+SET description = ?, scope = ?, config_json = ?, params_json = ?, version = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+`, item.Description, item.Scope, item.ConfigJSON, item.ParamsJSON, item.Version, item.ID); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := insertReportTemplateVersion(ctx, tx, item); err != nil {
+		return nil, "", err
 	}
-	return existingID, nil
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+	saved, err := s.GetReportTemplate(ctx, item.ID)
+	return saved, outcome, err
 }
 
+// DeleteReportTemplate deletes a template with its version history.
 func (s *Store) DeleteReportTemplate(ctx context.Context, id int64) (int64, error) {
-	res, err := s.db.ExecContext(ctx, `DELETE FROM report_templates WHERE id = ?`, id)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM report_template_versions WHERE template_id = ?`, id); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM report_templates WHERE id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
 	if err != nil {
 		return 0, err
 	}
-	return res.RowsAffected()
+	return affected, tx.Commit()
 }
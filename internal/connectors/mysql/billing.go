@@ -0,0 +1,766 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+	"go-am-realtime-report-ui/internal/dbctx"
+)
+
+// billingBytesPerGB uses decimal gigabytes, as storage is usually invoiced.
+const billingBytesPerGB = 1e9
+
+// Billing line item kinds.
+const (
+	BillingItemStorage   = "storage"
+	BillingItemIngest    = "ingest"
+	BillingItemTransfers = "transfers"
+)
+
+// ErrBillingRunApproved is returned when changing or deleting an approved run.
+var ErrBillingRunApproved = customermap.ErrBillingRunApproved
+
+// PriceTier prices the GB between the previous tier's UpToGB and its own; UpToGB
+// 0 is unbounded.
+type PriceTier struct {
+	UpToGB     float64 `json:"up_to_gb"`
+	PricePerGB float64 `json:"price_per_gb"`
+}
+
+// PricePlan is a per-customer chargeback plan from the app SQLite store.
+type PricePlan struct {
+	ID               int64       `json:"id"`
+	CustomerID       string      `json:"customer_id"`
+	Name             string      `json:"name"`
+	Currency         string      `json:"currency"`
+	PerGBStoredMonth float64     `json:"per_gb_stored_month"`
+	PerGBIngested    float64     `json:"per_gb_ingested"`
+	PerTransfer      float64     `json:"per_transfer"`
+	StoredTiers      []PriceTier `json:"stored_tiers"`
+	IngestedTiers    []PriceTier `json:"ingested_tiers"`
+	CreatedAt        string      `json:"created_at,omitempty"`
+	UpdatedAt        string      `json:"updated_at,omitempty"`
+}
+
+// BillingLineItem is one priced charge on a customer's bill.
+type BillingLineItem struct {
+	CustomerID  string  `json:"customer_id"`
+	PlanID      int64   `json:"plan_id"`
+	PlanName    string  `json:"plan_name"`
+	Item        string  `json:"item"`
+	Description string  `json:"description"`
+	Quantity    float64 `json:"quantity"`
+	Unit        string  `json:"unit"`
+	UnitPrice   float64 `json:"unit_price"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+}
+
+// BillingUsage is the volume a customer is billed for in a run. StoredBytes is
+// the volume at period end; storage is charged from StoredMonths.
+type BillingUsage struct {
+	CustomerID    string                `json:"customer_id"`
+	StoredBytes   int64                 `json:"stored_bytes"`
+	IngestedBytes int64                 `json:"ingested_bytes"`
+	Transfers     int64                 `json:"transfers"`
+	StoredGB      float64               `json:"stored_gb"`
+	IngestedGB    float64               `json:"ingested_gb"`
+	StoredMonths  []BillingStorageMonth `json:"stored_months"`
+	PlanID        int64                 `json:"plan_id"`
+	Priced        bool                  `json:"priced"`
+}
+
+// BillingStorageMonth is the volume stored at the end of one calendar month of a
+// run, charged for Months of a month (less than 1 when the run covers part of it).
+type BillingStorageMonth struct {
+	Month    string  `json:"month"`
+	StoredGB float64 `json:"stored_gb"`
+	Months   float64 `json:"months"`
+}
+
+// BillingAmbiguousSource is the usage of a source whose mappings overlap: it was
+// attributed to several of CustomerIDs at once and is not billed to any of them.
+type BillingAmbiguousSource struct {
+	SourceOfAcquisition string   `json:"source_of_acquisition"`
+	CustomerIDs         []string `json:"customer_ids"`
+	StoredBytes         int64    `json:"stored_bytes"`
+	IngestedBytes       int64    `json:"ingested_bytes"`
+	Transfers           int64    `json:"transfers"`
+}
+
+// BillingSummary records the usage a run was priced from. Months is the period
+// length in months.
+type BillingSummary struct {
+	Months    float64                  `json:"months"`
+	Customers []BillingUsage           `json:"customers"`
+	Unpriced  []string                 `json:"unpriced_customers"`
+	Unmapped  *BillingUsage            `json:"unmapped,omitempty"`
+	Ambiguous []BillingAmbiguousSource `json:"ambiguous_sources"`
+}
+
+// BillingCustomerTotal is a customer's bill total in one currency.
+type BillingCustomerTotal struct {
+	CustomerID string  `json:"customer_id"`
+	Currency   string  `json:"currency"`
+	Amount     float64 `json:"amount"`
+}
+
+// BillingRun is a period's chargeback run. Approved runs are frozen.
+type BillingRun struct {
+	ID             int64                  `json:"id"`
+	PeriodKind     string                 `json:"period_kind"`
+	PeriodLabel    string                 `json:"period_label"`
+	Timezone       string                 `json:"timezone"`
+	PeriodStart    string                 `json:"period_start"`
+	PeriodEnd      string                 `json:"period_end"`
+	Status         string                 `json:"status"`
+	ApprovedBy     string                 `json:"approved_by,omitempty"`
+	ApprovedAt     string                 `json:"approved_at,omitempty"`
+	CreatedAt      string                 `json:"created_at,omitempty"`
+	UpdatedAt      string                 `json:"updated_at,omitempty"`
+	Summary        *BillingSummary        `json:"summary,omitempty"`
+	Lines          []BillingLineItem      `json:"lines,omitempty"`
+	CustomerTotals []BillingCustomerTotal `json:"customer_totals,omitempty"`
+	Totals         map[string]float64     `json:"totals,omitempty"`
+}
+
+func (s *Store) ListPricePlans(ctx context.Context, limit int) ([]PricePlan, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	items, err := store.ListPricePlans(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PricePlan, 0, len(items))
+	for _, it := range items {
+		out = append(out, pricePlanFromStore(it))
+	}
+	return out, nil
+}
+
+func (s *Store) GetPricePlan(ctx context.Context, id int64) (*PricePlan, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	it, err := store.GetPricePlan(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	out := pricePlanFromStore(*it)
+	return &out, nil
+}
+
+func (s *Store) SavePricePlan(ctx context.Context, item PricePlan) (int64, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return 0, err
+	}
+	return store.SavePricePlan(ctx, customermap.PricePlan{
+		ID:               item.ID,
+		CustomerID:       item.CustomerID,
+		Name:             item.Name,
+		Currency:         item.Currency,
+		PerGBStoredMonth: item.PerGBStoredMonth,
+		PerGBIngested:    item.PerGBIngested,
+		PerTransfer:      item.PerTransfer,
+		StoredTiers:      priceTiersToStore(item.StoredTiers),
+		IngestedTiers:    priceTiersToStore(item.IngestedTiers),
+	})
+}
+
+func (s *Store) DeletePricePlan(ctx context.Context, id int64) (int64, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return 0, err
+	}
+	return store.DeletePricePlan(ctx, id)
+}
+
+func (s *Store) ListBillingRuns(ctx context.Context, limit int) ([]BillingRun, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	items, err := store.ListBillingRuns(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]BillingRun, 0, len(items))
+	for _, it := range items {
+		out = append(out, billingRunFromStore(it, false))
+	}
+	return out, nil
+}
+
+// GetBillingRun returns a run with its summary, line items and totals.
+func (s *Store) GetBillingRun(ctx context.Context, id int64) (*BillingRun, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	it, err := store.GetBillingRun(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	out := billingRunFromStore(*it, true)
+	return &out, nil
+}
+
+func (s *Store) ApproveBillingRun(ctx context.Context, id int64, approvedBy string) error {
+	store, err := s.templateStore()
+	if err != nil {
+		return err
+	}
+	return store.ApproveBillingRun(ctx, id, approvedBy)
+}
+
+func (s *Store) DeleteBillingRun(ctx context.Context, id int64) (int64, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return 0, err
+	}
+	return store.DeleteBillingRun(ctx, id)
+}
+
+// CreateBillingRun prices every mapped customer's usage in period and stores the
+// result as the period's draft run, replacing an earlier draft. packages are the
+// stored packages from the Storage Service, as for GetStorageConsumption. Usage
+// that mappings valid at the same transfer start attribute to several payers is
+// listed in the summary but not billed.
+func (s *Store) CreateBillingRun(ctx context.Context, period ReportPeriod, packages []StoragePackage) (int64, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return 0, err
+	}
+	sipTransfers, err := s.packageTransfers(ctx, packages)
+	if err != nil {
+		return 0, err
+	}
+	attribution, err := s.customerAttribution(ctx)
+	if err != nil {
+		return 0, err
+	}
+	transfers, err := s.completedTransfers(ctx, period)
+	if err != nil {
+		return 0, err
+	}
+
+	var payers map[string]string
+	if attribution != nil {
+		if payers, err = billingPayers(ctx, store, attribution.customerIDs()); err != nil {
+			return 0, err
+		}
+	}
+
+	months := billingPeriodMonths(period)
+	usage, ambiguous := billingUsage(packages, sipTransfers, transfers, billingAttribution{attribution: attribution, payers: payers}, period, months)
+	summary := &BillingSummary{
+		Months:    billingMonths(months),
+		Customers: make([]BillingUsage, 0, len(usage)),
+		Unpriced:  []string{},
+		Ambiguous: ambiguous,
+	}
+	plans := map[string]*PricePlan{}
+	for _, u := range usage {
+		if u.CustomerID == forecastUnmappedCustomer {
+			unmapped := u
+			summary.Unmapped = &unmapped
+			continue
+		}
+		it, err := store.PricePlanForCustomer(ctx, u.CustomerID)
+		switch {
+		case err == nil:
+			plan := pricePlanFromStore(*it)
+			plans[u.CustomerID] = &plan
+			u.PlanID = plan.ID
+			u.Priced = true
+		case errors.Is(err, sql.ErrNoRows):
+			summary.Unpriced = append(summary.Unpriced, u.CustomerID)
+		default:
+			return 0, err
+		}
+		summary.Customers = append(summary.Customers, u)
+	}
+
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return 0, err
+	}
+	lines := computeBillingLines(summary.Customers, plans)
+	storeLines := make([]customermap.BillingLineItem, 0, len(lines))
+	for _, l := range lines {
+		storeLines = append(storeLines, customermap.BillingLineItem(l))
+	}
+	return store.SaveBillingRunDraft(ctx, customermap.BillingRun{
+		PeriodKind:  period.Kind,
+		PeriodLabel: period.Label,
+		Timezone:    period.Timezone,
+		PeriodStart: period.Start,
+		PeriodEnd:   period.End,
+		SummaryJSON: string(summaryJSON),
+		Lines:       storeLines,
+	})
+}
+
+// completedTransfers lists the successful transfers completed in period with the
+// fields customerAttribution needs.
+func (s *Store) completedTransfers(ctx context.Context, period ReportPeriod) ([]mappingRuleTransfer, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT
+  t.transferUUID,
+  COALESCE(t.currentLocation, ''),
+  COALESCE(t.sourceOfAcquisition, ''),
+  COALESCE(t.accessionID, ''),
+  t.completed_at,
+  `+transferFirstJobExpr+`
+FROM Transfers t
+WHERE t.completed_at >= ?
+  AND t.completed_at < ?
+  AND t.status IN (2, 3);
+`, period.Start, period.End)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]mappingRuleTransfer, 0)
+	for rows.Next() {
+		var (
+			tr                    mappingRuleTransfer
+			completedAt, firstJob sql.NullTime
+		)
+		if err := rows.Scan(&tr.UUID, &tr.Location, &tr.Source, &tr.Accession, &completedAt, &firstJob); err != nil {
+			return nil, err
+		}
+		tr.Source = strings.TrimSpace(tr.Source)
+		tr.CompletedAt = nullTimePtr(completedAt)
+		tr.StartedAt = transferStart(firstJob, completedAt)
+		out = append(out, tr)
+	}
+	return out, rows.Err()
+}
+
+// billingAttribution decides who pays for a transfer or a package built from it:
+// the customers whose mappings are valid at the transfer start or, when none is,
+// the customer of the winning rule. A customer without a price plan of its own is
+// replaced by its payer, see billingPayers.
+type billingAttribution struct {
+	attribution *customerAttribution
+	payers      map[string]string
+}
+
+// customersFor returns the payers of tr; more than one means the mappings valid at
+// its start overlap and the usage is ambiguous. attribution may be nil, see
+// customerAttribution.
+func (b billingAttribution) customersFor(tr mappingRuleTransfer) []string {
+	if b.attribution == nil {
+		return forecastCustomersFor(tr.Source, nil)
+	}
+	ids := b.attribution.mappedOwners(tr)
+	if len(ids) == 0 {
+		if id := b.attribution.ruleOwner(tr); id != "" {
+			ids = []string{id}
+		}
+	}
+	if len(ids) == 0 {
+		return []string{forecastUnmappedCustomer}
+	}
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if payer, ok := b.payers[id]; ok {
+			id = payer
+		}
+		if !containsKey(out, id) {
+			out = append(out, id)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// billingPayers maps each of ids that has no price plan of its own to its nearest
+// ancestor that has one, so that members are billed on their parent's invoice.
+// Customers without such an ancestor pay for themselves and are not listed.
+func billingPayers(ctx context.Context, store *customermap.Store, ids []string) (map[string]string, error) {
+	plans, err := store.ListPricePlans(ctx, -1)
+	if err != nil {
+		return nil, err
+	}
+	planned := make(map[string]bool, len(plans))
+	for _, p := range plans {
+		planned[p.CustomerID] = true
+	}
+
+	out := map[string]string{}
+	for _, id := range ids {
+		if planned[id] {
+			continue
+		}
+		seen := map[string]bool{id: true}
+		for cur := id; ; {
+			c, err := store.GetCustomer(ctx, cur)
+			if errors.Is(err, sql.ErrNoRows) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if c.ParentID == "" || seen[c.ParentID] {
+				break
+			}
+			if planned[c.ParentID] {
+				out[id] = c.ParentID
+				break
+			}
+			seen[c.ParentID] = true
+			cur = c.ParentID
+		}
+	}
+	return out, nil
+}
+
+// billingMonth is the part of a run's period in one calendar month.
+type billingMonth struct {
+	label  string
+	end    time.Time
+	months float64
+}
+
+// billingPeriodMonths splits period at the calendar months of its time zone. A
+// month the period covers in part counts for the covered share of its length.
+func billingPeriodMonths(period ReportPeriod) []billingMonth {
+	loc := period.Location()
+	out := make([]billingMonth, 0)
+	for start := period.Start.In(loc); start.Before(period.End); {
+		y, m, _ := start.Date()
+		monthStart := time.Date(y, m, 1, 0, 0, 0, 0, loc)
+		monthEnd := monthStart.AddDate(0, 1, 0)
+		end := monthEnd
+		if period.End.Before(end) {
+			end = period.End.In(loc)
+		}
+		out = append(out, billingMonth{
+			label:  monthStart.Format("2006-01"),
+			end:    end.UTC(),
+			months: round4(end.Sub(start).Hours() / monthEnd.Sub(monthStart).Hours()),
+		})
+		start = end
+	}
+	return out
+}
+
+// billingUsage attributes every package stored before period.End and every
+// transfer to its payer and returns the usage per customer, sorted by ID. Packages
+// and transfers with several payers are not billed to any of them and are returned
+// per source, sorted by source. sipTransfers maps lower-case SIP UUIDs to the
+// transfer each package is attributed through. Stored volume is taken at the end
+// of each of months.
+func billingUsage(packages []StoragePackage, sipTransfers map[string]mappingRuleTransfer, transfers []mappingRuleTransfer, payers billingAttribution, period ReportPeriod, months []billingMonth) ([]BillingUsage, []BillingAmbiguousSource) {
+	byCustomer := map[string]*BillingUsage{}
+	monthBytes := map[string][]int64{}
+	ambiguous := map[string]*BillingAmbiguousSource{}
+	get := func(id string) *BillingUsage {
+		u, ok := byCustomer[id]
+		if !ok {
+			u = &BillingUsage{CustomerID: id}
+			byCustomer[id] = u
+			monthBytes[id] = make([]int64, len(months))
+		}
+		return u
+	}
+	getAmbiguous := func(source string, ids []string) *BillingAmbiguousSource {
+		a, ok := ambiguous[source]
+		if !ok {
+			a = &BillingAmbiguousSource{SourceOfAcquisition: source}
+			ambiguous[source] = a
+		}
+		for _, id := range ids {
+			if !containsKey(a.CustomerIDs, id) {
+				a.CustomerIDs = append(a.CustomerIDs, id)
+			}
+		}
+		return a
+	}
+
+	for _, p := range packages {
+		if !p.StoredAt.Before(period.End) {
+			continue
+		}
+		ingested := !p.StoredAt.Before(period.Start)
+		ids := []string{forecastUnmappedCustomer}
+		tr, ok := sipTransfers[strings.ToLower(p.SIPUUID)]
+		if ok {
+			ids = payers.customersFor(tr)
+		}
+		if len(ids) > 1 {
+			a := getAmbiguous(tr.Source, ids)
+			a.StoredBytes += p.SizeBytes
+			if ingested {
+				a.IngestedBytes += p.SizeBytes
+			}
+			continue
+		}
+		u := get(ids[0])
+		u.StoredBytes += p.SizeBytes
+		if ingested {
+			u.IngestedBytes += p.SizeBytes
+		}
+		for i, m := range months {
+			if p.StoredAt.Before(m.end) {
+				monthBytes[ids[0]][i] += p.SizeBytes
+			}
+		}
+	}
+	for _, tr := range transfers {
+		if ids := payers.customersFor(tr); len(ids) > 1 {
+			getAmbiguous(tr.Source, ids).Transfers++
+		} else {
+			get(ids[0]).Transfers++
+		}
+	}
+
+	out := make([]BillingUsage, 0, len(byCustomer))
+	for id, u := range byCustomer {
+		u.StoredGB = round4(float64(u.StoredBytes) / billingBytesPerGB)
+		u.IngestedGB = round4(float64(u.IngestedBytes) / billingBytesPerGB)
+		if u.StoredBytes > 0 {
+			u.StoredMonths = make([]BillingStorageMonth, len(months))
+			for i, m := range months {
+				u.StoredMonths[i] = BillingStorageMonth{Month: m.label, StoredGB: round4(float64(monthBytes[id][i]) / billingBytesPerGB), Months: m.months}
+			}
+		}
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CustomerID < out[j].CustomerID })
+
+	amb := make([]BillingAmbiguousSource, 0, len(ambiguous))
+	for _, a := range ambiguous {
+		sort.Strings(a.CustomerIDs)
+		amb = append(amb, *a)
+	}
+	sort.Slice(amb, func(i, j int) bool { return amb[i].SourceOfAcquisition < amb[j].SourceOfAcquisition })
+	return out, amb
+}
+
+// billingMonths is the number of months storage is charged for.
+func billingMonths(months []billingMonth) float64 {
+	total := 0.0
+	for _, m := range months {
+		total += m.months
+	}
+	return round4(total)
+}
+
+// computeBillingLines prices usage with each customer's plan. Customers without a
+// plan get no lines; measures without a price are skipped. Storage is priced month
+// by month from StoredMonths, so tiers apply to each month's volume.
+func computeBillingLines(usage []BillingUsage, plans map[string]*PricePlan) []BillingLineItem {
+	out := make([]BillingLineItem, 0)
+	for _, u := range usage {
+		plan := plans[u.CustomerID]
+		if plan == nil {
+			continue
+		}
+		line := func(item, description string, quantity float64, unit string, unitPrice float64) {
+			out = append(out, BillingLineItem{
+				CustomerID:  u.CustomerID,
+				PlanID:      plan.ID,
+				PlanName:    plan.Name,
+				Item:        item,
+				Description: description,
+				Quantity:    round4(quantity),
+				Unit:        unit,
+				UnitPrice:   unitPrice,
+				Amount:      round2(quantity * unitPrice),
+				Currency:    plan.Currency,
+			})
+		}
+
+		if len(plan.StoredTiers) > 0 {
+			for _, t := range monthlyTierSplit(u.StoredMonths, plan.StoredTiers) {
+				line(BillingItemStorage, "Storage "+t.label, t.gb, "GB-month", t.price)
+			}
+		} else if plan.PerGBStoredMonth > 0 {
+			gbMonths := 0.0
+			for _, m := range u.StoredMonths {
+				gbMonths += m.StoredGB * m.Months
+			}
+			line(BillingItemStorage, "Storage", gbMonths, "GB-month", plan.PerGBStoredMonth)
+		}
+
+		if len(plan.IngestedTiers) > 0 {
+			for _, t := range tierSplit(u.IngestedGB, plan.IngestedTiers) {
+				line(BillingItemIngest, "Ingest "+t.label, t.gb, "GB", t.price)
+			}
+		} else if plan.PerGBIngested > 0 {
+			line(BillingItemIngest, "Ingest", u.IngestedGB, "GB", plan.PerGBIngested)
+		}
+
+		if plan.PerTransfer > 0 && u.Transfers > 0 {
+			line(BillingItemTransfers, "Completed transfers", float64(u.Transfers), "transfer", plan.PerTransfer)
+		}
+	}
+	return out
+}
+
+type tierPortion struct {
+	label string
+	gb    float64
+	price float64
+}
+
+// tierSplit spreads gb over graduated tiers. GB above a bounded last tier is
+// charged at the last tier's price.
+func tierSplit(gb float64, tiers []PriceTier) []tierPortion {
+	out := make([]tierPortion, 0, len(tiers)+1)
+	lower := 0.0
+	for _, t := range tiers {
+		if gb <= lower {
+			return out
+		}
+		if t.UpToGB == 0 {
+			return append(out, tierPortion{label: fmt.Sprintf("over %g GB", lower), gb: gb - lower, price: t.PricePerGB})
+		}
+		out = append(out, tierPortion{label: fmt.Sprintf("%g-%g GB", lower, t.UpToGB), gb: math.Min(gb, t.UpToGB) - lower, price: t.PricePerGB})
+		lower = t.UpToGB
+	}
+	if gb > lower && len(tiers) > 0 {
+		out = append(out, tierPortion{label: fmt.Sprintf("over %g GB", lower), gb: gb - lower, price: tiers[len(tiers)-1].PricePerGB})
+	}
+	return out
+}
+
+// monthlyTierSplit applies the tiers to each month's stored GB and sums the GB-months
+// per tier.
+func monthlyTierSplit(months []BillingStorageMonth, tiers []PriceTier) []tierPortion {
+	out := make([]tierPortion, 0, len(tiers)+1)
+	index := map[string]int{}
+	for _, m := range months {
+		for _, t := range tierSplit(m.StoredGB, tiers) {
+			i, ok := index[t.label]
+			if !ok {
+				i = len(out)
+				index[t.label] = i
+				out = append(out, tierPortion{label: t.label, price: t.price})
+			}
+			out[i].gb += t.gb * m.Months
+		}
+	}
+	return out
+}
+
+func round4(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
+
+func pricePlanFromStore(it customermap.PricePlan) PricePlan {
+	row := PricePlan{
+		ID:               it.ID,
+		CustomerID:       it.CustomerID,
+		Name:             it.Name,
+		Currency:         it.Currency,
+		PerGBStoredMonth: it.PerGBStoredMonth,
+		PerGBIngested:    it.PerGBIngested,
+		PerTransfer:      it.PerTransfer,
+		StoredTiers:      make([]PriceTier, 0, len(it.StoredTiers)),
+		IngestedTiers:    make([]PriceTier, 0, len(it.IngestedTiers)),
+	}
+	for _, t := range it.StoredTiers {
+		row.StoredTiers = append(row.StoredTiers, PriceTier(t))
+	}
+	for _, t := range it.IngestedTiers {
+		row.IngestedTiers = append(row.IngestedTiers, PriceTier(t))
+	}
+	if it.CreatedAt != nil {
+		row.CreatedAt = it.CreatedAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	if it.UpdatedAt != nil {
+		row.UpdatedAt = it.UpdatedAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	return row
+}
+
+func priceTiersToStore(tiers []PriceTier) []customermap.PriceTier {
+	out := make([]customermap.PriceTier, 0, len(tiers))
+	for _, t := range tiers {
+		out = append(out, customermap.PriceTier(t))
+	}
+	return out
+}
+
+// billingRunFromStore converts a stored run; withLines adds the summary, line
+// items and totals.
+func billingRunFromStore(it customermap.BillingRun, withLines bool) BillingRun {
+	row := BillingRun{
+		ID:          it.ID,
+		PeriodKind:  it.PeriodKind,
+		PeriodLabel: it.PeriodLabel,
+		Timezone:    it.Timezone,
+		PeriodStart: it.PeriodStart.UTC().Format("2006-01-02T15:04:05Z"),
+		PeriodEnd:   it.PeriodEnd.UTC().Format("2006-01-02T15:04:05Z"),
+		Status:      it.Status,
+		ApprovedBy:  it.ApprovedBy,
+	}
+	if it.ApprovedAt != nil {
+		row.ApprovedAt = it.ApprovedAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	if it.CreatedAt != nil {
+		row.CreatedAt = it.CreatedAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	if it.UpdatedAt != nil {
+		row.UpdatedAt = it.UpdatedAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	if !withLines {
+		return row
+	}
+
+	if strings.TrimSpace(it.SummaryJSON) != "" {
+		var summary BillingSummary
+		if err := json.Unmarshal([]byte(it.SummaryJSON), &summary); err == nil {
+			row.Summary = &summary
+		}
+	}
+	row.Lines = make([]BillingLineItem, 0, len(it.Lines))
+	for _, l := range it.Lines {
+		row.Lines = append(row.Lines, BillingLineItem(l))
+	}
+	row.CustomerTotals, row.Totals = billingTotals(row.Lines)
+	return row
+}
+
+// billingTotals sums line items per customer and currency, and per currency.
+func billingTotals(lines []BillingLineItem) ([]BillingCustomerTotal, map[string]float64) {
+	byCustomer := map[string]*BillingCustomerTotal{}
+	order := make([]string, 0)
+	totals := map[string]float64{}
+	for _, l := range lines {
+		key := l.CustomerID + "\x00" + l.Currency
+		t, ok := byCustomer[key]
+		if !ok {
+			t = &BillingCustomerTotal{CustomerID: l.CustomerID, Currency: l.Currency}
+			byCustomer[key] = t
+			order = append(order, key)
+		}
+		t.Amount = round2(t.Amount + l.Amount)
+		totals[l.Currency] = round2(totals[l.Currency] + l.Amount)
+	}
+	out := make([]BillingCustomerTotal, 0, len(order))
+	for _, key := range order {
+		out = append(out, *byCustomer[key])
+	}
+	return out, totals
+}
@@ -0,0 +1,262 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+)
+
+func TestComputeBillingLines_FlatAndTiered(t *testing.T) {
+	months := func(gb ...float64) []BillingStorageMonth {
+		out := make([]BillingStorageMonth, 0, len(gb))
+		for i, v := range gb {
+			out = append(out, BillingStorageMonth{Month: fmt.Sprintf("2026-%02d", i+1), StoredGB: v, Months: 1})
+		}
+		return out
+	}
+	// Tiers apply to each month's volume: acme only exceeds 1000 GB in two months.
+	usage := []BillingUsage{
+		{CustomerID: "acme", StoredGB: 2000, IngestedGB: 200, Transfers: 10, StoredMonths: months(1000, 1500, 2000)},
+		{CustomerID: "globex", StoredGB: 60, IngestedGB: 5, StoredMonths: months(40, 50, 60)},
+		{CustomerID: "initech", StoredGB: 10, StoredMonths: months(10, 10, 10)},
+	}
+	plans := map[string]*PricePlan{
+		"acme": {
+			ID: 1, Name: "tiered", Currency: "EUR", PerGBIngested: 0.5, PerTransfer: 2,
+			StoredTiers: []PriceTier{{UpToGB: 1000, PricePerGB: 0.02}, {PricePerGB: 0.01}},
+		},
+		"globex": {ID: 2, Name: "flat", Currency: "USD", PerGBStoredMonth: 0.1},
+	}
+
+	lines := computeBillingLines(usage, plans)
+
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 lines, got %+v", lines)
+	}
+	tier1, tier2, ingest, transfers, flat := lines[0], lines[1], lines[2], lines[3], lines[4]
+	if tier1.Description != "Storage 0-1000 GB" || tier1.Quantity != 3000 || tier1.Amount != 60 {
+		t.Fatalf("unexpected first tier %+v", tier1)
+	}
+	if tier2.Description != "Storage over 1000 GB" || tier2.Quantity != 1500 || tier2.Amount != 15 {
+		t.Fatalf("unexpected second tier %+v", tier2)
+	}
+	if ingest.Item != BillingItemIngest || ingest.Amount != 100 || transfers.Amount != 20 {
+		t.Fatalf("unexpected ingest/transfer lines %+v %+v", ingest, transfers)
+	}
+	if flat.CustomerID != "globex" || flat.Currency != "USD" || flat.Amount != 15 {
+		t.Fatalf("unexpected flat storage line %+v", flat)
+	}
+
+	customerTotals, totals := billingTotals(lines)
+	if len(customerTotals) != 2 || customerTotals[0].Amount != 195 || totals["EUR"] != 195 || totals["USD"] != 15 {
+		t.Fatalf("unexpected totals %+v %+v", customerTotals, totals)
+	}
+}
+
+func TestTierSplit_BoundedLastTier(t *testing.T) {
+	got := tierSplit(250, []PriceTier{{UpToGB: 100, PricePerGB: 1}, {UpToGB: 200, PricePerGB: 0.5}})
+	if len(got) != 3 || got[1].gb != 100 || got[2].gb != 50 || got[2].price != 0.5 {
+		t.Fatalf("unexpected split %+v", got)
+	}
+	if got := tierSplit(0, []PriceTier{{PricePerGB: 1}}); len(got) != 0 {
+		t.Fatalf("expected no portions for zero usage, got %+v", got)
+	}
+}
+
+func TestBillingMonths(t *testing.T) {
+	anchor := time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC)
+	if got := billingMonths(billingPeriodMonths(MonthPeriod(anchor, time.UTC))); got != 1 {
+		t.Fatalf("expected 1 month for February, got %v", got)
+	}
+	quarter, _ := NewReportPeriod(PeriodQuarter, anchor, time.UTC, 1)
+	months := billingPeriodMonths(quarter)
+	if len(months) != 3 || months[0].label != "2026-01" || !months[0].end.Equal(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)) || billingMonths(months) != 3 {
+		t.Fatalf("unexpected quarter months %+v", months)
+	}
+	week, _ := NewReportPeriod(PeriodWeek, anchor, time.UTC, 1)
+	if got := billingMonths(billingPeriodMonths(week)); got != 0.25 {
+		t.Fatalf("expected 7/28 months for a February week, got %v", got)
+	}
+	// Monday 2026-02-23 to 2026-03-02: six February days and one March day.
+	split, _ := NewReportPeriod(PeriodWeek, time.Date(2026, 2, 25, 0, 0, 0, 0, time.UTC), time.UTC, 1)
+	months = billingPeriodMonths(split)
+	if len(months) != 2 || months[0].months != round4(6.0/28) || months[1].months != round4(1.0/31) || months[1].label != "2026-03" {
+		t.Fatalf("unexpected split week months %+v", months)
+	}
+}
+
+func TestBillingUsage_MonthlyAndAmbiguous(t *testing.T) {
+	quarter, _ := NewReportPeriod(PeriodQuarter, time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC), time.UTC, 1)
+	packages := []StoragePackage{
+		{UUID: "aip-1", SIPUUID: "sip-1", SizeBytes: 2e9, StoredAt: quarter.Start.AddDate(0, -1, 0)},
+		{UUID: "aip-2", SIPUUID: "sip-2", SizeBytes: 3e9, StoredAt: time.Date(2026, 2, 15, 0, 0, 0, 0, time.UTC)},
+		{UUID: "aip-3", SIPUUID: "sip-3", SizeBytes: 5e9, StoredAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{UUID: "aip-4", SIPUUID: "sip-3", SizeBytes: 1e9, StoredAt: quarter.End},
+	}
+	sources := map[string]string{"sip-1": "acme-ftp", "sip-2": "acme-ftp", "sip-3": "shared-ftp"}
+	sipTransfers := map[string]mappingRuleTransfer{}
+	for sipUUID, source := range sources {
+		sipTransfers[sipUUID] = mappingRuleTransfer{Source: source}
+	}
+	var transfers []mappingRuleTransfer
+	for source, n := range map[string]int{"acme-ftp": 4, "shared-ftp": 2} {
+		for i := 0; i < n; i++ {
+			transfers = append(transfers, mappingRuleTransfer{UUID: fmt.Sprintf("%s-%d", source, i), Source: source})
+		}
+	}
+	// shared-ftp has two unbounded mappings, so they overlap for every transfer.
+	payers := billingAttribution{attribution: testAttribution(map[string][]string{"acme-ftp": {"acme"}, "shared-ftp": {"globex", "acme"}}, nil)}
+
+	months := billingPeriodMonths(quarter)
+	usage, ambiguous := billingUsage(packages, sipTransfers, transfers, payers, quarter, months)
+	if len(ambiguous) != 1 || ambiguous[0].SourceOfAcquisition != "shared-ftp" || strings.Join(ambiguous[0].CustomerIDs, ",") != "acme,globex" ||
+		ambiguous[0].StoredBytes != 5e9 || ambiguous[0].IngestedBytes != 5e9 || ambiguous[0].Transfers != 2 {
+		t.Fatalf("unexpected ambiguous sources %+v", ambiguous)
+	}
+	if len(usage) != 1 || usage[0].CustomerID != "acme" || usage[0].StoredGB != 5 || usage[0].Transfers != 4 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+	stored := usage[0].StoredMonths
+	if len(stored) != 3 || stored[0].StoredGB != 2 || stored[1].StoredGB != 5 || stored[2].StoredGB != 5 {
+		t.Fatalf("unexpected stored months %+v", stored)
+	}
+	lines := computeBillingLines(usage, map[string]*PricePlan{"acme": {ID: 1, Currency: "EUR", PerGBStoredMonth: 1}})
+	if len(lines) != 1 || lines[0].Quantity != 12 || lines[0].Amount != 12 {
+		t.Fatalf("expected 2+5+5 GB-months, got %+v", lines)
+	}
+}
+
+func TestBillingUsage_AttributesEachUnitAtItsStart(t *testing.T) {
+	period := MonthPeriod(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.UTC)
+	switchover := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	before, after := switchover.AddDate(0, 0, -2), switchover.AddDate(0, 0, 2)
+	rules, err := compileMappingRules([]CustomerMappingRule{
+		{ID: 1, CustomerID: "initech", Field: "accession_id", MatchType: "prefix", Pattern: "INI-", Enabled: true},
+		{ID: 2, CustomerID: "hooli", Field: "accession_id", MatchType: "prefix", Pattern: "GLX-", Enabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// shared-ftp moved from acme to globex; initech's transfers are only claimed by a
+	// rule; globex's own mapping wins over hooli's rule.
+	attribution := &customerAttribution{bySource: map[string][]customerSourceOwner{
+		"shared-ftp": {
+			{CustomerID: "acme", Mapping: CustomerSourceMapping{SourceOfAcquisition: "shared-ftp", EffectiveTo: &switchover}},
+			{CustomerID: "globex", Mapping: CustomerSourceMapping{SourceOfAcquisition: "shared-ftp", EffectiveFrom: &switchover}},
+		},
+	}, rules: rules}
+	sipTransfers := map[string]mappingRuleTransfer{
+		"sip-1": {UUID: "t-1", Source: "shared-ftp", StartedAt: &before},
+		"sip-2": {UUID: "t-2", Source: "shared-ftp", Accession: "GLX-1", StartedAt: &after},
+		"sip-3": {UUID: "t-3", Source: "scanner", Accession: "INI-7", StartedAt: &after},
+		"sip-4": {UUID: "t-4", Source: "shared-ftp"},
+	}
+	packages := []StoragePackage{
+		{UUID: "sip-1", SIPUUID: "sip-1", SizeBytes: 1e9, StoredAt: before},
+		{UUID: "sip-2", SIPUUID: "sip-2", SizeBytes: 2e9, StoredAt: after},
+		{UUID: "sip-3", SIPUUID: "sip-3", SizeBytes: 4e9, StoredAt: after},
+		{UUID: "sip-4", SIPUUID: "sip-4", SizeBytes: 8e9, StoredAt: after},
+	}
+	transfers := []mappingRuleTransfer{sipTransfers["sip-1"], sipTransfers["sip-2"], sipTransfers["sip-3"]}
+
+	usage, ambiguous := billingUsage(packages, sipTransfers, transfers, billingAttribution{attribution: attribution}, period, billingPeriodMonths(period))
+	if len(ambiguous) != 0 {
+		t.Fatalf("a moved source must not be ambiguous, got %+v", ambiguous)
+	}
+	got := map[string]BillingUsage{}
+	for _, u := range usage {
+		got[u.CustomerID] = u
+	}
+	if len(usage) != 4 || got["acme"].StoredGB != 1 || got["acme"].Transfers != 1 ||
+		got["globex"].StoredGB != 2 || got["globex"].Transfers != 1 ||
+		got["initech"].StoredGB != 4 || got["initech"].Transfers != 1 ||
+		got[forecastUnmappedCustomer].StoredGB != 8 {
+		t.Fatalf("expected each unit billed to its owner at start, got %+v", usage)
+	}
+
+	// Members without a plan of their own are billed to their parent; two owners
+	// with the same payer are not ambiguous.
+	holding := billingAttribution{attribution: attribution, payers: map[string]string{"acme": "holding", "globex": "holding"}}
+	usage, _ = billingUsage(packages, sipTransfers, transfers, holding, period, billingPeriodMonths(period))
+	if len(usage) != 3 || usage[0].CustomerID != "holding" || usage[0].StoredGB != 3 || usage[0].Transfers != 2 {
+		t.Fatalf("expected acme and globex rolled up into holding, got %+v", usage)
+	}
+
+	// Overlapping windows at a transfer start are ambiguous.
+	overlap := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	attribution.bySource["shared-ftp"][0].Mapping.EffectiveTo = &overlap
+	_, ambiguous = billingUsage(packages, sipTransfers, transfers, billingAttribution{attribution: attribution}, period, billingPeriodMonths(period))
+	if len(ambiguous) != 1 || strings.Join(ambiguous[0].CustomerIDs, ",") != "acme,globex" || ambiguous[0].StoredBytes != 2e9 || ambiguous[0].Transfers != 1 {
+		t.Fatalf("expected the overlapping transfer to be ambiguous, got %+v", ambiguous)
+	}
+}
+
+func TestBillingPayers_NearestAncestorWithPlan(t *testing.T) {
+	cm, err := customermap.NewSQLiteStore(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+	ctx := context.Background()
+	for _, c := range []customermap.Customer{{ID: "holding"}, {ID: "region", ParentID: "holding"}, {ID: "acme", ParentID: "region"}, {ID: "globex", ParentID: "region"}, {ID: "solo"}} {
+		if err := cm.SaveCustomer(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{"holding", "globex"} {
+		if _, err := cm.SavePricePlan(ctx, customermap.PricePlan{CustomerID: id, Name: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	payers, err := billingPayers(ctx, cm, []string{"acme", "globex", "region", "solo", "unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payers) != 2 || payers["acme"] != "holding" || payers["region"] != "holding" {
+		t.Fatalf("unexpected payers %v", payers)
+	}
+}
+
+func TestBillingRun_ApprovedRunIsFrozen(t *testing.T) {
+	cm, err := customermap.NewSQLiteStore(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+	s := &Store{customerMap: cm, queryTimeout: time.Second}
+	ctx := context.Background()
+
+	period := MonthPeriod(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), time.UTC)
+	draft := customermap.BillingRun{PeriodKind: period.Kind, PeriodLabel: period.Label, Timezone: period.Timezone, PeriodStart: period.Start, PeriodEnd: period.End}
+	id, err := cm.SaveBillingRunDraft(ctx, draft)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := cm.SaveBillingRunDraft(ctx, draft); err != nil || again != id {
+		t.Fatalf("expected the draft to be replaced in place: %d %v", again, err)
+	}
+	if err := s.ApproveBillingRun(ctx, id, "finance"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cm.SaveBillingRunDraft(ctx, draft); !errors.Is(err, ErrBillingRunApproved) {
+		t.Fatalf("expected regenerating an approved run to fail, got %v", err)
+	}
+	if _, err := s.DeleteBillingRun(ctx, id); !errors.Is(err, ErrBillingRunApproved) {
+		t.Fatalf("expected deleting an approved run to fail, got %v", err)
+	}
+	if err := s.ApproveBillingRun(ctx, id, "finance"); !errors.Is(err, ErrBillingRunApproved) {
+		t.Fatalf("expected approving twice to fail, got %v", err)
+	}
+	run, err := s.GetBillingRun(ctx, id)
+	if err != nil || run.Status != customermap.BillingRunApproved || run.ApprovedBy != "finance" {
+		t.Fatalf("unexpected run %+v %v", run, err)
+	}
+}
@@ -0,0 +1,401 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go-am-realtime-report-ui/internal/dbctx"
+)
+
+const reportMaxSparkline = 24
+
+// kpiPolarity tells whether a rising KPI is an improvement (+1), a regression (-1)
+// or neither (0). KPIs not listed are treated as neutral.
+var kpiPolarity = map[string]int{
+	"transfers_total":     0,
+	"transfers_success":   1,
+	"transfers_failed":    -1,
+	"avg_processing_sec":  -1,
+	"p50_processing_sec":  -1,
+	"p95_processing_sec":  -1,
+	"files_total":         0,
+	"files_original":      0,
+	"files_normalized":    1,
+	"sla_on_time_percent": 1,
+	"sla_breaches":        -1,
+}
+
+// kpiNotCompared are numeric KPIs that are not measured per period: the backlog is
+// the number of transfers in progress now, and the SLA targets are configuration.
+var kpiNotCompared = map[string]bool{
+	"backlog_end_of_month": true,
+	"sla_target_seconds":   true,
+	"sla_target_percent":   true,
+}
+
+// ComparisonOptions selects which reference periods GetReportComparison builds.
+type ComparisonOptions struct {
+	Previous  bool
+	YearAgo   bool
+	Sparkline int
+}
+
+// KPIDelta compares a KPI with its value in a reference period. DeltaPercent is nil
+// when the reference value is zero. Trend is up, down or flat; Direction is better,
+// worse or neutral according to the KPI's polarity.
+type KPIDelta struct {
+	Period       string   `json:"period"`
+	Value        float64  `json:"value"`
+	Delta        float64  `json:"delta"`
+	DeltaPercent *float64 `json:"delta_percent"`
+	Trend        string   `json:"trend"`
+	Direction    string   `json:"direction"`
+}
+
+// SparklinePoint is one period of a KPI's history.
+type SparklinePoint struct {
+	Period string  `json:"period"`
+	Value  float64 `json:"value"`
+}
+
+// KPIComparison holds the comparisons and history for one numeric KPI.
+type KPIComparison struct {
+	Value     float64          `json:"value"`
+	Previous  *KPIDelta        `json:"previous,omitempty"`
+	YearAgo   *KPIDelta        `json:"year_ago,omitempty"`
+	Sparkline []SparklinePoint `json:"sparkline,omitempty"`
+}
+
+// ReportComparison is the period-over-period view of a report's KPIs.
+type ReportComparison struct {
+	Previous *ReportPeriod            `json:"previous,omitempty"`
+	YearAgo  *ReportPeriod            `json:"year_ago,omitempty"`
+	KPIs     map[string]KPIComparison `json:"kpis"`
+}
+
+// GetReportComparison builds the reference reports requested in opts and compares
+// current against them. The sparkline covers the current period and the
+// Sparkline-1 periods before it, oldest first. All reference periods are computed
+// together by referenceReports, so the query count does not grow with Sparkline.
+func (s *Store) GetReportComparison(ctx context.Context, customerID string, current *MonthlyReport, opts ComparisonOptions) (*ReportComparison, error) {
+	if opts.Sparkline > reportMaxSparkline {
+		opts.Sparkline = reportMaxSparkline
+	}
+	period := current.Period
+
+	out := &ReportComparison{}
+	wanted := make([]ReportPeriod, 0, opts.Sparkline+2)
+	if opts.Previous {
+		p := period.Shift(-1)
+		out.Previous = &p
+		wanted = append(wanted, p)
+	}
+	if opts.YearAgo {
+		p := period.YearAgo()
+		out.YearAgo = &p
+		wanted = append(wanted, p)
+	}
+	sparkline := make([]ReportPeriod, 0, opts.Sparkline)
+	for i := opts.Sparkline - 1; i >= 0; i-- {
+		sparkline = append(sparkline, period.Shift(-i))
+	}
+	wanted = append(wanted, sparkline...)
+
+	reports, err := s.referenceReports(ctx, customerID, current, wanted)
+	if err != nil {
+		return nil, err
+	}
+	var previous, yearAgo *MonthlyReport
+	if out.Previous != nil {
+		previous = reports[out.Previous.Label]
+	}
+	if out.YearAgo != nil {
+		yearAgo = reports[out.YearAgo.Label]
+	}
+	history := make([]*MonthlyReport, 0, len(sparkline))
+	for _, p := range sparkline {
+		history = append(history, reports[p.Label])
+	}
+
+	out.KPIs = compareKPIs(current, previous, yearAgo, history)
+	return out, nil
+}
+
+// referenceReports returns KPI-only reports for periods, keyed by label, with
+// current under its own label. Counts, durations and SLA samples are each loaded
+// once for the combined span of the periods; counts are grouped per period in SQL.
+// The backlog is not tied to a period and is left at zero, see kpiNotCompared.
+func (s *Store) referenceReports(ctx context.Context, customerID string, current *MonthlyReport, periods []ReportPeriod) (map[string]*MonthlyReport, error) {
+	out := map[string]*MonthlyReport{current.Period.Label: current}
+	pending := make([]ReportPeriod, 0, len(periods))
+	for _, p := range periods {
+		if _, ok := out[p.Label]; !ok {
+			out[p.Label] = nil
+			pending = append(pending, p)
+		}
+	}
+	if len(pending) == 0 {
+		return out, nil
+	}
+
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	from, to := pending[0].Start, pending[0].End
+	for _, p := range pending[1:] {
+		if p.Start.Before(from) {
+			from = p.Start
+		}
+		if p.End.After(to) {
+			to = p.End
+		}
+	}
+	filterClause, args, err := s.sourceFilterClause(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]periodCounts, len(pending))
+	// Periods in one CASE must not overlap; a year-ago custom range may overlap
+	// the sparkline and gets a query of its own.
+	for _, layer := range periodLayers(pending) {
+		if err := s.groupedPeriodCounts(ctx, pending, layer, filterClause, args, counts); err != nil {
+			return nil, err
+		}
+	}
+
+	samples, err := s.transferDurationSamples(ctx, from, to, filterClause, args)
+	if err != nil {
+		return nil, err
+	}
+	durations := make([][]int64, len(pending))
+	for _, sm := range samples {
+		for i, p := range pending {
+			if !sm.CompletedAt.Before(p.Start) && sm.CompletedAt.Before(p.End) {
+				durations[i] = append(durations[i], sm.Seconds)
+			}
+		}
+	}
+
+	policy, err := s.ResolveSLAPolicy(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	slaSamples, err := s.slaSamples(ctx, policy, from, to, filterClause, args)
+	if err != nil {
+		return nil, err
+	}
+	if policy.excludes("awaiting_decision") {
+		if err := s.attachDecisionWaits(ctx, slaSamples, time.Now().UTC()); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, p := range pending {
+		sla := evaluateSLA(slaSamples, policy, p.Start, p.End, monthlyMaxBreaches)
+		sla.CustomerID = customerID
+		sla.Month = p.Label
+		out[p.Label] = &MonthlyReport{
+			Month:      p.Label,
+			Period:     p,
+			CustomerID: customerID,
+			KPIs:       reportKPIs(counts[i], durations[i], sla, policy, 0),
+			Timeseries: []DailyReportPoint{},
+			SLA:        sla,
+		}
+	}
+	return out, nil
+}
+
+// periodLayers splits the indexes of periods into groups of non-overlapping
+// periods, in order.
+func periodLayers(periods []ReportPeriod) [][]int {
+	layers := make([][]int, 0, 1)
+	for i, p := range periods {
+		placed := false
+		for l, layer := range layers {
+			free := true
+			for _, j := range layer {
+				if p.Start.Before(periods[j].End) && periods[j].Start.Before(p.End) {
+					free = false
+					break
+				}
+			}
+			if free {
+				layers[l] = append(layer, i)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			layers = append(layers, []int{i})
+		}
+	}
+	return layers
+}
+
+// groupedPeriodCounts fills counts for the periods at indexes layer with one
+// query grouped by period. The periods must not overlap.
+func (s *Store) groupedPeriodCounts(ctx context.Context, periods []ReportPeriod, layer []int, filterClause string, filterArgs []any, counts []periodCounts) error {
+	q, args := groupedPeriodCountsQuery(periods, layer, filterClause, filterArgs)
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			idx                                sql.NullInt64
+			total, success, failed             sql.NullInt64
+			filesTotal, filesOrig, filesNormed sql.NullInt64
+		)
+		if err := rows.Scan(&idx, &total, &success, &failed, &filesTotal, &filesOrig, &filesNormed); err != nil {
+			return err
+		}
+		if !idx.Valid || idx.Int64 < 0 || int(idx.Int64) >= len(counts) {
+			continue
+		}
+		counts[idx.Int64] = periodCounts{
+			Total:           nullInt64Value(total),
+			Success:         nullInt64Value(success),
+			Failed:          nullInt64Value(failed),
+			FilesTotal:      nullInt64Value(filesTotal),
+			FilesOriginal:   nullInt64Value(filesOrig),
+			FilesNormalized: nullInt64Value(filesNormed),
+		}
+	}
+	return rows.Err()
+}
+
+// groupedPeriodCountsQuery mirrors the summary and files queries of
+// GetPeriodReport, grouped by the index of the period a transfer completed in.
+func groupedPeriodCountsQuery(periods []ReportPeriod, layer []int, filterClause string, filterArgs []any) (string, []any) {
+	var cases strings.Builder
+	args := make([]any, 0, 2*len(layer)+2+len(filterArgs))
+	from, to := periods[layer[0]].Start, periods[layer[0]].End
+	cases.WriteString("CASE")
+	for _, i := range layer {
+		p := periods[i]
+		fmt.Fprintf(&cases, " WHEN t.completed_at >= ? AND t.completed_at < ? THEN %d", i)
+		args = append(args, p.Start, p.End)
+		if p.Start.Before(from) {
+			from = p.Start
+		}
+		if p.End.After(to) {
+			to = p.End
+		}
+	}
+	cases.WriteString(" END")
+	args = append(args, from, to)
+	args = append(args, filterArgs...)
+
+	return fmt.Sprintf(`
+SELECT
+  %s AS period_idx,
+  COUNT(*) AS transfers_total,
+  SUM(CASE WHEN t.status IN (2, 3) THEN 1 ELSE 0 END) AS transfers_success,
+  SUM(CASE WHEN t.status = 4 THEN 1 ELSE 0 END) AS transfers_failed,
+  COALESCE(SUM(fc.total_files), 0) AS files_total,
+  COALESCE(SUM(fc.original_files), 0) AS files_original,
+  COALESCE(SUM(fc.normalized_files), 0) AS files_normalized
+FROM Transfers t
+LEFT JOIN (
+  SELECT
+    f.transferUUID,
+    COUNT(*) AS total_files,
+    SUM(CASE WHEN LOWER(f.fileGrpUse) = 'original' THEN 1 ELSE 0 END) AS original_files,
+    SUM(CASE WHEN d.derivedFileUUID IS NOT NULL THEN 1 ELSE 0 END) AS normalized_files
+  FROM Files f
+  LEFT JOIN Derivations d
+    ON d.sourceFileUUID = f.fileUUID
+  GROUP BY f.transferUUID
+) fc
+  ON fc.transferUUID = t.transferUUID
+WHERE t.completed_at >= ?
+  AND t.completed_at < ?
+  %s
+GROUP BY period_idx;
+`, cases.String(), filterClause), args
+}
+
+// compareKPIs compares every numeric KPI of current except kpiNotCompared.
+// previous, yearAgo and history may be nil/empty.
+func compareKPIs(current, previous, yearAgo *MonthlyReport, history []*MonthlyReport) map[string]KPIComparison {
+	keys := make([]string, 0, len(current.KPIs))
+	for k, v := range current.KPIs {
+		if _, ok := kpiNumber(v); ok && !kpiNotCompared[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	out := make(map[string]KPIComparison, len(keys))
+	for _, key := range keys {
+		value, _ := kpiNumber(current.KPIs[key])
+		cmp := KPIComparison{Value: value}
+		if previous != nil {
+			cmp.Previous = kpiDelta(key, value, previous)
+		}
+		if yearAgo != nil {
+			cmp.YearAgo = kpiDelta(key, value, yearAgo)
+		}
+		if len(history) > 0 {
+			cmp.Sparkline = make([]SparklinePoint, 0, len(history))
+			for _, r := range history {
+				v, _ := kpiNumber(r.KPIs[key])
+				cmp.Sparkline = append(cmp.Sparkline, SparklinePoint{Period: r.Period.Label, Value: v})
+			}
+		}
+		out[key] = cmp
+	}
+	return out
+}
+
+func kpiDelta(key string, value float64, reference *MonthlyReport) *KPIDelta {
+	base, ok := kpiNumber(reference.KPIs[key])
+	if !ok {
+		return nil
+	}
+	d := &KPIDelta{
+		Period: reference.Period.Label,
+		Value:  base,
+		Delta:  round2(value - base),
+		Trend:  "flat",
+	}
+	if base != 0 {
+		pct := round2((value - base) * 100 / base)
+		d.DeltaPercent = &pct
+	}
+	switch {
+	case value > base:
+		d.Trend = "up"
+	case value < base:
+		d.Trend = "down"
+	}
+	d.Direction = "neutral"
+	if polarity := kpiPolarity[key]; polarity != 0 && d.Trend != "flat" {
+		if (d.Trend == "up") == (polarity > 0) {
+			d.Direction = "better"
+		} else {
+			d.Direction = "worse"
+		}
+	}
+	return d
+}
+
+func kpiNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
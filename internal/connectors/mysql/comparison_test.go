@@ -0,0 +1,115 @@
+package mysql
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func comparisonReport(month time.Time, kpis map[string]any) *MonthlyReport {
+	return &MonthlyReport{Period: MonthPeriod(month, time.UTC), KPIs: kpis}
+}
+
+func TestCompareKPIs_DeltasAndDirections(t *testing.T) {
+	feb := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	current := comparisonReport(feb, map[string]any{
+		"transfers_failed":     int64(5),
+		"sla_on_time_percent":  90.0,
+		"files_total":          int64(100),
+		"sla_policy":           "transfer_start->transfer_completed",
+		"sla_target_percent":   95.0,
+		"backlog_end_of_month": int64(7),
+	})
+	previous := comparisonReport(feb.AddDate(0, -1, 0), map[string]any{
+		"transfers_failed":    int64(10),
+		"sla_on_time_percent": 95.0,
+		"files_total":         int64(0),
+	})
+
+	got := compareKPIs(current, previous, nil, []*MonthlyReport{previous, current})
+
+	if _, ok := got["sla_policy"]; ok {
+		t.Fatalf("non-numeric KPIs must be skipped")
+	}
+	for _, key := range []string{"sla_target_percent", "backlog_end_of_month"} {
+		if _, ok := got[key]; ok {
+			t.Fatalf("%s is not measured per period and must not be compared", key)
+		}
+	}
+	failed := got["transfers_failed"].Previous
+	if failed == nil || failed.Delta != -5 || *failed.DeltaPercent != -50 || failed.Trend != "down" || failed.Direction != "better" {
+		t.Fatalf("unexpected failed delta %+v", failed)
+	}
+	sla := got["sla_on_time_percent"].Previous
+	if sla.Trend != "down" || sla.Direction != "worse" || sla.Period != "2026-01" {
+		t.Fatalf("unexpected sla delta %+v", sla)
+	}
+	files := got["files_total"].Previous
+	if files.DeltaPercent != nil || files.Direction != "neutral" {
+		t.Fatalf("zero base must have nil percent and neutral direction: %+v", files)
+	}
+	spark := got["transfers_failed"].Sparkline
+	if len(spark) != 2 || spark[0].Period != "2026-01" || spark[1].Value != 5 {
+		t.Fatalf("unexpected sparkline %+v", spark)
+	}
+	if got["transfers_failed"].YearAgo != nil {
+		t.Fatalf("year_ago must be omitted when not requested")
+	}
+}
+
+func TestReportPeriod_ShiftAndYearAgo(t *testing.T) {
+	anchor := time.Date(2026, 2, 18, 0, 0, 0, 0, time.UTC)
+
+	month := MonthPeriod(anchor, time.UTC)
+	if got := month.Shift(-1).Label; got != "2026-01" {
+		t.Fatalf("previous month: %s", got)
+	}
+	if got := month.YearAgo().Label; got != "2025-02" {
+		t.Fatalf("month year ago: %s", got)
+	}
+
+	quarter, _ := NewReportPeriod(PeriodQuarter, anchor, time.UTC, 1)
+	if got := quarter.Shift(-1).Label; got != "2025-Q4" {
+		t.Fatalf("previous quarter: %s", got)
+	}
+
+	fiscal, _ := NewReportPeriod(PeriodFiscalYear, anchor, time.UTC, 7)
+	if got := fiscal.Shift(-1); got.Label != "FY2025" || got.Start.Month() != time.July {
+		t.Fatalf("previous fiscal year: %+v", got)
+	}
+
+	week, _ := NewReportPeriod(PeriodWeek, anchor, time.UTC, 1)
+	if got := week.YearAgo().Label; got != "2025-W08" {
+		t.Fatalf("week year ago: %s", got)
+	}
+
+	custom, _ := CustomReportPeriod(anchor, anchor.AddDate(0, 0, 10), time.UTC)
+	prev := custom.Shift(-1)
+	if !prev.End.Equal(custom.Start) || prev.Bucket != custom.Bucket {
+		t.Fatalf("previous custom range must end where current starts: %+v", prev)
+	}
+}
+
+func TestGroupedPeriodCountsQuery_OneQueryPerLayer(t *testing.T) {
+	anchor := time.Date(2026, 2, 18, 0, 0, 0, 0, time.UTC)
+	custom, _ := CustomReportPeriod(anchor, anchor.AddDate(0, 0, 200), time.UTC)
+	periods := make([]ReportPeriod, 0, 24)
+	for i := 23; i >= 1; i-- {
+		periods = append(periods, custom.Shift(-i))
+	}
+	// A year-ago custom range overlaps the shifted ranges.
+	periods = append(periods, custom.YearAgo())
+
+	layers := periodLayers(periods)
+	if len(layers) != 2 || len(layers[0]) != 23 || len(layers[1]) != 1 || layers[1][0] != 23 {
+		t.Fatalf("unexpected layers %v", layers)
+	}
+
+	q, args := groupedPeriodCountsQuery(periods, layers[0], "AND t.sourceOfAcquisition = ?", []any{"acme"})
+	if strings.Count(q, "WHEN t.completed_at >= ?") != 23 || !strings.Contains(q, "THEN 22 END AS period_idx") || !strings.Contains(q, "GROUP BY period_idx") {
+		t.Fatalf("unexpected query %s", q)
+	}
+	if len(args) != 2*23+3 || args[len(args)-1] != "acme" || args[len(args)-3] != periods[0].Start || args[len(args)-2] != periods[22].End {
+		t.Fatalf("unexpected args %v", args)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"go-am-realtime-report-ui/internal/dbctx"
 )
 
 // CompletedTransfer is a finished transfer row for dashboard/report browsing.
@@ -43,7 +45,7 @@ type TransferSummary struct {
 
 // ListCompletedTransfers returns newest completed transfers, optionally filtered by month/date range and search query.
 func (s *Store) ListCompletedTransfers(ctx context.Context, limit, offset int, month *time.Time, dateFrom, dateTo *time.Time, query string) ([]CompletedTransfer, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
 	whereClause := "WHERE t.status IN (2, 3, 4) AND t.completed_at IS NOT NULL"
@@ -203,7 +205,7 @@ LIMIT ? OFFSET ?;
 
 // GetTransferSummary returns one transfer summary with processing and file counters.
 func (s *Store) GetTransferSummary(ctx context.Context, transferUUID string) (*TransferSummary, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
 	const q = `
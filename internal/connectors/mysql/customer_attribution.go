@@ -0,0 +1,133 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+)
+
+// customerAttribution assigns transfers to customers the way sourceFilterClause
+// filters them: the exact mappings valid at the transfer start plus the customer
+// of the winning mapping rule. A nil *customerAttribution means no mapping backend
+// is configured and sources are used as customer IDs directly.
+type customerAttribution struct {
+	bySource map[string][]customerSourceOwner
+	rules    []mappingRuleMatcher
+}
+
+type customerSourceOwner struct {
+	CustomerID string
+	Mapping    CustomerSourceMapping
+}
+
+// customerAttribution loads all mappings and enabled rules. It returns nil without
+// a mapping backend; MCP CustomerTransferSources has neither windows nor rules.
+func (s *Store) customerAttribution(ctx context.Context) (*customerAttribution, error) {
+	if s.customerMap == nil {
+		index, err := s.customerSourceIndex(ctx)
+		if err != nil || index == nil {
+			return nil, err
+		}
+		out := &customerAttribution{bySource: map[string][]customerSourceOwner{}}
+		for source, ids := range index {
+			for _, id := range ids {
+				out.bySource[source] = append(out.bySource[source], customerSourceOwner{CustomerID: id, Mapping: CustomerSourceMapping{SourceOfAcquisition: source}})
+			}
+		}
+		return out, nil
+	}
+
+	items, err := s.customerMap.ListAllMappings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.customerMap.ListMappingRules(ctx, "", true)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := compileMappingRules(customerMappingRulesFromStore(stored))
+	if err != nil {
+		return nil, err
+	}
+	out := &customerAttribution{bySource: map[string][]customerSourceOwner{}, rules: rules}
+	for _, it := range items {
+		out.bySource[it.SourceOfAcquisition] = append(out.bySource[it.SourceOfAcquisition], customerSourceOwner{CustomerID: it.CustomerID, Mapping: customerSourceMappingFromStore(it)})
+	}
+	return out, nil
+}
+
+// owners returns the customers a transfer belongs to, or nil: mappedOwners plus
+// the customer of the winning rule.
+func (a *customerAttribution) owners(tr mappingRuleTransfer) []string {
+	out := a.mappedOwners(tr)
+	if id := a.ruleOwner(tr); id != "" && !containsKey(out, id) {
+		out = append(out, id)
+	}
+	return out
+}
+
+// mappedOwners returns the customers whose exact mappings apply to a transfer.
+// Bounded mappings need the transfer start (transferStartExpr); without one they
+// do not apply, as in SQL.
+func (a *customerAttribution) mappedOwners(tr mappingRuleTransfer) []string {
+	var out []string
+	for _, o := range a.bySource[strings.TrimSpace(tr.Source)] {
+		if o.Mapping.bounded() && (tr.StartedAt == nil || !o.Mapping.validAt(*tr.StartedAt)) {
+			continue
+		}
+		if !containsKey(out, o.CustomerID) {
+			out = append(out, o.CustomerID)
+		}
+	}
+	return out
+}
+
+// ruleOwner returns the customer of the winning rule for a transfer, or "".
+func (a *customerAttribution) ruleOwner(tr mappingRuleTransfer) string {
+	if i := mappingRuleOwner(a.rules, tr); i >= 0 {
+		return a.rules[i].rule.CustomerID
+	}
+	return ""
+}
+
+// customersFor is owners with the grouping fallbacks: the source itself without a
+// mapping backend, and "unmapped" for transfers no customer owns.
+func (a *customerAttribution) customersFor(tr mappingRuleTransfer) []string {
+	if a == nil {
+		return forecastCustomersFor(tr.Source, nil)
+	}
+	if ids := a.owners(tr); len(ids) > 0 {
+		return ids
+	}
+	return []string{forecastUnmappedCustomer}
+}
+
+// customerIDs lists the customers of all mappings and rules.
+func (a *customerAttribution) customerIDs() []string {
+	var out []string
+	for _, owners := range a.bySource {
+		for _, o := range owners {
+			if !containsKey(out, o.CustomerID) {
+				out = append(out, o.CustomerID)
+			}
+		}
+	}
+	for _, m := range a.rules {
+		if !containsKey(out, m.rule.CustomerID) {
+			out = append(out, m.rule.CustomerID)
+		}
+	}
+	return out
+}
+
+// sourceIndex maps every mapped source to its customers regardless of windows.
+func (a *customerAttribution) sourceIndex() map[string][]string {
+	index := make(map[string][]string, len(a.bySource))
+	for source, owners := range a.bySource {
+		for _, o := range owners {
+			if !containsKey(index[source], o.CustomerID) {
+				index[source] = append(index[source], o.CustomerID)
+			}
+		}
+	}
+	return index
+}
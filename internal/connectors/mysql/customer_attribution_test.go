@@ -0,0 +1,79 @@
+package mysql
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+)
+
+// testAttribution builds an attribution from unbounded source mappings and rules.
+func testAttribution(index map[string][]string, rules []mappingRuleMatcher) *customerAttribution {
+	out := &customerAttribution{bySource: map[string][]customerSourceOwner{}, rules: rules}
+	for source, ids := range index {
+		for _, id := range ids {
+			out.bySource[source] = append(out.bySource[source], customerSourceOwner{CustomerID: id, Mapping: CustomerSourceMapping{SourceOfAcquisition: source}})
+		}
+	}
+	return out
+}
+
+func TestCustomerAttribution_EffectiveWindows(t *testing.T) {
+	cm, err := customermap.NewSQLiteStore(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+	s := &Store{customerMap: cm, queryTimeout: time.Second}
+	ctx := context.Background()
+
+	switchover := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := s.AddCustomerMapping(ctx, "acme", "shared-ftp", nil, &switchover); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddCustomerMapping(ctx, "globex", "shared-ftp", &switchover, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SaveCustomerMappingRule(ctx, CustomerMappingRule{CustomerID: "initech", Field: "accession_id", MatchType: "prefix", Pattern: "INI-", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := s.customerAttribution(ctx)
+	if err != nil || a == nil {
+		t.Fatalf("attribution: %v %v", a, err)
+	}
+	before, after := switchover.Add(-time.Hour), switchover.Add(time.Hour)
+	cases := []struct {
+		tr   mappingRuleTransfer
+		want string
+	}{
+		{mappingRuleTransfer{Source: "shared-ftp", StartedAt: &before}, "acme"},
+		{mappingRuleTransfer{Source: "shared-ftp", StartedAt: &after}, "globex"},
+		{mappingRuleTransfer{Source: "shared-ftp"}, "unmapped"},
+		{mappingRuleTransfer{Source: "shared-ftp", Accession: "INI-1", StartedAt: &after}, "globex,initech"},
+		{mappingRuleTransfer{Source: "scanner"}, "unmapped"},
+	}
+	for _, c := range cases {
+		if got := strings.Join(a.customersFor(c.tr), ","); got != c.want {
+			t.Fatalf("customersFor(%+v) = %q, want %q", c.tr, got, c.want)
+		}
+	}
+	if got := strings.Join(a.sourceIndex()["shared-ftp"], ","); got != "acme,globex" {
+		t.Fatalf("unexpected source index %q", got)
+	}
+
+	// Billing, storage and forecast use the all-time index: windows and rules do
+	// not apply there.
+	index, err := s.customerSourceIndex(ctx)
+	if err != nil || strings.Join(index["shared-ftp"], ",") != "acme,globex" || len(index) != 1 {
+		t.Fatalf("unexpected all-time index %v %v", index, err)
+	}
+
+	var none *customerAttribution
+	if got := none.customersFor(mappingRuleTransfer{Source: "raw-source"}); len(got) != 1 || got[0] != "raw-source" {
+		t.Fatalf("expected the source without a mapping backend, got %v", got)
+	}
+}
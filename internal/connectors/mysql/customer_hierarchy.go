@@ -0,0 +1,201 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+)
+
+// Customer is a customer entity from the app SQLite store. A customer with a
+// ParentID is a member of that customer; reports for the parent roll up its members.
+type Customer struct {
+	ID          string            `json:"id"`
+	DisplayName string            `json:"display_name"`
+	ParentID    string            `json:"parent_id"`
+	Contact     string            `json:"contact"`
+	Metadata    map[string]string `json:"metadata"`
+	Members     []string          `json:"members"`
+	CreatedAt   *time.Time        `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time        `json:"updated_at,omitempty"`
+}
+
+// CustomerTree lists customers under their parents with the transfers completed
+// in [From, To) attributed to each. Transfers counts every scanned transfer,
+// attributed or not.
+type CustomerTree struct {
+	From          time.Time         `json:"from"`
+	To            time.Time         `json:"to"`
+	Customers     []CustomerSummary `json:"customers"`
+	Count         int               `json:"count"`
+	Transfers     int64             `json:"transfers"`
+	ScanTruncated bool              `json:"scan_truncated"`
+}
+
+// GetCustomer returns a customer with its direct and indirect members; an unknown
+// id returns sql.ErrNoRows.
+func (s *Store) GetCustomer(ctx context.Context, id string) (*Customer, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	it, err := store.GetCustomer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	out := customerFromStore(*it)
+	if out.Members, err = store.CustomerDescendants(ctx, out.ID); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SaveCustomer inserts or updates a customer.
+func (s *Store) SaveCustomer(ctx context.Context, item Customer) error {
+	store, err := s.templateStore()
+	if err != nil {
+		return err
+	}
+	return store.SaveCustomer(ctx, customerToStore(item))
+}
+
+// DeleteCustomer removes a customer; its members move up to its parent and its
+// mappings and rules are kept.
+func (s *Store) DeleteCustomer(ctx context.Context, id string) (int64, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return 0, err
+	}
+	return store.DeleteCustomer(ctx, id)
+}
+
+// ListCustomers returns up to limit customers as a tree, with the transfers
+// completed in [from, to) attributed to each node through the exact mappings valid
+// at their start and the enabled mapping rules. A customer whose parent is not
+// listed becomes a root.
+func (s *Store) ListCustomers(ctx context.Context, from, to time.Time, limit int) (*CustomerTree, error) {
+	items, err := s.listCustomerSummaries(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	attribution, err := s.customerAttribution(ctx)
+	if err != nil {
+		return nil, err
+	}
+	transfers, truncated, err := s.mappingRuleTransfers(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &CustomerTree{
+		From:          from.UTC(),
+		To:            to.UTC(),
+		Customers:     buildCustomerTree(items, transfers, attribution),
+		Count:         len(items),
+		Transfers:     int64(len(transfers)),
+		ScanTruncated: truncated,
+	}, nil
+}
+
+// buildCustomerTree nests items, sorted by ID, under their parents and counts the
+// transfers of each node. A transfer counts once per node even when several
+// members own it.
+func buildCustomerTree(items []CustomerSummary, transfers []mappingRuleTransfer, attribution *customerAttribution) []CustomerSummary {
+	byID := make(map[string]*CustomerSummary, len(items))
+	for i := range items {
+		items[i].Children = nil
+		byID[items[i].CustomerID] = &items[i]
+	}
+	parentOf := func(id string) string {
+		if node, ok := byID[id]; ok {
+			if _, listed := byID[node.ParentID]; listed {
+				return node.ParentID
+			}
+		}
+		return ""
+	}
+
+	for _, tr := range transfers {
+		var owners []string
+		if attribution != nil {
+			owners = attribution.owners(tr)
+		}
+		own, rolled := map[string]bool{}, map[string]bool{}
+		for _, id := range owners {
+			node, ok := byID[id]
+			if !ok || own[id] {
+				continue
+			}
+			own[id] = true
+			node.Transfers++
+			for cur := id; cur != "" && !rolled[cur]; cur = parentOf(cur) {
+				rolled[cur] = true
+				byID[cur].RollupTransfers++
+			}
+		}
+	}
+
+	children := map[string][]string{}
+	roots := make([]string, 0)
+	for _, it := range items {
+		if parent := parentOf(it.CustomerID); parent != "" {
+			children[parent] = append(children[parent], it.CustomerID)
+		} else {
+			roots = append(roots, it.CustomerID)
+		}
+	}
+	var build func(id string, seen map[string]bool) CustomerSummary
+	build = func(id string, seen map[string]bool) CustomerSummary {
+		node := *byID[id]
+		seen[id] = true
+		for _, child := range children[id] {
+			if !seen[child] {
+				node.Children = append(node.Children, build(child, seen))
+			}
+		}
+		return node
+	}
+	out := make([]CustomerSummary, 0, len(roots))
+	seen := map[string]bool{}
+	for _, id := range roots {
+		out = append(out, build(id, seen))
+	}
+	return out
+}
+
+// customerMembers returns the customer followed by its direct and indirect
+// members. Without the SQLite store customers have no members.
+func (s *Store) customerMembers(ctx context.Context, customerID string) ([]string, error) {
+	customerID = strings.TrimSpace(customerID)
+	if s.customerMap == nil {
+		return []string{customerID}, nil
+	}
+	members, err := s.customerMap.CustomerDescendants(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{customerID}, members...), nil
+}
+
+func customerFromStore(it customermap.Customer) Customer {
+	return Customer{
+		ID:          it.ID,
+		DisplayName: it.DisplayName,
+		ParentID:    it.ParentID,
+		Contact:     it.Contact,
+		Metadata:    it.Metadata,
+		Members:     []string{},
+		CreatedAt:   it.CreatedAt,
+		UpdatedAt:   it.UpdatedAt,
+	}
+}
+
+func customerToStore(it Customer) customermap.Customer {
+	return customermap.Customer{
+		ID:          it.ID,
+		DisplayName: it.DisplayName,
+		ParentID:    it.ParentID,
+		Contact:     it.Contact,
+		Metadata:    it.Metadata,
+	}
+}
@@ -0,0 +1,126 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+)
+
+func TestCustomerHierarchy_RollupFilter(t *testing.T) {
+	cm, err := customermap.NewSQLiteStore(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+	s := &Store{customerMap: cm, queryTimeout: time.Second}
+	ctx := context.Background()
+
+	// Mapped customer IDs become entities.
+	if err := cm.CreateMapping(ctx, "uni-a", "uni-a-ftp"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveCustomer(ctx, Customer{ID: "consortium", DisplayName: "Library Consortium", Contact: "ops@example.org", Metadata: map[string]string{"region": "north"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveCustomer(ctx, Customer{ID: "uni-a", DisplayName: "University A", ParentID: "consortium"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveCustomer(ctx, Customer{ID: "uni-b", ParentID: "uni-a"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SaveCustomerMappingRule(ctx, CustomerMappingRule{CustomerID: "uni-b", Field: "accession_id", MatchType: "prefix", Pattern: "UB-", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SaveCustomer(ctx, Customer{ID: "consortium", ParentID: "uni-b"}); !errors.Is(err, customermap.ErrCustomerCycle) {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+	if err := s.SaveCustomer(ctx, Customer{ID: "uni-c", ParentID: "missing"}); err == nil {
+		t.Fatal("expected an unknown parent to be rejected")
+	}
+	if err := s.SaveCustomer(ctx, Customer{ID: "All"}); err == nil {
+		t.Fatal("expected a reserved id to be rejected")
+	}
+
+	item, err := s.GetCustomer(ctx, "consortium")
+	if err != nil || item.DisplayName != "Library Consortium" || item.Metadata["region"] != "north" || strings.Join(item.Members, ",") != "uni-a,uni-b" {
+		t.Fatalf("unexpected customer %+v %v", item, err)
+	}
+
+	clause, args, err := s.sourceFilterClause(ctx, "consortium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clause != "AND (t.sourceOfAcquisition IN (?) OR (LOWER(COALESCE(t.accessionID, '')) LIKE ?))" || len(args) != 2 || args[0] != "uni-a-ftp" || args[1] != "ub-%" {
+		t.Fatalf("unexpected rollup clause %q %v", clause, args)
+	}
+	clause, _, err = s.sourceFilterClause(ctx, "uni-b")
+	if err != nil || strings.Contains(clause, "IN (") {
+		t.Fatalf("a member does not include its parent: %q %v", clause, err)
+	}
+
+	// Deleting a customer moves its members up and keeps its mappings.
+	if deleted, err := s.DeleteCustomer(ctx, "uni-a"); err != nil || deleted != 1 {
+		t.Fatalf("delete: %d %v", deleted, err)
+	}
+	if item, err := s.GetCustomer(ctx, "uni-b"); err != nil || item.ParentID != "consortium" {
+		t.Fatalf("expected uni-b to move up: %+v %v", item, err)
+	}
+	customers, err := s.listCustomerSummaries(ctx, 10)
+	if err != nil || len(customers) != 3 || customers[1].CustomerID != "uni-a" || customers[1].SourceCount != 1 || customers[1].ParentID != "" {
+		t.Fatalf("unexpected customers %+v %v", customers, err)
+	}
+}
+
+func TestBuildCustomerTree_Counts(t *testing.T) {
+	items := []CustomerSummary{
+		{CustomerID: "acme"},
+		{CustomerID: "consortium"},
+		{CustomerID: "uni-a", ParentID: "consortium"},
+		{CustomerID: "uni-b", ParentID: "consortium"},
+		{CustomerID: "uni-b-lab", ParentID: "uni-b"},
+		{CustomerID: "orphan", ParentID: "unlisted"},
+	}
+	index := map[string][]string{
+		"acme-ftp":  {"acme"},
+		"uni-a-ftp": {"uni-a"},
+		"shared":    {"uni-a", "uni-b"},
+		"cons-ftp":  {"consortium"},
+	}
+	rules, err := compileMappingRules([]CustomerMappingRule{
+		{ID: 1, CustomerID: "uni-b-lab", Field: "accession_id", MatchType: "prefix", Pattern: "LAB-", Enabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transfers := []mappingRuleTransfer{
+		{Source: "acme-ftp"},
+		{Source: "uni-a-ftp"},
+		{Source: "uni-a-ftp"},
+		{Source: "shared"},
+		{Source: "cons-ftp"},
+		{Source: "other", Accession: "LAB-7"},
+		{Source: "unknown"},
+	}
+
+	tree := buildCustomerTree(items, transfers, testAttribution(index, rules))
+	if len(tree) != 3 || tree[0].CustomerID != "acme" || tree[1].CustomerID != "consortium" || tree[2].CustomerID != "orphan" {
+		t.Fatalf("unexpected roots %+v", tree)
+	}
+	cons := tree[1]
+	if cons.Transfers != 1 || cons.RollupTransfers != 5 || len(cons.Children) != 2 {
+		t.Fatalf("unexpected consortium node %+v", cons)
+	}
+	uniA, uniB := cons.Children[0], cons.Children[1]
+	if uniA.Transfers != 3 || uniA.RollupTransfers != 3 {
+		t.Fatalf("unexpected uni-a node %+v", uniA)
+	}
+	if uniB.Transfers != 1 || uniB.RollupTransfers != 2 || len(uniB.Children) != 1 || uniB.Children[0].RollupTransfers != 1 {
+		t.Fatalf("unexpected uni-b node %+v", uniB)
+	}
+}
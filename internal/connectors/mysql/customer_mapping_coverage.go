@@ -0,0 +1,207 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	mappingSuggestionLimit    = 3
+	mappingSuggestionMinScore = 0.5
+)
+
+// ErrNoCustomerMapping is returned when neither the SQLite store nor
+// CustomerTransferSources is available, so every source counts as its own customer.
+var ErrNoCustomerMapping = errors.New("no customer mapping backend configured")
+
+// CustomerMappingSuggestion is a likely owner of an unmapped source, found by
+// comparing it with the sources already mapped to the customer.
+type CustomerMappingSuggestion struct {
+	CustomerID    string  `json:"customer_id"`
+	Score         float64 `json:"score"`
+	MatchedSource string  `json:"matched_source"`
+}
+
+// UnmappedSource is a sourceOfAcquisition value whose transfers no exact mapping
+// or mapping rule attributes to a customer.
+type UnmappedSource struct {
+	SourceOfAcquisition string                      `json:"source_of_acquisition"`
+	Transfers           int64                       `json:"transfers"`
+	FirstCompletedAt    *time.Time                  `json:"first_completed_at"`
+	LastCompletedAt     *time.Time                  `json:"last_completed_at"`
+	Suggestions         []CustomerMappingSuggestion `json:"suggestions"`
+}
+
+// CustomerMappingCoverage is the share of transfers completed in [From, To) that
+// are attributed to at least one customer.
+type CustomerMappingCoverage struct {
+	From            time.Time        `json:"from"`
+	To              time.Time        `json:"to"`
+	Transfers       int64            `json:"transfers"`
+	Attributed      int64            `json:"attributed"`
+	Unattributed    int64            `json:"unattributed"`
+	CoveragePercent float64          `json:"coverage_percent"`
+	UnmappedSources int              `json:"unmapped_sources"`
+	ScanTruncated   bool             `json:"scan_truncated"`
+	Unmapped        []UnmappedSource `json:"unmapped,omitempty"`
+}
+
+// GetCustomerMappingCoverage attributes the transfers completed in the window with
+// the exact mappings valid at their start and the enabled mapping rules. With
+// limit > 0 the result lists up to limit unmapped sources, most transfers first,
+// with owner suggestions.
+func (s *Store) GetCustomerMappingCoverage(ctx context.Context, from, to time.Time, limit int) (*CustomerMappingCoverage, error) {
+	attribution, err := s.customerAttribution(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if attribution == nil {
+		return nil, ErrNoCustomerMapping
+	}
+	transfers, truncated, err := s.mappingRuleTransfers(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	out := computeMappingCoverage(transfers, attribution, limit)
+	out.From, out.To = from.UTC(), to.UTC()
+	out.ScanTruncated = truncated
+	return out, nil
+}
+
+// computeMappingCoverage counts the transfers attribution assigns to no customer.
+// A source whose mapping window does not cover a transfer is unmapped for it.
+func computeMappingCoverage(transfers []mappingRuleTransfer, attribution *customerAttribution, limit int) *CustomerMappingCoverage {
+	out := &CustomerMappingCoverage{Transfers: int64(len(transfers))}
+	bySource := map[string]*UnmappedSource{}
+	for _, tr := range transfers {
+		if len(attribution.owners(tr)) > 0 {
+			out.Attributed++
+			continue
+		}
+		out.Unattributed++
+		item, ok := bySource[tr.Source]
+		if !ok {
+			item = &UnmappedSource{SourceOfAcquisition: tr.Source, Suggestions: []CustomerMappingSuggestion{}}
+			bySource[tr.Source] = item
+		}
+		item.Transfers++
+		if tr.CompletedAt != nil {
+			if item.FirstCompletedAt == nil || tr.CompletedAt.Before(*item.FirstCompletedAt) {
+				item.FirstCompletedAt = tr.CompletedAt
+			}
+			if item.LastCompletedAt == nil || tr.CompletedAt.After(*item.LastCompletedAt) {
+				item.LastCompletedAt = tr.CompletedAt
+			}
+		}
+	}
+	out.CoveragePercent = 100
+	if out.Transfers > 0 {
+		out.CoveragePercent = round2(float64(out.Attributed) * 100 / float64(out.Transfers))
+	}
+	out.UnmappedSources = len(bySource)
+	if limit <= 0 {
+		return out
+	}
+
+	unmapped := make([]UnmappedSource, 0, len(bySource))
+	for _, item := range bySource {
+		unmapped = append(unmapped, *item)
+	}
+	sort.Slice(unmapped, func(i, j int) bool {
+		if unmapped[i].Transfers != unmapped[j].Transfers {
+			return unmapped[i].Transfers > unmapped[j].Transfers
+		}
+		return unmapped[i].SourceOfAcquisition < unmapped[j].SourceOfAcquisition
+	})
+	if len(unmapped) > limit {
+		unmapped = unmapped[:limit]
+	}
+	index := attribution.sourceIndex()
+	for i := range unmapped {
+		unmapped[i].Suggestions = suggestCustomersForSource(unmapped[i].SourceOfAcquisition, index)
+	}
+	out.Unmapped = unmapped
+	return out
+}
+
+// suggestCustomersForSource ranks customers by how similar their mapped sources,
+// or their IDs, are to source.
+func suggestCustomersForSource(source string, index map[string][]string) []CustomerMappingSuggestion {
+	out := []CustomerMappingSuggestion{}
+	norm := normalizeSourceName(source)
+	if norm == "" {
+		return out
+	}
+	best := map[string]CustomerMappingSuggestion{}
+	consider := func(customerID, candidate string) {
+		score := sourceSimilarity(norm, normalizeSourceName(candidate))
+		if score < mappingSuggestionMinScore {
+			return
+		}
+		if cur, ok := best[customerID]; ok && cur.Score >= score {
+			return
+		}
+		best[customerID] = CustomerMappingSuggestion{CustomerID: customerID, Score: round2(score), MatchedSource: candidate}
+	}
+	for mapped, customers := range index {
+		for _, customerID := range customers {
+			consider(customerID, mapped)
+			consider(customerID, customerID)
+		}
+	}
+	for _, it := range best {
+		out = append(out, it)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].CustomerID < out[j].CustomerID
+	})
+	if len(out) > mappingSuggestionLimit {
+		out = out[:mappingSuggestionLimit]
+	}
+	return out
+}
+
+// normalizeSourceName lowercases a source and turns punctuation into single spaces.
+func normalizeSourceName(v string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(v), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// sourceSimilarity is the better of the edit-distance ratio and the token overlap
+// of two normalized source names.
+func sourceSimilarity(a, b string) float64 {
+	if a == "" || b == "" {
+		return 0
+	}
+	ra, rb := []rune(a), []rune(b)
+	edit := 1 - float64(levenshtein(ra, rb))/float64(max(len(ra), len(rb)))
+	return max(edit, jaccard(signatureTokens(a), signatureTokens(b)))
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,54 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeMappingCoverage(t *testing.T) {
+	day := func(d int) *time.Time {
+		v := time.Date(2026, 3, d, 12, 0, 0, 0, time.UTC)
+		return &v
+	}
+	index := map[string][]string{
+		"Acme Corp FTP": {"acme"},
+		"globex-nas":    {"globex"},
+	}
+	rules, err := compileMappingRules([]CustomerMappingRule{
+		{ID: 1, CustomerID: "initech", Field: "accession_id", MatchType: "prefix", Pattern: "INI-", Enabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transfers := []mappingRuleTransfer{
+		{UUID: "t1", Source: "Acme Corp FTP", CompletedAt: day(1)},
+		{UUID: "t2", Source: "globex-nas", CompletedAt: day(2)},
+		{UUID: "t3", Source: "scanner-7", Accession: "INI-42", CompletedAt: day(3)},
+		{UUID: "t4", Source: "acme-corp-sftp", CompletedAt: day(5)},
+		{UUID: "t5", Source: "acme-corp-sftp", CompletedAt: day(4)},
+		{UUID: "t6", Source: "unrelated", CompletedAt: day(6)},
+	}
+
+	got := computeMappingCoverage(transfers, testAttribution(index, rules), 10)
+
+	if got.Transfers != 6 || got.Attributed != 3 || got.Unattributed != 3 || got.CoveragePercent != 50 || got.UnmappedSources != 2 {
+		t.Fatalf("unexpected coverage %+v", got)
+	}
+	if len(got.Unmapped) != 2 {
+		t.Fatalf("expected two unmapped sources, got %+v", got.Unmapped)
+	}
+	first := got.Unmapped[0]
+	if first.SourceOfAcquisition != "acme-corp-sftp" || first.Transfers != 2 || !first.FirstCompletedAt.Equal(*day(4)) || !first.LastCompletedAt.Equal(*day(5)) {
+		t.Fatalf("unexpected first unmapped source %+v", first)
+	}
+	if len(first.Suggestions) == 0 || first.Suggestions[0].CustomerID != "acme" || first.Suggestions[0].MatchedSource != "Acme Corp FTP" {
+		t.Fatalf("expected acme to be suggested, got %+v", first.Suggestions)
+	}
+	if s := got.Unmapped[1].Suggestions; len(s) != 0 {
+		t.Fatalf("expected no suggestion for an unrelated source, got %+v", s)
+	}
+
+	if got := computeMappingCoverage(nil, testAttribution(index, nil), 0); got.CoveragePercent != 100 || got.Unmapped != nil {
+		t.Fatalf("empty window should be fully covered, got %+v", got)
+	}
+}
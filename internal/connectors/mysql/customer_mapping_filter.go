@@ -2,7 +2,6 @@ package mysql
 
 import (
 	"context"
-	"fmt"
 	"strings"
 )
 
@@ -13,20 +12,37 @@ func (s *Store) sourceFilterClause(ctx context.Context, customerID string) (stri
 	}
 
 	if s.customerMap != nil {
-		sources, err := s.customerMap.SourcesForCustomer(ctx, trimmed)
+		// Reports for a parent customer roll up the transfers of its members.
+		members, err := s.customerMembers(ctx, trimmed)
 		if err != nil {
 			return "", nil, err
 		}
-		if len(sources) == 0 {
-			return "AND 1 = 0", nil, nil
+		stored, err := s.customerMap.ListMappingRules(ctx, "", true)
+		if err != nil {
+			return "", nil, err
 		}
-		placeholders := make([]string, 0, len(sources))
-		args := make([]any, 0, len(sources))
-		for _, src := range sources {
-			placeholders = append(placeholders, "?")
-			args = append(args, src)
+		rules := customerMappingRulesFromStore(stored)
+		conds := make([]string, 0, 2*len(members))
+		args := make([]any, 0)
+		for _, member := range members {
+			mappings, err := s.customerMap.MappingsForCustomer(ctx, member)
+			if err != nil {
+				return "", nil, err
+			}
+			if clause, clauseArgs := mappingWindowClause(customerSourceMappingsFromStore(mappings)); clause != "" {
+				conds = append(conds, clause)
+				args = append(args, clauseArgs...)
+			}
+		}
+		// Mapping rules claim transfers in addition to exact source mappings.
+		if clause, clauseArgs := mappingRulesClaimClause(members, rules); clause != "" {
+			conds = append(conds, clause)
+			args = append(args, clauseArgs...)
+		}
+		if len(conds) == 0 {
+			return "AND 1 = 0", nil, nil
 		}
-		return fmt.Sprintf("AND t.sourceOfAcquisition IN (%s)", strings.Join(placeholders, ",")), args, nil
+		return "AND (" + strings.Join(conds, " OR ") + ")", args, nil
 	}
 
 	if s.hasCustomerSourceMapping {
@@ -0,0 +1,185 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+)
+
+// transferStartExpr is the time a mapping window is checked against: the first
+// job of the transfer, or its completion when it has no jobs.
+const transferStartExpr = `COALESCE(` + transferFirstJobExpr + `, t.completed_at)`
+
+// transferFirstJobExpr is the creation time of the transfer's first job. Queries
+// that scan many transfers select it next to t.completed_at and apply the
+// transferStartExpr fallback with transferStart.
+const transferFirstJobExpr = `(SELECT MIN(j.createdTime) FROM Jobs j WHERE j.SIPUUID = t.transferUUID)`
+
+// transferStart is transferStartExpr for a scanned first job and completion time.
+func transferStart(firstJob, completedAt sql.NullTime) *time.Time {
+	if firstJob.Valid {
+		return nullTimePtr(firstJob)
+	}
+	return nullTimePtr(completedAt)
+}
+
+// CustomerSourceMapping is one mapped source with its validity window. Nil bounds
+// are open; EffectiveFrom is inclusive and EffectiveTo exclusive.
+type CustomerSourceMapping struct {
+	SourceOfAcquisition string     `json:"source_of_acquisition"`
+	EffectiveFrom       *time.Time `json:"effective_from"`
+	EffectiveTo         *time.Time `json:"effective_to"`
+}
+
+// CustomerMappingEvent is one recorded change to a customer's mappings.
+type CustomerMappingEvent struct {
+	ID                  int64      `json:"id"`
+	SourceOfAcquisition string     `json:"source_of_acquisition"`
+	Action              string     `json:"action"`
+	EffectiveFrom       *time.Time `json:"effective_from"`
+	EffectiveTo         *time.Time `json:"effective_to"`
+	At                  time.Time  `json:"at"`
+}
+
+// CustomerMappingHistory shows how a customer's mapping set evolved: the current
+// mappings, the recorded changes newest first and, with At set, the sources
+// that applied to transfers started at that time.
+type CustomerMappingHistory struct {
+	CustomerID string                  `json:"customer_id"`
+	Mappings   []CustomerSourceMapping `json:"mappings"`
+	Events     []CustomerMappingEvent  `json:"events"`
+	At         *time.Time              `json:"at,omitempty"`
+	SourcesAt  []string                `json:"sources_at,omitempty"`
+}
+
+func (m CustomerSourceMapping) bounded() bool {
+	return m.EffectiveFrom != nil || m.EffectiveTo != nil
+}
+
+func (m CustomerSourceMapping) validAt(t time.Time) bool {
+	return (m.EffectiveFrom == nil || !t.Before(*m.EffectiveFrom)) && (m.EffectiveTo == nil || t.Before(*m.EffectiveTo))
+}
+
+// AddCustomerMapping maps a source to a customer from the app SQLite store with an
+// optional validity window. created is false when the mapping already existed.
+func (s *Store) AddCustomerMapping(ctx context.Context, customerID, source string, from, to *time.Time) (bool, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return false, err
+	}
+	return store.AddMapping(ctx, customermap.Mapping{CustomerID: customerID, SourceOfAcquisition: source, EffectiveFrom: from, EffectiveTo: to})
+}
+
+// SetCustomerMappingValidity changes the window of an existing mapping. It
+// returns sql.ErrNoRows when the customer has no mapping for the source.
+func (s *Store) SetCustomerMappingValidity(ctx context.Context, customerID, source string, from, to *time.Time) (*CustomerSourceMapping, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	it, err := store.SetMappingValidity(ctx, customerID, source, from, to)
+	if err != nil {
+		return nil, err
+	}
+	out := customerSourceMappingFromStore(*it)
+	return &out, nil
+}
+
+// GetCustomerMappingHistory returns the customer's mappings and up to limit of
+// their recorded changes. at is optional.
+func (s *Store) GetCustomerMappingHistory(ctx context.Context, customerID string, at *time.Time, limit int) (*CustomerMappingHistory, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	customerID = strings.TrimSpace(customerID)
+	items, err := store.MappingsForCustomer(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := store.ListMappingEvents(ctx, customerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := &CustomerMappingHistory{
+		CustomerID: customerID,
+		Mappings:   customerSourceMappingsFromStore(items),
+		Events:     make([]CustomerMappingEvent, 0, len(events)),
+	}
+	for _, ev := range events {
+		out.Events = append(out.Events, CustomerMappingEvent{
+			ID:                  ev.ID,
+			SourceOfAcquisition: ev.SourceOfAcquisition,
+			Action:              ev.Action,
+			EffectiveFrom:       ev.EffectiveFrom,
+			EffectiveTo:         ev.EffectiveTo,
+			At:                  ev.CreatedAt,
+		})
+	}
+	if at != nil {
+		t := at.UTC()
+		out.At = &t
+		out.SourcesAt = []string{}
+		for _, m := range out.Mappings {
+			if m.validAt(t) {
+				out.SourcesAt = append(out.SourcesAt, m.SourceOfAcquisition)
+			}
+		}
+	}
+	return out, nil
+}
+
+// mappingWindowClause matches transfers by source for mappings without a window
+// and, for bounded mappings, also by the transfer start time. It returns "" when
+// mappings is empty.
+func mappingWindowClause(mappings []CustomerSourceMapping) (string, []any) {
+	open := make([]any, 0, len(mappings))
+	conds := make([]string, 0)
+	args := make([]any, 0)
+	for _, m := range mappings {
+		if !m.bounded() {
+			open = append(open, m.SourceOfAcquisition)
+		}
+	}
+	if len(open) > 0 {
+		conds = append(conds, fmt.Sprintf("t.sourceOfAcquisition IN (%s)", placeholders(len(open))))
+		args = append(args, open...)
+	}
+	for _, m := range mappings {
+		if !m.bounded() {
+			continue
+		}
+		cond := "t.sourceOfAcquisition = ?"
+		args = append(args, m.SourceOfAcquisition)
+		if m.EffectiveFrom != nil {
+			cond += " AND " + transferStartExpr + " >= ?"
+			args = append(args, m.EffectiveFrom.UTC())
+		}
+		if m.EffectiveTo != nil {
+			cond += " AND " + transferStartExpr + " < ?"
+			args = append(args, m.EffectiveTo.UTC())
+		}
+		conds = append(conds, "("+cond+")")
+	}
+	return strings.Join(conds, " OR "), args
+}
+
+func customerSourceMappingsFromStore(items []customermap.Mapping) []CustomerSourceMapping {
+	out := make([]CustomerSourceMapping, 0, len(items))
+	for _, it := range items {
+		out = append(out, customerSourceMappingFromStore(it))
+	}
+	return out
+}
+
+func customerSourceMappingFromStore(it customermap.Mapping) CustomerSourceMapping {
+	return CustomerSourceMapping{
+		SourceOfAcquisition: it.SourceOfAcquisition,
+		EffectiveFrom:       it.EffectiveFrom,
+		EffectiveTo:         it.EffectiveTo,
+	}
+}
@@ -0,0 +1,109 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+)
+
+func TestMappingWindowClause(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	clause, args := mappingWindowClause([]CustomerSourceMapping{
+		{SourceOfAcquisition: "acme-ftp"},
+		{SourceOfAcquisition: "acme-old", EffectiveTo: &from},
+		{SourceOfAcquisition: "acme-s3", EffectiveFrom: &from, EffectiveTo: &to},
+		{SourceOfAcquisition: "acme-web"},
+	})
+	want := "t.sourceOfAcquisition IN (?,?)" +
+		" OR (t.sourceOfAcquisition = ? AND " + transferStartExpr + " < ?)" +
+		" OR (t.sourceOfAcquisition = ? AND " + transferStartExpr + " >= ? AND " + transferStartExpr + " < ?)"
+	if clause != want {
+		t.Fatalf("unexpected clause %q", clause)
+	}
+	if len(args) != 7 || args[0] != "acme-ftp" || args[1] != "acme-web" || args[2] != "acme-old" || args[3] != from || args[4] != "acme-s3" || args[6] != to {
+		t.Fatalf("unexpected args %v", args)
+	}
+
+	if clause, args := mappingWindowClause(nil); clause != "" || len(args) != 0 {
+		t.Fatalf("expected empty clause, got %q %v", clause, args)
+	}
+}
+
+func TestCustomerMappingHistory_WindowsAndEvents(t *testing.T) {
+	cm, err := customermap.NewSQLiteStore(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+	s := &Store{customerMap: cm, queryTimeout: time.Second}
+	ctx := context.Background()
+
+	cutover := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	if created, err := s.AddCustomerMapping(ctx, "acme", "acme-ftp", nil, &cutover); err != nil || !created {
+		t.Fatalf("add bounded mapping: %v %v", created, err)
+	}
+	if created, err := s.AddCustomerMapping(ctx, "acme", "acme-s3", &cutover, nil); err != nil || !created {
+		t.Fatalf("add second mapping: %v %v", created, err)
+	}
+	if created, err := s.AddCustomerMapping(ctx, "acme", "acme-s3", nil, nil); err != nil || created {
+		t.Fatalf("expected duplicate mapping to be kept as is: %v %v", created, err)
+	}
+	if _, err := s.AddCustomerMapping(ctx, "acme", "acme-web", &cutover, &cutover); err == nil {
+		t.Fatal("expected an empty window to be rejected")
+	}
+
+	clause, args, err := s.sourceFilterClause(ctx, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(clause, "AND ((t.sourceOfAcquisition = ? AND ") || strings.Contains(clause, " IN (") || len(args) != 4 {
+		t.Fatalf("unexpected bounded clause %q %v", clause, args)
+	}
+
+	// Replacing the set keeps the windows of sources that stay mapped.
+	if _, err := s.ReplaceCustomerMappings(ctx, "acme", []string{"acme-s3", "acme-web"}); err != nil {
+		t.Fatal(err)
+	}
+	before := cutover.AddDate(0, 0, -1)
+	history, err := s.GetCustomerMappingHistory(ctx, "acme", &before, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Mappings) != 2 || history.Mappings[0].SourceOfAcquisition != "acme-s3" || history.Mappings[0].EffectiveFrom == nil || !history.Mappings[0].EffectiveFrom.Equal(cutover) {
+		t.Fatalf("unexpected mappings %+v", history.Mappings)
+	}
+	if len(history.SourcesAt) != 1 || history.SourcesAt[0] != "acme-web" {
+		t.Fatalf("unexpected sources at %s: %v", before.Format(time.RFC3339), history.SourcesAt)
+	}
+	actions := make([]string, 0, len(history.Events))
+	for _, ev := range history.Events {
+		actions = append(actions, ev.Action+":"+ev.SourceOfAcquisition)
+	}
+	if got := strings.Join(actions, ","); got != "added:acme-web,removed:acme-ftp,added:acme-s3,added:acme-ftp" {
+		t.Fatalf("unexpected events %s", got)
+	}
+
+	item, err := s.SetCustomerMappingValidity(ctx, "acme", "acme-s3", nil, nil)
+	if err != nil || item.bounded() {
+		t.Fatalf("clear window: %+v %v", item, err)
+	}
+	if _, err := s.SetCustomerMappingValidity(ctx, "acme", "acme-ftp", nil, nil); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for a removed mapping, got %v", err)
+	}
+	history, err = s.GetCustomerMappingHistory(ctx, "acme", nil, 1)
+	if err != nil || len(history.Events) != 1 || history.Events[0].Action != customermap.MappingValidityChanged || history.SourcesAt != nil {
+		t.Fatalf("unexpected history %+v %v", history, err)
+	}
+
+	clause, args, err = s.sourceFilterClause(ctx, "acme")
+	if err != nil || clause != "AND (t.sourceOfAcquisition IN (?,?))" || len(args) != 2 {
+		t.Fatalf("unbounded clause: %q %v %v", clause, args, err)
+	}
+}
@@ -0,0 +1,597 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+	"go-am-realtime-report-ui/internal/dbctx"
+)
+
+const (
+	mappingRulePreviewMaxTransfers = 50000
+	mappingRulePreviewMaxRows      = 1000
+)
+
+// CustomerMappingRule is a rule-based customer mapping from the app SQLite store.
+// Rules claim transfers in addition to the customer's exact source mappings; when
+// rules of several customers match a transfer, the highest priority rule wins and
+// ties go to the oldest rule. Matching is case-insensitive.
+type CustomerMappingRule struct {
+	ID         int64  `json:"id"`
+	CustomerID string `json:"customer_id"`
+	Field      string `json:"field"`
+	MatchType  string `json:"match_type"`
+	Pattern    string `json:"pattern"`
+	Priority   int    `json:"priority"`
+	Enabled    bool   `json:"enabled"`
+	Note       string `json:"note"`
+	CreatedAt  string `json:"created_at,omitempty"`
+	UpdatedAt  string `json:"updated_at,omitempty"`
+}
+
+// MappingRulePreviewOptions bounds the transfers a rule preview runs against.
+type MappingRulePreviewOptions struct {
+	From  time.Time
+	To    time.Time
+	Limit int
+}
+
+// MappingRulePreviewRule reports how many scanned transfers one rule matched and
+// how many of those it won.
+type MappingRulePreviewRule struct {
+	CustomerMappingRule
+	Candidate bool  `json:"candidate"`
+	Matched   int64 `json:"matched"`
+	Claimed   int64 `json:"claimed"`
+	Overruled int64 `json:"overruled"`
+}
+
+// MappingRuleClaim is one transfer claimed by a candidate rule, or whose owner
+// would change if the candidate rules were saved.
+type MappingRuleClaim struct {
+	TransferUUID        string     `json:"transfer_uuid"`
+	TransferName        string     `json:"transfer_name"`
+	SourceOfAcquisition string     `json:"source_of_acquisition"`
+	AccessionID         string     `json:"accession_id"`
+	SourceLocation      string     `json:"source_location"`
+	CompletedAt         *time.Time `json:"completed_at"`
+	CustomerID          string     `json:"customer_id"`
+	RuleID              int64      `json:"rule_id"`
+	RuleIndex           int        `json:"rule_index"`
+	PreviousCustomerID  string     `json:"previous_customer_id"`
+	OverruledCustomers  []string   `json:"overruled_customers"`
+}
+
+// MappingRulePreview is the outcome of evaluating saved and candidate rules over
+// the transfers completed in [From, To).
+type MappingRulePreview struct {
+	From               time.Time                `json:"from"`
+	To                 time.Time                `json:"to"`
+	Scanned            int64                    `json:"scanned"`
+	ScanTruncated      bool                     `json:"scan_truncated"`
+	Claimed            int64                    `json:"claimed"`
+	Changed            int64                    `json:"changed"`
+	Rules              []MappingRulePreviewRule `json:"rules"`
+	Transfers          []MappingRuleClaim       `json:"transfers"`
+	TransfersTruncated bool                     `json:"transfers_truncated"`
+}
+
+// ValidateCustomerMappingRule normalizes a rule and checks its field, match type
+// and pattern.
+func ValidateCustomerMappingRule(rule CustomerMappingRule) (CustomerMappingRule, error) {
+	it, err := customermap.NormalizeMappingRule(rule.toStore())
+	if err != nil {
+		return rule, err
+	}
+	return customerMappingRuleFromStore(it), nil
+}
+
+// ListCustomerMappingRules returns the rules of one customer, or of all customers
+// when customerID is empty, in evaluation order.
+func (s *Store) ListCustomerMappingRules(ctx context.Context, customerID string) ([]CustomerMappingRule, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	items, err := store.ListMappingRules(ctx, customerID, false)
+	if err != nil {
+		return nil, err
+	}
+	return customerMappingRulesFromStore(items), nil
+}
+
+func (s *Store) GetCustomerMappingRule(ctx context.Context, id int64) (*CustomerMappingRule, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	it, err := store.GetMappingRule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	out := customerMappingRuleFromStore(*it)
+	return &out, nil
+}
+
+func (s *Store) SaveCustomerMappingRule(ctx context.Context, rule CustomerMappingRule) (int64, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return 0, err
+	}
+	return store.SaveMappingRule(ctx, rule.toStore())
+}
+
+func (s *Store) DeleteCustomerMappingRule(ctx context.Context, id int64) (int64, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return 0, err
+	}
+	return store.DeleteMappingRule(ctx, id)
+}
+
+// PreviewCustomerMappingRules evaluates candidate rules together with the saved
+// enabled rules over the transfers completed in the window. A candidate with the
+// ID of a saved rule replaces it, so disabling a saved rule can be previewed too.
+// Nothing is saved.
+func (s *Store) PreviewCustomerMappingRules(ctx context.Context, candidates []CustomerMappingRule, opts MappingRulePreviewOptions) (*MappingRulePreview, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	for i, c := range candidates {
+		if candidates[i], err = ValidateCustomerMappingRule(c); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	saved, err := store.ListMappingRules(ctx, "", true)
+	if err != nil {
+		return nil, err
+	}
+	transfers, truncated, err := s.mappingRuleTransfers(ctx, opts.From, opts.To)
+	if err != nil {
+		return nil, err
+	}
+	preview, err := evaluateMappingRulePreview(customerMappingRulesFromStore(saved), candidates, transfers, opts.Limit)
+	if err != nil {
+		return nil, err
+	}
+	preview.From, preview.To = opts.From.UTC(), opts.To.UTC()
+	preview.ScanTruncated = truncated
+	return preview, nil
+}
+
+// mappingRuleTransfer holds the transfer fields rules can match on.
+type mappingRuleTransfer struct {
+	UUID        string
+	Location    string
+	Source      string
+	Accession   string
+	CompletedAt *time.Time
+	// StartedAt is transferStartExpr, the time mapping windows are checked against.
+	StartedAt *time.Time
+}
+
+func (t mappingRuleTransfer) field(name string) string {
+	switch name {
+	case "source_of_acquisition":
+		return t.Source
+	case "accession_id":
+		return t.Accession
+	case "transfer_name":
+		return mappingRuleTransferName(t.Location, t.UUID)
+	case "source_location":
+		return t.Location
+	}
+	return ""
+}
+
+func (s *Store) mappingRuleTransfers(ctx context.Context, from, to time.Time) ([]mappingRuleTransfer, bool, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT
+  t.transferUUID,
+  COALESCE(t.currentLocation, ''),
+  COALESCE(t.sourceOfAcquisition, ''),
+  COALESCE(t.accessionID, ''),
+  t.completed_at,
+  `+transferStartExpr+`
+FROM Transfers t
+WHERE t.completed_at >= ?
+  AND t.completed_at < ?
+ORDER BY t.completed_at DESC
+LIMIT ?;
+`, from, to, mappingRulePreviewMaxTransfers+1)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	out := make([]mappingRuleTransfer, 0)
+	for rows.Next() {
+		var (
+			tr                     mappingRuleTransfer
+			completedAt, startedAt sql.NullTime
+		)
+		if err := rows.Scan(&tr.UUID, &tr.Location, &tr.Source, &tr.Accession, &completedAt, &startedAt); err != nil {
+			return nil, false, err
+		}
+		tr.CompletedAt = nullTimePtr(completedAt)
+		tr.StartedAt = nullTimePtr(startedAt)
+		out = append(out, tr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	if len(out) > mappingRulePreviewMaxTransfers {
+		return out[:mappingRulePreviewMaxTransfers], true, nil
+	}
+	return out, false, nil
+}
+
+type mappingRuleMatcher struct {
+	rule  CustomerMappingRule
+	match func(string) bool
+}
+
+func compileMappingRule(rule CustomerMappingRule) (mappingRuleMatcher, error) {
+	pattern := rule.Pattern
+	m := mappingRuleMatcher{rule: rule}
+	switch rule.MatchType {
+	case "exact":
+		m.match = func(v string) bool { return strings.EqualFold(v, pattern) }
+	case "prefix":
+		lower := strings.ToLower(pattern)
+		m.match = func(v string) bool { return strings.HasPrefix(strings.ToLower(v), lower) }
+	case "glob", "regex":
+		expr := "(?i)" + pattern
+		if rule.MatchType == "glob" {
+			expr = "(?is)^" + globToRegexp(pattern) + "$"
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return m, err
+		}
+		m.match = re.MatchString
+	default:
+		return m, fmt.Errorf("unsupported match_type %q", rule.MatchType)
+	}
+	return m, nil
+}
+
+// orderMappingRules sorts rules into evaluation order. Unsaved rules (ID 0) sort
+// after saved rules of the same priority, as they would once inserted.
+func orderMappingRules(rules []CustomerMappingRule) {
+	sort.SliceStable(rules, func(i, j int) bool { return mappingRuleBefore(rules[i], rules[j]) })
+}
+
+func mappingRuleBefore(a, b CustomerMappingRule) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	return mappingRuleOrderID(a) < mappingRuleOrderID(b)
+}
+
+func mappingRuleOrderID(rule CustomerMappingRule) int64 {
+	if rule.ID <= 0 {
+		return math.MaxInt64
+	}
+	return rule.ID
+}
+
+// mappingRuleOwner returns the index of the winning rule for tr, or -1.
+func mappingRuleOwner(matchers []mappingRuleMatcher, tr mappingRuleTransfer) int {
+	for i, m := range matchers {
+		if m.match(tr.field(m.rule.Field)) {
+			return i
+		}
+	}
+	return -1
+}
+
+func compileMappingRules(rules []CustomerMappingRule) ([]mappingRuleMatcher, error) {
+	orderMappingRules(rules)
+	out := make([]mappingRuleMatcher, 0, len(rules))
+	for _, r := range rules {
+		m, err := compileMappingRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", r.ID, err)
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+func evaluateMappingRulePreview(saved, candidates []CustomerMappingRule, transfers []mappingRuleTransfer, limit int) (*MappingRulePreview, error) {
+	if limit <= 0 || limit > mappingRulePreviewMaxRows {
+		limit = mappingRulePreviewMaxRows
+	}
+	replaced := map[int64]bool{}
+	for _, c := range candidates {
+		if c.ID > 0 {
+			replaced[c.ID] = true
+		}
+	}
+	current := make([]CustomerMappingRule, 0, len(saved))
+	current = append(current, saved...)
+	currentMatchers, err := compileMappingRules(current)
+	if err != nil {
+		return nil, err
+	}
+
+	type proposedRule struct {
+		rule      CustomerMappingRule
+		candidate bool
+	}
+	proposed := make([]proposedRule, 0, len(saved)+len(candidates))
+	for _, r := range saved {
+		if !replaced[r.ID] {
+			proposed = append(proposed, proposedRule{rule: r})
+		}
+	}
+	for _, c := range candidates {
+		if c.Enabled {
+			proposed = append(proposed, proposedRule{rule: c, candidate: true})
+		}
+	}
+	sort.SliceStable(proposed, func(i, j int) bool {
+		return mappingRuleBefore(proposed[i].rule, proposed[j].rule)
+	})
+	sortedRules := make([]CustomerMappingRule, 0, len(proposed))
+	sortedCandidate := make([]bool, 0, len(proposed))
+	for _, p := range proposed {
+		sortedRules = append(sortedRules, p.rule)
+		sortedCandidate = append(sortedCandidate, p.candidate)
+	}
+	proposedMatchers := make([]mappingRuleMatcher, 0, len(sortedRules))
+	for _, r := range sortedRules {
+		m, err := compileMappingRule(r)
+		if err != nil {
+			return nil, err
+		}
+		proposedMatchers = append(proposedMatchers, m)
+	}
+
+	out := &MappingRulePreview{
+		Scanned:   int64(len(transfers)),
+		Rules:     make([]MappingRulePreviewRule, len(sortedRules)),
+		Transfers: make([]MappingRuleClaim, 0),
+	}
+	for i, r := range sortedRules {
+		out.Rules[i] = MappingRulePreviewRule{CustomerMappingRule: r, Candidate: sortedCandidate[i]}
+	}
+	for _, tr := range transfers {
+		winner := -1
+		overruled := []string{}
+		for i, m := range proposedMatchers {
+			if !m.match(tr.field(m.rule.Field)) {
+				continue
+			}
+			out.Rules[i].Matched++
+			if winner < 0 {
+				winner = i
+				out.Rules[i].Claimed++
+				continue
+			}
+			if m.rule.CustomerID != sortedRules[winner].CustomerID {
+				out.Rules[i].Overruled++
+				if !containsKey(overruled, m.rule.CustomerID) {
+					overruled = append(overruled, m.rule.CustomerID)
+				}
+			}
+		}
+		previous := ""
+		if idx := mappingRuleOwner(currentMatchers, tr); idx >= 0 {
+			previous = currentMatchers[idx].rule.CustomerID
+		}
+		owner := ""
+		if winner >= 0 {
+			owner = sortedRules[winner].CustomerID
+			out.Claimed++
+		}
+		changed := owner != previous
+		if changed {
+			out.Changed++
+		}
+		if !changed && (winner < 0 || !sortedCandidate[winner]) {
+			continue
+		}
+		if len(out.Transfers) >= limit {
+			out.TransfersTruncated = true
+			continue
+		}
+		claim := MappingRuleClaim{
+			TransferUUID:        tr.UUID,
+			TransferName:        mappingRuleTransferName(tr.Location, tr.UUID),
+			SourceOfAcquisition: tr.Source,
+			AccessionID:         tr.Accession,
+			SourceLocation:      tr.Location,
+			CompletedAt:         tr.CompletedAt,
+			CustomerID:          owner,
+			RuleIndex:           winner,
+			PreviousCustomerID:  previous,
+			OverruledCustomers:  overruled,
+		}
+		if winner >= 0 {
+			claim.RuleID = sortedRules[winner].ID
+		}
+		out.Transfers = append(out.Transfers, claim)
+	}
+	return out, nil
+}
+
+// mappingRuleTransferName is the transfer name rules match on: the last segment
+// of the transfer location without its "-<uuid>" suffix. It mirrors the SQL in
+// mappingRuleFieldExpr so previews and reports agree.
+func mappingRuleTransferName(location, transferUUID string) string {
+	trimmed := strings.TrimRight(location, "/")
+	name := trimmed[strings.LastIndex(trimmed, "/")+1:]
+	if transferUUID == "" {
+		return name
+	}
+	return strings.ReplaceAll(name, "-"+transferUUID, "")
+}
+
+func mappingRuleFieldExpr(field string) string {
+	switch field {
+	case "source_of_acquisition":
+		return "COALESCE(t.sourceOfAcquisition, '')"
+	case "accession_id":
+		return "COALESCE(t.accessionID, '')"
+	case "transfer_name":
+		return "REPLACE(SUBSTRING_INDEX(TRIM(TRAILING '/' FROM COALESCE(t.currentLocation, '')), '/', -1), CONCAT('-', t.transferUUID), '')"
+	case "source_location":
+		return "COALESCE(t.currentLocation, '')"
+	}
+	return "''"
+}
+
+// mappingRuleCondition translates one rule into a SQL condition on Transfers t.
+func mappingRuleCondition(rule CustomerMappingRule) (string, []any) {
+	expr := mappingRuleFieldExpr(rule.Field)
+	switch rule.MatchType {
+	case "prefix":
+		return "LOWER(" + expr + ") LIKE ?", []any{escapeLike(strings.ToLower(rule.Pattern)) + "%"}
+	case "glob":
+		return "LOWER(" + expr + ") LIKE ?", []any{globToLike(strings.ToLower(rule.Pattern))}
+	case "regex":
+		// The 'i' flag matches the (?i) previews compile with, whatever the collation.
+		return "REGEXP_LIKE(" + expr + ", ?, 'i')", []any{rule.Pattern}
+	default:
+		return "LOWER(" + expr + ") = ?", []any{strings.ToLower(rule.Pattern)}
+	}
+}
+
+// mappingRulesClaimClause builds the condition under which one of customers owns
+// a transfer through its rules: the first matching rule is theirs. rules must be
+// enabled and in evaluation order. Each rule appears once, in a CASE whose
+// branches group consecutive rules of the same outcome; rules after the
+// customers' last rule are left out. It returns "" when the customers have no
+// rules.
+func mappingRulesClaimClause(customers []string, rules []CustomerMappingRule) (string, []any) {
+	last := -1
+	for i, r := range rules {
+		if containsKey(customers, r.CustomerID) {
+			last = i
+		}
+	}
+	if last < 0 {
+		return "", nil
+	}
+
+	type branch struct {
+		owned bool
+		conds []string
+	}
+	branches := make([]branch, 0)
+	args := make([]any, 0)
+	for _, r := range rules[:last+1] {
+		owned := containsKey(customers, r.CustomerID)
+		cond, condArgs := mappingRuleCondition(r)
+		if n := len(branches); n > 0 && branches[n-1].owned == owned {
+			branches[n-1].conds = append(branches[n-1].conds, cond)
+		} else {
+			branches = append(branches, branch{owned: owned, conds: []string{cond}})
+		}
+		args = append(args, condArgs...)
+	}
+	if len(branches) == 1 {
+		return "(" + strings.Join(branches[0].conds, " OR ") + ")", args
+	}
+
+	var b strings.Builder
+	b.WriteString("(CASE")
+	for _, br := range branches {
+		outcome := "0"
+		if br.owned {
+			outcome = "1"
+		}
+		b.WriteString(" WHEN " + strings.Join(br.conds, " OR ") + " THEN " + outcome)
+	}
+	b.WriteString(" END) = 1")
+	return b.String(), args
+}
+
+func escapeLike(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(v)
+}
+
+// globToLike converts * and ? wildcards into a LIKE pattern.
+func globToLike(glob string) string {
+	var b strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteByte('%')
+		case '?':
+			b.WriteByte('_')
+		default:
+			b.WriteString(escapeLike(string(r)))
+		}
+	}
+	return b.String()
+}
+
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteByte('.')
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return b.String()
+}
+
+func (r CustomerMappingRule) toStore() customermap.MappingRule {
+	return customermap.MappingRule{
+		ID:         r.ID,
+		CustomerID: r.CustomerID,
+		Field:      r.Field,
+		MatchType:  r.MatchType,
+		Pattern:    r.Pattern,
+		Priority:   r.Priority,
+		Enabled:    r.Enabled,
+		Note:       r.Note,
+	}
+}
+
+func customerMappingRulesFromStore(items []customermap.MappingRule) []CustomerMappingRule {
+	out := make([]CustomerMappingRule, 0, len(items))
+	for _, it := range items {
+		out = append(out, customerMappingRuleFromStore(it))
+	}
+	return out
+}
+
+func customerMappingRuleFromStore(it customermap.MappingRule) CustomerMappingRule {
+	row := CustomerMappingRule{
+		ID:         it.ID,
+		CustomerID: it.CustomerID,
+		Field:      it.Field,
+		MatchType:  it.MatchType,
+		Pattern:    it.Pattern,
+		Priority:   it.Priority,
+		Enabled:    it.Enabled,
+		Note:       it.Note,
+	}
+	if it.CreatedAt != nil {
+		row.CreatedAt = it.CreatedAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	if it.UpdatedAt != nil {
+		row.UpdatedAt = it.UpdatedAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	return row
+}
@@ -0,0 +1,180 @@
+package mysql
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+)
+
+func TestCompileMappingRule_MatchTypes(t *testing.T) {
+	tr := mappingRuleTransfer{
+		UUID:      "0b6f3c1e-aaaa-bbbb-cccc-1234567890ab",
+		Location:  "%sharedPath%completed/transfers/ACME_2026_box1-0b6f3c1e-aaaa-bbbb-cccc-1234567890ab/",
+		Source:    "acme-ftp",
+		Accession: "ACC-2026-017",
+	}
+	if got := tr.field("transfer_name"); got != "ACME_2026_box1" {
+		t.Fatalf("unexpected transfer name %q", got)
+	}
+
+	cases := []struct {
+		rule CustomerMappingRule
+		want bool
+	}{
+		{CustomerMappingRule{Field: "source_of_acquisition", MatchType: "exact", Pattern: "ACME-FTP"}, true},
+		{CustomerMappingRule{Field: "source_of_acquisition", MatchType: "exact", Pattern: "acme"}, false},
+		{CustomerMappingRule{Field: "transfer_name", MatchType: "prefix", Pattern: "acme_"}, true},
+		{CustomerMappingRule{Field: "accession_id", MatchType: "glob", Pattern: "acc-2026-0??"}, true},
+		{CustomerMappingRule{Field: "accession_id", MatchType: "glob", Pattern: "ACC-2025-*"}, false},
+		{CustomerMappingRule{Field: "source_location", MatchType: "glob", Pattern: "*/transfers/acme*"}, true},
+		{CustomerMappingRule{Field: "accession_id", MatchType: "regex", Pattern: `^acc-\d{4}-0\d+$`}, true},
+		{CustomerMappingRule{Field: "transfer_name", MatchType: "regex", Pattern: `box[2-9]`}, false},
+	}
+	for _, tc := range cases {
+		m, err := compileMappingRule(tc.rule)
+		if err != nil {
+			t.Fatalf("%+v: %v", tc.rule, err)
+		}
+		if got := m.match(tr.field(tc.rule.Field)); got != tc.want {
+			t.Fatalf("%+v: expected %v, got %v", tc.rule, tc.want, got)
+		}
+	}
+
+	if got := globToLike(`a_b%c*?\`); got != `a\_b\%c%_\\` {
+		t.Fatalf("unexpected LIKE pattern %q", got)
+	}
+}
+
+func TestEvaluateMappingRulePreview_PriorityAndChanges(t *testing.T) {
+	saved := []CustomerMappingRule{
+		{ID: 1, CustomerID: "acme", Field: "source_of_acquisition", MatchType: "prefix", Pattern: "acme", Enabled: true},
+	}
+	candidates := []CustomerMappingRule{
+		{CustomerID: "acme-labs", Field: "accession_id", MatchType: "prefix", Pattern: "LAB-", Priority: 10, Enabled: true},
+		{CustomerID: "globex", Field: "source_of_acquisition", MatchType: "exact", Pattern: "globex", Enabled: true},
+	}
+	transfers := []mappingRuleTransfer{
+		{UUID: "t1", Source: "acme-ftp", Accession: "LAB-1"},
+		{UUID: "t2", Source: "acme-ftp", Accession: "ACC-2"},
+		{UUID: "t3", Source: "globex", Accession: "G-3"},
+		{UUID: "t4", Source: "initech", Accession: "I-4"},
+	}
+
+	got, err := evaluateMappingRulePreview(saved, candidates, transfers, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Scanned != 4 || got.Claimed != 3 || got.Changed != 2 {
+		t.Fatalf("unexpected totals: %+v", got)
+	}
+	if len(got.Rules) != 3 || got.Rules[0].CustomerID != "acme-labs" || !got.Rules[0].Candidate || got.Rules[1].ID != 1 {
+		t.Fatalf("unexpected rule order: %+v", got.Rules)
+	}
+	if got.Rules[1].Matched != 2 || got.Rules[1].Claimed != 1 || got.Rules[1].Overruled != 1 {
+		t.Fatalf("unexpected counts for the saved rule: %+v", got.Rules[1])
+	}
+	if len(got.Transfers) != 2 {
+		t.Fatalf("expected the two transfers claimed by candidates, got %+v", got.Transfers)
+	}
+	first := got.Transfers[0]
+	if first.TransferUUID != "t1" || first.CustomerID != "acme-labs" || first.PreviousCustomerID != "acme" ||
+		len(first.OverruledCustomers) != 1 || first.OverruledCustomers[0] != "acme" {
+		t.Fatalf("unexpected claim %+v", first)
+	}
+
+	// Disabling the saved rule through a candidate releases its transfers.
+	got, err = evaluateMappingRulePreview(saved, []CustomerMappingRule{{ID: 1, CustomerID: "acme", Field: "source_of_acquisition", MatchType: "prefix", Pattern: "acme"}}, transfers, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Claimed != 0 || got.Changed != 2 || len(got.Transfers) != 2 || got.Transfers[0].PreviousCustomerID != "acme" || got.Transfers[0].CustomerID != "" {
+		t.Fatalf("unexpected preview after disabling: %+v", got)
+	}
+}
+
+func TestSourceFilterClause_MappingRules(t *testing.T) {
+	cm, err := customermap.NewSQLiteStore(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+	s := &Store{customerMap: cm, queryTimeout: time.Second}
+	ctx := context.Background()
+
+	if err := cm.CreateMapping(ctx, "acme", "acme-ftp"); err != nil {
+		t.Fatal(err)
+	}
+	clause, args, err := s.sourceFilterClause(ctx, "acme")
+	if err != nil || clause != "AND (t.sourceOfAcquisition IN (?))" || len(args) != 1 {
+		t.Fatalf("exact mappings only: %q %v %v", clause, args, err)
+	}
+
+	if _, err := s.SaveCustomerMappingRule(ctx, CustomerMappingRule{CustomerID: "acme", Field: "transfer_name", MatchType: "glob", Pattern: "ACME_*", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SaveCustomerMappingRule(ctx, CustomerMappingRule{CustomerID: "globex", Field: "accession_id", MatchType: "regex", Pattern: "^GX", Priority: 5, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, pattern := range []string{"(", "(?i)^gx", "(?P<code>GX)", `\pL`} {
+		if _, err := s.SaveCustomerMappingRule(ctx, CustomerMappingRule{CustomerID: "globex", Field: "accession_id", MatchType: "regex", Pattern: pattern, Enabled: true}); err == nil {
+			t.Fatalf("expected regex %q to be rejected", pattern)
+		}
+	}
+	if _, err := ValidateCustomerMappingRule(CustomerMappingRule{CustomerID: "globex", Field: "accession_id", MatchType: "regex", Pattern: `^(?:GX|GLX)[[:digit:](?]+\p{L}?$`}); err != nil {
+		t.Fatalf("expected a portable regex to be accepted: %v", err)
+	}
+
+	clause, args, err = s.sourceFilterClause(ctx, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(clause, "AND (t.sourceOfAcquisition IN (?) OR (CASE WHEN REGEXP_LIKE(COALESCE(t.accessionID, ''), ?, 'i') THEN 0 WHEN LOWER(") || !strings.HasSuffix(clause, " LIKE ? THEN 1 END) = 1)") {
+		t.Fatalf("unexpected clause %q", clause)
+	}
+	if len(args) != 3 || args[0] != "acme-ftp" || args[1] != "^GX" || args[2] != "acme\\_%" {
+		t.Fatalf("unexpected args %v", args)
+	}
+
+	clause, args, err = s.sourceFilterClause(ctx, "globex")
+	if err != nil || clause != "AND ((REGEXP_LIKE(COALESCE(t.accessionID, ''), ?, 'i')))" || len(args) != 1 {
+		t.Fatalf("rules only: %q %v %v", clause, args, err)
+	}
+
+	customers, err := s.listCustomerSummaries(ctx, 10)
+	if err != nil || len(customers) != 2 || customers[1].CustomerID != "globex" || customers[1].RuleCount != 1 || customers[1].SourceCount != 0 {
+		t.Fatalf("unexpected customers %+v %v", customers, err)
+	}
+}
+
+func TestMappingRulesClaimClause_Linear(t *testing.T) {
+	rule := func(customerID, pattern string) CustomerMappingRule {
+		return CustomerMappingRule{CustomerID: customerID, Field: "source_of_acquisition", MatchType: "exact", Pattern: pattern, Enabled: true}
+	}
+	rules := []CustomerMappingRule{
+		rule("acme", "a1"), rule("acme", "a2"),
+		rule("globex", "g1"),
+		rule("uni-b", "b1"),
+		rule("initech", "i1"), rule("initech", "i2"),
+	}
+
+	clause, args := mappingRulesClaimClause([]string{"acme", "uni-b"}, rules)
+	cond := "LOWER(COALESCE(t.sourceOfAcquisition, '')) = ?"
+	want := "(CASE WHEN " + cond + " OR " + cond + " THEN 1 WHEN " + cond + " THEN 0 WHEN " + cond + " THEN 1 END) = 1"
+	if clause != want {
+		t.Fatalf("unexpected clause\n got %q\nwant %q", clause, want)
+	}
+	if len(args) != 4 || args[0] != "a1" || args[2] != "g1" || args[3] != "b1" {
+		t.Fatalf("unexpected args %v", args)
+	}
+
+	if clause, args := mappingRulesClaimClause([]string{"acme"}, rules); clause != "("+cond+" OR "+cond+")" || len(args) != 2 {
+		t.Fatalf("leading rules need no CASE: %q %v", clause, args)
+	}
+	if clause, _ := mappingRulesClaimClause([]string{"hooli"}, rules); clause != "" {
+		t.Fatalf("expected no clause without rules, got %q", clause)
+	}
+}
@@ -4,23 +4,37 @@ import (
 	"context"
 	"database/sql"
 	"strings"
+
+	"go-am-realtime-report-ui/internal/dbctx"
 )
 
-// CustomerSummary represents one customer and number of mapped sources.
+// CustomerSummary represents one customer and number of mapped sources and rules.
+// In a CustomerTree it also holds its members and transfer counts: Transfers are
+// attributed to the customer itself, RollupTransfers to it or any member.
 type CustomerSummary struct {
-	CustomerID  string `json:"customer_id"`
-	SourceCount int64  `json:"source_count"`
+	CustomerID      string            `json:"customer_id"`
+	DisplayName     string            `json:"display_name"`
+	ParentID        string            `json:"parent_id"`
+	SourceCount     int64             `json:"source_count"`
+	RuleCount       int64             `json:"rule_count"`
+	Transfers       int64             `json:"transfers"`
+	RollupTransfers int64             `json:"rollup_transfers"`
+	Children        []CustomerSummary `json:"children,omitempty"`
 }
 
-// CustomerMappings holds all sourceOfAcquisition values for a customer.
+// CustomerMappings holds all sourceOfAcquisition values and mapping rules for a
+// customer. Mappings repeats the sources with their validity windows.
 type CustomerMappings struct {
-	CustomerID string   `json:"customer_id"`
-	Sources    []string `json:"sources"`
+	CustomerID string                  `json:"customer_id"`
+	Sources    []string                `json:"sources"`
+	Mappings   []CustomerSourceMapping `json:"mappings"`
+	Rules      []CustomerMappingRule   `json:"rules"`
 }
 
-// ListCustomers returns mapped customers from CustomerTransferSources.
-func (s *Store) ListCustomers(ctx context.Context, limit int) ([]CustomerSummary, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+// listCustomerSummaries returns mapped customers, sorted by ID, from the SQLite
+// store or CustomerTransferSources.
+func (s *Store) listCustomerSummaries(ctx context.Context, limit int) ([]CustomerSummary, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
 	if s.customerMap != nil {
@@ -32,7 +46,10 @@ func (s *Store) ListCustomers(ctx context.Context, limit int) ([]CustomerSummary
 		for _, item := range items {
 			out = append(out, CustomerSummary{
 				CustomerID:  item.CustomerID,
+				DisplayName: item.DisplayName,
+				ParentID:    item.ParentID,
 				SourceCount: item.SourceCount,
+				RuleCount:   item.RuleCount,
 			})
 		}
 		return out, nil
@@ -70,20 +87,28 @@ LIMIT ?;
 
 // GetCustomerMappings returns all mapped source_of_acquisition values for a customer.
 func (s *Store) GetCustomerMappings(ctx context.Context, customerID string) (*CustomerMappings, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
 	trimmed := strings.TrimSpace(customerID)
 	if trimmed == "" {
-		return &CustomerMappings{CustomerID: "", Sources: []string{}}, nil
+		return &CustomerMappings{CustomerID: "", Sources: []string{}, Mappings: []CustomerSourceMapping{}, Rules: []CustomerMappingRule{}}, nil
 	}
 
 	if s.customerMap != nil {
-		sources, err := s.customerMap.SourcesForCustomer(ctx, trimmed)
+		items, err := s.customerMap.MappingsForCustomer(ctx, trimmed)
+		if err != nil {
+			return nil, err
+		}
+		rules, err := s.ListCustomerMappingRules(ctx, trimmed)
 		if err != nil {
 			return nil, err
 		}
-		return &CustomerMappings{CustomerID: trimmed, Sources: sources}, nil
+		out := &CustomerMappings{CustomerID: trimmed, Sources: []string{}, Mappings: customerSourceMappingsFromStore(items), Rules: rules}
+		for _, m := range out.Mappings {
+			out.Sources = append(out.Sources, m.SourceOfAcquisition)
+		}
+		return out, nil
 	}
 
 	const q = `
@@ -113,12 +138,16 @@ ORDER BY cts.source_of_acquisition ASC;
 		return nil, err
 	}
 
-	return &CustomerMappings{CustomerID: trimmed, Sources: sources}, nil
+	mappings := make([]CustomerSourceMapping, 0, len(sources))
+	for _, src := range sources {
+		mappings = append(mappings, CustomerSourceMapping{SourceOfAcquisition: src})
+	}
+	return &CustomerMappings{CustomerID: trimmed, Sources: sources, Mappings: mappings, Rules: []CustomerMappingRule{}}, nil
 }
 
 // CreateCustomerMapping inserts a single customer/source mapping if not present.
 func (s *Store) CreateCustomerMapping(ctx context.Context, customerID, source string) error {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
 	customerID = strings.TrimSpace(customerID)
@@ -139,7 +168,7 @@ ON DUPLICATE KEY UPDATE source_of_acquisition = VALUES(source_of_acquisition);
 
 // ReplaceCustomerMappings replaces all sources for a customer in one transaction.
 func (s *Store) ReplaceCustomerMappings(ctx context.Context, customerID string, sources []string) (int, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
 	customerID = strings.TrimSpace(customerID)
@@ -182,7 +211,7 @@ func (s *Store) ReplaceCustomerMappings(ctx context.Context, customerID string,
 
 // DeleteCustomerMapping deletes one source mapping for a customer.
 func (s *Store) DeleteCustomerMapping(ctx context.Context, customerID, source string) (int64, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
 	customerID = strings.TrimSpace(customerID)
@@ -205,7 +234,7 @@ func (s *Store) DeleteCustomerMapping(ctx context.Context, customerID, source st
 
 // DeleteAllCustomerMappings deletes all mappings for one customer.
 func (s *Store) DeleteAllCustomerMappings(ctx context.Context, customerID string) (int64, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
 	customerID = strings.TrimSpace(customerID)
@@ -0,0 +1,230 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+)
+
+// FailureKnowledge is an app-owned remediation note for a failure pattern in SQLite.
+type FailureKnowledge struct {
+	ID                int64    `json:"id"`
+	SignatureID       string   `json:"signature_id"`
+	MatchText         string   `json:"match_text"`
+	MicroserviceGroup string   `json:"microservice_group"`
+	Title             string   `json:"title"`
+	Explanation       string   `json:"explanation"`
+	Remediation       string   `json:"remediation"`
+	Links             []string `json:"links"`
+	Severity          string   `json:"severity"`
+	CreatedAt         string   `json:"created_at,omitempty"`
+	UpdatedAt         string   `json:"updated_at,omitempty"`
+}
+
+// KnowledgeMatch is the known explanation attached to a failed row.
+type KnowledgeMatch struct {
+	ID          int64    `json:"id"`
+	Title       string   `json:"title"`
+	Severity    string   `json:"severity"`
+	Explanation string   `json:"explanation,omitempty"`
+	Remediation string   `json:"remediation,omitempty"`
+	Links       []string `json:"links,omitempty"`
+	MatchedBy   string   `json:"matched_by"`
+}
+
+const (
+	failureKnowledgeMaxEntries = 1000
+	// failureKnowledgeClusterWindow is the default failure signature window.
+	failureKnowledgeClusterWindow = 30 * 24 * time.Hour
+)
+
+func (s *Store) ListFailureKnowledge(ctx context.Context, limit int) ([]FailureKnowledge, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	items, err := store.ListFailureKnowledge(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]FailureKnowledge, 0, len(items))
+	for _, it := range items {
+		out = append(out, failureKnowledgeFromStore(it))
+	}
+	return out, nil
+}
+
+func (s *Store) GetFailureKnowledge(ctx context.Context, id int64) (*FailureKnowledge, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	it, err := store.GetFailureKnowledge(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	out := failureKnowledgeFromStore(*it)
+	return &out, nil
+}
+
+func (s *Store) SaveFailureKnowledge(ctx context.Context, item FailureKnowledge) (int64, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return 0, err
+	}
+	return store.SaveFailureKnowledge(ctx, customermap.FailureKnowledge{
+		ID:                item.ID,
+		SignatureID:       item.SignatureID,
+		MatchText:         item.MatchText,
+		MicroserviceGroup: item.MicroserviceGroup,
+		Title:             item.Title,
+		Explanation:       item.Explanation,
+		Remediation:       item.Remediation,
+		Links:             item.Links,
+		Severity:          item.Severity,
+	})
+}
+
+func (s *Store) DeleteFailureKnowledge(ctx context.Context, id int64) (int64, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return 0, err
+	}
+	return store.DeleteFailureKnowledge(ctx, id)
+}
+
+func failureKnowledgeFromStore(it customermap.FailureKnowledge) FailureKnowledge {
+	row := FailureKnowledge{
+		ID:                it.ID,
+		SignatureID:       it.SignatureID,
+		MatchText:         it.MatchText,
+		MicroserviceGroup: it.MicroserviceGroup,
+		Title:             it.Title,
+		Explanation:       it.Explanation,
+		Remediation:       it.Remediation,
+		Links:             it.Links,
+		Severity:          it.Severity,
+	}
+	if it.CreatedAt != nil {
+		row.CreatedAt = it.CreatedAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	if it.UpdatedAt != nil {
+		row.UpdatedAt = it.UpdatedAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	return row
+}
+
+// knowledgeIndex matches failure rows against the knowledge base. clusters maps
+// template-level signature IDs to the ID of the failure signature cluster they
+// belong to, so that single rows match entries saved for a signature.
+type knowledgeIndex struct {
+	entries  []FailureKnowledge
+	clusters map[string]string
+}
+
+// loadKnowledgeIndex returns nil when no SQLite store is configured or it cannot be read;
+// annotations are best-effort and never fail the underlying troubleshooting query.
+func (s *Store) loadKnowledgeIndex(ctx context.Context) *knowledgeIndex {
+	if !s.HasTemplateStore() {
+		return nil
+	}
+	entries, err := s.ListFailureKnowledge(ctx, failureKnowledgeMaxEntries)
+	if err != nil || len(entries) == 0 {
+		return nil
+	}
+	return &knowledgeIndex{entries: entries}
+}
+
+// loadRowKnowledgeIndex is loadKnowledgeIndex for single failed rows. When
+// entries are keyed by signature ID it also clusters the failures of the last
+// failureKnowledgeClusterWindow, as ListFailureSignatures does by default.
+func (s *Store) loadRowKnowledgeIndex(ctx context.Context) *knowledgeIndex {
+	kb := s.loadKnowledgeIndex(ctx)
+	if kb == nil {
+		return nil
+	}
+	for _, e := range kb.entries {
+		if e.SignatureID == "" {
+			continue
+		}
+		if rows, err := s.failureRows(ctx, time.Now().UTC().Add(-failureKnowledgeClusterWindow)); err == nil {
+			kb.clusters = failureClusterIDs(clusterFailureRows(rows))
+		}
+		break
+	}
+	return kb
+}
+
+// clusterID returns the cluster a template-level signature ID belongs to, or id
+// itself when it was not clustered.
+func (k *knowledgeIndex) clusterID(id string) string {
+	if k != nil {
+		if cluster, ok := k.clusters[id]; ok {
+			return cluster
+		}
+	}
+	return id
+}
+
+// matchRow matches a single failed row by its cluster and template IDs.
+func (k *knowledgeIndex) matchRow(group, text, templateID string) *KnowledgeMatch {
+	return k.match(group, text, k.clusterID(templateID), templateID)
+}
+
+// match prefers signature ID matches over text matches. signatureIDs are the
+// cluster/template IDs of the row; text is the raw error text.
+func (k *knowledgeIndex) match(group, text string, signatureIDs ...string) *KnowledgeMatch {
+	if k == nil {
+		return nil
+	}
+	group = strings.TrimSpace(group)
+	for _, e := range k.entries {
+		if e.SignatureID == "" || !knowledgeGroupMatches(e, group) {
+			continue
+		}
+		for _, id := range signatureIDs {
+			if id != "" && strings.EqualFold(e.SignatureID, id) {
+				return knowledgeMatchFrom(e, "signature_id")
+			}
+		}
+	}
+	lowered := strings.ToLower(text)
+	template := normalizeFailureText(text)
+	for _, e := range k.entries {
+		if e.MatchText == "" || !knowledgeGroupMatches(e, group) {
+			continue
+		}
+		needle := strings.ToLower(e.MatchText)
+		if strings.Contains(lowered, needle) || strings.Contains(template, needle) {
+			return knowledgeMatchFrom(e, "match_text")
+		}
+	}
+	return nil
+}
+
+func knowledgeGroupMatches(e FailureKnowledge, group string) bool {
+	return e.MicroserviceGroup == "" || strings.EqualFold(e.MicroserviceGroup, group)
+}
+
+func knowledgeMatchFrom(e FailureKnowledge, matchedBy string) *KnowledgeMatch {
+	return &KnowledgeMatch{
+		ID:          e.ID,
+		Title:       e.Title,
+		Severity:    e.Severity,
+		Explanation: e.Explanation,
+		Remediation: e.Remediation,
+		Links:       e.Links,
+		MatchedBy:   matchedBy,
+	}
+}
+
+// rowSignatureID is the template-level signature ID of a single failed row.
+func rowSignatureID(group, text string) string {
+	group = strings.TrimSpace(group)
+	if group == "" {
+		group = "UNKNOWN"
+	}
+	return failureSignatureID(group, signatureTemplate(text))
+}
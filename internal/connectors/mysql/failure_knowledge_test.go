@@ -0,0 +1,54 @@
+package mysql
+
+import "testing"
+
+func TestKnowledgeIndexMatch_PrefersSignatureID(t *testing.T) {
+	text := "fits timed out after 300 seconds on /a/b.tif"
+	kb := &knowledgeIndex{entries: []FailureKnowledge{
+		{ID: 1, MatchText: "timed out", Title: "generic timeout"},
+		{ID: 2, SignatureID: rowSignatureID("Characterize", text), MicroserviceGroup: "characterize", Title: "FITS timeout"},
+	}}
+
+	got := kb.match("Characterize", text, rowSignatureID("Characterize", text))
+	if got == nil || got.ID != 2 || got.MatchedBy != "signature_id" {
+		t.Fatalf("expected signature match on entry 2, got %+v", got)
+	}
+
+	got = kb.match("Normalize", "ffmpeg TIMED OUT", rowSignatureID("Normalize", "ffmpeg TIMED OUT"))
+	if got == nil || got.ID != 1 || got.MatchedBy != "match_text" {
+		t.Fatalf("expected text match on entry 1, got %+v", got)
+	}
+
+	if got := kb.match("Normalize", "ffmpeg returned 1"); got != nil {
+		t.Fatalf("expected no match, got %+v", got)
+	}
+}
+
+func TestKnowledgeIndexMatchRow_ResolvesCluster(t *testing.T) {
+	rows := []failureRow{
+		{TransferUUID: "t-1", Group: "Characterize", ErrorText: "fits could not identify the file format because the alpha plugin crashed"},
+		{TransferUUID: "t-2", Group: "Characterize", ErrorText: "fits could not identify the file format because the alpha plugin crashed"},
+		{TransferUUID: "t-3", Group: "Characterize", ErrorText: "fits could not identify the file format because the beta plugin crashed"},
+	}
+	items := clusterFailureRows(rows)
+	if len(items) != 1 || len(items[0].MemberIDs) != 2 {
+		t.Fatalf("expected one two-template cluster, got %+v", items)
+	}
+	kb := &knowledgeIndex{
+		entries:  []FailureKnowledge{{ID: 7, SignatureID: items[0].ID, Title: "FITS plugin crash"}},
+		clusters: failureClusterIDs(items),
+	}
+
+	for _, row := range rows {
+		templateID := rowSignatureID(row.Group, row.ErrorText)
+		if got := kb.clusterID(templateID); got != items[0].ID {
+			t.Fatalf("expected %s to resolve to cluster %s, got %s", templateID, items[0].ID, got)
+		}
+		if got := kb.matchRow(row.Group, row.ErrorText, templateID); got == nil || got.ID != 7 || got.MatchedBy != "signature_id" {
+			t.Fatalf("expected the cluster entry for %q, got %+v", row.ErrorText, got)
+		}
+	}
+	if got := kb.matchRow("Characterize", "unrelated failure", rowSignatureID("Characterize", "unrelated failure")); got != nil {
+		t.Fatalf("expected no match, got %+v", got)
+	}
+}
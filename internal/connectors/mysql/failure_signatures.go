@@ -0,0 +1,351 @@
+package mysql
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go-am-realtime-report-ui/internal/dbctx"
+)
+
+const (
+	// failureSignatureMaxRows bounds how many failed task rows are normalized per request.
+	failureSignatureMaxRows = 20000
+	// failureSignatureSimilarity is the token Jaccard threshold for merging two templates.
+	failureSignatureSimilarity = 0.8
+	failureSignatureMaxLen     = 220
+	failureSignatureExamples   = 3
+	failureSignatureTransfers  = 20
+)
+
+// FailureSignatureExample is one raw error occurrence behind a signature.
+type FailureSignatureExample struct {
+	TransferUUID string     `json:"transfer_uuid"`
+	ErrorText    string     `json:"error_text"`
+	FailedAt     *time.Time `json:"failed_at"`
+}
+
+var (
+	sigUUIDPattern      = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	sigTimestampPattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[t ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:z|[+-]\d{2}:?\d{2})?`)
+	sigDoubleQuoted     = regexp.MustCompile(`"[^"\n]*"`)
+	sigSingleQuoted     = regexp.MustCompile(`'[^'\n]*'`)
+	sigPathPattern      = regexp.MustCompile(`(?:^|[\s=(\[:,])(?:[/\\][^\s'"(),;\[\]/\\]+)+[/\\]?`)
+	sigHexPattern       = regexp.MustCompile(`\b(?:0x[0-9a-f]+|[0-9a-f]{16,})\b`)
+	sigNumberPattern    = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	sigSpacePattern     = regexp.MustCompile(`\s+`)
+)
+
+// normalizeFailureText reduces raw stderr to a template by masking the
+// variable parts (UUIDs, timestamps, quoted filenames, paths, digests, numbers).
+func normalizeFailureText(raw string) string {
+	text := strings.ToLower(strings.TrimSpace(raw))
+	if text == "" {
+		return ""
+	}
+	text = sigUUIDPattern.ReplaceAllString(text, "<uuid>")
+	text = sigTimestampPattern.ReplaceAllString(text, "<ts>")
+	maskQuoted := func(m string) string {
+		inner := m[1 : len(m)-1]
+		if strings.ContainsAny(inner, "./\\") {
+			return m[:1] + "<file>" + m[:1]
+		}
+		return m
+	}
+	text = sigDoubleQuoted.ReplaceAllStringFunc(text, maskQuoted)
+	text = sigSingleQuoted.ReplaceAllStringFunc(text, maskQuoted)
+	text = sigPathPattern.ReplaceAllStringFunc(text, func(m string) string {
+		// Keep the leading separator consumed by the pattern.
+		if m[0] != '/' && m[0] != '\\' {
+			return m[:1] + "<path>"
+		}
+		return "<path>"
+	})
+	text = sigHexPattern.ReplaceAllString(text, "<hex>")
+	text = sigNumberPattern.ReplaceAllString(text, "<n>")
+	text = strings.TrimSpace(sigSpacePattern.ReplaceAllString(text, " "))
+	if len(text) > failureSignatureMaxLen {
+		// Cut on a rune boundary so the template stays valid UTF-8.
+		cut := failureSignatureMaxLen
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut]
+	}
+	return text
+}
+
+// signatureTemplate is normalizeFailureText with a placeholder for empty stderr.
+func signatureTemplate(raw string) string {
+	if template := normalizeFailureText(raw); template != "" {
+		return template
+	}
+	return "task/job marked failed without stderr text"
+}
+
+// failureSignatureID is a short stable hash of a microservice group and template.
+func failureSignatureID(group, template string) string {
+	sum := sha1.Sum([]byte(group + "|" + template))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+type failureRow struct {
+	TransferUUID string
+	Group        string
+	FailedAt     *time.Time
+	ErrorText    string
+}
+
+type failureTemplate struct {
+	template string
+	tokens   map[string]struct{}
+	rows     []failureRow
+}
+
+type failureCluster struct {
+	group     string
+	seed      *failureTemplate
+	templates []*failureTemplate
+}
+
+// clusterFailureRows groups rows by normalized template and merges similar
+// templates within the same microservice group.
+func clusterFailureRows(rows []failureRow) []FailureSignature {
+	byGroup := map[string]map[string]*failureTemplate{}
+	for _, row := range rows {
+		template := signatureTemplate(row.ErrorText)
+		templates := byGroup[row.Group]
+		if templates == nil {
+			templates = map[string]*failureTemplate{}
+			byGroup[row.Group] = templates
+		}
+		t := templates[template]
+		if t == nil {
+			t = &failureTemplate{template: template, tokens: signatureTokens(template)}
+			templates[template] = t
+		}
+		t.rows = append(t.rows, row)
+	}
+
+	clusters := make([]*failureCluster, 0)
+	for group, templates := range byGroup {
+		ordered := make([]*failureTemplate, 0, len(templates))
+		for _, t := range templates {
+			ordered = append(ordered, t)
+		}
+		// Most frequent template seeds each cluster and names it; ties break on text.
+		sort.Slice(ordered, func(i, j int) bool {
+			if len(ordered[i].rows) != len(ordered[j].rows) {
+				return len(ordered[i].rows) > len(ordered[j].rows)
+			}
+			return ordered[i].template < ordered[j].template
+		})
+
+		groupClusters := make([]*failureCluster, 0)
+		for _, t := range ordered {
+			var best *failureCluster
+			bestScore := 0.0
+			for _, c := range groupClusters {
+				if score := jaccard(t.tokens, c.seed.tokens); score >= failureSignatureSimilarity && score > bestScore {
+					best, bestScore = c, score
+				}
+			}
+			if best == nil {
+				best = &failureCluster{group: group, seed: t}
+				groupClusters = append(groupClusters, best)
+			}
+			best.templates = append(best.templates, t)
+		}
+		clusters = append(clusters, groupClusters...)
+	}
+
+	out := make([]FailureSignature, 0, len(clusters))
+	for _, c := range clusters {
+		out = append(out, c.signature())
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Failures != out[j].Failures {
+			return out[i].Failures > out[j].Failures
+		}
+		if a, b := out[i].LastSeenAt, out[j].LastSeenAt; a != nil && b != nil && !a.Equal(*b) {
+			return a.After(*b)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// signature summarizes the cluster. Its ID comes from the lexicographically
+// smallest member template rather than the seed, so it does not change with the
+// template frequencies of the requested window.
+func (c *failureCluster) signature() FailureSignature {
+	idTemplate := c.seed.template
+	for _, t := range c.templates {
+		if t.template < idTemplate {
+			idTemplate = t.template
+		}
+	}
+	item := FailureSignature{
+		ID:                failureSignatureID(c.group, idTemplate),
+		Signature:         c.seed.template,
+		MicroserviceGroup: c.group,
+		Variants:          len(c.templates),
+		MemberIDs:         make([]string, 0, len(c.templates)),
+		Examples:          make([]FailureSignatureExample, 0, failureSignatureExamples),
+		AffectedTransfers: make([]string, 0),
+	}
+
+	all := make([]failureRow, 0)
+	for _, t := range c.templates {
+		all = append(all, t.rows...)
+		item.MemberIDs = append(item.MemberIDs, failureSignatureID(c.group, t.template))
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		a, b := all[i].FailedAt, all[j].FailedAt
+		if a == nil || b == nil {
+			return a != nil
+		}
+		return a.After(*b)
+	})
+
+	transfers := map[string]struct{}{}
+	seenText := map[string]struct{}{}
+	for _, row := range all {
+		item.Failures++
+		if row.FailedAt != nil {
+			if item.LastSeenAt == nil || row.FailedAt.After(*item.LastSeenAt) {
+				item.LastSeenAt = row.FailedAt
+			}
+			if item.FirstSeenAt == nil || row.FailedAt.Before(*item.FirstSeenAt) {
+				item.FirstSeenAt = row.FailedAt
+			}
+		}
+		if _, ok := transfers[row.TransferUUID]; !ok && row.TransferUUID != "" {
+			transfers[row.TransferUUID] = struct{}{}
+			if len(item.AffectedTransfers) < failureSignatureTransfers {
+				item.AffectedTransfers = append(item.AffectedTransfers, row.TransferUUID)
+			}
+		}
+		text := compactError(row.ErrorText)
+		if _, ok := seenText[text]; !ok && len(item.Examples) < failureSignatureExamples {
+			seenText[text] = struct{}{}
+			item.Examples = append(item.Examples, FailureSignatureExample{
+				TransferUUID: row.TransferUUID,
+				ErrorText:    text,
+				FailedAt:     row.FailedAt,
+			})
+		}
+	}
+	item.DistinctTransfers = int64(len(transfers))
+	return item
+}
+
+func signatureTokens(template string) map[string]struct{} {
+	out := map[string]struct{}{}
+	for _, tok := range strings.Fields(template) {
+		out[tok] = struct{}{}
+	}
+	return out
+}
+
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	inter := 0
+	for k := range a {
+		if _, ok := b[k]; ok {
+			inter++
+		}
+	}
+	union := len(a) + len(b) - inter
+	if union == 0 {
+		return 0
+	}
+	return float64(inter) / float64(union)
+}
+
+// ListFailureSignatures returns recurring transfer failures clustered by normalized stderr.
+func (s *Store) ListFailureSignatures(ctx context.Context, since time.Time, limit int) ([]FailureSignature, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	raw, err := s.failureRows(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	items := clusterFailureRows(raw)
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	if kb := s.loadKnowledgeIndex(ctx); kb != nil {
+		for i := range items {
+			text := items[i].Signature
+			if len(items[i].Examples) > 0 {
+				text = items[i].Examples[0].ErrorText
+			}
+			items[i].Knowledge = kb.match(items[i].MicroserviceGroup, text, append([]string{items[i].ID}, items[i].MemberIDs...)...)
+		}
+	}
+	return items, nil
+}
+
+// failureRows loads the most recent failed transfer task rows since since, at most
+// failureSignatureMaxRows.
+func (s *Store) failureRows(ctx context.Context, since time.Time) ([]failureRow, error) {
+	const q = `
+SELECT
+  j.SIPUUID,
+  COALESCE(NULLIF(j.microserviceGroup, ''), 'UNKNOWN') AS microservice_group,
+  COALESCE(tsk.endTime, tsk.startTime, j.createdTime) AS fail_time,
+  LEFT(COALESCE(NULLIF(tsk.stdError, ''), CONCAT('job failed: ', COALESCE(j.jobType, 'unknown'))), 2000) AS error_text
+FROM Jobs j
+LEFT JOIN Tasks tsk
+  ON tsk.jobuuid = j.jobUUID
+WHERE j.unitType LIKE '%Transfer'
+  AND COALESCE(tsk.endTime, tsk.startTime, j.createdTime) >= ?
+  AND COALESCE(tsk.exitCode, 0) <> 0
+  AND j.currentStep = 4
+ORDER BY fail_time DESC
+LIMIT ?;
+`
+
+	rows, err := s.db.QueryContext(ctx, q, since, failureSignatureMaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	raw := make([]failureRow, 0)
+	for rows.Next() {
+		var (
+			row      failureRow
+			failedAt sql.NullTime
+		)
+		if err := rows.Scan(&row.TransferUUID, &row.Group, &failedAt, &row.ErrorText); err != nil {
+			return nil, err
+		}
+		row.FailedAt = nullTimePtr(failedAt)
+		raw = append(raw, row)
+	}
+	return raw, rows.Err()
+}
+
+// failureClusterIDs maps the template-level signature ID of every clustered
+// template to the ID of its cluster.
+func failureClusterIDs(items []FailureSignature) map[string]string {
+	out := map[string]string{}
+	for _, it := range items {
+		for _, id := range it.MemberIDs {
+			out[id] = it.ID
+		}
+	}
+	return out
+}
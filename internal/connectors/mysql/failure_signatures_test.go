@@ -0,0 +1,70 @@
+package mysql
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestNormalizeFailureText_MasksVariableParts(t *testing.T) {
+	a := normalizeFailureText(`Error: file "/var/archivematica/sharedDirectory/currentlyProcessing/a-3f2b1c4d-aaaa-bbbb-cccc-1234567890ab/objects/img 01.tif" failed after 1234 bytes at 2026-02-03 10:11:12 (md5 d41d8cd98f00b204e9800998ecf8427e)`)
+	b := normalizeFailureText(`Error: file "/var/archivematica/sharedDirectory/currentlyProcessing/b-00000000-1111-2222-3333-444444444444/objects/scan.jpg" failed after 99 bytes at 2026-03-09 23:59:01 (md5 0123456789abcdef0123456789abcdef)`)
+	if a != b {
+		t.Fatalf("expected identical templates\n a=%q\n b=%q", a, b)
+	}
+	want := `error: file "<file>" failed after <n> bytes at <ts> (md5 <hex>)`
+	if a != want {
+		t.Fatalf("unexpected template %q, want %q", a, want)
+	}
+}
+
+func TestNormalizeFailureText_MasksBarePaths(t *testing.T) {
+	got := normalizeFailureText("No such file or directory: /tmp/abc/def.txt")
+	if got != "no such file or directory: <path>" {
+		t.Fatalf("unexpected template %q", got)
+	}
+}
+
+func TestNormalizeFailureText_TruncatesOnRuneBoundary(t *testing.T) {
+	got := normalizeFailureText(strings.Repeat("x", failureSignatureMaxLen-1) + "ééé")
+	if !utf8.ValidString(got) || got != strings.Repeat("x", failureSignatureMaxLen-1) {
+		t.Fatalf("unexpected truncated template %q", got)
+	}
+}
+
+func TestClusterFailureRows_MergesSimilarTemplates(t *testing.T) {
+	now := time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-2 * time.Hour)
+	rows := []failureRow{
+		{TransferUUID: "t1", Group: "Characterize and extract metadata", FailedAt: &now, ErrorText: "fits timed out after 300 seconds while processing file /a/b.tif with tool fits version 1.5"},
+		{TransferUUID: "t2", Group: "Characterize and extract metadata", FailedAt: &earlier, ErrorText: "fits timed out after 120 seconds while processing file /c/d.pdf with tool fits version 1.6 retry"},
+		{TransferUUID: "t3", Group: "Normalize", FailedAt: &now, ErrorText: "ffmpeg returned 1"},
+	}
+
+	items := clusterFailureRows(rows)
+	if len(items) != 2 {
+		t.Fatalf("expected 2 signatures, got %d: %+v", len(items), items)
+	}
+	top := items[0]
+	if top.Failures != 2 || top.DistinctTransfers != 2 || top.Variants != 2 {
+		t.Fatalf("unexpected merged signature: %+v", top)
+	}
+	if top.FirstSeenAt == nil || !top.FirstSeenAt.Equal(earlier) || top.LastSeenAt == nil || !top.LastSeenAt.Equal(now) {
+		t.Fatalf("unexpected first/last seen: %v %v", top.FirstSeenAt, top.LastSeenAt)
+	}
+	if top.ID == "" || top.ID != clusterFailureRows(rows)[0].ID {
+		t.Fatalf("expected stable signature id, got %q", top.ID)
+	}
+
+	// A window in which the other variant is more frequent names the cluster
+	// differently but keeps its ID.
+	shifted := append(append([]failureRow{}, rows...), failureRow{TransferUUID: "t4", Group: rows[1].Group, FailedAt: &earlier, ErrorText: rows[1].ErrorText})
+	again := clusterFailureRows(shifted)[0]
+	if again.Signature == top.Signature || again.ID != top.ID {
+		t.Fatalf("expected the id to survive a seed change: %q/%q vs %q/%q", top.Signature, top.ID, again.Signature, again.ID)
+	}
+	if !containsKey(top.MemberIDs, top.ID) {
+		t.Fatalf("expected the id to be one of the member ids %v", top.MemberIDs)
+	}
+}
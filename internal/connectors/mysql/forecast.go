@@ -0,0 +1,504 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"go-am-realtime-report-ui/internal/dbctx"
+)
+
+const (
+	forecastSmallMaxBytes  = int64(1) << 30  // 1 GiB
+	forecastMediumMaxBytes = int64(50) << 30 // 50 GiB
+
+	// forecastBandZ is the z-score of the 10th/90th percentile of a normal distribution.
+	forecastBandZ = 1.2816
+
+	forecastUnmappedCustomer = "unmapped"
+)
+
+var forecastSizeClasses = []string{"small", "medium", "large"}
+
+// BacklogForecastOptions controls the history window and projection horizon.
+type BacklogForecastOptions struct {
+	Now         time.Time
+	HistoryDays int
+	HorizonDays int
+	CustomerID  string
+}
+
+// ForecastBand is a projected value with a 10th-90th percentile confidence band.
+type ForecastBand struct {
+	P10 float64 `json:"p10"`
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+}
+
+// BacklogProjection describes arrival/throughput rates and drain estimates for one slice of the backlog.
+// Drain days are nil when the backlog does not shrink at that rate.
+type BacklogProjection struct {
+	Backlog              int64        `json:"backlog"`
+	Arrivals             int64        `json:"arrivals"`
+	Completed            int64        `json:"completed"`
+	ArrivalsPerDay       float64      `json:"arrivals_per_day"`
+	ArrivalsStdDev       float64      `json:"arrivals_stddev"`
+	ThroughputPerDay     float64      `json:"throughput_per_day"`
+	ThroughputStdDev     float64      `json:"throughput_stddev"`
+	NetDrainPerDay       float64      `json:"net_drain_per_day"`
+	DrainDays            *float64     `json:"drain_days"`
+	DrainDaysOptimistic  *float64     `json:"drain_days_optimistic"`
+	DrainDaysPessimistic *float64     `json:"drain_days_pessimistic"`
+	ExpectedDrainDate    string       `json:"expected_drain_date,omitempty"`
+	ProjectedBacklog     ForecastBand `json:"projected_backlog"`
+}
+
+// SizeClassForecast is the projection for transfers of one size class.
+type SizeClassForecast struct {
+	SizeClass string `json:"size_class"`
+	BacklogProjection
+}
+
+// CustomerForecast is the projection for transfers of one customer.
+type CustomerForecast struct {
+	CustomerID string `json:"customer_id"`
+	BacklogProjection
+}
+
+// ForecastHistoryPoint is one observed day of arrivals and completions.
+type ForecastHistoryPoint struct {
+	Date      string `json:"date"`
+	Arrivals  int64  `json:"arrivals"`
+	Completed int64  `json:"completed"`
+}
+
+// ForecastPoint is one projected day of backlog size.
+type ForecastPoint struct {
+	Date    string       `json:"date"`
+	Backlog ForecastBand `json:"backlog"`
+}
+
+// BacklogForecast projects backlog size and drain time from historic arrival and throughput rates.
+type BacklogForecast struct {
+	HistoryFrom string `json:"history_from"`
+	HistoryTo   string `json:"history_to"`
+	HistoryDays int    `json:"history_days"`
+	HorizonDays int    `json:"horizon_days"`
+	BacklogProjection
+	History     []ForecastHistoryPoint `json:"history"`
+	Projection  []ForecastPoint        `json:"projection"`
+	SizeClasses []SizeClassForecast    `json:"size_classes"`
+	Customers   []CustomerForecast     `json:"customers"`
+}
+
+// forecastTransfer is the per-transfer input to computeBacklogForecast. UUID,
+// Location, Accession and StartedAt are only used for customer attribution.
+type forecastTransfer struct {
+	UUID        string
+	Location    string
+	Source      string
+	Accession   string
+	Status      int64
+	Bytes       int64
+	ArrivedAt   *time.Time
+	CompletedAt *time.Time
+	StartedAt   *time.Time
+}
+
+func (t forecastTransfer) mappingTransfer() mappingRuleTransfer {
+	return mappingRuleTransfer{UUID: t.UUID, Location: t.Location, Source: t.Source, Accession: t.Accession, CompletedAt: t.CompletedAt, StartedAt: t.StartedAt}
+}
+
+// GetBacklogForecast projects the processing backlog for the next HorizonDays days
+// using the arrival and completion rates of the previous HistoryDays full days.
+// Transfers in status 1 count as backlog; statuses 2-4 count as drained.
+func (s *Store) GetBacklogForecast(ctx context.Context, opts BacklogForecastOptions) (*BacklogForecast, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	if opts.Now.IsZero() {
+		opts.Now = time.Now().UTC()
+	}
+	historyTo := time.Date(opts.Now.Year(), opts.Now.Month(), opts.Now.Day(), 0, 0, 0, 0, time.UTC)
+	historyFrom := historyTo.AddDate(0, 0, -opts.HistoryDays)
+
+	filterClause, filterArgs, err := s.sourceFilterClause(ctx, opts.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `
+SELECT
+  t.transferUUID,
+  COALESCE(t.currentLocation, ''),
+  COALESCE(t.sourceOfAcquisition, '') AS source,
+  COALESCE(t.accessionID, ''),
+  t.status,
+  COALESCE(fs.total_bytes, 0) AS total_bytes,
+  COALESCE(ja.arrived_at, fs.first_seen_at) AS arrived_at,
+  t.completed_at,
+  ` + transferFirstJobExpr + `
+FROM Transfers t
+LEFT JOIN (
+  SELECT
+    j.SIPUUID,
+    MIN(j.createdTime) AS arrived_at
+  FROM Jobs j
+  WHERE j.unitType LIKE '%Transfer'
+  GROUP BY j.SIPUUID
+) ja
+  ON ja.SIPUUID = t.transferUUID
+LEFT JOIN (
+  SELECT
+    f.transferUUID,
+    MIN(f.enteredSystem) AS first_seen_at,
+    SUM(COALESCE(f.fileSize, 0)) AS total_bytes
+  FROM Files f
+  WHERE f.transferUUID IS NOT NULL
+    AND LOWER(COALESCE(f.fileGrpUse, '')) = 'original'
+  GROUP BY f.transferUUID
+) fs
+  ON fs.transferUUID = t.transferUUID
+WHERE (
+    t.status = 1
+    OR t.completed_at >= ?
+    OR COALESCE(ja.arrived_at, fs.first_seen_at) >= ?
+  )
+  ` + filterClause + `;
+`
+	args := []any{historyFrom, historyFrom}
+	args = append(args, filterArgs...)
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := make([]forecastTransfer, 0)
+	for rows.Next() {
+		var (
+			item        forecastTransfer
+			status      sql.NullInt64
+			arrivedAt   sql.NullTime
+			completedAt sql.NullTime
+			firstJob    sql.NullTime
+		)
+		if err := rows.Scan(&item.UUID, &item.Location, &item.Source, &item.Accession, &status, &item.Bytes, &arrivedAt, &completedAt, &firstJob); err != nil {
+			return nil, err
+		}
+		item.Status = nullInt64Value(status)
+		item.ArrivedAt = nullTimePtr(arrivedAt)
+		item.CompletedAt = nullTimePtr(completedAt)
+		item.StartedAt = transferStart(firstJob, completedAt)
+		transfers = append(transfers, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var attribution *customerAttribution
+	if filterClause != "" {
+		// Everything already belongs to the requested customer.
+		attribution = &customerAttribution{bySource: map[string][]customerSourceOwner{}}
+		for _, tr := range transfers {
+			source := strings.TrimSpace(tr.Source)
+			attribution.bySource[source] = []customerSourceOwner{{CustomerID: strings.TrimSpace(opts.CustomerID)}}
+		}
+	} else if attribution, err = s.customerAttribution(ctx); err != nil {
+		return nil, err
+	}
+
+	return computeBacklogForecast(transfers, attribution, historyFrom, historyTo, opts.HorizonDays), nil
+}
+
+// customerSourceIndex maps sourceOfAcquisition values to every customer they were
+// ever mapped to, ignoring validity windows and mapping rules; breakdowns that need
+// per-transfer attribution use customerAttribution. A nil map means no mapping
+// backend is configured and sources are used as customer IDs directly, matching
+// sourceFilterClause.
+func (s *Store) customerSourceIndex(ctx context.Context) (map[string][]string, error) {
+	if s.customerMap == nil && !s.hasCustomerSourceMapping {
+		return nil, nil
+	}
+
+	index := map[string][]string{}
+	if s.customerMap != nil {
+		items, err := s.customerMap.ListAllMappings(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, it := range items {
+			index[it.SourceOfAcquisition] = append(index[it.SourceOfAcquisition], it.CustomerID)
+		}
+		return index, nil
+	}
+
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, `
+SELECT cts.customer_id, cts.source_of_acquisition
+FROM CustomerTransferSources cts
+ORDER BY cts.customer_id, cts.source_of_acquisition;
+`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var customerID, source sql.NullString
+		if err := rows.Scan(&customerID, &source); err != nil {
+			return nil, err
+		}
+		if src := strings.TrimSpace(source.String); source.Valid && src != "" {
+			index[src] = append(index[src], customerID.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// computeBacklogForecast buckets arrivals and completions per day over [from, to) and
+// projects the current backlog forward. The daily net flow is treated as independent
+// draws, so the confidence band widens with the square root of the horizon.
+// Each transfer counts toward the customers it is attributed to at its start;
+// attribution may be nil, see customerAttribution.
+func computeBacklogForecast(transfers []forecastTransfer, attribution *customerAttribution, from, to time.Time, horizonDays int) *BacklogForecast {
+	days := int(to.Sub(from).Hours() / 24)
+	if days < 1 {
+		days = 1
+	}
+
+	total := newForecastAccumulator(days)
+	classes := map[string]*forecastAccumulator{}
+	for _, c := range forecastSizeClasses {
+		classes[c] = newForecastAccumulator(days)
+	}
+	byCustomer := map[string]*forecastAccumulator{}
+
+	for _, tr := range transfers {
+		accs := []*forecastAccumulator{total, classes[forecastSizeClass(tr.Bytes)]}
+		for _, customerID := range attribution.customersFor(tr.mappingTransfer()) {
+			acc, ok := byCustomer[customerID]
+			if !ok {
+				acc = newForecastAccumulator(days)
+				byCustomer[customerID] = acc
+			}
+			accs = append(accs, acc)
+		}
+		for _, acc := range accs {
+			acc.add(tr, from, to)
+		}
+	}
+
+	out := &BacklogForecast{
+		HistoryFrom:       from.Format("2006-01-02"),
+		HistoryTo:         to.Format("2006-01-02"),
+		HistoryDays:       days,
+		HorizonDays:       horizonDays,
+		BacklogProjection: total.projection(to, horizonDays),
+		History:           make([]ForecastHistoryPoint, 0, days),
+		Projection:        make([]ForecastPoint, 0, horizonDays),
+		SizeClasses:       make([]SizeClassForecast, 0, len(forecastSizeClasses)),
+		Customers:         make([]CustomerForecast, 0, len(byCustomer)),
+	}
+	for i := 0; i < days; i++ {
+		out.History = append(out.History, ForecastHistoryPoint{
+			Date:      from.AddDate(0, 0, i).Format("2006-01-02"),
+			Arrivals:  total.arrivals[i],
+			Completed: total.completed[i],
+		})
+	}
+	for k := 1; k <= horizonDays; k++ {
+		out.Projection = append(out.Projection, ForecastPoint{
+			Date:    to.AddDate(0, 0, k-1).Format("2006-01-02"),
+			Backlog: total.band(k),
+		})
+	}
+	for _, c := range forecastSizeClasses {
+		out.SizeClasses = append(out.SizeClasses, SizeClassForecast{
+			SizeClass:         c,
+			BacklogProjection: classes[c].projection(to, horizonDays),
+		})
+	}
+	for customerID, acc := range byCustomer {
+		out.Customers = append(out.Customers, CustomerForecast{
+			CustomerID:        customerID,
+			BacklogProjection: acc.projection(to, horizonDays),
+		})
+	}
+	sort.Slice(out.Customers, func(i, j int) bool {
+		if out.Customers[i].Backlog != out.Customers[j].Backlog {
+			return out.Customers[i].Backlog > out.Customers[j].Backlog
+		}
+		return out.Customers[i].CustomerID < out.Customers[j].CustomerID
+	})
+	return out
+}
+
+func forecastSizeClass(bytes int64) string {
+	switch {
+	case bytes < forecastSmallMaxBytes:
+		return "small"
+	case bytes < forecastMediumMaxBytes:
+		return "medium"
+	default:
+		return "large"
+	}
+}
+
+func forecastCustomersFor(source string, customers map[string][]string) []string {
+	source = strings.TrimSpace(source)
+	if customers == nil {
+		if source == "" {
+			return []string{forecastUnmappedCustomer}
+		}
+		return []string{source}
+	}
+	if ids := customers[source]; len(ids) > 0 {
+		return ids
+	}
+	return []string{forecastUnmappedCustomer}
+}
+
+type forecastAccumulator struct {
+	backlog   int64
+	arrivals  []int64
+	completed []int64
+	net       []float64
+
+	arrivalMean, arrivalStd       float64
+	throughputMean, throughputStd float64
+	netMean, netStd               float64
+	computed                      bool
+}
+
+func newForecastAccumulator(days int) *forecastAccumulator {
+	return &forecastAccumulator{
+		arrivals:  make([]int64, days),
+		completed: make([]int64, days),
+	}
+}
+
+func (a *forecastAccumulator) add(tr forecastTransfer, from, to time.Time) {
+	if tr.Status == 1 {
+		a.backlog++
+	}
+	if i, ok := forecastDayIndex(tr.ArrivedAt, from, to); ok {
+		a.arrivals[i]++
+	}
+	if tr.Status >= 2 && tr.Status <= 4 {
+		if i, ok := forecastDayIndex(tr.CompletedAt, from, to); ok {
+			a.completed[i]++
+		}
+	}
+}
+
+func forecastDayIndex(t *time.Time, from, to time.Time) (int, bool) {
+	if t == nil || t.Before(from) || !t.Before(to) {
+		return 0, false
+	}
+	return int(t.Sub(from).Hours() / 24), true
+}
+
+func (a *forecastAccumulator) compute() {
+	if a.computed {
+		return
+	}
+	a.computed = true
+	arrivals := make([]float64, len(a.arrivals))
+	completed := make([]float64, len(a.completed))
+	a.net = make([]float64, len(a.arrivals))
+	for i := range a.arrivals {
+		arrivals[i] = float64(a.arrivals[i])
+		completed[i] = float64(a.completed[i])
+		a.net[i] = completed[i] - arrivals[i]
+	}
+	a.arrivalMean, a.arrivalStd = meanStdDev(arrivals)
+	a.throughputMean, a.throughputStd = meanStdDev(completed)
+	a.netMean, a.netStd = meanStdDev(a.net)
+}
+
+// band returns the projected backlog after k days. P10 is the optimistic (smallest) backlog.
+func (a *forecastAccumulator) band(k int) ForecastBand {
+	a.compute()
+	center := float64(a.backlog) - a.netMean*float64(k)
+	spread := forecastBandZ * a.netStd * math.Sqrt(float64(k))
+	return ForecastBand{
+		P10: round2(math.Max(0, center-spread)),
+		P50: round2(math.Max(0, center)),
+		P90: round2(math.Max(0, center+spread)),
+	}
+}
+
+func (a *forecastAccumulator) projection(to time.Time, horizonDays int) BacklogProjection {
+	a.compute()
+	out := BacklogProjection{
+		Backlog:          a.backlog,
+		Arrivals:         sumInt64(a.arrivals),
+		Completed:        sumInt64(a.completed),
+		ArrivalsPerDay:   round2(a.arrivalMean),
+		ArrivalsStdDev:   round2(a.arrivalStd),
+		ThroughputPerDay: round2(a.throughputMean),
+		ThroughputStdDev: round2(a.throughputStd),
+		NetDrainPerDay:   round2(a.netMean),
+		ProjectedBacklog: a.band(horizonDays),
+	}
+
+	// The drain-rate band uses the standard error of the mean daily net flow.
+	stdErr := 0.0
+	if n := len(a.net); n > 0 {
+		stdErr = a.netStd / math.Sqrt(float64(n))
+	}
+	out.DrainDays = drainDays(a.backlog, a.netMean)
+	out.DrainDaysOptimistic = drainDays(a.backlog, a.netMean+forecastBandZ*stdErr)
+	out.DrainDaysPessimistic = drainDays(a.backlog, a.netMean-forecastBandZ*stdErr)
+	if out.DrainDays != nil {
+		out.ExpectedDrainDate = to.Add(time.Duration(*out.DrainDays * float64(24*time.Hour))).Format("2006-01-02")
+	}
+	return out
+}
+
+func drainDays(backlog int64, netPerDay float64) *float64 {
+	if backlog == 0 {
+		v := 0.0
+		return &v
+	}
+	if netPerDay <= 0 {
+		return nil
+	}
+	v := round2(float64(backlog) / netPerDay)
+	return &v
+}
+
+func meanStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	sq := 0.0
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(values)-1))
+}
+
+func sumInt64(values []int64) int64 {
+	total := int64(0)
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,155 @@
+package mysql
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+)
+
+func forecastTime(t time.Time) *time.Time {
+	return &t
+}
+
+func TestComputeBacklogForecast_DrainsAtNetRate(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 4)
+
+	transfers := make([]forecastTransfer, 0)
+	// One arrival and three completions per day: net drain of 2/day.
+	for d := 0; d < 4; d++ {
+		day := from.AddDate(0, 0, d).Add(6 * time.Hour)
+		transfers = append(transfers, forecastTransfer{Source: "acme-ftp", Status: 1, ArrivedAt: forecastTime(day)})
+		for i := 0; i < 3; i++ {
+			transfers = append(transfers, forecastTransfer{
+				Source:      "acme-ftp",
+				Status:      2,
+				Bytes:       2 << 30,
+				ArrivedAt:   forecastTime(from.AddDate(0, 0, -10)),
+				CompletedAt: forecastTime(day),
+			})
+		}
+	}
+	attribution := testAttribution(map[string][]string{"acme-ftp": {"acme"}}, nil)
+
+	got := computeBacklogForecast(transfers, attribution, from, to, 3)
+
+	if got.Backlog != 4 || got.ArrivalsPerDay != 1 || got.ThroughputPerDay != 3 || got.NetDrainPerDay != 2 {
+		t.Fatalf("unexpected rates: %+v", got.BacklogProjection)
+	}
+	if got.DrainDays == nil || *got.DrainDays != 2 {
+		t.Fatalf("expected drain in 2 days, got %v", got.DrainDays)
+	}
+	if got.ExpectedDrainDate != "2026-03-07" {
+		t.Fatalf("unexpected drain date %q", got.ExpectedDrainDate)
+	}
+	if len(got.History) != 4 || len(got.Projection) != 3 {
+		t.Fatalf("unexpected series lengths: history=%d projection=%d", len(got.History), len(got.Projection))
+	}
+	if p := got.Projection[0].Backlog; p.P50 != 2 || p.P10 != 2 || p.P90 != 2 {
+		t.Fatalf("zero-variance projection should have a flat band, got %+v", p)
+	}
+	if p := got.Projection[2].Backlog; p.P50 != 0 {
+		t.Fatalf("projection must not go negative, got %+v", p)
+	}
+
+	var small, medium SizeClassForecast
+	for _, c := range got.SizeClasses {
+		switch c.SizeClass {
+		case "small":
+			small = c
+		case "medium":
+			medium = c
+		}
+	}
+	if small.Backlog != 4 || small.Completed != 0 || small.DrainDays != nil {
+		t.Fatalf("unexpected small class: %+v", small)
+	}
+	if medium.Completed != 12 || medium.Backlog != 0 {
+		t.Fatalf("unexpected medium class: %+v", medium)
+	}
+
+	if len(got.Customers) != 1 || got.Customers[0].CustomerID != "acme" || got.Customers[0].Backlog != 4 {
+		t.Fatalf("unexpected customer breakdown: %+v", got.Customers)
+	}
+}
+
+func TestComputeBacklogForecast_AttributesCustomersAtTransferStart(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 2)
+	switchover := from.Add(12 * time.Hour)
+	before, after := from.Add(time.Hour), from.Add(24*time.Hour)
+	rules, err := compileMappingRules([]CustomerMappingRule{{ID: 1, CustomerID: "initech", Field: "accession_id", MatchType: "prefix", Pattern: "INI-", Enabled: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attribution := &customerAttribution{bySource: map[string][]customerSourceOwner{
+		"shared-ftp": {
+			{CustomerID: "acme", Mapping: CustomerSourceMapping{SourceOfAcquisition: "shared-ftp", EffectiveTo: &switchover}},
+			{CustomerID: "globex", Mapping: CustomerSourceMapping{SourceOfAcquisition: "shared-ftp", EffectiveFrom: &switchover}},
+		},
+	}, rules: rules}
+	transfers := []forecastTransfer{
+		{Source: "shared-ftp", Status: 1, ArrivedAt: &before, StartedAt: &before},
+		{Source: "shared-ftp", Status: 1, ArrivedAt: &after, StartedAt: &after},
+		{Source: "scanner", Accession: "INI-1", Status: 1, ArrivedAt: &after, StartedAt: &after},
+	}
+
+	got := computeBacklogForecast(transfers, attribution, from, to, 2)
+
+	backlog := map[string]int64{}
+	for _, c := range got.Customers {
+		backlog[c.CustomerID] = c.Backlog
+	}
+	if len(backlog) != 3 || backlog["acme"] != 1 || backlog["globex"] != 1 || backlog["initech"] != 1 {
+		t.Fatalf("expected each transfer counted once for its owner at start, got %v", backlog)
+	}
+}
+
+func TestComputeBacklogForecast_GrowingBacklogNeverDrains(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 2)
+	transfers := []forecastTransfer{
+		{Source: "", Status: 1, ArrivedAt: forecastTime(from.Add(time.Hour))},
+		{Source: "", Status: 1, ArrivedAt: forecastTime(from.Add(25 * time.Hour))},
+		{Source: "", Status: 1, ArrivedAt: forecastTime(from.Add(26 * time.Hour))},
+	}
+
+	got := computeBacklogForecast(transfers, nil, from, to, 2)
+
+	if got.DrainDays != nil || got.DrainDaysPessimistic != nil {
+		t.Fatalf("expected no drain estimate, got %+v", got.BacklogProjection)
+	}
+	if got.ProjectedBacklog.P90 < got.ProjectedBacklog.P50 || got.ProjectedBacklog.P50 < got.ProjectedBacklog.P10 {
+		t.Fatalf("band out of order: %+v", got.ProjectedBacklog)
+	}
+	if len(got.Customers) != 1 || got.Customers[0].CustomerID != forecastUnmappedCustomer {
+		t.Fatalf("expected unmapped customer bucket, got %+v", got.Customers)
+	}
+}
+
+func TestCustomerSourceIndex_LoadsAllMappings(t *testing.T) {
+	cm, err := customermap.NewSQLiteStore(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+	s := &Store{customerMap: cm, queryTimeout: time.Second}
+	ctx := context.Background()
+
+	for _, m := range [][2]string{{"globex", "shared"}, {"acme", "acme-ftp"}, {"acme", "shared"}} {
+		if err := cm.CreateMapping(ctx, m[0], m[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	index, err := s.customerSourceIndex(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != 2 || strings.Join(index["shared"], ",") != "acme,globex" || strings.Join(index["acme-ftp"], ",") != "acme" {
+		t.Fatalf("unexpected index %v", index)
+	}
+}
@@ -0,0 +1,227 @@
+package mysql
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"go-am-realtime-report-ui/internal/dbctx"
+)
+
+const formatAnalyticsTopCommands = 5
+
+// FormatOutcome summarizes identification and normalization outcomes for one PRONOM ID.
+type FormatOutcome struct {
+	PronomID                 string           `json:"pronom_id"`
+	FormatName               string           `json:"format_name"`
+	FilesSeen                int64            `json:"files_seen"`
+	IdentificationFailures   int64            `json:"identification_failures"`
+	NormalizationAttempts    int64            `json:"normalization_attempts"`
+	NormalizationSuccesses   int64            `json:"normalization_successes"`
+	NormalizationFailures    int64            `json:"normalization_failures"`
+	NormalizedFiles          int64            `json:"normalized_files"`
+	NormalizationFailureRate float64          `json:"normalization_failure_rate"`
+	AvgToolRuntimeSeconds    float64          `json:"avg_tool_runtime_seconds"`
+	TopFailingCommands       []FailingCommand `json:"top_failing_commands"`
+}
+
+// FailingCommand is a task command that failed for files of a given format.
+type FailingCommand struct {
+	JobType   string `json:"job_type"`
+	Execution string `json:"execution"`
+	Failures  int64  `json:"failures"`
+}
+
+// FormatAnalyticsOptions scopes the format report by file ingest date and customer.
+type FormatAnalyticsOptions struct {
+	DateFrom   time.Time
+	DateTo     time.Time
+	CustomerID string
+	Limit      int
+}
+
+// GetFormatAnalytics returns per-PRONOM file, identification and normalization outcomes.
+// Files without an identification row are grouped under an empty PRONOM ID.
+func (s *Store) GetFormatAnalytics(ctx context.Context, opts FormatAnalyticsOptions) ([]FormatOutcome, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	filterClause, filterArgs, err := s.sourceFilterClause(ctx, opts.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `
+SELECT
+  COALESCE(fv.pronom_id, '') AS pronom_id,
+  COALESCE(MAX(fv.description), '') AS format_name,
+  COUNT(DISTINCT f.fileUUID) AS files_seen,
+  SUM(CASE WHEN fii.fileUUID IS NULL OR COALESCE(ts.ident_failures, 0) > 0 THEN 1 ELSE 0 END) AS identification_failures,
+  COALESCE(SUM(ts.norm_attempts), 0) AS normalization_attempts,
+  COALESCE(SUM(ts.norm_successes), 0) AS normalization_successes,
+  COALESCE(SUM(ts.norm_failures), 0) AS normalization_failures,
+  SUM(CASE WHEN EXISTS (SELECT 1 FROM Derivations d WHERE d.sourceFileUUID = f.fileUUID) THEN 1 ELSE 0 END) AS normalized_files,
+  COALESCE(SUM(ts.runtime_seconds), 0) AS runtime_seconds,
+  COALESCE(SUM(ts.runtime_tasks), 0) AS runtime_tasks
+FROM Files f
+JOIN Transfers t
+  ON t.transferUUID = f.transferUUID
+LEFT JOIN FilesIdentifiedIDs fii
+  ON fii.fileUUID = f.fileUUID
+LEFT JOIN fpr_formatversion fv
+  ON fv.uuid = fii.fileID
+LEFT JOIN (
+  SELECT
+    tsk.fileUUID,
+    SUM(CASE WHEN j.microserviceGroup LIKE 'Identify file format%' AND COALESCE(tsk.exitCode, 0) <> 0 THEN 1 ELSE 0 END) AS ident_failures,
+    SUM(CASE WHEN j.microserviceGroup LIKE 'Normalize%' THEN 1 ELSE 0 END) AS norm_attempts,
+    SUM(CASE WHEN j.microserviceGroup LIKE 'Normalize%' AND COALESCE(tsk.exitCode, 0) = 0 THEN 1 ELSE 0 END) AS norm_successes,
+    SUM(CASE WHEN j.microserviceGroup LIKE 'Normalize%' AND COALESCE(tsk.exitCode, 0) <> 0 THEN 1 ELSE 0 END) AS norm_failures,
+    SUM(CASE WHEN tsk.startTime IS NOT NULL AND tsk.endTime IS NOT NULL THEN TIMESTAMPDIFF(SECOND, tsk.startTime, tsk.endTime) ELSE 0 END) AS runtime_seconds,
+    SUM(CASE WHEN tsk.startTime IS NOT NULL AND tsk.endTime IS NOT NULL THEN 1 ELSE 0 END) AS runtime_tasks
+  FROM Tasks tsk
+  JOIN Jobs j
+    ON j.jobUUID = tsk.jobuuid
+  WHERE tsk.fileUUID IS NOT NULL
+    AND tsk.createdTime >= ?
+  GROUP BY tsk.fileUUID
+) ts
+  ON ts.fileUUID = f.fileUUID
+WHERE f.enteredSystem >= ?
+  AND f.enteredSystem < ?
+  AND LOWER(COALESCE(f.fileGrpUse, '')) = 'original'
+  ` + filterClause + `
+GROUP BY COALESCE(fv.pronom_id, '')
+ORDER BY files_seen DESC, pronom_id ASC
+LIMIT ?;
+`
+	args := []any{opts.DateFrom, opts.DateFrom, opts.DateTo}
+	args = append(args, filterArgs...)
+	args = append(args, opts.Limit)
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]FormatOutcome, 0)
+	index := map[string]int{}
+	for rows.Next() {
+		var (
+			item           FormatOutcome
+			runtimeSeconds float64
+			runtimeTasks   int64
+		)
+		if err := rows.Scan(
+			&item.PronomID,
+			&item.FormatName,
+			&item.FilesSeen,
+			&item.IdentificationFailures,
+			&item.NormalizationAttempts,
+			&item.NormalizationSuccesses,
+			&item.NormalizationFailures,
+			&item.NormalizedFiles,
+			&runtimeSeconds,
+			&runtimeTasks,
+		); err != nil {
+			return nil, err
+		}
+		if item.PronomID == "" {
+			item.FormatName = "Unidentified"
+		}
+		if runtimeTasks > 0 {
+			item.AvgToolRuntimeSeconds = round2(runtimeSeconds / float64(runtimeTasks))
+		}
+		if item.NormalizationAttempts > 0 {
+			item.NormalizationFailureRate = round2(float64(item.NormalizationFailures) * 100 / float64(item.NormalizationAttempts))
+		}
+		item.TopFailingCommands = make([]FailingCommand, 0)
+		index[item.PronomID] = len(items)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return items, nil
+	}
+
+	commands, err := s.formatFailingCommands(ctx, opts, filterClause, filterArgs)
+	if err != nil {
+		return nil, err
+	}
+	for pronom, list := range commands {
+		i, ok := index[pronom]
+		if !ok {
+			continue
+		}
+		sort.Slice(list, func(a, b int) bool {
+			if list[a].Failures != list[b].Failures {
+				return list[a].Failures > list[b].Failures
+			}
+			return list[a].JobType < list[b].JobType
+		})
+		if len(list) > formatAnalyticsTopCommands {
+			list = list[:formatAnalyticsTopCommands]
+		}
+		items[i].TopFailingCommands = list
+	}
+	return items, nil
+}
+
+func (s *Store) formatFailingCommands(ctx context.Context, opts FormatAnalyticsOptions, filterClause string, filterArgs []any) (map[string][]FailingCommand, error) {
+	q := `
+SELECT
+  COALESCE(fv.pronom_id, '') AS pronom_id,
+  COALESCE(j.jobType, '') AS job_type,
+  COALESCE(tsk.execution, '') AS execution,
+  COUNT(*) AS failures
+FROM Tasks tsk
+JOIN Jobs j
+  ON j.jobUUID = tsk.jobuuid
+JOIN Files f
+  ON f.fileUUID = tsk.fileUUID
+JOIN Transfers t
+  ON t.transferUUID = f.transferUUID
+LEFT JOIN FilesIdentifiedIDs fii
+  ON fii.fileUUID = f.fileUUID
+LEFT JOIN fpr_formatversion fv
+  ON fv.uuid = fii.fileID
+WHERE COALESCE(tsk.exitCode, 0) <> 0
+  AND tsk.createdTime >= ?
+  AND f.enteredSystem >= ?
+  AND f.enteredSystem < ?
+  AND LOWER(COALESCE(f.fileGrpUse, '')) = 'original'
+  ` + filterClause + `
+GROUP BY COALESCE(fv.pronom_id, ''), j.jobType, tsk.execution
+ORDER BY failures DESC
+LIMIT 1000;
+`
+	args := []any{opts.DateFrom, opts.DateFrom, opts.DateTo}
+	args = append(args, filterArgs...)
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string][]FailingCommand{}
+	for rows.Next() {
+		var (
+			pronom string
+			cmd    FailingCommand
+		)
+		if err := rows.Scan(&pronom, &cmd.JobType, &cmd.Execution, &cmd.Failures); err != nil {
+			return nil, err
+		}
+		cmd.Execution = strings.TrimSpace(cmd.Execution)
+		out[pronom] = append(out[pronom], cmd)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,440 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"go-am-realtime-report-ui/internal/dbctx"
+)
+
+// LineageNode is one package, unit or file in a lineage graph.
+// ID is "<type>:<uuid>" because a SIP and its AIP share the same UUID.
+type LineageNode struct {
+	ID          string         `json:"id"`
+	UUID        string         `json:"uuid"`
+	Type        string         `json:"type"`
+	Source      string         `json:"source"`
+	Name        string         `json:"name,omitempty"`
+	Status      string         `json:"status"`
+	CreatedAt   *time.Time     `json:"created_at,omitempty"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+	Attributes  map[string]any `json:"attributes,omitempty"`
+}
+
+// LineageEdge is a directed relation between two lineage nodes.
+type LineageEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Relation string `json:"relation"`
+}
+
+// LineageGraph is the resolved transfer -> SIP -> AIP -> DIP chain for one UUID.
+type LineageGraph struct {
+	QueryUUID  string        `json:"query_uuid"`
+	ResolvedAs string        `json:"resolved_as"`
+	Nodes      []LineageNode `json:"nodes"`
+	Edges      []LineageEdge `json:"edges"`
+}
+
+// NewLineageGraph returns an empty graph for a query UUID.
+func NewLineageGraph(queryUUID string) *LineageGraph {
+	return &LineageGraph{
+		QueryUUID: strings.TrimSpace(queryUUID),
+		Nodes:     make([]LineageNode, 0),
+		Edges:     make([]LineageEdge, 0),
+	}
+}
+
+// LineageNodeID returns the graph key for a node type and UUID.
+func LineageNodeID(nodeType, uuid string) string {
+	return nodeType + ":" + uuid
+}
+
+// AddNode inserts a node, merging attributes into an existing node with the same ID.
+// It returns the node ID for use in AddEdge.
+func (g *LineageGraph) AddNode(node LineageNode) string {
+	node.ID = LineageNodeID(node.Type, node.UUID)
+	for i := range g.Nodes {
+		if g.Nodes[i].ID != node.ID {
+			continue
+		}
+		if len(node.Attributes) > 0 {
+			if g.Nodes[i].Attributes == nil {
+				g.Nodes[i].Attributes = map[string]any{}
+			}
+			for k, v := range node.Attributes {
+				g.Nodes[i].Attributes[k] = v
+			}
+		}
+		return node.ID
+	}
+	g.Nodes = append(g.Nodes, node)
+	return node.ID
+}
+
+// AddEdge inserts an edge unless the same relation already exists.
+func (g *LineageGraph) AddEdge(from, to, relation string) {
+	if from == "" || to == "" {
+		return
+	}
+	for _, e := range g.Edges {
+		if e.From == from && e.To == to && e.Relation == relation {
+			return
+		}
+	}
+	g.Edges = append(g.Edges, LineageEdge{From: from, To: to, Relation: relation})
+}
+
+// HasNode reports whether a node with the given type and UUID exists.
+func (g *LineageGraph) HasNode(nodeType, uuid string) bool {
+	id := LineageNodeID(nodeType, uuid)
+	for _, n := range g.Nodes {
+		if n.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// PruneEdges drops edges whose endpoints are not both nodes of the graph, e.g.
+// links to units left out by the lineage unit cap.
+func (g *LineageGraph) PruneEdges() {
+	ids := make(map[string]struct{}, len(g.Nodes))
+	for _, n := range g.Nodes {
+		ids[n.ID] = struct{}{}
+	}
+	kept := g.Edges[:0]
+	for _, e := range g.Edges {
+		_, okFrom := ids[e.From]
+		_, okTo := ids[e.To]
+		if okFrom && okTo {
+			kept = append(kept, e)
+		}
+	}
+	g.Edges = kept
+}
+
+// NodeUUIDs returns UUIDs of nodes of the given type in insertion order.
+func (g *LineageGraph) NodeUUIDs(nodeType string) []string {
+	out := make([]string, 0)
+	for _, n := range g.Nodes {
+		if n.Type == nodeType {
+			out = append(out, n.UUID)
+		}
+	}
+	return out
+}
+
+const lineageMaxUnits = 50
+
+// ResolveLineage resolves transfers, SIPs and (optionally) a file from MCP for any UUID.
+// Storage Service and Elasticsearch nodes are attached by the caller.
+func (s *Store) ResolveLineage(ctx context.Context, graph *LineageGraph, uuid string) error {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	uuid = strings.TrimSpace(uuid)
+	transfers := map[string]struct{}{}
+	sips := map[string]struct{}{}
+
+	var exists int
+	err := s.db.QueryRowContext(ctx, `SELECT 1 FROM Transfers WHERE transferUUID = ? LIMIT 1;`, uuid).Scan(&exists)
+	switch {
+	case err == nil:
+		transfers[uuid] = struct{}{}
+		setResolvedAs(graph, "transfer")
+	case err != sql.ErrNoRows:
+		return err
+	}
+
+	err = s.db.QueryRowContext(ctx, `SELECT 1 FROM SIPs WHERE sipUUID = ? LIMIT 1;`, uuid).Scan(&exists)
+	switch {
+	case err == nil:
+		sips[uuid] = struct{}{}
+		setResolvedAs(graph, "sip")
+	case err != sql.ErrNoRows:
+		return err
+	}
+
+	if len(transfers) == 0 && len(sips) == 0 {
+		var (
+			fileTransfer sql.NullString
+			fileSIP      sql.NullString
+			location     sql.NullString
+			grpUse       sql.NullString
+			size         sql.NullInt64
+			entered      sql.NullTime
+		)
+		err = s.db.QueryRowContext(ctx, `
+SELECT transferUUID, sipUUID, currentLocation, fileGrpUse, fileSize, enteredSystem
+FROM Files
+WHERE fileUUID = ?
+LIMIT 1;
+`, uuid).Scan(&fileTransfer, &fileSIP, &location, &grpUse, &size, &entered)
+		switch {
+		case err == nil:
+			setResolvedAs(graph, "file")
+			fileID := graph.AddNode(LineageNode{
+				UUID:      uuid,
+				Type:      "file",
+				Source:    "mcp",
+				Name:      baseName(location.String),
+				Status:    "PRESENT",
+				CreatedAt: nullTimePtr(entered),
+				Attributes: map[string]any{
+					"file_grp_use": grpUse.String,
+					"size_bytes":   nullInt64Value(size),
+				},
+			})
+			if t := strings.TrimSpace(fileTransfer.String); t != "" {
+				transfers[t] = struct{}{}
+				graph.AddEdge(fileID, LineageNodeID("transfer", t), "part_of")
+			}
+			if sp := strings.TrimSpace(fileSIP.String); sp != "" {
+				sips[sp] = struct{}{}
+				graph.AddEdge(fileID, LineageNodeID("sip", sp), "part_of")
+			}
+		case err != sql.ErrNoRows:
+			return err
+		}
+	}
+
+	// Two expansion rounds cover arranged SIPs that merge several transfers
+	// and transfers that were split into several SIPs.
+	for round := 0; round < 2; round++ {
+		links, err := s.transferSIPLinks(ctx, keysOf(transfers), keysOf(sips))
+		if err != nil {
+			return err
+		}
+		for _, l := range links {
+			if _, ok := transfers[l.TransferUUID]; !ok && len(transfers) < lineageMaxUnits {
+				transfers[l.TransferUUID] = struct{}{}
+			}
+			if _, ok := sips[l.SIPUUID]; !ok && len(sips) < lineageMaxUnits {
+				sips[l.SIPUUID] = struct{}{}
+			}
+			_, okTransfer := transfers[l.TransferUUID]
+			_, okSIP := sips[l.SIPUUID]
+			if okTransfer && okSIP {
+				graph.AddEdge(LineageNodeID("transfer", l.TransferUUID), LineageNodeID("sip", l.SIPUUID), "ingested_as")
+			}
+		}
+	}
+
+	if err := s.addTransferLineageNodes(ctx, graph, keysOf(transfers)); err != nil {
+		return err
+	}
+	if err := s.addSIPLineageNodes(ctx, graph, keysOf(sips)); err != nil {
+		return err
+	}
+	// Units referenced by a file or link but missing from MCP get no node.
+	graph.PruneEdges()
+	return nil
+}
+
+type transferSIPLink struct {
+	TransferUUID string
+	SIPUUID      string
+	Files        int64
+}
+
+func (s *Store) transferSIPLinks(ctx context.Context, transferUUIDs, sipUUIDs []string) ([]transferSIPLink, error) {
+	if len(transferUUIDs) == 0 && len(sipUUIDs) == 0 {
+		return nil, nil
+	}
+	conds := make([]string, 0, 2)
+	args := make([]any, 0, len(transferUUIDs)+len(sipUUIDs))
+	if len(transferUUIDs) > 0 {
+		conds = append(conds, "f.transferUUID IN ("+placeholders(len(transferUUIDs))+")")
+		for _, id := range transferUUIDs {
+			args = append(args, id)
+		}
+	}
+	if len(sipUUIDs) > 0 {
+		conds = append(conds, "f.sipUUID IN ("+placeholders(len(sipUUIDs))+")")
+		for _, id := range sipUUIDs {
+			args = append(args, id)
+		}
+	}
+
+	q := `
+SELECT f.transferUUID, f.sipUUID, COUNT(*) AS files
+FROM Files f
+WHERE (` + strings.Join(conds, " OR ") + `)
+  AND f.transferUUID IS NOT NULL
+  AND f.transferUUID <> ''
+  AND f.sipUUID IS NOT NULL
+  AND f.sipUUID <> ''
+GROUP BY f.transferUUID, f.sipUUID
+LIMIT 500;
+`
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]transferSIPLink, 0)
+	for rows.Next() {
+		var l transferSIPLink
+		if err := rows.Scan(&l.TransferUUID, &l.SIPUUID, &l.Files); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) addTransferLineageNodes(ctx context.Context, graph *LineageGraph, uuids []string) error {
+	if len(uuids) == 0 {
+		return nil
+	}
+	args := make([]any, 0, len(uuids))
+	for _, id := range uuids {
+		args = append(args, id)
+	}
+	q := `
+SELECT
+  t.transferUUID,
+  COALESCE(t.currentLocation, ''),
+  COALESCE(t.status, 0),
+  COALESCE(t.sourceOfAcquisition, ''),
+  COALESCE(t.accessionID, ''),
+  (SELECT MIN(j.createdTime) FROM Jobs j WHERE j.SIPUUID = t.transferUUID) AS started_at,
+  t.completed_at
+FROM Transfers t
+WHERE t.transferUUID IN (` + placeholders(len(uuids)) + `);
+`
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id          string
+			location    string
+			status      int
+			source      string
+			accession   string
+			startedAt   sql.NullTime
+			completedAt sql.NullTime
+		)
+		if err := rows.Scan(&id, &location, &status, &source, &accession, &startedAt, &completedAt); err != nil {
+			return err
+		}
+		graph.AddNode(LineageNode{
+			UUID:        id,
+			Type:        "transfer",
+			Source:      "mcp",
+			Name:        transferNameFromLocation(location, id),
+			Status:      transferStatusName(status),
+			CreatedAt:   nullTimePtr(startedAt),
+			CompletedAt: nullTimePtr(completedAt),
+			Attributes: map[string]any{
+				"source_of_acquisition": source,
+				"accession_id":          accession,
+			},
+		})
+	}
+	return rows.Err()
+}
+
+func (s *Store) addSIPLineageNodes(ctx context.Context, graph *LineageGraph, uuids []string) error {
+	if len(uuids) == 0 {
+		return nil
+	}
+	args := make([]any, 0, len(uuids))
+	for _, id := range uuids {
+		args = append(args, id)
+	}
+	q := `
+SELECT
+  s.sipUUID,
+  COALESCE(s.currentPath, ''),
+  COALESCE(s.status, 0),
+  COALESCE(s.sipType, ''),
+  COALESCE(s.aipFilename, ''),
+  s.createdTime,
+  s.completed_at,
+  (
+    SELECT COUNT(DISTINCT j.jobUUID)
+    FROM Jobs j
+    JOIN Tasks tsk
+      ON tsk.jobuuid = j.jobUUID
+    WHERE j.SIPUUID = s.sipUUID
+      AND j.unitType IN ('unitSIP', 'unitDIP')
+      AND j.currentStep = 4
+      AND COALESCE(tsk.exitCode, 0) <> 0
+  ) AS failed_jobs
+FROM SIPs s
+WHERE s.sipUUID IN (` + placeholders(len(uuids)) + `);
+`
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id          string
+			path        string
+			status      int
+			sipType     string
+			aipFilename string
+			createdAt   sql.NullTime
+			completedAt sql.NullTime
+			failedJobs  int64
+		)
+		if err := rows.Scan(&id, &path, &status, &sipType, &aipFilename, &createdAt, &completedAt, &failedJobs); err != nil {
+			return err
+		}
+		graph.AddNode(LineageNode{
+			UUID:        id,
+			Type:        "sip",
+			Source:      "mcp",
+			Name:        transferNameFromLocation(path, id),
+			Status:      sipStatusName(status, failedJobs, 0, 0),
+			CreatedAt:   nullTimePtr(createdAt),
+			CompletedAt: nullTimePtr(completedAt),
+			Attributes: map[string]any{
+				"sip_type":     sipType,
+				"aip_filename": aipFilename,
+				"failed_jobs":  failedJobs,
+			},
+		})
+	}
+	return rows.Err()
+}
+
+func setResolvedAs(graph *LineageGraph, kind string) {
+	if graph.ResolvedAs == "" {
+		graph.ResolvedAs = kind
+	}
+}
+
+func keysOf(m map[string]struct{}) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func baseName(path string) string {
+	path = strings.TrimRight(strings.TrimSpace(path), "/")
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
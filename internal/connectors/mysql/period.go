@@ -0,0 +1,327 @@
+package mysql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Report period kinds.
+const (
+	PeriodMonth      = "month"
+	PeriodWeek       = "week"
+	PeriodQuarter    = "quarter"
+	PeriodYear       = "year"
+	PeriodFiscalYear = "fiscal_year"
+	PeriodCustom     = "custom"
+)
+
+// Report bucket granularities.
+const (
+	BucketHour  = "hour"
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+const (
+	reportMaxBuckets    = 2000
+	reportMaxCustomSpan = 3 * 366 * 24 * time.Hour
+)
+
+// ReportPeriod is a reporting window aligned to local days in Timezone.
+// Start is inclusive and End exclusive; both are UTC instants.
+type ReportPeriod struct {
+	Kind     string    `json:"kind"`
+	Label    string    `json:"label"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Timezone string    `json:"timezone"`
+	Bucket   string    `json:"bucket"`
+
+	loc *time.Location
+}
+
+// MonthPeriod returns the calendar month containing month.
+func MonthPeriod(month time.Time, loc *time.Location) ReportPeriod {
+	p, _ := NewReportPeriod(PeriodMonth, month, loc, 1)
+	return p
+}
+
+// NewReportPeriod returns the week (ISO, Monday start), month, quarter, year or
+// fiscal year containing anchor's local date in loc. Fiscal years start on the
+// first of fiscalStartMonth and are labelled by the calendar year they end in.
+func NewReportPeriod(kind string, anchor time.Time, loc *time.Location, fiscalStartMonth int) (ReportPeriod, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	if fiscalStartMonth < 1 || fiscalStartMonth > 12 {
+		fiscalStartMonth = 1
+	}
+	y, m, d := anchor.In(loc).Date()
+
+	var start, end time.Time
+	var label string
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case PeriodMonth, "":
+		kind = PeriodMonth
+		start = time.Date(y, m, 1, 0, 0, 0, 0, loc)
+		end = start.AddDate(0, 1, 0)
+		label = start.Format("2006-01")
+	case PeriodWeek:
+		day := time.Date(y, m, d, 0, 0, 0, 0, loc)
+		offset := (int(day.Weekday()) + 6) % 7
+		start = day.AddDate(0, 0, -offset)
+		end = start.AddDate(0, 0, 7)
+		isoYear, week := start.ISOWeek()
+		label = fmt.Sprintf("%d-W%02d", isoYear, week)
+	case PeriodQuarter:
+		q := (int(m) - 1) / 3
+		start = time.Date(y, time.Month(q*3+1), 1, 0, 0, 0, 0, loc)
+		end = start.AddDate(0, 3, 0)
+		label = fmt.Sprintf("%d-Q%d", y, q+1)
+	case PeriodYear:
+		start = time.Date(y, 1, 1, 0, 0, 0, 0, loc)
+		end = start.AddDate(1, 0, 0)
+		label = fmt.Sprintf("%d", y)
+	case PeriodFiscalYear:
+		startYear := y
+		if int(m) < fiscalStartMonth {
+			startYear--
+		}
+		start = time.Date(startYear, time.Month(fiscalStartMonth), 1, 0, 0, 0, 0, loc)
+		end = start.AddDate(1, 0, 0)
+		label = fmt.Sprintf("FY%d", end.AddDate(0, 0, -1).Year())
+	default:
+		return ReportPeriod{}, fmt.Errorf("unsupported period %q (expected month, week, quarter, year, fiscal_year or custom)", kind)
+	}
+
+	p := ReportPeriod{
+		Kind:     strings.ToLower(strings.TrimSpace(kind)),
+		Label:    label,
+		Start:    start.UTC(),
+		End:      end.UTC(),
+		Timezone: loc.String(),
+		loc:      loc,
+	}
+	p.Bucket = AutoReportBucket(p.End.Sub(p.Start))
+	return p, nil
+}
+
+// CustomReportPeriod returns an arbitrary [start, end) window.
+func CustomReportPeriod(start, end time.Time, loc *time.Location) (ReportPeriod, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	if !end.After(start) {
+		return ReportPeriod{}, fmt.Errorf("date_to must be after date_from")
+	}
+	if end.Sub(start) > reportMaxCustomSpan {
+		return ReportPeriod{}, fmt.Errorf("date range too large, maximum is %d days", int(reportMaxCustomSpan.Hours()/24))
+	}
+	p := ReportPeriod{
+		Kind:     PeriodCustom,
+		Label:    start.In(loc).Format("2006-01-02") + ".." + end.In(loc).Add(-time.Nanosecond).Format("2006-01-02"),
+		Start:    start.UTC(),
+		End:      end.UTC(),
+		Timezone: loc.String(),
+		loc:      loc,
+	}
+	p.Bucket = AutoReportBucket(p.End.Sub(p.Start))
+	return p, nil
+}
+
+// relativePeriodKinds maps the calendar relative ranges to period kinds; "last" and
+// "previous" are synonyms.
+var relativePeriodKinds = map[string]string{
+	"week":        PeriodWeek,
+	"month":       PeriodMonth,
+	"quarter":     PeriodQuarter,
+	"year":        PeriodYear,
+	"fiscal_year": PeriodFiscalYear,
+}
+
+// RelativeReportRanges lists the names RelativeReportPeriod accepts.
+func RelativeReportRanges() []string {
+	return []string{
+		"today", "yesterday", "last_7_days", "last_30_days", "last_90_days",
+		"this_week", "last_week", "this_month", "last_month", "this_quarter", "last_quarter",
+		"this_year", "last_year", "this_fiscal_year", "last_fiscal_year",
+	}
+}
+
+// RelativeReportPeriod resolves a named range relative to at in loc: today,
+// yesterday, last_N_days (the N whole days before today, N = 7, 30 or 90), or
+// this_/last_ (also previous_) week, month, quarter, year and fiscal_year.
+func RelativeReportPeriod(name string, at time.Time, loc *time.Location, fiscalStartMonth int) (ReportPeriod, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	y, m, d := at.In(loc).Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	switch name {
+	case "today":
+		return CustomReportPeriod(today, today.AddDate(0, 0, 1), loc)
+	case "yesterday", "previous_day":
+		return CustomReportPeriod(today.AddDate(0, 0, -1), today, loc)
+	case "last_7_days":
+		return CustomReportPeriod(today.AddDate(0, 0, -7), today, loc)
+	case "last_30_days":
+		return CustomReportPeriod(today.AddDate(0, 0, -30), today, loc)
+	case "last_90_days":
+		return CustomReportPeriod(today.AddDate(0, 0, -90), today, loc)
+	}
+	when, unit, _ := strings.Cut(name, "_")
+	kind, ok := relativePeriodKinds[unit]
+	if !ok || (when != "this" && when != "last" && when != "previous") {
+		return ReportPeriod{}, fmt.Errorf("unsupported relative range %q (expected one of %s)", name, strings.Join(RelativeReportRanges(), ", "))
+	}
+	current, err := NewReportPeriod(kind, at, loc, fiscalStartMonth)
+	if err != nil || when == "this" {
+		return current, err
+	}
+	return NewReportPeriod(kind, current.Start.Add(-time.Nanosecond), loc, fiscalStartMonth)
+}
+
+// AutoReportBucket picks a bucket granularity that keeps charts readable.
+func AutoReportBucket(span time.Duration) string {
+	switch {
+	case span <= 2*24*time.Hour:
+		return BucketHour
+	case span <= 100*24*time.Hour:
+		return BucketDay
+	case span <= 400*24*time.Hour:
+		return BucketWeek
+	default:
+		return BucketMonth
+	}
+}
+
+// WithBucket overrides the automatic bucket; "" and "auto" keep it.
+func (p ReportPeriod) WithBucket(bucket string) (ReportPeriod, error) {
+	bucket = strings.ToLower(strings.TrimSpace(bucket))
+	switch bucket {
+	case "", "auto":
+		return p, nil
+	case BucketHour, BucketDay, BucketWeek, BucketMonth:
+	default:
+		return p, fmt.Errorf("unsupported bucket %q (expected auto, hour, day, week or month)", bucket)
+	}
+	p.Bucket = bucket
+	if n := len(p.BucketStarts()); n > reportMaxBuckets {
+		return p, fmt.Errorf("bucket too small for period, at most %d points", reportMaxBuckets)
+	}
+	return p, nil
+}
+
+// Location returns the period's timezone.
+func (p ReportPeriod) Location() *time.Location {
+	if p.loc == nil {
+		return time.UTC
+	}
+	return p.loc
+}
+
+func (p ReportPeriod) bucketStart(t time.Time) time.Time {
+	local := t.In(p.Location())
+	y, m, d := local.Date()
+	switch p.Bucket {
+	case BucketHour:
+		return time.Date(y, m, d, local.Hour(), 0, 0, 0, p.Location())
+	case BucketWeek:
+		day := time.Date(y, m, d, 0, 0, 0, 0, p.Location())
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case BucketMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, p.Location())
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, p.Location())
+	}
+}
+
+func (p ReportPeriod) nextBucket(start time.Time) time.Time {
+	switch p.Bucket {
+	case BucketHour:
+		return start.Add(time.Hour)
+	case BucketWeek:
+		return start.AddDate(0, 0, 7)
+	case BucketMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// BucketKey formats the local bucket containing t: hour "2006-01-02T15:00",
+// day and week "2006-01-02" (weeks by their Monday), month "2006-01".
+func (p ReportPeriod) BucketKey(t time.Time) string {
+	start := p.bucketStart(t)
+	switch p.Bucket {
+	case BucketHour:
+		return start.Format("2006-01-02T15:00")
+	case BucketMonth:
+		return start.Format("2006-01")
+	default:
+		return start.Format("2006-01-02")
+	}
+}
+
+// BucketStarts lists the local start of every bucket overlapping the period.
+func (p ReportPeriod) BucketStarts() []time.Time {
+	out := make([]time.Time, 0)
+	for b := p.bucketStart(p.Start); b.Before(p.End); b = p.nextBucket(b) {
+		out = append(out, b)
+		if len(out) > reportMaxBuckets {
+			break
+		}
+	}
+	return out
+}
+
+// Shift returns the period moved by n periods of the same kind; n may be negative.
+// Custom periods move by their own length. The bucket is kept.
+func (p ReportPeriod) Shift(n int) ReportPeriod {
+	loc := p.Location()
+	start := p.Start.In(loc)
+	var shifted ReportPeriod
+	switch p.Kind {
+	case PeriodWeek:
+		shifted, _ = NewReportPeriod(p.Kind, start.AddDate(0, 0, 7*n), loc, 1)
+	case PeriodQuarter:
+		shifted, _ = NewReportPeriod(p.Kind, start.AddDate(0, 3*n, 0), loc, 1)
+	case PeriodYear, PeriodFiscalYear:
+		// The start month of a fiscal year is its fiscal start month.
+		shifted, _ = NewReportPeriod(p.Kind, start.AddDate(n, 0, 0), loc, int(start.Month()))
+	case PeriodCustom:
+		span := p.End.Sub(p.Start)
+		shifted, _ = CustomReportPeriod(p.Start.Add(time.Duration(n)*span), p.End.Add(time.Duration(n)*span), loc)
+	default:
+		shifted, _ = NewReportPeriod(PeriodMonth, start.AddDate(0, n, 0), loc, 1)
+	}
+	shifted.Bucket = p.Bucket
+	return shifted
+}
+
+// YearAgo returns the same period one year earlier.
+func (p ReportPeriod) YearAgo() ReportPeriod {
+	switch p.Kind {
+	case PeriodMonth, "":
+		return p.Shift(-12)
+	case PeriodQuarter:
+		return p.Shift(-4)
+	case PeriodYear, PeriodFiscalYear:
+		return p.Shift(-1)
+	case PeriodWeek:
+		// Same ISO week number of the previous year.
+		_, week := p.Start.In(p.Location()).ISOWeek()
+		shifted := p.Shift(-52)
+		if _, w := shifted.Start.In(p.Location()).ISOWeek(); w != week {
+			shifted = p.Shift(-53)
+		}
+		return shifted
+	}
+	loc := p.Location()
+	shifted, _ := CustomReportPeriod(p.Start.In(loc).AddDate(-1, 0, 0), p.End.In(loc).AddDate(-1, 0, 0), loc)
+	shifted.Bucket = p.Bucket
+	return shifted
+}
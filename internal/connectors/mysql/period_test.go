@@ -0,0 +1,116 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewReportPeriod_Kinds(t *testing.T) {
+	anchor := time.Date(2026, 2, 18, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		kind   string
+		label  string
+		start  string
+		end    string
+		bucket string
+	}{
+		{PeriodMonth, "2026-02", "2026-02-01", "2026-03-01", BucketDay},
+		{PeriodWeek, "2026-W08", "2026-02-16", "2026-02-23", BucketDay},
+		{PeriodQuarter, "2026-Q1", "2026-01-01", "2026-04-01", BucketDay},
+		{PeriodYear, "2026", "2026-01-01", "2027-01-01", BucketWeek},
+		{PeriodFiscalYear, "FY2026", "2025-07-01", "2026-07-01", BucketWeek},
+	}
+	for _, tc := range cases {
+		p, err := NewReportPeriod(tc.kind, anchor, time.UTC, 7)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", tc.kind, err)
+		}
+		if p.Label != tc.label || p.Start.Format("2006-01-02") != tc.start || p.End.Format("2006-01-02") != tc.end || p.Bucket != tc.bucket {
+			t.Fatalf("%s: got %s [%s, %s) bucket %s", tc.kind, p.Label, p.Start, p.End, p.Bucket)
+		}
+	}
+
+	if _, err := NewReportPeriod("decade", anchor, time.UTC, 1); err == nil {
+		t.Fatalf("expected error for unsupported period")
+	}
+}
+
+func TestReportPeriod_TimezoneAlignsDayBuckets(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	p := MonthPeriod(time.Date(2026, 2, 1, 0, 0, 0, 0, berlin), berlin)
+
+	if got := p.Start; !got.Equal(time.Date(2026, 1, 31, 23, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected local midnight start, got %s", got)
+	}
+	// 23:30 UTC on Feb 1 is already Feb 2 in Berlin.
+	if got := p.BucketKey(time.Date(2026, 2, 1, 23, 30, 0, 0, time.UTC)); got != "2026-02-02" {
+		t.Fatalf("unexpected bucket key %q", got)
+	}
+	if n := len(p.BucketStarts()); n != 28 {
+		t.Fatalf("expected 28 day buckets, got %d", n)
+	}
+}
+
+func TestReportPeriod_BucketsAndCustomRange(t *testing.T) {
+	start := time.Date(2026, 2, 2, 0, 0, 0, 0, time.UTC)
+	p, err := CustomReportPeriod(start, start.Add(36*time.Hour), time.UTC)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if p.Bucket != BucketHour || len(p.BucketStarts()) != 36 {
+		t.Fatalf("expected 36 hourly buckets, got %s/%d", p.Bucket, len(p.BucketStarts()))
+	}
+	if p.Label != "2026-02-02..2026-02-03" {
+		t.Fatalf("unexpected label %q", p.Label)
+	}
+
+	weekly, err := p.WithBucket(BucketWeek)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got := weekly.BucketKey(time.Date(2026, 2, 5, 10, 0, 0, 0, time.UTC)); got != "2026-02-02" {
+		t.Fatalf("expected Monday week key, got %q", got)
+	}
+
+	year, _ := NewReportPeriod(PeriodYear, start, time.UTC, 1)
+	if _, err := year.WithBucket(BucketHour); err == nil {
+		t.Fatalf("expected error for too many hourly buckets")
+	}
+	if _, err := CustomReportPeriod(start, start, time.UTC); err == nil {
+		t.Fatalf("expected error for empty range")
+	}
+}
+
+func TestRelativeReportPeriod(t *testing.T) {
+	at := time.Date(2026, 4, 15, 9, 30, 0, 0, time.UTC)
+	cases := []struct {
+		name, start, end string
+	}{
+		{"today", "2026-04-15", "2026-04-16"},
+		{"yesterday", "2026-04-14", "2026-04-15"},
+		{"last_7_days", "2026-04-08", "2026-04-15"},
+		{"this_month", "2026-04-01", "2026-05-01"},
+		{"last_month", "2026-03-01", "2026-04-01"},
+		{"previous_quarter", "2026-01-01", "2026-04-01"},
+		{"last_year", "2025-01-01", "2026-01-01"},
+		{"this_fiscal_year", "2025-07-01", "2026-07-01"},
+		{"last_fiscal_year", "2024-07-01", "2025-07-01"},
+	}
+	for _, tc := range cases {
+		p, err := RelativeReportPeriod(tc.name, at, time.UTC, 7)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", tc.name, err)
+		}
+		if p.Start.Format("2006-01-02") != tc.start || p.End.Format("2006-01-02") != tc.end {
+			t.Fatalf("%s: got [%s, %s)", tc.name, p.Start, p.End)
+		}
+	}
+	for _, name := range []string{"", "next_month", "last_decade", "last_5_days"} {
+		if _, err := RelativeReportPeriod(name, at, time.UTC, 1); err == nil {
+			t.Fatalf("expected error for %q", name)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+)
+
+// ReportSnapshotInputs are the settings a monthly report was computed with.
+// Sources is empty for reports over all customers and includes the sources of
+// the rolled-up Members; SourceWindows lists the sources whose mapping has a
+// validity window.
+type ReportSnapshotInputs struct {
+	AppVersion    string                  `json:"app_version"`
+	MappingMode   string                  `json:"mapping_mode"`
+	Members       []string                `json:"members,omitempty"`
+	Sources       []string                `json:"sources"`
+	SourceWindows []CustomerSourceMapping `json:"source_windows,omitempty"`
+	SLAPolicy     SLAPolicy               `json:"sla_policy"`
+}
+
+// ReportSnapshot is a frozen monthly report. Verified tells whether the stored
+// content still matches its hash; Report is left out of listings.
+type ReportSnapshot struct {
+	ID          int64                `json:"id"`
+	CustomerID  string               `json:"customer_id"`
+	Period      ReportPeriod         `json:"period"`
+	Report      *MonthlyReport       `json:"report,omitempty"`
+	Inputs      ReportSnapshotInputs `json:"inputs"`
+	ContentHash string               `json:"content_hash"`
+	Verified    bool                 `json:"verified"`
+	Note        string               `json:"note"`
+	Official    bool                 `json:"official"`
+	OfficialAt  string               `json:"official_at,omitempty"`
+	CreatedAt   string               `json:"created_at"`
+}
+
+// ReportSnapshotChange is a value that differs between a snapshot and a fresh
+// computation. Delta is set for numbers.
+type ReportSnapshotChange struct {
+	Field    string   `json:"field"`
+	Snapshot any      `json:"snapshot"`
+	Current  any      `json:"current"`
+	Delta    *float64 `json:"delta,omitempty"`
+}
+
+// ReportSnapshotDiff compares a snapshot with the report computed now from the live
+// tables and the current mappings and SLA policy.
+type ReportSnapshotDiff struct {
+	SnapshotID   int64                  `json:"snapshot_id"`
+	ComputedAt   time.Time              `json:"computed_at"`
+	Changed      bool                   `json:"changed"`
+	SnapshotHash string                 `json:"snapshot_hash"`
+	CurrentHash  string                 `json:"current_hash"`
+	KPIs         []ReportSnapshotChange `json:"kpis"`
+	Timeseries   []ReportSnapshotChange `json:"timeseries"`
+	Inputs       []ReportSnapshotChange `json:"inputs"`
+}
+
+// CreateReportSnapshot computes the monthly report of customerID for period and
+// freezes it with its inputs. Freezing a report identical to an existing snapshot
+// of the same customer and period returns that snapshot with created false.
+func (s *Store) CreateReportSnapshot(ctx context.Context, customerID string, period ReportPeriod, appVersion, note string, official bool) (*ReportSnapshot, bool, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, false, err
+	}
+	report, reportJSON, inputsJSON, err := s.computeReportSnapshot(ctx, customerID, period, appVersion)
+	if err != nil {
+		return nil, false, err
+	}
+	saved, created, err := store.InsertReportSnapshot(ctx, customermap.ReportSnapshot{
+		CustomerID:  report.CustomerID,
+		PeriodKind:  period.Kind,
+		PeriodLabel: period.Label,
+		PeriodStart: period.Start,
+		PeriodEnd:   period.End,
+		Timezone:    period.Timezone,
+		ReportJSON:  reportJSON,
+		InputsJSON:  inputsJSON,
+		Note:        note,
+	}, official)
+	if err != nil {
+		return nil, false, err
+	}
+	out, err := reportSnapshotFromApp(*saved, true)
+	return out, created, err
+}
+
+func (s *Store) GetReportSnapshot(ctx context.Context, id int64) (*ReportSnapshot, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	it, err := store.GetReportSnapshot(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return reportSnapshotFromApp(*it, true)
+}
+
+// ListReportSnapshots returns snapshots newest first, without their reports.
+// label filters by period label such as 2026-03.
+func (s *Store) ListReportSnapshots(ctx context.Context, customerID, label string, officialOnly bool, limit int) ([]ReportSnapshot, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	items, err := store.ListReportSnapshots(ctx, customermap.ReportSnapshotFilter{CustomerID: customerID, PeriodLabel: label, OfficialOnly: officialOnly}, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ReportSnapshot, 0, len(items))
+	for _, it := range items {
+		row, err := reportSnapshotFromApp(it, false)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *row)
+	}
+	return out, nil
+}
+
+// SetReportSnapshotOfficial marks a snapshot as the official one of its customer
+// and period, or removes the mark.
+func (s *Store) SetReportSnapshotOfficial(ctx context.Context, id int64, official bool) (*ReportSnapshot, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	it, err := store.SetReportSnapshotOfficial(ctx, id, official)
+	if err != nil {
+		return nil, err
+	}
+	return reportSnapshotFromApp(*it, false)
+}
+
+// DiffReportSnapshot recomputes the report of a snapshot and lists what changed.
+func (s *Store) DiffReportSnapshot(ctx context.Context, id int64, appVersion string) (*ReportSnapshotDiff, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	it, err := store.GetReportSnapshot(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := reportSnapshotFromApp(*it, true)
+	if err != nil {
+		return nil, err
+	}
+	period, err := restoreReportPeriod(snap.Period)
+	if err != nil {
+		return nil, err
+	}
+	_, reportJSON, inputsJSON, err := s.computeReportSnapshot(ctx, snap.CustomerID, period, appVersion)
+	if err != nil {
+		return nil, err
+	}
+	diff := &ReportSnapshotDiff{
+		SnapshotID:   id,
+		ComputedAt:   time.Now().UTC(),
+		SnapshotHash: it.ContentHash,
+		CurrentHash:  customermap.ReportSnapshotHash(reportJSON, inputsJSON),
+		KPIs:         []ReportSnapshotChange{},
+		Timeseries:   []ReportSnapshotChange{},
+		Inputs:       []ReportSnapshotChange{},
+	}
+	diff.Changed = diff.SnapshotHash != diff.CurrentHash
+
+	var before, after struct {
+		KPIs       map[string]any     `json:"kpis"`
+		Timeseries []DailyReportPoint `json:"timeseries"`
+	}
+	if err := json.Unmarshal([]byte(it.ReportJSON), &before); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(reportJSON), &after); err != nil {
+		return nil, err
+	}
+	diff.KPIs = diffSnapshotValues("kpis.", before.KPIs, after.KPIs)
+	diff.Timeseries = diffSnapshotTimeseries(before.Timeseries, after.Timeseries)
+
+	var inBefore, inAfter map[string]any
+	if err := json.Unmarshal([]byte(it.InputsJSON), &inBefore); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(inputsJSON), &inAfter); err != nil {
+		return nil, err
+	}
+	diff.Inputs = diffSnapshotValues("inputs.", inBefore, inAfter)
+	return diff, nil
+}
+
+// computeReportSnapshot builds the report and its inputs as the JSON a snapshot
+// stores.
+func (s *Store) computeReportSnapshot(ctx context.Context, customerID string, period ReportPeriod, appVersion string) (*MonthlyReport, string, string, error) {
+	report, err := s.GetPeriodReport(ctx, customerID, period)
+	if err != nil {
+		return nil, "", "", err
+	}
+	policy, err := s.ResolveSLAPolicy(ctx, customerID)
+	if err != nil {
+		return nil, "", "", err
+	}
+	inputs := ReportSnapshotInputs{
+		AppVersion:  appVersion,
+		MappingMode: s.CustomerMappingMode(),
+		Sources:     []string{},
+		SLAPolicy:   policy,
+	}
+	trimmed := strings.TrimSpace(customerID)
+	if trimmed != "" && !strings.EqualFold(trimmed, "all") && !strings.EqualFold(trimmed, "default") {
+		members, err := s.customerMembers(ctx, trimmed)
+		if err != nil {
+			return nil, "", "", err
+		}
+		if len(members) > 1 {
+			inputs.Members = members[1:]
+		}
+		for _, member := range members {
+			mappings, err := s.GetCustomerMappings(ctx, member)
+			if err != nil {
+				return nil, "", "", err
+			}
+			inputs.Sources = append(inputs.Sources, mappings.Sources...)
+			for _, m := range mappings.Mappings {
+				if m.bounded() {
+					inputs.SourceWindows = append(inputs.SourceWindows, m)
+				}
+			}
+		}
+		inputs.Sources = normalizeSources(inputs.Sources)
+		sort.Strings(inputs.Sources)
+	}
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return nil, "", "", err
+	}
+	inputsJSON, err := json.Marshal(inputs)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return report, string(reportJSON), string(inputsJSON), nil
+}
+
+func reportSnapshotFromApp(it customermap.ReportSnapshot, withReport bool) (*ReportSnapshot, error) {
+	row := &ReportSnapshot{
+		ID:          it.ID,
+		CustomerID:  it.CustomerID,
+		Period:      ReportPeriod{Kind: it.PeriodKind, Label: it.PeriodLabel, Start: it.PeriodStart, End: it.PeriodEnd, Timezone: it.Timezone},
+		ContentHash: it.ContentHash,
+		Verified:    customermap.ReportSnapshotHash(it.ReportJSON, it.InputsJSON) == it.ContentHash,
+		Note:        it.Note,
+		Official:    it.Official,
+		CreatedAt:   it.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+	if it.OfficialAt != nil {
+		row.OfficialAt = it.OfficialAt.Format("2006-01-02T15:04:05Z")
+	}
+	if err := json.Unmarshal([]byte(it.InputsJSON), &row.Inputs); err != nil {
+		return nil, fmt.Errorf("snapshot %d has invalid inputs: %w", it.ID, err)
+	}
+	var report MonthlyReport
+	if err := json.Unmarshal([]byte(it.ReportJSON), &report); err != nil {
+		return nil, fmt.Errorf("snapshot %d has an invalid report: %w", it.ID, err)
+	}
+	row.Period.Bucket = report.Period.Bucket
+	if withReport {
+		row.Report = &report
+	}
+	return row, nil
+}
+
+// restoreReportPeriod makes a period decoded from JSON usable for queries again.
+func restoreReportPeriod(p ReportPeriod) (ReportPeriod, error) {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return ReportPeriod{}, fmt.Errorf("invalid period timezone %q", p.Timezone)
+	}
+	p.loc = loc
+	if p.Bucket == "" {
+		p.Bucket = AutoReportBucket(p.End.Sub(p.Start))
+	}
+	return p, nil
+}
+
+// diffSnapshotValues compares two decoded JSON objects key by key.
+func diffSnapshotValues(prefix string, before, after map[string]any) []ReportSnapshotChange {
+	keys := make([]string, 0, len(before)+len(after))
+	for k := range before {
+		keys = append(keys, k)
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	out := []ReportSnapshotChange{}
+	for _, k := range keys {
+		a, _ := json.Marshal(before[k])
+		b, _ := json.Marshal(after[k])
+		if string(a) == string(b) {
+			continue
+		}
+		change := ReportSnapshotChange{Field: prefix + k, Snapshot: before[k], Current: after[k]}
+		if x, ok := before[k].(float64); ok {
+			if y, ok := after[k].(float64); ok {
+				d := y - x
+				change.Delta = &d
+			}
+		}
+		out = append(out, change)
+	}
+	return out
+}
+
+func diffSnapshotTimeseries(before, after []DailyReportPoint) []ReportSnapshotChange {
+	byDate := map[string]DailyReportPoint{}
+	for _, p := range after {
+		byDate[p.Date] = p
+	}
+	out := []ReportSnapshotChange{}
+	seen := map[string]bool{}
+	add := func(date, field string, x, y int64) {
+		if x == y {
+			return
+		}
+		d := float64(y - x)
+		out = append(out, ReportSnapshotChange{Field: "timeseries." + date + "." + field, Snapshot: x, Current: y, Delta: &d})
+	}
+	for _, p := range before {
+		seen[p.Date] = true
+		cur := byDate[p.Date]
+		add(p.Date, "success", p.Success, cur.Success)
+		add(p.Date, "failed", p.Failed, cur.Failed)
+	}
+	for _, p := range after {
+		if !seen[p.Date] {
+			add(p.Date, "success", 0, p.Success)
+			add(p.Date, "failed", 0, p.Failed)
+		}
+	}
+	return out
+}
@@ -0,0 +1,82 @@
+package mysql
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+)
+
+func TestReportSnapshots_DedupAndOfficial(t *testing.T) {
+	cm, err := customermap.NewSQLiteStore(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+	s := &Store{customerMap: cm}
+	ctx := context.Background()
+
+	period := MonthPeriod(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.UTC)
+	snapshot := func(total int) customermap.ReportSnapshot {
+		return customermap.ReportSnapshot{
+			CustomerID:  "acme",
+			PeriodKind:  period.Kind,
+			PeriodLabel: period.Label,
+			PeriodStart: period.Start,
+			PeriodEnd:   period.End,
+			Timezone:    period.Timezone,
+			ReportJSON:  `{"month":"2026-03","customer_id":"acme","kpis":{"transfers_total":` + strconv.Itoa(total) + `},"period":{"bucket":"day"}}`,
+			InputsJSON:  `{"app_version":"1.2.0","mapping_mode":"sqlite","sources":["acme-ftp"],"sla_policy":{}}`,
+		}
+	}
+	first, created, err := cm.InsertReportSnapshot(ctx, snapshot(1), true)
+	if err != nil || !created || !first.Official {
+		t.Fatalf("first: %+v %v %v", first, created, err)
+	}
+	again, created, err := cm.InsertReportSnapshot(ctx, snapshot(1), false)
+	if err != nil || created || again.ID != first.ID {
+		t.Fatalf("identical content must return the existing snapshot: %+v %v %v", again, created, err)
+	}
+	second, _, err := cm.InsertReportSnapshot(ctx, snapshot(2), true)
+	if err != nil || second.ID == first.ID || !second.Official {
+		t.Fatalf("second: %+v %v", second, err)
+	}
+
+	official, err := s.ListReportSnapshots(ctx, "acme", "2026-03", true, -1)
+	if err != nil || len(official) != 1 || official[0].ID != second.ID || official[0].Report != nil {
+		t.Fatalf("expected only the second snapshot to be official: %+v %v", official, err)
+	}
+	got, err := s.SetReportSnapshotOfficial(ctx, first.ID, true)
+	if err != nil || !got.Official {
+		t.Fatalf("mark official: %+v %v", got, err)
+	}
+	if other, _ := s.GetReportSnapshot(ctx, second.ID); other.Official {
+		t.Fatal("marking a snapshot official must unmark the previous one")
+	}
+
+	item, err := s.GetReportSnapshot(ctx, first.ID)
+	if err != nil || !item.Verified || item.Report == nil || item.Report.KPIs["transfers_total"] != float64(1) || item.Inputs.AppVersion != "1.2.0" || item.Period.Bucket != "day" {
+		t.Fatalf("unexpected snapshot %+v %v", item, err)
+	}
+	if _, err := restoreReportPeriod(item.Period); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiffSnapshotValues(t *testing.T) {
+	changes := diffSnapshotValues("kpis.", map[string]any{"total": float64(10), "policy": "24h", "same": true}, map[string]any{"total": float64(8), "policy": "48h", "same": true, "new": float64(1)})
+	if len(changes) != 3 || changes[0].Field != "kpis.new" || changes[1].Field != "kpis.policy" || changes[2].Field != "kpis.total" || *changes[2].Delta != -2 || changes[1].Delta != nil {
+		t.Fatalf("unexpected changes %+v", changes)
+	}
+
+	series := diffSnapshotTimeseries(
+		[]DailyReportPoint{{Date: "2026-03-01", Success: 3, Failed: 1}, {Date: "2026-03-02", Success: 2}},
+		[]DailyReportPoint{{Date: "2026-03-01", Success: 3}, {Date: "2026-03-02", Success: 2}},
+	)
+	if len(series) != 1 || series[0].Field != "timeseries.2026-03-01.failed" || *series[0].Delta != -1 {
+		t.Fatalf("unexpected timeseries changes %+v", series)
+	}
+}
@@ -0,0 +1,278 @@
+package mysql
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report template parameter types.
+const (
+	ParamString    = "string"
+	ParamInt       = "int"
+	ParamBool      = "bool"
+	ParamCustomer  = "customer"
+	ParamStatus    = "status"
+	ParamDate      = "date"
+	ParamDateRange = "date_range"
+)
+
+// ReportTemplateParam declares a value a template config refers to as {{name}}.
+// Date ranges are referred to as {{name.from}} and {{name.to}}.
+type ReportTemplateParam struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Label    string   `json:"label,omitempty"`
+	Required bool     `json:"required,omitempty"`
+	Default  any      `json:"default,omitempty"`
+	Options  []string `json:"options,omitempty"`
+}
+
+// ReportTemplateParamTypes lists the supported parameter types.
+func ReportTemplateParamTypes() []string {
+	return []string{ParamString, ParamInt, ParamBool, ParamCustomer, ParamStatus, ParamDate, ParamDateRange}
+}
+
+var (
+	templateParamName   = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+	templatePlaceholder = regexp.MustCompile(`\{\{\s*([a-z][a-z0-9_]*)(?:\.(from|to))?\s*\}\}`)
+	templateStatuses    = []string{"all", "success", "failed", "completed_with_non_blocking_errors"}
+)
+
+// NormalizeReportTemplateParams validates parameter declarations, including their
+// defaults, and checks that every placeholder in config refers to a declared
+// parameter.
+func NormalizeReportTemplateParams(params []ReportTemplateParam, config map[string]any) ([]ReportTemplateParam, error) {
+	out := make([]ReportTemplateParam, 0, len(params))
+	byName := map[string]ReportTemplateParam{}
+	for _, p := range params {
+		p.Name = strings.TrimSpace(p.Name)
+		p.Type = strings.ToLower(strings.TrimSpace(p.Type))
+		p.Label = strings.TrimSpace(p.Label)
+		if !templateParamName.MatchString(p.Name) {
+			return nil, fmt.Errorf("invalid parameter name %q (lowercase letters, digits and underscores, starting with a letter)", p.Name)
+		}
+		if _, dup := byName[p.Name]; dup {
+			return nil, fmt.Errorf("parameter %q is declared twice", p.Name)
+		}
+		if p.Type == "" {
+			p.Type = ParamString
+		}
+		if !containsKey(ReportTemplateParamTypes(), p.Type) {
+			return nil, fmt.Errorf("parameter %q has unsupported type %q (expected one of %s)", p.Name, p.Type, strings.Join(ReportTemplateParamTypes(), ", "))
+		}
+		if len(p.Options) > 0 && p.Type != ParamString {
+			return nil, fmt.Errorf("parameter %q: options are only supported for string parameters", p.Name)
+		}
+		if p.Default != nil {
+			if _, err := resolveTemplateParam(p, p.Default, time.Now(), time.UTC, 1); err != nil {
+				return nil, fmt.Errorf("default of %w", err)
+			}
+		}
+		byName[p.Name] = p
+		out = append(out, p)
+	}
+
+	var check func(v any) error
+	check = func(v any) error {
+		switch t := v.(type) {
+		case map[string]any:
+			for _, child := range t {
+				if err := check(child); err != nil {
+					return err
+				}
+			}
+		case []any:
+			for _, child := range t {
+				if err := check(child); err != nil {
+					return err
+				}
+			}
+		case string:
+			for _, m := range templatePlaceholder.FindAllStringSubmatch(t, -1) {
+				p, ok := byName[m[1]]
+				if !ok {
+					return fmt.Errorf("config refers to undeclared parameter %q", m[1])
+				}
+				if (p.Type == ParamDateRange) != (m[2] != "") {
+					return fmt.Errorf("placeholder %s: date_range parameters are used as {{%s.from}} and {{%s.to}}, other parameters as {{%s}}", m[0], p.Name, p.Name, p.Name)
+				}
+			}
+		}
+		return nil
+	}
+	if err := check(config); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ResolveReportTemplateConfig substitutes parameter values into the config of tpl.
+// values override the declared defaults; relative date ranges such as last_month
+// resolve against at in loc. A placeholder that is a whole JSON string is replaced
+// by the typed value, one inside a longer string by its text. It returns the
+// resolved config and the value used for each parameter.
+func ResolveReportTemplateConfig(tpl ReportTemplate, values map[string]any, at time.Time, loc *time.Location, fiscalStartMonth int) ([]byte, map[string]any, error) {
+	byName := map[string]ReportTemplateParam{}
+	for _, p := range tpl.Params {
+		byName[p.Name] = p
+	}
+	for name := range values {
+		if _, ok := byName[name]; !ok {
+			return nil, nil, fmt.Errorf("template has no parameter %q", name)
+		}
+	}
+	resolved := map[string]any{}
+	for _, p := range tpl.Params {
+		raw, ok := values[p.Name]
+		if !ok || raw == nil {
+			raw = p.Default
+		}
+		v, err := resolveTemplateParam(p, raw, at, loc, fiscalStartMonth)
+		if err != nil {
+			return nil, nil, err
+		}
+		resolved[p.Name] = v
+	}
+
+	var config any
+	if err := json.Unmarshal([]byte(tpl.ConfigJSON), &config); err != nil {
+		return nil, nil, fmt.Errorf("template has an invalid config: %w", err)
+	}
+	lookup := func(name, part string) any {
+		v := resolved[name]
+		if r, ok := v.(map[string]any); ok && part != "" {
+			return r[part]
+		}
+		return v
+	}
+	var substitute func(v any) any
+	substitute = func(v any) any {
+		switch t := v.(type) {
+		case map[string]any:
+			for k, child := range t {
+				t[k] = substitute(child)
+			}
+			return t
+		case []any:
+			for i, child := range t {
+				t[i] = substitute(child)
+			}
+			return t
+		case string:
+			if m := templatePlaceholder.FindStringSubmatch(t); m != nil && m[0] == t {
+				return lookup(m[1], m[2])
+			}
+			return templatePlaceholder.ReplaceAllStringFunc(t, func(s string) string {
+				m := templatePlaceholder.FindStringSubmatch(s)
+				if v := lookup(m[1], m[2]); v != nil {
+					return fmt.Sprint(v)
+				}
+				return ""
+			})
+		}
+		return v
+	}
+	out, err := json.Marshal(substitute(config))
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, resolved, nil
+}
+
+// resolveTemplateParam checks a parameter value against its type. Date ranges
+// resolve to {"from", "to", "label"} with RFC3339 bounds, to being exclusive.
+func resolveTemplateParam(p ReportTemplateParam, raw any, at time.Time, loc *time.Location, fiscalStartMonth int) (any, error) {
+	if s, ok := raw.(string); ok && strings.TrimSpace(s) == "" {
+		raw = nil
+	}
+	if raw == nil {
+		if p.Required {
+			return nil, fmt.Errorf("parameter %q is required", p.Name)
+		}
+		return nil, nil
+	}
+	switch p.Type {
+	case ParamInt:
+		switch t := raw.(type) {
+		case float64:
+			if t == math.Trunc(t) {
+				return int64(t), nil
+			}
+		case int:
+			return int64(t), nil
+		case int64:
+			return t, nil
+		case string:
+			if n, err := strconv.ParseInt(strings.TrimSpace(t), 10, 64); err == nil {
+				return n, nil
+			}
+		}
+		return nil, fmt.Errorf("parameter %q must be an integer", p.Name)
+	case ParamBool:
+		switch t := raw.(type) {
+		case bool:
+			return t, nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(t)); err == nil {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("parameter %q must be true or false", p.Name)
+	}
+
+	s, ok := raw.(string)
+	if !ok {
+		return nil, fmt.Errorf("parameter %q must be a string", p.Name)
+	}
+	s = strings.TrimSpace(s)
+	switch p.Type {
+	case ParamStatus:
+		s = strings.ToLower(s)
+		if !containsKey(templateStatuses, s) {
+			return nil, fmt.Errorf("parameter %q must be one of %s", p.Name, strings.Join(templateStatuses, ", "))
+		}
+	case ParamDate:
+		if _, err := time.ParseInLocation("2006-01-02", s, loc); err != nil {
+			return nil, fmt.Errorf("parameter %q must be a date (YYYY-MM-DD)", p.Name)
+		}
+	case ParamDateRange:
+		return resolveTemplateDateRange(p, s, at, loc, fiscalStartMonth)
+	case ParamString:
+		if len(p.Options) > 0 && !containsKey(p.Options, s) {
+			return nil, fmt.Errorf("parameter %q must be one of %s", p.Name, strings.Join(p.Options, ", "))
+		}
+	}
+	return s, nil
+}
+
+// resolveTemplateDateRange accepts a relative range name or an inclusive
+// YYYY-MM-DD..YYYY-MM-DD range.
+func resolveTemplateDateRange(p ReportTemplateParam, s string, at time.Time, loc *time.Location, fiscalStartMonth int) (any, error) {
+	var (
+		period ReportPeriod
+		err    error
+	)
+	if from, to, explicit := strings.Cut(s, ".."); explicit {
+		start, errFrom := time.ParseInLocation("2006-01-02", strings.TrimSpace(from), loc)
+		end, errTo := time.ParseInLocation("2006-01-02", strings.TrimSpace(to), loc)
+		if errFrom != nil || errTo != nil {
+			return nil, fmt.Errorf("parameter %q: expected YYYY-MM-DD..YYYY-MM-DD or a relative range", p.Name)
+		}
+		period, err = CustomReportPeriod(start, end.AddDate(0, 0, 1), loc)
+	} else {
+		period, err = RelativeReportPeriod(s, at, loc, fiscalStartMonth)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parameter %q: %w", p.Name, err)
+	}
+	return map[string]any{
+		"from":  period.Start.Format(time.RFC3339),
+		"to":    period.End.Format(time.RFC3339),
+		"label": s,
+	}, nil
+}
@@ -0,0 +1,126 @@
+package mysql
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeReportTemplateParams(t *testing.T) {
+	config := map[string]any{
+		"date_from":   "{{period.from}}",
+		"date_to":     "{{period.to}}",
+		"customer_id": "{{customer}}",
+		"filter":      "status = {{ status }}",
+	}
+	params := []ReportTemplateParam{
+		{Name: "period", Type: "DATE_RANGE", Default: "last_month"},
+		{Name: "customer", Type: ParamCustomer, Required: true},
+		{Name: "status", Type: ParamStatus, Default: "all"},
+	}
+	out, err := NormalizeReportTemplateParams(params, config)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if out[0].Type != ParamDateRange {
+		t.Fatalf("type not normalized: %+v", out[0])
+	}
+
+	cases := []struct {
+		params []ReportTemplateParam
+		config map[string]any
+		want   string
+	}{
+		{[]ReportTemplateParam{{Name: "Customer"}}, nil, "invalid parameter name"},
+		{[]ReportTemplateParam{{Name: "a"}, {Name: "a"}}, nil, "declared twice"},
+		{[]ReportTemplateParam{{Name: "a", Type: "uuid"}}, nil, "unsupported type"},
+		{[]ReportTemplateParam{{Name: "a", Type: ParamInt, Default: "many"}}, nil, "must be an integer"},
+		{[]ReportTemplateParam{{Name: "a", Type: ParamDateRange, Default: "next_month"}}, nil, "unsupported relative range"},
+		{[]ReportTemplateParam{{Name: "a", Type: ParamStatus, Options: []string{"x"}}}, nil, "options"},
+		{nil, map[string]any{"customer_id": "{{customer}}"}, "undeclared parameter"},
+		{[]ReportTemplateParam{{Name: "period", Type: ParamDateRange}}, map[string]any{"date_from": "{{period}}"}, "{{period.from}}"},
+		{[]ReportTemplateParam{{Name: "customer"}}, map[string]any{"x": []any{"{{customer.from}}"}}, "{{customer}}"},
+	}
+	for _, tc := range cases {
+		_, err := NormalizeReportTemplateParams(tc.params, tc.config)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%+v %v: expected error containing %q, got %v", tc.params, tc.config, tc.want, err)
+		}
+	}
+}
+
+func TestResolveReportTemplateConfig(t *testing.T) {
+	tpl := ReportTemplate{
+		ConfigJSON: `{"date_from":"{{period.from}}","date_to":"{{period.to}}","customer_id":"{{customer}}","status":"{{status}}","limit":"{{rows}}","filter":"customer = {{customer}}","columns":["uuid"]}`,
+		Params: []ReportTemplateParam{
+			{Name: "period", Type: ParamDateRange, Default: "last_month"},
+			{Name: "customer", Type: ParamCustomer},
+			{Name: "status", Type: ParamStatus, Default: "failed"},
+			{Name: "rows", Type: ParamInt, Default: float64(50)},
+		},
+	}
+	at := time.Date(2026, 4, 2, 8, 0, 0, 0, time.UTC)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("tzdata not available")
+	}
+
+	raw, resolved, err := ResolveReportTemplateConfig(tpl, map[string]any{"customer": "acme", "rows": "200"}, at, berlin, 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["date_from"] != "2026-02-28T23:00:00Z" || got["date_to"] != "2026-03-31T22:00:00Z" {
+		t.Fatalf("unexpected range %v..%v", got["date_from"], got["date_to"])
+	}
+	if got["customer_id"] != "acme" || got["status"] != "failed" || got["limit"] != float64(200) || got["filter"] != "customer = acme" {
+		t.Fatalf("unexpected config %s", raw)
+	}
+	if resolved["rows"] != int64(200) {
+		t.Fatalf("unexpected resolved values %v", resolved)
+	}
+
+	raw, _, err = ResolveReportTemplateConfig(tpl, map[string]any{"period": "2026-01-01..2026-01-31"}, at, time.UTC, 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !strings.Contains(string(raw), `"date_from":"2026-01-01T00:00:00Z","date_to":"2026-02-01T00:00:00Z"`) || !strings.Contains(string(raw), `"customer_id":null`) {
+		t.Fatalf("unexpected config %s", raw)
+	}
+
+	for _, values := range []map[string]any{
+		{"region": "eu"},
+		{"status": "broken"},
+		{"period": "2026-01-31..2026-01-01"},
+		{"rows": 1.5},
+	} {
+		if _, _, err := ResolveReportTemplateConfig(tpl, values, at, time.UTC, 1); err == nil {
+			t.Fatalf("expected %v to be rejected", values)
+		}
+	}
+	tpl.Params[1].Required = true
+	if _, _, err := ResolveReportTemplateConfig(tpl, nil, at, time.UTC, 1); err == nil || !strings.Contains(err.Error(), "required") {
+		t.Fatalf("expected missing required parameter to be rejected, got %v", err)
+	}
+}
+
+func TestDiffReportTemplates(t *testing.T) {
+	from := ReportTemplate{Description: "a", Scope: "transfer", ConfigJSON: `{"limit":50,"status":"all"}`}
+	to := ReportTemplate{Description: "a", Scope: "transfer", ConfigJSON: `{"limit":100,"filter":"x"}`, Params: []ReportTemplateParam{{Name: "customer", Type: ParamCustomer}}}
+
+	changes := DiffReportTemplates(from, to)
+	fields := make([]string, 0, len(changes))
+	for _, c := range changes {
+		fields = append(fields, c.Field)
+	}
+	if got := strings.Join(fields, ","); got != "config.filter,config.limit,config.status,params" {
+		t.Fatalf("unexpected changes %s", got)
+	}
+	if len(DiffReportTemplates(from, from)) != 0 {
+		t.Fatal("expected no changes between identical versions")
+	}
+}
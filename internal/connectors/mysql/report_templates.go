@@ -2,21 +2,68 @@ package mysql
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
 )
 
-// ReportTemplate is an app-owned saved report definition in SQLite.
+// ReportTemplate is an app-owned saved report definition in SQLite. Version is
+// the current version for templates and the snapshot's version for entries of a
+// template's history.
 type ReportTemplate struct {
-	ID          int64  `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Scope       string `json:"scope"`
-	ConfigJSON  string `json:"config_json"`
-	CreatedAt   string `json:"created_at,omitempty"`
-	UpdatedAt   string `json:"updated_at,omitempty"`
+	ID          int64                 `json:"id"`
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	Scope       string                `json:"scope"`
+	ConfigJSON  string                `json:"config_json"`
+	Params      []ReportTemplateParam `json:"params"`
+	Version     int                   `json:"version"`
+	CreatedAt   string                `json:"created_at,omitempty"`
+	UpdatedAt   string                `json:"updated_at,omitempty"`
+}
+
+// ReportTemplateChange is one difference between two template versions; config
+// changes are reported per top-level key as config.<key>.
+type ReportTemplateChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// ReportTemplateBundleFormat identifies template export files.
+const ReportTemplateBundleFormat = "am-ops-observer/report-templates"
+
+// ReportTemplateBundle is a portable set of templates for moving them between
+// installations. Templates are matched by name on import.
+type ReportTemplateBundle struct {
+	Format     string                     `json:"format"`
+	Version    int                        `json:"version"`
+	ExportedAt time.Time                  `json:"exported_at"`
+	Templates  []ReportTemplateBundleItem `json:"templates"`
+}
+
+// ReportTemplateBundleItem is one template of a bundle; Version is the version it
+// had where it was exported.
+type ReportTemplateBundleItem struct {
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	Scope       string                `json:"scope"`
+	Config      map[string]any        `json:"config"`
+	Params      []ReportTemplateParam `json:"params"`
+	Version     int                   `json:"version,omitempty"`
+}
+
+// ReportTemplateImportResult tells what importing one bundle template did.
+type ReportTemplateImportResult struct {
+	Name    string `json:"name"`
+	Outcome string `json:"outcome"`
+	ID      int64  `json:"id,omitempty"`
+	Version int    `json:"version,omitempty"`
 }
 
 var errTemplateStoreUnavailable = errors.New("template sqlite store not configured")
@@ -32,6 +79,15 @@ func (s *Store) HasTemplateStore() bool {
 	return s != nil && s.customerMap != nil
 }
 
+// AppStore returns the app SQLite store, or nil when APP_CUSTOMER_MAP_SQLITE_PATH is
+// not set. Report jobs keep their queue in it.
+func (s *Store) AppStore() *customermap.Store {
+	if s == nil {
+		return nil
+	}
+	return s.customerMap
+}
+
 func (s *Store) ListReportTemplates(ctx context.Context, limit int) ([]ReportTemplate, error) {
 	store, err := s.templateStore()
 	if err != nil {
@@ -43,61 +99,286 @@ func (s *Store) ListReportTemplates(ctx context.Context, limit int) ([]ReportTem
 	}
 	out := make([]ReportTemplate, 0, len(items))
 	for _, it := range items {
-		row := ReportTemplate{
-			ID:          it.ID,
+		out = append(out, reportTemplateFromApp(it))
+	}
+	return out, nil
+}
+
+func (s *Store) GetReportTemplate(ctx context.Context, id int64) (*ReportTemplate, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	it, err := store.GetReportTemplate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	out := reportTemplateFromApp(*it)
+	return &out, nil
+}
+
+// UpsertReportTemplate saves the template named name after validating its scope,
+// parameters and the placeholders of config. It returns the saved template and
+// whether it was created, updated (a new version) or unchanged.
+func (s *Store) UpsertReportTemplate(ctx context.Context, name, description, scope string, config map[string]any, params []ReportTemplateParam) (*ReportTemplate, string, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, "", err
+	}
+	item, err := newAppReportTemplate(name, description, scope, config, params)
+	if err != nil {
+		return nil, "", err
+	}
+	saved, outcome, err := store.UpsertReportTemplate(ctx, item)
+	if err != nil {
+		return nil, "", err
+	}
+	out := reportTemplateFromApp(*saved)
+	return &out, outcome, nil
+}
+
+func (s *Store) DeleteReportTemplate(ctx context.Context, id int64) (int64, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return 0, err
+	}
+	return store.DeleteReportTemplate(ctx, id)
+}
+
+// ListReportTemplateVersions returns the history of a template, newest first.
+func (s *Store) ListReportTemplateVersions(ctx context.Context, id int64) ([]ReportTemplate, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	items, err := store.ListReportTemplateVersions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ReportTemplate, 0, len(items))
+	for _, it := range items {
+		out = append(out, reportTemplateFromVersion(it))
+	}
+	return out, nil
+}
+
+// GetReportTemplateVersion returns one version of a template.
+func (s *Store) GetReportTemplateVersion(ctx context.Context, id int64, version int) (*ReportTemplate, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	it, err := store.GetReportTemplateVersion(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	out := reportTemplateFromVersion(*it)
+	return &out, nil
+}
+
+// ExportReportTemplates bundles the given templates, or all templates when ids is
+// empty.
+func (s *Store) ExportReportTemplates(ctx context.Context, ids []int64) (*ReportTemplateBundle, error) {
+	items, err := s.ListReportTemplates(ctx, -1)
+	if err != nil {
+		return nil, err
+	}
+	wanted := map[int64]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	bundle := &ReportTemplateBundle{Format: ReportTemplateBundleFormat, Version: 1, ExportedAt: time.Now().UTC(), Templates: []ReportTemplateBundleItem{}}
+	for _, it := range items {
+		if len(wanted) > 0 && !wanted[it.ID] {
+			continue
+		}
+		delete(wanted, it.ID)
+		var config map[string]any
+		if err := json.Unmarshal([]byte(it.ConfigJSON), &config); err != nil {
+			return nil, fmt.Errorf("template %q has an invalid config: %w", it.Name, err)
+		}
+		bundle.Templates = append(bundle.Templates, ReportTemplateBundleItem{
 			Name:        it.Name,
 			Description: it.Description,
 			Scope:       it.Scope,
-			ConfigJSON:  it.ConfigJSON,
+			Config:      config,
+			Params:      it.Params,
+			Version:     it.Version,
+		})
+	}
+	if len(wanted) > 0 {
+		missing := make([]string, 0, len(wanted))
+		for id := range wanted {
+			missing = append(missing, fmt.Sprint(id))
 		}
-		if it.CreatedAt != nil {
-			row.CreatedAt = it.CreatedAt.UTC().Format("2006-01-02T15:04:05Z")
+		sort.Strings(missing)
+		return nil, fmt.Errorf("templates not found: %s", strings.Join(missing, ", "))
+	}
+	return bundle, nil
+}
+
+// ImportReportTemplates saves the templates of a bundle by name. Every template is
+// validated before any is saved; with dryRun nothing is saved and the outcomes
+// tell what an import would do.
+func (s *Store) ImportReportTemplates(ctx context.Context, bundle ReportTemplateBundle, dryRun bool) ([]ReportTemplateImportResult, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	if bundle.Format != ReportTemplateBundleFormat {
+		return nil, fmt.Errorf("not a report template bundle, expected format %q", ReportTemplateBundleFormat)
+	}
+	if bundle.Version != 1 {
+		return nil, fmt.Errorf("unsupported bundle version %d", bundle.Version)
+	}
+	items := make([]customermap.ReportTemplate, 0, len(bundle.Templates))
+	seen := map[string]bool{}
+	for i, t := range bundle.Templates {
+		item, err := newAppReportTemplate(t.Name, t.Description, t.Scope, t.Config, t.Params)
+		if err != nil {
+			return nil, fmt.Errorf("template %d (%q): %w", i+1, t.Name, err)
+		}
+		if seen[item.Name] {
+			return nil, fmt.Errorf("template %q appears twice in the bundle", item.Name)
+		}
+		seen[item.Name] = true
+		items = append(items, item)
+	}
+
+	existing := map[string]customermap.ReportTemplate{}
+	if dryRun {
+		current, err := store.ListReportTemplates(ctx, -1)
+		if err != nil {
+			return nil, err
 		}
-		if it.UpdatedAt != nil {
-			row.UpdatedAt = it.UpdatedAt.UTC().Format("2006-01-02T15:04:05Z")
+		for _, it := range current {
+			existing[it.Name] = it
 		}
-		out = append(out, row)
+	}
+	out := make([]ReportTemplateImportResult, 0, len(items))
+	for _, item := range items {
+		if dryRun {
+			res := ReportTemplateImportResult{Name: item.Name, Outcome: customermap.TemplateCreated}
+			if cur, ok := existing[item.Name]; ok {
+				res.ID, res.Version, res.Outcome = cur.ID, cur.Version, customermap.TemplateUnchanged
+				if cur.Description != item.Description || cur.Scope != item.Scope || cur.ConfigJSON != item.ConfigJSON || cur.ParamsJSON != item.ParamsJSON {
+					res.Outcome = customermap.TemplateUpdated
+				}
+			}
+			out = append(out, res)
+			continue
+		}
+		saved, outcome, err := store.UpsertReportTemplate(ctx, item)
+		if err != nil {
+			return out, fmt.Errorf("template %q: %w", item.Name, err)
+		}
+		out = append(out, ReportTemplateImportResult{Name: saved.Name, Outcome: outcome, ID: saved.ID, Version: saved.Version})
 	}
 	return out, nil
 }
 
-func (s *Store) GetReportTemplate(ctx context.Context, id int64) (*ReportTemplate, error) {
-	store, err := s.templateStore()
+// DiffReportTemplates lists what changed from one template version to another.
+func DiffReportTemplates(from, to ReportTemplate) []ReportTemplateChange {
+	changes := []ReportTemplateChange{}
+	if from.Description != to.Description {
+		changes = append(changes, ReportTemplateChange{Field: "description", From: from.Description, To: to.Description})
+	}
+	if from.Scope != to.Scope {
+		changes = append(changes, ReportTemplateChange{Field: "scope", From: from.Scope, To: to.Scope})
+	}
+	var fromConfig, toConfig map[string]any
+	_ = json.Unmarshal([]byte(from.ConfigJSON), &fromConfig)
+	_ = json.Unmarshal([]byte(to.ConfigJSON), &toConfig)
+	keys := make([]string, 0, len(fromConfig)+len(toConfig))
+	for k := range fromConfig {
+		keys = append(keys, k)
+	}
+	for k := range toConfig {
+		if _, ok := fromConfig[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		a, _ := json.Marshal(fromConfig[k])
+		b, _ := json.Marshal(toConfig[k])
+		if string(a) != string(b) {
+			changes = append(changes, ReportTemplateChange{Field: "config." + k, From: fromConfig[k], To: toConfig[k]})
+		}
+	}
+	a, _ := json.Marshal(from.Params)
+	b, _ := json.Marshal(to.Params)
+	if string(a) != string(b) {
+		changes = append(changes, ReportTemplateChange{Field: "params", From: from.Params, To: to.Params})
+	}
+	return changes
+}
+
+func newAppReportTemplate(name, description, scope string, config map[string]any, params []ReportTemplateParam) (customermap.ReportTemplate, error) {
+	scope, err := NormalizeReportScope(scope)
 	if err != nil {
-		return nil, err
+		return customermap.ReportTemplate{}, err
 	}
-	it, err := store.GetReportTemplate(ctx, id)
+	if config == nil {
+		config = map[string]any{}
+	}
+	params, err = NormalizeReportTemplateParams(params, config)
 	if err != nil {
-		return nil, err
+		return customermap.ReportTemplate{}, err
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return customermap.ReportTemplate{}, fmt.Errorf("invalid template config: %w", err)
+	}
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return customermap.ReportTemplate{}, fmt.Errorf("invalid template params: %w", err)
 	}
-	out := &ReportTemplate{
+	return customermap.ReportTemplate{
+		Name:        strings.TrimSpace(name),
+		Description: strings.TrimSpace(description),
+		Scope:       scope,
+		ConfigJSON:  string(configJSON),
+		ParamsJSON:  string(paramsJSON),
+	}, nil
+}
+
+func reportTemplateFromApp(it customermap.ReportTemplate) ReportTemplate {
+	row := ReportTemplate{
 		ID:          it.ID,
 		Name:        it.Name,
 		Description: it.Description,
 		Scope:       it.Scope,
 		ConfigJSON:  it.ConfigJSON,
+		Params:      decodeReportTemplateParams(it.ParamsJSON),
+		Version:     it.Version,
 	}
 	if it.CreatedAt != nil {
-		out.CreatedAt = it.CreatedAt.UTC().Format("2006-01-02T15:04:05Z")
+		row.CreatedAt = it.CreatedAt.UTC().Format("2006-01-02T15:04:05Z")
 	}
 	if it.UpdatedAt != nil {
-		out.UpdatedAt = it.UpdatedAt.UTC().Format("2006-01-02T15:04:05Z")
+		row.UpdatedAt = it.UpdatedAt.UTC().Format("2006-01-02T15:04:05Z")
 	}
-	return out, nil
+	return row
 }
 
-func (s *Store) UpsertReportTemplate(ctx context.Context, name, description, scope, configJSON string) (int64, error) {
-	store, err := s.templateStore()
-	if err != nil {
-		return 0, err
+func reportTemplateFromVersion(it customermap.ReportTemplateVersion) ReportTemplate {
+	return ReportTemplate{
+		ID:          it.TemplateID,
+		Name:        it.Name,
+		Description: it.Description,
+		Scope:       it.Scope,
+		ConfigJSON:  it.ConfigJSON,
+		Params:      decodeReportTemplateParams(it.ParamsJSON),
+		Version:     it.Version,
+		CreatedAt:   it.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
 	}
-	return store.UpsertReportTemplate(ctx, strings.TrimSpace(name), strings.TrimSpace(description), strings.TrimSpace(scope), strings.TrimSpace(configJSON))
 }
 
-func (s *Store) DeleteReportTemplate(ctx context.Context, id int64) (int64, error) {
-	store, err := s.templateStore()
-	if err != nil {
-		return 0, err
+func decodeReportTemplateParams(raw string) []ReportTemplateParam {
+	params := []ReportTemplateParam{}
+	if strings.TrimSpace(raw) != "" {
+		_ = json.Unmarshal([]byte(raw), &params)
 	}
-	return store.DeleteReportTemplate(ctx, id)
+	return params
 }
@@ -0,0 +1,80 @@
+package mysql
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+)
+
+func TestReportTemplates_VersionsAndBundles(t *testing.T) {
+	cm, err := customermap.NewSQLiteStore(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+	s := &Store{customerMap: cm}
+	ctx := context.Background()
+
+	params := []ReportTemplateParam{{Name: "customer", Type: ParamCustomer}}
+	config := map[string]any{"customer_id": "{{customer}}", "limit": 50}
+	v1, outcome, err := s.UpsertReportTemplate(ctx, "Failed", "", "transfer", config, params)
+	if err != nil || outcome != customermap.TemplateCreated || v1.Version != 1 {
+		t.Fatalf("create: %+v %s %v", v1, outcome, err)
+	}
+	if _, outcome, _ = s.UpsertReportTemplate(ctx, "Failed", "", "transfer", config, params); outcome != customermap.TemplateUnchanged {
+		t.Fatalf("expected saving the same content to keep the version, got %s", outcome)
+	}
+	config["limit"] = 100
+	v2, outcome, err := s.UpsertReportTemplate(ctx, "Failed", "all failures", "transfer", config, params)
+	if err != nil || outcome != customermap.TemplateUpdated || v2.ID != v1.ID || v2.Version != 2 {
+		t.Fatalf("update: %+v %s %v", v2, outcome, err)
+	}
+	if _, _, err := s.UpsertReportTemplate(ctx, "Broken", "", "transfer", map[string]any{"customer_id": "{{who}}"}, nil); err == nil {
+		t.Fatal("expected a placeholder without parameter to be rejected")
+	}
+
+	versions, err := s.ListReportTemplateVersions(ctx, v1.ID)
+	if err != nil || len(versions) != 2 || versions[0].Version != 2 || versions[1].ConfigJSON != `{"customer_id":"{{customer}}","limit":50}` {
+		t.Fatalf("unexpected versions %+v %v", versions, err)
+	}
+	old, err := s.GetReportTemplateVersion(ctx, v1.ID, 1)
+	if err != nil || len(old.Params) != 1 || old.Description != "" {
+		t.Fatalf("unexpected version 1 %+v %v", old, err)
+	}
+
+	bundle, err := s.ExportReportTemplates(ctx, nil)
+	if err != nil || len(bundle.Templates) != 1 || bundle.Templates[0].Version != 2 {
+		t.Fatalf("unexpected bundle %+v %v", bundle, err)
+	}
+	if _, err := s.ExportReportTemplates(ctx, []int64{v1.ID, 99}); err == nil {
+		t.Fatal("expected unknown template ids to be rejected")
+	}
+
+	bundle.Templates = append(bundle.Templates, ReportTemplateBundleItem{Name: "Weekly", Scope: "sip", Config: map[string]any{}})
+	bundle.Templates[0].Description = "changed in staging"
+	results, err := s.ImportReportTemplates(ctx, *bundle, true)
+	if err != nil || len(results) != 2 || results[0].Outcome != customermap.TemplateUpdated || results[1].Outcome != customermap.TemplateCreated {
+		t.Fatalf("unexpected dry run %+v %v", results, err)
+	}
+	if items, _ := s.ListReportTemplates(ctx, -1); len(items) != 1 {
+		t.Fatalf("dry run must not save, got %+v", items)
+	}
+	results, err = s.ImportReportTemplates(ctx, *bundle, false)
+	if err != nil || results[0].Version != 3 || results[1].Version != 1 {
+		t.Fatalf("unexpected import %+v %v", results, err)
+	}
+
+	bundle.Format = "something-else"
+	if _, err := s.ImportReportTemplates(ctx, *bundle, true); err == nil {
+		t.Fatal("expected a foreign bundle to be rejected")
+	}
+
+	if deleted, err := s.DeleteReportTemplate(ctx, v1.ID); err != nil || deleted != 1 {
+		t.Fatalf("delete: %d %v", deleted, err)
+	}
+	if versions, _ := s.ListReportTemplateVersions(ctx, v1.ID); len(versions) != 0 {
+		t.Fatalf("versions must be deleted with the template, got %+v", versions)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"math"
 	"sort"
 	"time"
+
+	"go-am-realtime-report-ui/internal/dbctx"
 )
 
 // DailyReportPoint is an aggregated day bucket for monthly reports.
@@ -19,9 +21,11 @@ type DailyReportPoint struct {
 // MonthlyReport contains KPI summary and chart-ready timeseries.
 type MonthlyReport struct {
 	Month      string             `json:"month"`
+	Period     ReportPeriod       `json:"period"`
 	CustomerID string             `json:"customer_id"`
 	KPIs       map[string]any     `json:"kpis"`
 	Timeseries []DailyReportPoint `json:"timeseries"`
+	SLA        *SLACompliance     `json:"sla"`
 }
 
 // DurationChartPoint is a day bucket used directly by frontend graphs.
@@ -33,13 +37,19 @@ type DurationChartPoint struct {
 	P95Seconds int64  `json:"p95_seconds"`
 }
 
-// GetMonthlyReport builds a real report from Transfers/Jobs/Tasks/Files tables.
+// GetMonthlyReport builds a real report from Transfers/Jobs/Tasks/Files tables
+// for one calendar month in UTC.
 func (s *Store) GetMonthlyReport(ctx context.Context, customerID string, month time.Time) (*MonthlyReport, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	return s.GetPeriodReport(ctx, customerID, MonthPeriod(month, time.UTC))
+}
+
+// GetPeriodReport builds the monthly report KPIs for an arbitrary period, with the
+// timeseries bucketed by the period's bucket in its timezone.
+func (s *Store) GetPeriodReport(ctx context.Context, customerID string, period ReportPeriod) (*MonthlyReport, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
-	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
-	end := start.AddDate(0, 1, 0)
+	start, end := period.Start, period.End
 
 	filterClause, args, err := s.sourceFilterClause(ctx, customerID)
 	if err != nil {
@@ -63,11 +73,14 @@ WHERE t.completed_at >= ?
 		return nil, err
 	}
 
-	durations, err := s.transferDurationsForMonth(ctx, start, end, filterClause, args)
+	samples, err := s.transferDurationSamples(ctx, start, end, filterClause, args)
 	if err != nil {
 		return nil, err
 	}
-	avg, p50, p95 := durationStats(durations)
+	durations := make([]int64, 0, len(samples))
+	for _, sm := range samples {
+		durations = append(durations, sm.Seconds)
+	}
 
 	filesQuery := fmt.Sprintf(`
 SELECT
@@ -100,15 +113,13 @@ WHERE t.completed_at >= ?
 
 	timeseriesQuery := fmt.Sprintf(`
 SELECT
-  DATE(t.completed_at) AS d,
-  SUM(CASE WHEN t.status IN (2, 3) THEN 1 ELSE 0 END) AS success_count,
-  SUM(CASE WHEN t.status = 4 THEN 1 ELSE 0 END) AS failed_count
+  t.completed_at,
+  t.status
 FROM Transfers t
 WHERE t.completed_at >= ?
   AND t.completed_at < ?
-  %s
-GROUP BY DATE(t.completed_at)
-ORDER BY DATE(t.completed_at);
+  AND t.status IN (2, 3, 4)
+  %s;
 `, filterClause)
 
 	timeseriesArgs := append([]any{start, end}, args...)
@@ -118,38 +129,51 @@ ORDER BY DATE(t.completed_at);
 	}
 	defer rows.Close()
 
-	series := make([]DailyReportPoint, 0)
+	byBucket := map[string]*DailyReportPoint{}
 	for rows.Next() {
 		var (
-			day     time.Time
-			success sql.NullInt64
-			failed  sql.NullInt64
+			completedAt time.Time
+			status      sql.NullInt64
 		)
-		if err := rows.Scan(&day, &success, &failed); err != nil {
+		if err := rows.Scan(&completedAt, &status); err != nil {
 			return nil, err
 		}
-		series = append(series, DailyReportPoint{
-			Date:    day.Format("2006-01-02"),
-			Success: nullInt64Value(success),
-			Failed:  nullInt64Value(failed),
-		})
+		key := period.BucketKey(completedAt)
+		point, ok := byBucket[key]
+		if !ok {
+			point = &DailyReportPoint{Date: key}
+			byBucket[key] = point
+		}
+		if nullInt64Value(status) == 4 {
+			point.Failed++
+		} else {
+			point.Success++
+		}
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	defaultSLASeconds := int64(24 * 60 * 60)
-	onTime := int64(0)
-	for _, d := range durations {
-		if d <= defaultSLASeconds {
-			onTime++
+	series := make([]DailyReportPoint, 0, len(byBucket))
+	for _, b := range period.BucketStarts() {
+		key := period.BucketKey(b)
+		if point, ok := byBucket[key]; ok {
+			series = append(series, *point)
+			continue
 		}
+		series = append(series, DailyReportPoint{Date: key})
+	}
+
+	policy, err := s.ResolveSLAPolicy(ctx, customerID)
+	if err != nil {
+		return nil, err
 	}
-	slaPercent := 0.0
-	if len(durations) > 0 {
-		slaPercent = round2((float64(onTime) / float64(len(durations))) * 100)
+	sla, err := s.slaCompliance(ctx, policy, period, filterClause, args, monthlyMaxBreaches)
+	if err != nil {
+		return nil, err
 	}
+	sla.CustomerID = customerID
 
 	backlogQuery := fmt.Sprintf(`
 SELECT COUNT(*)
@@ -162,31 +186,92 @@ WHERE t.status = 1
 		return nil, err
 	}
 
+	counts := periodCounts{
+		Total:           nullInt64Value(total),
+		Success:         nullInt64Value(success),
+		Failed:          nullInt64Value(failed),
+		FilesTotal:      nullInt64Value(filesTotal),
+		FilesOriginal:   nullInt64Value(filesOriginal),
+		FilesNormalized: nullInt64Value(filesNormalized),
+	}
 	report := &MonthlyReport{
-		Month:      start.Format("2006-01"),
+		Month:      period.Label,
+		Period:     period,
 		CustomerID: customerID,
-		KPIs: map[string]any{
-			"transfers_total":      nullInt64Value(total),
-			"transfers_success":    nullInt64Value(success),
-			"transfers_failed":     nullInt64Value(failed),
-			"avg_processing_sec":   avg,
-			"p50_processing_sec":   p50,
-			"p95_processing_sec":   p95,
-			"files_total":          nullInt64Value(filesTotal),
-			"files_original":       nullInt64Value(filesOriginal),
-			"files_normalized":     nullInt64Value(filesNormalized),
-			"sla_on_time_percent":  slaPercent,
-			"backlog_end_of_month": nullInt64Value(backlog),
-		},
+		KPIs:       reportKPIs(counts, durations, sla, policy, nullInt64Value(backlog)),
 		Timeseries: series,
+		SLA:        sla,
 	}
 
 	return report, nil
 }
 
-func (s *Store) transferDurationsForMonth(ctx context.Context, start, end time.Time, filterClause string, filterArgs []any) ([]int64, error) {
+// periodCounts are the transfer and file counts of one report period.
+type periodCounts struct {
+	Total           int64
+	Success         int64
+	Failed          int64
+	FilesTotal      int64
+	FilesOriginal   int64
+	FilesNormalized int64
+}
+
+// reportKPIs is the KPI map of a period report. backlog is the current number of
+// transfers in progress. Without a configured SLA policy the SLA KPIs keep their
+// original meaning, the share of processing durations within the 24h default,
+// while sla lists the breaches measured between the built-in milestones.
+func reportKPIs(counts periodCounts, durations []int64, sla *SLACompliance, policy SLAPolicy, backlog int64) map[string]any {
+	avg, p50, p95 := durationStats(durations)
+	onTime, breaches, compliant := sla.OnTimePercent, sla.Breached, sla.Compliant
+	if policy.Builtin {
+		onTime, breaches = durationsOnTime(durations, policy.TargetSeconds)
+		compliant = len(durations) == 0 || onTime >= policy.TargetPercent
+	}
+	return map[string]any{
+		"transfers_total":      counts.Total,
+		"transfers_success":    counts.Success,
+		"transfers_failed":     counts.Failed,
+		"avg_processing_sec":   avg,
+		"p50_processing_sec":   p50,
+		"p95_processing_sec":   p95,
+		"files_total":          counts.FilesTotal,
+		"files_original":       counts.FilesOriginal,
+		"files_normalized":     counts.FilesNormalized,
+		"sla_on_time_percent":  onTime,
+		"sla_target_seconds":   policy.TargetSeconds,
+		"sla_target_percent":   policy.TargetPercent,
+		"sla_policy":           slaPolicySummary(policy),
+		"sla_breaches":         breaches,
+		"sla_compliant":        compliant,
+		"backlog_end_of_month": backlog,
+	}
+}
+
+// durationsOnTime returns the percentage of durations within target and the
+// number over it.
+func durationsOnTime(durations []int64, target int64) (float64, int64) {
+	if len(durations) == 0 {
+		return 0, 0
+	}
+	onTime := int64(0)
+	for _, d := range durations {
+		if d <= target {
+			onTime++
+		}
+	}
+	return round2(float64(onTime) * 100 / float64(len(durations))), int64(len(durations)) - onTime
+}
+
+// transferDurationSample is the processing time of one transfer completed in a report window.
+type transferDurationSample struct {
+	CompletedAt time.Time
+	Seconds     int64
+}
+
+func (s *Store) transferDurationSamples(ctx context.Context, start, end time.Time, filterClause string, filterArgs []any) ([]transferDurationSample, error) {
 	q := fmt.Sprintf(`
 SELECT
+  t.completed_at,
   TIMESTAMPDIFF(
     SECOND,
     COALESCE(tt.transfer_started_at, tfs.transfer_first_seen_at),
@@ -234,115 +319,64 @@ WHERE t.completed_at >= ?
 	}
 	defer rows.Close()
 
-	durations := make([]int64, 0)
+	samples := make([]transferDurationSample, 0)
 	for rows.Next() {
-		var dur sql.NullInt64
-		if err := rows.Scan(&dur); err != nil {
+		var (
+			completedAt time.Time
+			dur         sql.NullInt64
+		)
+		if err := rows.Scan(&completedAt, &dur); err != nil {
 			return nil, err
 		}
 		if dur.Valid && dur.Int64 >= 0 {
-			durations = append(durations, dur.Int64)
+			samples = append(samples, transferDurationSample{CompletedAt: completedAt, Seconds: dur.Int64})
 		}
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return durations, nil
+	return samples, nil
 }
 
 // GetTransferDurationChart returns per-day duration percentiles for monthly graphing.
 func (s *Store) GetTransferDurationChart(ctx context.Context, customerID string, month time.Time) ([]DurationChartPoint, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	return s.GetPeriodDurationChart(ctx, customerID, MonthPeriod(month, time.UTC))
+}
+
+// GetPeriodDurationChart returns duration percentiles per period bucket. Buckets
+// without completed transfers are omitted.
+func (s *Store) GetPeriodDurationChart(ctx context.Context, customerID string, period ReportPeriod) ([]DurationChartPoint, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
-	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
-	end := start.AddDate(0, 1, 0)
 	filterClause, args, err := s.sourceFilterClause(ctx, customerID)
 	if err != nil {
 		return nil, err
 	}
-
-	q := fmt.Sprintf(`
-SELECT
-  DATE(t.completed_at) AS day_bucket,
-  TIMESTAMPDIFF(
-    SECOND,
-    COALESCE(tt.transfer_started_at, tfs.transfer_first_seen_at),
-    COALESCE(tt.transfer_finished_at, t.completed_at)
-  ) AS duration_sec
-FROM Transfers t
-LEFT JOIN (
-  SELECT
-    t2.transferUUID,
-    MIN(COALESCE(tsk.startTime, j.createdTime)) AS transfer_started_at,
-    COALESCE(
-      t2.completed_at,
-      MAX(COALESCE(tsk.endTime, tsk.startTime, j.createdTime))
-    ) AS transfer_finished_at
-  FROM Transfers t2
-  LEFT JOIN Jobs j
-    ON j.SIPUUID = t2.transferUUID
-    AND j.unitType = 'Transfer'
-  LEFT JOIN Tasks tsk
-    ON tsk.jobuuid = j.jobUUID
-  GROUP BY t2.transferUUID, t2.completed_at
-) tt
-  ON tt.transferUUID = t.transferUUID
-LEFT JOIN (
-  SELECT
-    f.transferUUID,
-    MIN(f.enteredSystem) AS transfer_first_seen_at
-  FROM Files f
-  WHERE f.transferUUID IS NOT NULL
-  GROUP BY f.transferUUID
-) tfs
-  ON tfs.transferUUID = t.transferUUID
-WHERE t.completed_at >= ?
-  AND t.completed_at < ?
-  AND t.status IN (2, 3, 4)
-  AND COALESCE(tt.transfer_started_at, tfs.transfer_first_seen_at) IS NOT NULL
-  AND COALESCE(tt.transfer_finished_at, t.completed_at) IS NOT NULL
-  %s;
-`, filterClause)
-
-	queryArgs := append([]any{start, end}, args...)
-	rows, err := s.db.QueryContext(ctx, q, queryArgs...)
+	samples, err := s.transferDurationSamples(ctx, period.Start, period.End, filterClause, args)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	byDay := map[string][]int64{}
-	for rows.Next() {
-		var (
-			day time.Time
-			dur sql.NullInt64
-		)
-		if err := rows.Scan(&day, &dur); err != nil {
-			return nil, err
-		}
-		if dur.Valid && dur.Int64 >= 0 {
-			key := day.Format("2006-01-02")
-			byDay[key] = append(byDay[key], dur.Int64)
-		}
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+	byBucket := map[string][]int64{}
+	for _, sm := range samples {
+		key := period.BucketKey(sm.CompletedAt)
+		byBucket[key] = append(byBucket[key], sm.Seconds)
 	}
 
-	keys := make([]string, 0, len(byDay))
-	for k := range byDay {
+	keys := make([]string, 0, len(byBucket))
+	for k := range byBucket {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
 
 	series := make([]DurationChartPoint, 0, len(keys))
-	for _, day := range keys {
-		d := byDay[day]
+	for _, key := range keys {
+		d := byBucket[key]
 		avg, p50, p95 := durationStats(d)
 		series = append(series, DurationChartPoint{
-			Date:       day,
+			Date:       key,
 			Count:      int64(len(d)),
 			AvgSeconds: avg,
 			P50Seconds: p50,
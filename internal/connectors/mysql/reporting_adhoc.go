@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"go-am-realtime-report-ui/internal/dbctx"
 )
 
 // ReportColumn describes a selectable column in ad-hoc transfer reports.
@@ -23,12 +25,24 @@ type TransferReportOptions struct {
 	Limit      int
 	Offset     int
 	Columns    []string
+	// GroupBy and Measures turn the report into one aggregated row per group;
+	// Limit and Offset then page through groups.
+	GroupBy  []string
+	Measures []string
+	// Filter is an expression over the report columns, see CompileTransferReportFilter.
+	Filter string
+	// Sort lists "column [asc|desc]" terms; the default is newest completion first.
+	Sort []string
 }
 
 // TransferReportResult contains rows and total count for paginated report output.
+// For grouped reports Total counts groups, Transfers the aggregated transfers and
+// Truncated tells whether transferReportMaxGroupedRows was reached.
 type TransferReportResult struct {
-	Rows  []map[string]any `json:"rows"`
-	Total int64            `json:"total"`
+	Rows      []map[string]any `json:"rows"`
+	Total     int64            `json:"total"`
+	Transfers int64            `json:"transfers,omitempty"`
+	Truncated bool             `json:"truncated,omitempty"`
 }
 
 var transferReportColumns = []ReportColumn{
@@ -69,6 +83,80 @@ var transferReportColumnSet = map[string]struct{}{
 	"source_of_acquisition":  {},
 }
 
+// transferReportJoins are the per-transfer aggregates behind the report columns; filter
+// and sort expressions (see transferReportFilterColumns) refer to their aliases.
+const transferReportJoins = `
+LEFT JOIN (
+  SELECT
+    t2.transferUUID,
+    MIN(COALESCE(tsk.startTime, j.createdTime)) AS transfer_started_at,
+    COALESCE(
+      t2.completed_at,
+      MAX(COALESCE(tsk.endTime, tsk.startTime, j.createdTime))
+    ) AS transfer_finished_at
+  FROM Transfers t2
+  LEFT JOIN Jobs j
+    ON j.SIPUUID = t2.transferUUID
+    AND j.unitType LIKE '%Transfer'
+  LEFT JOIN Tasks tsk
+    ON tsk.jobuuid = j.jobUUID
+  GROUP BY t2.transferUUID, t2.completed_at
+) tt
+  ON tt.transferUUID = t.transferUUID
+LEFT JOIN (
+  SELECT
+    f.transferUUID,
+    MIN(f.enteredSystem) AS transfer_first_seen_at
+  FROM Files f
+  WHERE f.transferUUID IS NOT NULL
+  GROUP BY f.transferUUID
+) tfs
+  ON tfs.transferUUID = t.transferUUID
+LEFT JOIN (
+  SELECT
+    f.transferUUID,
+    COUNT(*) AS total_files,
+    SUM(CASE WHEN LOWER(f.fileGrpUse) = 'original' THEN 1 ELSE 0 END) AS original_files,
+    SUM(CASE WHEN d.derivedFileUUID IS NOT NULL THEN 1 ELSE 0 END) AS normalized_files,
+    SUM(COALESCE(f.fileSize, 0)) AS total_bytes
+  FROM Files f
+  LEFT JOIN Derivations d
+    ON d.sourceFileUUID = f.fileUUID
+  GROUP BY f.transferUUID
+) fc
+  ON fc.transferUUID = t.transferUUID
+LEFT JOIN (
+  SELECT
+    j.SIPUUID AS transferUUID,
+    SUM(CASE WHEN COALESCE(tsk.exitCode, 0) <> 0 AND j.currentStep = 4 THEN 1 ELSE 0 END) AS failed_markers
+  FROM Jobs j
+  LEFT JOIN Tasks tsk
+    ON tsk.jobuuid = j.jobUUID
+  WHERE j.unitType LIKE '%Transfer'
+  GROUP BY j.SIPUUID
+) fm
+  ON fm.transferUUID = t.transferUUID
+LEFT JOIN (
+  SELECT
+    j.SIPUUID AS transferUUID,
+    COUNT(DISTINCT CASE WHEN COALESCE(tsk.exitCode, 0) <> 0 AND j.currentStep = 4 THEN j.jobUUID END) AS failed_jobs
+  FROM Jobs j
+  LEFT JOIN Tasks tsk
+    ON tsk.jobuuid = j.jobUUID
+  WHERE j.unitType LIKE '%Transfer'
+  GROUP BY j.SIPUUID
+) fj
+  ON fj.transferUUID = t.transferUUID
+LEFT JOIN (
+  SELECT
+    f.transferUUID,
+    MAX(CASE WHEN f.sipUUID IS NOT NULL AND f.sipUUID <> '' THEN 1 ELSE 0 END) AS has_sip_output,
+    MAX(COALESCE(NULLIF(f.sipUUID, ''), '')) AS sip_uuid
+  FROM Files f
+  GROUP BY f.transferUUID
+) sf
+  ON sf.transferUUID = t.transferUUID`
+
 // AvailableTransferReportColumns returns columns accepted by RunTransferReport.
 func AvailableTransferReportColumns() []ReportColumn {
 	out := make([]ReportColumn, 0, len(transferReportColumns))
@@ -95,43 +183,28 @@ func NormalizeTransferReportColumns(columns []string) []string {
 		out = append(out, key)
 	}
 	if len(out) == 0 {
-		return []string{"transfer_uuid", "name", "status", "completed_at", "duration_seconds", "files_total", "failed_tasks"}
+		return append([]string(nil), reportScopes[ReportScopeTransfer].defaultColumns...)
 	}
 	return out
 }
 
 // RunTransferReport executes a configurable transfer report.
 func (s *Store) RunTransferReport(ctx context.Context, opts TransferReportOptions) (*TransferReportResult, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
-	status := strings.ToLower(strings.TrimSpace(opts.Status))
-	if status == "" {
-		status = "all"
-	}
-
-	filterClause, filterArgs, err := s.sourceFilterClause(ctx, opts.CustomerID)
+	groupBy, measures, err := NormalizeTransferReportGrouping(opts.GroupBy, opts.Measures)
 	if err != nil {
 		return nil, err
 	}
-
-	where := "WHERE t.status IN (2, 3, 4) AND t.completed_at >= ? AND t.completed_at < ?"
-	args := []any{opts.DateFrom, opts.DateTo}
-	if filterClause != "" {
-		where += " " + filterClause
-		args = append(args, filterArgs...)
+	grouped := len(groupBy) > 0
+	sorts, err := NormalizeTransferReportSort(opts.Sort, groupBy, measures)
+	if err != nil {
+		return nil, err
 	}
-
-	switch status {
-	case "all":
-	case "success":
-		where += " AND COALESCE(fm.failed_markers, 0) = 0"
-	case "failed":
-		where += " AND COALESCE(fm.failed_markers, 0) > 0 AND COALESCE(sf.has_sip_output, 0) = 0"
-	case "completed_with_non_blocking_errors":
-		where += " AND COALESCE(fm.failed_markers, 0) > 0 AND COALESCE(sf.has_sip_output, 0) = 1"
-	default:
-		return nil, fmt.Errorf("invalid status filter: %s", status)
+	where, args, hasExpr, err := s.transferReportWhere(ctx, opts)
+	if err != nil {
+		return nil, err
 	}
 
 	countQuery := fmt.Sprintf(`
@@ -158,13 +231,147 @@ LEFT JOIN (
   ON sf.transferUUID = t.transferUUID
 %s;
 `, where)
+	if hasExpr {
+		// Filter expressions may reference any report column, so count over all joins.
+		countQuery = fmt.Sprintf("\nSELECT COUNT(*)\nFROM Transfers t%s\n%s;\n", transferReportJoins, where)
+	}
 
 	var total sql.NullInt64
 	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
 		return nil, err
 	}
 
-	q := fmt.Sprintf(`
+	q := transferReportSelect(where, sorts, grouped) + "\nLIMIT ? OFFSET ?;\n"
+	queryArgs := append([]any{}, args...)
+	if grouped {
+		queryArgs = append(queryArgs, transferReportMaxGroupedRows, 0)
+	} else {
+		queryArgs = append(queryArgs, opts.Limit, opts.Offset)
+	}
+	rows, err := s.db.QueryContext(ctx, q, queryArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := NormalizeTransferReportColumns(opts.Columns)
+	resultRows := make([]map[string]any, 0, opts.Limit)
+	groupRows := make([]transferReportRow, 0)
+	for rows.Next() {
+		rowAll, groupRow, err := scanTransferReportRow(rows, grouped)
+		if err != nil {
+			return nil, err
+		}
+		if grouped {
+			groupRows = append(groupRows, groupRow)
+			continue
+		}
+
+		row := make(map[string]any, len(columns))
+		for _, c := range columns {
+			row[c] = rowAll[c]
+		}
+		resultRows = append(resultRows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if grouped {
+		var customers *customerAttribution
+		if containsKey(groupBy, "customer") {
+			if customers, err = s.customerAttribution(ctx); err != nil {
+				return nil, err
+			}
+		}
+		groups := groupTransferReportRows(groupRows, groupBy, measures, customers)
+		sortReportRows(groups, sorts)
+		start := min(opts.Offset, len(groups))
+		end := len(groups)
+		if opts.Limit > 0 {
+			end = min(start+opts.Limit, len(groups))
+		}
+		return &TransferReportResult{
+			Rows:      groups[start:end],
+			Total:     int64(len(groups)),
+			Transfers: int64(len(groupRows)),
+			Truncated: nullInt64Value(total) > int64(len(groupRows)),
+		}, nil
+	}
+
+	return &TransferReportResult{
+		Rows:  resultRows,
+		Total: nullInt64Value(total),
+	}, nil
+}
+
+// transferReportWhere renders the period, customer, status and filter expression of a
+// transfer report; hasExpr tells whether a filter expression was added.
+func (s *Store) transferReportWhere(ctx context.Context, opts TransferReportOptions) (string, []any, bool, error) {
+	status := strings.ToLower(strings.TrimSpace(opts.Status))
+	if status == "" {
+		status = "all"
+	}
+	exprClause, exprArgs, err := CompileTransferReportFilter(opts.Filter)
+	if err != nil {
+		return "", nil, false, err
+	}
+
+	filterClause, filterArgs, err := s.sourceFilterClause(ctx, opts.CustomerID)
+	if err != nil {
+		return "", nil, false, err
+	}
+
+	where := "WHERE t.status IN (2, 3, 4) AND t.completed_at >= ? AND t.completed_at < ?"
+	args := []any{opts.DateFrom, opts.DateTo}
+	if filterClause != "" {
+		where += " " + filterClause
+		args = append(args, filterArgs...)
+	}
+
+	switch status {
+	case "all":
+	case "success":
+		where += " AND COALESCE(fm.failed_markers, 0) = 0"
+	case "failed":
+		where += " AND COALESCE(fm.failed_markers, 0) > 0 AND COALESCE(sf.has_sip_output, 0) = 0"
+	case "completed_with_non_blocking_errors":
+		where += " AND COALESCE(fm.failed_markers, 0) > 0 AND COALESCE(sf.has_sip_output, 0) = 1"
+	default:
+		return "", nil, false, fmt.Errorf("invalid status filter: %s", status)
+	}
+	if exprClause != "" {
+		where += " AND " + exprClause
+		args = append(args, exprArgs...)
+	}
+	return where, args, exprClause != "", nil
+}
+
+// transferReportSelect builds the row query of a transfer report without LIMIT.
+func transferReportSelect(where string, sorts []ReportSort, grouped bool) string {
+	// The microservice group is only needed, and only joined, for grouped reports.
+	groupSelect, groupJoin := "", ""
+	if grouped {
+		groupSelect = `,
+  COALESCE(mg.microservice_group, 'UNKNOWN') AS microservice_group,
+  COALESCE(t.accessionID, '') AS accession_id,
+  ` + transferStartExpr + ` AS mapping_started_at`
+		groupJoin = `
+LEFT JOIN (
+  SELECT
+    j.SIPUUID AS transferUUID,
+    COALESCE(
+      SUBSTRING_INDEX(GROUP_CONCAT(CASE WHEN j.currentStep = 4 THEN NULLIF(j.microserviceGroup, '') END ORDER BY j.createdTime SEPARATOR '||'), '||', 1),
+      SUBSTRING_INDEX(GROUP_CONCAT(NULLIF(j.microserviceGroup, '') ORDER BY j.createdTime DESC SEPARATOR '||'), '||', 1)
+    ) AS microservice_group
+  FROM Jobs j
+  WHERE j.unitType LIKE '%Transfer'
+  GROUP BY j.SIPUUID
+) mg
+  ON mg.transferUUID = t.transferUUID`
+	}
+
+	return fmt.Sprintf(`
 SELECT
   t.transferUUID,
   t.currentLocation,
@@ -187,165 +394,91 @@ SELECT
   COALESCE(fm.failed_markers, 0) AS failed_tasks,
   COALESCE(fj.failed_jobs, 0) AS failed_jobs,
   COALESCE(sf.has_sip_output, 0) AS has_sip_output,
-  COALESCE(sf.sip_uuid, '') AS sip_uuid
-FROM Transfers t
-LEFT JOIN (
-  SELECT
-    t2.transferUUID,
-    MIN(COALESCE(tsk.startTime, j.createdTime)) AS transfer_started_at,
-    COALESCE(
-      t2.completed_at,
-      MAX(COALESCE(tsk.endTime, tsk.startTime, j.createdTime))
-    ) AS transfer_finished_at
-  FROM Transfers t2
-  LEFT JOIN Jobs j
-    ON j.SIPUUID = t2.transferUUID
-    AND j.unitType LIKE '%%Transfer'
-  LEFT JOIN Tasks tsk
-    ON tsk.jobuuid = j.jobUUID
-  GROUP BY t2.transferUUID, t2.completed_at
-) tt
-  ON tt.transferUUID = t.transferUUID
-LEFT JOIN (
-  SELECT
-    f.transferUUID,
-    MIN(f.enteredSystem) AS transfer_first_seen_at
-  FROM Files f
-  WHERE f.transferUUID IS NOT NULL
-  GROUP BY f.transferUUID
-) tfs
-  ON tfs.transferUUID = t.transferUUID
-LEFT JOIN (
-  SELECT
-    f.transferUUID,
-    COUNT(*) AS total_files,
-    SUM(CASE WHEN LOWER(f.fileGrpUse) = 'original' THEN 1 ELSE 0 END) AS original_files,
-    SUM(CASE WHEN d.derivedFileUUID IS NOT NULL THEN 1 ELSE 0 END) AS normalized_files,
-    SUM(COALESCE(f.fileSize, 0)) AS total_bytes
-  FROM Files f
-  LEFT JOIN Derivations d
-    ON d.sourceFileUUID = f.fileUUID
-  GROUP BY f.transferUUID
-) fc
-  ON fc.transferUUID = t.transferUUID
-LEFT JOIN (
-  SELECT
-    j.SIPUUID AS transferUUID,
-    SUM(CASE WHEN COALESCE(tsk.exitCode, 0) <> 0 AND j.currentStep = 4 THEN 1 ELSE 0 END) AS failed_markers
-  FROM Jobs j
-  LEFT JOIN Tasks tsk
-    ON tsk.jobuuid = j.jobUUID
-  WHERE j.unitType LIKE '%%Transfer'
-  GROUP BY j.SIPUUID
-) fm
-  ON fm.transferUUID = t.transferUUID
-LEFT JOIN (
-  SELECT
-    j.SIPUUID AS transferUUID,
-    COUNT(DISTINCT CASE WHEN COALESCE(tsk.exitCode, 0) <> 0 AND j.currentStep = 4 THEN j.jobUUID END) AS failed_jobs
-  FROM Jobs j
-  LEFT JOIN Tasks tsk
-    ON tsk.jobuuid = j.jobUUID
-  WHERE j.unitType LIKE '%%Transfer'
-  GROUP BY j.SIPUUID
-) fj
-  ON fj.transferUUID = t.transferUUID
-LEFT JOIN (
-  SELECT
-    f.transferUUID,
-    MAX(CASE WHEN f.sipUUID IS NOT NULL AND f.sipUUID <> '' THEN 1 ELSE 0 END) AS has_sip_output,
-    MAX(COALESCE(NULLIF(f.sipUUID, ''), '')) AS sip_uuid
-  FROM Files f
-  GROUP BY f.transferUUID
-) sf
-  ON sf.transferUUID = t.transferUUID
+  COALESCE(sf.sip_uuid, '') AS sip_uuid%s
+FROM Transfers t%s%s
 %s
-ORDER BY t.completed_at DESC
-LIMIT ? OFFSET ?;
-`, where)
+ORDER BY %s`, groupSelect, transferReportJoins, groupJoin, where, transferReportOrderBy(sorts))
+}
 
-	queryArgs := append([]any{}, args...)
-	queryArgs = append(queryArgs, opts.Limit, opts.Offset)
-	rows, err := s.db.QueryContext(ctx, q, queryArgs...)
-	if err != nil {
-		return nil, err
+// scanTransferReportRow reads one row of transferReportSelect as all report columns
+// and, for grouped reports, as the input of groupTransferReportRows.
+func scanTransferReportRow(rows *sql.Rows, grouped bool) (map[string]any, transferReportRow, error) {
+	var (
+		transferUUID      string
+		currentLocation   string
+		statusCode        int
+		source            string
+		startedAt         sql.NullTime
+		completedAt       sql.NullTime
+		durationSeconds   int64
+		filesTotal        int64
+		filesOriginal     int64
+		filesNormalized   int64
+		sizeBytes         int64
+		failedTasks       int64
+		failedJobs        int64
+		hasSIPOutput      sql.NullInt64
+		sipUUID           string
+		microserviceGroup string
+		accessionID       string
+		mappingStartedAt  sql.NullTime
+	)
+	dest := []any{
+		&transferUUID,
+		&currentLocation,
+		&statusCode,
+		&source,
+		&startedAt,
+		&completedAt,
+		&durationSeconds,
+		&filesTotal,
+		&filesOriginal,
+		&filesNormalized,
+		&sizeBytes,
+		&failedTasks,
+		&failedJobs,
+		&hasSIPOutput,
+		&sipUUID,
 	}
-	defer rows.Close()
-
-	columns := NormalizeTransferReportColumns(opts.Columns)
-	resultRows := make([]map[string]any, 0, opts.Limit)
-	for rows.Next() {
-		var (
-			transferUUID      string
-			currentLocation   string
-			statusCode        int
-			source            string
-			startedAt         sql.NullTime
-			completedAt       sql.NullTime
-			durationSeconds   int64
-			filesTotal        int64
-			filesOriginal     int64
-			filesNormalized   int64
-			sizeBytes         int64
-			failedTasks       int64
-			failedJobs        int64
-			hasSIPOutput      sql.NullInt64
-			sipUUID           string
-		)
-		if err := rows.Scan(
-			&transferUUID,
-			&currentLocation,
-			&statusCode,
-			&source,
-			&startedAt,
-			&completedAt,
-			&durationSeconds,
-			&filesTotal,
-			&filesOriginal,
-			&filesNormalized,
-			&sizeBytes,
-			&failedTasks,
-			&failedJobs,
-			&hasSIPOutput,
-			&sipUUID,
-		); err != nil {
-			return nil, err
-		}
-
-		recoverable := failedTasks > 0 && nullInt64Value(hasSIPOutput) > 0
-		status := transferStatusNameWithEvidence(statusCode, failedTasks > 0, recoverable)
-		rowAll := map[string]any{
-			"transfer_uuid":         transferUUID,
-			"name":                  transferNameFromLocation(currentLocation, transferUUID),
-			"status":                status,
-			"status_code":           statusCode,
-			"recoverable":           recoverable,
-			"started_at":            formatNullTime(startedAt),
-			"completed_at":          formatNullTime(completedAt),
-			"duration_seconds":      durationSeconds,
-			"files_total":           filesTotal,
-			"files_original":        filesOriginal,
-			"files_normalized":      filesNormalized,
-			"size_mb":               float64(sizeBytes) / 1024.0 / 1024.0,
-			"failed_jobs":           failedJobs,
-			"failed_tasks":          failedTasks,
-			"sip_uuid":              sipUUID,
-			"source_of_acquisition": source,
-		}
-
-		row := make(map[string]any, len(columns))
-		for _, c := range columns {
-			row[c] = rowAll[c]
-		}
-		resultRows = append(resultRows, row)
+	if grouped {
+		dest = append(dest, &microserviceGroup, &accessionID, &mappingStartedAt)
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+	if err := rows.Scan(dest...); err != nil {
+		return nil, transferReportRow{}, err
 	}
 
-	return &TransferReportResult{
-		Rows:  resultRows,
-		Total: nullInt64Value(total),
+	recoverable := failedTasks > 0 && nullInt64Value(hasSIPOutput) > 0
+	status := transferStatusNameWithEvidence(statusCode, failedTasks > 0, recoverable)
+	rowAll := map[string]any{
+		"transfer_uuid":         transferUUID,
+		"name":                  transferNameFromLocation(currentLocation, transferUUID),
+		"status":                status,
+		"status_code":           statusCode,
+		"recoverable":           recoverable,
+		"started_at":            formatNullTime(startedAt),
+		"completed_at":          formatNullTime(completedAt),
+		"duration_seconds":      durationSeconds,
+		"files_total":           filesTotal,
+		"files_original":        filesOriginal,
+		"files_normalized":      filesNormalized,
+		"size_mb":               float64(sizeBytes) / 1024.0 / 1024.0,
+		"failed_jobs":           failedJobs,
+		"failed_tasks":          failedTasks,
+		"sip_uuid":              sipUUID,
+		"source_of_acquisition": source,
+	}
+	return rowAll, transferReportRow{
+		UUID:              transferUUID,
+		Location:          currentLocation,
+		Accession:         accessionID,
+		MappingStartedAt:  nullTimePtr(mappingStartedAt),
+		CompletedAt:       completedAt.Time.UTC(),
+		Status:            status,
+		Source:            source,
+		MicroserviceGroup: microserviceGroup,
+		DurationSeconds:   durationSeconds,
+		SizeBytes:         sizeBytes,
+		FilesTotal:        filesTotal,
 	}, nil
 }
 
@@ -0,0 +1,176 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go-am-realtime-report-ui/internal/dbctx"
+)
+
+var aipReportColumns = []ReportColumn{
+	{Key: "aip_uuid", Label: "AIP UUID"},
+	{Key: "name", Label: "SIP Name"},
+	{Key: "package_type", Label: "Package Type"},
+	{Key: "replica_of", Label: "Replica Of"},
+	{Key: "size_mb", Label: "Size (MB)"},
+	{Key: "stored_at", Label: "Stored At"},
+	{Key: "location", Label: "Storage Location"},
+	{Key: "source_of_acquisition", Label: "Source Of Acquisition"},
+	{Key: "customer_id", Label: "Customer"},
+	{Key: "files_total", Label: "Files (ES)"},
+	{Key: "format_count", Label: "Distinct Formats (ES)"},
+	{Key: "top_format", Label: "Most Common Format (ES)"},
+	{Key: "unknown_formats", Label: "Unknown Formats (ES)"},
+	{Key: "missing_identifiers", Label: "Missing Identifiers (ES)"},
+	{Key: "extension_format_mismatch", Label: "Extension/Format Mismatches (ES)"},
+	{Key: "duplicate_filename_candidates", Label: "Duplicate Filenames (ES)"},
+	{Key: "originals_without_normalized", Label: "Originals Without Normalization (ES)"},
+}
+
+var aipReportSortColumns = []string{
+	"aip_uuid", "name", "package_type", "replica_of", "size_mb", "stored_at", "location", "source_of_acquisition", "customer_id",
+}
+
+// aipReportStatsColumns are filled from Elasticsearch AIP stats for the returned page.
+var aipReportStatsColumns = []string{
+	"files_total", "format_count", "top_format", "unknown_formats", "missing_identifiers",
+	"extension_format_mismatch", "duplicate_filename_candidates", "originals_without_normalized",
+}
+
+// RunAIPReport lists Storage Service packages stored in the window with their SIP
+// name, source of acquisition and customer from MCP. Elasticsearch columns are left
+// for the caller, see ReportLateColumns.
+func (s *Store) RunAIPReport(ctx context.Context, packages []StoragePackage, opts ReportQueryOptions) (*ReportQueryResult, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	if _, _, err := CompileReportFilter(ReportScopeAIP, opts.Filter); err != nil {
+		return nil, err
+	}
+	sorts, err := NormalizeReportSort(ReportScopeAIP, opts.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	inWindow := make([]StoragePackage, 0)
+	sipSet := map[string]struct{}{}
+	for _, p := range packages {
+		if p.StoredAt.Before(opts.DateFrom) || !p.StoredAt.Before(opts.DateTo) {
+			continue
+		}
+		inWindow = append(inWindow, p)
+		sipSet[strings.ToLower(p.SIPUUID)] = struct{}{}
+	}
+	sipUUIDs := keysOf(sipSet)
+	transfers, err := s.sipTransfers(ctx, sipUUIDs)
+	if err != nil {
+		return nil, err
+	}
+	names, err := s.sipNames(ctx, sipUUIDs)
+	if err != nil {
+		return nil, err
+	}
+	attribution, err := s.customerAttribution(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var members []string
+	if customerID := strings.TrimSpace(opts.CustomerID); customerID != "" && !strings.EqualFold(customerID, "all") && !strings.EqualFold(customerID, "default") {
+		if members, err = s.customerMembers(ctx, customerID); err != nil {
+			return nil, err
+		}
+	}
+
+	rows := computeAIPReportRows(inWindow, transfers, names, attribution, members, sorts)
+	start := min(opts.Offset, len(rows))
+	end := len(rows)
+	if opts.Limit > 0 {
+		end = min(start+opts.Limit, len(rows))
+	}
+	columns := NormalizeReportColumns(ReportScopeAIP, opts.Columns)
+	out := make([]map[string]any, 0, end-start)
+	for _, rowAll := range rows[start:end] {
+		row := make(map[string]any, len(columns))
+		for _, c := range columns {
+			row[c] = rowAll[c]
+		}
+		out = append(out, row)
+	}
+	return &ReportQueryResult{Rows: out, Total: int64(len(rows))}, nil
+}
+
+// computeAIPReportRows builds the sorted AIP rows. Packages are attributed through
+// the transfer their SIP was built from, at its start; packages whose SIP MCP does
+// not know count under forecastUnmappedCustomer. A non-empty members keeps the
+// packages of those customers. Without sorts rows are newest first.
+func computeAIPReportRows(packages []StoragePackage, transfers map[string]mappingRuleTransfer, names map[string]string, attribution *customerAttribution, members []string, sorts []ReportSort) []map[string]any {
+	rows := make([]map[string]any, 0, len(packages))
+	for _, p := range packages {
+		sipUUID := strings.ToLower(p.SIPUUID)
+		tr, known := transfers[sipUUID]
+		ids := []string{forecastUnmappedCustomer}
+		if known {
+			ids = attribution.customersFor(tr)
+		}
+		if len(members) > 0 && !containsAnyCustomer(ids, members) {
+			continue
+		}
+		replicaOf := ""
+		if !strings.EqualFold(p.SIPUUID, p.UUID) {
+			replicaOf = p.SIPUUID
+		}
+		rows = append(rows, map[string]any{
+			"aip_uuid":              p.UUID,
+			"name":                  names[sipUUID],
+			"package_type":          p.PackageType,
+			"replica_of":            replicaOf,
+			"size_mb":               round2(float64(p.SizeBytes) / 1024.0 / 1024.0),
+			"stored_at":             p.StoredAt.UTC().Format(time.RFC3339),
+			"location":              p.Location,
+			"source_of_acquisition": tr.Source,
+			"customer_id":           strings.Join(ids, ","),
+		})
+	}
+
+	if len(sorts) == 0 {
+		sorts = []ReportSort{{Key: "stored_at", Desc: true}}
+	}
+	sortReportRows(rows, append(append([]ReportSort{}, sorts...), ReportSort{Key: "aip_uuid"}))
+	return rows
+}
+
+// sipNames resolves SIP display names from MCP, keyed by lower-case SIP UUID.
+func (s *Store) sipNames(ctx context.Context, sipUUIDs []string) (map[string]string, error) {
+	out := make(map[string]string, len(sipUUIDs))
+	for offset := 0; offset < len(sipUUIDs); offset += storageSourceBatchSize {
+		batch := sipUUIDs[offset:min(offset+storageSourceBatchSize, len(sipUUIDs))]
+		args := make([]any, 0, len(batch))
+		for _, id := range batch {
+			args = append(args, id)
+		}
+		q := `
+SELECT s.sipUUID, COALESCE(s.currentPath, '')
+FROM SIPs s
+WHERE s.sipUUID IN (` + placeholders(len(batch)) + `);
+`
+		rows, err := s.db.QueryContext(ctx, q, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var sipUUID, path string
+			if err := rows.Scan(&sipUUID, &path); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			out[strings.ToLower(sipUUID)] = transferNameFromLocation(path, sipUUID)
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		rows.Close()
+	}
+	return out, nil
+}
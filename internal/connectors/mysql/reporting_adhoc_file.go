@@ -0,0 +1,101 @@
+package mysql
+
+var fileReportColumns = []ReportColumn{
+	{Key: "file_uuid", Label: "File UUID"},
+	{Key: "file_name", Label: "File Name"},
+	{Key: "file_path", Label: "Current Location"},
+	{Key: "file_group", Label: "File Group"},
+	{Key: "extension", Label: "Extension"},
+	{Key: "size_bytes", Label: "Size (bytes)"},
+	{Key: "pronom_id", Label: "PRONOM ID"},
+	{Key: "format_name", Label: "Format"},
+	{Key: "format_version", Label: "Format Version"},
+	{Key: "identification_tool", Label: "Identification Tool"},
+	{Key: "derivatives", Label: "Derivatives"},
+	{Key: "normalized", Label: "Normalized"},
+	{Key: "derived_from", Label: "Derived From (file UUID)"},
+	{Key: "entered_at", Label: "Entered System"},
+	{Key: "transfer_uuid", Label: "Transfer UUID"},
+	{Key: "sip_uuid", Label: "SIP UUID"},
+	{Key: "source_of_acquisition", Label: "Source Of Acquisition"},
+}
+
+var fileReportFilterColumns = map[string]reportFilterColumn{
+	"file_uuid":  {expr: "f.fileUUID", kind: reportKindString},
+	"file_name":  {expr: "SUBSTRING_INDEX(COALESCE(f.currentLocation, ''), '/', -1)", kind: reportKindString},
+	"file_path":  {expr: "COALESCE(f.currentLocation, '')", kind: reportKindString},
+	"file_group": {expr: "LOWER(NULLIF(f.fileGrpUse, ''))", kind: reportKindString},
+	"extension": {expr: `NULLIF(
+    CASE
+      WHEN LOCATE('.', SUBSTRING_INDEX(COALESCE(f.currentLocation, ''), '/', -1)) > 0
+        THEN LOWER(SUBSTRING_INDEX(f.currentLocation, '.', -1))
+      ELSE ''
+    END,
+    ''
+  )`, kind: reportKindString},
+	"size_bytes":     {expr: "COALESCE(f.fileSize, 0)", kind: reportKindNumber},
+	"pronom_id":      {expr: "NULLIF(fid.pronom_id, '')", kind: reportKindString},
+	"format_name":    {expr: "NULLIF(fid.format_name, '')", kind: reportKindString},
+	"format_version": {expr: "NULLIF(fid.format_version, '')", kind: reportKindString},
+	// Identification events carry program="<tool>"; version="<version>".
+	"identification_tool": {expr: `NULLIF(
+    TRIM(BOTH '"' FROM SUBSTRING_INDEX(SUBSTRING_INDEX(ev.detail, 'program=', -1), ';', 1)),
+    ''
+  )`, kind: reportKindString},
+	"derivatives":           {expr: "COALESCE(dv.derivatives, 0)", kind: reportKindNumber},
+	"normalized":            {expr: "(COALESCE(dv.derivatives, 0) > 0)", kind: reportKindBool},
+	"derived_from":          {expr: "ds.source_file_uuid", kind: reportKindString},
+	"entered_at":            {expr: "f.enteredSystem", kind: reportKindTime},
+	"transfer_uuid":         {expr: "NULLIF(f.transferUUID, '')", kind: reportKindString},
+	"sip_uuid":              {expr: "NULLIF(f.sipUUID, '')", kind: reportKindString},
+	"source_of_acquisition": {expr: "NULLIF(t.sourceOfAcquisition, '')", kind: reportKindString},
+}
+
+// fileReportSource reports files that entered the system in the window. Files created
+// during ingest (e.g. preservation derivatives) have no transfer and therefore no
+// customer.
+var fileReportSource = &sqlReportSource{
+	from: `FROM Files f
+LEFT JOIN Transfers t
+  ON t.transferUUID = f.transferUUID
+LEFT JOIN (
+  SELECT
+    fii.fileUUID,
+    MIN(fv.pronom_id) AS pronom_id,
+    MIN(fv.description) AS format_name,
+    MIN(fv.version) AS format_version
+  FROM FilesIdentifiedIDs fii
+  JOIN fpr_formatversion fv
+    ON fv.uuid = fii.fileID
+  GROUP BY fii.fileUUID
+) fid
+  ON fid.fileUUID = f.fileUUID
+LEFT JOIN (
+  SELECT
+    e.fileUUID,
+    MAX(e.eventDetail) AS detail
+  FROM Events e
+  WHERE e.eventType = 'format identification'
+  GROUP BY e.fileUUID
+) ev
+  ON ev.fileUUID = f.fileUUID
+LEFT JOIN (
+  SELECT
+    d.sourceFileUUID AS fileUUID,
+    COUNT(*) AS derivatives
+  FROM Derivations d
+  GROUP BY d.sourceFileUUID
+) dv
+  ON dv.fileUUID = f.fileUUID
+LEFT JOIN (
+  SELECT
+    d.derivedFileUUID AS fileUUID,
+    MIN(d.sourceFileUUID) AS source_file_uuid
+  FROM Derivations d
+  GROUP BY d.derivedFileUUID
+) ds
+  ON ds.fileUUID = f.fileUUID`,
+	window:       "f.enteredSystem",
+	defaultOrder: "f.enteredSystem DESC",
+	tiebreak:     "f.fileUUID",
+}
@@ -0,0 +1,597 @@
+package mysql
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	reportFilterMaxLength = 4000
+	reportFilterMaxDepth  = 20
+	reportFilterMaxValues = 500
+	reportMaxSortKeys     = 5
+)
+
+const (
+	reportKindString = "string"
+	reportKindNumber = "number"
+	reportKindBool   = "bool"
+	reportKindTime   = "time"
+)
+
+// reportFilterColumn is the SQL expression behind a report column, usable in WHERE and
+// ORDER BY over the joins of RunTransferReport.
+type reportFilterColumn struct {
+	expr string
+	kind string
+	// decimal marks number columns with fractional values.
+	decimal bool
+}
+
+var transferReportFilterColumns = map[string]reportFilterColumn{
+	"transfer_uuid": {expr: "t.transferUUID", kind: reportKindString},
+	// The display name is derived from the location in Go; filters and sorting use
+	// the location path itself, so match names with LIKE '%name%'.
+	"name": {expr: "t.currentLocation", kind: reportKindString},
+	"status": {expr: `CASE
+    WHEN COALESCE(fm.failed_markers, 0) > 0 AND COALESCE(sf.has_sip_output, 0) > 0 THEN 'COMPLETED_WITH_NON_BLOCKING_ERRORS'
+    WHEN COALESCE(fm.failed_markers, 0) > 0 AND t.status IN (2, 3) THEN 'FAILED_OR_ABORTED'
+    WHEN t.status = 1 THEN 'RUNNING'
+    WHEN t.status = 2 THEN 'SUCCESS'
+    WHEN t.status = 3 THEN 'SUCCESS_WITH_WARNINGS'
+    WHEN t.status = 4 THEN 'FAILED'
+    ELSE 'UNKNOWN'
+  END`, kind: reportKindString},
+	"status_code":  {expr: "t.status", kind: reportKindNumber},
+	"recoverable":  {expr: "(COALESCE(fm.failed_markers, 0) > 0 AND COALESCE(sf.has_sip_output, 0) > 0)", kind: reportKindBool},
+	"started_at":   {expr: "COALESCE(tt.transfer_started_at, tfs.transfer_first_seen_at)", kind: reportKindTime},
+	"completed_at": {expr: "t.completed_at", kind: reportKindTime},
+	"duration_seconds": {expr: `COALESCE(
+    TIMESTAMPDIFF(
+      SECOND,
+      COALESCE(tt.transfer_started_at, tfs.transfer_first_seen_at),
+      COALESCE(tt.transfer_finished_at, t.completed_at)
+    ),
+    0
+  )`, kind: reportKindNumber},
+	"files_total":           {expr: "COALESCE(fc.total_files, 0)", kind: reportKindNumber},
+	"files_original":        {expr: "COALESCE(fc.original_files, 0)", kind: reportKindNumber},
+	"files_normalized":      {expr: "COALESCE(fc.normalized_files, 0)", kind: reportKindNumber},
+	"size_mb":               {expr: "(COALESCE(fc.total_bytes, 0) / 1048576)", kind: reportKindNumber, decimal: true},
+	"failed_jobs":           {expr: "COALESCE(fj.failed_jobs, 0)", kind: reportKindNumber},
+	"failed_tasks":          {expr: "COALESCE(fm.failed_markers, 0)", kind: reportKindNumber},
+	"sip_uuid":              {expr: "NULLIF(sf.sip_uuid, '')", kind: reportKindString},
+	"source_of_acquisition": {expr: "NULLIF(t.sourceOfAcquisition, '')", kind: reportKindString},
+}
+
+// CompileTransferReportFilter compiles a transfer report filter, see CompileReportFilter.
+func CompileTransferReportFilter(expr string) (string, []any, error) {
+	return CompileReportFilter(ReportScopeTransfer, expr)
+}
+
+// CompileReportFilter validates a filter expression over the columns of a report scope
+// and compiles it to a parameterized SQL condition. An empty expression yields "".
+//
+// Grammar (keywords are case-insensitive):
+//
+//	expr       = term { OR term }
+//	term       = factor { AND factor }
+//	factor     = NOT factor | "(" expr ")" | comparison
+//	comparison = column ( op value | [NOT] IN "(" value { "," value } ")" |
+//	             [NOT] LIKE string | IS [NOT] NULL )
+//	op         = "=" | "!=" | "<>" | "<" | "<=" | ">" | ">="
+//
+// Strings are quoted with ' or " (double the quote to escape it), times are strings
+// in RFC3339 or YYYY-MM-DD[ HH:MM:SS] (UTC) form and booleans are true/false.
+func CompileReportFilter(scope, expr string) (string, []any, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return "", nil, nil
+	}
+	sc, ok := reportScopes[scope]
+	if !ok {
+		return "", nil, fmt.Errorf("invalid report scope: %s", scope)
+	}
+	if sc.filterColumns == nil {
+		return "", nil, fmt.Errorf("filter expressions are not supported for %s reports", scope)
+	}
+	if len(expr) > reportFilterMaxLength {
+		return "", nil, fmt.Errorf("invalid filter: longer than %d characters", reportFilterMaxLength)
+	}
+	tokens, err := lexReportFilter(expr)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	p := &reportFilterParser{tokens: tokens, scope: sc}
+	clause, err := p.parseOr()
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	if tok := p.peek(); tok.kind != filterTokenEOF {
+		return "", nil, fmt.Errorf("invalid filter: unexpected %s at position %d, expected AND, OR or end of filter", tok, tok.pos)
+	}
+	return clause, p.args, nil
+}
+
+const (
+	filterTokenEOF    = "end of filter"
+	filterTokenIdent  = "identifier"
+	filterTokenString = "string"
+	filterTokenNumber = "number"
+	filterTokenOp     = "operator"
+	filterTokenLParen = "("
+	filterTokenRParen = ")"
+	filterTokenComma  = ","
+)
+
+type filterToken struct {
+	kind string
+	text string
+	pos  int
+}
+
+func (t filterToken) String() string {
+	switch t.kind {
+	case filterTokenEOF:
+		return "end of filter"
+	case filterTokenString:
+		return fmt.Sprintf("string %q", t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+func (t filterToken) isKeyword(kw string) bool {
+	return t.kind == filterTokenIdent && strings.EqualFold(t.text, kw)
+}
+
+// lexReportFilter splits a filter expression into tokens; positions are 1-based.
+func lexReportFilter(expr string) ([]filterToken, error) {
+	out := make([]filterToken, 0)
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		pos := i + 1
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			out = append(out, filterToken{kind: filterTokenLParen, text: "(", pos: pos})
+			i++
+		case c == ')':
+			out = append(out, filterToken{kind: filterTokenRParen, text: ")", pos: pos})
+			i++
+		case c == ',':
+			out = append(out, filterToken{kind: filterTokenComma, text: ",", pos: pos})
+			i++
+		case c == '=' || c == '<' || c == '>' || c == '!':
+			op := string(c)
+			if i+1 < len(expr) && (expr[i+1] == '=' || (c == '<' && expr[i+1] == '>')) {
+				op = expr[i : i+2]
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("unexpected \"!\" at position %d, did you mean \"!=\"?", pos)
+			}
+			out = append(out, filterToken{kind: filterTokenOp, text: op, pos: pos})
+			i += len(op)
+		case c == '\'' || c == '"':
+			var b strings.Builder
+			j := i + 1
+			closed := false
+			for j < len(expr) {
+				if expr[j] == c {
+					if j+1 < len(expr) && expr[j+1] == c {
+						b.WriteByte(c)
+						j += 2
+						continue
+					}
+					closed = true
+					j++
+					break
+				}
+				b.WriteByte(expr[j])
+				j++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string starting at position %d", pos)
+			}
+			out = append(out, filterToken{kind: filterTokenString, text: b.String(), pos: pos})
+			i = j
+		case isFilterDigit(c) || ((c == '-' || c == '.') && i+1 < len(expr) && isFilterDigit(expr[i+1])):
+			j := i + 1
+			for j < len(expr) && (isFilterDigit(expr[j]) || expr[j] == '.') {
+				j++
+			}
+			out = append(out, filterToken{kind: filterTokenNumber, text: expr[i:j], pos: pos})
+			i = j
+		case c == '_' || (c|0x20 >= 'a' && c|0x20 <= 'z'):
+			j := i + 1
+			for j < len(expr) && (expr[j] == '_' || isFilterDigit(expr[j]) || (expr[j]|0x20 >= 'a' && expr[j]|0x20 <= 'z')) {
+				j++
+			}
+			out = append(out, filterToken{kind: filterTokenIdent, text: expr[i:j], pos: pos})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", c, pos)
+		}
+	}
+	return append(out, filterToken{kind: filterTokenEOF, pos: len(expr) + 1}), nil
+}
+
+func isFilterDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+type reportFilterParser struct {
+	scope  reportScope
+	tokens []filterToken
+	pos    int
+	depth  int
+	args   []any
+}
+
+func (p *reportFilterParser) peek() filterToken {
+	return p.tokens[p.pos]
+}
+
+func (p *reportFilterParser) next() filterToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != filterTokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *reportFilterParser) parseOr() (string, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return "", err
+	}
+	for p.peek().isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + " OR " + right + ")"
+	}
+	return left, nil
+}
+
+func (p *reportFilterParser) parseAnd() (string, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return "", err
+	}
+	for p.peek().isKeyword("AND") {
+		p.next()
+		right, err := p.parseFactor()
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + " AND " + right + ")"
+	}
+	return left, nil
+}
+
+func (p *reportFilterParser) parseFactor() (string, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > reportFilterMaxDepth {
+		return "", fmt.Errorf("nested deeper than %d levels at position %d", reportFilterMaxDepth, p.peek().pos)
+	}
+
+	tok := p.peek()
+	switch {
+	case tok.isKeyword("NOT"):
+		p.next()
+		inner, err := p.parseFactor()
+		if err != nil {
+			return "", err
+		}
+		return "NOT (" + inner + ")", nil
+	case tok.kind == filterTokenLParen:
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return "", err
+		}
+		if closing := p.next(); closing.kind != filterTokenRParen {
+			return "", fmt.Errorf("expected \")\" at position %d, got %s", closing.pos, closing)
+		}
+		return "(" + inner + ")", nil
+	}
+	return p.parseComparison()
+}
+
+func (p *reportFilterParser) parseComparison() (string, error) {
+	tok := p.next()
+	if tok.kind != filterTokenIdent {
+		return "", fmt.Errorf("expected a column at position %d, got %s", tok.pos, tok)
+	}
+	key := strings.ToLower(tok.text)
+	col, ok := p.scope.filterColumns[key]
+	if !ok {
+		if p.scope.hasColumn(key) {
+			return "", fmt.Errorf("column %s at position %d cannot be filtered, it is loaded after the query", key, tok.pos)
+		}
+		return "", fmt.Errorf("unknown column %q at position %d (available: %s)", tok.text, tok.pos, strings.Join(p.scope.sortKeys(), ", "))
+	}
+
+	op := p.next()
+	negate := false
+	if op.isKeyword("NOT") {
+		negate = true
+		op = p.next()
+		if !op.isKeyword("IN") && !op.isKeyword("LIKE") {
+			return "", fmt.Errorf("expected IN or LIKE after NOT at position %d, got %s", op.pos, op)
+		}
+	}
+	not := ""
+	if negate {
+		not = "NOT "
+	}
+
+	switch {
+	case op.isKeyword("IS"):
+		nullTok := p.next()
+		if nullTok.isKeyword("NOT") {
+			not = "NOT "
+			nullTok = p.next()
+		}
+		if !nullTok.isKeyword("NULL") {
+			return "", fmt.Errorf("expected NULL at position %d, got %s", nullTok.pos, nullTok)
+		}
+		return fmt.Sprintf("(%s) IS %sNULL", col.expr, not), nil
+
+	case op.isKeyword("IN"):
+		if open := p.next(); open.kind != filterTokenLParen {
+			return "", fmt.Errorf("expected \"(\" after IN at position %d, got %s", open.pos, open)
+		}
+		values := 0
+		for {
+			if err := p.parseValue(key, col); err != nil {
+				return "", err
+			}
+			values++
+			if values > reportFilterMaxValues {
+				return "", fmt.Errorf("IN list for %s has more than %d values", key, reportFilterMaxValues)
+			}
+			sep := p.next()
+			if sep.kind == filterTokenRParen {
+				break
+			}
+			if sep.kind != filterTokenComma {
+				return "", fmt.Errorf("expected \",\" or \")\" at position %d, got %s", sep.pos, sep)
+			}
+		}
+		return fmt.Sprintf("(%s) %sIN (%s)", col.expr, not, placeholders(values)), nil
+
+	case op.isKeyword("LIKE"):
+		if col.kind != reportKindString {
+			return "", fmt.Errorf("LIKE needs a text column, %s is a %s column (position %d)", key, col.kind, op.pos)
+		}
+		pattern := p.next()
+		if pattern.kind != filterTokenString {
+			return "", fmt.Errorf("expected a quoted pattern after LIKE at position %d, got %s", pattern.pos, pattern)
+		}
+		p.args = append(p.args, pattern.text)
+		return fmt.Sprintf("(%s) %sLIKE ?", col.expr, not), nil
+
+	case op.kind == filterTokenOp:
+		if p.peek().isKeyword("NULL") {
+			return "", fmt.Errorf("use IS NULL or IS NOT NULL instead of %s NULL at position %d", op.text, op.pos)
+		}
+		sqlOp := op.text
+		if sqlOp == "!=" {
+			sqlOp = "<>"
+		}
+		if col.kind == reportKindBool && sqlOp != "=" && sqlOp != "<>" {
+			return "", fmt.Errorf("%s is a boolean column, only = and != are allowed (position %d)", key, op.pos)
+		}
+		if err := p.parseValue(key, col); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(%s) %s ?", col.expr, sqlOp), nil
+	}
+	return "", fmt.Errorf("expected an operator, IN, LIKE or IS after %s at position %d, got %s", key, op.pos, op)
+}
+
+// parseValue reads one literal for column key and appends it as a query argument.
+func (p *reportFilterParser) parseValue(key string, col reportFilterColumn) error {
+	tok := p.next()
+	switch col.kind {
+	case reportKindString:
+		if tok.kind != filterTokenString {
+			return fmt.Errorf("%s needs a quoted string at position %d, got %s", key, tok.pos, tok)
+		}
+		p.args = append(p.args, tok.text)
+	case reportKindNumber:
+		if tok.kind != filterTokenNumber {
+			return fmt.Errorf("%s needs a number at position %d, got %s", key, tok.pos, tok)
+		}
+		if n, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			p.args = append(p.args, n)
+			return nil
+		}
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q at position %d", tok.text, tok.pos)
+		}
+		p.args = append(p.args, f)
+	case reportKindBool:
+		switch {
+		case tok.isKeyword("TRUE"):
+			p.args = append(p.args, true)
+		case tok.isKeyword("FALSE"):
+			p.args = append(p.args, false)
+		default:
+			return fmt.Errorf("%s needs true or false at position %d, got %s", key, tok.pos, tok)
+		}
+	case reportKindTime:
+		if tok.kind != filterTokenString {
+			return fmt.Errorf("%s needs a quoted time such as '2026-02-01' at position %d, got %s", key, tok.pos, tok)
+		}
+		t, err := parseReportFilterTime(tok.text)
+		if err != nil {
+			return fmt.Errorf("%s needs RFC3339 or YYYY-MM-DD[ HH:MM:SS] at position %d, got %q", key, tok.pos, tok.text)
+		}
+		p.args = append(p.args, t)
+	}
+	return nil
+}
+
+func parseReportFilterTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UTC(), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, v, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", v)
+}
+
+// ReportFilterTypes maps each filterable column of a report scope to its filter value
+// type: string, number, bool or time.
+func ReportFilterTypes(scope string) map[string]string {
+	sc := reportScopes[scope]
+	out := make(map[string]string, len(sc.filterColumns))
+	for key, col := range sc.filterColumns {
+		out[key] = col.kind
+	}
+	return out
+}
+
+// ReportSort is one ordering term of an ad-hoc report.
+type ReportSort struct {
+	Key  string `json:"key"`
+	Desc bool   `json:"desc"`
+}
+
+// NormalizeTransferReportSort parses transfer report sort terms. Without group-by any
+// report column can be sorted on; grouped reports sort on their dimensions and measures.
+func NormalizeTransferReportSort(terms, groupBy, measures []string) ([]ReportSort, error) {
+	if len(groupBy) > 0 {
+		return normalizeReportSort(terms, append(append([]string{}, groupBy...), measures...))
+	}
+	return NormalizeReportSort(ReportScopeTransfer, terms)
+}
+
+// NormalizeReportSort parses sort terms of the form "column" or "column asc|desc" for
+// the columns of a report scope that are known before paging.
+func NormalizeReportSort(scope string, terms []string) ([]ReportSort, error) {
+	sc, ok := reportScopes[scope]
+	if !ok {
+		return nil, fmt.Errorf("invalid report scope: %s", scope)
+	}
+	return normalizeReportSort(terms, sc.sortKeys())
+}
+
+func normalizeReportSort(terms, allowed []string) ([]ReportSort, error) {
+	out := make([]ReportSort, 0, len(terms))
+	seen := map[string]struct{}{}
+	for _, term := range terms {
+		fields := strings.Fields(strings.ToLower(term))
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("invalid sort %q: expected \"column\" or \"column asc|desc\"", term)
+		}
+		item := ReportSort{Key: fields[0]}
+		if !containsKey(allowed, item.Key) {
+			return nil, fmt.Errorf("invalid sort column %q (available: %s)", item.Key, strings.Join(allowed, ", "))
+		}
+		if len(fields) == 2 {
+			switch fields[1] {
+			case "asc":
+			case "desc":
+				item.Desc = true
+			default:
+				return nil, fmt.Errorf("invalid sort direction %q for %s: use asc or desc", fields[1], item.Key)
+			}
+		}
+		if _, ok := seen[item.Key]; ok {
+			return nil, fmt.Errorf("sort column %s listed twice", item.Key)
+		}
+		seen[item.Key] = struct{}{}
+		out = append(out, item)
+	}
+	if len(out) > reportMaxSortKeys {
+		return nil, fmt.Errorf("at most %d sort columns are allowed", reportMaxSortKeys)
+	}
+	return out, nil
+}
+
+// transferReportOrderBy renders sorts as an ORDER BY list. The transfer UUID is always
+// appended so paging is stable.
+func transferReportOrderBy(sorts []ReportSort) string {
+	return reportOrderBy(transferReportFilterColumns, sorts, "t.completed_at DESC", "t.transferUUID")
+}
+
+// reportOrderBy renders sorts over columns as an ORDER BY list, falling back to
+// defaultOrder, and always ends with the unique tiebreak expression.
+func reportOrderBy(columns map[string]reportFilterColumn, sorts []ReportSort, defaultOrder, tiebreak string) string {
+	if len(sorts) == 0 {
+		return defaultOrder + ", " + tiebreak
+	}
+	parts := make([]string, 0, len(sorts)+1)
+	for _, s := range sorts {
+		dir := "ASC"
+		if s.Desc {
+			dir = "DESC"
+		}
+		parts = append(parts, columns[s.Key].expr+" "+dir)
+	}
+	return strings.Join(append(parts, tiebreak), ", ")
+}
+
+// sortReportRows orders rows built in Go by sorts; ties keep their existing order.
+func sortReportRows(rows []map[string]any, sorts []ReportSort) {
+	if len(sorts) == 0 {
+		return
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, s := range sorts {
+			c := compareReportValues(rows[i][s.Key], rows[j][s.Key])
+			if c == 0 {
+				continue
+			}
+			if s.Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+func compareReportValues(a, b any) int {
+	switch av := a.(type) {
+	case int64:
+		if bv, ok := b.(int64); ok {
+			switch {
+			case av < bv:
+				return -1
+			case av > bv:
+				return 1
+			}
+			return 0
+		}
+	case float64:
+		if bv, ok := b.(float64); ok {
+			switch {
+			case av < bv:
+				return -1
+			case av > bv:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
@@ -0,0 +1,99 @@
+package mysql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompileTransferReportFilter(t *testing.T) {
+	clause, args, err := CompileTransferReportFilter(`status_code IN (2, 3) and (size_mb >= 1.5 OR name like '%it''s%') AND NOT recoverable = true AND completed_at < '2026-02-01' AND sip_uuid IS NOT NULL`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "(((((t.status) IN (?,?) AND ((((COALESCE(fc.total_bytes, 0) / 1048576)) >= ? OR (t.currentLocation) LIKE ?))) AND " +
+		"NOT (((COALESCE(fm.failed_markers, 0) > 0 AND COALESCE(sf.has_sip_output, 0) > 0)) = ?)) AND (t.completed_at) < ?) AND " +
+		"(NULLIF(sf.sip_uuid, '')) IS NOT NULL)"
+	if clause != want {
+		t.Fatalf("unexpected clause:\n got %s\nwant %s", clause, want)
+	}
+	wantArgs := []any{int64(2), int64(3), 1.5, "%it's%", true, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Fatalf("unexpected args %#v", args)
+	}
+
+	if clause, args, err := CompileTransferReportFilter("  "); err != nil || clause != "" || args != nil {
+		t.Fatalf("expected empty filter, got %q %v %v", clause, args, err)
+	}
+}
+
+func TestCompileTransferReportFilter_Errors(t *testing.T) {
+	cases := map[string]string{
+		`siz_mb > 1`:                        `unknown column "siz_mb" at position 1`,
+		`size_mb > 'big'`:                   `size_mb needs a number at position 11`,
+		`files_total LIKE '1%'`:             `LIKE needs a text column`,
+		`recoverable > true`:                `only = and != are allowed`,
+		`completed_at > 'yesterday'`:        `completed_at needs RFC3339`,
+		`status = NULL`:                     `use IS NULL`,
+		`status = 'FAILED' status = 'x'`:    `unexpected "status" at position 19`,
+		`(status = 'FAILED'`:                `expected ")"`,
+		`name = 'unterminated`:              `unterminated string starting at position 8`,
+		`status ! 'FAILED'`:                 `did you mean "!="`,
+		`status_code IN (1 2)`:              `expected "," or ")" at position 19`,
+		`status_code; DROP TABLE Transfers`: `unexpected character ';' at position 12`,
+		strings.Repeat("(", 30) + "status_code = 1" + strings.Repeat(")", 30): `nested deeper than`,
+	}
+	for expr, want := range cases {
+		_, _, err := CompileTransferReportFilter(expr)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("filter %q: expected error containing %q, got %v", expr, want, err)
+		}
+	}
+}
+
+func TestTransferReportFilterColumns_CoverReportColumns(t *testing.T) {
+	for _, c := range transferReportColumns {
+		if _, ok := transferReportFilterColumns[c.Key]; !ok {
+			t.Errorf("column %s has no filter expression", c.Key)
+		}
+	}
+}
+
+func TestNormalizeTransferReportSort(t *testing.T) {
+	sorts, err := NormalizeTransferReportSort([]string{"Failed_Tasks DESC", " name ", ""}, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sorts) != 2 || sorts[0] != (ReportSort{Key: "failed_tasks", Desc: true}) || sorts[1] != (ReportSort{Key: "name"}) {
+		t.Fatalf("unexpected sorts %+v", sorts)
+	}
+	if got := transferReportOrderBy(sorts); got != "COALESCE(fm.failed_markers, 0) DESC, t.currentLocation ASC, t.transferUUID" {
+		t.Fatalf("unexpected order by %q", got)
+	}
+	if got := transferReportOrderBy(nil); got != "t.completed_at DESC, t.transferUUID" {
+		t.Fatalf("unexpected default order by %q", got)
+	}
+
+	for _, terms := range [][]string{{"size"}, {"name sideways"}, {"name", "name desc"}, {"name asc extra"}} {
+		if _, err := NormalizeTransferReportSort(terms, nil, nil); err == nil {
+			t.Errorf("expected error for %v", terms)
+		}
+	}
+	if _, err := NormalizeTransferReportSort([]string{"size_mb"}, []string{"customer"}, []string{"count"}); err == nil {
+		t.Fatal("expected grouped sort to reject non-group columns")
+	}
+}
+
+func TestSortReportRows(t *testing.T) {
+	rows := []map[string]any{
+		{"customer": "b", "count": int64(2)},
+		{"customer": "a", "count": int64(2)},
+		{"customer": "c", "count": int64(9)},
+	}
+	sortReportRows(rows, []ReportSort{{Key: "count", Desc: true}, {Key: "customer"}})
+	got := []any{rows[0]["customer"], rows[1]["customer"], rows[2]["customer"]}
+	if !reflect.DeepEqual(got, []any{"c", "a", "b"}) {
+		t.Fatalf("unexpected order %v", got)
+	}
+}
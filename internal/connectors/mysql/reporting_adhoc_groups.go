@@ -0,0 +1,222 @@
+package mysql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// transferReportMaxGroupedRows caps the transfers aggregated by a grouped report.
+const transferReportMaxGroupedRows = 50000
+
+var transferReportGroupBy = []ReportColumn{
+	{Key: "status", Label: "Status"},
+	{Key: "customer", Label: "Customer"},
+	{Key: "day", Label: "Completed Day"},
+	{Key: "week", Label: "Completed Week (Monday)"},
+	{Key: "month", Label: "Completed Month"},
+	{Key: "microservice_group", Label: "Microservice Group (failed, else last)"},
+	{Key: "source_of_acquisition", Label: "Source Of Acquisition"},
+}
+
+var transferReportMeasures = []ReportColumn{
+	{Key: "count", Label: "Transfers"},
+	{Key: "sum_size_mb", Label: "Total Size (MB)"},
+	{Key: "avg_duration_seconds", Label: "Avg Duration (s)"},
+	{Key: "p95_duration_seconds", Label: "P95 Duration (s)"},
+	{Key: "sum_files", Label: "Files Total"},
+}
+
+// AvailableTransferReportGroupBy returns group-by dimensions accepted by RunTransferReport.
+func AvailableTransferReportGroupBy() []ReportColumn {
+	return append([]ReportColumn(nil), transferReportGroupBy...)
+}
+
+// AvailableTransferReportMeasures returns aggregate measures accepted by RunTransferReport.
+func AvailableTransferReportMeasures() []ReportColumn {
+	return append([]ReportColumn(nil), transferReportMeasures...)
+}
+
+// NormalizeTransferReportGrouping validates group-by dimensions and measures.
+// Measures default to count when grouping; measures without group-by are rejected.
+func NormalizeTransferReportGrouping(groupBy, measures []string) ([]string, []string, error) {
+	dims, err := normalizeReportKeys("group_by", groupBy, transferReportGroupBy)
+	if err != nil {
+		return nil, nil, err
+	}
+	ms, err := normalizeReportKeys("measure", measures, transferReportMeasures)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(dims) == 0 {
+		if len(ms) > 0 {
+			return nil, nil, fmt.Errorf("measures require at least one group_by dimension")
+		}
+		return nil, nil, nil
+	}
+	if len(ms) == 0 {
+		ms = []string{"count"}
+	}
+	return dims, ms, nil
+}
+
+func normalizeReportKeys(kind string, keys []string, catalogue []ReportColumn) ([]string, error) {
+	out := make([]string, 0, len(keys))
+	for _, k := range keys {
+		key := strings.ToLower(strings.TrimSpace(k))
+		if key == "" || containsKey(out, key) {
+			continue
+		}
+		known := false
+		for _, c := range catalogue {
+			if c.Key == key {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("invalid %s: %s", kind, key)
+		}
+		out = append(out, key)
+	}
+	return out, nil
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// transferReportRow is one scanned transfer with the raw values grouping needs.
+// UUID, Location, Accession and MappingStartedAt attribute it to customers.
+type transferReportRow struct {
+	UUID              string
+	Location          string
+	Accession         string
+	MappingStartedAt  *time.Time
+	CompletedAt       time.Time
+	Status            string
+	Source            string
+	MicroserviceGroup string
+	DurationSeconds   int64
+	SizeBytes         int64
+	FilesTotal        int64
+}
+
+func (r transferReportRow) mappingTransfer() mappingRuleTransfer {
+	return mappingRuleTransfer{UUID: r.UUID, Location: r.Location, Source: r.Source, Accession: r.Accession, StartedAt: r.MappingStartedAt}
+}
+
+type transferReportGroup struct {
+	keys      []string
+	count     int64
+	sizeBytes int64
+	files     int64
+	durations []int64
+}
+
+// groupTransferReportRows aggregates rows by groupBy and returns one map per group
+// holding the dimension values and measures, ordered by dimension values. A transfer
+// owned by several customers counts in each customer's group. customers may be nil,
+// see customerAttribution.
+func groupTransferReportRows(rows []transferReportRow, groupBy, measures []string, customers *customerAttribution) []map[string]any {
+	groups := map[string]*transferReportGroup{}
+	for _, row := range rows {
+		for _, keys := range transferReportGroupKeys(row, groupBy, customers) {
+			id := strings.Join(keys, "\x00")
+			g, ok := groups[id]
+			if !ok {
+				g = &transferReportGroup{keys: keys}
+				groups[id] = g
+			}
+			g.count++
+			g.sizeBytes += row.SizeBytes
+			g.files += row.FilesTotal
+			g.durations = append(g.durations, row.DurationSeconds)
+		}
+	}
+
+	ordered := make([]*transferReportGroup, 0, len(groups))
+	for _, g := range groups {
+		ordered = append(ordered, g)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		for k := range ordered[i].keys {
+			if ordered[i].keys[k] != ordered[j].keys[k] {
+				return ordered[i].keys[k] < ordered[j].keys[k]
+			}
+		}
+		return false
+	})
+
+	out := make([]map[string]any, 0, len(ordered))
+	for _, g := range ordered {
+		item := make(map[string]any, len(groupBy)+len(measures))
+		for i, dim := range groupBy {
+			item[dim] = g.keys[i]
+		}
+		avg, _, p95 := durationStats(g.durations)
+		for _, m := range measures {
+			switch m {
+			case "count":
+				item[m] = g.count
+			case "sum_size_mb":
+				item[m] = round2(float64(g.sizeBytes) / 1024.0 / 1024.0)
+			case "avg_duration_seconds":
+				item[m] = avg
+			case "p95_duration_seconds":
+				item[m] = p95
+			case "sum_files":
+				item[m] = g.files
+			}
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+// transferReportGroupKeys returns the group keys a row belongs to; only the
+// customer dimension can yield more than one value.
+func transferReportGroupKeys(row transferReportRow, groupBy []string, customers *customerAttribution) [][]string {
+	combos := [][]string{{}}
+	for _, dim := range groupBy {
+		var values []string
+		switch dim {
+		case "status":
+			values = []string{row.Status}
+		case "customer":
+			values = customers.customersFor(row.mappingTransfer())
+		case "day":
+			values = []string{transferReportBucket(row.CompletedAt, BucketDay)}
+		case "week":
+			values = []string{transferReportBucket(row.CompletedAt, BucketWeek)}
+		case "month":
+			values = []string{transferReportBucket(row.CompletedAt, BucketMonth)}
+		case "microservice_group":
+			values = []string{row.MicroserviceGroup}
+		case "source_of_acquisition":
+			values = []string{row.Source}
+		}
+		next := make([][]string, 0, len(combos)*len(values))
+		for _, c := range combos {
+			for _, v := range values {
+				next = append(next, append(append([]string(nil), c...), v))
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// transferReportBucket formats t as a UTC day, Monday-week or month key.
+func transferReportBucket(t time.Time, bucket string) string {
+	if t.IsZero() {
+		return ""
+	}
+	return ReportPeriod{Bucket: bucket}.BucketKey(t)
+}
@@ -0,0 +1,72 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeTransferReportGrouping(t *testing.T) {
+	dims, ms, err := NormalizeTransferReportGrouping([]string{" Customer ", "week", "customer"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(dims) != 2 || dims[0] != "customer" || dims[1] != "week" {
+		t.Fatalf("unexpected dimensions %v", dims)
+	}
+	if len(ms) != 1 || ms[0] != "count" {
+		t.Fatalf("expected default count measure, got %v", ms)
+	}
+
+	if dims, ms, err := NormalizeTransferReportGrouping(nil, nil); err != nil || dims != nil || ms != nil {
+		t.Fatalf("expected ungrouped report, got %v %v %v", dims, ms, err)
+	}
+	if _, _, err := NormalizeTransferReportGrouping([]string{"hour"}, nil); err == nil {
+		t.Fatal("expected error for unknown dimension")
+	}
+	if _, _, err := NormalizeTransferReportGrouping([]string{"status"}, []string{"max_size"}); err == nil {
+		t.Fatal("expected error for unknown measure")
+	}
+	if _, _, err := NormalizeTransferReportGrouping(nil, []string{"count"}); err == nil {
+		t.Fatal("expected error for measures without group_by")
+	}
+}
+
+func TestGroupTransferReportRows(t *testing.T) {
+	thursday := time.Date(2026, 2, 5, 10, 0, 0, 0, time.UTC)
+	nextMonday := time.Date(2026, 2, 9, 8, 0, 0, 0, time.UTC)
+	rows := []transferReportRow{
+		{CompletedAt: thursday, Status: "COMPLETED", Source: "shared-ftp", DurationSeconds: 10, SizeBytes: 1024 * 1024, FilesTotal: 3},
+		{CompletedAt: thursday, Status: "COMPLETED", Source: "acme-ftp", DurationSeconds: 30, SizeBytes: 2 * 1024 * 1024, FilesTotal: 1},
+		{CompletedAt: nextMonday, Status: "FAILED", Source: "unknown", DurationSeconds: 5, FilesTotal: 2},
+	}
+	customers := map[string][]string{
+		"acme-ftp":   {"acme"},
+		"shared-ftp": {"acme", "globex"},
+	}
+
+	got := groupTransferReportRows(rows, []string{"customer", "week"}, []string{"count", "sum_size_mb", "sum_files", "p95_duration_seconds"}, testAttribution(customers, nil))
+	if len(got) != 3 {
+		t.Fatalf("expected 3 groups, got %+v", got)
+	}
+	acme := got[0]
+	if acme["customer"] != "acme" || acme["week"] != "2026-02-02" {
+		t.Fatalf("unexpected first group %+v", acme)
+	}
+	if acme["count"] != int64(2) || acme["sum_size_mb"] != 3.0 || acme["sum_files"] != int64(4) || acme["p95_duration_seconds"] != int64(30) {
+		t.Fatalf("unexpected acme measures %+v", acme)
+	}
+	if got[1]["customer"] != "globex" || got[1]["count"] != int64(1) {
+		t.Fatalf("expected shared source counted for globex, got %+v", got[1])
+	}
+	if got[2]["customer"] != forecastUnmappedCustomer || got[2]["week"] != "2026-02-09" {
+		t.Fatalf("unexpected unmapped group %+v", got[2])
+	}
+	if _, ok := acme["avg_duration_seconds"]; ok {
+		t.Fatalf("unrequested measure present in %+v", acme)
+	}
+
+	byStatus := groupTransferReportRows(rows, []string{"status"}, []string{"count"}, nil)
+	if len(byStatus) != 2 || byStatus[0]["status"] != "COMPLETED" || byStatus[0]["count"] != int64(2) {
+		t.Fatalf("unexpected status groups %+v", byStatus)
+	}
+}
@@ -0,0 +1,291 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-am-realtime-report-ui/internal/dbctx"
+)
+
+// Report scopes accepted by the ad-hoc report builder and report templates.
+const (
+	ReportScopeTransfer = "transfer"
+	ReportScopeSIP      = "sip"
+	ReportScopeAIP      = "aip"
+	ReportScopeFile     = "file"
+)
+
+// ReportQueryOptions controls SIP, AIP and file report execution; transfer reports use
+// TransferReportOptions.
+type ReportQueryOptions struct {
+	DateFrom   time.Time
+	DateTo     time.Time
+	CustomerID string
+	Limit      int
+	Offset     int
+	Columns    []string
+	Filter     string
+	Sort       []string
+}
+
+// ReportQueryResult contains one page of SIP, AIP or file report rows.
+type ReportQueryResult struct {
+	Rows  []map[string]any `json:"rows"`
+	Total int64            `json:"total"`
+}
+
+// reportScope is the column catalogue of one report scope.
+type reportScope struct {
+	columns        []ReportColumn
+	defaultColumns []string
+	// filterColumns back filter and sort expressions of scopes queried in SQL; nil for
+	// scopes whose rows are assembled in Go.
+	filterColumns map[string]reportFilterColumn
+	// sortColumns are the sortable columns of scopes without filterColumns.
+	sortColumns []string
+	// lateColumns are filled by the caller after the query (other backends).
+	lateColumns []string
+	source      *sqlReportSource
+}
+
+// sqlReportSource describes how a SQL-backed scope is queried. from must join
+// Transfers as t so the customer filter applies.
+type sqlReportSource struct {
+	from         string
+	window       string
+	defaultOrder string
+	tiebreak     string
+	finish       func(row map[string]any)
+}
+
+var reportScopeOrder = []string{ReportScopeTransfer, ReportScopeSIP, ReportScopeAIP, ReportScopeFile}
+
+var reportScopes = map[string]reportScope{
+	ReportScopeTransfer: {
+		columns:        transferReportColumns,
+		defaultColumns: []string{"transfer_uuid", "name", "status", "completed_at", "duration_seconds", "files_total", "failed_tasks"},
+		filterColumns:  transferReportFilterColumns,
+	},
+	ReportScopeSIP: {
+		columns:        sipReportColumns,
+		defaultColumns: []string{"sip_uuid", "name", "status", "completed_at", "ingest_duration_seconds", "files_original", "files_normalized", "aip_size_mb"},
+		filterColumns:  sipReportFilterColumns,
+		lateColumns:    []string{"aip_size_mb"},
+		source:         sipReportSource,
+	},
+	ReportScopeAIP: {
+		columns:        aipReportColumns,
+		defaultColumns: []string{"aip_uuid", "name", "size_mb", "stored_at", "customer_id", "files_total", "format_count", "unknown_formats"},
+		sortColumns:    aipReportSortColumns,
+		lateColumns:    aipReportStatsColumns,
+	},
+	ReportScopeFile: {
+		columns:        fileReportColumns,
+		defaultColumns: []string{"file_uuid", "file_name", "file_group", "size_bytes", "pronom_id", "format_name", "identification_tool", "derivatives"},
+		filterColumns:  fileReportFilterColumns,
+		source:         fileReportSource,
+	},
+}
+
+func (sc reportScope) hasColumn(key string) bool {
+	for _, c := range sc.columns {
+		if c.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// sortKeys lists the columns that can be filtered (SQL scopes) or sorted, in
+// catalogue order.
+func (sc reportScope) sortKeys() []string {
+	out := make([]string, 0, len(sc.columns))
+	for _, c := range sc.columns {
+		if _, ok := sc.filterColumns[c.Key]; ok || containsKey(sc.sortColumns, c.Key) {
+			out = append(out, c.Key)
+		}
+	}
+	return out
+}
+
+// ReportScopes lists the supported report scopes.
+func ReportScopes() []string {
+	return append([]string(nil), reportScopeOrder...)
+}
+
+// NormalizeReportScope validates a report scope; empty means transfer.
+func NormalizeReportScope(scope string) (string, error) {
+	scope = strings.ToLower(strings.TrimSpace(scope))
+	if scope == "" {
+		return ReportScopeTransfer, nil
+	}
+	if _, ok := reportScopes[scope]; !ok {
+		return "", fmt.Errorf("invalid report scope: %s (available: %s)", scope, strings.Join(reportScopeOrder, ", "))
+	}
+	return scope, nil
+}
+
+// AvailableReportColumns returns the column catalogue of a report scope.
+func AvailableReportColumns(scope string) []ReportColumn {
+	return append([]ReportColumn(nil), reportScopes[scope].columns...)
+}
+
+// NormalizeReportColumns validates selected columns of a report scope, dropping unknown
+// ones and falling back to the scope defaults.
+func NormalizeReportColumns(scope string, columns []string) []string {
+	if scope == ReportScopeTransfer {
+		return NormalizeTransferReportColumns(columns)
+	}
+	sc := reportScopes[scope]
+	out := make([]string, 0, len(columns))
+	for _, c := range columns {
+		key := strings.ToLower(strings.TrimSpace(c))
+		if key == "" || containsKey(out, key) || !sc.hasColumn(key) {
+			continue
+		}
+		out = append(out, key)
+	}
+	if len(out) == 0 {
+		return append([]string(nil), sc.defaultColumns...)
+	}
+	return out
+}
+
+// ReportLateColumns returns the selected columns the caller fills after the query
+// from other backends (Storage Service, Elasticsearch).
+func ReportLateColumns(scope string, columns []string) []string {
+	out := make([]string, 0)
+	for _, c := range columns {
+		if containsKey(reportScopes[scope].lateColumns, c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// RunScopedReport executes a SIP or file report; AIP reports need Storage Service
+// packages and run through RunAIPReport, transfer reports through RunTransferReport.
+func (s *Store) RunScopedReport(ctx context.Context, scope string, opts ReportQueryOptions) (*ReportQueryResult, error) {
+	sc, ok := reportScopes[scope]
+	if !ok || sc.source == nil {
+		return nil, fmt.Errorf("report scope %s is not queried from MCP", scope)
+	}
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	sorts, err := NormalizeReportSort(scope, opts.Sort)
+	if err != nil {
+		return nil, err
+	}
+	exprClause, exprArgs, err := CompileReportFilter(scope, opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+	customerClause, customerArgs, err := s.sourceFilterClause(ctx, opts.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+
+	src := sc.source
+	where := fmt.Sprintf("WHERE %s >= ? AND %s < ?", src.window, src.window)
+	args := []any{opts.DateFrom, opts.DateTo}
+	if customerClause != "" {
+		where += " " + customerClause
+		args = append(args, customerArgs...)
+	}
+	if exprClause != "" {
+		where += " AND " + exprClause
+		args = append(args, exprArgs...)
+	}
+
+	var total sql.NullInt64
+	countQuery := fmt.Sprintf("\nSELECT COUNT(*)\n%s\n%s;\n", src.from, where)
+	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(sc.columns))
+	selects := make([]string, 0, len(sc.columns))
+	for _, c := range sc.columns {
+		col, ok := sc.filterColumns[c.Key]
+		if !ok {
+			continue
+		}
+		keys = append(keys, c.Key)
+		selects = append(selects, "  "+col.expr+" AS "+c.Key)
+	}
+	q := fmt.Sprintf("\nSELECT\n%s\n%s\n%s\nORDER BY %s\nLIMIT ? OFFSET ?;\n",
+		strings.Join(selects, ",\n"), src.from, where,
+		reportOrderBy(sc.filterColumns, sorts, src.defaultOrder, src.tiebreak))
+	queryArgs := append(append([]any{}, args...), opts.Limit, opts.Offset)
+
+	rows, err := s.db.QueryContext(ctx, q, queryArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := NormalizeReportColumns(scope, opts.Columns)
+	out := make([]map[string]any, 0, opts.Limit)
+	for rows.Next() {
+		dest := make([]any, len(keys))
+		for i, key := range keys {
+			dest[i] = newReportScanValue(sc.filterColumns[key])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		rowAll := make(map[string]any, len(keys))
+		for i, key := range keys {
+			rowAll[key] = reportScanValue(dest[i])
+		}
+		if src.finish != nil {
+			src.finish(rowAll)
+		}
+		row := make(map[string]any, len(columns))
+		for _, c := range columns {
+			row[c] = rowAll[c]
+		}
+		out = append(out, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &ReportQueryResult{Rows: out, Total: nullInt64Value(total)}, nil
+}
+
+func newReportScanValue(col reportFilterColumn) any {
+	switch {
+	case col.kind == reportKindTime:
+		return &sql.NullTime{}
+	case col.kind == reportKindBool:
+		return &sql.NullBool{}
+	case col.kind == reportKindNumber && col.decimal:
+		return &sql.NullFloat64{}
+	case col.kind == reportKindNumber:
+		return &sql.NullInt64{}
+	default:
+		return &sql.NullString{}
+	}
+}
+
+// reportScanValue converts a scanned value to its JSON form: empty strings for NULL
+// text and times, zero for NULL numbers.
+func reportScanValue(v any) any {
+	switch x := v.(type) {
+	case *sql.NullTime:
+		return formatNullTime(*x)
+	case *sql.NullBool:
+		return x.Valid && x.Bool
+	case *sql.NullFloat64:
+		return round2(x.Float64)
+	case *sql.NullInt64:
+		return x.Int64
+	case *sql.NullString:
+		return x.String
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package mysql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeReportScope(t *testing.T) {
+	for in, want := range map[string]string{"": "transfer", " SIP ": "sip", "aip": "aip", "File": "file"} {
+		got, err := NormalizeReportScope(in)
+		if err != nil || got != want {
+			t.Errorf("scope %q: got %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := NormalizeReportScope("dip"); err == nil || !strings.Contains(err.Error(), "invalid report scope") {
+		t.Fatalf("expected invalid scope error, got %v", err)
+	}
+}
+
+func TestReportScopes_CatalogueConsistency(t *testing.T) {
+	for _, name := range ReportScopes() {
+		sc := reportScopes[name]
+		for key := range sc.filterColumns {
+			if !sc.hasColumn(key) {
+				t.Errorf("%s: filter column %s is not in the catalogue", name, key)
+			}
+		}
+		for _, key := range append(append(append([]string{}, sc.defaultColumns...), sc.sortColumns...), sc.lateColumns...) {
+			if !sc.hasColumn(key) {
+				t.Errorf("%s: column %s is not in the catalogue", name, key)
+			}
+		}
+		for _, c := range sc.columns {
+			_, filterable := sc.filterColumns[c.Key]
+			late := containsKey(sc.lateColumns, c.Key)
+			if sc.source != nil && !filterable && !late {
+				t.Errorf("%s: column %s is neither queried nor filled late", name, c.Key)
+			}
+		}
+	}
+}
+
+func TestNormalizeReportColumns(t *testing.T) {
+	got := NormalizeReportColumns(ReportScopeFile, []string{" PRONOM_ID ", "nope", "pronom_id", "file_name"})
+	if want := []string{"pronom_id", "file_name"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if got := NormalizeReportColumns(ReportScopeSIP, []string{"duration_seconds"}); !reflect.DeepEqual(got, reportScopes[ReportScopeSIP].defaultColumns) {
+		t.Fatalf("expected SIP defaults, got %v", got)
+	}
+	if got := ReportLateColumns(ReportScopeAIP, []string{"aip_uuid", "files_total", "top_format"}); !reflect.DeepEqual(got, []string{"files_total", "top_format"}) {
+		t.Fatalf("unexpected late columns %v", got)
+	}
+}
+
+func TestCompileReportFilter_Scopes(t *testing.T) {
+	clause, args, err := CompileReportFilter(ReportScopeFile, "format_name LIKE 'PDF%' AND NOT normalized = true")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "((NULLIF(fid.format_name, '')) LIKE ? AND NOT (((COALESCE(dv.derivatives, 0) > 0)) = ?))"; clause != want {
+		t.Fatalf("unexpected clause:\n got %s\nwant %s", clause, want)
+	}
+	if !reflect.DeepEqual(args, []any{"PDF%", true}) {
+		t.Fatalf("unexpected args %#v", args)
+	}
+
+	if _, _, err := CompileReportFilter(ReportScopeSIP, "aip_size_mb > 10"); err == nil || !strings.Contains(err.Error(), "loaded after the query") {
+		t.Fatalf("expected late column error, got %v", err)
+	}
+	if _, _, err := CompileReportFilter(ReportScopeSIP, "duration_seconds > 10"); err == nil || !strings.Contains(err.Error(), "unknown column") {
+		t.Fatalf("expected unknown column error, got %v", err)
+	}
+	if _, _, err := CompileReportFilter(ReportScopeAIP, "size_mb > 10"); err == nil || !strings.Contains(err.Error(), "not supported for aip reports") {
+		t.Fatalf("expected unsupported error, got %v", err)
+	}
+	if clause, _, err := CompileReportFilter(ReportScopeAIP, ""); err != nil || clause != "" {
+		t.Fatalf("empty AIP filter should pass, got %q %v", clause, err)
+	}
+}
+
+func TestNormalizeReportSort_AIP(t *testing.T) {
+	sorts, err := NormalizeReportSort(ReportScopeAIP, []string{"size_mb desc"})
+	if err != nil || !reflect.DeepEqual(sorts, []ReportSort{{Key: "size_mb", Desc: true}}) {
+		t.Fatalf("unexpected sorts %v %v", sorts, err)
+	}
+	if _, err := NormalizeReportSort(ReportScopeAIP, []string{"files_total"}); err == nil {
+		t.Fatal("expected Elasticsearch columns to be unsortable")
+	}
+}
+
+func TestComputeAIPReportRows(t *testing.T) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	packages := []StoragePackage{
+		{UUID: "aip-1", SIPUUID: "AIP-1", PackageType: "AIP", SizeBytes: 3 * 1048576, StoredAt: day, Location: "primary"},
+		{UUID: "rep-1", SIPUUID: "aip-1", PackageType: "replica", SizeBytes: 3 * 1048576, StoredAt: day.Add(time.Hour), Location: "offsite"},
+		{UUID: "aip-2", SIPUUID: "aip-2", PackageType: "AIP", SizeBytes: 1572864, StoredAt: day.Add(2 * time.Hour)},
+		{UUID: "aip-3", SIPUUID: "aip-3", PackageType: "AIP", SizeBytes: 1048576, StoredAt: day.Add(time.Hour)},
+	}
+	transfers := map[string]mappingRuleTransfer{"aip-1": {Source: "acme-ingest"}, "aip-2": {Source: "other"}}
+	names := map[string]string{"aip-1": "first", "aip-2": "second"}
+	attribution := testAttribution(map[string][]string{"acme-ingest": {"acme"}}, nil)
+
+	rows := computeAIPReportRows(packages, transfers, names, attribution, nil, nil)
+	var order []string
+	for _, r := range rows {
+		order = append(order, r["aip_uuid"].(string))
+	}
+	if want := []string{"aip-2", "aip-3", "rep-1", "aip-1"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("unexpected default order %v, want %v", order, want)
+	}
+	replica := rows[2]
+	if replica["replica_of"] != "aip-1" || replica["name"] != "first" || replica["customer_id"] != "acme" {
+		t.Fatalf("unexpected replica row %v", replica)
+	}
+	if rows[3]["replica_of"] != "" || rows[3]["size_mb"] != 3.0 {
+		t.Fatalf("unexpected AIP row %v", rows[3])
+	}
+	if rows[0]["customer_id"] != forecastUnmappedCustomer || rows[1]["customer_id"] != forecastUnmappedCustomer {
+		t.Fatalf("expected unmapped customers, got %v / %v", rows[0]["customer_id"], rows[1]["customer_id"])
+	}
+
+	acme := computeAIPReportRows(packages, transfers, names, attribution, []string{"acme"}, []ReportSort{{Key: "location"}})
+	if len(acme) != 2 || acme[0]["aip_uuid"] != "rep-1" || acme[1]["aip_uuid"] != "aip-1" {
+		t.Fatalf("unexpected customer rows %v", acme)
+	}
+}
+
+func TestComputeAIPReportRows_AttributesAtTransferStart(t *testing.T) {
+	switchover := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	before, after := switchover.AddDate(0, 0, -1), switchover.AddDate(0, 0, 1)
+	rules, err := compileMappingRules([]CustomerMappingRule{{ID: 1, CustomerID: "initech", Field: "accession_id", MatchType: "prefix", Pattern: "INI-", Enabled: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attribution := &customerAttribution{bySource: map[string][]customerSourceOwner{
+		"shared-ftp": {
+			{CustomerID: "acme", Mapping: CustomerSourceMapping{SourceOfAcquisition: "shared-ftp", EffectiveTo: &switchover}},
+			{CustomerID: "globex", Mapping: CustomerSourceMapping{SourceOfAcquisition: "shared-ftp", EffectiveFrom: &switchover}},
+		},
+	}, rules: rules}
+	packages := []StoragePackage{
+		{UUID: "aip-1", SIPUUID: "aip-1", StoredAt: before},
+		{UUID: "aip-2", SIPUUID: "aip-2", StoredAt: after},
+		{UUID: "aip-3", SIPUUID: "aip-3", StoredAt: after.Add(time.Hour)},
+	}
+	transfers := map[string]mappingRuleTransfer{
+		"aip-1": {Source: "shared-ftp", StartedAt: &before},
+		"aip-2": {Source: "shared-ftp", StartedAt: &after},
+		"aip-3": {Source: "scanner", Accession: "INI-9", StartedAt: &after},
+	}
+
+	rows := computeAIPReportRows(packages, transfers, nil, attribution, nil, []ReportSort{{Key: "aip_uuid"}})
+	var got []string
+	for _, r := range rows {
+		got = append(got, r["customer_id"].(string))
+	}
+	if want := []string{"acme", "globex", "initech"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected customers %v, want %v", got, want)
+	}
+	if parent := computeAIPReportRows(packages, transfers, nil, attribution, []string{"holding", "globex", "initech"}, nil); len(parent) != 2 {
+		t.Fatalf("expected the members' packages, got %v", parent)
+	}
+}
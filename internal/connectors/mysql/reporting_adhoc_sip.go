@@ -0,0 +1,104 @@
+package mysql
+
+var sipReportColumns = []ReportColumn{
+	{Key: "sip_uuid", Label: "SIP UUID"},
+	{Key: "name", Label: "SIP Name"},
+	{Key: "status", Label: "Status"},
+	{Key: "sip_type", Label: "SIP Type"},
+	{Key: "aip_filename", Label: "AIP Filename"},
+	{Key: "transfer_uuid", Label: "Transfer UUID"},
+	{Key: "source_of_acquisition", Label: "Source Of Acquisition"},
+	{Key: "created_at", Label: "Created At"},
+	{Key: "completed_at", Label: "Completed At"},
+	{Key: "ingest_duration_seconds", Label: "Ingest Duration (s)"},
+	{Key: "files_total", Label: "Files Total"},
+	{Key: "files_original", Label: "Files Original"},
+	{Key: "files_preservation", Label: "Preservation Files"},
+	{Key: "files_normalized", Label: "Originals Normalized"},
+	{Key: "normalization_failures", Label: "Normalization Failures"},
+	{Key: "failed_jobs", Label: "Failed Jobs"},
+	{Key: "size_mb", Label: "Files Size (MB)"},
+	{Key: "aip_size_mb", Label: "AIP Size (MB, Storage Service)"},
+}
+
+var sipReportFilterColumns = map[string]reportFilterColumn{
+	"sip_uuid": {expr: "s.sipUUID", kind: reportKindString},
+	// Turned into a display name after the query; filters match the path.
+	"name": {expr: "COALESCE(s.currentPath, '')", kind: reportKindString},
+	"status": {expr: `CASE
+    WHEN COALESCE(s.status, 0) = 0 AND COALESCE(sj.failed_jobs, 0) > 0 THEN 'FAILED'
+    WHEN COALESCE(s.status, 0) = 0 THEN 'UNKNOWN'
+    WHEN COALESCE(sj.failed_jobs, 0) > 0 AND s.status IN (2, 3) THEN 'FAILED_OR_ABORTED'
+    WHEN s.status = 1 THEN 'RUNNING'
+    WHEN s.status = 2 THEN 'SUCCESS'
+    WHEN s.status = 3 THEN 'SUCCESS_WITH_WARNINGS'
+    WHEN s.status = 4 THEN 'FAILED'
+    ELSE 'UNKNOWN'
+  END`, kind: reportKindString},
+	"sip_type":              {expr: "NULLIF(s.sipType, '')", kind: reportKindString},
+	"aip_filename":          {expr: "NULLIF(s.aipFilename, '')", kind: reportKindString},
+	"transfer_uuid":         {expr: "sfc.transferUUID", kind: reportKindString},
+	"source_of_acquisition": {expr: "NULLIF(t.sourceOfAcquisition, '')", kind: reportKindString},
+	"created_at":            {expr: "s.createdTime", kind: reportKindTime},
+	"completed_at":          {expr: "s.completed_at", kind: reportKindTime},
+	"ingest_duration_seconds": {expr: `COALESCE(
+    TIMESTAMPDIFF(SECOND, sj.started_at, COALESCE(s.completed_at, sj.finished_at)),
+    0
+  )`, kind: reportKindNumber},
+	"files_total":            {expr: "COALESCE(sfc.total_files, 0)", kind: reportKindNumber},
+	"files_original":         {expr: "COALESCE(sfc.original_files, 0)", kind: reportKindNumber},
+	"files_preservation":     {expr: "COALESCE(sfc.preservation_files, 0)", kind: reportKindNumber},
+	"files_normalized":       {expr: "COALESCE(sfc.normalized_files, 0)", kind: reportKindNumber},
+	"normalization_failures": {expr: "COALESCE(sj.normalization_failures, 0)", kind: reportKindNumber},
+	"failed_jobs":            {expr: "COALESCE(sj.failed_jobs, 0)", kind: reportKindNumber},
+	"size_mb":                {expr: "(COALESCE(sfc.total_bytes, 0) / 1048576)", kind: reportKindNumber, decimal: true},
+}
+
+// sipReportSource reports SIPs completed in the window. A SIP is attributed to the
+// transfer contributing its first file, which also decides the customer.
+var sipReportSource = &sqlReportSource{
+	from: `FROM SIPs s
+LEFT JOIN (
+  SELECT
+    f.sipUUID,
+    MIN(f.transferUUID) AS transferUUID,
+    COUNT(*) AS total_files,
+    SUM(CASE WHEN LOWER(f.fileGrpUse) = 'original' THEN 1 ELSE 0 END) AS original_files,
+    SUM(CASE WHEN LOWER(f.fileGrpUse) = 'preservation' THEN 1 ELSE 0 END) AS preservation_files,
+    SUM(CASE WHEN LOWER(f.fileGrpUse) = 'original' AND d.sourceFileUUID IS NOT NULL THEN 1 ELSE 0 END) AS normalized_files,
+    SUM(COALESCE(f.fileSize, 0)) AS total_bytes
+  FROM Files f
+  LEFT JOIN (
+    SELECT DISTINCT sourceFileUUID
+    FROM Derivations
+  ) d
+    ON d.sourceFileUUID = f.fileUUID
+  WHERE f.sipUUID IS NOT NULL
+  GROUP BY f.sipUUID
+) sfc
+  ON sfc.sipUUID = s.sipUUID
+LEFT JOIN Transfers t
+  ON t.transferUUID = sfc.transferUUID
+LEFT JOIN (
+  SELECT
+    j.SIPUUID,
+    MIN(COALESCE(tsk.startTime, j.createdTime)) AS started_at,
+    MAX(COALESCE(tsk.endTime, tsk.startTime, j.createdTime)) AS finished_at,
+    COUNT(DISTINCT CASE WHEN COALESCE(tsk.exitCode, 0) <> 0 AND j.currentStep = 4 THEN j.jobUUID END) AS failed_jobs,
+    SUM(CASE WHEN j.microserviceGroup LIKE 'Normalize%' AND COALESCE(tsk.exitCode, 0) <> 0 THEN 1 ELSE 0 END) AS normalization_failures
+  FROM Jobs j
+  LEFT JOIN Tasks tsk
+    ON tsk.jobuuid = j.jobUUID
+  WHERE j.unitType = 'unitSIP'
+  GROUP BY j.SIPUUID
+) sj
+  ON sj.SIPUUID = s.sipUUID`,
+	window:       "s.completed_at",
+	defaultOrder: "s.completed_at DESC",
+	tiebreak:     "s.sipUUID",
+	finish: func(row map[string]any) {
+		path, _ := row["name"].(string)
+		sipUUID, _ := row["sip_uuid"].(string)
+		row["name"] = transferNameFromLocation(path, sipUUID)
+	},
+}
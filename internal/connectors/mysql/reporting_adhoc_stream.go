@@ -0,0 +1,81 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// transferReportMaxStreams bounds concurrent report streams; each holds a database
+// connection until its last row has been sent to the client.
+const transferReportMaxStreams = 2
+
+// ErrReportStreamBusy is returned by StreamTransferReport when
+// transferReportMaxStreams streams are already running.
+var ErrReportStreamBusy = errors.New("too many report exports running, retry later")
+
+// ExportTimeout returns the query timeout of streaming exports.
+func (s *Store) ExportTimeout() time.Duration {
+	if s.exportTimeout > 0 {
+		return s.exportTimeout
+	}
+	return s.queryTimeout
+}
+
+// StreamTransferReport runs a transfer report without a row cap and calls emit for
+// each row, in report order, as it is read from the server, so memory use does not
+// grow with the result. Limit, Offset and grouping are not supported. The query runs
+// under ExportTimeout instead of the regular query timeout and stops when ctx is
+// cancelled or emit fails. It returns the number of rows emitted.
+func (s *Store) StreamTransferReport(ctx context.Context, opts TransferReportOptions, emit func(row map[string]any) error) (int64, error) {
+	if len(opts.GroupBy) > 0 {
+		return 0, errors.New("group_by is not supported by streaming exports, use the paginated report")
+	}
+	sorts, err := NormalizeTransferReportSort(opts.Sort, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	select {
+	case s.exportSlots <- struct{}{}:
+		defer func() { <-s.exportSlots }()
+	default:
+		return 0, ErrReportStreamBusy
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.ExportTimeout())
+	defer cancel()
+
+	where, args, _, err := s.transferReportWhere(ctx, opts)
+	if err != nil {
+		return 0, err
+	}
+	// database/sql reads the result set from the connection as rows.Next advances,
+	// so rows are never buffered beyond the driver's packet buffer.
+	rows, err := s.db.QueryContext(ctx, transferReportSelect(where, sorts, false)+";\n", args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns := NormalizeTransferReportColumns(opts.Columns)
+	var n int64
+	for rows.Next() {
+		rowAll, _, err := scanTransferReportRow(rows, false)
+		if err != nil {
+			return n, err
+		}
+		row := make(map[string]any, len(columns))
+		for _, c := range columns {
+			row[c] = rowAll[c]
+		}
+		if err := emit(row); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	return n, ctx.Err()
+}
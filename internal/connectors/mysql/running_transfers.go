@@ -10,6 +10,7 @@ import (
 
 	"go-am-realtime-report-ui/internal/config"
 	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+	"go-am-realtime-report-ui/internal/dbctx"
 )
 
 // RunningTransfer is the live troubleshooting view for a transfer in progress.
@@ -42,6 +43,8 @@ type RunningSIP struct {
 type Store struct {
 	db                       *sql.DB
 	queryTimeout             time.Duration
+	exportTimeout            time.Duration
+	exportSlots              chan struct{}
 	stuckAfter               time.Duration
 	dbName                   string
 	hasCustomerSourceMapping bool
@@ -94,6 +97,8 @@ func NewStore(cfg config.Config) (*Store, error) {
 	return &Store{
 		db:                       db,
 		queryTimeout:             cfg.DBQueryTimeout,
+		exportTimeout:            cfg.DBExportTimeout,
+		exportSlots:              make(chan struct{}, transferReportMaxStreams),
 		stuckAfter:               cfg.RunningStuckAfter,
 		dbName:                   cfg.DBName,
 		hasCustomerSourceMapping: hasMapping,
@@ -103,6 +108,24 @@ func NewStore(cfg config.Config) (*Store, error) {
 	}, nil
 }
 
+// NewStoreWithDB wraps an open MCP database and an optional app SQLite store
+// without probing either; NewStore is the configured constructor.
+func NewStoreWithDB(db *sql.DB, customerMap *customermap.Store, queryTimeout time.Duration) *Store {
+	mappingMode := "source_of_acquisition_fallback"
+	if customerMap != nil {
+		mappingMode = "sqlite_customer_mappings"
+	}
+	return &Store{
+		db:                       db,
+		queryTimeout:             queryTimeout,
+		exportTimeout:            queryTimeout,
+		exportSlots:              make(chan struct{}, transferReportMaxStreams),
+		hasCustomerSourceMapping: customerMap != nil,
+		customerMap:              customerMap,
+		customerMappingMode:      mappingMode,
+	}
+}
+
 func (s *Store) Close() error {
 	if s == nil {
 		return nil
@@ -142,7 +165,7 @@ func (s *Store) CustomerMappingPath() string {
 
 // ListRunningTransfers returns active transfers with their latest known progress markers.
 func (s *Store) ListRunningTransfers(ctx context.Context, limit int) ([]RunningTransfer, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
 	const q = `
@@ -233,7 +256,7 @@ LIMIT ?;
 
 // ListRunningSIPs returns active SIP ingest rows based on SIP jobs state.
 func (s *Store) ListRunningSIPs(ctx context.Context, limit int) ([]RunningSIP, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
 	const q = `
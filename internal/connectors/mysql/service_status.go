@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"time"
+
+	"go-am-realtime-report-ui/internal/dbctx"
 )
 
 // ServiceStats contains lightweight DB health and volume counters.
@@ -19,7 +21,7 @@ type ServiceStats struct {
 
 // ServiceStats returns MySQL health and high-level transfer/job counters.
 func (s *Store) ServiceStats(ctx context.Context) (*ServiceStats, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
 	start := time.Now()
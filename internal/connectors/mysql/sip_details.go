@@ -0,0 +1,219 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go-am-realtime-report-ui/internal/dbctx"
+)
+
+// SIPSummary is a detailed SIP/ingest overview used by troubleshooting views.
+type SIPSummary struct {
+	SIPUUID         string              `json:"sip_uuid"`
+	Name            string              `json:"name"`
+	SIPType         string              `json:"sip_type"`
+	StatusCode      int                 `json:"status_code"`
+	Status          string              `json:"status"`
+	FailureEvidence bool                `json:"failure_evidence"`
+	AIPFilename     string              `json:"aip_filename,omitempty"`
+	CreatedAt       *time.Time          `json:"created_at"`
+	StartedAt       *time.Time          `json:"started_at"`
+	CompletedAt     *time.Time          `json:"completed_at"`
+	LastProgressAt  *time.Time          `json:"last_progress_at"`
+	DurationSeconds int64               `json:"duration_seconds"`
+	FilesTotal      int64               `json:"files_total"`
+	FilesOriginal   int64               `json:"files_original"`
+	FilesNormalized int64               `json:"files_normalized"`
+	FailedJobs      int64               `json:"failed_jobs"`
+	ExecutingJobs   int64               `json:"executing_jobs"`
+	AwaitingJobs    int64               `json:"awaiting_jobs"`
+	Transfers       []SIPSourceTransfer `json:"transfers"`
+}
+
+// SIPSourceTransfer links a SIP back to a transfer that contributed files to it.
+type SIPSourceTransfer struct {
+	TransferUUID string `json:"transfer_uuid"`
+	Name         string `json:"name"`
+	StatusCode   int    `json:"status_code"`
+	Status       string `json:"status"`
+	Files        int64  `json:"files"`
+}
+
+// GetSIPSummary returns one SIP with ingest job state, file counts and source transfers.
+func (s *Store) GetSIPSummary(ctx context.Context, sipUUID string) (*SIPSummary, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	const q = `
+SELECT
+  s.sipUUID,
+  COALESCE(s.currentPath, ''),
+  COALESCE(s.sipType, ''),
+  COALESCE(s.aipFilename, ''),
+  COALESCE(s.status, 0),
+  s.createdTime,
+  s.completed_at,
+  js.started_at,
+  js.last_progress_at,
+  COALESCE(js.failed_jobs, 0),
+  COALESCE(js.executing_jobs, 0),
+  COALESCE(js.awaiting_jobs, 0),
+  COALESCE(fc.total_files, 0),
+  COALESCE(fc.original_files, 0),
+  COALESCE(fc.normalized_files, 0)
+FROM SIPs s
+LEFT JOIN (
+  SELECT
+    j.SIPUUID,
+    MIN(COALESCE(tsk.startTime, j.createdTime)) AS started_at,
+    MAX(COALESCE(tsk.endTime, tsk.startTime, j.createdTime)) AS last_progress_at,
+    COUNT(DISTINCT CASE WHEN COALESCE(tsk.exitCode, 0) <> 0 AND j.currentStep = 4 THEN j.jobUUID END) AS failed_jobs,
+    COUNT(DISTINCT CASE WHEN j.currentStep = 3 THEN j.jobUUID END) AS executing_jobs,
+    COUNT(DISTINCT CASE WHEN j.currentStep = 1 THEN j.jobUUID END) AS awaiting_jobs
+  FROM Jobs j
+  LEFT JOIN Tasks tsk
+    ON tsk.jobuuid = j.jobUUID
+  WHERE j.SIPUUID = ?
+    AND j.unitType IN ('unitSIP', 'unitDIP')
+  GROUP BY j.SIPUUID
+) js
+  ON js.SIPUUID = s.sipUUID
+LEFT JOIN (
+  SELECT
+    f.sipUUID,
+    COUNT(*) AS total_files,
+    SUM(CASE WHEN LOWER(f.fileGrpUse) = 'original' THEN 1 ELSE 0 END) AS original_files,
+    SUM(CASE WHEN d.derivedFileUUID IS NOT NULL THEN 1 ELSE 0 END) AS normalized_files
+  FROM Files f
+  LEFT JOIN Derivations d
+    ON d.sourceFileUUID = f.fileUUID
+  WHERE f.sipUUID = ?
+  GROUP BY f.sipUUID
+) fc
+  ON fc.sipUUID = s.sipUUID
+WHERE s.sipUUID = ?
+LIMIT 1;
+`
+
+	var (
+		item           SIPSummary
+		currentPath    string
+		createdAt      sql.NullTime
+		completedAt    sql.NullTime
+		startedAt      sql.NullTime
+		lastProgressAt sql.NullTime
+	)
+	err := s.db.QueryRowContext(ctx, q, sipUUID, sipUUID, sipUUID).Scan(
+		&item.SIPUUID,
+		&currentPath,
+		&item.SIPType,
+		&item.AIPFilename,
+		&item.StatusCode,
+		&createdAt,
+		&completedAt,
+		&startedAt,
+		&lastProgressAt,
+		&item.FailedJobs,
+		&item.ExecutingJobs,
+		&item.AwaitingJobs,
+		&item.FilesTotal,
+		&item.FilesOriginal,
+		&item.FilesNormalized,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	item.Name = transferNameFromLocation(currentPath, item.SIPUUID)
+	item.FailureEvidence = item.FailedJobs > 0
+	item.Status = sipStatusName(item.StatusCode, item.FailedJobs, item.ExecutingJobs, item.AwaitingJobs)
+	item.CreatedAt = nullTimePtr(createdAt)
+	item.CompletedAt = nullTimePtr(completedAt)
+	item.StartedAt = nullTimePtr(startedAt)
+	item.LastProgressAt = nullTimePtr(lastProgressAt)
+	end := item.CompletedAt
+	if end == nil {
+		end = item.LastProgressAt
+	}
+	if d := secondsBetween(item.StartedAt, end); d != nil {
+		item.DurationSeconds = *d
+	}
+
+	transfers, err := s.sipSourceTransfers(ctx, sipUUID)
+	if err != nil {
+		return nil, err
+	}
+	item.Transfers = transfers
+	return &item, nil
+}
+
+// GetSIPTimeline returns ordered SIP and DIP job/task events.
+func (s *Store) GetSIPTimeline(ctx context.Context, sipUUID string, limit int) ([]TimelineEvent, error) {
+	return s.unitTimeline(ctx, ingestUnitClause, sipUUID, limit)
+}
+
+// GetSIPErrors returns failed SIP and DIP tasks and their stderr snippets.
+func (s *Store) GetSIPErrors(ctx context.Context, sipUUID string, limit int) ([]TransferError, error) {
+	return s.unitErrors(ctx, ingestUnitClause, sipUUID, limit)
+}
+
+func (s *Store) sipSourceTransfers(ctx context.Context, sipUUID string) ([]SIPSourceTransfer, error) {
+	const q = `
+SELECT
+  f.transferUUID,
+  COALESCE(t.currentLocation, ''),
+  COALESCE(t.status, 0),
+  COUNT(*) AS files
+FROM Files f
+LEFT JOIN Transfers t
+  ON t.transferUUID = f.transferUUID
+WHERE f.sipUUID = ?
+  AND f.transferUUID IS NOT NULL
+  AND f.transferUUID <> ''
+GROUP BY f.transferUUID, t.currentLocation, t.status
+ORDER BY files DESC, f.transferUUID ASC;
+`
+
+	rows, err := s.db.QueryContext(ctx, q, sipUUID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]SIPSourceTransfer, 0)
+	for rows.Next() {
+		var (
+			item            SIPSourceTransfer
+			currentLocation string
+		)
+		if err := rows.Scan(&item.TransferUUID, &currentLocation, &item.StatusCode, &item.Files); err != nil {
+			return nil, err
+		}
+		item.Name = transferNameFromLocation(currentLocation, item.TransferUUID)
+		item.Status = transferStatusName(item.StatusCode)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// sipStatusName prefers the SIPs.status column and falls back to job state
+// for older MCP schemas where the column stays at 0.
+func sipStatusName(code int, failedJobs, executingJobs, awaitingJobs int64) string {
+	if code != 0 {
+		return transferStatusNameWithEvidence(code, failedJobs > 0, false)
+	}
+	switch {
+	case failedJobs > 0:
+		return "FAILED"
+	case executingJobs > 0:
+		return "RUNNING"
+	case awaitingJobs > 0:
+		return "AWAITING_DECISION"
+	default:
+		return "UNKNOWN"
+	}
+}
@@ -0,0 +1,583 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+	"go-am-realtime-report-ui/internal/dbctx"
+)
+
+const (
+	slaDefaultTargetSeconds = int64(24 * 60 * 60)
+	slaDefaultTargetPercent = 95.0
+	// slaStoredLookback widens the candidate window when the end milestone is
+	// AIP storage, which can happen well after the transfer completed.
+	slaStoredLookback  = 31 * 24 * time.Hour
+	slaWaitBatchSize   = 500
+	monthlyMaxBreaches = 20
+)
+
+// SLAPolicy is a per-customer SLA target from the app SQLite store.
+// Builtin is set when no stored policy applies and the 24h default is used.
+type SLAPolicy struct {
+	ID             int64    `json:"id"`
+	CustomerID     string   `json:"customer_id"`
+	Name           string   `json:"name"`
+	StartMilestone string   `json:"start_milestone"`
+	EndMilestone   string   `json:"end_milestone"`
+	TargetSeconds  int64    `json:"target_seconds"`
+	TargetPercent  float64  `json:"target_percent"`
+	Exclusions     []string `json:"exclusions"`
+	Builtin        bool     `json:"builtin,omitempty"`
+	CreatedAt      string   `json:"created_at,omitempty"`
+	UpdatedAt      string   `json:"updated_at,omitempty"`
+}
+
+// SLABreach is one transfer whose measured time exceeded the policy target.
+type SLABreach struct {
+	TransferUUID        string     `json:"transfer_uuid"`
+	TransferName        string     `json:"transfer_name"`
+	SourceOfAcquisition string     `json:"source_of_acquisition"`
+	Status              string     `json:"status"`
+	StartedAt           *time.Time `json:"started_at"`
+	EndedAt             *time.Time `json:"ended_at"`
+	ElapsedSeconds      int64      `json:"elapsed_seconds"`
+	ExcludedSeconds     int64      `json:"excluded_seconds"`
+	MeasuredSeconds     int64      `json:"measured_seconds"`
+	OverBySeconds       int64      `json:"over_by_seconds"`
+}
+
+// SLACompliance is the outcome of one SLA policy over one report period; Month
+// holds the period label.
+type SLACompliance struct {
+	Month                   string      `json:"month"`
+	CustomerID              string      `json:"customer_id"`
+	Policy                  SLAPolicy   `json:"policy"`
+	Measured                int64       `json:"measured"`
+	WithinTarget            int64       `json:"within_target"`
+	Breached                int64       `json:"breached"`
+	Excluded                int64       `json:"excluded"`
+	MissingMilestones       int64       `json:"missing_milestones"`
+	OnTimePercent           float64     `json:"on_time_percent"`
+	TargetPercentileSeconds int64       `json:"target_percentile_seconds"`
+	Compliant               bool        `json:"compliant"`
+	Breaches                []SLABreach `json:"breaches"`
+	BreachesTruncated       bool        `json:"breaches_truncated"`
+}
+
+func (s *Store) ListSLAPolicies(ctx context.Context, limit int) ([]SLAPolicy, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	items, err := store.ListSLAPolicies(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]SLAPolicy, 0, len(items))
+	for _, it := range items {
+		out = append(out, slaPolicyFromStore(it))
+	}
+	return out, nil
+}
+
+func (s *Store) GetSLAPolicy(ctx context.Context, id int64) (*SLAPolicy, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return nil, err
+	}
+	it, err := store.GetSLAPolicy(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	out := slaPolicyFromStore(*it)
+	return &out, nil
+}
+
+func (s *Store) SaveSLAPolicy(ctx context.Context, item SLAPolicy) (int64, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return 0, err
+	}
+	return store.SaveSLAPolicy(ctx, customermap.SLAPolicy{
+		ID:             item.ID,
+		CustomerID:     item.CustomerID,
+		Name:           item.Name,
+		StartMilestone: item.StartMilestone,
+		EndMilestone:   item.EndMilestone,
+		TargetSeconds:  item.TargetSeconds,
+		TargetPercent:  item.TargetPercent,
+		Exclusions:     item.Exclusions,
+	})
+}
+
+func (s *Store) DeleteSLAPolicy(ctx context.Context, id int64) (int64, error) {
+	store, err := s.templateStore()
+	if err != nil {
+		return 0, err
+	}
+	return store.DeleteSLAPolicy(ctx, id)
+}
+
+// ResolveSLAPolicy returns the customer's stored policy, the stored "default"
+// policy, or the built-in 24h policy, in that order.
+func (s *Store) ResolveSLAPolicy(ctx context.Context, customerID string) (SLAPolicy, error) {
+	if s.HasTemplateStore() {
+		it, err := s.customerMap.SLAPolicyForCustomer(ctx, customerID)
+		switch {
+		case err == nil:
+			return slaPolicyFromStore(*it), nil
+		case !errors.Is(err, sql.ErrNoRows):
+			return SLAPolicy{}, err
+		}
+	}
+	return SLAPolicy{
+		CustomerID:     "default",
+		Name:           "built-in default",
+		StartMilestone: customermap.SLAStartMilestones[0],
+		EndMilestone:   customermap.SLAEndMilestones[0],
+		TargetSeconds:  slaDefaultTargetSeconds,
+		TargetPercent:  slaDefaultTargetPercent,
+		Exclusions:     []string{},
+		Builtin:        true,
+	}, nil
+}
+
+func slaPolicyFromStore(it customermap.SLAPolicy) SLAPolicy {
+	row := SLAPolicy{
+		ID:             it.ID,
+		CustomerID:     it.CustomerID,
+		Name:           it.Name,
+		StartMilestone: it.StartMilestone,
+		EndMilestone:   it.EndMilestone,
+		TargetSeconds:  it.TargetSeconds,
+		TargetPercent:  it.TargetPercent,
+		Exclusions:     it.Exclusions,
+	}
+	if it.CreatedAt != nil {
+		row.CreatedAt = it.CreatedAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	if it.UpdatedAt != nil {
+		row.UpdatedAt = it.UpdatedAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	return row
+}
+
+func (p SLAPolicy) excludes(rule string) bool {
+	for _, e := range p.Exclusions {
+		if e == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSLACompliance evaluates the customer's SLA policy for one report period.
+func (s *Store) GetSLACompliance(ctx context.Context, customerID string, period ReportPeriod, breachLimit int) (*SLACompliance, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	filterClause, args, err := s.sourceFilterClause(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := s.ResolveSLAPolicy(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.slaCompliance(ctx, policy, period, filterClause, args, breachLimit)
+	if err != nil {
+		return nil, err
+	}
+	out.CustomerID = customerID
+	return out, nil
+}
+
+func (s *Store) slaCompliance(ctx context.Context, policy SLAPolicy, period ReportPeriod, filterClause string, filterArgs []any, breachLimit int) (*SLACompliance, error) {
+	samples, err := s.slaSamples(ctx, policy, period.Start, period.End, filterClause, filterArgs)
+	if err != nil {
+		return nil, err
+	}
+	if policy.excludes("awaiting_decision") {
+		if err := s.attachDecisionWaits(ctx, samples, time.Now().UTC()); err != nil {
+			return nil, err
+		}
+	}
+	out := evaluateSLA(samples, policy, period.Start, period.End, breachLimit)
+	out.Month = period.Label
+	return out, nil
+}
+
+// slaSample carries every milestone of one transfer; the policy picks which pair to measure.
+type slaSample struct {
+	TransferUUID      string
+	Location          string
+	Source            string
+	Status            int
+	TransferStartedAt *time.Time
+	TransferEndedAt   *time.Time
+	SIPStartedAt      *time.Time
+	AIPStoredAt       *time.Time
+	Waits             []slaInterval
+}
+
+type slaInterval struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (s *Store) slaSamples(ctx context.Context, policy SLAPolicy, start, end time.Time, filterClause string, filterArgs []any) ([]slaSample, error) {
+	windowStart := start
+	if policy.EndMilestone == "aip_stored" {
+		windowStart = start.Add(-slaStoredLookback)
+	}
+	q := slaSamplesQuery(policy.StartMilestone == "sip_start" || policy.EndMilestone == "aip_stored", filterClause)
+	args := append([]any{windowStart, end}, filterArgs...)
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]slaSample, 0)
+	for rows.Next() {
+		var (
+			item                       slaSample
+			status                     sql.NullInt64
+			transferStart, transferEnd sql.NullTime
+			sipStart, aipStored        sql.NullTime
+		)
+		if err := rows.Scan(&item.TransferUUID, &item.Location, &item.Source, &status, &transferStart, &transferEnd, &sipStart, &aipStored); err != nil {
+			return nil, err
+		}
+		item.Status = int(nullInt64Value(status))
+		item.TransferStartedAt = nullTimePtr(transferStart)
+		item.TransferEndedAt = nullTimePtr(transferEnd)
+		item.SIPStartedAt = nullTimePtr(sipStart)
+		item.AIPStoredAt = nullTimePtr(aipStored)
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// slaSamplesQuery selects the milestones of transfers completed in [?, ?). MCP
+// stores Jobs.unitType as unitTransfer/unitSIP/unitDIP, so units are matched by suffix.
+func slaSamplesQuery(needSIP bool, filterClause string) string {
+	sipColumns := "NULL AS sip_started_at, NULL AS aip_stored_at"
+	sipJoin := ""
+	if needSIP {
+		sipColumns = "ss.sip_started_at, ss.aip_stored_at"
+		sipJoin = `
+LEFT JOIN (
+  SELECT
+    tsu.transferUUID,
+    MIN(sj.sip_started_at) AS sip_started_at,
+    MAX(sj.aip_stored_at) AS aip_stored_at
+  FROM (
+    SELECT DISTINCT f.transferUUID, f.sipUUID
+    FROM Files f
+    WHERE f.transferUUID IS NOT NULL
+      AND f.sipUUID IS NOT NULL
+  ) tsu
+  JOIN (
+    SELECT
+      j.SIPUUID,
+      MIN(j.createdTime) AS sip_started_at,
+      MAX(CASE WHEN j.microserviceGroup = 'Store AIP' AND j.currentStep = 2 THEN COALESCE(tsk.endTime, j.createdTime) END) AS aip_stored_at
+    FROM Jobs j
+    LEFT JOIN Tasks tsk
+      ON tsk.jobuuid = j.jobUUID
+    WHERE j.unitType LIKE '%SIP'
+    GROUP BY j.SIPUUID
+  ) sj
+    ON sj.SIPUUID = tsu.sipUUID
+  GROUP BY tsu.transferUUID
+) ss
+  ON ss.transferUUID = t.transferUUID`
+	}
+	return `
+SELECT
+  t.transferUUID,
+  COALESCE(t.currentLocation, '') AS current_location,
+  COALESCE(t.sourceOfAcquisition, '') AS source,
+  t.status,
+  COALESCE(tt.transfer_started_at, tfs.transfer_first_seen_at) AS transfer_started_at,
+  COALESCE(tt.transfer_finished_at, t.completed_at) AS transfer_finished_at,
+  ` + sipColumns + `
+FROM Transfers t
+LEFT JOIN (
+  SELECT
+    t2.transferUUID,
+    MIN(COALESCE(tsk.startTime, j.createdTime)) AS transfer_started_at,
+    COALESCE(
+      t2.completed_at,
+      MAX(COALESCE(tsk.endTime, tsk.startTime, j.createdTime))
+    ) AS transfer_finished_at
+  FROM Transfers t2
+  LEFT JOIN Jobs j
+    ON j.SIPUUID = t2.transferUUID
+    AND j.unitType LIKE '%Transfer'
+  LEFT JOIN Tasks tsk
+    ON tsk.jobuuid = j.jobUUID
+  GROUP BY t2.transferUUID, t2.completed_at
+) tt
+  ON tt.transferUUID = t.transferUUID
+LEFT JOIN (
+  SELECT
+    f.transferUUID,
+    MIN(f.enteredSystem) AS transfer_first_seen_at
+  FROM Files f
+  WHERE f.transferUUID IS NOT NULL
+  GROUP BY f.transferUUID
+) tfs
+  ON tfs.transferUUID = t.transferUUID` + sipJoin + `
+WHERE t.completed_at >= ?
+  AND t.completed_at < ?
+  AND t.status IN (2, 3, 4)
+  ` + filterClause + `;
+`
+}
+
+// slaUnitJob is a job of a transfer or of one of the SIPs created from it.
+type slaUnitJob struct {
+	TransferUUID string
+	UnitUUID     string
+	CreatedAt    time.Time
+	CurrentStep  int
+	HasTasks     bool
+}
+
+// attachDecisionWaits loads the job history of each sample's transfer and SIPs and
+// records the time spent at decision points.
+func (s *Store) attachDecisionWaits(ctx context.Context, samples []slaSample, now time.Time) error {
+	index := make(map[string]int, len(samples))
+	for i, sm := range samples {
+		index[sm.TransferUUID] = i
+	}
+	for offset := 0; offset < len(samples); offset += slaWaitBatchSize {
+		batch := samples[offset:min(offset+slaWaitBatchSize, len(samples))]
+		ids := make([]any, 0, len(batch)*2)
+		for _, sm := range batch {
+			ids = append(ids, sm.TransferUUID)
+		}
+		ids = append(ids, ids...)
+
+		q := `
+SELECT
+  u.transferUUID,
+  j.SIPUUID,
+  j.createdTime,
+  j.currentStep,
+  EXISTS (SELECT 1 FROM Tasks tsk WHERE tsk.jobuuid = j.jobUUID) AS has_tasks
+FROM Jobs j
+JOIN (
+  SELECT t.transferUUID, t.transferUUID AS unitUUID
+  FROM Transfers t
+  WHERE t.transferUUID IN (` + placeholders(len(batch)) + `)
+  UNION
+  SELECT DISTINCT f.transferUUID, f.sipUUID AS unitUUID
+  FROM Files f
+  WHERE f.transferUUID IN (` + placeholders(len(batch)) + `)
+    AND f.sipUUID IS NOT NULL
+) u
+  ON u.unitUUID = j.SIPUUID
+ORDER BY u.transferUUID, j.SIPUUID, j.createdTime;
+`
+		rows, err := s.db.QueryContext(ctx, q, ids...)
+		if err != nil {
+			return err
+		}
+		jobs := make([]slaUnitJob, 0)
+		for rows.Next() {
+			var (
+				job     slaUnitJob
+				step    sql.NullInt64
+				created sql.NullTime
+			)
+			if err := rows.Scan(&job.TransferUUID, &job.UnitUUID, &created, &step, &job.HasTasks); err != nil {
+				rows.Close()
+				return err
+			}
+			if !created.Valid {
+				continue
+			}
+			job.CreatedAt = created.Time
+			job.CurrentStep = int(nullInt64Value(step))
+			jobs = append(jobs, job)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		for transferUUID, waits := range decisionWaits(jobs, now) {
+			if i, ok := index[transferUUID]; ok {
+				samples[i].Waits = waits
+			}
+		}
+	}
+	return nil
+}
+
+// decisionWaits treats jobs without tasks as decision points: the wait runs
+// from the job's creation until the next job of the same unit is created, or
+// until now when the job is still awaiting a decision (currentStep 1).
+// Non-interactive task-less links produce near-zero waits.
+func decisionWaits(jobs []slaUnitJob, now time.Time) map[string][]slaInterval {
+	out := map[string][]slaInterval{}
+	for i, job := range jobs {
+		if job.HasTasks {
+			continue
+		}
+		var until time.Time
+		switch {
+		case i+1 < len(jobs) && jobs[i+1].TransferUUID == job.TransferUUID && jobs[i+1].UnitUUID == job.UnitUUID:
+			until = jobs[i+1].CreatedAt
+		case job.CurrentStep == 1:
+			until = now
+		default:
+			continue
+		}
+		if until.After(job.CreatedAt) {
+			out[job.TransferUUID] = append(out[job.TransferUUID], slaInterval{Start: job.CreatedAt, End: until})
+		}
+	}
+	return out
+}
+
+// overlapSeconds returns how much of [start, end] is covered by intervals,
+// counting overlapping intervals once.
+func overlapSeconds(intervals []slaInterval, start, end time.Time) int64 {
+	clipped := make([]slaInterval, 0, len(intervals))
+	for _, iv := range intervals {
+		if iv.Start.Before(start) {
+			iv.Start = start
+		}
+		if iv.End.After(end) {
+			iv.End = end
+		}
+		if iv.End.After(iv.Start) {
+			clipped = append(clipped, iv)
+		}
+	}
+	sort.Slice(clipped, func(i, j int) bool { return clipped[i].Start.Before(clipped[j].Start) })
+
+	var (
+		total  time.Duration
+		cursor time.Time
+	)
+	for _, iv := range clipped {
+		if iv.Start.Before(cursor) {
+			iv.Start = cursor
+		}
+		if iv.End.After(iv.Start) {
+			total += iv.End.Sub(iv.Start)
+			cursor = iv.End
+		}
+	}
+	return int64(total.Seconds())
+}
+
+func (sm slaSample) milestones(policy SLAPolicy) (*time.Time, *time.Time) {
+	startAt := sm.TransferStartedAt
+	if policy.StartMilestone == "sip_start" {
+		startAt = sm.SIPStartedAt
+	}
+	endAt := sm.TransferEndedAt
+	if policy.EndMilestone == "aip_stored" {
+		endAt = sm.AIPStoredAt
+	}
+	return startAt, endAt
+}
+
+// evaluateSLA measures every sample whose end milestone falls in [from, to).
+func evaluateSLA(samples []slaSample, policy SLAPolicy, from, to time.Time, breachLimit int) *SLACompliance {
+	out := &SLACompliance{
+		Policy:   policy,
+		Breaches: make([]SLABreach, 0),
+	}
+	measured := make([]int64, 0, len(samples))
+	for _, sm := range samples {
+		startAt, endAt := sm.milestones(policy)
+		// Transfers that never reached the end milestone are attributed to
+		// the month their transfer ended in.
+		periodAt := endAt
+		if periodAt == nil {
+			periodAt = sm.TransferEndedAt
+		}
+		if periodAt == nil || periodAt.Before(from) || !periodAt.Before(to) {
+			continue
+		}
+		if policy.excludes("failed") && sm.Status == 4 {
+			out.Excluded++
+			continue
+		}
+		if startAt == nil || endAt == nil || endAt.Before(*startAt) {
+			out.MissingMilestones++
+			continue
+		}
+
+		elapsed := int64(endAt.Sub(*startAt).Seconds())
+		excluded := int64(0)
+		if policy.excludes("awaiting_decision") {
+			excluded = overlapSeconds(sm.Waits, *startAt, *endAt)
+		}
+		value := elapsed - excluded
+		measured = append(measured, value)
+		if value <= policy.TargetSeconds {
+			out.WithinTarget++
+			continue
+		}
+		out.Breached++
+		out.Breaches = append(out.Breaches, SLABreach{
+			TransferUUID:        sm.TransferUUID,
+			TransferName:        transferNameFromLocation(sm.Location, sm.TransferUUID),
+			SourceOfAcquisition: sm.Source,
+			Status:              transferStatusName(sm.Status),
+			StartedAt:           startAt,
+			EndedAt:             endAt,
+			ElapsedSeconds:      elapsed,
+			ExcludedSeconds:     excluded,
+			MeasuredSeconds:     value,
+			OverBySeconds:       value - policy.TargetSeconds,
+		})
+	}
+
+	out.Measured = int64(len(measured))
+	if out.Measured > 0 {
+		out.OnTimePercent = round2(float64(out.WithinTarget) * 100 / float64(out.Measured))
+		sort.Slice(measured, func(i, j int) bool { return measured[i] < measured[j] })
+		out.TargetPercentileSeconds = percentile(measured, int(math.Ceil(policy.TargetPercent)))
+	}
+	out.Compliant = out.Measured == 0 || out.OnTimePercent >= policy.TargetPercent
+
+	sort.Slice(out.Breaches, func(i, j int) bool {
+		if out.Breaches[i].OverBySeconds != out.Breaches[j].OverBySeconds {
+			return out.Breaches[i].OverBySeconds > out.Breaches[j].OverBySeconds
+		}
+		return out.Breaches[i].TransferUUID < out.Breaches[j].TransferUUID
+	})
+	if breachLimit > 0 && len(out.Breaches) > breachLimit {
+		out.Breaches = out.Breaches[:breachLimit]
+		out.BreachesTruncated = true
+	}
+	return out
+}
+
+// slaPolicySummary is the compact policy description embedded in monthly KPIs.
+func slaPolicySummary(p SLAPolicy) string {
+	parts := []string{p.StartMilestone + "->" + p.EndMilestone}
+	if len(p.Exclusions) > 0 {
+		parts = append(parts, "excluding "+strings.Join(p.Exclusions, ","))
+	}
+	return strings.Join(parts, " ")
+}
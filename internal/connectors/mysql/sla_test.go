@@ -0,0 +1,138 @@
+package mysql
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func slaTime(base time.Time, hours float64) *time.Time {
+	t := base.Add(time.Duration(hours * float64(time.Hour)))
+	return &t
+}
+
+func TestEvaluateSLA_BreachesAndExclusions(t *testing.T) {
+	from := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	base := from.Add(24 * time.Hour)
+
+	policy := SLAPolicy{
+		StartMilestone: "transfer_start",
+		EndMilestone:   "transfer_completed",
+		TargetSeconds:  48 * 3600,
+		TargetPercent:  95,
+		Exclusions:     []string{"awaiting_decision", "failed"},
+	}
+	samples := []slaSample{
+		{TransferUUID: "ok", Status: 2, TransferStartedAt: slaTime(base, 0), TransferEndedAt: slaTime(base, 10)},
+		{TransferUUID: "late", Status: 2, TransferStartedAt: slaTime(base, 0), TransferEndedAt: slaTime(base, 60)},
+		{
+			// 60h elapsed but 20h spent awaiting a decision.
+			TransferUUID:      "waited",
+			Status:            2,
+			TransferStartedAt: slaTime(base, 0),
+			TransferEndedAt:   slaTime(base, 60),
+			Waits:             []slaInterval{{Start: *slaTime(base, 5), End: *slaTime(base, 25)}},
+		},
+		{TransferUUID: "failed", Status: 4, TransferStartedAt: slaTime(base, 0), TransferEndedAt: slaTime(base, 100)},
+		{TransferUUID: "nostart", Status: 2, TransferEndedAt: slaTime(base, 1)},
+		{TransferUUID: "prev-month", Status: 2, TransferStartedAt: slaTime(from, -100), TransferEndedAt: slaTime(from, -1)},
+	}
+
+	got := evaluateSLA(samples, policy, from, to, 10)
+
+	if got.Measured != 3 || got.WithinTarget != 2 || got.Breached != 1 {
+		t.Fatalf("unexpected counts: %+v", got)
+	}
+	if got.Excluded != 1 || got.MissingMilestones != 1 {
+		t.Fatalf("expected 1 excluded and 1 missing, got %d/%d", got.Excluded, got.MissingMilestones)
+	}
+	if got.Compliant || got.OnTimePercent != 66.67 {
+		t.Fatalf("expected non-compliant 66.67%%, got %v %v", got.Compliant, got.OnTimePercent)
+	}
+	if len(got.Breaches) != 1 || got.Breaches[0].TransferUUID != "late" || got.Breaches[0].OverBySeconds != 12*3600 {
+		t.Fatalf("unexpected breaches: %+v", got.Breaches)
+	}
+}
+
+func TestEvaluateSLA_AIPStoredUsesStorageMonth(t *testing.T) {
+	from := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	policy := SLAPolicy{StartMilestone: "transfer_start", EndMilestone: "aip_stored", TargetSeconds: 3600, TargetPercent: 100}
+	samples := []slaSample{
+		// Transfer finished in January, AIP stored in February.
+		{TransferUUID: "a", Status: 2, TransferStartedAt: slaTime(from, -2), TransferEndedAt: slaTime(from, -1), AIPStoredAt: slaTime(from, 0.5)},
+		// Transfer finished in February but never stored.
+		{TransferUUID: "b", Status: 2, TransferStartedAt: slaTime(from, 1), TransferEndedAt: slaTime(from, 2)},
+	}
+
+	got := evaluateSLA(samples, policy, from, to, 0)
+
+	if got.Measured != 1 || got.Breached != 1 || got.MissingMilestones != 1 {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+}
+
+func TestDecisionWaitsAndOverlap(t *testing.T) {
+	base := time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)
+	now := base.Add(48 * time.Hour)
+	jobs := []slaUnitJob{
+		{TransferUUID: "t1", UnitUUID: "t1", CreatedAt: base, HasTasks: true},
+		{TransferUUID: "t1", UnitUUID: "t1", CreatedAt: base.Add(time.Hour), CurrentStep: 2},
+		{TransferUUID: "t1", UnitUUID: "t1", CreatedAt: base.Add(4 * time.Hour), HasTasks: true},
+		{TransferUUID: "t1", UnitUUID: "s1", CreatedAt: base.Add(10 * time.Hour), CurrentStep: 1},
+	}
+
+	waits := decisionWaits(jobs, now)["t1"]
+	if len(waits) != 2 {
+		t.Fatalf("expected 2 waits, got %+v", waits)
+	}
+	if got := waits[0].End.Sub(waits[0].Start); got != 3*time.Hour {
+		t.Fatalf("unexpected first wait %s", got)
+	}
+	if !waits[1].End.Equal(now) {
+		t.Fatalf("pending decision should run until now, got %s", waits[1].End)
+	}
+
+	overlapping := []slaInterval{
+		{Start: base, End: base.Add(2 * time.Hour)},
+		{Start: base.Add(time.Hour), End: base.Add(3 * time.Hour)},
+	}
+	if got := overlapSeconds(overlapping, base.Add(30*time.Minute), base.Add(10*time.Hour)); got != int64(150*60) {
+		t.Fatalf("expected 9000s overlap, got %d", got)
+	}
+}
+
+func TestSLASamplesQuery_UnitTypes(t *testing.T) {
+	// MCP stores unitTransfer/unitSIP/unitDIP; bare 'Transfer' or 'SIP' never match.
+	q := slaSamplesQuery(true, "")
+	for _, want := range []string{"j.unitType LIKE '%Transfer'", "j.unitType LIKE '%SIP'"} {
+		if !strings.Contains(q, want) {
+			t.Fatalf("expected %q in query", want)
+		}
+	}
+	if strings.Contains(q, "unitType = ") {
+		t.Fatalf("unexpected exact unitType match in query")
+	}
+	if q := slaSamplesQuery(false, ""); strings.Contains(q, "LIKE '%SIP'") || !strings.Contains(q, "NULL AS sip_started_at") {
+		t.Fatalf("SIP milestones should only be joined when needed")
+	}
+}
+
+func TestReportKPIs_BuiltinPolicyKeepsDurationShare(t *testing.T) {
+	durations := []int64{3600, 20 * 3600, 30 * 3600, 40 * 3600}
+	sla := &SLACompliance{OnTimePercent: 100, Breached: 0, Compliant: true}
+
+	builtin := SLAPolicy{TargetSeconds: slaDefaultTargetSeconds, TargetPercent: slaDefaultTargetPercent, Builtin: true}
+	kpis := reportKPIs(periodCounts{}, durations, sla, builtin, 0)
+	if kpis["sla_on_time_percent"] != 50.0 || kpis["sla_breaches"] != int64(2) || kpis["sla_compliant"] != false {
+		t.Fatalf("built-in policy should measure processing durations, got %v", kpis)
+	}
+
+	configured := builtin
+	configured.Builtin = false
+	kpis = reportKPIs(periodCounts{}, durations, sla, configured, 0)
+	if kpis["sla_on_time_percent"] != 100.0 || kpis["sla_breaches"] != int64(0) || kpis["sla_compliant"] != true {
+		t.Fatalf("configured policy should use the milestone evaluation, got %v", kpis)
+	}
+}
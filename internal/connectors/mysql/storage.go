@@ -0,0 +1,310 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"go-am-realtime-report-ui/internal/dbctx"
+)
+
+const storageSourceBatchSize = 500
+
+// StoragePackage is a stored package as reported by the Storage Service. SIPUUID is
+// the UUID of the SIP the package was built from: Archivematica reuses the SIP UUID
+// as the AIP UUID, and replicas point at the AIP they copy.
+type StoragePackage struct {
+	UUID         string
+	SIPUUID      string
+	PackageType  string
+	SizeBytes    int64
+	StoredAt     time.Time
+	LocationUUID string
+	Location     string
+}
+
+// StorageUsage counts packages and bytes stored during a period and in total at its
+// end. GrowthPercent compares the period's bytes with the bytes stored before it and
+// is nil when nothing was stored before.
+type StorageUsage struct {
+	PackagesPeriod int64    `json:"packages_period"`
+	BytesPeriod    int64    `json:"bytes_period"`
+	PackagesTotal  int64    `json:"packages_total"`
+	BytesTotal     int64    `json:"bytes_total"`
+	BytesAtStart   int64    `json:"bytes_at_start"`
+	BytesPerDay    float64  `json:"bytes_per_day"`
+	GrowthPercent  *float64 `json:"growth_percent"`
+}
+
+// LocationStorage is storage usage in one SS location.
+type LocationStorage struct {
+	LocationUUID string `json:"location_uuid"`
+	Location     string `json:"location"`
+	StorageUsage
+}
+
+// CustomerStorage is a customer's storage usage with its per-location split.
+type CustomerStorage struct {
+	CustomerID string `json:"customer_id"`
+	StorageUsage
+	Locations []LocationStorage `json:"locations"`
+}
+
+// StorageConsumption attributes stored packages to customers. A package whose source
+// maps to several customers counts toward each of them but only once in Totals.
+// UnresolvedPackages were not found in MCP (e.g. after MCP cleanup) and are reported
+// under the unmapped customer.
+type StorageConsumption struct {
+	Period             ReportPeriod      `json:"period"`
+	Totals             StorageUsage      `json:"totals"`
+	Customers          []CustomerStorage `json:"customers"`
+	Locations          []LocationStorage `json:"locations"`
+	UnresolvedPackages int64             `json:"unresolved_packages"`
+}
+
+// GetStorageConsumption resolves the packages' SIPs to the transfers they were
+// built from, attributes each package with the mappings valid at that transfer's
+// start and the enabled mapping rules, and aggregates usage for period. packages
+// should contain everything stored before period.End. A non-empty customerID
+// limits the result to that customer and its members.
+func (s *Store) GetStorageConsumption(ctx context.Context, packages []StoragePackage, period ReportPeriod, customerID string) (*StorageConsumption, error) {
+	transfers, err := s.packageTransfers(ctx, packages)
+	if err != nil {
+		return nil, err
+	}
+	attribution, err := s.customerAttribution(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	customerID = strings.TrimSpace(customerID)
+	if strings.EqualFold(customerID, "all") || strings.EqualFold(customerID, "default") {
+		customerID = ""
+	}
+	var members []string
+	if customerID != "" {
+		if members, err = s.customerMembers(ctx, customerID); err != nil {
+			return nil, err
+		}
+	}
+	return computeStorageConsumption(packages, transfers, attribution, period, customerID, members), nil
+}
+
+// packageTransfers maps the lower-case SIP UUIDs of packages to the transfer that
+// contributed most of their files.
+func (s *Store) packageTransfers(ctx context.Context, packages []StoragePackage) (map[string]mappingRuleTransfer, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	sipUUIDs := make(map[string]struct{}, len(packages))
+	for _, p := range packages {
+		if p.SIPUUID != "" {
+			sipUUIDs[strings.ToLower(p.SIPUUID)] = struct{}{}
+		}
+	}
+	return s.sipTransfers(ctx, keysOf(sipUUIDs))
+}
+
+// sipTransfers maps lower-case SIP UUIDs to the transfer that contributed most of
+// their files, with the fields customerAttribution needs.
+func (s *Store) sipTransfers(ctx context.Context, sipUUIDs []string) (map[string]mappingRuleTransfer, error) {
+	out := make(map[string]mappingRuleTransfer, len(sipUUIDs))
+	best := make(map[string]int64, len(sipUUIDs))
+	for offset := 0; offset < len(sipUUIDs); offset += storageSourceBatchSize {
+		batch := sipUUIDs[offset:min(offset+storageSourceBatchSize, len(sipUUIDs))]
+		args := make([]any, 0, len(batch))
+		for _, id := range batch {
+			args = append(args, id)
+		}
+		q := `
+SELECT
+  c.sipUUID,
+  t.transferUUID,
+  COALESCE(t.currentLocation, ''),
+  COALESCE(t.sourceOfAcquisition, ''),
+  COALESCE(t.accessionID, ''),
+  t.completed_at,
+  ` + transferFirstJobExpr + `,
+  c.files
+FROM (
+  SELECT f.sipUUID, f.transferUUID, COUNT(*) AS files
+  FROM Files f
+  WHERE f.sipUUID IN (` + placeholders(len(batch)) + `)
+  GROUP BY f.sipUUID, f.transferUUID
+) c
+JOIN Transfers t
+  ON t.transferUUID = c.transferUUID;
+`
+		rows, err := s.db.QueryContext(ctx, q, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var (
+				sipUUID               string
+				tr                    mappingRuleTransfer
+				completedAt, firstJob sql.NullTime
+				files                 int64
+			)
+			if err := rows.Scan(&sipUUID, &tr.UUID, &tr.Location, &tr.Source, &tr.Accession, &completedAt, &firstJob, &files); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			tr.CompletedAt = nullTimePtr(completedAt)
+			tr.StartedAt = transferStart(firstJob, completedAt)
+			sipUUID = strings.ToLower(sipUUID)
+			if _, ok := out[sipUUID]; !ok || files > best[sipUUID] {
+				out[sipUUID] = tr
+				best[sipUUID] = files
+			}
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		rows.Close()
+	}
+	return out, nil
+}
+
+type storageAccumulator struct {
+	usage     StorageUsage
+	locations map[string]*LocationStorage
+}
+
+func newStorageAccumulator() *storageAccumulator {
+	return &storageAccumulator{locations: map[string]*LocationStorage{}}
+}
+
+func (a *storageAccumulator) add(p StoragePackage, period ReportPeriod) {
+	loc, ok := a.locations[p.LocationUUID]
+	if !ok {
+		loc = &LocationStorage{LocationUUID: p.LocationUUID, Location: p.Location}
+		a.locations[p.LocationUUID] = loc
+	}
+	for _, u := range []*StorageUsage{&a.usage, &loc.StorageUsage} {
+		u.PackagesTotal++
+		u.BytesTotal += p.SizeBytes
+		if p.StoredAt.Before(period.Start) {
+			u.BytesAtStart += p.SizeBytes
+		} else {
+			u.PackagesPeriod++
+			u.BytesPeriod += p.SizeBytes
+		}
+	}
+}
+
+func (a *storageAccumulator) locationList(days float64) []LocationStorage {
+	out := make([]LocationStorage, 0, len(a.locations))
+	for _, loc := range a.locations {
+		loc.StorageUsage = finishStorageUsage(loc.StorageUsage, days)
+		out = append(out, *loc)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].BytesTotal != out[j].BytesTotal {
+			return out[i].BytesTotal > out[j].BytesTotal
+		}
+		return out[i].LocationUUID < out[j].LocationUUID
+	})
+	return out
+}
+
+func finishStorageUsage(u StorageUsage, days float64) StorageUsage {
+	if days > 0 {
+		u.BytesPerDay = round2(float64(u.BytesPeriod) / days)
+	}
+	if u.BytesAtStart > 0 {
+		growth := round2(float64(u.BytesPeriod) * 100 / float64(u.BytesAtStart))
+		u.GrowthPercent = &growth
+	}
+	return u
+}
+
+// computeStorageConsumption aggregates packages stored before period.End. transfers
+// maps lower-case SIP UUIDs to their transfer; attribution may be nil, see
+// customerAttribution. With a customerID, packages owned by any of members are
+// reported under customerID.
+func computeStorageConsumption(packages []StoragePackage, transfers map[string]mappingRuleTransfer, attribution *customerAttribution, period ReportPeriod, customerID string, members []string) *StorageConsumption {
+	total := newStorageAccumulator()
+	byCustomer := map[string]*storageAccumulator{}
+	var unresolved int64
+
+	for _, p := range packages {
+		if !p.StoredAt.Before(period.End) {
+			continue
+		}
+		owners, ok := packageCustomers(p, transfers, attribution)
+		if customerID != "" {
+			if !containsAnyCustomer(owners, members) {
+				continue
+			}
+			owners = []string{customerID}
+		}
+		if !ok {
+			unresolved++
+		}
+
+		total.add(p, period)
+		for _, owner := range owners {
+			acc, exists := byCustomer[owner]
+			if !exists {
+				acc = newStorageAccumulator()
+				byCustomer[owner] = acc
+			}
+			acc.add(p, period)
+		}
+	}
+
+	days := period.End.Sub(period.Start).Hours() / 24
+	out := &StorageConsumption{
+		Period:             period,
+		Totals:             finishStorageUsage(total.usage, days),
+		Customers:          make([]CustomerStorage, 0, len(byCustomer)),
+		Locations:          total.locationList(days),
+		UnresolvedPackages: unresolved,
+	}
+	for id, acc := range byCustomer {
+		out.Customers = append(out.Customers, CustomerStorage{
+			CustomerID:   id,
+			StorageUsage: finishStorageUsage(acc.usage, days),
+			Locations:    acc.locationList(days),
+		})
+	}
+	sort.Slice(out.Customers, func(i, j int) bool {
+		if out.Customers[i].BytesTotal != out.Customers[j].BytesTotal {
+			return out.Customers[i].BytesTotal > out.Customers[j].BytesTotal
+		}
+		return out.Customers[i].CustomerID < out.Customers[j].CustomerID
+	})
+	return out
+}
+
+// packageCustomers returns the customers a package is attributed to through its
+// SIP's transfer; ok is false when MCP does not know the SIP.
+func packageCustomers(p StoragePackage, transfers map[string]mappingRuleTransfer, attribution *customerAttribution) ([]string, bool) {
+	tr, ok := transfers[strings.ToLower(p.SIPUUID)]
+	if !ok {
+		return []string{forecastUnmappedCustomer}, false
+	}
+	return attribution.customersFor(tr), true
+}
+
+func containsAnyCustomer(ids, customerIDs []string) bool {
+	for _, id := range customerIDs {
+		if containsCustomer(ids, id) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsCustomer(ids []string, customerID string) bool {
+	for _, id := range ids {
+		if strings.EqualFold(id, customerID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeStorageConsumption_CustomersAndLocations(t *testing.T) {
+	period := MonthPeriod(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), time.UTC)
+	before := period.Start.AddDate(0, 0, -10)
+	during := period.Start.AddDate(0, 0, 3)
+
+	packages := []StoragePackage{
+		{UUID: "aip-1", SIPUUID: "sip-1", SizeBytes: 100, StoredAt: before, LocationUUID: "loc-a", Location: "AIP store"},
+		{UUID: "aip-2", SIPUUID: "SIP-2", SizeBytes: 50, StoredAt: during, LocationUUID: "loc-a", Location: "AIP store"},
+		{UUID: "replica-2", SIPUUID: "sip-2", SizeBytes: 50, StoredAt: during, LocationUUID: "loc-b", Location: "Replica"},
+		{UUID: "aip-3", SIPUUID: "sip-3", SizeBytes: 7, StoredAt: during, LocationUUID: "loc-a", Location: "AIP store"},
+		{UUID: "aip-4", SIPUUID: "sip-4", SizeBytes: 9, StoredAt: period.End, LocationUUID: "loc-a", Location: "AIP store"},
+	}
+	transfers := map[string]mappingRuleTransfer{"sip-1": {Source: "acme-ftp"}, "sip-2": {Source: "acme-ftp"}}
+	attribution := testAttribution(map[string][]string{"acme-ftp": {"acme"}}, nil)
+
+	got := computeStorageConsumption(packages, transfers, attribution, period, "", nil)
+
+	if got.Totals.PackagesTotal != 4 || got.Totals.BytesTotal != 207 || got.Totals.BytesPeriod != 107 {
+		t.Fatalf("unexpected totals %+v", got.Totals)
+	}
+	if got.UnresolvedPackages != 1 || len(got.Customers) != 2 {
+		t.Fatalf("expected acme plus unmapped, got %+v", got.Customers)
+	}
+	acme := got.Customers[0]
+	if acme.CustomerID != "acme" || acme.BytesTotal != 200 || acme.BytesAtStart != 100 || acme.PackagesPeriod != 2 {
+		t.Fatalf("unexpected acme usage %+v", acme)
+	}
+	if acme.GrowthPercent == nil || *acme.GrowthPercent != 100 {
+		t.Fatalf("expected 100%% growth, got %v", acme.GrowthPercent)
+	}
+	if len(acme.Locations) != 2 || acme.Locations[0].LocationUUID != "loc-a" || acme.Locations[1].BytesTotal != 50 {
+		t.Fatalf("unexpected acme locations %+v", acme.Locations)
+	}
+	if got.Customers[1].CustomerID != forecastUnmappedCustomer || got.Customers[1].GrowthPercent != nil {
+		t.Fatalf("unexpected unmapped usage %+v", got.Customers[1])
+	}
+
+	filtered := computeStorageConsumption(packages, transfers, attribution, period, "acme", []string{"acme"})
+	if len(filtered.Customers) != 1 || filtered.Totals.BytesTotal != 200 || filtered.UnresolvedPackages != 0 {
+		t.Fatalf("unexpected filtered report %+v", filtered)
+	}
+}
+
+func TestComputeStorageConsumption_AttributesAtTransferStart(t *testing.T) {
+	period := MonthPeriod(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.UTC)
+	switchover := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	before, after := switchover.AddDate(0, 0, -5), switchover.AddDate(0, 0, 5)
+	attribution := &customerAttribution{bySource: map[string][]customerSourceOwner{
+		"shared-ftp": {
+			{CustomerID: "acme", Mapping: CustomerSourceMapping{SourceOfAcquisition: "shared-ftp", EffectiveTo: &switchover}},
+			{CustomerID: "globex", Mapping: CustomerSourceMapping{SourceOfAcquisition: "shared-ftp", EffectiveFrom: &switchover}},
+		},
+	}}
+	packages := []StoragePackage{
+		{UUID: "sip-1", SIPUUID: "sip-1", SizeBytes: 100, StoredAt: before},
+		{UUID: "sip-2", SIPUUID: "sip-2", SizeBytes: 30, StoredAt: after},
+		{UUID: "sip-3", SIPUUID: "sip-3", SizeBytes: 5, StoredAt: after},
+	}
+	transfers := map[string]mappingRuleTransfer{
+		"sip-1": {Source: "shared-ftp", StartedAt: &before},
+		"sip-2": {Source: "shared-ftp", StartedAt: &after},
+		"sip-3": {Source: "shared-ftp"},
+	}
+
+	got := computeStorageConsumption(packages, transfers, attribution, period, "", nil)
+	if len(got.Customers) != 3 || got.Totals.BytesTotal != 135 {
+		t.Fatalf("expected each package counted once, got %+v", got)
+	}
+	byID := map[string]int64{}
+	for _, c := range got.Customers {
+		byID[c.CustomerID] = c.BytesTotal
+	}
+	if byID["acme"] != 100 || byID["globex"] != 30 || byID[forecastUnmappedCustomer] != 5 {
+		t.Fatalf("expected the owner at transfer start, got %v", byID)
+	}
+
+	// A parent's report includes its members.
+	parent := computeStorageConsumption(packages, transfers, attribution, period, "holding", []string{"holding", "acme", "globex"})
+	if len(parent.Customers) != 1 || parent.Customers[0].CustomerID != "holding" || parent.Totals.BytesTotal != 130 {
+		t.Fatalf("unexpected member rollup %+v", parent)
+	}
+}
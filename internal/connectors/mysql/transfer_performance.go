@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"strings"
 	"time"
+
+	"go-am-realtime-report-ui/internal/dbctx"
 )
 
 // TransferTiming contains package-level timing/task information.
@@ -53,7 +55,7 @@ type TransferPerformance struct {
 
 // GetTransferPerformance returns SQL-based per-transfer performance metrics.
 func (s *Store) GetTransferPerformance(ctx context.Context, transferUUID string) (*TransferPerformance, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
 	out := &TransferPerformance{
@@ -81,6 +83,31 @@ LIMIT 1;
 		uuids = append(uuids, relatedSIP)
 	}
 
+	if err := s.fillUnitPerformance(ctx, out, where, uuids, "transferUUID", transferUUID); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetSIPPerformance returns SQL-based performance metrics for one SIP ingest.
+func (s *Store) GetSIPPerformance(ctx context.Context, sipUUID string) (*TransferPerformance, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	out := &TransferPerformance{
+		FormatBreakdown: make([]TransferFormatBreakdown, 0),
+		Microservices:   make([]MicroserviceDuration, 0),
+		Summary:         map[string]any{},
+	}
+	if err := s.fillUnitPerformance(ctx, out, "J.SIPUUID = ? AND J.unitType IN ('unitSIP', 'unitDIP')", []any{sipUUID}, "sipUUID", sipUUID); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// fillUnitPerformance computes timing, size, format and microservice metrics.
+// where/uuids select the Jobs rows; filesColumn/filesUUID select the Files rows.
+func (s *Store) fillUnitPerformance(ctx context.Context, out *TransferPerformance, where string, uuids []any, filesColumn, filesUUID string) error {
 	var (
 		startTime sql.NullTime
 		endTime   sql.NullTime
@@ -98,7 +125,7 @@ JOIN Tasks T
   ON J.jobUUID = T.jobuuid
 WHERE ` + where + `;`
 	if err := s.db.QueryRowContext(ctx, timingQuery, uuids...).Scan(&startTime, &endTime, &durSec, &tasks); err != nil {
-		return nil, err
+		return err
 	}
 	out.TransferDetails = TransferTiming{
 		StartTime:       nullTimePtr(startTime),
@@ -116,9 +143,9 @@ SELECT
   COUNT(*) AS files,
   COALESCE(ROUND(SUM(fileSize)/1024/1024, 2), 0) AS mb
 FROM Files
-WHERE transferUUID = ?;
-`, transferUUID).Scan(&files, &totalMB); err != nil {
-		return nil, err
+WHERE `+filesColumn+` = ?;
+`, filesUUID).Scan(&files, &totalMB); err != nil {
+		return err
 	}
 	out.TransferSize = TransferSize{
 		Files:   nullInt64Value(files),
@@ -136,25 +163,25 @@ LEFT JOIN FilesIdentifiedIDs I
   ON I.fileUUID = F.fileUUID
 LEFT JOIN fpr_formatversion V
   ON V.uuid = I.fileID
-WHERE F.transferUUID = ?
+WHERE F.`+filesColumn+` = ?
 GROUP BY I.fileID, F.fileGrpUse, V.pronom_id, V.description
 ORDER BY c DESC
 LIMIT 200;
-`, transferUUID)
+`, filesUUID)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer formatsRows.Close()
 
 	for formatsRows.Next() {
 		var item TransferFormatBreakdown
 		if err := formatsRows.Scan(&item.PronomID, &item.FileGrpUse, &item.Description, &item.Count); err != nil {
-			return nil, err
+			return err
 		}
 		out.FormatBreakdown = append(out.FormatBreakdown, item)
 	}
 	if err := formatsRows.Err(); err != nil {
-		return nil, err
+		return err
 	}
 
 	microRows, err := s.db.QueryContext(ctx, `
@@ -194,7 +221,7 @@ GROUP BY J.unitType, J.microserviceGroup
 ORDER BY MIN(T.createdTime);
 `, uuids...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer microRows.Close()
 
@@ -206,7 +233,7 @@ ORDER BY MIN(T.createdTime);
 	for microRows.Next() {
 		var item MicroserviceDuration
 		if err := microRows.Scan(&item.Phase, &item.MicroserviceGroup, &item.CPUSeconds, &item.Tasks, &item.FailedTasks, &item.DurationSeconds); err != nil {
-			return nil, err
+			return err
 		}
 
 		if item.DurationSeconds > 0 {
@@ -224,7 +251,7 @@ ORDER BY MIN(T.createdTime);
 		out.Microservices = append(out.Microservices, item)
 	}
 	if err := microRows.Err(); err != nil {
-		return nil, err
+		return err
 	}
 
 	out.Summary["microservices_count"] = len(out.Microservices)
@@ -234,7 +261,7 @@ ORDER BY MIN(T.createdTime);
 	out.Summary["longest_microservice_group"] = topStage
 	out.Summary["longest_microservice_seconds"] = maxDuration
 
-	return out, nil
+	return nil
 }
 
 func safeRatio(cpu, wall int64) float64 {
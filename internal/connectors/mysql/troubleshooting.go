@@ -6,11 +6,14 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"go-am-realtime-report-ui/internal/dbctx"
 )
 
 // TimelineEvent is a job/task event for transfer troubleshooting views.
 type TimelineEvent struct {
 	TransferUUID      string     `json:"transfer_uuid"`
+	UnitType          string     `json:"unit_type,omitempty"`
 	JobUUID           string     `json:"job_uuid"`
 	TaskUUID          string     `json:"task_uuid,omitempty"`
 	JobType           string     `json:"job_type"`
@@ -29,22 +32,25 @@ type TimelineEvent struct {
 
 // TransferError is a failed task/job entry with useful diagnostics.
 type TransferError struct {
-	TransferUUID      string     `json:"transfer_uuid"`
-	JobUUID           string     `json:"job_uuid"`
-	TaskUUID          string     `json:"task_uuid,omitempty"`
-	JobType           string     `json:"job_type"`
-	MicroserviceGroup string     `json:"microservice_group"`
-	FileUUID          string     `json:"file_uuid,omitempty"`
-	FilePath          string     `json:"file_path,omitempty"`
-	Execution         string     `json:"execution,omitempty"`
-	Arguments         string     `json:"arguments,omitempty"`
-	CreatedAt         *time.Time `json:"created_at"`
-	StartedAt         *time.Time `json:"started_at"`
-	EndedAt           *time.Time `json:"ended_at"`
-	ExitCode          *int64     `json:"exit_code,omitempty"`
-	ErrorText         string     `json:"error_text"`
-	StdOut            string     `json:"stdout,omitempty"`
-	StdErr            string     `json:"stderr,omitempty"`
+	TransferUUID      string          `json:"transfer_uuid"`
+	UnitType          string          `json:"unit_type,omitempty"`
+	JobUUID           string          `json:"job_uuid"`
+	TaskUUID          string          `json:"task_uuid,omitempty"`
+	JobType           string          `json:"job_type"`
+	MicroserviceGroup string          `json:"microservice_group"`
+	FileUUID          string          `json:"file_uuid,omitempty"`
+	FilePath          string          `json:"file_path,omitempty"`
+	Execution         string          `json:"execution,omitempty"`
+	Arguments         string          `json:"arguments,omitempty"`
+	CreatedAt         *time.Time      `json:"created_at"`
+	StartedAt         *time.Time      `json:"started_at"`
+	EndedAt           *time.Time      `json:"ended_at"`
+	ExitCode          *int64          `json:"exit_code,omitempty"`
+	ErrorText         string          `json:"error_text"`
+	StdOut            string          `json:"stdout,omitempty"`
+	StdErr            string          `json:"stderr,omitempty"`
+	SignatureID       string          `json:"signature_id"`
+	Knowledge         *KnowledgeMatch `json:"knowledge,omitempty"`
 }
 
 // StalledTransfer represents a running transfer with no recent progress.
@@ -69,27 +75,38 @@ type ErrorHotspot struct {
 
 // FailedTransfer is a transfer-level failed processing summary for triage.
 type FailedTransfer struct {
-	TransferUUID      string     `json:"transfer_uuid"`
-	Name              string     `json:"name"`
-	StatusCode        int        `json:"status_code"`
-	Status            string     `json:"status"`
-	Recoverable       bool       `json:"recoverable"`
-	FailedAt          *time.Time `json:"failed_at"`
-	StartedAt         *time.Time `json:"started_at"`
-	DurationSeconds   int64      `json:"duration_seconds"`
-	FilesTotal        int64      `json:"files_total"`
-	FailedJobs        int64      `json:"failed_jobs"`
-	MicroserviceGroup string     `json:"microservice_group"`
-	ErrorText         string     `json:"error_text"`
+	TransferUUID      string          `json:"transfer_uuid"`
+	Name              string          `json:"name"`
+	StatusCode        int             `json:"status_code"`
+	Status            string          `json:"status"`
+	Recoverable       bool            `json:"recoverable"`
+	FailedAt          *time.Time      `json:"failed_at"`
+	StartedAt         *time.Time      `json:"started_at"`
+	DurationSeconds   int64           `json:"duration_seconds"`
+	FilesTotal        int64           `json:"files_total"`
+	FailedJobs        int64           `json:"failed_jobs"`
+	MicroserviceGroup string          `json:"microservice_group"`
+	ErrorText         string          `json:"error_text"`
+	SignatureID       string          `json:"signature_id"`
+	Knowledge         *KnowledgeMatch `json:"knowledge,omitempty"`
 }
 
 // FailureSignature groups recurring failures by normalized stderr signature.
+// Counts and first/last seen times cover the most recent failureSignatureMaxRows
+// failures of the window, so FirstSeenAt is the first failure in that sample.
 type FailureSignature struct {
-	Signature         string     `json:"signature"`
-	MicroserviceGroup string     `json:"microservice_group"`
-	Failures          int64      `json:"failures"`
-	DistinctTransfers int64      `json:"distinct_transfers"`
-	LastSeenAt        *time.Time `json:"last_seen_at"`
+	ID                string                    `json:"id"`
+	Signature         string                    `json:"signature"`
+	MicroserviceGroup string                    `json:"microservice_group"`
+	Failures          int64                     `json:"failures"`
+	DistinctTransfers int64                     `json:"distinct_transfers"`
+	Variants          int                       `json:"variants"`
+	FirstSeenAt       *time.Time                `json:"first_seen_at"`
+	LastSeenAt        *time.Time                `json:"last_seen_at"`
+	Examples          []FailureSignatureExample `json:"examples"`
+	AffectedTransfers []string                  `json:"affected_transfers"`
+	MemberIDs         []string                  `json:"member_ids"`
+	Knowledge         *KnowledgeMatch           `json:"knowledge,omitempty"`
 }
 
 // FailureCounts summarizes failed task attempts and distinct failed workflows.
@@ -98,6 +115,12 @@ type FailureCounts struct {
 	FailedUnits int64 `json:"failed_units"`
 }
 
+const (
+	transferUnitClause = "j.unitType LIKE '%Transfer'"
+	// ingestUnitClause covers SIP ingest jobs and the DIP jobs that share the SIP UUID.
+	ingestUnitClause = "j.unitType IN ('unitSIP', 'unitDIP')"
+)
+
 func unitWhereClause(unit string) string {
 	switch strings.ToLower(strings.TrimSpace(unit)) {
 	case "sip":
@@ -112,7 +135,7 @@ func unitWhereClause(unit string) string {
 // CountFailureCounts returns failed task and distinct-workflow counts in a time window.
 // unit can be "transfer", "sip", or "all".
 func (s *Store) CountFailureCounts(ctx context.Context, since time.Time, unit string) (*FailureCounts, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
 	q := fmt.Sprintf(`
@@ -137,12 +160,17 @@ WHERE %s
 
 // GetTransferTimeline returns ordered transfer events from Jobs/Tasks.
 func (s *Store) GetTransferTimeline(ctx context.Context, transferUUID string, limit int) ([]TimelineEvent, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	return s.unitTimeline(ctx, transferUnitClause, transferUUID, limit)
+}
+
+func (s *Store) unitTimeline(ctx context.Context, unitClause, unitUUID string, limit int) ([]TimelineEvent, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
-	const q = `
+	q := fmt.Sprintf(`
 SELECT
   j.SIPUUID,
+  COALESCE(j.unitType, ''),
   j.jobUUID,
   COALESCE(tsk.taskUUID, ''),
   COALESCE(j.jobType, ''),
@@ -158,13 +186,13 @@ SELECT
 FROM Jobs j
 LEFT JOIN Tasks tsk
   ON tsk.jobuuid = j.jobUUID
-WHERE j.unitType LIKE '%Transfer'
+WHERE %s
   AND j.SIPUUID = ?
 ORDER BY COALESCE(tsk.startTime, j.createdTime) ASC, j.createdTime ASC
 LIMIT ?;
-`
+`, unitClause)
 
-	rows, err := s.db.QueryContext(ctx, q, transferUUID, limit)
+	rows, err := s.db.QueryContext(ctx, q, unitUUID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -182,6 +210,7 @@ LIMIT ?;
 
 		if err := rows.Scan(
 			&item.TransferUUID,
+			&item.UnitType,
 			&item.JobUUID,
 			&item.TaskUUID,
 			&item.JobType,
@@ -216,12 +245,17 @@ LIMIT ?;
 
 // GetTransferErrors returns failed transfer tasks and their stderr snippets.
 func (s *Store) GetTransferErrors(ctx context.Context, transferUUID string, limit int) ([]TransferError, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	return s.unitErrors(ctx, transferUnitClause, transferUUID, limit)
+}
+
+func (s *Store) unitErrors(ctx context.Context, unitClause, unitUUID string, limit int) ([]TransferError, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
-	const q = `
+	q := fmt.Sprintf(`
 SELECT
   j.SIPUUID,
+  COALESCE(j.unitType, ''),
   j.jobUUID,
   COALESCE(tsk.taskUUID, ''),
   COALESCE(j.jobType, ''),
@@ -241,15 +275,15 @@ LEFT JOIN Tasks tsk
   ON tsk.jobuuid = j.jobUUID
 LEFT JOIN Files f
   ON f.fileUUID = tsk.fileUUID
-		WHERE j.unitType LIKE '%Transfer'
-		  AND j.SIPUUID = ?
-		  AND COALESCE(tsk.exitCode, 0) <> 0
-		  AND j.currentStep = 4
-		ORDER BY COALESCE(tsk.endTime, tsk.startTime, j.createdTime) DESC
-		LIMIT ?;
-		`
+WHERE %s
+  AND j.SIPUUID = ?
+  AND COALESCE(tsk.exitCode, 0) <> 0
+  AND j.currentStep = 4
+ORDER BY COALESCE(tsk.endTime, tsk.startTime, j.createdTime) DESC
+LIMIT ?;
+`, unitClause)
 
-	rows, err := s.db.QueryContext(ctx, q, transferUUID, limit)
+	rows, err := s.db.QueryContext(ctx, q, unitUUID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -269,6 +303,7 @@ LEFT JOIN Files f
 
 		if err := rows.Scan(
 			&item.TransferUUID,
+			&item.UnitType,
 			&item.JobUUID,
 			&item.TaskUUID,
 			&item.JobType,
@@ -301,12 +336,18 @@ LEFT JOIN Files f
 		return nil, err
 	}
 
+	kb := s.loadRowKnowledgeIndex(ctx)
+	for i := range items {
+		templateID := rowSignatureID(items[i].MicroserviceGroup, items[i].StdErr)
+		items[i].SignatureID = kb.clusterID(templateID)
+		items[i].Knowledge = kb.matchRow(items[i].MicroserviceGroup, items[i].StdErr, templateID)
+	}
 	return items, nil
 }
 
 // ListStalledTransfers returns running transfers whose latest progress is older than stuckAfter.
 func (s *Store) ListStalledTransfers(ctx context.Context, limit int) ([]StalledTransfer, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
 	const q = `
@@ -388,7 +429,7 @@ LIMIT ?;
 // ListErrorHotspots returns failing microservice/job groups for a recent time window.
 // unit can be "transfer", "sip", or "all".
 func (s *Store) ListErrorHotspots(ctx context.Context, since time.Time, limit int, unit string) ([]ErrorHotspot, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
 	q := fmt.Sprintf(`
@@ -442,7 +483,7 @@ LEFT JOIN Tasks tsk
 
 // ListFailedTransfers returns failed transfers with latest failure context.
 func (s *Store) ListFailedTransfers(ctx context.Context, since time.Time, until *time.Time, limit, offset int, query string) ([]FailedTransfer, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
 	whereClause := `
@@ -599,70 +640,18 @@ LIMIT ? OFFSET ?;
 		item.Status = transferStatusNameWithEvidence(item.StatusCode, true, item.Recoverable)
 		item.FailedAt = nullTimePtr(failedAt)
 		item.StartedAt = nullTimePtr(startedAt)
+		item.SignatureID = rowSignatureID(item.MicroserviceGroup, item.ErrorText)
 		item.ErrorText = compactError(item.ErrorText)
 		items = append(items, item)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-	return items, nil
-}
-
-// ListFailureSignatures returns grouped recurring error signatures.
-func (s *Store) ListFailureSignatures(ctx context.Context, since time.Time, limit int) ([]FailureSignature, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
-	defer cancel()
-
-	const q = `
-SELECT
-  signature,
-  microservice_group,
-  COUNT(*) AS failures,
-  COUNT(DISTINCT transfer_uuid) AS distinct_transfers,
-  MAX(fail_time) AS last_seen_at
-FROM (
-  SELECT
-    j.SIPUUID AS transfer_uuid,
-    COALESCE(NULLIF(j.microserviceGroup, ''), 'UNKNOWN') AS microservice_group,
-    COALESCE(tsk.endTime, tsk.startTime, j.createdTime) AS fail_time,
-    LEFT(
-      REPLACE(REPLACE(LOWER(TRIM(COALESCE(NULLIF(tsk.stdError, ''), CONCAT('job failed: ', COALESCE(j.jobType, 'unknown'))))), '\n', ' '), '\r', ' '),
-      220
-    ) AS signature
-  FROM Jobs j
-  LEFT JOIN Tasks tsk
-    ON tsk.jobuuid = j.jobUUID
-		  WHERE j.unitType LIKE '%Transfer'
-		    AND COALESCE(tsk.endTime, tsk.startTime, j.createdTime) >= ?
-		    AND COALESCE(tsk.exitCode, 0) <> 0
-		    AND j.currentStep = 4
-) fail_rows
-GROUP BY signature, microservice_group
-ORDER BY failures DESC, last_seen_at DESC
-LIMIT ?;
-`
-
-	rows, err := s.db.QueryContext(ctx, q, since, limit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	items := make([]FailureSignature, 0, limit)
-	for rows.Next() {
-		var (
-			item       FailureSignature
-			lastSeenAt sql.NullTime
-		)
-		if err := rows.Scan(&item.Signature, &item.MicroserviceGroup, &item.Failures, &item.DistinctTransfers, &lastSeenAt); err != nil {
-			return nil, err
-		}
-		item.LastSeenAt = nullTimePtr(lastSeenAt)
-		item.Signature = compactError(item.Signature)
-		items = append(items, item)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+	kb := s.loadRowKnowledgeIndex(ctx)
+	for i := range items {
+		templateID := items[i].SignatureID
+		items[i].SignatureID = kb.clusterID(templateID)
+		items[i].Knowledge = kb.matchRow(items[i].MicroserviceGroup, items[i].ErrorText, templateID)
 	}
 	return items, nil
 }
@@ -0,0 +1,450 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"go-am-realtime-report-ui/internal/dbctx"
+)
+
+const (
+	// workerUtilizationMaxTasks bounds task rows loaded for one utilization request.
+	workerUtilizationMaxTasks = 200000
+	workerUtilizationMaxGaps  = 50
+)
+
+// WorkerUtilizationOptions controls the MCPClient concurrency analysis window.
+type WorkerUtilizationOptions struct {
+	From           time.Time
+	To             time.Time
+	Bucket         time.Duration
+	Workers        int
+	MinIdleSeconds int64
+}
+
+// WorkerUtilization is a task-concurrency timeline with saturation, idle and queueing analysis.
+type WorkerUtilization struct {
+	From              time.Time                `json:"from"`
+	To                time.Time                `json:"to"`
+	BucketSeconds     int64                    `json:"bucket_seconds"`
+	Workers           int                      `json:"workers"`
+	Summary           WorkerUtilizationSummary `json:"summary"`
+	Timeline          []ConcurrencyPoint       `json:"timeline"`
+	Groups            []GroupUtilization       `json:"groups"`
+	IdleGaps          []UtilizationWindow      `json:"idle_gaps"`
+	SaturationWindows []UtilizationWindow      `json:"saturation_windows"`
+}
+
+// WorkerUtilizationSummary holds window-level totals.
+type WorkerUtilizationSummary struct {
+	TasksAnalyzed      int64      `json:"tasks_analyzed"`
+	Truncated          bool       `json:"truncated"`
+	PeakConcurrency    int        `json:"peak_concurrency"`
+	PeakAt             *time.Time `json:"peak_at"`
+	AvgConcurrency     float64    `json:"avg_concurrency"`
+	UtilizationPercent float64    `json:"utilization_percent"`
+	SaturatedSeconds   int64      `json:"saturated_seconds"`
+	SaturationPercent  float64    `json:"saturation_percent"`
+	IdleSeconds        int64      `json:"idle_seconds"`
+	IdlePercent        float64    `json:"idle_percent"`
+	QueueDelay         QueueDelay `json:"queue_delay"`
+}
+
+// QueueDelay summarizes the wait between job creation and its first task start.
+type QueueDelay struct {
+	Jobs       int64 `json:"jobs"`
+	AvgSeconds int64 `json:"avg_seconds"`
+	P50Seconds int64 `json:"p50_seconds"`
+	P95Seconds int64 `json:"p95_seconds"`
+	MaxSeconds int64 `json:"max_seconds"`
+}
+
+// ConcurrencyPoint is one timeline bucket.
+type ConcurrencyPoint struct {
+	Time           time.Time          `json:"time"`
+	AvgConcurrency float64            `json:"avg_concurrency"`
+	MaxConcurrency int                `json:"max_concurrency"`
+	Saturated      bool               `json:"saturated"`
+	ByGroup        map[string]float64 `json:"by_group"`
+}
+
+// GroupUtilization is per-microservice-group busy time and queueing.
+type GroupUtilization struct {
+	MicroserviceGroup string     `json:"microservice_group"`
+	Tasks             int64      `json:"tasks"`
+	BusySeconds       int64      `json:"busy_seconds"`
+	AvgConcurrency    float64    `json:"avg_concurrency"`
+	PeakConcurrency   int        `json:"peak_concurrency"`
+	SharePercent      float64    `json:"share_percent"`
+	QueueDelay        QueueDelay `json:"queue_delay"`
+}
+
+// UtilizationWindow is a contiguous idle or saturated period.
+type UtilizationWindow struct {
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	Seconds         int64     `json:"seconds"`
+	PeakConcurrency int       `json:"peak_concurrency,omitempty"`
+}
+
+type taskInterval struct {
+	Group string
+	Start time.Time
+	End   time.Time
+}
+
+type jobQueueSample struct {
+	Group        string
+	CreatedAt    time.Time
+	FirstStartAt time.Time
+}
+
+// GetWorkerUtilization loads task intervals overlapping the window and computes
+// the concurrency timeline with a sweep line over task start/end events.
+func (s *Store) GetWorkerUtilization(ctx context.Context, opts WorkerUtilizationOptions) (*WorkerUtilization, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	const q = `
+SELECT
+  COALESCE(NULLIF(j.microserviceGroup, ''), 'UNKNOWN') AS microservice_group,
+  j.jobUUID,
+  j.createdTime,
+  tsk.startTime,
+  tsk.endTime
+FROM Tasks tsk
+JOIN Jobs j
+  ON j.jobUUID = tsk.jobuuid
+WHERE tsk.startTime IS NOT NULL
+  AND tsk.startTime < ?
+  AND COALESCE(tsk.endTime, ?) >= ?
+ORDER BY tsk.startTime ASC
+LIMIT ?;
+`
+
+	now := time.Now().UTC()
+	rows, err := s.db.QueryContext(ctx, q, opts.To, now, opts.From, workerUtilizationMaxTasks+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := make([]taskInterval, 0)
+	jobs := map[string]*jobQueueSample{}
+	for rows.Next() {
+		var (
+			group     string
+			jobUUID   string
+			createdAt sql.NullTime
+			startedAt sql.NullTime
+			endedAt   sql.NullTime
+		)
+		if err := rows.Scan(&group, &jobUUID, &createdAt, &startedAt, &endedAt); err != nil {
+			return nil, err
+		}
+		if !startedAt.Valid {
+			continue
+		}
+		end := now
+		if endedAt.Valid {
+			end = endedAt.Time
+		}
+		tasks = append(tasks, taskInterval{Group: group, Start: startedAt.Time, End: end})
+
+		if createdAt.Valid && !startedAt.Time.Before(opts.From) {
+			sample := jobs[jobUUID]
+			if sample == nil {
+				jobs[jobUUID] = &jobQueueSample{Group: group, CreatedAt: createdAt.Time, FirstStartAt: startedAt.Time}
+			} else if startedAt.Time.Before(sample.FirstStartAt) {
+				sample.FirstStartAt = startedAt.Time
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	truncated := len(tasks) > workerUtilizationMaxTasks
+	if truncated {
+		tasks = tasks[:workerUtilizationMaxTasks]
+	}
+	samples := make([]jobQueueSample, 0, len(jobs))
+	for _, j := range jobs {
+		samples = append(samples, *j)
+	}
+
+	out := computeWorkerUtilization(tasks, samples, opts)
+	out.Summary.Truncated = truncated
+	return out, nil
+}
+
+type concurrencyEvent struct {
+	At    time.Time
+	Group string
+	Delta int
+}
+
+// computeWorkerUtilization is the pure sweep-line part of GetWorkerUtilization.
+func computeWorkerUtilization(tasks []taskInterval, samples []jobQueueSample, opts WorkerUtilizationOptions) *WorkerUtilization {
+	from, to := opts.From.UTC(), opts.To.UTC()
+	bucket := opts.Bucket
+	if bucket <= 0 {
+		bucket = time.Hour
+	}
+	out := &WorkerUtilization{
+		From:              from,
+		To:                to,
+		BucketSeconds:     int64(bucket / time.Second),
+		Workers:           opts.Workers,
+		Timeline:          make([]ConcurrencyPoint, 0),
+		Groups:            make([]GroupUtilization, 0),
+		IdleGaps:          make([]UtilizationWindow, 0),
+		SaturationWindows: make([]UtilizationWindow, 0),
+	}
+	if !to.After(from) {
+		return out
+	}
+
+	for t := from; t.Before(to); t = t.Add(bucket) {
+		out.Timeline = append(out.Timeline, ConcurrencyPoint{Time: t, ByGroup: map[string]float64{}})
+	}
+	bucketArea := make([]float64, len(out.Timeline))
+	groupArea := make([]map[string]float64, len(out.Timeline))
+	for i := range groupArea {
+		groupArea[i] = map[string]float64{}
+	}
+
+	events := make([]concurrencyEvent, 0, len(tasks)*2)
+	groupTasks := map[string]int64{}
+	for _, task := range tasks {
+		start, end := task.Start.UTC(), task.End.UTC()
+		if end.Before(start) {
+			end = start
+		}
+		if !end.After(from) || !start.Before(to) {
+			continue
+		}
+		groupTasks[task.Group]++
+		out.Summary.TasksAnalyzed++
+		events = append(events, concurrencyEvent{At: start, Group: task.Group, Delta: 1}, concurrencyEvent{At: end, Group: task.Group, Delta: -1})
+	}
+	// Ends sort before starts at the same instant so back-to-back tasks do not overlap.
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].At.Equal(events[j].At) {
+			return events[i].At.Before(events[j].At)
+		}
+		return events[i].Delta < events[j].Delta
+	})
+
+	var (
+		current     int
+		perGroup    = map[string]int{}
+		groupPeak   = map[string]int{}
+		groupBusy   = map[string]float64{}
+		totalArea   float64
+		cursor      = from
+		idleStart   *time.Time
+		satWindow   *UtilizationWindow
+		minIdle     = opts.MinIdleSeconds
+		bucketSecs  = bucket.Seconds()
+		windowSecs  = to.Sub(from).Seconds()
+		idleSeconds float64
+		satSeconds  float64
+	)
+	if minIdle <= 0 {
+		minIdle = 300
+	}
+
+	closeIdle := func(at time.Time) {
+		if idleStart == nil {
+			return
+		}
+		secs := int64(at.Sub(*idleStart) / time.Second)
+		if secs >= minIdle {
+			out.IdleGaps = append(out.IdleGaps, UtilizationWindow{Start: *idleStart, End: at, Seconds: secs})
+		}
+		idleStart = nil
+	}
+	closeSat := func(at time.Time) {
+		if satWindow == nil {
+			return
+		}
+		satWindow.End = at
+		satWindow.Seconds = int64(at.Sub(satWindow.Start) / time.Second)
+		out.SaturationWindows = append(out.SaturationWindows, *satWindow)
+		satWindow = nil
+	}
+
+	// advance accounts the constant-concurrency segment [cursor, until).
+	advance := func(until time.Time) {
+		if until.After(to) {
+			until = to
+		}
+		if !until.After(cursor) {
+			return
+		}
+		segStart := cursor
+		segSecs := until.Sub(segStart).Seconds()
+		totalArea += float64(current) * segSecs
+		for g, n := range perGroup {
+			if n > 0 {
+				groupBusy[g] += float64(n) * segSecs
+			}
+		}
+
+		if current == 0 {
+			idleSeconds += segSecs
+			if idleStart == nil {
+				t := segStart
+				idleStart = &t
+			}
+		} else {
+			closeIdle(segStart)
+		}
+		if opts.Workers > 0 && current >= opts.Workers {
+			satSeconds += segSecs
+			if satWindow == nil {
+				satWindow = &UtilizationWindow{Start: segStart}
+			}
+			if current > satWindow.PeakConcurrency {
+				satWindow.PeakConcurrency = current
+			}
+		} else {
+			closeSat(segStart)
+		}
+
+		for t := segStart; t.Before(until); {
+			idx := int(t.Sub(from) / bucket)
+			if idx >= len(out.Timeline) {
+				break
+			}
+			bucketEnd := from.Add(time.Duration(idx+1) * bucket)
+			if bucketEnd.After(until) {
+				bucketEnd = until
+			}
+			overlap := bucketEnd.Sub(t).Seconds()
+			bucketArea[idx] += float64(current) * overlap
+			for g, n := range perGroup {
+				if n > 0 {
+					groupArea[idx][g] += float64(n) * overlap
+				}
+			}
+			if current > out.Timeline[idx].MaxConcurrency {
+				out.Timeline[idx].MaxConcurrency = current
+			}
+			t = bucketEnd
+		}
+		cursor = until
+	}
+
+	for _, ev := range events {
+		if ev.At.After(from) {
+			advance(ev.At)
+		}
+		current += ev.Delta
+		perGroup[ev.Group] += ev.Delta
+		if perGroup[ev.Group] > groupPeak[ev.Group] && ev.At.Before(to) {
+			groupPeak[ev.Group] = perGroup[ev.Group]
+		}
+		if current > out.Summary.PeakConcurrency && ev.At.Before(to) {
+			out.Summary.PeakConcurrency = current
+			at := ev.At
+			if at.Before(from) {
+				at = from
+			}
+			out.Summary.PeakAt = &at
+		}
+	}
+	advance(to)
+	closeIdle(to)
+	closeSat(to)
+
+	for i := range out.Timeline {
+		span := bucketSecs
+		if end := out.Timeline[i].Time.Add(bucket); end.After(to) {
+			span = to.Sub(out.Timeline[i].Time).Seconds()
+		}
+		if span <= 0 {
+			continue
+		}
+		out.Timeline[i].AvgConcurrency = round2(bucketArea[i] / span)
+		for g, area := range groupArea[i] {
+			out.Timeline[i].ByGroup[g] = round2(area / span)
+		}
+		out.Timeline[i].Saturated = opts.Workers > 0 && out.Timeline[i].MaxConcurrency >= opts.Workers
+	}
+
+	out.Summary.AvgConcurrency = round2(totalArea / windowSecs)
+	if opts.Workers > 0 {
+		out.Summary.UtilizationPercent = round2(totalArea / windowSecs / float64(opts.Workers) * 100)
+	}
+	out.Summary.SaturatedSeconds = int64(satSeconds)
+	out.Summary.SaturationPercent = round2(satSeconds / windowSecs * 100)
+	out.Summary.IdleSeconds = int64(idleSeconds)
+	out.Summary.IdlePercent = round2(idleSeconds / windowSecs * 100)
+
+	sort.Slice(out.IdleGaps, func(i, j int) bool { return out.IdleGaps[i].Seconds > out.IdleGaps[j].Seconds })
+	if len(out.IdleGaps) > workerUtilizationMaxGaps {
+		out.IdleGaps = out.IdleGaps[:workerUtilizationMaxGaps]
+	}
+	sort.Slice(out.SaturationWindows, func(i, j int) bool {
+		return out.SaturationWindows[i].Seconds > out.SaturationWindows[j].Seconds
+	})
+	if len(out.SaturationWindows) > workerUtilizationMaxGaps {
+		out.SaturationWindows = out.SaturationWindows[:workerUtilizationMaxGaps]
+	}
+
+	allDelays := make([]int64, 0, len(samples))
+	groupDelays := map[string][]int64{}
+	for _, sample := range samples {
+		if sample.FirstStartAt.Before(from) || !sample.FirstStartAt.Before(to) {
+			continue
+		}
+		d := int64(sample.FirstStartAt.Sub(sample.CreatedAt) / time.Second)
+		if d < 0 {
+			d = 0
+		}
+		allDelays = append(allDelays, d)
+		groupDelays[sample.Group] = append(groupDelays[sample.Group], d)
+	}
+	out.Summary.QueueDelay = queueDelayStats(allDelays)
+
+	for g, n := range groupTasks {
+		busy := groupBusy[g]
+		item := GroupUtilization{
+			MicroserviceGroup: g,
+			Tasks:             n,
+			BusySeconds:       int64(busy),
+			AvgConcurrency:    round2(busy / windowSecs),
+			PeakConcurrency:   groupPeak[g],
+			QueueDelay:        queueDelayStats(groupDelays[g]),
+		}
+		if totalArea > 0 {
+			item.SharePercent = round2(busy / totalArea * 100)
+		}
+		out.Groups = append(out.Groups, item)
+	}
+	sort.Slice(out.Groups, func(i, j int) bool {
+		if out.Groups[i].BusySeconds != out.Groups[j].BusySeconds {
+			return out.Groups[i].BusySeconds > out.Groups[j].BusySeconds
+		}
+		return out.Groups[i].MicroserviceGroup < out.Groups[j].MicroserviceGroup
+	})
+	return out
+}
+
+func queueDelayStats(delays []int64) QueueDelay {
+	if len(delays) == 0 {
+		return QueueDelay{}
+	}
+	avg, p50, p95 := durationStats(delays)
+	out := QueueDelay{Jobs: int64(len(delays)), AvgSeconds: avg, P50Seconds: p50, P95Seconds: p95}
+	for _, d := range delays {
+		if d > out.MaxSeconds {
+			out.MaxSeconds = d
+		}
+	}
+	return out
+}
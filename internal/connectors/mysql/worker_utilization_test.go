@@ -0,0 +1,44 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeWorkerUtilization_SweepLine(t *testing.T) {
+	from := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	at := func(min int) time.Time { return from.Add(time.Duration(min) * time.Minute) }
+	tasks := []taskInterval{
+		{Group: "Normalize", Start: at(0), End: at(30)},
+		{Group: "Normalize", Start: at(10), End: at(30)},
+		{Group: "Transcribe", Start: at(30), End: at(40)},
+	}
+	samples := []jobQueueSample{
+		{Group: "Normalize", CreatedAt: at(0), FirstStartAt: at(0)},
+		{Group: "Transcribe", CreatedAt: at(20), FirstStartAt: at(30)},
+	}
+
+	out := computeWorkerUtilization(tasks, samples, WorkerUtilizationOptions{
+		From:           from,
+		To:             at(60),
+		Bucket:         30 * time.Minute,
+		Workers:        2,
+		MinIdleSeconds: 600,
+	})
+
+	if out.Summary.PeakConcurrency != 2 {
+		t.Fatalf("expected peak 2, got %d", out.Summary.PeakConcurrency)
+	}
+	if out.Summary.SaturatedSeconds != 20*60 {
+		t.Fatalf("expected 1200 saturated seconds, got %d", out.Summary.SaturatedSeconds)
+	}
+	if out.Summary.IdleSeconds != 20*60 || len(out.IdleGaps) != 1 || !out.IdleGaps[0].Start.Equal(at(40)) {
+		t.Fatalf("unexpected idle analysis: %d %+v", out.Summary.IdleSeconds, out.IdleGaps)
+	}
+	if len(out.Timeline) != 2 || out.Timeline[0].AvgConcurrency != 1.67 || out.Timeline[1].MaxConcurrency != 1 {
+		t.Fatalf("unexpected timeline: %+v", out.Timeline)
+	}
+	if out.Summary.QueueDelay.Jobs != 2 || out.Summary.QueueDelay.MaxSeconds != 600 {
+		t.Fatalf("unexpected queue delay: %+v", out.Summary.QueueDelay)
+	}
+}
@@ -0,0 +1,73 @@
+package ssdb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go-am-realtime-report-ui/internal/dbctx"
+)
+
+// StoredPackage is an AIP, AIC or AIP replica occupying storage.
+// ReplicaOf holds the original AIP UUID for replicas.
+type StoredPackage struct {
+	UUID         string    `json:"uuid"`
+	PackageType  string    `json:"package_type"`
+	SizeBytes    int64     `json:"size_bytes"`
+	StoredDate   time.Time `json:"stored_date"`
+	ReplicaOf    string    `json:"replica_of,omitempty"`
+	LocationUUID string    `json:"location_uuid"`
+	Location     string    `json:"location"`
+}
+
+// ListStoredPackages returns AIPs, AICs and their replicas stored before end that
+// have not been deleted.
+func (s *Store) ListStoredPackages(ctx context.Context, end time.Time) ([]StoredPackage, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT
+  p.uuid,
+  COALESCE(p.package_type, ''),
+  COALESCE(p.size, 0),
+  p.stored_date,
+  COALESCE(p.replicated_package_id, ''),
+  COALESCE(l.uuid, ''),
+  COALESCE(NULLIF(l.description, ''), NULLIF(l.relative_path, ''), l.purpose, '')
+FROM locations_package p
+LEFT JOIN locations_location l
+  ON l.uuid = p.current_location_id
+WHERE p.package_type IN ('AIP', 'AIC')
+  AND COALESCE(p.status, '') NOT IN ('DELETED', 'FAIL')
+  AND p.stored_date IS NOT NULL
+  AND p.stored_date < ?
+ORDER BY p.stored_date;
+`, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]StoredPackage, 0)
+	for rows.Next() {
+		var (
+			item   StoredPackage
+			stored sql.NullTime
+		)
+		if err := rows.Scan(
+			&item.UUID,
+			&item.PackageType,
+			&item.SizeBytes,
+			&stored,
+			&item.ReplicaOf,
+			&item.LocationUUID,
+			&item.Location,
+		); err != nil {
+			return nil, err
+		}
+		item.StoredDate = stored.Time.UTC()
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
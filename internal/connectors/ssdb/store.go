@@ -9,6 +9,7 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 
 	"go-am-realtime-report-ui/internal/config"
+	"go-am-realtime-report-ui/internal/dbctx"
 )
 
 // Store wraps Storage Service MySQL access.
@@ -76,6 +77,11 @@ func NewStore(cfg config.Config) (*Store, error) {
 	return &Store{db: db, queryTimeout: cfg.SSDBQueryTimeout, dbName: cfg.SSDBName}, nil
 }
 
+// NewStoreWithDB wraps an open Storage Service database without probing it.
+func NewStoreWithDB(db *sql.DB, queryTimeout time.Duration) *Store {
+	return &Store{db: db, queryTimeout: queryTimeout}
+}
+
 func (s *Store) Close() error {
 	if s == nil || s.db == nil {
 		return nil
@@ -84,7 +90,7 @@ func (s *Store) Close() error {
 }
 
 func (s *Store) ServiceStats(ctx context.Context) (*ServiceStats, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
 	start := time.Now()
@@ -139,7 +145,7 @@ func (s *Store) ServiceStats(ctx context.Context) (*ServiceStats, error) {
 }
 
 func (s *Store) LookupPackagesByUUIDs(ctx context.Context, uuids []string) ([]PackageInfo, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
 	norm := normalizeUUIDs(uuids)
@@ -276,15 +282,101 @@ GROUP BY package_id;
 	return out, nil
 }
 
-func (s *Store) ReportStats(ctx context.Context, month time.Time) (*ReportStats, error) {
-	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+// PackageRelation links two SS packages through related_packages or replication.
+type PackageRelation struct {
+	FromUUID string `json:"from_uuid"`
+	ToUUID   string `json:"to_uuid"`
+	Relation string `json:"relation"`
+}
+
+// LookupPackageRelations returns related-package links (e.g. AIP <-> DIP) and
+// replica links touching any of the given package UUIDs.
+func (s *Store) LookupPackageRelations(ctx context.Context, uuids []string) ([]PackageRelation, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
+	norm := normalizeUUIDs(uuids)
+	if len(norm) == 0 {
+		return []PackageRelation{}, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(norm)), ",")
+	args := make([]any, 0, len(norm)*2)
+	for _, id := range norm {
+		args = append(args, id)
+	}
+	for _, id := range norm {
+		args = append(args, id)
+	}
+
+	out := make([]PackageRelation, 0)
+	relatedQuery := `
+SELECT p1.uuid, p2.uuid
+FROM locations_package_related_packages r
+JOIN locations_package p1
+  ON p1.id = r.from_package_id
+JOIN locations_package p2
+  ON p2.id = r.to_package_id
+WHERE p1.uuid IN (` + placeholders + `)
+   OR p2.uuid IN (` + placeholders + `);
+`
+	rows, err := s.db.QueryContext(ctx, relatedQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var rel PackageRelation
+		if err := rows.Scan(&rel.FromUUID, &rel.ToUUID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		rel.Relation = "related_package"
+		out = append(out, rel)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	rows.Close()
+
+	replicaQuery := `
+SELECT p.replicated_package_id, p.uuid
+FROM locations_package p
+WHERE p.replicated_package_id IS NOT NULL
+  AND (p.replicated_package_id IN (` + placeholders + `) OR p.uuid IN (` + placeholders + `));
+`
+	rrows, err := s.db.QueryContext(ctx, replicaQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rrows.Close()
+	for rrows.Next() {
+		var rel PackageRelation
+		if err := rrows.Scan(&rel.FromUUID, &rel.ToUUID); err != nil {
+			return nil, err
+		}
+		rel.Relation = "replica"
+		out = append(out, rel)
+	}
+	if err := rrows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *Store) ReportStats(ctx context.Context, month time.Time) (*ReportStats, error) {
 	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
-	end := start.AddDate(0, 1, 0)
+	return s.ReportStatsForRange(ctx, start, start.AddDate(0, 1, 0), start.Format("2006-01"))
+}
+
+// ReportStatsForRange is ReportStats for an arbitrary [start, end) window; the
+// *_month fields then cover the whole window and label is reported as month.
+func (s *Store) ReportStatsForRange(ctx context.Context, start, end time.Time, label string) (*ReportStats, error) {
+	ctx, cancel := dbctx.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
 
 	out := &ReportStats{
-		Month:        start.Format("2006-01"),
+		Month:        label,
 		PackageTypes: map[string]int64{},
 	}
 
@@ -0,0 +1,25 @@
+// Package dbctx carries per-request database settings through contexts, so callers
+// such as background jobs can relax limits the stores apply to interactive requests.
+package dbctx
+
+import (
+	"context"
+	"time"
+)
+
+type queryTimeoutKey struct{}
+
+// WithQueryTimeout returns a context whose store queries may run for d instead of the
+// store's configured query timeout.
+func WithQueryTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, queryTimeoutKey{}, d)
+}
+
+// WithTimeout derives a query context bounded by the timeout set with
+// WithQueryTimeout, or by def when there is none.
+func WithTimeout(ctx context.Context, def time.Duration) (context.Context, context.CancelFunc) {
+	if d, ok := ctx.Value(queryTimeoutKey{}).(time.Duration); ok && d > 0 {
+		def = d
+	}
+	return context.WithTimeout(ctx, def)
+}
@@ -0,0 +1,18 @@
+package export
+
+import "io"
+
+// WriteCSV writes a table with a header row of column keys, matching the other CSV
+// exports of the API.
+func WriteCSV(w io.Writer, t Table) error {
+	rw, err := newCSVRowWriter(w, t.Columns)
+	if err != nil {
+		return err
+	}
+	for _, row := range t.Rows {
+		if err := rw.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	return rw.Flush()
+}
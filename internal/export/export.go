@@ -0,0 +1,177 @@
+// Package export renders report tables as CSV, XLSX and PDF files. It only depends on
+// the standard library so reports can be exported without external tooling.
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export formats.
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+	FormatPDF  = "pdf"
+)
+
+// Column kinds. An empty kind infers the cell type from the value.
+const (
+	KindString = "string"
+	KindNumber = "number"
+	KindBool   = "bool"
+	KindTime   = "time"
+)
+
+var contentTypes = map[string]string{
+	FormatJSON: "application/json",
+	FormatCSV:  "text/csv; charset=utf-8",
+	FormatXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	FormatPDF:  "application/pdf",
+}
+
+// Column describes one exported column.
+type Column struct {
+	Key   string `json:"key"`
+	Label string `json:"label,omitempty"`
+	Kind  string `json:"kind,omitempty"`
+}
+
+// Table is one exported table; XLSX writes each table as a sheet named after Title.
+type Table struct {
+	Title   string   `json:"title"`
+	Columns []Column `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+// ContentType returns the MIME type of a format.
+func ContentType(format string) string {
+	if format == FormatNDJSON {
+		return ndjsonContentType
+	}
+	return contentTypes[format]
+}
+
+// ParseFormat validates a format name.
+func ParseFormat(raw string) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(raw))
+	if _, ok := contentTypes[format]; !ok {
+		return "", fmt.Errorf("unsupported format: %s (available: json, csv, xlsx, pdf)", raw)
+	}
+	return format, nil
+}
+
+// FormatFromMediaType maps an Accept media type to a format; ok is false for types
+// that are not export formats (e.g. */*).
+func FormatFromMediaType(mediaType string) (string, bool) {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for format, ct := range contentTypes {
+		if mediaType == strings.SplitN(ct, ";", 2)[0] {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+// RowsFromMaps projects map rows onto the table columns.
+func RowsFromMaps(columns []Column, rows []map[string]any) [][]any {
+	out := make([][]any, 0, len(rows))
+	for _, row := range rows {
+		values := make([]any, len(columns))
+		for i, c := range columns {
+			values[i] = row[c.Key]
+		}
+		out = append(out, values)
+	}
+	return out
+}
+
+// cellValue normalizes a value to nil, string, float64, bool or time.Time.
+func cellValue(v any, kind string) any {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case string:
+		if kind == KindTime && x != "" {
+			if t, err := time.Parse(time.RFC3339, x); err == nil {
+				return t.UTC()
+			}
+		}
+		if kind == KindNumber && x != "" {
+			if f, err := strconv.ParseFloat(x, 64); err == nil {
+				return f
+			}
+		}
+		return x
+	case bool:
+		return x
+	case int:
+		return float64(x)
+	case int32:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case uint64:
+		return float64(x)
+	case float32:
+		return float64(x)
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil
+		}
+		return x
+	case *float64:
+		if x == nil {
+			return nil
+		}
+		return cellValue(*x, kind)
+	case json.Number:
+		if f, err := x.Float64(); err == nil {
+			return f
+		}
+		return x.String()
+	case time.Time:
+		if x.IsZero() {
+			return nil
+		}
+		return x.UTC()
+	case *time.Time:
+		if x == nil || x.IsZero() {
+			return nil
+		}
+		return x.UTC()
+	case []string:
+		return strings.Join(x, ", ")
+	case fmt.Stringer:
+		return x.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// cellText formats a normalized cell for text formats.
+func cellText(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case bool:
+		return strconv.FormatBool(x)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case time.Time:
+		return x.Format(time.RFC3339)
+	}
+	return fmt.Sprint(v)
+}
+
+func columnLabel(c Column) string {
+	if c.Label != "" {
+		return c.Label
+	}
+	return c.Key
+}
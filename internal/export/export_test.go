@@ -0,0 +1,232 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testTable = Table{
+	Title: "Transfers: 2026/02",
+	Columns: []Column{
+		{Key: "name", Label: "Name"},
+		{Key: "size_mb", Label: "Size (MB)", Kind: KindNumber},
+		{Key: "ok", Label: "OK", Kind: KindBool},
+		{Key: "completed_at", Label: "Completed At", Kind: KindTime},
+	},
+	Rows: [][]any{
+		{"a, \"quoted\" <name>", 1.5, true, "2026-02-03T04:05:06Z"},
+		{"b", int64(7), false, nil},
+	},
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, testTable); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "name,size_mb,ok,completed_at\n\"a, \"\"quoted\"\" <name>\",1.5,true,2026-02-03T04:05:06Z\nb,7,false,\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected csv:\n%s", buf.String())
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteXLSX(&buf, testTable, Table{Title: testTable.Title, Columns: testTable.Columns[:1]}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("not a zip: %v", err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(body)
+		if strings.HasSuffix(f.Name, ".xml") || strings.HasSuffix(f.Name, ".rels") {
+			dec := xml.NewDecoder(bytes.NewReader(body))
+			for {
+				if _, err := dec.Token(); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("%s is not well-formed: %v", f.Name, err)
+				}
+			}
+		}
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Fatalf("missing part %s", name)
+		}
+	}
+	if !strings.Contains(parts["xl/workbook.xml"], `name="Transfers- 2026-02"`) || !strings.Contains(parts["xl/workbook.xml"], `name="Transfers- 2026-02 (2)"`) {
+		t.Fatalf("unexpected sheet names: %s", parts["xl/workbook.xml"])
+	}
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="B1" t="inlineStr" s="1"><is><t>Size (MB)</t></is></c>`,
+		`<t xml:space="preserve">a, &#34;quoted&#34; &lt;name&gt;</t>`,
+		`<c r="B2"><v>1.5</v></c>`,
+		`<c r="C2" t="b"><v>1</v></c>`,
+		`<c r="D2" s="2"><v>46056.170208333`,
+		`<c r="B3"><v>7</v></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet lacks %s", want)
+		}
+	}
+	if strings.Contains(sheet, `r="D3"`) {
+		t.Errorf("nil cells should be omitted")
+	}
+}
+
+func TestXLSXColumnName(t *testing.T) {
+	for idx, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := xlsxColumnName(idx); got != want {
+			t.Errorf("column %d: got %s want %s", idx, got, want)
+		}
+	}
+}
+
+func TestDocumentBytes(t *testing.T) {
+	doc := NewDocument(Brand{Name: "Acme (Archive)", Color: "#123456"}, "Report – 2026-02", "Customer acme", time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC))
+	doc.Heading("Key figures")
+	doc.Cards([]Card{{Label: "Transfers", Value: "42", Note: "+5.0% vs 2026-01"}})
+	doc.Chart(Chart{Title: "Volume", Kind: ChartBar, Stacked: true, Labels: []string{"2026-02-01", "2026-02-02"}, Series: []Series{{Name: "ok", Values: []float64{3, 4}}, {Name: "failed", Values: []float64{1, 0}}}})
+	doc.Sparklines([]Sparkline{{Label: "Transfers", Value: "42", Values: []float64{1, 3, 2}}})
+	big := Table{Columns: testTable.Columns}
+	for i := 0; i < 120; i++ {
+		big.Rows = append(big.Rows, testTable.Rows[i%2])
+	}
+	doc.Table(big)
+	if doc.PageCount() < 2 {
+		t.Fatalf("expected the table to span pages, got %d", doc.PageCount())
+	}
+
+	out, err := doc.Bytes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatalf("missing PDF header or trailer")
+	}
+
+	// Every xref entry must point at its object.
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(out)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	lines := strings.Split(string(out[xref:]), "\n")
+	count, _ := strconv.Atoi(strings.Fields(lines[1])[1])
+	for i := 1; i < count; i++ {
+		off, _ := strconv.Atoi(strings.Fields(lines[2+i])[0])
+		if !bytes.HasPrefix(out[off:], []byte(fmt.Sprintf("%d 0 obj", i))) {
+			t.Fatalf("xref entry %d points at %q", i, out[off:off+10])
+		}
+	}
+	if !bytes.Contains(out, []byte(fmt.Sprintf("/Count %d", doc.PageCount()))) {
+		t.Fatalf("page tree does not count %d pages", doc.PageCount())
+	}
+
+	streams := regexp.MustCompile(`(?s)stream\n(.*?)\nendstream`).FindAllSubmatch(out, -1)
+	if len(streams) != doc.PageCount() {
+		t.Fatalf("expected %d content streams, got %d", doc.PageCount(), len(streams))
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(streams[0][1]))
+	if err != nil {
+		t.Fatalf("content is not deflated: %v", err)
+	}
+	first, _ := io.ReadAll(zr)
+	for _, want := range []string{`(Acme \(Archive\)) Tj`, `(Report \226 2026-02) Tj`, "(Page 1 of ", "0.07 0.2 0.34 rg"} {
+		if !bytes.Contains(first, []byte(want)) {
+			t.Errorf("first page lacks %q", want)
+		}
+	}
+}
+
+func TestTextLayout(t *testing.T) {
+	if got := textWidth("Hi", 10, false); got != 9.44 {
+		t.Fatalf("unexpected width %v", got)
+	}
+	if got := fitText("abcdefghijklmnopqrstuvwxyz", 40, 10, false); got != "abcdef..." {
+		t.Fatalf("unexpected fit %q", got)
+	}
+	lines := wrapText("the quick brown fox jumps over the lazy dog", 60, 10, false)
+	for _, l := range lines {
+		if textWidth(l, 10, false) > 60 {
+			t.Fatalf("line %q is too wide", l)
+		}
+	}
+	if strings.Join(lines, " ") != "the quick brown fox jumps over the lazy dog" {
+		t.Fatalf("wrapping lost words: %v", lines)
+	}
+	if got := niceCeil(37); got != 50 {
+		t.Fatalf("unexpected axis max %v", got)
+	}
+}
+
+func TestParseFormatAndMediaTypes(t *testing.T) {
+	if f, err := ParseFormat(" XLSX "); err != nil || f != FormatXLSX {
+		t.Fatalf("unexpected %q %v", f, err)
+	}
+	if _, err := ParseFormat("docx"); err == nil {
+		t.Fatal("expected error for docx")
+	}
+	if f, ok := FormatFromMediaType("application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"); !ok || f != FormatXLSX {
+		t.Fatalf("unexpected %q %v", f, ok)
+	}
+	if _, ok := FormatFromMediaType("*/*"); ok {
+		t.Fatal("*/* is not an export format")
+	}
+}
+
+func TestRowWriters(t *testing.T) {
+	var buf bytes.Buffer
+	rw, err := NewRowWriter(&buf, FormatNDJSON, testTable.Columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range testTable.Rows {
+		if err := rw.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"name":"a, \"quoted\" \u003cname\u003e","size_mb":1.5,"ok":true,"completed_at":"2026-02-03T04:05:06Z"}` + "\n" +
+		`{"name":"b","size_mb":7,"ok":false,"completed_at":null}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected ndjson:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	rw, err = NewRowWriter(&buf, FormatCSV, testTable.Columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "name,size_mb,ok,completed_at\n" {
+		t.Fatalf("empty csv stream should still have a header: %q", buf.String())
+	}
+	if _, err := NewRowWriter(&buf, FormatPDF, testTable.Columns); err == nil {
+		t.Fatal("expected error for pdf stream")
+	}
+}
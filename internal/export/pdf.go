@@ -0,0 +1,718 @@
+package export
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A4 portrait in points, and the layout of the page furniture.
+const (
+	pdfPageWidth    = 595.28
+	pdfPageHeight   = 841.89
+	pdfMargin       = 40.0
+	pdfHeaderHeight = 44.0
+	pdfFooterHeight = 30.0
+	pdfContentWidth = pdfPageWidth - 2*pdfMargin
+	pdfContentEnd   = pdfPageHeight - pdfFooterHeight - 16
+	pdfTableFont    = 8.0
+	pdfTableRow     = 15.0
+	pdfDefaultColor = "#0e5d8f"
+)
+
+var (
+	pdfWhite = rgb{1, 1, 1}
+	pdfText  = rgb{0.2, 0.2, 0.2}
+	pdfMuted = rgb{0.47, 0.47, 0.47}
+	pdfLine  = rgb{0.87, 0.87, 0.87}
+	pdfZebra = rgb{0.96, 0.96, 0.96}
+	// pdfPalette colors chart series without an explicit color, after the brand color.
+	pdfPalette = []string{"#d9534f", "#5cb85c", "#f0ad4e", "#5bc0de", "#777777"}
+)
+
+// Brand is the identity printed in the header band of every PDF page.
+type Brand struct {
+	Name  string
+	Color string
+}
+
+// Card is one headline figure of a card grid.
+type Card struct {
+	Label string
+	Value string
+	Note  string
+}
+
+// Series is one data series of a chart.
+type Series struct {
+	Name   string
+	Values []float64
+	Color  string
+}
+
+// Chart is a bar or line chart over labelled buckets.
+type Chart struct {
+	Title   string
+	Kind    string
+	Stacked bool
+	Labels  []string
+	Series  []Series
+	Height  float64
+}
+
+// Chart kinds.
+const (
+	ChartBar  = "bar"
+	ChartLine = "line"
+)
+
+// Sparkline is a small trend line with its current value.
+type Sparkline struct {
+	Label  string
+	Value  string
+	Values []float64
+}
+
+type rgb struct{ r, g, b float64 }
+
+// Document lays out a paginated A4 PDF with a branded header and a page footer.
+// Blocks are added top to bottom and start a new page when they do not fit.
+type Document struct {
+	brand     Brand
+	color     rgb
+	title     string
+	generated time.Time
+	pages     []*bytes.Buffer
+	y         float64
+}
+
+// NewDocument starts a document whose first page carries title and subtitle.
+func NewDocument(brand Brand, title, subtitle string, generated time.Time) *Document {
+	d := &Document{
+		brand:     brand,
+		color:     parseColor(brand.Color, parseColor(pdfDefaultColor, pdfText)),
+		title:     title,
+		generated: generated.UTC(),
+	}
+	d.newPage()
+	d.text(pdfMargin, d.y+18, 18, true, pdfText, fitText(title, pdfContentWidth, 18, true))
+	d.y += 26
+	if subtitle != "" {
+		d.text(pdfMargin, d.y+10, 10, false, pdfMuted, fitText(subtitle, pdfContentWidth, 10, false))
+		d.y += 16
+	}
+	d.y += 8
+	return d
+}
+
+// Heading adds a section heading.
+func (d *Document) Heading(s string) {
+	d.ensure(48)
+	d.y += 6
+	d.text(pdfMargin, d.y+13, 13, true, d.color, fitText(s, pdfContentWidth, 13, true))
+	d.strokeLine(pdfMargin, d.y+18, pdfMargin+pdfContentWidth, d.y+18, 0.6, pdfLine)
+	d.y += 26
+}
+
+// Paragraph adds wrapped body text.
+func (d *Document) Paragraph(s string) {
+	for _, line := range wrapText(s, pdfContentWidth, 9.5, false) {
+		d.ensure(13)
+		d.text(pdfMargin, d.y+10, 9.5, false, pdfText, line)
+		d.y += 13
+	}
+	d.y += 6
+}
+
+// Cards adds a grid of headline figures, three per row.
+func (d *Document) Cards(cards []Card) {
+	const perRow, gap, height = 3, 10.0, 52.0
+	width := (pdfContentWidth - gap*(perRow-1)) / perRow
+	for i, c := range cards {
+		col := i % perRow
+		if col == 0 {
+			if i > 0 {
+				d.y += height + gap
+			}
+			d.ensure(height + gap)
+		}
+		x := pdfMargin + float64(col)*(width+gap)
+		d.fillRect(x, d.y, width, height, pdfZebra)
+		d.fillRect(x, d.y, 3, height, d.color)
+		d.text(x+10, d.y+14, 8, false, pdfMuted, fitText(c.Label, width-16, 8, false))
+		d.text(x+10, d.y+32, 14, true, pdfText, fitText(c.Value, width-16, 14, true))
+		if c.Note != "" {
+			d.text(x+10, d.y+45, 7.5, false, pdfMuted, fitText(c.Note, width-16, 7.5, false))
+		}
+	}
+	if len(cards) > 0 {
+		d.y += height + 14
+	}
+}
+
+// Table adds a table with a header row that repeats on every page it spans. Columns
+// share the page width by content; overlong cells are shortened.
+func (d *Document) Table(t Table) {
+	if len(t.Columns) == 0 {
+		return
+	}
+	widths := pdfColumnWidths(t)
+	numeric := make([]bool, len(t.Columns))
+	cells := make([][]string, len(t.Rows))
+	for r, row := range t.Rows {
+		cells[r] = make([]string, len(t.Columns))
+		for i, c := range t.Columns {
+			var v any
+			if i < len(row) {
+				v = cellValue(row[i], c.Kind)
+			}
+			if _, ok := v.(float64); ok {
+				numeric[i] = true
+			}
+			cells[r][i] = pdfCellText(v)
+		}
+	}
+
+	header := func() {
+		d.fillRect(pdfMargin, d.y, pdfContentWidth, pdfTableRow+2, d.color)
+		x := pdfMargin
+		for i, c := range t.Columns {
+			d.text(x+4, d.y+11, pdfTableFont, true, pdfWhite, fitText(columnLabel(c), widths[i]-8, pdfTableFont, true))
+			x += widths[i]
+		}
+		d.y += pdfTableRow + 2
+	}
+	d.ensure(3 * pdfTableRow)
+	header()
+	for r, row := range cells {
+		if d.y+pdfTableRow > pdfContentEnd {
+			d.newPage()
+			header()
+		}
+		if r%2 == 1 {
+			d.fillRect(pdfMargin, d.y, pdfContentWidth, pdfTableRow, pdfZebra)
+		}
+		x := pdfMargin
+		for i, s := range row {
+			s = fitText(s, widths[i]-8, pdfTableFont, false)
+			tx := x + 4
+			if numeric[i] {
+				tx = x + widths[i] - 4 - textWidth(s, pdfTableFont, false)
+			}
+			d.text(tx, d.y+10.5, pdfTableFont, false, pdfText, s)
+			x += widths[i]
+		}
+		d.y += pdfTableRow
+	}
+	if len(cells) == 0 {
+		d.text(pdfMargin+4, d.y+10.5, pdfTableFont, false, pdfMuted, "No rows.")
+		d.y += pdfTableRow
+	}
+	d.strokeLine(pdfMargin, d.y, pdfMargin+pdfContentWidth, d.y, 0.6, pdfLine)
+	d.y += 14
+}
+
+// Chart adds a bar or line chart with a value axis and legend.
+func (d *Document) Chart(c Chart) {
+	height := c.Height
+	if height <= 0 {
+		height = 160
+	}
+	const axisWidth, titleHeight, labelHeight, legendHeight = 44.0, 18.0, 16.0, 16.0
+	d.ensure(titleHeight + height + labelHeight + legendHeight + 10)
+	d.text(pdfMargin, d.y+11, 10, true, pdfText, fitText(c.Title, pdfContentWidth, 10, true))
+	d.y += titleHeight
+
+	plotX, plotY := pdfMargin+axisWidth, d.y
+	plotW := pdfContentWidth - axisWidth
+	maxValue := 0.0
+	for i := range c.Labels {
+		sum := 0.0
+		for _, s := range c.Series {
+			if i >= len(s.Values) {
+				continue
+			}
+			if c.Stacked {
+				sum += s.Values[i]
+			} else {
+				sum = math.Max(sum, s.Values[i])
+			}
+		}
+		maxValue = math.Max(maxValue, sum)
+	}
+	top := niceCeil(maxValue)
+	const ticks = 4
+	for i := 0; i <= ticks; i++ {
+		value := top * float64(i) / ticks
+		y := plotY + height - height*float64(i)/ticks
+		d.strokeLine(plotX, y, plotX+plotW, y, 0.4, pdfLine)
+		label := formatNumber(value)
+		d.text(plotX-6-textWidth(label, 7, false), y+2.5, 7, false, pdfMuted, label)
+	}
+
+	colors := d.seriesColors(c.Series)
+	n := len(c.Labels)
+	if n > 0 {
+		slot := plotW / float64(n)
+		scale := height / top
+		for i := range c.Labels {
+			x := plotX + slot*float64(i)
+			switch {
+			case c.Kind == ChartLine:
+			case c.Stacked:
+				base := plotY + height
+				for si, s := range c.Series {
+					if i >= len(s.Values) || s.Values[i] <= 0 {
+						continue
+					}
+					h := s.Values[i] * scale
+					d.fillRect(x+slot*0.15, base-h, slot*0.7, h, colors[si])
+					base -= h
+				}
+			default:
+				barW := slot * 0.8 / float64(max(len(c.Series), 1))
+				for si, s := range c.Series {
+					if i >= len(s.Values) || s.Values[i] <= 0 {
+						continue
+					}
+					h := s.Values[i] * scale
+					d.fillRect(x+slot*0.1+barW*float64(si), plotY+height-h, barW, h, colors[si])
+				}
+			}
+		}
+		if c.Kind == ChartLine {
+			for si, s := range c.Series {
+				points := make([][2]float64, 0, len(s.Values))
+				for i, v := range s.Values {
+					if i >= n {
+						break
+					}
+					points = append(points, [2]float64{plotX + slot*(float64(i)+0.5), plotY + height - v*scale})
+				}
+				d.polyline(points, 1.4, colors[si])
+			}
+		}
+
+		maxLabel := 0.0
+		for _, l := range c.Labels {
+			maxLabel = math.Max(maxLabel, textWidth(l, 6.5, false))
+		}
+		step := max(1, int(math.Ceil((maxLabel+6)/slot)))
+		for i := 0; i < n; i += step {
+			label := fitText(c.Labels[i], slot*float64(step)-2, 6.5, false)
+			cx := plotX + slot*(float64(i)+0.5)
+			d.text(cx-textWidth(label, 6.5, false)/2, plotY+height+10, 6.5, false, pdfMuted, label)
+		}
+	}
+	d.strokeLine(plotX, plotY+height, plotX+plotW, plotY+height, 0.8, pdfMuted)
+	d.y = plotY + height + labelHeight
+
+	x := plotX
+	for si, s := range c.Series {
+		d.fillRect(x, d.y+2, 8, 8, colors[si])
+		d.text(x+12, d.y+9, 7.5, false, pdfText, s.Name)
+		x += 24 + textWidth(s.Name, 7.5, false)
+	}
+	d.y += legendHeight + 10
+}
+
+// Sparklines adds small trend lines, three per row.
+func (d *Document) Sparklines(items []Sparkline) {
+	const perRow, gap, height = 3, 10.0, 44.0
+	width := (pdfContentWidth - gap*(perRow-1)) / perRow
+	for i, s := range items {
+		col := i % perRow
+		if col == 0 {
+			if i > 0 {
+				d.y += height + gap
+			}
+			d.ensure(height + gap)
+		}
+		x := pdfMargin + float64(col)*(width+gap)
+		d.strokeRect(x, d.y, width, height, 0.5, pdfLine)
+		d.text(x+6, d.y+12, 7.5, false, pdfMuted, fitText(s.Label, width-12, 7.5, false))
+		d.text(x+6, d.y+30, 11, true, pdfText, fitText(s.Value, width*0.45, 11, true))
+
+		lineX, lineW := x+width*0.5, width*0.5-8
+		lineTop, lineH := d.y+14, height-22
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, v := range s.Values {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+		if len(s.Values) < 2 {
+			continue
+		}
+		points := make([][2]float64, len(s.Values))
+		for j, v := range s.Values {
+			ratio := 0.5
+			if hi > lo {
+				ratio = (v - lo) / (hi - lo)
+			}
+			points[j] = [2]float64{lineX + lineW*float64(j)/float64(len(s.Values)-1), lineTop + lineH*(1-ratio)}
+		}
+		d.polyline(points, 1.1, d.color)
+	}
+	if len(items) > 0 {
+		d.y += height + 14
+	}
+}
+
+// Bytes renders the document, adding the footer with page numbers to every page.
+func (d *Document) Bytes() ([]byte, error) {
+	var out bytes.Buffer
+	offsets := []int{}
+	obj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	obj(fmt.Sprintf("<< /Title (%s) /Producer (%s) /CreationDate (D:%s) >>",
+		pdfEscape(d.title), pdfEscape(d.brandName()), d.generated.Format("20060102150405Z")))
+
+	for i, page := range d.pages {
+		var content bytes.Buffer
+		content.Write(page.Bytes())
+		content.Write(d.footer(i+1, len(d.pages)))
+
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write(content.Bytes()); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfNum(pdfPageWidth), pdfNum(pdfPageHeight), 7+2*i))
+		obj(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.Bytes()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes(), nil
+}
+
+// PageCount returns the number of pages laid out so far.
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+func (d *Document) brandName() string {
+	if strings.TrimSpace(d.brand.Name) == "" {
+		return "Archivematica Reporting"
+	}
+	return d.brand.Name
+}
+
+func (d *Document) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.fillRect(0, 0, pdfPageWidth, pdfHeaderHeight, d.color)
+	d.text(pdfMargin, 27, 13, true, pdfWhite, fitText(d.brandName(), pdfContentWidth*0.55, 13, true))
+	title := fitText(d.title, pdfContentWidth*0.42, 8.5, false)
+	d.text(pdfPageWidth-pdfMargin-textWidth(title, 8.5, false), 26, 8.5, false, pdfWhite, title)
+	d.y = pdfHeaderHeight + 22
+}
+
+// ensure starts a new page unless height points still fit on the current one.
+func (d *Document) ensure(height float64) {
+	if d.y+height > pdfContentEnd {
+		d.newPage()
+	}
+}
+
+func (d *Document) footer(page, pages int) []byte {
+	var b bytes.Buffer
+	y := pdfPageHeight - pdfFooterHeight
+	fmt.Fprintf(&b, "%s RG 0.6 w %s %s m %s %s l S\n", pdfLine.op(), pdfNum(pdfMargin), pdfNum(pdfPageHeight-y), pdfNum(pdfPageWidth-pdfMargin), pdfNum(pdfPageHeight-y))
+	left := "Generated " + d.generated.Format("2006-01-02 15:04 UTC")
+	right := fmt.Sprintf("Page %d of %d", page, pages)
+	writeText(&b, pdfMargin, y+14, 7.5, false, pdfMuted, left)
+	writeText(&b, pdfPageWidth-pdfMargin-textWidth(right, 7.5, false), y+14, 7.5, false, pdfMuted, right)
+	return b.Bytes()
+}
+
+func (d *Document) seriesColors(series []Series) []rgb {
+	out := make([]rgb, len(series))
+	for i, s := range series {
+		fallback := d.color
+		if i > 0 {
+			fallback = parseColor(pdfPalette[(i-1)%len(pdfPalette)], pdfText)
+		}
+		out[i] = parseColor(s.Color, fallback)
+	}
+	return out
+}
+
+func (d *Document) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+// The drawing helpers take top-left based coordinates; text y is the baseline.
+
+func (d *Document) text(x, y, size float64, bold bool, c rgb, s string) {
+	writeText(d.page(), x, y, size, bold, c, s)
+}
+
+func (d *Document) fillRect(x, y, w, h float64, c rgb) {
+	fmt.Fprintf(d.page(), "%s rg %s %s %s %s re f\n", c.op(), pdfNum(x), pdfNum(pdfPageHeight-y-h), pdfNum(w), pdfNum(h))
+}
+
+func (d *Document) strokeRect(x, y, w, h, width float64, c rgb) {
+	fmt.Fprintf(d.page(), "%s RG %s w %s %s %s %s re S\n", c.op(), pdfNum(width), pdfNum(x), pdfNum(pdfPageHeight-y-h), pdfNum(w), pdfNum(h))
+}
+
+func (d *Document) strokeLine(x1, y1, x2, y2, width float64, c rgb) {
+	d.polyline([][2]float64{{x1, y1}, {x2, y2}}, width, c)
+}
+
+func (d *Document) polyline(points [][2]float64, width float64, c rgb) {
+	if len(points) < 2 {
+		return
+	}
+	b := d.page()
+	fmt.Fprintf(b, "%s RG %s w", c.op(), pdfNum(width))
+	for i, p := range points {
+		op := "l"
+		if i == 0 {
+			op = "m"
+		}
+		fmt.Fprintf(b, " %s %s %s", pdfNum(p[0]), pdfNum(pdfPageHeight-p[1]), op)
+	}
+	b.WriteString(" S\n")
+}
+
+func writeText(b *bytes.Buffer, x, y, size float64, bold bool, c rgb, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(b, "BT /%s %s Tf %s rg %s %s Td (%s) Tj ET\n", font, pdfNum(size), c.op(), pdfNum(x), pdfNum(pdfPageHeight-y), pdfEscape(s))
+}
+
+func (c rgb) op() string {
+	return pdfNum(c.r) + " " + pdfNum(c.g) + " " + pdfNum(c.b)
+}
+
+func parseColor(hex string, fallback rgb) rgb {
+	hex = strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	if len(hex) != 6 {
+		return fallback
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return fallback
+	}
+	return rgb{float64(v>>16&0xff) / 255, float64(v>>8&0xff) / 255, float64(v&0xff) / 255}
+}
+
+func pdfNum(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "" || s == "-" || s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// pdfEscape encodes s as a WinAnsi literal string body.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, c := range winAnsi(s) {
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 32 || c >= 127:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94,
+	'•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99, '→': '>', '↑': '^', '↓': 'v',
+}
+
+// winAnsi converts text to the WinAnsi encoding of the standard fonts; characters it
+// cannot represent become '?'.
+func winAnsi(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			out = append(out, ' ')
+		case r >= 32 && r < 127, r >= 0xA0 && r <= 0xFF:
+			out = append(out, byte(r))
+		default:
+			if c, ok := winAnsiExtras[r]; ok {
+				out = append(out, c)
+			} else {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
+
+// Glyph widths (1/1000 em) of Helvetica and Helvetica-Bold for ASCII 32-126.
+var (
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+func textWidth(s string, size float64, bold bool) float64 {
+	widths := &helveticaWidths
+	if bold {
+		widths = &helveticaBoldWidths
+	}
+	total := 0
+	for _, c := range winAnsi(s) {
+		if c >= 32 && c < 127 {
+			total += widths[c-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// fitText shortens s with an ellipsis until it fits width.
+func fitText(s string, width, size float64, bold bool) string {
+	if textWidth(s, size, bold) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if candidate := string(runes) + "..."; textWidth(candidate, size, bold) <= width {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// wrapText breaks s into lines no wider than width, splitting overlong words.
+func wrapText(s string, width, size float64, bold bool) []string {
+	lines := []string{}
+	line := ""
+	for _, word := range strings.Fields(s) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if textWidth(candidate, size, bold) <= width {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		line = word
+		for textWidth(line, size, bold) > width {
+			runes := []rune(line)
+			cut := len(runes) - 1
+			for cut > 1 && textWidth(string(runes[:cut]), size, bold) > width {
+				cut--
+			}
+			lines = append(lines, string(runes[:cut]))
+			line = string(runes[cut:])
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// pdfColumnWidths shares the content width by the widest label or sampled cell of
+// each column, keeping every column readable.
+func pdfColumnWidths(t Table) []float64 {
+	const minWidth, maxWidth = 36.0, 220.0
+	widths := make([]float64, len(t.Columns))
+	total := 0.0
+	for i, c := range t.Columns {
+		w := textWidth(columnLabel(c), pdfTableFont, true)
+		for r, row := range t.Rows {
+			if r >= 200 {
+				break
+			}
+			if i < len(row) {
+				w = math.Max(w, textWidth(pdfCellText(cellValue(row[i], c.Kind)), pdfTableFont, false))
+			}
+		}
+		widths[i] = math.Min(math.Max(w+10, minWidth), maxWidth)
+		total += widths[i]
+	}
+	for i := range widths {
+		widths[i] *= pdfContentWidth / total
+	}
+	return widths
+}
+
+func pdfCellText(v any) string {
+	switch x := v.(type) {
+	case float64:
+		return formatNumber(x)
+	case time.Time:
+		return x.Format("2006-01-02 15:04")
+	case bool:
+		return map[bool]string{true: "yes", false: "no"}[x]
+	}
+	return cellText(v)
+}
+
+// formatNumber prints integers without decimals and other values with two.
+func formatNumber(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// niceCeil rounds an axis maximum up to 1, 2, 2.5 or 5 times a power of ten.
+func niceCeil(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	exp := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 2.5, 5, 10} {
+		if v <= m*exp {
+			return m * exp
+		}
+	}
+	return 10 * exp
+}
@@ -0,0 +1,135 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// FormatNDJSON is newline-delimited JSON, one object per row. It is only produced by
+// RowWriter, since whole tables are exported as JSON by the API handlers.
+const FormatNDJSON = "ndjson"
+
+const ndjsonContentType = "application/x-ndjson"
+
+// RowWriter writes a table one row at a time, so exports of unbounded results do
+// not hold the rows in memory. Flush pushes buffered rows to the underlying writer.
+type RowWriter interface {
+	WriteRow(row []any) error
+	Flush() error
+}
+
+// ParseStreamFormat validates the format of a row stream (csv or ndjson).
+func ParseStreamFormat(raw string) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(raw))
+	if format != FormatCSV && format != FormatNDJSON {
+		return "", fmt.Errorf("unsupported stream format: %s (available: csv, ndjson)", raw)
+	}
+	return format, nil
+}
+
+// StreamFormatFromMediaType maps an Accept media type to a stream format.
+func StreamFormatFromMediaType(mediaType string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(mediaType)) {
+	case "text/csv":
+		return FormatCSV, true
+	case ndjsonContentType, "application/jsonl", "application/json-seq":
+		return FormatNDJSON, true
+	}
+	return "", false
+}
+
+// NewRowWriter returns a RowWriter for a stream format. CSV writes the header row
+// of column keys immediately.
+func NewRowWriter(w io.Writer, format string, columns []Column) (RowWriter, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVRowWriter(w, columns)
+	case FormatNDJSON:
+		return &ndjsonRowWriter{w: bufio.NewWriter(w), columns: columns, keys: ndjsonKeys(columns)}, nil
+	}
+	return nil, fmt.Errorf("unsupported stream format: %s", format)
+}
+
+type csvRowWriter struct {
+	cw      *csv.Writer
+	columns []Column
+	record  []string
+}
+
+func newCSVRowWriter(w io.Writer, columns []Column) (*csvRowWriter, error) {
+	cw := csv.NewWriter(w)
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.Key
+	}
+	if err := cw.Write(header); err != nil {
+		return nil, err
+	}
+	return &csvRowWriter{cw: cw, columns: columns, record: make([]string, len(columns))}, nil
+}
+
+func (c *csvRowWriter) WriteRow(row []any) error {
+	for i, col := range c.columns {
+		var v any
+		if i < len(row) {
+			v = row[i]
+		}
+		c.record[i] = cellText(cellValue(v, col.Kind))
+	}
+	return c.cw.Write(c.record)
+}
+
+func (c *csvRowWriter) Flush() error {
+	c.cw.Flush()
+	return c.cw.Error()
+}
+
+type ndjsonRowWriter struct {
+	w       *bufio.Writer
+	columns []Column
+	keys    [][]byte
+}
+
+// ndjsonKeys pre-encodes the object keys; objects keep the column order.
+func ndjsonKeys(columns []Column) [][]byte {
+	keys := make([][]byte, len(columns))
+	for i, c := range columns {
+		keys[i], _ = json.Marshal(c.Key)
+	}
+	return keys
+}
+
+func (n *ndjsonRowWriter) WriteRow(row []any) error {
+	n.w.WriteByte('{')
+	for i, col := range n.columns {
+		var v any
+		if i < len(row) {
+			v = row[i]
+		}
+		v = cellValue(v, col.Kind)
+		if t, ok := v.(time.Time); ok {
+			v = t.Format(time.RFC3339)
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			n.w.WriteByte(',')
+		}
+		n.w.Write(n.keys[i])
+		n.w.WriteByte(':')
+		n.w.Write(value)
+	}
+	n.w.WriteByte('}')
+	return n.w.WriteByte('\n')
+}
+
+func (n *ndjsonRowWriter) Flush() error {
+	return n.w.Flush()
+}
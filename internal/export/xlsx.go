@@ -0,0 +1,218 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cell style indexes into xlsxStyles' cellXfs.
+const (
+	xlsxStyleDefault = 0
+	xlsxStyleHeader  = 1
+	xlsxStyleTime    = 2
+)
+
+const xlsxMaxColumnWidth = 60
+
+// excelEpoch is day zero of the 1900 date system, accounting for Excel's leap-year bug.
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+const xlsxContentTypesHead = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>
+`
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="3">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>
+<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+</cellXfs>
+<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>
+</styleSheet>
+`
+
+// WriteXLSX writes the tables as sheets of one workbook. Header cells use the column
+// labels; numbers, booleans and times are written as typed cells.
+func WriteXLSX(w io.Writer, tables ...Table) error {
+	if len(tables) == 0 {
+		return fmt.Errorf("no tables to export")
+	}
+	zw := zip.NewWriter(w)
+	names := xlsxSheetNames(tables)
+
+	var contentTypes, workbook, workbookRels bytes.Buffer
+	contentTypes.WriteString(xlsxContentTypesHead)
+	workbook.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets>
+`)
+	workbookRels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+`)
+	for i := range tables {
+		n := i + 1
+		fmt.Fprintf(&contentTypes, "<Override PartName=\"/xl/worksheets/sheet%d.xml\" ContentType=\"application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml\"/>\n", n)
+		fmt.Fprintf(&workbook, "<sheet name=\"%s\" sheetId=\"%d\" r:id=\"rId%d\"/>\n", xmlEscape(names[i]), n, n)
+		fmt.Fprintf(&workbookRels, "<Relationship Id=\"rId%d\" Type=\"http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet\" Target=\"worksheets/sheet%d.xml\"/>\n", n, n)
+	}
+	contentTypes.WriteString("</Types>\n")
+	workbook.WriteString("</sheets>\n</workbook>\n")
+	fmt.Fprintf(&workbookRels, "<Relationship Id=\"rId%d\" Type=\"http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles\" Target=\"styles.xml\"/>\n</Relationships>\n", len(tables)+1)
+
+	parts := []struct {
+		name string
+		body []byte
+	}{
+		{"[Content_Types].xml", contentTypes.Bytes()},
+		{"_rels/.rels", []byte(xlsxRootRels)},
+		{"xl/workbook.xml", workbook.Bytes()},
+		{"xl/_rels/workbook.xml.rels", workbookRels.Bytes()},
+		{"xl/styles.xml", []byte(xlsxStyles)},
+	}
+	for i, t := range tables {
+		parts = append(parts, struct {
+			name string
+			body []byte
+		}{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), xlsxSheet(t)})
+	}
+	for _, p := range parts {
+		fw, err := zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(p.body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func xlsxSheet(t Table) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>
+`)
+	if len(t.Columns) > 0 {
+		b.WriteString("<cols>")
+		for i, c := range t.Columns {
+			fmt.Fprintf(&b, "<col min=\"%d\" max=\"%d\" width=\"%d\" customWidth=\"1\"/>", i+1, i+1, xlsxColumnWidth(c, t.Rows, i))
+		}
+		b.WriteString("</cols>\n")
+	}
+	b.WriteString("<sheetData>\n<row r=\"1\">")
+	for i, c := range t.Columns {
+		fmt.Fprintf(&b, "<c r=\"%s1\" t=\"inlineStr\" s=\"%d\"><is><t>%s</t></is></c>", xlsxColumnName(i), xlsxStyleHeader, xmlEscape(columnLabel(c)))
+	}
+	b.WriteString("</row>\n")
+	for r, row := range t.Rows {
+		rowNum := r + 2
+		fmt.Fprintf(&b, "<row r=\"%d\">", rowNum)
+		for i, c := range t.Columns {
+			if i >= len(row) {
+				break
+			}
+			ref := xlsxColumnName(i) + strconv.Itoa(rowNum)
+			switch v := cellValue(row[i], c.Kind).(type) {
+			case nil:
+			case float64:
+				fmt.Fprintf(&b, "<c r=\"%s\"><v>%s</v></c>", ref, strconv.FormatFloat(v, 'f', -1, 64))
+			case bool:
+				fmt.Fprintf(&b, "<c r=\"%s\" t=\"b\"><v>%d</v></c>", ref, map[bool]int{false: 0, true: 1}[v])
+			case time.Time:
+				serial := v.Sub(excelEpoch).Seconds() / 86400
+				fmt.Fprintf(&b, "<c r=\"%s\" s=\"%d\"><v>%s</v></c>", ref, xlsxStyleTime, strconv.FormatFloat(serial, 'f', -1, 64))
+			default:
+				fmt.Fprintf(&b, "<c r=\"%s\" t=\"inlineStr\"><is><t xml:space=\"preserve\">%s</t></is></c>", ref, xmlEscape(cellText(v)))
+			}
+		}
+		b.WriteString("</row>\n")
+	}
+	b.WriteString("</sheetData>\n</worksheet>\n")
+	return b.Bytes()
+}
+
+// xlsxColumnWidth sizes a column to its label and the first rows, in characters.
+func xlsxColumnWidth(c Column, rows [][]any, idx int) int {
+	width := len(columnLabel(c)) + 2
+	for r, row := range rows {
+		if r >= 200 {
+			break
+		}
+		if idx >= len(row) {
+			continue
+		}
+		v := cellValue(row[idx], c.Kind)
+		n := len(cellText(v)) + 2
+		if _, ok := v.(time.Time); ok {
+			n = 21
+		}
+		width = max(width, n)
+	}
+	return min(width, xlsxMaxColumnWidth)
+}
+
+// xlsxColumnName converts a zero-based column index to A, B, ..., Z, AA, ...
+func xlsxColumnName(idx int) string {
+	name := ""
+	for n := idx + 1; n > 0; n = (n - 1) / 26 {
+		name = string(rune('A'+(n-1)%26)) + name
+	}
+	return name
+}
+
+// xlsxSheetNames derives unique sheet names (at most 31 characters, no []:*?/\).
+func xlsxSheetNames(tables []Table) []string {
+	used := map[string]bool{}
+	out := make([]string, len(tables))
+	for i, t := range tables {
+		name := strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`[]:*?/\`, r) {
+				return '-'
+			}
+			return r
+		}, strings.TrimSpace(t.Title))
+		if name == "" {
+			name = "Sheet" + strconv.Itoa(i+1)
+		}
+		if len([]rune(name)) > 31 {
+			name = string([]rune(name)[:31])
+		}
+		base := name
+		for n := 2; used[strings.ToLower(name)]; n++ {
+			suffix := " (" + strconv.Itoa(n) + ")"
+			name = string([]rune(base)[:min(len([]rune(base)), 31-len(suffix))]) + suffix
+		}
+		used[strings.ToLower(name)] = true
+		out[i] = name
+	}
+	return out
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
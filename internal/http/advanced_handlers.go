@@ -2,6 +2,7 @@ package http
 
 import (
 	"errors"
+	"fmt"
 	nethttp "net/http"
 	"strconv"
 	"strings"
@@ -9,6 +10,7 @@ import (
 
 	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
 	promstore "go-am-realtime-report-ui/internal/connectors/prometheus"
+	"go-am-realtime-report-ui/internal/export"
 )
 
 func parseFailureWindow(raw string, defaultHours, maxHours int) (since time.Time, hours int) {
@@ -131,6 +133,10 @@ func failedTransfersHandler(defaultLimit int, store *mysqlstore.Store) nethttp.H
 			return
 		}
 
+		format, ok := tableExportFormat(w, r)
+		if !ok {
+			return
+		}
 		limit := parseLimit(r, defaultLimit)
 		offset := parseOffset(r)
 		dateFrom, dateTo, err := parseOptionalDateRange(r.URL.Query().Get("date_from"), r.URL.Query().Get("date_to"))
@@ -149,6 +155,10 @@ func failedTransfersHandler(defaultLimit int, store *mysqlstore.Store) nethttp.H
 			writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to fetch failed transfers"})
 			return
 		}
+		if format != export.FormatJSON {
+			writeExport(w, format, fmt.Sprintf("failed-transfers-%s", time.Now().UTC().Format("20060102")), failedTransfersExportTable(items))
+			return
+		}
 
 		meta := map[string]any{"hours": hours, "limit": limit, "offset": offset, "count": len(items)}
 		if dateFrom != nil {
@@ -175,6 +185,10 @@ func failureSignaturesHandler(defaultLimit int, store *mysqlstore.Store) nethttp
 			return
 		}
 
+		format, ok := tableExportFormat(w, r)
+		if !ok {
+			return
+		}
 		limit := parseLimit(r, defaultLimit)
 		since, hours := parseFailureWindow(r.URL.Query().Get("hours"), 24*30, 24*365*20)
 		start := time.Now()
@@ -184,6 +198,10 @@ func failureSignaturesHandler(defaultLimit int, store *mysqlstore.Store) nethttp
 			writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to fetch failure signatures"})
 			return
 		}
+		if format != export.FormatJSON {
+			writeExport(w, format, fmt.Sprintf("failure-signatures-%s", time.Now().UTC().Format("20060102")), failureSignaturesExportTable(items))
+			return
+		}
 
 		writeJSON(w, nethttp.StatusOK, map[string]any{
 			"meta": map[string]any{"hours": hours, "limit": limit, "count": len(items)},
@@ -192,7 +210,7 @@ func failureSignaturesHandler(defaultLimit int, store *mysqlstore.Store) nethttp
 	}
 }
 
-func transferDurationChartHandler(defaultCustomerID string, store *mysqlstore.Store) nethttp.HandlerFunc {
+func transferDurationChartHandler(defaultCustomerID string, fiscalStartMonth int, store *mysqlstore.Store) nethttp.HandlerFunc {
 	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
 		if store == nil {
 			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{"error": "database integration disabled (set APP_DB_ENABLED=true)"})
@@ -204,19 +222,15 @@ func transferDurationChartHandler(defaultCustomerID string, store *mysqlstore.St
 			customerID = defaultCustomerID
 		}
 
-		monthStr := strings.TrimSpace(r.URL.Query().Get("month"))
-		if monthStr == "" {
-			monthStr = time.Now().UTC().Format("2006-01")
-		}
-		month, err := time.Parse("2006-01", monthStr)
+		period, err := parseReportPeriod(r.URL.Query(), fiscalStartMonth, time.Now())
 		if err != nil {
-			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid month format, expected YYYY-MM"})
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
 			return
 		}
 
 		start := time.Now()
-		series, err := store.GetTransferDurationChart(r.Context(), customerID, month)
-		recordDBQuery("mcp", "GetTransferDurationChart", time.Since(start).Seconds(), err)
+		series, err := store.GetPeriodDurationChart(r.Context(), customerID, period)
+		recordDBQuery("mcp", "GetPeriodDurationChart", time.Since(start).Seconds(), err)
 		if err != nil {
 			writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to build transfer duration chart"})
 			return
@@ -225,7 +239,8 @@ func transferDurationChartHandler(defaultCustomerID string, store *mysqlstore.St
 		writeJSON(w, nethttp.StatusOK, map[string]any{
 			"meta": map[string]any{
 				"customer_id": customerID,
-				"month":       month.Format("2006-01"),
+				"month":       period.Label,
+				"period":      period,
 				"count":       len(series),
 			},
 			"data": series,
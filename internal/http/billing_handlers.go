@@ -0,0 +1,359 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+	ssstore "go-am-realtime-report-ui/internal/connectors/ssdb"
+	"go-am-realtime-report-ui/internal/export"
+)
+
+type savePricePlanRequest struct {
+	CustomerID       string                 `json:"customer_id"`
+	Name             string                 `json:"name"`
+	Currency         string                 `json:"currency"`
+	PerGBStoredMonth float64                `json:"per_gb_stored_month"`
+	PerGBIngested    float64                `json:"per_gb_ingested"`
+	PerTransfer      float64                `json:"per_transfer"`
+	StoredTiers      []mysqlstore.PriceTier `json:"stored_tiers"`
+	IngestedTiers    []mysqlstore.PriceTier `json:"ingested_tiers"`
+}
+
+type approveBillingRunRequest struct {
+	ApprovedBy string `json:"approved_by"`
+}
+
+// billingRouter serves /api/v1/reports/billing/plans[/{id}] and
+// /api/v1/reports/billing/runs[/{id}[/approve|/export]].
+func billingRouter(defaultLimit, fiscalStartMonth int, store *mysqlstore.Store, ssStore *ssstore.Store) nethttp.HandlerFunc {
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if store == nil {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{"error": "database integration disabled (set APP_DB_ENABLED=true)"})
+			return
+		}
+		if !store.HasTemplateStore() {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{
+				"error": "template sqlite store not available",
+				"hint":  "set APP_CUSTOMER_MAP_SQLITE_PATH to enable app-owned price plans and billing runs",
+			})
+			return
+		}
+
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/reports/billing"), "/"), "/")
+		switch parts[0] {
+		case "plans":
+			pricePlansRoute(w, r, defaultLimit, store, parts[1:])
+		case "runs":
+			billingRunsRoute(w, r, defaultLimit, fiscalStartMonth, store, ssStore, parts[1:])
+		default:
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "not found"})
+		}
+	}
+}
+
+func pricePlansRoute(w nethttp.ResponseWriter, r *nethttp.Request, defaultLimit int, store *mysqlstore.Store, rest []string) {
+	if len(rest) == 0 {
+		switch r.Method {
+		case nethttp.MethodGet:
+			limit := parseLimit(r, defaultLimit)
+			start := time.Now()
+			items, err := store.ListPricePlans(r.Context(), limit)
+			recordDBQuery("appsqlite", "ListPricePlans", time.Since(start).Seconds(), err)
+			if err != nil {
+				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to list price plans"})
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{
+				"meta": map[string]any{"count": len(items), "limit": limit},
+				"data": items,
+			})
+		case nethttp.MethodPost:
+			savePricePlan(w, r, store, 0)
+		default:
+			writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		}
+		return
+	}
+
+	id, err := strconv.ParseInt(rest[0], 10, 64)
+	if err != nil || id <= 0 || len(rest) > 1 {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid price plan id"})
+		return
+	}
+	switch r.Method {
+	case nethttp.MethodGet:
+		start := time.Now()
+		item, err := store.GetPricePlan(r.Context(), id)
+		recordDBQuery("appsqlite", "GetPricePlan", time.Since(start).Seconds(), err)
+		if err != nil {
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "price plan not found"})
+			return
+		}
+		writeJSON(w, nethttp.StatusOK, map[string]any{"data": item})
+	case nethttp.MethodPut:
+		savePricePlan(w, r, store, id)
+	case nethttp.MethodDelete:
+		start := time.Now()
+		deleted, err := store.DeletePricePlan(r.Context(), id)
+		recordDBQuery("appsqlite", "DeletePricePlan", time.Since(start).Seconds(), err)
+		if err != nil {
+			writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to delete price plan"})
+			return
+		}
+		writeJSON(w, nethttp.StatusOK, map[string]any{
+			"meta": map[string]any{"deleted": deleted, "id": id},
+		})
+	default:
+		writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+	}
+}
+
+func savePricePlan(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store, id int64) {
+	var req savePricePlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
+		return
+	}
+	start := time.Now()
+	savedID, err := store.SavePricePlan(r.Context(), mysqlstore.PricePlan{
+		ID:               id,
+		CustomerID:       req.CustomerID,
+		Name:             req.Name,
+		Currency:         req.Currency,
+		PerGBStoredMonth: req.PerGBStoredMonth,
+		PerGBIngested:    req.PerGBIngested,
+		PerTransfer:      req.PerTransfer,
+		StoredTiers:      req.StoredTiers,
+		IngestedTiers:    req.IngestedTiers,
+	})
+	recordDBQuery("appsqlite", "SavePricePlan", time.Since(start).Seconds(), err)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "price plan not found"})
+		case strings.Contains(strings.ToLower(err.Error()), "unique"):
+			writeJSON(w, nethttp.StatusConflict, map[string]any{"error": "a price plan already exists for this customer"})
+		default:
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		}
+		return
+	}
+	startGet := time.Now()
+	item, err := store.GetPricePlan(r.Context(), savedID)
+	recordDBQuery("appsqlite", "GetPricePlan", time.Since(startGet).Seconds(), err)
+	if err != nil {
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "price plan saved but failed to read it back"})
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, map[string]any{
+		"meta": map[string]any{"saved": true},
+		"data": item,
+	})
+}
+
+func billingRunsRoute(w nethttp.ResponseWriter, r *nethttp.Request, defaultLimit, fiscalStartMonth int, store *mysqlstore.Store, ssStore *ssstore.Store, rest []string) {
+	if len(rest) == 0 {
+		switch r.Method {
+		case nethttp.MethodGet:
+			limit := parseLimit(r, defaultLimit)
+			start := time.Now()
+			items, err := store.ListBillingRuns(r.Context(), limit)
+			recordDBQuery("appsqlite", "ListBillingRuns", time.Since(start).Seconds(), err)
+			if err != nil {
+				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to list billing runs"})
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{
+				"meta": map[string]any{"count": len(items), "limit": limit},
+				"data": items,
+			})
+		case nethttp.MethodPost:
+			createBillingRun(w, r, fiscalStartMonth, store, ssStore)
+		default:
+			writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		}
+		return
+	}
+
+	id, err := strconv.ParseInt(rest[0], 10, 64)
+	if err != nil || id <= 0 || len(rest) > 2 {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid billing run id"})
+		return
+	}
+	action := ""
+	if len(rest) == 2 {
+		action = rest[1]
+	}
+
+	switch {
+	case action == "" && r.Method == nethttp.MethodGet:
+		run, ok := loadBillingRun(w, r, store, id)
+		if !ok {
+			return
+		}
+		writeJSON(w, nethttp.StatusOK, map[string]any{"data": run})
+	case action == "" && r.Method == nethttp.MethodDelete:
+		start := time.Now()
+		deleted, err := store.DeleteBillingRun(r.Context(), id)
+		recordDBQuery("appsqlite", "DeleteBillingRun", time.Since(start).Seconds(), err)
+		if errors.Is(err, mysqlstore.ErrBillingRunApproved) {
+			writeJSON(w, nethttp.StatusConflict, map[string]any{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to delete billing run"})
+			return
+		}
+		writeJSON(w, nethttp.StatusOK, map[string]any{
+			"meta": map[string]any{"deleted": deleted, "id": id},
+		})
+	case action == "approve" && r.Method == nethttp.MethodPost:
+		var req approveBillingRunRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
+				return
+			}
+		}
+		start := time.Now()
+		err := store.ApproveBillingRun(r.Context(), id, req.ApprovedBy)
+		recordDBQuery("appsqlite", "ApproveBillingRun", time.Since(start).Seconds(), err)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "billing run not found"})
+			return
+		case errors.Is(err, mysqlstore.ErrBillingRunApproved):
+			writeJSON(w, nethttp.StatusConflict, map[string]any{"error": "billing run is already approved"})
+			return
+		case err != nil:
+			writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to approve billing run"})
+			return
+		}
+		run, ok := loadBillingRun(w, r, store, id)
+		if !ok {
+			return
+		}
+		writeJSON(w, nethttp.StatusOK, map[string]any{
+			"meta": map[string]any{"approved": true},
+			"data": run,
+		})
+	case action == "export" && r.Method == nethttp.MethodGet:
+		run, ok := loadBillingRun(w, r, store, id)
+		if !ok {
+			return
+		}
+		if run.Status != "approved" {
+			writeJSON(w, nethttp.StatusConflict, map[string]any{"error": "billing run must be approved before export"})
+			return
+		}
+		writeBillingExport(w, r, run)
+	case action == "" || action == "approve" || action == "export":
+		writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+	default:
+		writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "not found"})
+	}
+}
+
+// createBillingRun prices the period given by the monthly report period
+// parameters and stores it as the period's draft.
+func createBillingRun(w nethttp.ResponseWriter, r *nethttp.Request, fiscalStartMonth int, store *mysqlstore.Store, ssStore *ssstore.Store) {
+	if ssStore == nil {
+		writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{"error": "storage service database integration disabled (set APP_SS_DB_ENABLED=true)"})
+		return
+	}
+	period, err := parseReportPeriod(r.URL.Query(), fiscalStartMonth, time.Now())
+	if err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+
+	id, err := priceBillingRun(r.Context(), store, ssStore, period)
+	if err != nil {
+		writeReportError(w, err)
+		return
+	}
+
+	run, ok := loadBillingRun(w, r, store, id)
+	if !ok {
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, map[string]any{
+		"meta": map[string]any{"saved": true, "period": period},
+		"data": run,
+	})
+}
+
+// priceBillingRun prices period against the Storage Service packages and stores it
+// as the period's draft run, returning its id.
+func priceBillingRun(ctx context.Context, store *mysqlstore.Store, ssStore *ssstore.Store, period mysqlstore.ReportPeriod) (int64, error) {
+	runStart := time.Now()
+	start := time.Now()
+	stored, err := ssStore.ListStoredPackages(ctx, period.End)
+	recordDBQuery("ssdb", "ListStoredPackages", time.Since(start).Seconds(), err)
+	if err != nil {
+		recordReportRun("error", time.Since(runStart).Seconds())
+		return 0, &reportError{status: nethttp.StatusInternalServerError, message: "failed to list storage service packages", backend: true}
+	}
+
+	start = time.Now()
+	id, err := store.CreateBillingRun(ctx, period, storagePackagesFromSS(stored))
+	recordDBQuery("mcp", "CreateBillingRun", time.Since(start).Seconds(), err)
+	recordReportRun(map[bool]string{true: "error", false: "success"}[err != nil], time.Since(runStart).Seconds())
+	if errors.Is(err, mysqlstore.ErrBillingRunApproved) {
+		return 0, &reportError{status: nethttp.StatusConflict, message: fmt.Sprintf("billing run for %s is already approved", period.Label)}
+	}
+	if err != nil {
+		return 0, &reportError{status: nethttp.StatusInternalServerError, message: "failed to create billing run", backend: true}
+	}
+	return id, nil
+}
+
+func loadBillingRun(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store, id int64) (*mysqlstore.BillingRun, bool) {
+	start := time.Now()
+	run, err := store.GetBillingRun(r.Context(), id)
+	recordDBQuery("appsqlite", "GetBillingRun", time.Since(start).Seconds(), err)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "billing run not found"})
+		return nil, false
+	}
+	if err != nil {
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to load billing run"})
+		return nil, false
+	}
+	return run, true
+}
+
+// writeBillingExport writes the run's line items for ERP import as CSV
+// (?format=csv) or as a JSON attachment.
+func writeBillingExport(w nethttp.ResponseWriter, r *nethttp.Request, run *mysqlstore.BillingRun) {
+	filename := fmt.Sprintf("billing-%s-%d", run.PeriodLabel, run.ID)
+	format, ok := tableExportFormat(w, r)
+	if !ok {
+		return
+	}
+	if format != export.FormatJSON {
+		writeExport(w, format, filename, billingLinesExportTable(run))
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+	writeJSON(w, nethttp.StatusOK, map[string]any{
+		"run_id":          run.ID,
+		"period":          run.PeriodLabel,
+		"period_start":    run.PeriodStart,
+		"period_end":      run.PeriodEnd,
+		"approved_at":     run.ApprovedAt,
+		"approved_by":     run.ApprovedBy,
+		"customer_totals": run.CustomerTotals,
+		"totals":          run.Totals,
+		"lines":           run.Lines,
+	})
+}
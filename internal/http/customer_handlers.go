@@ -0,0 +1,142 @@
+package http
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	nethttp "net/http"
+	"strings"
+	"time"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+)
+
+type saveCustomerRequest struct {
+	ID          string            `json:"id"`
+	DisplayName string            `json:"display_name"`
+	ParentID    string            `json:"parent_id"`
+	Contact     string            `json:"contact"`
+	Metadata    map[string]string `json:"metadata"`
+}
+
+// customersRoutes serves /api/v1/reports/customers and
+// /api/v1/reports/customers/{id}. Listing works with either mapping backend;
+// customer entities live in the app SQLite store.
+func customersRoutes(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store, defaultLimit int, id string) {
+	if id == "" && r.Method == nethttp.MethodGet {
+		listCustomerTree(w, r, store, defaultLimit)
+		return
+	}
+	if !store.HasTemplateStore() {
+		writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{
+			"error": "template sqlite store not available",
+			"hint":  "set APP_CUSTOMER_MAP_SQLITE_PATH to manage customers",
+		})
+		return
+	}
+	if id == "" {
+		if r.Method != nethttp.MethodPost {
+			writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			return
+		}
+		saveCustomer(w, r, store, "")
+		return
+	}
+
+	switch r.Method {
+	case nethttp.MethodGet:
+		start := time.Now()
+		item, err := store.GetCustomer(r.Context(), id)
+		recordDBQuery("appsqlite", "GetCustomer", time.Since(start).Seconds(), err)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "customer not found"})
+				return
+			}
+			writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to fetch customer"})
+			return
+		}
+		writeJSON(w, nethttp.StatusOK, map[string]any{"data": item})
+	case nethttp.MethodPut:
+		saveCustomer(w, r, store, id)
+	case nethttp.MethodDelete:
+		start := time.Now()
+		deleted, err := store.DeleteCustomer(r.Context(), id)
+		recordDBQuery("appsqlite", "DeleteCustomer", time.Since(start).Seconds(), err)
+		if err != nil {
+			writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to delete customer"})
+			return
+		}
+		writeJSON(w, nethttp.StatusOK, map[string]any{
+			"meta": map[string]any{"deleted": deleted, "id": id},
+		})
+	default:
+		writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+	}
+}
+
+func listCustomerTree(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store, defaultLimit int) {
+	q := r.URL.Query()
+	from, to, err := parseReportDateRange(q.Get("date_from"), q.Get("date_to"))
+	if err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	limit := parseLimit(r, defaultLimit)
+	start := time.Now()
+	tree, err := store.ListCustomers(r.Context(), from, to, limit)
+	recordDBQuery("mcp", "ListCustomers", time.Since(start).Seconds(), err)
+	if err != nil {
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to list customers"})
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, map[string]any{
+		"meta": map[string]any{
+			"limit":          limit,
+			"count":          tree.Count,
+			"date_from":      tree.From.Format(time.RFC3339),
+			"date_to":        tree.To.Format(time.RFC3339),
+			"transfers":      tree.Transfers,
+			"scan_truncated": tree.ScanTruncated,
+		},
+		"data": tree.Customers,
+	})
+}
+
+// saveCustomer creates a customer from the body (POST) or updates the one in the
+// path (PUT).
+func saveCustomer(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store, id string) {
+	var req saveCustomerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
+		return
+	}
+	if id != "" {
+		req.ID = id
+	}
+	req.ID = strings.TrimSpace(req.ID)
+	start := time.Now()
+	err := store.SaveCustomer(r.Context(), mysqlstore.Customer{
+		ID:          req.ID,
+		DisplayName: req.DisplayName,
+		ParentID:    req.ParentID,
+		Contact:     req.Contact,
+		Metadata:    req.Metadata,
+	})
+	recordDBQuery("appsqlite", "SaveCustomer", time.Since(start).Seconds(), err)
+	if err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	startGet := time.Now()
+	item, err := store.GetCustomer(r.Context(), req.ID)
+	recordDBQuery("appsqlite", "GetCustomer", time.Since(startGet).Seconds(), err)
+	if err != nil {
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "customer saved but failed to read it back"})
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, map[string]any{
+		"meta": map[string]any{"saved": true},
+		"data": item,
+	})
+}
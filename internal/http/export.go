@@ -0,0 +1,276 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	nethttp "net/http"
+	"strings"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+	"go-am-realtime-report-ui/internal/export"
+)
+
+// negotiateExportFormat picks the response format from ?format= or, without it, the
+// first export media type listed in the Accept header. It defaults to JSON.
+func negotiateExportFormat(r *nethttp.Request) (string, error) {
+	if raw := strings.TrimSpace(r.URL.Query().Get("format")); raw != "" {
+		return export.ParseFormat(raw)
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		if format, ok := export.FormatFromMediaType(mediaType); ok {
+			return format, nil
+		}
+	}
+	return export.FormatJSON, nil
+}
+
+// tableExportFormat negotiates the format of a tabular endpoint, answering 400 for
+// unknown formats and 406 for PDF, which only monthly reports and their snapshots render.
+func tableExportFormat(w nethttp.ResponseWriter, r *nethttp.Request) (string, bool) {
+	format, err := negotiateExportFormat(r)
+	if err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return "", false
+	}
+	if format == export.FormatPDF {
+		writeJSON(w, nethttp.StatusNotAcceptable, map[string]any{"error": "pdf export is only available for /api/v1/reports/monthly and report snapshots, use format=csv or format=xlsx"})
+		return "", false
+	}
+	return format, true
+}
+
+// writeExport writes tables as a CSV (first table only) or XLSX attachment; filename
+// has no extension.
+func writeExport(w nethttp.ResponseWriter, format, filename string, tables ...export.Table) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case export.FormatCSV:
+		err = export.WriteCSV(&buf, tables[0])
+	case export.FormatXLSX:
+		err = export.WriteXLSX(&buf, tables...)
+	default:
+		err = fmt.Errorf("unsupported export format: %s", format)
+	}
+	if err != nil {
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to render export: " + err.Error()})
+		return
+	}
+	writeAttachment(w, format, filename, buf.Bytes())
+}
+
+func writeAttachment(w nethttp.ResponseWriter, format, filename string, body []byte) {
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+"."+format))
+	w.WriteHeader(nethttp.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// reportExportTable labels ad-hoc report rows from the scope's column catalogue;
+// grouped transfer reports label their dimensions and measures.
+func reportExportTable(scope string, columns []string, rows []map[string]any) export.Table {
+	labels := map[string]string{}
+	catalogue := mysqlstore.AvailableReportColumns(scope)
+	if scope == mysqlstore.ReportScopeTransfer {
+		catalogue = append(catalogue, mysqlstore.AvailableTransferReportGroupBy()...)
+		catalogue = append(catalogue, mysqlstore.AvailableTransferReportMeasures()...)
+	}
+	for _, c := range catalogue {
+		if _, ok := labels[c.Key]; !ok {
+			labels[c.Key] = c.Label
+		}
+	}
+	kinds := mysqlstore.ReportFilterTypes(scope)
+	measures := map[string]bool{}
+	if scope == mysqlstore.ReportScopeTransfer {
+		for _, m := range mysqlstore.AvailableTransferReportMeasures() {
+			measures[m.Key] = true
+		}
+	}
+
+	cols := make([]export.Column, 0, len(columns))
+	for _, key := range columns {
+		kind := kinds[key]
+		switch {
+		case measures[key]:
+			kind = export.KindNumber
+		case kind == "" && strings.HasSuffix(key, "_at"):
+			kind = export.KindTime
+		}
+		cols = append(cols, export.Column{Key: key, Label: labels[key], Kind: kind})
+	}
+	return export.Table{Title: scope + " report", Columns: cols, Rows: export.RowsFromMaps(cols, rows)}
+}
+
+var failedTransferExportColumns = []export.Column{
+	{Key: "transfer_uuid", Label: "Transfer UUID"},
+	{Key: "name", Label: "Name"},
+	{Key: "status", Label: "Status"},
+	{Key: "status_code", Label: "Status Code", Kind: export.KindNumber},
+	{Key: "recoverable", Label: "Recoverable", Kind: export.KindBool},
+	{Key: "failed_at", Label: "Failed At", Kind: export.KindTime},
+	{Key: "started_at", Label: "Started At", Kind: export.KindTime},
+	{Key: "duration_seconds", Label: "Duration (s)", Kind: export.KindNumber},
+	{Key: "files_total", Label: "Files", Kind: export.KindNumber},
+	{Key: "failed_jobs", Label: "Failed Jobs", Kind: export.KindNumber},
+	{Key: "microservice_group", Label: "Microservice Group"},
+	{Key: "signature_id", Label: "Failure Signature"},
+	{Key: "known_issue", Label: "Known Issue"},
+	{Key: "error_text", Label: "Error"},
+}
+
+func failedTransfersExportTable(items []mysqlstore.FailedTransfer) export.Table {
+	rows := make([][]any, 0, len(items))
+	for _, it := range items {
+		knownIssue := ""
+		if it.Knowledge != nil {
+			knownIssue = it.Knowledge.Title
+		}
+		rows = append(rows, []any{
+			it.TransferUUID, it.Name, it.Status, it.StatusCode, it.Recoverable, it.FailedAt, it.StartedAt,
+			it.DurationSeconds, it.FilesTotal, it.FailedJobs, it.MicroserviceGroup, it.SignatureID, knownIssue, it.ErrorText,
+		})
+	}
+	return export.Table{Title: "Failed transfers", Columns: failedTransferExportColumns, Rows: rows}
+}
+
+var failureSignatureExportColumns = []export.Column{
+	{Key: "id", Label: "Signature ID"},
+	{Key: "microservice_group", Label: "Microservice Group"},
+	{Key: "failures", Label: "Failures", Kind: export.KindNumber},
+	{Key: "distinct_transfers", Label: "Transfers", Kind: export.KindNumber},
+	{Key: "variants", Label: "Variants", Kind: export.KindNumber},
+	{Key: "first_seen_at", Label: "First Seen (in sample)", Kind: export.KindTime},
+	{Key: "last_seen_at", Label: "Last Seen", Kind: export.KindTime},
+	{Key: "known_issue", Label: "Known Issue"},
+	{Key: "signature", Label: "Signature"},
+}
+
+func failureSignaturesExportTable(items []mysqlstore.FailureSignature) export.Table {
+	rows := make([][]any, 0, len(items))
+	for _, it := range items {
+		knownIssue := ""
+		if it.Knowledge != nil {
+			knownIssue = it.Knowledge.Title
+		}
+		rows = append(rows, []any{
+			it.ID, it.MicroserviceGroup, it.Failures, it.DistinctTransfers, it.Variants,
+			it.FirstSeenAt, it.LastSeenAt, knownIssue, it.Signature,
+		})
+	}
+	return export.Table{Title: "Failure signatures", Columns: failureSignatureExportColumns, Rows: rows}
+}
+
+var formatOutcomeExportColumns = []export.Column{
+	{Key: "pronom_id", Label: "PRONOM ID"},
+	{Key: "format_name", Label: "Format"},
+	{Key: "files_seen", Label: "Files", Kind: export.KindNumber},
+	{Key: "identification_failures", Label: "Identification Failures", Kind: export.KindNumber},
+	{Key: "normalization_attempts", Label: "Normalization Attempts", Kind: export.KindNumber},
+	{Key: "normalization_successes", Label: "Normalization Successes", Kind: export.KindNumber},
+	{Key: "normalization_failures", Label: "Normalization Failures", Kind: export.KindNumber},
+	{Key: "normalized_files", Label: "Normalized Files", Kind: export.KindNumber},
+	{Key: "normalization_failure_rate", Label: "Normalization Failure Rate (%)", Kind: export.KindNumber},
+	{Key: "avg_tool_runtime_seconds", Label: "Avg Tool Runtime (s)", Kind: export.KindNumber},
+	{Key: "top_failing_commands", Label: "Top Failing Commands"},
+}
+
+func formatOutcomesExportTable(items []mysqlstore.FormatOutcome) export.Table {
+	rows := make([][]any, 0, len(items))
+	for _, it := range items {
+		commands := make([]string, 0, len(it.TopFailingCommands))
+		for _, c := range it.TopFailingCommands {
+			commands = append(commands, fmt.Sprintf("%s [%s] x%d", c.JobType, c.Execution, c.Failures))
+		}
+		rows = append(rows, []any{
+			it.PronomID, it.FormatName, it.FilesSeen, it.IdentificationFailures, it.NormalizationAttempts,
+			it.NormalizationSuccesses, it.NormalizationFailures, it.NormalizedFiles,
+			it.NormalizationFailureRate, it.AvgToolRuntimeSeconds, strings.Join(commands, "; "),
+		})
+	}
+	return export.Table{Title: "Format analytics", Columns: formatOutcomeExportColumns, Rows: rows}
+}
+
+var storageExportColumns = []export.Column{
+	{Key: "customer_id", Label: "Customer"},
+	{Key: "location_uuid", Label: "Location UUID"},
+	{Key: "location", Label: "Location"},
+	{Key: "packages_period", Label: "Packages (Period)", Kind: export.KindNumber},
+	{Key: "bytes_period", Label: "Bytes (Period)", Kind: export.KindNumber},
+	{Key: "packages_total", Label: "Packages (Total)", Kind: export.KindNumber},
+	{Key: "bytes_total", Label: "Bytes (Total)", Kind: export.KindNumber},
+	{Key: "bytes_at_start", Label: "Bytes at Start", Kind: export.KindNumber},
+	{Key: "bytes_per_day", Label: "Bytes per Day", Kind: export.KindNumber},
+	{Key: "growth_percent", Label: "Growth (%)", Kind: export.KindNumber},
+}
+
+// storageExportTable lists each customer (empty location) followed by its locations.
+func storageExportTable(report *mysqlstore.StorageConsumption) export.Table {
+	row := func(customerID, locationUUID, location string, u mysqlstore.StorageUsage) []any {
+		return []any{
+			customerID, locationUUID, location, u.PackagesPeriod, u.BytesPeriod,
+			u.PackagesTotal, u.BytesTotal, u.BytesAtStart, u.BytesPerDay, u.GrowthPercent,
+		}
+	}
+	rows := make([][]any, 0, len(report.Customers))
+	for _, c := range report.Customers {
+		rows = append(rows, row(c.CustomerID, "", "", c.StorageUsage))
+		for _, loc := range c.Locations {
+			rows = append(rows, row(c.CustomerID, loc.LocationUUID, loc.Location, loc.StorageUsage))
+		}
+	}
+	return export.Table{Title: "Storage", Columns: storageExportColumns, Rows: rows}
+}
+
+var slaBreachExportColumns = []export.Column{
+	{Key: "transfer_uuid", Label: "Transfer UUID"},
+	{Key: "transfer_name", Label: "Name"},
+	{Key: "source_of_acquisition", Label: "Source of Acquisition"},
+	{Key: "status", Label: "Status"},
+	{Key: "started_at", Label: "Started At", Kind: export.KindTime},
+	{Key: "ended_at", Label: "Ended At", Kind: export.KindTime},
+	{Key: "elapsed_seconds", Label: "Elapsed (s)", Kind: export.KindNumber},
+	{Key: "excluded_seconds", Label: "Excluded (s)", Kind: export.KindNumber},
+	{Key: "measured_seconds", Label: "Measured (s)", Kind: export.KindNumber},
+	{Key: "over_by_seconds", Label: "Over By (s)", Kind: export.KindNumber},
+}
+
+func slaBreachesExportTable(items []mysqlstore.SLABreach) export.Table {
+	rows := make([][]any, 0, len(items))
+	for _, b := range items {
+		rows = append(rows, []any{
+			b.TransferUUID, b.TransferName, b.SourceOfAcquisition, b.Status, b.StartedAt, b.EndedAt,
+			b.ElapsedSeconds, b.ExcludedSeconds, b.MeasuredSeconds, b.OverBySeconds,
+		})
+	}
+	return export.Table{Title: "SLA breaches", Columns: slaBreachExportColumns, Rows: rows}
+}
+
+var billingLineExportColumns = []export.Column{
+	{Key: "run_id", Label: "Run", Kind: export.KindNumber},
+	{Key: "period", Label: "Period"},
+	{Key: "period_start", Label: "Period Start"},
+	{Key: "period_end", Label: "Period End"},
+	{Key: "customer_id", Label: "Customer"},
+	{Key: "plan_id", Label: "Plan", Kind: export.KindNumber},
+	{Key: "plan_name", Label: "Plan Name"},
+	{Key: "item", Label: "Item"},
+	{Key: "description", Label: "Description"},
+	{Key: "quantity", Label: "Quantity", Kind: export.KindNumber},
+	{Key: "unit", Label: "Unit"},
+	{Key: "unit_price", Label: "Unit Price", Kind: export.KindNumber},
+	{Key: "amount", Label: "Amount", Kind: export.KindNumber},
+	{Key: "currency", Label: "Currency"},
+}
+
+func billingLinesExportTable(run *mysqlstore.BillingRun) export.Table {
+	rows := make([][]any, 0, len(run.Lines))
+	for _, l := range run.Lines {
+		rows = append(rows, []any{
+			run.ID, run.PeriodLabel, run.PeriodStart, run.PeriodEnd, l.CustomerID, l.PlanID, l.PlanName,
+			l.Item, l.Description, l.Quantity, l.Unit, l.UnitPrice, l.Amount, l.Currency,
+		})
+	}
+	return export.Table{Title: "Billing lines", Columns: billingLineExportColumns, Rows: rows}
+}
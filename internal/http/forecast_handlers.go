@@ -0,0 +1,79 @@
+package http
+
+import (
+	"fmt"
+	nethttp "net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+)
+
+const (
+	forecastDefaultHistoryDays = 90
+	forecastMaxHistoryDays     = 730
+	forecastDefaultHorizonDays = 30
+	forecastMaxHorizonDays     = 365
+)
+
+func backlogForecastHandler(store *mysqlstore.Store) nethttp.HandlerFunc {
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if store == nil {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{"error": "database integration disabled (set APP_DB_ENABLED=true)"})
+			return
+		}
+		if r.Method != nethttp.MethodGet {
+			writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			return
+		}
+
+		query := r.URL.Query()
+		historyDays, err := parseForecastDays(query.Get("history_days"), forecastDefaultHistoryDays, forecastMaxHistoryDays)
+		if err != nil {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid history_days: " + err.Error()})
+			return
+		}
+		horizonDays, err := parseForecastDays(query.Get("days"), forecastDefaultHorizonDays, forecastMaxHorizonDays)
+		if err != nil {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid days: " + err.Error()})
+			return
+		}
+		customerID := strings.TrimSpace(query.Get("customer_id"))
+
+		start := time.Now()
+		forecast, err := store.GetBacklogForecast(r.Context(), mysqlstore.BacklogForecastOptions{
+			HistoryDays: historyDays,
+			HorizonDays: horizonDays,
+			CustomerID:  customerID,
+		})
+		recordDBQuery("mcp", "GetBacklogForecast", time.Since(start).Seconds(), err)
+		recordReportRun(map[bool]string{true: "error", false: "success"}[err != nil], time.Since(start).Seconds())
+		if err != nil {
+			writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to build backlog forecast"})
+			return
+		}
+
+		writeJSON(w, nethttp.StatusOK, map[string]any{
+			"meta": map[string]any{
+				"customer_id":  customerID,
+				"history_days": historyDays,
+				"days":         horizonDays,
+				"model":        "daily arrival/throughput mean with normal p10-p90 band",
+			},
+			"data": forecast,
+		})
+	}
+}
+
+func parseForecastDays(raw string, def, max int) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 1 || v > max {
+		return 0, fmt.Errorf("expected an integer between 1 and %d", max)
+	}
+	return v, nil
+}
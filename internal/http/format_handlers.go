@@ -0,0 +1,67 @@
+package http
+
+import (
+	"fmt"
+	nethttp "net/http"
+	"strings"
+	"time"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+	"go-am-realtime-report-ui/internal/export"
+)
+
+func formatAnalyticsHandler(defaultLimit int, store *mysqlstore.Store) nethttp.HandlerFunc {
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if store == nil {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{"error": "database integration disabled (set APP_DB_ENABLED=true)"})
+			return
+		}
+		if r.Method != nethttp.MethodGet {
+			writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			return
+		}
+
+		format, ok := tableExportFormat(w, r)
+		if !ok {
+			return
+		}
+		dateFrom, dateTo, err := parseReportDateRange(r.URL.Query().Get("date_from"), r.URL.Query().Get("date_to"))
+		if err != nil {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		customerID := strings.TrimSpace(r.URL.Query().Get("customer_id"))
+		limit := parseLimit(r, defaultLimit)
+
+		start := time.Now()
+		items, err := store.GetFormatAnalytics(r.Context(), mysqlstore.FormatAnalyticsOptions{
+			DateFrom:   dateFrom,
+			DateTo:     dateTo,
+			CustomerID: customerID,
+			Limit:      limit,
+		})
+		recordDBQuery("mcp", "GetFormatAnalytics", time.Since(start).Seconds(), err)
+		recordReportRun(map[bool]string{true: "error", false: "success"}[err != nil], time.Since(start).Seconds())
+		if err != nil {
+			writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to build format analytics"})
+			return
+		}
+
+		if format != export.FormatJSON {
+			writeExport(w, format, fmt.Sprintf("format-analytics-%s-%s", dateFrom.Format("20060102"), dateTo.Format("20060102")), formatOutcomesExportTable(items))
+			return
+		}
+
+		writeJSON(w, nethttp.StatusOK, map[string]any{
+			"meta": map[string]any{
+				"date_from":            dateFrom.Format(time.RFC3339),
+				"date_to":              dateTo.Format(time.RFC3339),
+				"customer_id":          customerID,
+				"customer_filter_mode": store.CustomerMappingMode(),
+				"limit":                limit,
+				"count":                len(items),
+			},
+			"data": items,
+		})
+	}
+}
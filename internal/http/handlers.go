@@ -13,6 +13,7 @@ import (
 	esstore "go-am-realtime-report-ui/internal/connectors/es"
 	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
 	ssstore "go-am-realtime-report-ui/internal/connectors/ssdb"
+	"go-am-realtime-report-ui/internal/export"
 )
 
 type createMappingRequest struct {
@@ -25,6 +26,7 @@ type replaceMappingsRequest struct {
 }
 
 type runReportRequest struct {
+	Scope      string   `json:"scope"`
 	DateFrom   string   `json:"date_from"`
 	DateTo     string   `json:"date_to"`
 	Status     string   `json:"status"`
@@ -32,13 +34,10 @@ type runReportRequest struct {
 	Limit      int      `json:"limit"`
 	Offset     int      `json:"offset"`
 	Columns    []string `json:"columns"`
-}
-
-type saveTemplateRequest struct {
-	Name        string         `json:"name"`
-	Description string         `json:"description"`
-	Scope       string         `json:"scope"`
-	Config      map[string]any `json:"config"`
+	GroupBy    []string `json:"group_by"`
+	Measures   []string `json:"measures"`
+	Filter     string   `json:"filter"`
+	Sort       []string `json:"sort"`
 }
 
 func runningTransfersHandler(defaultLimit int, store *mysqlstore.Store) nethttp.HandlerFunc {
@@ -178,164 +177,21 @@ func completedTransfersHandler(defaultLimit int, store *mysqlstore.Store) nethtt
 }
 
 func transferDetailRouter(defaultLimit int, store *mysqlstore.Store, ssStore *ssstore.Store, esClient *esstore.Client, esLookupLimit int) nethttp.HandlerFunc {
-	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
-		if store == nil {
-			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{
-				"error": "database integration disabled (set APP_DB_ENABLED=true)",
-			})
-			return
-		}
-
-		trimmed := strings.TrimPrefix(r.URL.Path, "/api/v1/transfers/")
-		parts := strings.Split(strings.Trim(trimmed, "/"), "/")
-		if len(parts) != 2 || parts[0] == "" {
-			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "not found"})
-			return
-		}
-
-		transferUUID := parts[0]
-		action := parts[1]
-		limit := parseLimit(r, defaultLimit)
-
-		switch action {
-		case "summary":
-			start := time.Now()
-			item, err := store.GetTransferSummary(r.Context(), transferUUID)
-			recordDBQuery("mcp", "GetTransferSummary", time.Since(start).Seconds(), err)
-			if err != nil {
-				if strings.Contains(err.Error(), "no rows in result set") {
-					writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": fmt.Sprintf("transfer not found: %s", transferUUID)})
-					return
-				}
-				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to fetch transfer summary"})
-				return
-			}
-			writeJSON(w, nethttp.StatusOK, map[string]any{"data": item})
-		case "details":
-			startSummary := time.Now()
-			summary, err := store.GetTransferSummary(r.Context(), transferUUID)
-			recordDBQuery("mcp", "GetTransferSummary", time.Since(startSummary).Seconds(), err)
-			if err != nil {
-				if strings.Contains(err.Error(), "no rows in result set") {
-					writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": fmt.Sprintf("transfer not found: %s", transferUUID)})
-					return
-				}
-				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to fetch transfer summary"})
-				return
-			}
-
-			startTimeline := time.Now()
-			timeline, err := store.GetTransferTimeline(r.Context(), transferUUID, limit)
-			recordDBQuery("mcp", "GetTransferTimeline", time.Since(startTimeline).Seconds(), err)
-			if err != nil {
-				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to fetch transfer timeline"})
-				return
-			}
-			startErrors := time.Now()
-			errs, err := store.GetTransferErrors(r.Context(), transferUUID, limit)
-			recordDBQuery("mcp", "GetTransferErrors", time.Since(startErrors).Seconds(), err)
-			if err != nil {
-				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to fetch transfer errors"})
-				return
-			}
-
-			payload := map[string]any{
-				"summary":  summary,
-				"timeline": timeline,
-				"errors":   errs,
-			}
-			meta := map[string]any{
-				"transfer_uuid":  transferUUID,
-				"timeline_count": len(timeline),
-				"error_count":    len(errs),
-			}
-
-			startPerf := time.Now()
-			perf, perfErr := store.GetTransferPerformance(r.Context(), transferUUID)
-			recordDBQuery("mcp", "GetTransferPerformance", time.Since(startPerf).Seconds(), perfErr)
-			if perfErr != nil {
-				meta["performance_error"] = perfErr.Error()
-			} else if perf != nil {
-				payload["performance"] = perf
-				meta["microservices_count"] = perf.Summary["microservices_count"]
-			}
-
-			if esClient != nil && esClient.Enabled() {
-				startES := time.Now()
-				esResult, esErr := esClient.SearchTransfer(r.Context(), transferUUID, esLookupLimit)
-				recordExternalProbe("elasticsearch", "SearchTransfer", time.Since(startES).Seconds(), esErr)
-				if esErr != nil {
-					meta["es_error"] = esErr.Error()
-				} else if esResult != nil {
-					// If transfer UUID returns no hits, try related SIP/AIP UUID from performance view.
-					if esResult.TotalHits == 0 && perf != nil && strings.TrimSpace(perf.RelatedSIPUUID) != "" {
-						startESAlt := time.Now()
-						alt, altErr := esClient.SearchTransfer(r.Context(), strings.TrimSpace(perf.RelatedSIPUUID), esLookupLimit)
-						recordExternalProbe("elasticsearch", "SearchTransfer", time.Since(startESAlt).Seconds(), altErr)
-						if altErr == nil && alt != nil {
-							esResult = alt
-							meta["es_lookup_uuid"] = strings.TrimSpace(perf.RelatedSIPUUID)
-						}
-					}
-					payload["elasticsearch"] = esResult
-					meta["es_hits"] = esResult.TotalHits
-				}
-			}
-
-			if ssStore != nil {
-				lookupUUIDs := []string{transferUUID}
-				if perf != nil && strings.TrimSpace(perf.RelatedSIPUUID) != "" {
-					lookupUUIDs = append(lookupUUIDs, perf.RelatedSIPUUID)
-				}
-				startSS := time.Now()
-				packages, ssErr := ssStore.LookupPackagesByUUIDs(r.Context(), lookupUUIDs)
-				recordDBQuery("ssdb", "LookupPackagesByUUIDs", time.Since(startSS).Seconds(), ssErr)
-				if ssErr != nil {
-					meta["ss_error"] = ssErr.Error()
-				} else {
-					payload["storage_service"] = map[string]any{
-						"lookup_uuids": lookupUUIDs,
-						"packages":     packages,
-					}
-					meta["ss_packages"] = len(packages)
-				}
-			}
-
-			writeJSON(w, nethttp.StatusOK, map[string]any{
-				"meta": meta,
-				"data": payload,
-			})
-		case "timeline":
-			start := time.Now()
-			items, err := store.GetTransferTimeline(r.Context(), transferUUID, limit)
-			recordDBQuery("mcp", "GetTransferTimeline", time.Since(start).Seconds(), err)
-			if err != nil {
-				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to fetch transfer timeline"})
-				return
-			}
-			writeJSON(w, nethttp.StatusOK, map[string]any{
-				"meta": map[string]any{"transfer_uuid": transferUUID, "limit": limit, "count": len(items)},
-				"data": items,
-			})
-		case "errors":
-			start := time.Now()
-			items, err := store.GetTransferErrors(r.Context(), transferUUID, limit)
-			recordDBQuery("mcp", "GetTransferErrors", time.Since(start).Seconds(), err)
-			if err != nil {
-				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to fetch transfer errors"})
-				return
-			}
-			writeJSON(w, nethttp.StatusOK, map[string]any{
-				"meta": map[string]any{"transfer_uuid": transferUUID, "limit": limit, "count": len(items)},
-				"data": items,
-			})
-		default:
-			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "not found"})
-		}
-	}
+	return unitDetailRouter(unitDetail{
+		prefix: "/api/v1/transfers/",
+		name:   "transfer",
+		op:     "Transfer",
+		summary: func(ctx context.Context, uuid string) (any, error) {
+			return store.GetTransferSummary(ctx, uuid)
+		},
+		timeline:         store.GetTransferTimeline,
+		errors:           store.GetTransferErrors,
+		performance:      store.GetTransferPerformance,
+		followRelatedSIP: true,
+	}, defaultLimit, store, ssStore, esClient, esLookupLimit)
 }
 
-func monthlyReportHandler(defaultCustomerID string, store *mysqlstore.Store, ssStore *ssstore.Store) nethttp.HandlerFunc {
+func monthlyReportHandler(defaultCustomerID string, fiscalStartMonth int, store *mysqlstore.Store, ssStore *ssstore.Store, brand export.Brand) nethttp.HandlerFunc {
 	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
 		if store == nil {
 			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{
@@ -349,41 +205,100 @@ func monthlyReportHandler(defaultCustomerID string, store *mysqlstore.Store, ssS
 			customerID = defaultCustomerID
 		}
 
-		monthStr := r.URL.Query().Get("month")
-		if monthStr == "" {
-			monthStr = time.Now().UTC().Format("2006-01")
+		period, err := parseReportPeriod(r.URL.Query(), fiscalStartMonth, time.Now())
+		if err != nil {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		compare, err := parseComparisonOptions(r.URL.Query().Get("compare"), r.URL.Query().Get("sparkline"))
+		if err != nil {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{
+				"error": err.Error(),
+			})
+			return
 		}
 
-		month, err := time.Parse("2006-01", monthStr)
+		format, err := negotiateExportFormat(r)
 		if err != nil {
 			writeJSON(w, nethttp.StatusBadRequest, map[string]any{
-				"error": "invalid month format, expected YYYY-MM",
+				"error": err.Error(),
 			})
 			return
 		}
 
 		start := time.Now()
-		report, err := store.GetMonthlyReport(r.Context(), customerID, month)
-		recordDBQuery("mcp", "GetMonthlyReport", time.Since(start).Seconds(), err)
+		report, err := store.GetPeriodReport(r.Context(), customerID, period)
+		recordDBQuery("mcp", "GetPeriodReport", time.Since(start).Seconds(), err)
 		if err != nil {
 			writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to build monthly report"})
 			return
 		}
+		legacyTimeseries(r.URL.Query(), report)
 
-		writeJSON(w, nethttp.StatusOK, map[string]any{
-			"meta": map[string]any{
-				"customer_id":          report.CustomerID,
-				"month":                report.Month,
-				"customer_filter_mode": store.CustomerMappingMode(),
-			},
-			"kpis":         report.KPIs,
-			"timeseries":   report.Timeseries,
-			"integrations": buildReportIntegrations(r.Context(), ssStore, month),
-		})
+		var comparison *mysqlstore.ReportComparison
+		if compare.Previous || compare.YearAgo || compare.Sparkline > 0 {
+			startCompare := time.Now()
+			comparison, err = store.GetReportComparison(r.Context(), customerID, report, compare)
+			recordDBQuery("mcp", "GetReportComparison", time.Since(startCompare).Seconds(), err)
+			if err != nil {
+				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to build period comparison"})
+				return
+			}
+		}
+
+		if format != export.FormatJSON {
+			var durations []mysqlstore.DurationChartPoint
+			if format != export.FormatCSV {
+				startChart := time.Now()
+				durations, err = store.GetPeriodDurationChart(r.Context(), customerID, period)
+				recordDBQuery("mcp", "GetPeriodDurationChart", time.Since(startChart).Seconds(), err)
+				if err != nil {
+					writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to build transfer duration chart"})
+					return
+				}
+			}
+			filename := fmt.Sprintf("monthly-report-%s-%s", report.Month, report.CustomerID)
+			if format == export.FormatPDF {
+				body, err := renderMonthlyReportPDF(brand, report, comparison, durations, time.Now())
+				if err != nil {
+					writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to render monthly report pdf"})
+					return
+				}
+				writeAttachment(w, format, filename, body)
+				return
+			}
+			writeExport(w, format, filename, monthlyReportExportTables(report, comparison, durations)...)
+			return
+		}
+
+		writeJSON(w, nethttp.StatusOK, monthlyReportResponse(r.Context(), store, ssStore, period, report, comparison))
 	}
 }
 
-func buildReportIntegrations(ctx context.Context, ssStore *ssstore.Store, month time.Time) map[string]any {
+// monthlyReportResponse is the JSON body of the monthly report.
+func monthlyReportResponse(ctx context.Context, store *mysqlstore.Store, ssStore *ssstore.Store, period mysqlstore.ReportPeriod, report *mysqlstore.MonthlyReport, comparison *mysqlstore.ReportComparison) map[string]any {
+	resp := map[string]any{
+		"meta": map[string]any{
+			"customer_id":          report.CustomerID,
+			"month":                report.Month,
+			"period":               report.Period,
+			"customer_filter_mode": store.CustomerMappingMode(),
+		},
+		"kpis":         report.KPIs,
+		"timeseries":   report.Timeseries,
+		"sla":          report.SLA,
+		"integrations": buildReportIntegrations(ctx, ssStore, period),
+	}
+	if comparison != nil {
+		resp["comparison"] = comparison
+	}
+	return resp
+}
+
+func buildReportIntegrations(ctx context.Context, ssStore *ssstore.Store, period mysqlstore.ReportPeriod) map[string]any {
 	out := map[string]any{}
 	if ssStore == nil {
 		out["storage_service_db"] = map[string]any{
@@ -394,7 +309,7 @@ func buildReportIntegrations(ctx context.Context, ssStore *ssstore.Store, month
 	}
 
 	start := time.Now()
-	stats, err := ssStore.ReportStats(ctx, month)
+	stats, err := ssStore.ReportStatsForRange(ctx, period.Start, period.End, period.Label)
 	recordDBQuery("ssdb", "ReportStats", time.Since(start).Seconds(), err)
 	if err != nil {
 		out["storage_service_db"] = map[string]any{
@@ -413,7 +328,8 @@ func buildReportIntegrations(ctx context.Context, ssStore *ssstore.Store, month
 	return out
 }
 
-func reportRoutesRouter(defaultLimit int, store *mysqlstore.Store) nethttp.HandlerFunc {
+func reportRoutesRouter(defaultLimit int, store *mysqlstore.Store, ssStore *ssstore.Store, esClient *esstore.Client, esIndex string, esPageSize int) nethttp.HandlerFunc {
+	deps := reportScopeDeps{ssStore: ssStore, esClient: esClient, esIndex: esIndex, esPageSize: esPageSize}
 	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
 		if store == nil {
 			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{"error": "database integration disabled (set APP_DB_ENABLED=true)"})
@@ -426,191 +342,76 @@ func reportRoutesRouter(defaultLimit int, store *mysqlstore.Store) nethttp.Handl
 				writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
 				return
 			}
+			scope, err := mysqlstore.NormalizeReportScope(r.URL.Query().Get("scope"))
+			if err != nil {
+				writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+				return
+			}
+			columns := mysqlstore.AvailableReportColumns(scope)
+			keys := make([]string, 0, len(columns))
+			for _, c := range columns {
+				keys = append(keys, c.Key)
+			}
+			groupBy := []mysqlstore.ReportColumn{}
+			measures := []mysqlstore.ReportColumn{}
+			statuses := []string{"all"}
+			if scope == mysqlstore.ReportScopeTransfer {
+				groupBy = mysqlstore.AvailableTransferReportGroupBy()
+				measures = mysqlstore.AvailableTransferReportMeasures()
+				statuses = append(statuses, "success", "failed", "completed_with_non_blocking_errors")
+			}
 			writeJSON(w, nethttp.StatusOK, map[string]any{
 				"data": map[string]any{
-					"columns": mysqlstore.AvailableTransferReportColumns(),
-					"statuses": []string{
-						"all",
-						"success",
-						"failed",
-						"completed_with_non_blocking_errors",
-					},
+					"scope":           scope,
+					"scopes":          mysqlstore.ReportScopes(),
+					"columns":         columns,
+					"default_columns": mysqlstore.NormalizeReportColumns(scope, nil),
+					"late_columns":    mysqlstore.ReportLateColumns(scope, keys),
+					"group_by":        groupBy,
+					"measures":        measures,
+					"filter_types":    mysqlstore.ReportFilterTypes(scope),
+					"statuses":        statuses,
 				},
 			})
 			return
 		}
+		if path == "query/stream" {
+			if r.Method != nethttp.MethodPost {
+				writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+				return
+			}
+			streamReportQuery(w, r, store)
+			return
+		}
 		if path == "query" {
 			if r.Method != nethttp.MethodPost {
 				writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
 				return
 			}
+			format, ok := tableExportFormat(w, r)
+			if !ok {
+				return
+			}
 			var req runReportRequest
 			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 				writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
 				return
 			}
-			dateFrom, dateTo, err := parseReportDateRange(req.DateFrom, req.DateTo)
+			result, err := runReportQuery(r.Context(), store, deps, req, defaultLimit, 1000)
 			if err != nil {
-				writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+				writeReportError(w, err)
 				return
 			}
-
-			limit := req.Limit
-			if limit <= 0 || limit > 1000 {
-				limit = defaultLimit
-			}
-			offset := req.Offset
-			if offset < 0 {
-				offset = 0
-			}
-			columns := mysqlstore.NormalizeTransferReportColumns(req.Columns)
-			start := time.Now()
-			result, err := store.RunTransferReport(r.Context(), mysqlstore.TransferReportOptions{
-				DateFrom:   dateFrom,
-				DateTo:     dateTo,
-				Status:     req.Status,
-				CustomerID: req.CustomerID,
-				Limit:      limit,
-				Offset:     offset,
-				Columns:    columns,
-			})
-			recordDBQuery("mcp", "RunTransferReport", time.Since(start).Seconds(), err)
-			recordReportRun(map[bool]string{true: "error", false: "success"}[err != nil], time.Since(start).Seconds())
-			if err != nil {
-				writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+			if format != export.FormatJSON {
+				writeExport(w, format, result.filename, result.table)
 				return
 			}
 			writeJSON(w, nethttp.StatusOK, map[string]any{
-				"meta": map[string]any{
-					"date_from": dateFrom.Format(time.RFC3339),
-					"date_to":   dateTo.Format(time.RFC3339),
-					"status":    strings.TrimSpace(req.Status),
-					"customer":  strings.TrimSpace(req.CustomerID),
-					"limit":     limit,
-					"offset":    offset,
-					"columns":   columns,
-					"total":     result.Total,
-					"count":     len(result.Rows),
-				},
-				"data": result.Rows,
+				"meta": result.meta,
+				"data": result.rows,
 			})
 			return
 		}
-		if path == "templates" {
-			if !store.HasTemplateStore() {
-				writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{
-					"error": "template sqlite store not available",
-					"hint":  "set APP_CUSTOMER_MAP_SQLITE_PATH to enable app-owned template persistence",
-				})
-				return
-			}
-			switch r.Method {
-			case nethttp.MethodGet:
-				limit := parseLimit(r, defaultLimit)
-				start := time.Now()
-				items, err := store.ListReportTemplates(r.Context(), limit)
-				recordDBQuery("appsqlite", "ListReportTemplates", time.Since(start).Seconds(), err)
-				if err != nil {
-					writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to list report templates"})
-					return
-				}
-				writeJSON(w, nethttp.StatusOK, map[string]any{
-					"meta": map[string]any{"count": len(items), "limit": limit},
-					"data": items,
-				})
-				return
-			case nethttp.MethodPost:
-				var req saveTemplateRequest
-				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-					writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
-					return
-				}
-				req.Name = strings.TrimSpace(req.Name)
-				req.Description = strings.TrimSpace(req.Description)
-				req.Scope = strings.TrimSpace(req.Scope)
-				if req.Name == "" {
-					writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "template name is required"})
-					return
-				}
-				if req.Scope == "" {
-					req.Scope = "transfer"
-				}
-				if req.Config == nil {
-					req.Config = map[string]any{}
-				}
-				configJSON, err := json.Marshal(req.Config)
-				if err != nil {
-					writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid template config"})
-					return
-				}
-				startUpsert := time.Now()
-				id, err := store.UpsertReportTemplate(r.Context(), req.Name, req.Description, req.Scope, string(configJSON))
-				recordDBQuery("appsqlite", "UpsertReportTemplate", time.Since(startUpsert).Seconds(), err)
-				if err != nil {
-					writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
-					return
-				}
-				startGet := time.Now()
-				item, err := store.GetReportTemplate(r.Context(), id)
-				recordDBQuery("appsqlite", "GetReportTemplate", time.Since(startGet).Seconds(), err)
-				if err != nil {
-					writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "template saved but failed to read it back"})
-					return
-				}
-				writeJSON(w, nethttp.StatusOK, map[string]any{
-					"meta": map[string]any{"saved": true},
-					"data": item,
-				})
-				return
-			default:
-				writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
-				return
-			}
-		}
-
-		if strings.HasPrefix(path, "templates/") {
-			if !store.HasTemplateStore() {
-				writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{
-					"error": "template sqlite store not available",
-					"hint":  "set APP_CUSTOMER_MAP_SQLITE_PATH to enable app-owned template persistence",
-				})
-				return
-			}
-			idRaw := strings.Trim(strings.TrimPrefix(path, "templates/"), "/")
-			id, err := strconv.ParseInt(idRaw, 10, 64)
-			if err != nil || id <= 0 {
-				writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid template id"})
-				return
-			}
-			switch r.Method {
-			case nethttp.MethodGet:
-				startGet := time.Now()
-				item, err := store.GetReportTemplate(r.Context(), id)
-				recordDBQuery("appsqlite", "GetReportTemplate", time.Since(startGet).Seconds(), err)
-				if err != nil {
-					writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "template not found"})
-					return
-				}
-				writeJSON(w, nethttp.StatusOK, map[string]any{"data": item})
-				return
-			case nethttp.MethodDelete:
-				startDelete := time.Now()
-				deleted, err := store.DeleteReportTemplate(r.Context(), id)
-				recordDBQuery("appsqlite", "DeleteReportTemplate", time.Since(startDelete).Seconds(), err)
-				if err != nil {
-					writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to delete template"})
-					return
-				}
-				writeJSON(w, nethttp.StatusOK, map[string]any{
-					"meta": map[string]any{"deleted": deleted, "id": id},
-				})
-				return
-			default:
-				writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
-				return
-			}
-		}
-
 		if !store.HasCustomerSourceMapping() {
 			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{
 				"error": "customer mapping backend not available",
@@ -619,23 +420,8 @@ func reportRoutesRouter(defaultLimit int, store *mysqlstore.Store) nethttp.Handl
 			return
 		}
 
-		if path == "customers" {
-			if r.Method != nethttp.MethodGet {
-				writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
-				return
-			}
-			limit := parseLimit(r, defaultLimit)
-			start := time.Now()
-			items, err := store.ListCustomers(r.Context(), limit)
-			recordDBQuery("appsqlite", "ListCustomers", time.Since(start).Seconds(), err)
-			if err != nil {
-				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to list customers"})
-				return
-			}
-			writeJSON(w, nethttp.StatusOK, map[string]any{
-				"meta": map[string]any{"limit": limit, "count": len(items)},
-				"data": items,
-			})
+		if path == "customers" || strings.HasPrefix(path, "customers/") {
+			customersRoutes(w, r, store, defaultLimit, strings.Trim(strings.TrimPrefix(path, "customers"), "/"))
 			return
 		}
 
@@ -657,6 +443,10 @@ func reportRoutesRouter(defaultLimit int, store *mysqlstore.Store) nethttp.Handl
 				writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "customer_id path parameter is required"})
 				return
 			}
+			if i := strings.LastIndex(customerID, "/"); i > 0 && (customerID[i+1:] == "history" || customerID[i+1:] == "validity") {
+				customerMappingHistoryRoutes(w, r, store, defaultLimit, customerID[:i], customerID[i+1:])
+				return
+			}
 
 			switch r.Method {
 			case nethttp.MethodGet:
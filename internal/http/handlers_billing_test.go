@@ -0,0 +1,83 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBillingRouter_DBDisabled(t *testing.T) {
+	h := billingRouter(50, 1, nil, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/reports/billing/runs?month=2026-02", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestBillingRouter_ApprovedRunCannotBeRegenerated(t *testing.T) {
+	ts := newTestStores(t)
+	if _, err := ts.store.AddCustomerMapping(context.Background(), "acme", "acme-src", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	ts.exec(t, ts.mcp,
+		`INSERT INTO Transfers (transferUUID, sourceOfAcquisition, status, completed_at) VALUES ('t-1', 'acme-src', 2, '2026-02-05 10:00:00'), ('t-2', 'acme-src', 3, '2026-02-06 10:00:00')`,
+	)
+	h := billingRouter(50, 1, ts.store, ts.ssStore)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := serve(http.MethodPost, "/api/v1/reports/billing/plans", `{"customer_id":"acme","name":"Standard","currency":"EUR","per_transfer":2.5}`); rr.Code != http.StatusOK {
+		t.Fatalf("save plan: got %d %s", rr.Code, rr.Body.String())
+	}
+	rr := serve(http.MethodPost, "/api/v1/reports/billing/runs?month=2026-02", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("create run: got %d %s", rr.Code, rr.Body.String())
+	}
+	var created struct {
+		Data struct {
+			ID     int64              `json:"id"`
+			Status string             `json:"status"`
+			Totals map[string]float64 `json:"totals"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Data.Status != "draft" || created.Data.Totals["EUR"] != 5 {
+		t.Fatalf("unexpected draft run %+v", created.Data)
+	}
+	runPath := "/api/v1/reports/billing/runs/" + strconv.FormatInt(created.Data.ID, 10)
+
+	if rr := serve(http.MethodGet, runPath+"/export?format=csv", ""); rr.Code != http.StatusConflict {
+		t.Fatalf("export of a draft: expected 409, got %d", rr.Code)
+	}
+	if rr := serve(http.MethodPost, runPath+"/approve", `{"approved_by":"finance"}`); rr.Code != http.StatusOK {
+		t.Fatalf("approve: got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := serve(http.MethodPost, runPath+"/approve", ""); rr.Code != http.StatusConflict {
+		t.Fatalf("second approval: expected 409, got %d", rr.Code)
+	}
+	if rr := serve(http.MethodPost, "/api/v1/reports/billing/runs?month=2026-02", ""); rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "already approved") {
+		t.Fatalf("regenerate: expected 409, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := serve(http.MethodDelete, runPath, ""); rr.Code != http.StatusConflict {
+		t.Fatalf("delete: expected 409, got %d", rr.Code)
+	}
+
+	rr = serve(http.MethodGet, runPath+"/export?format=csv", "")
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if rr.Code != http.StatusOK || len(lines) != 2 || !strings.Contains(lines[1], ",acme,") || !strings.HasSuffix(lines[1], ",5,EUR") {
+		t.Fatalf("unexpected export %d %q", rr.Code, rr.Body.String())
+	}
+}
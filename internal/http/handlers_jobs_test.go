@@ -0,0 +1,101 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-am-realtime-report-ui/internal/export"
+	"go-am-realtime-report-ui/internal/jobs"
+)
+
+func TestJobsRouter(t *testing.T) {
+	m := jobs.NewManager(jobs.NewMemoryStore(), jobs.Config{Workers: 1})
+	release := make(chan struct{})
+	m.Register("report", jobs.Kind{Run: func(ctx context.Context, params json.RawMessage, progress func(float64, string)) (*jobs.Result, error) {
+		<-release
+		table := export.Table{Columns: []export.Column{{Key: "customer_id"}, {Key: "count", Kind: export.KindNumber}}, Rows: [][]any{{"acme", 3}}}
+		return reportJobResult("transfer-report", map[string]any{"data": []string{"acme"}}, nil, table)
+	}})
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+	h := jobsRouter(50, m)
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := serve(http.MethodPost, "/api/v1/jobs", `{"kind":"nope"}`); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "available: report") {
+		t.Fatalf("unknown kind: got %d %s", rr.Code, rr.Body.String())
+	}
+	rr := serve(http.MethodPost, "/api/v1/jobs", `{"kind":"report","params":{}}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("enqueue: got %d %s", rr.Code, rr.Body.String())
+	}
+	var created struct{ Data jobs.Job }
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	id := created.Data.ID
+	if rr.Header().Get("Location") != "/api/v1/jobs/"+id {
+		t.Fatalf("unexpected location %q", rr.Header().Get("Location"))
+	}
+
+	if rr := serve(http.MethodGet, "/api/v1/jobs/"+id+"/result", ""); rr.Code != http.StatusConflict {
+		t.Fatalf("unfinished result: got %d", rr.Code)
+	}
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := m.Get(context.Background(), id)
+		if err == nil && job.State == jobs.StateSucceeded {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job did not succeed: %+v %v", job, err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	rr = serve(http.MethodGet, "/api/v1/jobs/"+id+"/result?format=csv", "")
+	if rr.Code != http.StatusOK || rr.Body.String() != "customer_id,count\nacme,3\n" {
+		t.Fatalf("csv result: got %d %q", rr.Code, rr.Body.String())
+	}
+	if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename="transfer-report.csv"` {
+		t.Fatalf("unexpected disposition %q", cd)
+	}
+	if rr := serve(http.MethodGet, "/api/v1/jobs/"+id+"/result", ""); rr.Code != http.StatusOK || rr.Body.String() != `{"data":["acme"]}` {
+		t.Fatalf("json result: got %d %q", rr.Code, rr.Body.String())
+	}
+	if rr := serve(http.MethodGet, "/api/v1/jobs/"+id+"/result?format=pdf", ""); rr.Code != http.StatusNotAcceptable {
+		t.Fatalf("pdf result: got %d", rr.Code)
+	}
+	if rr := serve(http.MethodPost, "/api/v1/jobs/"+id+"/cancel", ""); rr.Code != http.StatusConflict {
+		t.Fatalf("cancel finished job: got %d", rr.Code)
+	}
+	if rr := serve(http.MethodGet, "/api/v1/jobs?state=succeeded", ""); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), id) {
+		t.Fatalf("list: got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := serve(http.MethodDelete, "/api/v1/jobs/"+id, ""); rr.Code != http.StatusOK {
+		t.Fatalf("delete: got %d", rr.Code)
+	}
+	if rr := serve(http.MethodGet, "/api/v1/jobs/"+id, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("deleted job: got %d", rr.Code)
+	}
+}
+
+func TestRegisterReportJobs_OnlyConfiguredBackends(t *testing.T) {
+	m := jobs.NewManager(jobs.NewMemoryStore(), jobs.Config{})
+	registerReportJobs(m, reportJobDeps{})
+	if kinds := m.Kinds(); len(kinds) != 0 {
+		t.Fatalf("expected no job kinds without backends, got %v", kinds)
+	}
+}
@@ -0,0 +1,21 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+)
+
+func TestFailureKnowledgeRouter_NoSQLiteStore(t *testing.T) {
+	h := failureKnowledgeRouter(50, &mysqlstore.Store{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/troubleshooting/knowledge", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
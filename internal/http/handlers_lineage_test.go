@@ -0,0 +1,162 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+)
+
+func TestLineageHandler_DBDisabled(t *testing.T) {
+	h := lineageHandler(nil, nil, nil, 5)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/lineage/abc-123", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestLineageHandler_NestedPathReturnsNotFound(t *testing.T) {
+	h := lineageHandler(&mysqlstore.Store{}, nil, nil, 5)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/lineage/abc-123/extra", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+// newLineageTestStores extends the shared test schema with the MCP and SS
+// columns and tables the lineage queries read.
+func newLineageTestStores(t *testing.T) testStores {
+	t.Helper()
+	ts := newTestStores(t)
+	ts.exec(t, ts.mcp,
+		`CREATE TABLE SIPs (sipUUID TEXT, currentPath TEXT, status INTEGER, sipType TEXT, aipFilename TEXT, createdTime DATETIME, completed_at DATETIME)`,
+		`CREATE TABLE Tasks (jobuuid TEXT, exitCode INTEGER)`,
+		`ALTER TABLE Jobs ADD COLUMN jobUUID TEXT`,
+		`ALTER TABLE Jobs ADD COLUMN unitType TEXT`,
+		`ALTER TABLE Jobs ADD COLUMN currentStep INTEGER`,
+		`ALTER TABLE Files ADD COLUMN currentLocation TEXT`,
+		`ALTER TABLE Files ADD COLUMN fileGrpUse TEXT`,
+		`ALTER TABLE Files ADD COLUMN fileSize INTEGER`,
+		`ALTER TABLE Files ADD COLUMN enteredSystem DATETIME`,
+	)
+	ts.exec(t, ts.ss,
+		`DROP TABLE locations_package`,
+		`CREATE TABLE locations_package (id INTEGER PRIMARY KEY, uuid TEXT, package_type TEXT, status TEXT, size INTEGER, current_path TEXT, stored_date DATETIME, current_location_id TEXT, origin_pipeline_id TEXT, replicated_package_id TEXT)`,
+		`ALTER TABLE locations_location ADD COLUMN space_id TEXT`,
+		`CREATE TABLE locations_space (uuid TEXT, path TEXT)`,
+		`CREATE TABLE locations_pipeline (uuid TEXT, remote_name TEXT, description TEXT)`,
+		`CREATE TABLE locations_file (package_id INTEGER, stored INTEGER)`,
+		`CREATE TABLE locations_package_related_packages (from_package_id INTEGER, to_package_id INTEGER)`,
+	)
+	return ts
+}
+
+func serveLineage(t *testing.T, h http.Handler, uuid string) mysqlstore.LineageGraph {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/lineage/"+uuid, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("lineage %s: got %d %s", uuid, rr.Code, rr.Body.String())
+	}
+	var body struct {
+		Data mysqlstore.LineageGraph `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return body.Data
+}
+
+func assertEdgesConnected(t *testing.T, graph mysqlstore.LineageGraph) {
+	t.Helper()
+	ids := map[string]bool{}
+	for _, n := range graph.Nodes {
+		ids[n.ID] = true
+	}
+	for _, e := range graph.Edges {
+		if !ids[e.From] || !ids[e.To] {
+			t.Fatalf("edge %+v points outside the node set", e)
+		}
+	}
+}
+
+func TestLineageHandler_UnitCapDropsEdgesToOmittedUnits(t *testing.T) {
+	ts := newLineageTestStores(t)
+	ts.exec(t, ts.mcp,
+		`INSERT INTO Transfers (transferUUID, status) VALUES ('t-1', 2)`,
+		// A file whose transfer row was purged from MCP.
+		`INSERT INTO Files (fileUUID, transferUUID, currentLocation) VALUES ('f-1', 't-gone', '%transferDirectory%objects/a.tif')`,
+	)
+	for i := 0; i < 60; i++ {
+		ts.exec(t, ts.mcp,
+			fmt.Sprintf(`INSERT INTO SIPs (sipUUID, status) VALUES ('sip-%02d', 2)`, i),
+			fmt.Sprintf(`INSERT INTO Files (fileUUID, transferUUID, sipUUID) VALUES ('f-sip-%02d', 't-1', 'sip-%02d')`, i, i),
+		)
+	}
+	h := lineageHandler(ts.store, nil, nil, 5)
+
+	graph := serveLineage(t, h, "t-1")
+	assertEdgesConnected(t, graph)
+	sips := 0
+	for _, n := range graph.Nodes {
+		if n.Type == "sip" {
+			sips++
+		}
+	}
+	if sips != 50 || len(graph.Edges) != 50 {
+		t.Fatalf("expected 50 SIPs and 50 edges under the cap, got %d SIPs and %d edges", sips, len(graph.Edges))
+	}
+
+	graph = serveLineage(t, h, "f-1")
+	assertEdgesConnected(t, graph)
+	if len(graph.Nodes) != 1 || len(graph.Edges) != 0 {
+		t.Fatalf("expected only the file node, got %+v", graph)
+	}
+}
+
+func TestLineageHandler_DIPQueryLooksUpPackagesOfResolvedUnits(t *testing.T) {
+	ts := newLineageTestStores(t)
+	ts.exec(t, ts.mcp,
+		`INSERT INTO Transfers (transferUUID, status) VALUES ('t-1', 2)`,
+		`INSERT INTO SIPs (sipUUID, status) VALUES ('sip-1', 2)`,
+		`INSERT INTO Files (fileUUID, transferUUID, sipUUID) VALUES ('f-1', 't-1', 'sip-1')`,
+	)
+	ts.exec(t, ts.ss,
+		`INSERT INTO locations_package (id, uuid, package_type, status) VALUES (1, 'sip-1', 'AIP', 'UPLOADED'), (2, 'dip-1', 'DIP', 'UPLOADED'), (3, 't-1', 'transfer', 'UPLOADED')`,
+		`INSERT INTO locations_package_related_packages (from_package_id, to_package_id) VALUES (2, 1)`,
+	)
+	h := lineageHandler(ts.store, ts.ssStore, nil, 5)
+
+	graph := serveLineage(t, h, "dip-1")
+	assertEdgesConnected(t, graph)
+	if graph.ResolvedAs != "dip" {
+		t.Fatalf("expected resolved_as dip, got %q", graph.ResolvedAs)
+	}
+	relations := make([]string, 0, len(graph.Edges))
+	for _, e := range graph.Edges {
+		relations = append(relations, e.From+" "+e.Relation+" "+e.To)
+	}
+	got := strings.Join(relations, "\n")
+	for _, want := range []string{
+		"transfer:t-1 ingested_as sip:sip-1",
+		"sip:sip-1 stored_as aip:sip-1",
+		"aip:sip-1 disseminated_as dip:dip-1",
+		"transfer:t-1 backlogged_as transfer_package:t-1",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing edge %q in\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCustomerMappingRulesRouter_DBDisabled(t *testing.T) {
+	h := customerMappingRulesRouter(nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/reports/customer-mapping-rules/preview", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestCustomerMappingRulesRouter_PreviewReplacesSavedRules(t *testing.T) {
+	ts := newTestStores(t)
+	h := customerMappingRulesRouter(ts.store)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := serve(http.MethodPost, "/api/v1/reports/customer-mapping-rules", `{"customer_id":"acme","field":"source_of_acquisition","match_type":"regex","pattern":"(?i)^acme"}`); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "(?:...)") {
+		t.Fatalf("non-portable regex: got %d %s", rr.Code, rr.Body.String())
+	}
+	var ids []int64
+	for _, body := range []string{
+		`{"customer_id":"acme","field":"source_of_acquisition","match_type":"prefix","pattern":"acme-"}`,
+		`{"customer_id":"globex","field":"accession_id","match_type":"glob","pattern":"GX-*","priority":5}`,
+	} {
+		rr := serve(http.MethodPost, "/api/v1/reports/customer-mapping-rules", body)
+		var saved struct {
+			Data struct {
+				ID int64 `json:"id"`
+			} `json:"data"`
+		}
+		if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &saved) != nil {
+			t.Fatalf("save rule: got %d %s", rr.Code, rr.Body.String())
+		}
+		ids = append(ids, saved.Data.ID)
+	}
+
+	if rr := serve(http.MethodPost, "/api/v1/reports/customer-mapping-rules/preview", `{"rules":[{"customer_id":"beta","field":"transfer_name","match_type":"regex","pattern":"(?i)^beta"}]}`); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "rule 0:") {
+		t.Fatalf("preview of a non-portable regex: got %d %s", rr.Code, rr.Body.String())
+	}
+	// Disabling the saved acme rule drops it from the preview; the new beta rule
+	// is evaluated with the saved globex rule.
+	rr := serve(http.MethodPost, "/api/v1/reports/customer-mapping-rules/preview", `{"date_from":"2026-02-01","date_to":"2026-03-01","rules":[`+
+		`{"id":`+strconv.FormatInt(ids[0], 10)+`,"customer_id":"acme","field":"source_of_acquisition","match_type":"prefix","pattern":"acme-","enabled":false},`+
+		`{"customer_id":"beta","field":"transfer_name","match_type":"regex","pattern":"^beta[0-9]+$","priority":10}]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("preview: got %d %s", rr.Code, rr.Body.String())
+	}
+	var preview struct {
+		Meta struct {
+			Scanned int64 `json:"scanned"`
+		} `json:"meta"`
+		Data struct {
+			Rules []struct {
+				ID         int64  `json:"id"`
+				CustomerID string `json:"customer_id"`
+				Candidate  bool   `json:"candidate"`
+			} `json:"rules"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &preview); err != nil {
+		t.Fatal(err)
+	}
+	rules := preview.Data.Rules
+	if preview.Meta.Scanned != 0 || len(rules) != 2 || rules[0].CustomerID != "beta" || !rules[0].Candidate || rules[1].ID != ids[1] || rules[1].Candidate {
+		t.Fatalf("unexpected preview rules %+v", rules)
+	}
+}
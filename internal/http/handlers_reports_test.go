@@ -0,0 +1,484 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+	ssstore "go-am-realtime-report-ui/internal/connectors/ssdb"
+	"go-am-realtime-report-ui/internal/export"
+)
+
+func TestFormatAnalyticsHandler_DBDisabled(t *testing.T) {
+	h := formatAnalyticsHandler(50, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/reports/formats?format=csv", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestWorkerUtilizationHandler_DBDisabled(t *testing.T) {
+	h := workerUtilizationHandler(4, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/charts/worker-utilization", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestBacklogForecastHandler_DBDisabled(t *testing.T) {
+	h := backlogForecastHandler(nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/reports/forecast?days=30", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestParseForecastDays(t *testing.T) {
+	if v, err := parseForecastDays("", 30, 365); err != nil || v != 30 {
+		t.Fatalf("expected default 30, got %d (%v)", v, err)
+	}
+	if v, err := parseForecastDays("14", 30, 365); err != nil || v != 14 {
+		t.Fatalf("expected 14, got %d (%v)", v, err)
+	}
+	for _, raw := range []string{"0", "366", "abc"} {
+		if _, err := parseForecastDays(raw, 30, 365); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestSLAComplianceHandler_DBDisabled(t *testing.T) {
+	h := slaComplianceHandler("default", 50, 1, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/reports/sla?month=2026-02", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestSLAComplianceHandler_InvalidPeriod(t *testing.T) {
+	h := slaComplianceHandler("default", 50, 1, &mysqlstore.Store{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/reports/sla?period=custom&date_from=2026-02-01", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "date_to") {
+		t.Fatalf("expected 400 naming date_to, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestSLAPoliciesRouter_DBDisabled(t *testing.T) {
+	h := slaPoliciesRouter(50, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/reports/sla-policies", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestSaveSLAPolicyRequest_TargetHours(t *testing.T) {
+	p := saveSLAPolicyRequest{CustomerID: "acme", TargetHours: 48, TargetPercent: 95}.toPolicy(3)
+	if p.ID != 3 || p.TargetSeconds != 48*3600 || p.TargetPercent != 95 {
+		t.Fatalf("unexpected policy %+v", p)
+	}
+}
+
+func TestParseReportPeriod(t *testing.T) {
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	p, err := parseReportPeriod(url.Values{"month": {"2026-02"}}, 1, now)
+	if err != nil || p.Kind != "month" || p.Label != "2026-02" {
+		t.Fatalf("legacy month: got %+v (%v)", p, err)
+	}
+
+	p, err = parseReportPeriod(url.Values{"period": {"fiscal_year"}}, 10, now)
+	if err != nil || p.Label != "FY2026" {
+		t.Fatalf("fiscal year: got %+v (%v)", p, err)
+	}
+
+	p, err = parseReportPeriod(url.Values{
+		"period":    {"custom"},
+		"date_from": {"2026-03-01"},
+		"date_to":   {"2026-03-01"},
+		"tz":        {"America/New_York"},
+	}, 1, now)
+	if err != nil || p.Bucket != "hour" || p.End.Sub(p.Start) != 24*time.Hour || p.Start.Hour() != 5 {
+		t.Fatalf("custom local day: got %+v (%v)", p, err)
+	}
+
+	for _, q := range []url.Values{
+		{"tz": {"Mars/Olympus"}},
+		{"period": {"custom"}, "date_from": {"2026-03-01"}},
+		{"period": {"decade"}},
+		{"bucket": {"minute"}},
+	} {
+		if _, err := parseReportPeriod(q, 1, now); err == nil {
+			t.Fatalf("expected error for %v", q)
+		}
+	}
+}
+
+func TestLegacyTimeseries_DropsEmptyBucketsWithoutPeriod(t *testing.T) {
+	build := func() *mysqlstore.MonthlyReport {
+		return &mysqlstore.MonthlyReport{Timeseries: []mysqlstore.DailyReportPoint{
+			{Date: "2026-02-01"}, {Date: "2026-02-02", Success: 3}, {Date: "2026-02-03"}, {Date: "2026-02-04", Failed: 1},
+		}}
+	}
+
+	report := build()
+	legacyTimeseries(url.Values{"month": {"2026-02"}}, report)
+	if len(report.Timeseries) != 2 || report.Timeseries[0].Date != "2026-02-02" || report.Timeseries[1].Date != "2026-02-04" {
+		t.Fatalf("legacy month: unexpected timeseries %+v", report.Timeseries)
+	}
+	for _, q := range []url.Values{{"period": {"month"}, "month": {"2026-02"}}, {"month": {"2026-02"}, "bucket": {"day"}}} {
+		report := build()
+		legacyTimeseries(q, report)
+		if len(report.Timeseries) != 4 {
+			t.Fatalf("%v: expected zero-filled timeseries, got %+v", q, report.Timeseries)
+		}
+	}
+}
+
+func TestParseComparisonOptions(t *testing.T) {
+	opts, err := parseComparisonOptions("previous, year", "true")
+	if err != nil || !opts.Previous || !opts.YearAgo || opts.Sparkline != 12 {
+		t.Fatalf("unexpected options %+v (%v)", opts, err)
+	}
+	opts, err = parseComparisonOptions("", "6")
+	if err != nil || opts.Previous || opts.Sparkline != 6 {
+		t.Fatalf("unexpected options %+v (%v)", opts, err)
+	}
+	if _, err := parseComparisonOptions("lastweek", ""); err == nil {
+		t.Fatalf("expected error for unknown compare value")
+	}
+	if _, err := parseComparisonOptions("", "100"); err == nil {
+		t.Fatalf("expected error for oversized sparkline")
+	}
+}
+
+func TestParseMappingWindow(t *testing.T) {
+	from, to, err := parseMappingWindow("2026-01-01", "")
+	if err != nil || to != nil || from == nil || !from.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected window %v %v (%v)", from, to, err)
+	}
+	from, to, err = parseMappingWindow("", "")
+	if err != nil || from != nil || to != nil {
+		t.Fatalf("expected open window, got %v %v (%v)", from, to, err)
+	}
+	if _, _, err := parseMappingWindow("2026-02-01", "2026-01-01"); err == nil {
+		t.Fatalf("expected error for effective_to before effective_from")
+	}
+	if _, _, err := parseMappingWindow("", "soon"); err == nil {
+		t.Fatalf("expected error for invalid effective_to")
+	}
+}
+
+func TestReportRoutesRouter_DBDisabled(t *testing.T) {
+	h := reportRoutesRouter(50, nil, nil, nil, "aipfiles", 500)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/reports/query/options?scope=sip", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestRunScopedReportQuery_RejectsTransferOnlyOptions(t *testing.T) {
+	cases := []runReportRequest{
+		{GroupBy: []string{"customer_id"}},
+		{Status: "failed"},
+		{Sort: []string{"files_total"}},
+		{Filter: "files_total > 1"},
+	}
+	for _, body := range cases {
+		_, err := runScopedReportQuery(context.Background(), nil, reportScopeDeps{}, "aip", body, time.Now().Add(-time.Hour), time.Now(), 50, 0)
+
+		var qe *reportError
+		if !errors.As(err, &qe) || qe.status != http.StatusBadRequest || qe.backend {
+			t.Errorf("request %+v: expected a bad request error, got %v", body, err)
+		}
+	}
+}
+
+func TestNegotiateExportFormat(t *testing.T) {
+	cases := []struct {
+		target string
+		accept string
+		want   string
+	}{
+		{"/api/v1/reports/monthly", "", "json"},
+		{"/api/v1/reports/monthly", "text/html,application/xhtml+xml,*/*;q=0.8", "json"},
+		{"/api/v1/reports/monthly", "application/pdf", "pdf"},
+		{"/api/v1/reports/monthly", "application/json, text/csv", "json"},
+		{"/api/v1/reports/monthly", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet;q=0.9", "xlsx"},
+		{"/api/v1/reports/monthly?format=CSV", "application/pdf", "csv"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		got, err := negotiateExportFormat(req)
+		if err != nil || got != tc.want {
+			t.Errorf("%s with Accept %q: got %q, %v; want %q", tc.target, tc.accept, got, err, tc.want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/transfers/failed?format=pdf", nil)
+	rr := httptest.NewRecorder()
+	if _, ok := tableExportFormat(rr, req); ok || rr.Code != http.StatusNotAcceptable {
+		t.Fatalf("expected 406 for tabular pdf, got %d", rr.Code)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/transfers/failed?format=docx", nil)
+	rr = httptest.NewRecorder()
+	if _, ok := tableExportFormat(rr, req); ok || rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown format, got %d", rr.Code)
+	}
+}
+
+func TestMonthlyReportExports(t *testing.T) {
+	period := mysqlstore.MonthPeriod(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), time.UTC)
+	report := &mysqlstore.MonthlyReport{
+		Month:      "2026-02",
+		Period:     period,
+		CustomerID: "acme",
+		KPIs: map[string]any{
+			"transfers_total":     int64(12),
+			"avg_processing_sec":  int64(3725),
+			"sla_on_time_percent": 91.66,
+			"sla_policy":          "transfer_start->transfer_end",
+			"sla_compliant":       true,
+		},
+		SLA: &mysqlstore.SLACompliance{Measured: 12, Breached: 1, Breaches: []mysqlstore.SLABreach{{TransferUUID: "t-1", TransferName: "late", OverBySeconds: 90}}},
+	}
+	for d := 1; d <= 28; d++ {
+		report.Timeseries = append(report.Timeseries, mysqlstore.DailyReportPoint{Date: time.Date(2026, 2, d, 0, 0, 0, 0, time.UTC).Format("2006-01-02"), Success: int64(d % 5), Failed: int64(d % 2)})
+	}
+	delta := 20.0
+	comparison := &mysqlstore.ReportComparison{
+		Previous: &mysqlstore.ReportPeriod{Label: "2026-01"},
+		KPIs: map[string]mysqlstore.KPIComparison{
+			"transfers_total": {
+				Value:     12,
+				Previous:  &mysqlstore.KPIDelta{Period: "2026-01", Value: 10, DeltaPercent: &delta},
+				Sparkline: []mysqlstore.SparklinePoint{{Period: "2026-01", Value: 10}, {Period: "2026-02", Value: 12}},
+			},
+		},
+	}
+
+	tables := monthlyReportExportTables(report, comparison, []mysqlstore.DurationChartPoint{{Date: "2026-02-01", Count: 2, AvgSeconds: 60, P95Seconds: 120}})
+	var titles []string
+	for _, tbl := range tables {
+		titles = append(titles, tbl.Title)
+	}
+	if want := []string{"KPIs", "Transfers per day", "Processing times", "SLA breaches", "Trend"}; !reflect.DeepEqual(titles, want) {
+		t.Fatalf("unexpected tables %v", titles)
+	}
+	kpis := tables[0]
+	if kpis.Rows[0][0] != "transfers_total" || kpis.Rows[0][3] != 10.0 || kpis.Rows[0][4] != &delta {
+		t.Fatalf("unexpected KPI row %v", kpis.Rows[0])
+	}
+	if trend := tables[4]; len(trend.Rows) != 2 || trend.Rows[1][1] != 12.0 {
+		t.Fatalf("unexpected trend %v", trend.Rows)
+	}
+
+	body, err := renderMonthlyReportPDF(export.Brand{Name: "Acme Archive"}, report, comparison, nil, time.Now())
+	if err != nil || !bytes.HasPrefix(body, []byte("%PDF-")) {
+		t.Fatalf("unexpected pdf render: %v", err)
+	}
+	if got := monthlyKPIText("avg_processing_sec", int64(3725)); got != "1h 02m" {
+		t.Fatalf("unexpected duration text %q", got)
+	}
+	if got := monthlyKPIText("sla_on_time_percent", 91.66); got != "91.7%" {
+		t.Fatalf("unexpected percent text %q", got)
+	}
+}
+
+func TestTableExports_KeepCSVHeaders(t *testing.T) {
+	run := &mysqlstore.BillingRun{
+		ID:          7,
+		PeriodLabel: "2026-02",
+		PeriodStart: "2026-02-01",
+		PeriodEnd:   "2026-03-01",
+		Lines: []mysqlstore.BillingLineItem{{
+			CustomerID: "acme", PlanID: 2, PlanName: "Standard", Item: "storage_gb",
+			Description: "Storage, first tier", Quantity: 1.5, Unit: "GB", UnitPrice: 0.2, Amount: 0.3, Currency: "EUR",
+		}},
+	}
+	rr := httptest.NewRecorder()
+	writeExport(rr, export.FormatCSV, "billing-2026-02-7", billingLinesExportTable(run))
+	if got := rr.Header().Get("Content-Disposition"); got != `attachment; filename="billing-2026-02-7.csv"` {
+		t.Fatalf("unexpected content disposition %q", got)
+	}
+	want := "run_id,period,period_start,period_end,customer_id,plan_id,plan_name,item,description,quantity,unit,unit_price,amount,currency\n" +
+		"7,2026-02,2026-02-01,2026-03-01,acme,2,Standard,storage_gb,\"Storage, first tier\",1.5,GB,0.2,0.3,EUR\n"
+	if got := rr.Body.String(); got != want {
+		t.Fatalf("unexpected csv body %q", got)
+	}
+
+	growth := 12.5
+	storage := storageExportTable(&mysqlstore.StorageConsumption{Customers: []mysqlstore.CustomerStorage{{
+		CustomerID:   "acme",
+		StorageUsage: mysqlstore.StorageUsage{BytesTotal: 300, GrowthPercent: &growth},
+		Locations:    []mysqlstore.LocationStorage{{LocationUUID: "loc-1", Location: "Primary", StorageUsage: mysqlstore.StorageUsage{BytesTotal: 300}}},
+	}}})
+	if len(storage.Rows) != 2 || storage.Rows[0][1] != "" || storage.Rows[1][2] != "Primary" || storage.Rows[1][9] != (*float64)(nil) {
+		t.Fatalf("unexpected storage rows %v", storage.Rows)
+	}
+	rr = httptest.NewRecorder()
+	writeExport(rr, export.FormatXLSX, "storage", storage)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != export.ContentType(export.FormatXLSX) {
+		t.Fatalf("unexpected xlsx response %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+}
+
+func TestStreamReportQuery_RejectsUnsupportedRequests(t *testing.T) {
+	cases := []struct {
+		target string
+		body   string
+	}{
+		{"/api/v1/reports/query/stream?format=xlsx", `{}`},
+		{"/api/v1/reports/query/stream", `{"scope":"sip"}`},
+		{"/api/v1/reports/query/stream", `{"group_by":["customer"]}`},
+		{"/api/v1/reports/query/stream", `{"sort":["count desc"]}`},
+		{"/api/v1/reports/query/stream", `{"filter":"size_mb >"}`},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, tc.target, strings.NewReader(tc.body))
+		rr := httptest.NewRecorder()
+		streamReportQuery(rr, req, nil)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: expected status %d, got %d", tc.target, tc.body, http.StatusBadRequest, rr.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/reports/query/stream", nil)
+	req.Header.Set("Accept", "text/csv")
+	if format, err := negotiateStreamFormat(req); err != nil || format != "csv" {
+		t.Fatalf("unexpected stream format %q, %v", format, err)
+	}
+	req.Header.Set("Accept", "*/*")
+	if format, err := negotiateStreamFormat(req); err != nil || format != "ndjson" {
+		t.Fatalf("unexpected default stream format %q, %v", format, err)
+	}
+}
+
+func TestStreamReportQuery_BackendErrorBeforeFirstRow(t *testing.T) {
+	ts := newTestStores(t)
+	// Break the MCP schema so the query fails before any row is written.
+	ts.exec(t, ts.mcp, `DROP TABLE Transfers`)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/reports/query/stream", strings.NewReader(`{"date_from":"2026-02-01","date_to":"2026-03-01"}`))
+	streamReportQuery(rr, req, ts.store)
+
+	if rr.Code != http.StatusInternalServerError || strings.TrimSpace(rr.Body.String()) != `{"error":"failed to run report"}` {
+		t.Fatalf("expected a generic 500, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestReportTemplatesRouter_DBDisabled(t *testing.T) {
+	h := reportTemplatesRouter(50, 1, nil, reportScopeDeps{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/reports/templates/1/versions/2", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+// testStores is a report store backed by an app SQLite store and an MCP database,
+// and a Storage Service store. Both databases are SQLite with the columns the app
+// reads; times computed in SQL do not scan from SQLite, so only queries over
+// stored time columns work.
+type testStores struct {
+	cm      *customermap.Store
+	store   *mysqlstore.Store
+	ssStore *ssstore.Store
+	mcp     *sql.DB
+	ss      *sql.DB
+}
+
+func newTestStores(t *testing.T) testStores {
+	t.Helper()
+	dir := t.TempDir()
+	cm, err := customermap.NewSQLiteStore(filepath.Join(dir, "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cm.Close() })
+	open := func(name string, schema ...string) *sql.DB {
+		db, err := sql.Open("sqlite", filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+		for _, stmt := range schema {
+			if _, err := db.Exec(stmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return db
+	}
+	mcp := open("mcp.db",
+		`CREATE TABLE Transfers (transferUUID TEXT, currentLocation TEXT, sourceOfAcquisition TEXT, accessionID TEXT, status INTEGER, completed_at DATETIME)`,
+		`CREATE TABLE Jobs (SIPUUID TEXT, createdTime DATETIME)`,
+		`CREATE TABLE Files (fileUUID TEXT, sipUUID TEXT, transferUUID TEXT)`,
+	)
+	ss := open("ss.db",
+		`CREATE TABLE locations_package (uuid TEXT, package_type TEXT, size INTEGER, status TEXT, stored_date DATETIME, replicated_package_id TEXT, current_location_id TEXT)`,
+		`CREATE TABLE locations_location (uuid TEXT, description TEXT, relative_path TEXT, purpose TEXT)`,
+	)
+	return testStores{
+		cm:      cm,
+		store:   mysqlstore.NewStoreWithDB(mcp, cm, time.Second),
+		ssStore: ssstore.NewStoreWithDB(ss, time.Second),
+		mcp:     mcp,
+		ss:      ss,
+	}
+}
+
+// exec runs seed statements, failing the test on the first error.
+func (ts testStores) exec(t *testing.T, db *sql.DB, stmts ...string) {
+	t.Helper()
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+}
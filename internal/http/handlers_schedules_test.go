@@ -0,0 +1,107 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-am-realtime-report-ui/internal/jobs"
+	"go-am-realtime-report-ui/internal/schedule"
+)
+
+func TestReportSchedulesRouter_DBDisabled(t *testing.T) {
+	h := reportSchedulesRouter(50, nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/reports/schedules/1/runs", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestReportSchedulesRouter_RunNowQueuesTemplateJob(t *testing.T) {
+	ts := newTestStores(t)
+	tpl, _, err := ts.store.UpsertReportTemplate(context.Background(), "Monthly transfers", "", "transfer", map[string]any{"status": "completed"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := jobs.NewManager(jobs.NewMemoryStore(), jobs.Config{Workers: 1})
+	queued := make(chan map[string]any, 1)
+	m.Register(schedule.JobKind, jobs.Kind{Run: func(ctx context.Context, raw json.RawMessage, progress func(float64, string)) (*jobs.Result, error) {
+		var params map[string]any
+		_ = json.Unmarshal(raw, &params)
+		queued <- params
+		return &jobs.Result{Name: "monthly-transfers", Data: raw}, nil
+	}})
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+	h := reportSchedulesRouter(50, ts.store, schedule.New(ts.cm, m, schedule.Config{Delivery: schedule.DeliveryConfig{Dir: t.TempDir()}}))
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := serve(http.MethodPost, "/api/v1/reports/schedules", `{"template_id":999,"name":"x","cron":"0 6 1 * *","delivery":"directory"}`); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "not found") {
+		t.Fatalf("unknown template: got %d %s", rr.Code, rr.Body.String())
+	}
+	// Run now works on disabled schedules too.
+	rr := serve(http.MethodPost, "/api/v1/reports/schedules", `{"template_id":`+strconv.FormatInt(tpl.ID, 10)+`,"name":"Monthly ops","cron":"0 6 1 * *","range":"previous_month","delivery":"directory","target":"ops","enabled":false}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("save schedule: got %d %s", rr.Code, rr.Body.String())
+	}
+	var saved struct {
+		Data struct {
+			ID int64 `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &saved); err != nil {
+		t.Fatal(err)
+	}
+	schedulePath := "/api/v1/reports/schedules/" + strconv.FormatInt(saved.Data.ID, 10)
+
+	if rr := serve(http.MethodGet, schedulePath+"/run", ""); rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET run: expected 405, got %d", rr.Code)
+	}
+	rr = serve(http.MethodPost, schedulePath+"/run", "")
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("run now: got %d %s", rr.Code, rr.Body.String())
+	}
+	var run struct {
+		Data struct {
+			JobID    string `json:"job_id"`
+			Trigger  string `json:"trigger"`
+			DateFrom string `json:"date_from"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &run); err != nil {
+		t.Fatal(err)
+	}
+	if run.Data.JobID == "" || run.Data.Trigger != schedule.TriggerManual || rr.Header().Get("Location") != "/api/v1/jobs/"+run.Data.JobID {
+		t.Fatalf("unexpected run %+v (Location %q)", run.Data, rr.Header().Get("Location"))
+	}
+	wantFrom := time.Date(time.Now().UTC().Year(), time.Now().UTC().Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0).Format(time.RFC3339)
+	select {
+	case params := <-queued:
+		if params["template_id"] != float64(tpl.ID) || params["date_from"] != wantFrom {
+			t.Fatalf("unexpected job params %v", params)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the run's job did not start")
+	}
+
+	rr = serve(http.MethodGet, schedulePath+"/runs", "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"job_id":"`+run.Data.JobID+`"`) {
+		t.Fatalf("runs: got %d %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+)
+
+func TestSIPDetailRouter_DBDisabled(t *testing.T) {
+	h := sipDetailRouter(50, nil, nil, nil, 5)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sips/abc-123/details", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestSIPDetailRouter_UnknownActionReturnsNotFound(t *testing.T) {
+	h := sipDetailRouter(50, &mysqlstore.Store{}, nil, nil, 5)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sips/abc-123/unknown", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestNormalizeMetricPath_SIPs(t *testing.T) {
+	cases := map[string]string{
+		"/api/v1/sips/running":               "/api/v1/sips/running",
+		"/api/v1/sips/abc-123/details":       "/api/v1/sips/{uuid}/details",
+		"/api/v1/sips/abc-123/performance":   "/api/v1/sips/{uuid}/performance",
+		"/api/v1/sips/abc-123":               "/api/v1/sips/{uuid}",
+		"/api/v1/sips/abc-123/def-456":       "/api/v1/sips/{uuid}",
+		"/api/v1/transfers/abc-123/timeline": "/api/v1/transfers/{uuid}/timeline",
+	}
+	for path, want := range cases {
+		if got := normalizeMetricPath(path); got != want {
+			t.Fatalf("normalizeMetricPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+	"go-am-realtime-report-ui/internal/export"
+)
+
+func TestReportSnapshotsRouter_DBDisabled(t *testing.T) {
+	h := reportSnapshotsRouter("default", 50, 1, "dev", nil, export.Brand{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/reports/snapshots/1/diff", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestReportSnapshotsRouter_OneOfficialSnapshotPerPeriod(t *testing.T) {
+	ts := newTestStores(t)
+	ids := make([]string, 0, 2)
+	for _, transfers := range []int{10, 12} {
+		item, _, err := ts.cm.InsertReportSnapshot(context.Background(), customermap.ReportSnapshot{
+			CustomerID:  "acme",
+			PeriodKind:  "month",
+			PeriodLabel: "2026-02",
+			PeriodStart: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+			PeriodEnd:   time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			Timezone:    "UTC",
+			ReportJSON:  `{"month":"2026-02","customer_id":"acme","kpis":{"transfers_total":` + strconv.Itoa(transfers) + `},"timeseries":[]}`,
+			InputsJSON:  `{}`,
+		}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, strconv.FormatInt(item.ID, 10))
+	}
+	h := reportSnapshotsRouter("default", 50, 1, "dev", ts.store, export.Brand{})
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, target, nil))
+		return rr
+	}
+	official := func() []string {
+		t.Helper()
+		rr := serve(http.MethodGet, "/api/v1/reports/snapshots?customer_id=acme&official=true")
+		var body struct {
+			Data []struct {
+				ID int64 `json:"id"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		out := []string{}
+		for _, it := range body.Data {
+			out = append(out, strconv.FormatInt(it.ID, 10))
+		}
+		return out
+	}
+
+	for _, id := range ids {
+		if rr := serve(http.MethodPut, "/api/v1/reports/snapshots/"+id+"/official"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"official":true`) {
+			t.Fatalf("mark %s official: got %d %s", id, rr.Code, rr.Body.String())
+		}
+	}
+	if got := official(); len(got) != 1 || got[0] != ids[1] {
+		t.Fatalf("expected only the last marked snapshot to be official, got %v", got)
+	}
+	if rr := serve(http.MethodDelete, "/api/v1/reports/snapshots/"+ids[1]+"/official"); rr.Code != http.StatusOK {
+		t.Fatalf("unmark: got %d %s", rr.Code, rr.Body.String())
+	}
+	if got := official(); len(got) != 0 {
+		t.Fatalf("expected no official snapshot, got %v", got)
+	}
+	if rr := serve(http.MethodPut, "/api/v1/reports/snapshots/999/official"); rr.Code != http.StatusNotFound {
+		t.Fatalf("unknown snapshot: expected 404, got %d", rr.Code)
+	}
+
+	rr := serve(http.MethodGet, "/api/v1/reports/snapshots/"+ids[0]+"?format=csv")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "transfers_total,Transfers,10\n") {
+		t.Fatalf("frozen export: got %d %q", rr.Code, rr.Body.String())
+	}
+}
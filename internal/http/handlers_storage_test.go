@@ -0,0 +1,74 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStorageReportHandler_DBDisabled(t *testing.T) {
+	h := storageReportHandler("default", 1, nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/reports/storage?month=2026-02", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestStorageReportHandler_AttributesPackagesThroughTheirSIP(t *testing.T) {
+	ts := newTestStores(t)
+	if _, err := ts.store.AddCustomerMapping(context.Background(), "acme", "acme-src", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	ts.exec(t, ts.mcp,
+		`INSERT INTO Transfers (transferUUID, sourceOfAcquisition) VALUES ('t-1', 'acme-src')`,
+		`INSERT INTO Files (fileUUID, sipUUID, transferUUID) VALUES ('f-1', 'sip-1', 't-1'), ('f-2', 'sip-1', 't-1')`,
+	)
+	// The AIP reuses the SIP UUID; its replica points at the AIP.
+	ts.exec(t, ts.ss,
+		`INSERT INTO locations_location (uuid, description) VALUES ('loc-1', 'Primary'), ('loc-2', 'Replica')`,
+		`INSERT INTO locations_package VALUES ('sip-1', 'AIP', 100, 'UPLOADED', '2026-02-10 12:00:00', NULL, 'loc-1')`,
+		`INSERT INTO locations_package VALUES ('rep-1', 'AIP', 100, 'UPLOADED', '2026-02-11 12:00:00', 'sip-1', 'loc-2')`,
+		`INSERT INTO locations_package VALUES ('gone', 'AIP', 999, 'DELETED', '2026-02-12 12:00:00', NULL, 'loc-1')`,
+	)
+	h := storageReportHandler("default", 1, ts.store, ts.ssStore)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/reports/storage?month=2026-02&customer_id=acme", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var body struct {
+		Data struct {
+			Customers []struct {
+				CustomerID  string `json:"customer_id"`
+				BytesPeriod int64  `json:"bytes_period"`
+				Locations   []struct {
+					Location string `json:"location"`
+				} `json:"locations"`
+			} `json:"customers"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if c := body.Data.Customers; len(c) != 1 || c[0].CustomerID != "acme" || c[0].BytesPeriod != 200 || len(c[0].Locations) != 2 {
+		t.Fatalf("unexpected customers %+v", c)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/reports/storage?month=2026-02&customer_id=acme&format=csv", nil))
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if rr.Code != http.StatusOK || len(lines) != 4 || !strings.HasPrefix(lines[0], "customer_id,location_uuid,location,") || !strings.HasPrefix(lines[1], "acme,,,2,200,") {
+		t.Fatalf("unexpected csv export %d %q", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Disposition"); got != `attachment; filename="storage-2026-02-acme.csv"` {
+		t.Fatalf("unexpected content disposition %q", got)
+	}
+}
@@ -64,3 +64,15 @@ func TestTransferDetailRouter_InvalidPathReturnsNotFound(t *testing.T) {
 		t.Fatalf("expected status %d, got %d", http.StatusNotFound, rr.Code)
 	}
 }
+
+func TestTransferDetailRouter_PerformanceIsSIPOnly(t *testing.T) {
+	h := transferDetailRouter(50, &mysqlstore.Store{}, nil, nil, 5)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/transfers/abc-123/performance", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
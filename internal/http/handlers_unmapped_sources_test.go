@@ -0,0 +1,69 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUnmappedSourcesRouter_DBDisabled(t *testing.T) {
+	h := unmappedSourcesRouter(50, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/reports/unmapped-sources/assign", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestUnmappedSourcesRouter_AssignMapsSourceOnce(t *testing.T) {
+	ts := newTestStores(t)
+	h := unmappedSourcesRouter(50, ts.store)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := serve(http.MethodPost, "/api/v1/reports/unmapped-sources/assign", `{"source_of_acquisition":"acme-src"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("missing customer: expected 400, got %d", rr.Code)
+	}
+	if rr := serve(http.MethodPost, "/api/v1/reports/unmapped-sources/assign", `{"source_of_acquisition":"acme-src","customer_id":"acme","effective_to":"bad"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("invalid window: expected 400, got %d", rr.Code)
+	}
+
+	assign := func() (bool, []string) {
+		t.Helper()
+		rr := serve(http.MethodPost, "/api/v1/reports/unmapped-sources/assign", `{"source_of_acquisition":" acme-src ","customer_id":"acme","effective_from":"2026-01-01"}`)
+		var body struct {
+			Meta struct {
+				Assigned bool `json:"assigned"`
+			} `json:"meta"`
+			Data struct {
+				Sources []string `json:"sources"`
+			} `json:"data"`
+		}
+		if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &body) != nil {
+			t.Fatalf("assign: got %d %s", rr.Code, rr.Body.String())
+		}
+		return body.Meta.Assigned, body.Data.Sources
+	}
+	if assigned, sources := assign(); !assigned || len(sources) != 1 || sources[0] != "acme-src" {
+		t.Fatalf("first assignment: assigned=%v mappings=%v", assigned, sources)
+	}
+	if assigned, sources := assign(); assigned || len(sources) != 1 {
+		t.Fatalf("repeated assignment: assigned=%v mappings=%v", assigned, sources)
+	}
+
+	rr := serve(http.MethodGet, "/api/v1/reports/unmapped-sources?date_from=2026-02-01&date_to=2026-03-01", "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"count":0`) {
+		t.Fatalf("list: got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := serve(http.MethodGet, "/api/v1/reports/unmapped-sources/assign", ""); rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET assign: expected 405, got %d", rr.Code)
+	}
+}
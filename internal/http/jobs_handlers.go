@@ -0,0 +1,539 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	esstore "go-am-realtime-report-ui/internal/connectors/es"
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+	"go-am-realtime-report-ui/internal/dbctx"
+	"go-am-realtime-report-ui/internal/export"
+	"go-am-realtime-report-ui/internal/jobs"
+)
+
+type enqueueJobRequest struct {
+	Kind   string          `json:"kind"`
+	Params json.RawMessage `json:"params"`
+}
+
+// reportTemplateJobParams runs a template with parameter values resolved at At
+// (RFC3339, default the time the job runs) in Timezone. Version 0 is the current
+// version.
+type reportTemplateJobParams struct {
+	TemplateID int64          `json:"template_id"`
+	Version    int            `json:"version"`
+	Params     map[string]any `json:"params"`
+	At         string         `json:"at"`
+	Timezone   string         `json:"timezone"`
+	DateFrom   string         `json:"date_from"`
+	DateTo     string         `json:"date_to"`
+	CustomerID string         `json:"customer_id"`
+}
+
+type aipStatsJobParams struct {
+	AIPUUID  string `json:"aip_uuid"`
+	PageSize int    `json:"page_size"`
+}
+
+// reportJobDeps are the backends and settings report jobs run with.
+type reportJobDeps struct {
+	store             *mysqlstore.Store
+	scope             reportScopeDeps
+	brand             export.Brand
+	defaultCustomerID string
+	fiscalStartMonth  int
+	defaultLimit      int
+	maxRows           int
+	// queryTimeout replaces the regular database query timeout inside jobs.
+	queryTimeout time.Duration
+}
+
+// registerReportJobs registers the job kinds whose backends are configured.
+func registerReportJobs(m *jobs.Manager, deps reportJobDeps) {
+	if deps.store != nil {
+		m.Register("report_query", jobs.Kind{
+			Description: "ad-hoc report, params as for POST /api/v1/reports/query; limit may go up to APP_JOB_MAX_ROWS",
+			Validate:    validateReportQueryJob,
+			Run:         runReportQueryJob(deps),
+		})
+		m.Register("monthly_report", jobs.Kind{
+			Description: "monthly report with PDF, params are the /api/v1/reports/monthly query parameters as strings",
+			Validate:    validatePeriodJob(deps.fiscalStartMonth, true),
+			Run:         runMonthlyReportJob(deps),
+		})
+	}
+	if deps.store.HasTemplateStore() {
+		m.Register("report_template", jobs.Kind{
+			Description: "saved report template, params template_id and optional version, params, at, timezone and date_from, date_to and customer_id overrides",
+			Validate:    validateReportTemplateJob,
+			Run:         runReportTemplateJob(deps),
+		})
+		if deps.scope.ssStore != nil {
+			m.Register("billing_run", jobs.Kind{
+				Description: "billing run draft, params are the period parameters of POST /api/v1/reports/billing/runs as strings",
+				Validate:    validatePeriodJob(deps.fiscalStartMonth, false),
+				Run:         runBillingJob(deps),
+			})
+		}
+	}
+	if deps.scope.esClient != nil && deps.scope.esClient.Enabled() {
+		m.Register("aip_stats", jobs.Kind{
+			Description: "Elasticsearch statistics of one AIP, params aip_uuid and optional page_size",
+			Validate:    validateAIPStatsJob,
+			Run:         runAIPStatsJob(deps),
+		})
+	}
+}
+
+func validateReportQueryJob(params json.RawMessage) error {
+	var req runReportRequest
+	if err := json.Unmarshal(params, &req); err != nil {
+		return errors.New("invalid params: " + err.Error())
+	}
+	if _, _, err := parseReportDateRange(req.DateFrom, req.DateTo); err != nil {
+		return err
+	}
+	_, err := mysqlstore.NormalizeReportScope(req.Scope)
+	return err
+}
+
+func runReportQueryJob(deps reportJobDeps) jobs.Runner {
+	return func(ctx context.Context, params json.RawMessage, progress func(float64, string)) (*jobs.Result, error) {
+		var req runReportRequest
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, jobs.Permanent(err)
+		}
+		return runReportQueryJobRequest(ctx, deps, req, progress)
+	}
+}
+
+func runReportQueryJobRequest(ctx context.Context, deps reportJobDeps, req runReportRequest, progress func(float64, string)) (*jobs.Result, error) {
+	progress(0.1, "running query")
+	result, err := runReportQuery(dbctx.WithQueryTimeout(ctx, deps.queryTimeout), deps.store, deps.scope, req, deps.defaultLimit, deps.maxRows)
+	if err != nil {
+		return nil, reportJobError(err)
+	}
+	return reportJobResult(result.filename, map[string]any{"meta": result.meta, "data": result.rows}, nil, result.table)
+}
+
+func validateReportTemplateJob(params json.RawMessage) error {
+	var p reportTemplateJobParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return errors.New("invalid params: " + err.Error())
+	}
+	if p.TemplateID <= 0 {
+		return errors.New("template_id is required")
+	}
+	if p.Version < 0 {
+		return errors.New("version must not be negative")
+	}
+	if p.At != "" {
+		if _, err := time.Parse(time.RFC3339, p.At); err != nil {
+			return errors.New("invalid at, expected RFC3339")
+		}
+	}
+	if p.Timezone != "" {
+		if _, err := time.LoadLocation(p.Timezone); err != nil {
+			return errors.New("invalid timezone, expected an IANA timezone such as Europe/Berlin")
+		}
+	}
+	return nil
+}
+
+// runReportTemplateJob runs a saved template; the date range and customer given in
+// the params override the template's after its parameters are resolved.
+func runReportTemplateJob(deps reportJobDeps) jobs.Runner {
+	return func(ctx context.Context, params json.RawMessage, progress func(float64, string)) (*jobs.Result, error) {
+		var p reportTemplateJobParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jobs.Permanent(err)
+		}
+		at := time.Now()
+		if p.At != "" {
+			parsed, err := time.Parse(time.RFC3339, p.At)
+			if err != nil {
+				return nil, jobs.Permanent(fmt.Errorf("invalid at, expected RFC3339: %v", err))
+			}
+			at = parsed
+		}
+		req, _, _, err := resolveTemplateRequest(ctx, deps.store, p.TemplateID, p.Version, p.Params, at, p.Timezone, deps.fiscalStartMonth)
+		if err != nil {
+			return nil, reportJobError(err)
+		}
+		if p.DateFrom != "" {
+			req.DateFrom = p.DateFrom
+		}
+		if p.DateTo != "" {
+			req.DateTo = p.DateTo
+		}
+		if p.CustomerID != "" {
+			req.CustomerID = p.CustomerID
+		}
+		return runReportQueryJobRequest(ctx, deps, req, progress)
+	}
+}
+
+// periodJobParams turns string params into the query values the period parsers read.
+func periodJobParams(params json.RawMessage) (url.Values, error) {
+	var raw map[string]string
+	if err := json.Unmarshal(params, &raw); err != nil {
+		return nil, errors.New("invalid params, expected an object of strings: " + err.Error())
+	}
+	q := url.Values{}
+	for k, v := range raw {
+		q.Set(k, v)
+	}
+	return q, nil
+}
+
+func validatePeriodJob(fiscalStartMonth int, comparison bool) func(json.RawMessage) error {
+	return func(params json.RawMessage) error {
+		q, err := periodJobParams(params)
+		if err != nil {
+			return err
+		}
+		if _, err := parseReportPeriod(q, fiscalStartMonth, time.Now()); err != nil {
+			return err
+		}
+		if comparison {
+			_, err = parseComparisonOptions(q.Get("compare"), q.Get("sparkline"))
+		}
+		return err
+	}
+}
+
+// runMonthlyReportJob builds the monthly report with all its exports, including the
+// PDF. Relative periods resolve when the job runs.
+func runMonthlyReportJob(deps reportJobDeps) jobs.Runner {
+	return func(ctx context.Context, params json.RawMessage, progress func(float64, string)) (*jobs.Result, error) {
+		q, err := periodJobParams(params)
+		if err != nil {
+			return nil, jobs.Permanent(err)
+		}
+		customerID := q.Get("customer_id")
+		if customerID == "" {
+			customerID = deps.defaultCustomerID
+		}
+		period, err := parseReportPeriod(q, deps.fiscalStartMonth, time.Now())
+		if err != nil {
+			return nil, jobs.Permanent(err)
+		}
+		compare, err := parseComparisonOptions(q.Get("compare"), q.Get("sparkline"))
+		if err != nil {
+			return nil, jobs.Permanent(err)
+		}
+		ctx = dbctx.WithQueryTimeout(ctx, deps.queryTimeout)
+
+		progress(0.1, "building report")
+		start := time.Now()
+		report, err := deps.store.GetPeriodReport(ctx, customerID, period)
+		recordDBQuery("mcp", "GetPeriodReport", time.Since(start).Seconds(), err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build monthly report: %w", err)
+		}
+		legacyTimeseries(q, report)
+		var comparison *mysqlstore.ReportComparison
+		if compare.Previous || compare.YearAgo || compare.Sparkline > 0 {
+			progress(0.4, "building period comparison")
+			start = time.Now()
+			comparison, err = deps.store.GetReportComparison(ctx, customerID, report, compare)
+			recordDBQuery("mcp", "GetReportComparison", time.Since(start).Seconds(), err)
+			if err != nil {
+				return nil, fmt.Errorf("failed to build period comparison: %w", err)
+			}
+		}
+		progress(0.6, "building transfer duration chart")
+		start = time.Now()
+		durations, err := deps.store.GetPeriodDurationChart(ctx, customerID, period)
+		recordDBQuery("mcp", "GetPeriodDurationChart", time.Since(start).Seconds(), err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build transfer duration chart: %w", err)
+		}
+		progress(0.8, "rendering pdf")
+		pdf, err := renderMonthlyReportPDF(deps.brand, report, comparison, durations, time.Now())
+		if err != nil {
+			return nil, jobs.Permanent(fmt.Errorf("failed to render monthly report pdf: %w", err))
+		}
+		filename := fmt.Sprintf("monthly-report-%s-%s", report.Month, report.CustomerID)
+		body := monthlyReportResponse(ctx, deps.store, deps.scope.ssStore, period, report, comparison)
+		return reportJobResult(filename, body, pdf, monthlyReportExportTables(report, comparison, durations)...)
+	}
+}
+
+func runBillingJob(deps reportJobDeps) jobs.Runner {
+	return func(ctx context.Context, params json.RawMessage, progress func(float64, string)) (*jobs.Result, error) {
+		q, err := periodJobParams(params)
+		if err != nil {
+			return nil, jobs.Permanent(err)
+		}
+		period, err := parseReportPeriod(q, deps.fiscalStartMonth, time.Now())
+		if err != nil {
+			return nil, jobs.Permanent(err)
+		}
+		progress(0.1, "pricing "+period.Label)
+		id, err := priceBillingRun(dbctx.WithQueryTimeout(ctx, deps.queryTimeout), deps.store, deps.scope.ssStore, period)
+		if err != nil {
+			return nil, reportJobError(err)
+		}
+		start := time.Now()
+		run, err := deps.store.GetBillingRun(ctx, id)
+		recordDBQuery("appsqlite", "GetBillingRun", time.Since(start).Seconds(), err)
+		if err != nil {
+			return nil, err
+		}
+		body := map[string]any{
+			"meta": map[string]any{"saved": true, "period": period},
+			"data": run,
+		}
+		return reportJobResult(fmt.Sprintf("billing-%s-%d", run.PeriodLabel, run.ID), body, nil, billingRunExportTable(run))
+	}
+}
+
+func validateAIPStatsJob(params json.RawMessage) error {
+	var p aipStatsJobParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return errors.New("invalid params: " + err.Error())
+	}
+	if strings.TrimSpace(p.AIPUUID) == "" {
+		return errors.New("aip_uuid is required")
+	}
+	return nil
+}
+
+func runAIPStatsJob(deps reportJobDeps) jobs.Runner {
+	return func(ctx context.Context, params json.RawMessage, progress func(float64, string)) (*jobs.Result, error) {
+		var p aipStatsJobParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jobs.Permanent(err)
+		}
+		aipUUID := strings.TrimSpace(p.AIPUUID)
+		pageSize := p.PageSize
+		if pageSize <= 0 {
+			pageSize = deps.scope.esPageSize
+		}
+		progress(0.1, "scanning AIP files")
+		start := time.Now()
+		stats, err := deps.scope.esClient.AIPStats(ctx, deps.scope.esIndex, aipUUID, pageSize)
+		recordExternalProbe("elasticsearch", "AIPStats", time.Since(start).Seconds(), err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch AIP stats for %s: %w", aipUUID, err)
+		}
+		body := map[string]any{
+			"meta": map[string]any{"index": deps.scope.esIndex, "aip_uuid": aipUUID},
+			"data": stats,
+		}
+		return reportJobResult("aip-stats-"+aipUUID, body, nil, aipFormatsExportTable(stats))
+	}
+}
+
+// reportJobError marks report errors caused by the request as permanent so the job
+// is not retried.
+func reportJobError(err error) error {
+	var re *reportError
+	if errors.As(err, &re) && !re.backend {
+		return jobs.Permanent(err)
+	}
+	return err
+}
+
+func reportJobResult(name string, body any, pdf []byte, tables ...export.Table) (*jobs.Result, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return &jobs.Result{Name: name, Data: data, Tables: tables, PDF: pdf}, nil
+}
+
+func billingRunExportTable(run *mysqlstore.BillingRun) export.Table {
+	t := export.Table{Title: "Billing " + run.PeriodLabel, Columns: []export.Column{
+		{Key: "customer_id", Label: "Customer"},
+		{Key: "plan_name", Label: "Plan"},
+		{Key: "item", Label: "Item"},
+		{Key: "description", Label: "Description"},
+		{Key: "quantity", Label: "Quantity", Kind: export.KindNumber},
+		{Key: "unit", Label: "Unit"},
+		{Key: "unit_price", Label: "Unit Price", Kind: export.KindNumber},
+		{Key: "amount", Label: "Amount", Kind: export.KindNumber},
+		{Key: "currency", Label: "Currency"},
+	}}
+	for _, l := range run.Lines {
+		t.Rows = append(t.Rows, []any{l.CustomerID, l.PlanName, l.Item, l.Description, l.Quantity, l.Unit, l.UnitPrice, l.Amount, l.Currency})
+	}
+	return t
+}
+
+func aipFormatsExportTable(stats *esstore.AIPStats) export.Table {
+	t := export.Table{Title: "Formats", Columns: []export.Column{
+		{Key: "format", Label: "Format"},
+		{Key: "files", Label: "Files", Kind: export.KindNumber},
+	}}
+	for _, c := range stats.FormatNameCounts {
+		t.Rows = append(t.Rows, []any{c.Key, c.Count})
+	}
+	return t
+}
+
+// jobsRouter serves /api/v1/jobs and /api/v1/jobs/{id}[/cancel|/result].
+func jobsRouter(defaultLimit int, m *jobs.Manager) nethttp.HandlerFunc {
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/jobs"), "/"), "/")
+		if parts[0] == "" {
+			switch r.Method {
+			case nethttp.MethodGet:
+				listJobs(w, r, defaultLimit, m)
+			case nethttp.MethodPost:
+				enqueueJob(w, r, m)
+			default:
+				writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			}
+			return
+		}
+		if len(parts) > 2 {
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "not found"})
+			return
+		}
+		id := parts[0]
+		action := ""
+		if len(parts) == 2 {
+			action = parts[1]
+		}
+
+		switch {
+		case action == "" && r.Method == nethttp.MethodGet:
+			job, err := m.Get(r.Context(), id)
+			if err != nil {
+				writeJobError(w, err)
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{"data": job})
+		case action == "" && r.Method == nethttp.MethodDelete:
+			if err := m.Delete(r.Context(), id); err != nil {
+				writeJobError(w, err)
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{"meta": map[string]any{"deleted": true, "id": id}})
+		case action == "cancel" && r.Method == nethttp.MethodPost:
+			job, err := m.Cancel(r.Context(), id)
+			if err != nil {
+				writeJobError(w, err)
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{"data": job})
+		case action == "result" && r.Method == nethttp.MethodGet:
+			writeJobResult(w, r, m, id)
+		case action == "" || action == "cancel" || action == "result":
+			writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		default:
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "not found"})
+		}
+	}
+}
+
+func listJobs(w nethttp.ResponseWriter, r *nethttp.Request, defaultLimit int, m *jobs.Manager) {
+	limit := parseLimit(r, defaultLimit)
+	var states []string
+	for _, s := range strings.Split(r.URL.Query().Get("state"), ",") {
+		if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+			states = append(states, s)
+		}
+	}
+	items, err := m.List(r.Context(), limit, states...)
+	if err != nil {
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to list jobs"})
+		return
+	}
+	kinds := m.Kinds()
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	available := make([]map[string]string, 0, len(names))
+	for _, name := range names {
+		available = append(available, map[string]string{"kind": name, "description": kinds[name]})
+	}
+	writeJSON(w, nethttp.StatusOK, map[string]any{
+		"meta": map[string]any{"limit": limit, "count": len(items), "states": states, "kinds": available},
+		"data": items,
+	})
+}
+
+func enqueueJob(w nethttp.ResponseWriter, r *nethttp.Request, m *jobs.Manager) {
+	var req enqueueJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
+		return
+	}
+	job, err := m.Enqueue(r.Context(), strings.TrimSpace(req.Kind), req.Params)
+	switch {
+	case errors.Is(err, jobs.ErrQueueFull):
+		w.Header().Set("Retry-After", "30")
+		writeJSON(w, nethttp.StatusTooManyRequests, map[string]any{"error": err.Error()})
+		return
+	case jobs.IsInvalid(err):
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	case err != nil:
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to enqueue job"})
+		return
+	}
+	w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+	writeJSON(w, nethttp.StatusAccepted, map[string]any{"data": job})
+}
+
+// writeJobResult downloads a succeeded job's result in ?format= or the Accept
+// header's format; JSON returns the body the synchronous endpoint would have.
+func writeJobResult(w nethttp.ResponseWriter, r *nethttp.Request, m *jobs.Manager, id string) {
+	format, err := negotiateExportFormat(r)
+	if err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	job, result, err := m.Result(r.Context(), id)
+	if errors.Is(err, jobs.ErrNotReady) {
+		writeJSON(w, nethttp.StatusConflict, map[string]any{"error": fmt.Sprintf("job is %s, results are only available once it succeeded", job.State), "data": job})
+		return
+	}
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	offered := false
+	for _, f := range result.Formats() {
+		offered = offered || f == format
+	}
+	if !offered {
+		writeJSON(w, nethttp.StatusNotAcceptable, map[string]any{"error": fmt.Sprintf("%s jobs cannot be downloaded as %s, use one of %s", job.Kind, format, strings.Join(result.Formats(), ", "))})
+		return
+	}
+	body, err := result.Render(format)
+	if err != nil {
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to render export: " + err.Error()})
+		return
+	}
+	if format == export.FormatJSON {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(nethttp.StatusOK)
+		_, _ = w.Write(body)
+		return
+	}
+	writeAttachment(w, format, result.Name, body)
+}
+
+func writeJobError(w nethttp.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": err.Error()})
+	case errors.Is(err, jobs.ErrFinished):
+		writeJSON(w, nethttp.StatusConflict, map[string]any{"error": err.Error()})
+	default:
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": err.Error()})
+	}
+}
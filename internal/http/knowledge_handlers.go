@@ -0,0 +1,140 @@
+package http
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	nethttp "net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+)
+
+type saveKnowledgeRequest struct {
+	SignatureID       string   `json:"signature_id"`
+	MatchText         string   `json:"match_text"`
+	MicroserviceGroup string   `json:"microservice_group"`
+	Title             string   `json:"title"`
+	Explanation       string   `json:"explanation"`
+	Remediation       string   `json:"remediation"`
+	Links             []string `json:"links"`
+	Severity          string   `json:"severity"`
+}
+
+func (req saveKnowledgeRequest) toKnowledge(id int64) mysqlstore.FailureKnowledge {
+	return mysqlstore.FailureKnowledge{
+		ID:                id,
+		SignatureID:       req.SignatureID,
+		MatchText:         req.MatchText,
+		MicroserviceGroup: req.MicroserviceGroup,
+		Title:             req.Title,
+		Explanation:       req.Explanation,
+		Remediation:       req.Remediation,
+		Links:             req.Links,
+		Severity:          req.Severity,
+	}
+}
+
+func failureKnowledgeRouter(defaultLimit int, store *mysqlstore.Store) nethttp.HandlerFunc {
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if store == nil {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{"error": "database integration disabled (set APP_DB_ENABLED=true)"})
+			return
+		}
+		if !store.HasTemplateStore() {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{
+				"error": "template sqlite store not available",
+				"hint":  "set APP_CUSTOMER_MAP_SQLITE_PATH to enable app-owned knowledge base persistence",
+			})
+			return
+		}
+
+		idRaw := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/troubleshooting/knowledge"), "/")
+		if idRaw == "" {
+			switch r.Method {
+			case nethttp.MethodGet:
+				limit := parseLimit(r, defaultLimit)
+				start := time.Now()
+				items, err := store.ListFailureKnowledge(r.Context(), limit)
+				recordDBQuery("appsqlite", "ListFailureKnowledge", time.Since(start).Seconds(), err)
+				if err != nil {
+					writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to list failure knowledge"})
+					return
+				}
+				writeJSON(w, nethttp.StatusOK, map[string]any{
+					"meta": map[string]any{"count": len(items), "limit": limit},
+					"data": items,
+				})
+			case nethttp.MethodPost:
+				saveFailureKnowledge(w, r, store, 0)
+			default:
+				writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			}
+			return
+		}
+
+		id, err := strconv.ParseInt(idRaw, 10, 64)
+		if err != nil || id <= 0 {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid knowledge id"})
+			return
+		}
+		switch r.Method {
+		case nethttp.MethodGet:
+			start := time.Now()
+			item, err := store.GetFailureKnowledge(r.Context(), id)
+			recordDBQuery("appsqlite", "GetFailureKnowledge", time.Since(start).Seconds(), err)
+			if err != nil {
+				writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "knowledge entry not found"})
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{"data": item})
+		case nethttp.MethodPut:
+			saveFailureKnowledge(w, r, store, id)
+		case nethttp.MethodDelete:
+			start := time.Now()
+			deleted, err := store.DeleteFailureKnowledge(r.Context(), id)
+			recordDBQuery("appsqlite", "DeleteFailureKnowledge", time.Since(start).Seconds(), err)
+			if err != nil {
+				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to delete knowledge entry"})
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{
+				"meta": map[string]any{"deleted": deleted, "id": id},
+			})
+		default:
+			writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		}
+	}
+}
+
+func saveFailureKnowledge(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store, id int64) {
+	var req saveKnowledgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
+		return
+	}
+	start := time.Now()
+	savedID, err := store.SaveFailureKnowledge(r.Context(), req.toKnowledge(id))
+	recordDBQuery("appsqlite", "SaveFailureKnowledge", time.Since(start).Seconds(), err)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "knowledge entry not found"})
+			return
+		}
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	startGet := time.Now()
+	item, err := store.GetFailureKnowledge(r.Context(), savedID)
+	recordDBQuery("appsqlite", "GetFailureKnowledge", time.Since(startGet).Seconds(), err)
+	if err != nil {
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "knowledge entry saved but failed to read it back"})
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, map[string]any{
+		"meta": map[string]any{"saved": true},
+		"data": item,
+	})
+}
@@ -0,0 +1,306 @@
+package http
+
+import (
+	"fmt"
+	nethttp "net/http"
+	"sort"
+	"strings"
+	"time"
+
+	esstore "go-am-realtime-report-ui/internal/connectors/es"
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+	ssstore "go-am-realtime-report-ui/internal/connectors/ssdb"
+)
+
+// lineageMaxESLookups caps per-request Elasticsearch searches for large arranged SIPs.
+const lineageMaxESLookups = 10
+
+func lineageHandler(store *mysqlstore.Store, ssStore *ssstore.Store, esClient *esstore.Client, esLookupLimit int) nethttp.HandlerFunc {
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.Method != nethttp.MethodGet {
+			writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			return
+		}
+		if store == nil && ssStore == nil {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{
+				"error": "database integration disabled (set APP_DB_ENABLED=true)",
+			})
+			return
+		}
+
+		trimmed := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/lineage/"), "/")
+		if trimmed == "" || strings.Contains(trimmed, "/") {
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "not found"})
+			return
+		}
+		queryUUID := strings.ToLower(trimmed)
+
+		graph := mysqlstore.NewLineageGraph(queryUUID)
+		meta := map[string]any{"query_uuid": queryUUID}
+
+		if store != nil {
+			start := time.Now()
+			err := store.ResolveLineage(r.Context(), graph, queryUUID)
+			recordDBQuery("mcp", "ResolveLineage", time.Since(start).Seconds(), err)
+			if err != nil {
+				meta["mcp_error"] = err.Error()
+			}
+		} else {
+			meta["mcp_error"] = "database integration disabled (set APP_DB_ENABLED=true)"
+		}
+
+		if ssStore != nil {
+			if err := addStorageLineage(r, graph, store, ssStore); err != nil {
+				meta["ss_error"] = err.Error()
+			}
+		}
+
+		if esClient != nil && esClient.Enabled() {
+			if hits, err := addIndexLineage(r, graph, esClient, esLookupLimit); err != nil {
+				meta["es_error"] = err.Error()
+			} else {
+				meta["es_hits"] = hits
+			}
+		}
+
+		if len(graph.Nodes) == 0 {
+			payload := map[string]any{"error": fmt.Sprintf("no lineage found for uuid: %s", queryUUID)}
+			for _, key := range []string{"mcp_error", "ss_error", "es_error"} {
+				if v, ok := meta[key]; ok {
+					payload[key] = v
+				}
+			}
+			writeJSON(w, nethttp.StatusNotFound, payload)
+			return
+		}
+
+		meta["resolved_as"] = graph.ResolvedAs
+		meta["node_count"] = len(graph.Nodes)
+		meta["edge_count"] = len(graph.Edges)
+		writeJSON(w, nethttp.StatusOK, map[string]any{
+			"meta": meta,
+			"data": graph,
+		})
+	}
+}
+
+// addStorageLineage attaches SS packages (transfer backlog, AIP, DIP, replicas) to the graph.
+// When MCP does not know the query UUID (e.g. a DIP or replica UUID), the related AIP is
+// resolved back through MCP since Archivematica reuses the SIP UUID as the AIP UUID, and
+// the packages are looked up again for the transfers and SIPs found that way.
+func addStorageLineage(r *nethttp.Request, graph *mysqlstore.LineageGraph, store *mysqlstore.Store, ssStore *ssstore.Store) error {
+	byUUID, relations, err := lookupLineagePackages(r, graph, ssStore)
+	if err != nil || len(byUUID) == 0 {
+		return err
+	}
+
+	if graph.ResolvedAs == "" {
+		if p, ok := byUUID[graph.QueryUUID]; ok {
+			graph.ResolvedAs = lineagePackageNodeType(p.PackageType)
+		}
+		if store != nil {
+			resolved := false
+			for _, id := range sortedPackageUUIDs(byUUID) {
+				if lineagePackageNodeType(byUUID[id].PackageType) != "aip" {
+					continue
+				}
+				start := time.Now()
+				err := store.ResolveLineage(r.Context(), graph, id)
+				recordDBQuery("mcp", "ResolveLineage", time.Since(start).Seconds(), err)
+				if err != nil {
+					return err
+				}
+				resolved = true
+			}
+			if resolved {
+				if byUUID, relations, err = lookupLineagePackages(r, graph, ssStore); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, id := range sortedPackageUUIDs(byUUID) {
+		p := byUUID[id]
+		nodeType := lineagePackageNodeType(p.PackageType)
+		nodeID := graph.AddNode(mysqlstore.LineageNode{
+			UUID:      p.UUID,
+			Type:      nodeType,
+			Source:    "storage_service",
+			Status:    strings.ToUpper(p.Status),
+			CreatedAt: p.StoredDate,
+			Attributes: map[string]any{
+				"package_type":     p.PackageType,
+				"size_bytes":       p.SizeBytes,
+				"current_path":     p.CurrentPath,
+				"location_uuid":    p.CurrentLocation,
+				"location_purpose": p.LocationPurpose,
+				"pipeline_uuid":    p.PipelineUUID,
+				"files_count":      p.FilesCount,
+			},
+		})
+		switch {
+		case nodeType == "aip" && graph.HasNode("sip", p.UUID):
+			graph.AddEdge(mysqlstore.LineageNodeID("sip", p.UUID), nodeID, "stored_as")
+		case nodeType == "transfer_package" && graph.HasNode("transfer", p.UUID):
+			graph.AddEdge(mysqlstore.LineageNodeID("transfer", p.UUID), nodeID, "backlogged_as")
+		}
+	}
+
+	for _, rel := range relations {
+		from, okFrom := byUUID[rel.FromUUID]
+		to, okTo := byUUID[rel.ToUUID]
+		if !okFrom || !okTo {
+			continue
+		}
+		fromType := lineagePackageNodeType(from.PackageType)
+		toType := lineagePackageNodeType(to.PackageType)
+		fromID := mysqlstore.LineageNodeID(fromType, from.UUID)
+		toID := mysqlstore.LineageNodeID(toType, to.UUID)
+		switch {
+		case rel.Relation == "replica":
+			graph.AddEdge(fromID, toID, "replicated_as")
+		case fromType == "dip" && toType == "aip":
+			graph.AddEdge(toID, fromID, "disseminated_as")
+		case fromType == "aip" && toType == "dip":
+			graph.AddEdge(fromID, toID, "disseminated_as")
+		default:
+			graph.AddEdge(fromID, toID, "related_to")
+		}
+	}
+	return nil
+}
+
+// lookupLineagePackages loads the SS packages of the query UUID and of the graph's
+// transfers and SIPs, plus the packages related to them.
+func lookupLineagePackages(r *nethttp.Request, graph *mysqlstore.LineageGraph, ssStore *ssstore.Store) (map[string]ssstore.PackageInfo, []ssstore.PackageRelation, error) {
+	lookup := []string{graph.QueryUUID}
+	lookup = append(lookup, graph.NodeUUIDs("transfer")...)
+	lookup = append(lookup, graph.NodeUUIDs("sip")...)
+
+	start := time.Now()
+	packages, err := ssStore.LookupPackagesByUUIDs(r.Context(), lookup)
+	recordDBQuery("ssdb", "LookupPackagesByUUIDs", time.Since(start).Seconds(), err)
+	if err != nil {
+		return nil, nil, err
+	}
+	byUUID := map[string]ssstore.PackageInfo{}
+	for _, p := range packages {
+		byUUID[p.UUID] = p
+	}
+	if len(byUUID) == 0 {
+		return byUUID, nil, nil
+	}
+
+	start = time.Now()
+	relations, err := ssStore.LookupPackageRelations(r.Context(), sortedPackageUUIDs(byUUID))
+	recordDBQuery("ssdb", "LookupPackageRelations", time.Since(start).Seconds(), err)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	missing := make([]string, 0)
+	for _, rel := range relations {
+		for _, id := range []string{rel.FromUUID, rel.ToUUID} {
+			if _, ok := byUUID[id]; !ok {
+				missing = append(missing, id)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		start = time.Now()
+		extra, err := ssStore.LookupPackagesByUUIDs(r.Context(), missing)
+		recordDBQuery("ssdb", "LookupPackagesByUUIDs", time.Since(start).Seconds(), err)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, p := range extra {
+			byUUID[p.UUID] = p
+		}
+	}
+	return byUUID, relations, nil
+}
+
+// addIndexLineage adds one node per ES index that holds documents for a transfer or AIP.
+func addIndexLineage(r *nethttp.Request, graph *mysqlstore.LineageGraph, esClient *esstore.Client, esLookupLimit int) (int64, error) {
+	type candidate struct{ nodeID, uuid string }
+	candidates := make([]candidate, 0)
+	for _, nodeType := range []string{"aip", "transfer"} {
+		for _, id := range graph.NodeUUIDs(nodeType) {
+			candidates = append(candidates, candidate{mysqlstore.LineageNodeID(nodeType, id), id})
+		}
+	}
+	if len(graph.NodeUUIDs("aip")) == 0 {
+		// Without SS access, fall back to the SIP UUID which doubles as the AIP UUID.
+		for _, id := range graph.NodeUUIDs("sip") {
+			candidates = append(candidates, candidate{mysqlstore.LineageNodeID("sip", id), id})
+		}
+	}
+	if len(candidates) > lineageMaxESLookups {
+		candidates = candidates[:lineageMaxESLookups]
+	}
+
+	var total int64
+	for _, c := range candidates {
+		start := time.Now()
+		result, err := esClient.SearchTransfer(r.Context(), c.uuid, esLookupLimit)
+		recordExternalProbe("elasticsearch", "SearchTransfer", time.Since(start).Seconds(), err)
+		if err != nil {
+			return total, err
+		}
+		if result == nil || len(result.Hits) == 0 {
+			continue
+		}
+		total += result.TotalHits
+
+		perIndex := map[string]int{}
+		for _, h := range result.Hits {
+			perIndex[h.Index]++
+		}
+		indexes := make([]string, 0, len(perIndex))
+		for idx := range perIndex {
+			indexes = append(indexes, idx)
+		}
+		sort.Strings(indexes)
+		for _, idx := range indexes {
+			nodeID := graph.AddNode(mysqlstore.LineageNode{
+				UUID:   idx + "/" + c.uuid,
+				Type:   "index",
+				Source: "elasticsearch",
+				Name:   idx,
+				Status: "INDEXED",
+				Attributes: map[string]any{
+					"index":        idx,
+					"sampled_hits": perIndex[idx],
+					"total_hits":   result.TotalHits,
+				},
+			})
+			graph.AddEdge(c.nodeID, nodeID, "indexed_as")
+		}
+	}
+	return total, nil
+}
+
+func lineagePackageNodeType(packageType string) string {
+	switch strings.ToUpper(strings.TrimSpace(packageType)) {
+	case "AIP", "AIC":
+		return "aip"
+	case "DIP":
+		return "dip"
+	case "TRANSFER":
+		return "transfer_package"
+	case "":
+		return "package"
+	default:
+		return strings.ToLower(strings.TrimSpace(packageType))
+	}
+}
+
+func sortedPackageUUIDs(m map[string]ssstore.PackageInfo) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
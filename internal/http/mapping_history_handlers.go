@@ -0,0 +1,110 @@
+package http
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"strings"
+	"time"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+)
+
+type mappingValidityRequest struct {
+	SourceOfAcquisition string `json:"source_of_acquisition"`
+	EffectiveFrom       string `json:"effective_from"`
+	EffectiveTo         string `json:"effective_to"`
+}
+
+// customerMappingHistoryRoutes serves /api/v1/reports/customer-mappings/{customer_id}/history
+// and /api/v1/reports/customer-mappings/{customer_id}/validity. Both need the app
+// SQLite store, which is the only place validity windows and changes are kept.
+func customerMappingHistoryRoutes(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store, defaultLimit int, customerID, action string) {
+	if !store.HasTemplateStore() {
+		writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{
+			"error": "template sqlite store not available",
+			"hint":  "set APP_CUSTOMER_MAP_SQLITE_PATH to keep customer mapping history",
+		})
+		return
+	}
+	switch {
+	case action == "history" && r.Method == nethttp.MethodGet:
+		var at *time.Time
+		if raw := strings.TrimSpace(r.URL.Query().Get("at")); raw != "" {
+			parsed, _, err := parseFlexibleTime(raw)
+			if err != nil {
+				writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid at, expected YYYY-MM-DD or YYYY-MM-DDTHH:MM"})
+				return
+			}
+			at = &parsed
+		}
+		limit := parseLimit(r, defaultLimit)
+		start := time.Now()
+		history, err := store.GetCustomerMappingHistory(r.Context(), customerID, at, limit)
+		recordDBQuery("appsqlite", "GetCustomerMappingHistory", time.Since(start).Seconds(), err)
+		if err != nil {
+			writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to fetch customer mapping history"})
+			return
+		}
+		writeJSON(w, nethttp.StatusOK, map[string]any{
+			"meta": map[string]any{"customer_id": customerID, "count": len(history.Events), "limit": limit},
+			"data": history,
+		})
+	case action == "validity" && r.Method == nethttp.MethodPut:
+		var req mappingValidityRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
+			return
+		}
+		from, to, err := parseMappingWindow(req.EffectiveFrom, req.EffectiveTo)
+		if err != nil {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		start := time.Now()
+		item, err := store.SetCustomerMappingValidity(r.Context(), customerID, req.SourceOfAcquisition, from, to)
+		recordDBQuery("appsqlite", "SetCustomerMappingValidity", time.Since(start).Seconds(), err)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "customer mapping not found"})
+				return
+			}
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, nethttp.StatusOK, map[string]any{
+			"meta": map[string]any{"customer_id": customerID, "saved": true},
+			"data": item,
+		})
+	default:
+		writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+	}
+}
+
+// parseMappingWindow parses optional effective_from/effective_to values. Both
+// are instants; a date means midnight UTC, so effective_to of 2026-01-01 ends the
+// mapping before that day.
+func parseMappingWindow(fromRaw, toRaw string) (*time.Time, *time.Time, error) {
+	var from, to *time.Time
+	for _, v := range []struct {
+		name string
+		raw  string
+		out  **time.Time
+	}{{"effective_from", fromRaw, &from}, {"effective_to", toRaw, &to}} {
+		if strings.TrimSpace(v.raw) == "" {
+			continue
+		}
+		parsed, _, err := parseFlexibleTime(v.raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid %s, expected YYYY-MM-DD or YYYY-MM-DDTHH:MM", v.name)
+		}
+		parsed = parsed.UTC()
+		*v.out = &parsed
+	}
+	if from != nil && to != nil && !to.After(*from) {
+		return nil, nil, fmt.Errorf("effective_to must be after effective_from")
+	}
+	return from, to, nil
+}
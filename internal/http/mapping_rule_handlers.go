@@ -0,0 +1,213 @@
+package http
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	nethttp "net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+)
+
+type saveMappingRuleRequest struct {
+	CustomerID string `json:"customer_id"`
+	Field      string `json:"field"`
+	MatchType  string `json:"match_type"`
+	Pattern    string `json:"pattern"`
+	Priority   int    `json:"priority"`
+	Enabled    *bool  `json:"enabled"`
+	Note       string `json:"note"`
+}
+
+func (req saveMappingRuleRequest) toRule(id int64) mysqlstore.CustomerMappingRule {
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	return mysqlstore.CustomerMappingRule{
+		ID:         id,
+		CustomerID: req.CustomerID,
+		Field:      req.Field,
+		MatchType:  req.MatchType,
+		Pattern:    req.Pattern,
+		Priority:   req.Priority,
+		Enabled:    enabled,
+		Note:       req.Note,
+	}
+}
+
+// previewMappingRule is an unsaved rule; with the id of a saved rule it stands in
+// for that rule.
+type previewMappingRule struct {
+	ID int64 `json:"id"`
+	saveMappingRuleRequest
+}
+
+type previewMappingRulesRequest struct {
+	Rules    []previewMappingRule `json:"rules"`
+	DateFrom string               `json:"date_from"`
+	DateTo   string               `json:"date_to"`
+	Limit    int                  `json:"limit"`
+}
+
+// customerMappingRulesRouter serves /api/v1/reports/customer-mapping-rules,
+// /api/v1/reports/customer-mapping-rules/preview and
+// /api/v1/reports/customer-mapping-rules/{id}.
+func customerMappingRulesRouter(store *mysqlstore.Store) nethttp.HandlerFunc {
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if store == nil {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{"error": "database integration disabled (set APP_DB_ENABLED=true)"})
+			return
+		}
+		if !store.HasTemplateStore() {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{
+				"error": "template sqlite store not available",
+				"hint":  "set APP_CUSTOMER_MAP_SQLITE_PATH to enable customer mapping rules",
+			})
+			return
+		}
+
+		idRaw := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/reports/customer-mapping-rules"), "/")
+		switch idRaw {
+		case "":
+			switch r.Method {
+			case nethttp.MethodGet:
+				start := time.Now()
+				items, err := store.ListCustomerMappingRules(r.Context(), r.URL.Query().Get("customer_id"))
+				recordDBQuery("appsqlite", "ListCustomerMappingRules", time.Since(start).Seconds(), err)
+				if err != nil {
+					writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to list customer mapping rules"})
+					return
+				}
+				writeJSON(w, nethttp.StatusOK, map[string]any{
+					"meta": map[string]any{"count": len(items)},
+					"data": items,
+				})
+			case nethttp.MethodPost:
+				saveMappingRule(w, r, store, 0)
+			default:
+				writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			}
+			return
+		case "preview":
+			if r.Method != nethttp.MethodPost {
+				writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+				return
+			}
+			previewMappingRules(w, r, store)
+			return
+		}
+
+		id, err := strconv.ParseInt(idRaw, 10, 64)
+		if err != nil || id <= 0 {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid customer mapping rule id"})
+			return
+		}
+		switch r.Method {
+		case nethttp.MethodGet:
+			start := time.Now()
+			item, err := store.GetCustomerMappingRule(r.Context(), id)
+			recordDBQuery("appsqlite", "GetCustomerMappingRule", time.Since(start).Seconds(), err)
+			if err != nil {
+				writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "customer mapping rule not found"})
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{"data": item})
+		case nethttp.MethodPut:
+			saveMappingRule(w, r, store, id)
+		case nethttp.MethodDelete:
+			start := time.Now()
+			deleted, err := store.DeleteCustomerMappingRule(r.Context(), id)
+			recordDBQuery("appsqlite", "DeleteCustomerMappingRule", time.Since(start).Seconds(), err)
+			if err != nil {
+				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to delete customer mapping rule"})
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{
+				"meta": map[string]any{"deleted": deleted, "id": id},
+			})
+		default:
+			writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		}
+	}
+}
+
+func saveMappingRule(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store, id int64) {
+	var req saveMappingRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
+		return
+	}
+	start := time.Now()
+	savedID, err := store.SaveCustomerMappingRule(r.Context(), req.toRule(id))
+	recordDBQuery("appsqlite", "SaveCustomerMappingRule", time.Since(start).Seconds(), err)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "customer mapping rule not found"})
+			return
+		}
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	startGet := time.Now()
+	item, err := store.GetCustomerMappingRule(r.Context(), savedID)
+	recordDBQuery("appsqlite", "GetCustomerMappingRule", time.Since(startGet).Seconds(), err)
+	if err != nil {
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "customer mapping rule saved but failed to read it back"})
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, map[string]any{
+		"meta": map[string]any{"saved": true},
+		"data": item,
+	})
+}
+
+func previewMappingRules(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store) {
+	var req previewMappingRulesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
+		return
+	}
+	if len(req.Rules) == 0 {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "at least one rule is required"})
+		return
+	}
+	rules := make([]mysqlstore.CustomerMappingRule, 0, len(req.Rules))
+	for i, it := range req.Rules {
+		rule, err := mysqlstore.ValidateCustomerMappingRule(it.toRule(it.ID))
+		if err != nil {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "rule " + strconv.Itoa(i) + ": " + err.Error()})
+			return
+		}
+		rules = append(rules, rule)
+	}
+	from, to, err := parseReportDateRange(req.DateFrom, req.DateTo)
+	if err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+
+	start := time.Now()
+	preview, err := store.PreviewCustomerMappingRules(r.Context(), rules, mysqlstore.MappingRulePreviewOptions{From: from, To: to, Limit: req.Limit})
+	recordDBQuery("mcp", "PreviewCustomerMappingRules", time.Since(start).Seconds(), err)
+	if err != nil {
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to preview customer mapping rules"})
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, map[string]any{
+		"meta": map[string]any{
+			"date_from":           from.Format(time.RFC3339),
+			"date_to":             to.Format(time.RFC3339),
+			"scanned":             preview.Scanned,
+			"scan_truncated":      preview.ScanTruncated,
+			"claimed":             preview.Claimed,
+			"changed":             preview.Changed,
+			"count":               len(preview.Transfers),
+			"transfers_truncated": preview.TransfersTruncated,
+		},
+		"data": preview,
+	})
+}
@@ -337,6 +337,12 @@ func (r *statusRecorder) WriteHeader(code int) {
 	r.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush
+// streamed responses or extend their write deadline.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 func observabilityMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -366,12 +372,56 @@ func normalizeMetricPath(path string) string {
 		return "/api/v1/transfers/{uuid}/timeline"
 	case strings.HasPrefix(path, "/api/v1/transfers/") && strings.HasSuffix(path, "/errors"):
 		return "/api/v1/transfers/{uuid}/errors"
+	case strings.HasPrefix(path, "/api/v1/sips/") && (strings.HasSuffix(path, "/summary") || strings.HasSuffix(path, "/details") || strings.HasSuffix(path, "/timeline") || strings.HasSuffix(path, "/errors") || strings.HasSuffix(path, "/performance")):
+		return "/api/v1/sips/{uuid}/" + path[strings.LastIndex(path, "/")+1:]
+	case strings.HasPrefix(path, "/api/v1/sips/") && path != "/api/v1/sips/running":
+		return "/api/v1/sips/{uuid}"
+	case strings.HasPrefix(path, "/api/v1/lineage/"):
+		return "/api/v1/lineage/{uuid}"
 	case strings.HasPrefix(path, "/api/v1/aips/") && strings.HasSuffix(path, "/stats"):
 		return "/api/v1/aips/{aip_uuid}/stats"
 	case strings.HasPrefix(path, "/api/v1/aips/") && strings.HasSuffix(path, "/storage-service"):
 		return "/api/v1/aips/{aip_uuid}/storage-service"
+	case strings.HasPrefix(path, "/api/v1/troubleshooting/knowledge/"):
+		return "/api/v1/troubleshooting/knowledge/{id}"
+	case strings.HasPrefix(path, "/api/v1/reports/sla-policies/"):
+		return "/api/v1/reports/sla-policies/{id}"
+	case path == "/api/v1/reports/customer-mapping-rules/preview":
+		return path
+	case strings.HasPrefix(path, "/api/v1/reports/customer-mapping-rules/"):
+		return "/api/v1/reports/customer-mapping-rules/{id}"
+	case strings.HasPrefix(path, "/api/v1/reports/customers/"):
+		return "/api/v1/reports/customers/{id}"
+	case strings.HasPrefix(path, "/api/v1/reports/customer-mappings/") && (strings.HasSuffix(path, "/history") || strings.HasSuffix(path, "/validity")):
+		return "/api/v1/reports/customer-mappings/{customer_id}/" + path[strings.LastIndex(path, "/")+1:]
+	case strings.HasPrefix(path, "/api/v1/reports/customer-mappings/"):
+		return "/api/v1/reports/customer-mappings/{customer_id}"
+	case strings.HasPrefix(path, "/api/v1/reports/billing/plans/"):
+		return "/api/v1/reports/billing/plans/{id}"
+	case strings.HasPrefix(path, "/api/v1/reports/billing/runs/") && (strings.HasSuffix(path, "/approve") || strings.HasSuffix(path, "/export")):
+		return "/api/v1/reports/billing/runs/{id}/" + path[strings.LastIndex(path, "/")+1:]
+	case strings.HasPrefix(path, "/api/v1/reports/billing/runs/"):
+		return "/api/v1/reports/billing/runs/{id}"
+	case path == "/api/v1/reports/templates/export" || path == "/api/v1/reports/templates/import":
+		return path
+	case strings.HasPrefix(path, "/api/v1/reports/templates/") && strings.Contains(path, "/versions/"):
+		return "/api/v1/reports/templates/{id}/versions/{version}"
+	case strings.HasPrefix(path, "/api/v1/reports/templates/") && (strings.HasSuffix(path, "/versions") || strings.HasSuffix(path, "/run")):
+		return "/api/v1/reports/templates/{id}/" + path[strings.LastIndex(path, "/")+1:]
 	case strings.HasPrefix(path, "/api/v1/reports/templates/"):
 		return "/api/v1/reports/templates/{id}"
+	case strings.HasPrefix(path, "/api/v1/reports/snapshots/") && (strings.HasSuffix(path, "/diff") || strings.HasSuffix(path, "/official")):
+		return "/api/v1/reports/snapshots/{id}/" + path[strings.LastIndex(path, "/")+1:]
+	case strings.HasPrefix(path, "/api/v1/reports/snapshots/"):
+		return "/api/v1/reports/snapshots/{id}"
+	case strings.HasPrefix(path, "/api/v1/reports/schedules/") && (strings.HasSuffix(path, "/run") || strings.HasSuffix(path, "/runs")):
+		return "/api/v1/reports/schedules/{id}/" + path[strings.LastIndex(path, "/")+1:]
+	case strings.HasPrefix(path, "/api/v1/reports/schedules/"):
+		return "/api/v1/reports/schedules/{id}"
+	case strings.HasPrefix(path, "/api/v1/jobs/") && (strings.HasSuffix(path, "/cancel") || strings.HasSuffix(path, "/result")):
+		return "/api/v1/jobs/{id}/" + path[strings.LastIndex(path, "/")+1:]
+	case strings.HasPrefix(path, "/api/v1/jobs/"):
+		return "/api/v1/jobs/{id}"
 	default:
 		return path
 	}
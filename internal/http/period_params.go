@@ -0,0 +1,146 @@
+package http
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // report timezones must resolve on hosts without zoneinfo
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+)
+
+const (
+	comparisonDefaultSparkline = 12
+	comparisonMaxSparkline     = 24
+)
+
+// parseReportPeriod reads period, month, date, date_from, date_to, tz and bucket.
+// Without a period parameter it falls back to the legacy month=YYYY-MM behaviour.
+func parseReportPeriod(q url.Values, fiscalStartMonth int, now time.Time) (mysqlstore.ReportPeriod, error) {
+	loc := time.UTC
+	if raw := strings.TrimSpace(q.Get("tz")); raw != "" {
+		parsed, err := time.LoadLocation(raw)
+		if err != nil {
+			return mysqlstore.ReportPeriod{}, fmt.Errorf("invalid tz, expected an IANA timezone such as Europe/Berlin")
+		}
+		loc = parsed
+	}
+
+	kind := strings.ToLower(strings.TrimSpace(q.Get("period")))
+	var (
+		period mysqlstore.ReportPeriod
+		err    error
+	)
+	switch kind {
+	case mysqlstore.PeriodCustom:
+		fromRaw := strings.TrimSpace(q.Get("date_from"))
+		toRaw := strings.TrimSpace(q.Get("date_to"))
+		if fromRaw == "" || toRaw == "" {
+			return mysqlstore.ReportPeriod{}, fmt.Errorf("period=custom requires date_from and date_to")
+		}
+		from, _, err := parseLocalTime(fromRaw, loc)
+		if err != nil {
+			return mysqlstore.ReportPeriod{}, fmt.Errorf("invalid date_from, expected YYYY-MM-DD or YYYY-MM-DDTHH:MM")
+		}
+		to, dateOnly, err := parseLocalTime(toRaw, loc)
+		if err != nil {
+			return mysqlstore.ReportPeriod{}, fmt.Errorf("invalid date_to, expected YYYY-MM-DD or YYYY-MM-DDTHH:MM")
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		period, err = mysqlstore.CustomReportPeriod(from, to, loc)
+		if err != nil {
+			return mysqlstore.ReportPeriod{}, err
+		}
+	default:
+		anchor := now.In(loc)
+		if raw := strings.TrimSpace(q.Get("month")); raw != "" && (kind == "" || kind == mysqlstore.PeriodMonth) {
+			parsed, err := time.ParseInLocation("2006-01", raw, loc)
+			if err != nil {
+				return mysqlstore.ReportPeriod{}, fmt.Errorf("invalid month format, expected YYYY-MM")
+			}
+			anchor = parsed
+		} else if raw := strings.TrimSpace(q.Get("date")); raw != "" {
+			parsed, _, err := parseLocalTime(raw, loc)
+			if err != nil {
+				return mysqlstore.ReportPeriod{}, fmt.Errorf("invalid date, expected YYYY-MM-DD")
+			}
+			anchor = parsed
+		}
+		period, err = mysqlstore.NewReportPeriod(kind, anchor, loc, fiscalStartMonth)
+		if err != nil {
+			return mysqlstore.ReportPeriod{}, err
+		}
+	}
+
+	return period.WithBucket(q.Get("bucket"))
+}
+
+// parseLocalTime is parseFlexibleTime with zone-less values interpreted in loc.
+func parseLocalTime(raw string, loc *time.Location) (time.Time, bool, error) {
+	raw = strings.TrimSpace(raw)
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, false, nil
+	}
+	formats := []struct {
+		layout   string
+		dateOnly bool
+	}{
+		{layout: "2006-01-02T15:04:05", dateOnly: false},
+		{layout: "2006-01-02T15:04", dateOnly: false},
+		{layout: "2006-01-02", dateOnly: true},
+	}
+	for _, format := range formats {
+		if t, err := time.ParseInLocation(format.layout, raw, loc); err == nil {
+			return t, format.dateOnly, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("unsupported time format")
+}
+
+// parseComparisonOptions reads compare=previous,year and sparkline=true|N.
+func parseComparisonOptions(compareRaw, sparklineRaw string) (mysqlstore.ComparisonOptions, error) {
+	var opts mysqlstore.ComparisonOptions
+	for _, part := range strings.Split(compareRaw, ",") {
+		switch strings.ToLower(strings.TrimSpace(part)) {
+		case "":
+		case "previous", "prev":
+			opts.Previous = true
+		case "year", "year_ago", "yoy":
+			opts.YearAgo = true
+		default:
+			return opts, fmt.Errorf("invalid compare value %q (expected previous and/or year)", strings.TrimSpace(part))
+		}
+	}
+
+	switch raw := strings.ToLower(strings.TrimSpace(sparklineRaw)); raw {
+	case "", "false", "0":
+	case "true", "1":
+		opts.Sparkline = comparisonDefaultSparkline
+	default:
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 2 || n > comparisonMaxSparkline {
+			return opts, fmt.Errorf("invalid sparkline, expected true or a period count between 2 and %d", comparisonMaxSparkline)
+		}
+		opts.Sparkline = n
+	}
+	return opts, nil
+}
+
+// legacyTimeseries drops empty buckets for legacy requests (neither period nor
+// bucket set), whose timeseries has only ever listed the days with transfers.
+func legacyTimeseries(q url.Values, report *mysqlstore.MonthlyReport) {
+	if strings.TrimSpace(q.Get("period")) != "" || strings.TrimSpace(q.Get("bucket")) != "" {
+		return
+	}
+	points := report.Timeseries[:0]
+	for _, p := range report.Timeseries {
+		if p.Success != 0 || p.Failed != 0 {
+			points = append(points, p)
+		}
+	}
+	report.Timeseries = points
+}
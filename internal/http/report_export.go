@@ -0,0 +1,330 @@
+package http
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+	"go-am-realtime-report-ui/internal/export"
+)
+
+// monthlyReportKPIs labels the monthly report KPIs in export order.
+var monthlyReportKPIs = []mysqlstore.ReportColumn{
+	{Key: "transfers_total", Label: "Transfers"},
+	{Key: "transfers_success", Label: "Successful Transfers"},
+	{Key: "transfers_failed", Label: "Failed Transfers"},
+	{Key: "avg_processing_sec", Label: "Avg Processing Time"},
+	{Key: "p50_processing_sec", Label: "Median Processing Time"},
+	{Key: "p95_processing_sec", Label: "P95 Processing Time"},
+	{Key: "files_total", Label: "Files"},
+	{Key: "files_original", Label: "Original Files"},
+	{Key: "files_normalized", Label: "Normalized Files"},
+	{Key: "sla_on_time_percent", Label: "SLA On Time"},
+	{Key: "sla_target_seconds", Label: "SLA Target"},
+	{Key: "sla_target_percent", Label: "SLA Target Share"},
+	{Key: "sla_policy", Label: "SLA Policy"},
+	{Key: "sla_breaches", Label: "SLA Breaches"},
+	{Key: "sla_compliant", Label: "SLA Compliant"},
+	{Key: "backlog_end_of_month", Label: "Backlog At Period End"},
+}
+
+// monthlyReportCards are the KPIs shown as headline figures in the PDF.
+var monthlyReportCards = []string{
+	"transfers_total", "transfers_success", "transfers_failed",
+	"avg_processing_sec", "p95_processing_sec", "files_total",
+	"files_normalized", "sla_on_time_percent", "backlog_end_of_month",
+}
+
+// monthlyReportExportTables returns the KPI, daily, duration, SLA breach and trend
+// tables of a monthly report; CSV exports only use the first.
+func monthlyReportExportTables(report *mysqlstore.MonthlyReport, comparison *mysqlstore.ReportComparison, durations []mysqlstore.DurationChartPoint) []export.Table {
+	tables := []export.Table{monthlyKPIExportTable(report, comparison)}
+
+	daily := export.Table{Title: "Transfers per " + periodBucket(report), Columns: []export.Column{
+		{Key: "date", Label: "Date"},
+		{Key: "success", Label: "Successful", Kind: export.KindNumber},
+		{Key: "failed", Label: "Failed", Kind: export.KindNumber},
+	}}
+	for _, p := range report.Timeseries {
+		daily.Rows = append(daily.Rows, []any{p.Date, p.Success, p.Failed})
+	}
+	tables = append(tables, daily)
+
+	if durations != nil {
+		t := export.Table{Title: "Processing times", Columns: []export.Column{
+			{Key: "date", Label: "Date"},
+			{Key: "count", Label: "Transfers", Kind: export.KindNumber},
+			{Key: "avg_seconds", Label: "Avg (s)", Kind: export.KindNumber},
+			{Key: "p50_seconds", Label: "Median (s)", Kind: export.KindNumber},
+			{Key: "p95_seconds", Label: "P95 (s)", Kind: export.KindNumber},
+		}}
+		for _, p := range durations {
+			t.Rows = append(t.Rows, []any{p.Date, p.Count, p.AvgSeconds, p.P50Seconds, p.P95Seconds})
+		}
+		tables = append(tables, t)
+	}
+
+	if report.SLA != nil {
+		tables = append(tables, slaBreachExportTable(report.SLA.Breaches))
+	}
+	if trend := monthlyTrendExportTable(comparison); trend != nil {
+		tables = append(tables, *trend)
+	}
+	return tables
+}
+
+func monthlyKPIExportTable(report *mysqlstore.MonthlyReport, comparison *mysqlstore.ReportComparison) export.Table {
+	t := export.Table{Title: "KPIs", Columns: []export.Column{
+		{Key: "kpi", Label: "Key"},
+		{Key: "label", Label: "KPI"},
+		{Key: "value", Label: "Value " + report.Month},
+	}}
+	if comparison != nil && comparison.Previous != nil {
+		t.Columns = append(t.Columns,
+			export.Column{Key: "previous_value", Label: "Value " + comparison.Previous.Label, Kind: export.KindNumber},
+			export.Column{Key: "previous_delta_percent", Label: "Change vs " + comparison.Previous.Label + " (%)", Kind: export.KindNumber})
+	}
+	if comparison != nil && comparison.YearAgo != nil {
+		t.Columns = append(t.Columns,
+			export.Column{Key: "year_ago_value", Label: "Value " + comparison.YearAgo.Label, Kind: export.KindNumber},
+			export.Column{Key: "year_ago_delta_percent", Label: "Change vs " + comparison.YearAgo.Label + " (%)", Kind: export.KindNumber})
+	}
+	for _, kpi := range monthlyReportKPIs {
+		row := []any{kpi.Key, kpi.Label, report.KPIs[kpi.Key]}
+		if comparison != nil {
+			cmp, ok := comparison.KPIs[kpi.Key]
+			if comparison.Previous != nil {
+				row = append(row, deltaExportValues(ok, cmp.Previous)...)
+			}
+			if comparison.YearAgo != nil {
+				row = append(row, deltaExportValues(ok, cmp.YearAgo)...)
+			}
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t
+}
+
+func deltaExportValues(ok bool, delta *mysqlstore.KPIDelta) []any {
+	if !ok || delta == nil {
+		return []any{nil, nil}
+	}
+	return []any{delta.Value, delta.DeltaPercent}
+}
+
+func slaBreachExportTable(breaches []mysqlstore.SLABreach) export.Table {
+	t := export.Table{Title: "SLA breaches", Columns: []export.Column{
+		{Key: "transfer_uuid", Label: "Transfer UUID"},
+		{Key: "transfer_name", Label: "Transfer"},
+		{Key: "source_of_acquisition", Label: "Source Of Acquisition"},
+		{Key: "status", Label: "Status"},
+		{Key: "started_at", Label: "Started At", Kind: export.KindTime},
+		{Key: "ended_at", Label: "Ended At", Kind: export.KindTime},
+		{Key: "measured_seconds", Label: "Measured (s)", Kind: export.KindNumber},
+		{Key: "over_by_seconds", Label: "Over Target By (s)", Kind: export.KindNumber},
+	}}
+	for _, b := range breaches {
+		t.Rows = append(t.Rows, []any{b.TransferUUID, b.TransferName, b.SourceOfAcquisition, b.Status, b.StartedAt, b.EndedAt, b.MeasuredSeconds, b.OverBySeconds})
+	}
+	return t
+}
+
+// monthlyTrendExportTable lays out the comparison sparklines as one row per period.
+func monthlyTrendExportTable(comparison *mysqlstore.ReportComparison) *export.Table {
+	if comparison == nil {
+		return nil
+	}
+	t := export.Table{Title: "Trend", Columns: []export.Column{{Key: "period", Label: "Period"}}}
+	var periods []string
+	for _, kpi := range monthlyReportKPIs {
+		cmp, ok := comparison.KPIs[kpi.Key]
+		if !ok || len(cmp.Sparkline) == 0 {
+			continue
+		}
+		if periods == nil {
+			for _, p := range cmp.Sparkline {
+				periods = append(periods, p.Period)
+				t.Rows = append(t.Rows, []any{p.Period})
+			}
+		}
+		t.Columns = append(t.Columns, export.Column{Key: kpi.Key, Label: kpi.Label, Kind: export.KindNumber})
+		for i := range t.Rows {
+			var v any
+			if i < len(cmp.Sparkline) {
+				v = cmp.Sparkline[i].Value
+			}
+			t.Rows[i] = append(t.Rows[i], v)
+		}
+	}
+	if periods == nil {
+		return nil
+	}
+	return &t
+}
+
+// renderMonthlyReportPDF lays out the monthly customer report with its charts.
+func renderMonthlyReportPDF(brand export.Brand, report *mysqlstore.MonthlyReport, comparison *mysqlstore.ReportComparison, durations []mysqlstore.DurationChartPoint, now time.Time) ([]byte, error) {
+	period := report.Period
+	subtitle := fmt.Sprintf("Customer %s | %s to %s", report.CustomerID,
+		period.Start.Format("2006-01-02"), period.End.Add(-time.Second).Format("2006-01-02"))
+	if period.Timezone != "" {
+		subtitle += " (" + period.Timezone + ")"
+	}
+	doc := export.NewDocument(brand, "Transfer report "+report.Month, subtitle, now)
+
+	doc.Heading("Key figures")
+	cards := make([]export.Card, 0, len(monthlyReportCards))
+	for _, key := range monthlyReportCards {
+		card := export.Card{Label: monthlyKPILabel(key), Value: monthlyKPIText(key, report.KPIs[key])}
+		if comparison != nil {
+			if cmp, ok := comparison.KPIs[key]; ok && cmp.Previous != nil && cmp.Previous.DeltaPercent != nil {
+				card.Note = fmt.Sprintf("%+.1f%% vs %s", *cmp.Previous.DeltaPercent, cmp.Previous.Period)
+			}
+		}
+		cards = append(cards, card)
+	}
+	doc.Cards(cards)
+
+	bucket := periodBucket(report)
+	doc.Heading("Transfers per " + bucket)
+	volume := export.Chart{Kind: export.ChartBar, Stacked: true, Series: []export.Series{
+		{Name: "Successful", Color: "#5cb85c"},
+		{Name: "Failed", Color: "#d9534f"},
+	}}
+	for _, p := range report.Timeseries {
+		volume.Labels = append(volume.Labels, p.Date)
+		volume.Series[0].Values = append(volume.Series[0].Values, float64(p.Success))
+		volume.Series[1].Values = append(volume.Series[1].Values, float64(p.Failed))
+	}
+	doc.Chart(volume)
+
+	doc.Heading("Processing time (minutes)")
+	durationChart := export.Chart{Kind: export.ChartLine, Series: []export.Series{{Name: "Average"}, {Name: "P95"}}}
+	for _, p := range durations {
+		durationChart.Labels = append(durationChart.Labels, p.Date)
+		durationChart.Series[0].Values = append(durationChart.Series[0].Values, float64(p.AvgSeconds)/60)
+		durationChart.Series[1].Values = append(durationChart.Series[1].Values, float64(p.P95Seconds)/60)
+	}
+	doc.Chart(durationChart)
+
+	if comparison != nil {
+		var lines []export.Sparkline
+		for _, kpi := range monthlyReportKPIs {
+			cmp, ok := comparison.KPIs[kpi.Key]
+			if !ok || len(cmp.Sparkline) < 2 {
+				continue
+			}
+			values := make([]float64, len(cmp.Sparkline))
+			for i, p := range cmp.Sparkline {
+				values[i] = p.Value
+			}
+			lines = append(lines, export.Sparkline{Label: kpi.Label, Value: monthlyKPIText(kpi.Key, cmp.Value), Values: values})
+		}
+		if len(lines) > 0 {
+			doc.Heading(fmt.Sprintf("Trend over %d periods", len(lines[0].Values)))
+			doc.Sparklines(lines)
+		}
+	}
+
+	if sla := report.SLA; sla != nil {
+		doc.Heading("SLA compliance")
+		verdict := "not met"
+		if sla.Compliant {
+			verdict = "met"
+		}
+		doc.Paragraph(fmt.Sprintf("Policy %s (%s): %s of transfers within %s. %d measured, %d within target, %d breached, %d excluded. On time %.1f%%, target %s.",
+			sla.Policy.Name, strings.TrimSpace(sla.Policy.StartMilestone+" to "+sla.Policy.EndMilestone),
+			strconv.FormatFloat(sla.Policy.TargetPercent, 'f', -1, 64)+"%", humanDuration(float64(sla.Policy.TargetSeconds)),
+			sla.Measured, sla.WithinTarget, sla.Breached, sla.Excluded, sla.OnTimePercent, verdict))
+		if len(sla.Breaches) > 0 {
+			breaches := export.Table{Columns: []export.Column{
+				{Key: "transfer_name", Label: "Transfer"},
+				{Key: "source_of_acquisition", Label: "Source Of Acquisition"},
+				{Key: "status", Label: "Status"},
+				{Key: "ended_at", Label: "Ended At", Kind: export.KindTime},
+				{Key: "over_by", Label: "Over Target By"},
+			}}
+			for _, b := range sla.Breaches {
+				breaches.Rows = append(breaches.Rows, []any{b.TransferName, b.SourceOfAcquisition, b.Status, b.EndedAt, humanDuration(float64(b.OverBySeconds))})
+			}
+			doc.Table(breaches)
+			if sla.BreachesTruncated {
+				doc.Paragraph("Only the first breaches are listed; export the SLA report for all of them.")
+			}
+		}
+	}
+
+	doc.Heading("All KPIs")
+	kpis := monthlyKPIExportTable(report, comparison)
+	kpis.Columns = kpis.Columns[1:]
+	for i, row := range kpis.Rows {
+		row[2] = monthlyKPIText(monthlyReportKPIs[i].Key, row[2])
+		kpis.Rows[i] = row[1:]
+	}
+	doc.Table(kpis)
+
+	return doc.Bytes()
+}
+
+func periodBucket(report *mysqlstore.MonthlyReport) string {
+	if report.Period.Bucket == "" {
+		return "day"
+	}
+	return report.Period.Bucket
+}
+
+func monthlyKPILabel(key string) string {
+	for _, kpi := range monthlyReportKPIs {
+		if kpi.Key == key {
+			return kpi.Label
+		}
+	}
+	return key
+}
+
+// monthlyKPIText formats a KPI value for reading: durations, percentages and yes/no.
+func monthlyKPIText(key string, v any) string {
+	var f float64
+	switch x := v.(type) {
+	case nil:
+		return "-"
+	case bool:
+		return map[bool]string{true: "yes", false: "no"}[x]
+	case string:
+		return x
+	case int:
+		f = float64(x)
+	case int64:
+		f = float64(x)
+	case float64:
+		f = x
+	default:
+		return fmt.Sprint(v)
+	}
+	switch {
+	case strings.HasSuffix(key, "_sec") || strings.HasSuffix(key, "_seconds"):
+		return humanDuration(f)
+	case strings.HasSuffix(key, "_percent"):
+		return strconv.FormatFloat(math.Round(f*10)/10, 'f', -1, 64) + "%"
+	case f == math.Trunc(f):
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+// humanDuration prints seconds as e.g. 45s, 12m 30s or 3h 05m.
+func humanDuration(seconds float64) string {
+	s := int64(math.Round(seconds))
+	switch {
+	case s < 60:
+		return fmt.Sprintf("%ds", s)
+	case s < 3600:
+		return fmt.Sprintf("%dm %02ds", s/60, s%60)
+	case s < 86400:
+		return fmt.Sprintf("%dh %02dm", s/3600, s%3600/60)
+	}
+	return fmt.Sprintf("%dd %02dh", s/86400, s%86400/3600)
+}
@@ -0,0 +1,135 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"strings"
+	"time"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+	"go-am-realtime-report-ui/internal/export"
+)
+
+// reportError is a report failure with the status it is answered with. backend is
+// set when a backend failed rather than the request being invalid, so a retry may
+// succeed.
+type reportError struct {
+	status  int
+	message string
+	backend bool
+}
+
+func (e *reportError) Error() string { return e.message }
+
+func badReport(err error) error {
+	return &reportError{status: nethttp.StatusBadRequest, message: err.Error()}
+}
+
+// reportQueryResult is one page of an ad-hoc report with the meta the JSON response
+// carries and the table its exports contain.
+type reportQueryResult struct {
+	meta     map[string]any
+	rows     []map[string]any
+	table    export.Table
+	filename string
+}
+
+// runReportQuery runs an ad-hoc report for POST /api/v1/reports/query and report
+// jobs. Limits outside 1..maxLimit fall back to defaultLimit.
+func runReportQuery(ctx context.Context, store *mysqlstore.Store, deps reportScopeDeps, req runReportRequest, defaultLimit, maxLimit int) (*reportQueryResult, error) {
+	dateFrom, dateTo, err := parseReportDateRange(req.DateFrom, req.DateTo)
+	if err != nil {
+		return nil, badReport(err)
+	}
+
+	limit := req.Limit
+	if limit <= 0 || limit > maxLimit {
+		limit = defaultLimit
+	}
+	offset := req.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	scope, err := mysqlstore.NormalizeReportScope(req.Scope)
+	if err != nil {
+		return nil, badReport(err)
+	}
+	if scope != mysqlstore.ReportScopeTransfer {
+		return runScopedReportQuery(ctx, store, deps, scope, req, dateFrom, dateTo, limit, offset)
+	}
+	columns := mysqlstore.NormalizeTransferReportColumns(req.Columns)
+	groupBy, measures, err := mysqlstore.NormalizeTransferReportGrouping(req.GroupBy, req.Measures)
+	if err != nil {
+		return nil, badReport(err)
+	}
+	sorts, err := mysqlstore.NormalizeTransferReportSort(req.Sort, groupBy, measures)
+	if err != nil {
+		return nil, badReport(err)
+	}
+	filter := strings.TrimSpace(req.Filter)
+	if _, _, err := mysqlstore.CompileTransferReportFilter(filter); err != nil {
+		return nil, badReport(err)
+	}
+	start := time.Now()
+	result, err := store.RunTransferReport(ctx, mysqlstore.TransferReportOptions{
+		DateFrom:   dateFrom,
+		DateTo:     dateTo,
+		Status:     req.Status,
+		CustomerID: req.CustomerID,
+		Limit:      limit,
+		Offset:     offset,
+		Columns:    columns,
+		GroupBy:    groupBy,
+		Measures:   measures,
+		Filter:     filter,
+		Sort:       req.Sort,
+	})
+	recordDBQuery("mcp", "RunTransferReport", time.Since(start).Seconds(), err)
+	recordReportRun(map[bool]string{true: "error", false: "success"}[err != nil], time.Since(start).Seconds())
+	if err != nil {
+		return nil, &reportError{status: nethttp.StatusBadRequest, message: err.Error(), backend: true}
+	}
+	meta := map[string]any{
+		"scope":     scope,
+		"date_from": dateFrom.Format(time.RFC3339),
+		"date_to":   dateTo.Format(time.RFC3339),
+		"status":    strings.TrimSpace(req.Status),
+		"customer":  strings.TrimSpace(req.CustomerID),
+		"limit":     limit,
+		"offset":    offset,
+		"columns":   columns,
+		"total":     result.Total,
+		"count":     len(result.Rows),
+		"filter":    filter,
+		"sort":      sorts,
+	}
+	if len(groupBy) > 0 {
+		meta["columns"] = append(append([]string{}, groupBy...), measures...)
+		meta["group_by"] = groupBy
+		meta["measures"] = measures
+		meta["transfers"] = result.Transfers
+		meta["truncated"] = result.Truncated
+	}
+	return &reportQueryResult{
+		meta:     meta,
+		rows:     result.Rows,
+		table:    reportExportTable(scope, meta["columns"].([]string), result.Rows),
+		filename: reportQueryFilename(scope, dateFrom, dateTo),
+	}, nil
+}
+
+func reportQueryFilename(scope string, dateFrom, dateTo time.Time) string {
+	return fmt.Sprintf("%s-report-%s-%s", scope, dateFrom.Format("20060102"), dateTo.Format("20060102"))
+}
+
+// writeReportError answers a report error; errors without a status are internal.
+func writeReportError(w nethttp.ResponseWriter, err error) {
+	var qe *reportError
+	if errors.As(err, &qe) {
+		writeJSON(w, qe.status, map[string]any{"error": qe.message})
+		return
+	}
+	writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": err.Error()})
+}
@@ -0,0 +1,239 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"math"
+	nethttp "net/http"
+	"strings"
+	"time"
+
+	esstore "go-am-realtime-report-ui/internal/connectors/es"
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+	ssstore "go-am-realtime-report-ui/internal/connectors/ssdb"
+)
+
+// aipReportMaxStatsRows caps the page size of AIP reports with Elasticsearch columns;
+// each row costs one AIP stats scan.
+const aipReportMaxStatsRows = 50
+
+// reportScopeDeps are the backends SIP and AIP reports read besides MCP.
+type reportScopeDeps struct {
+	ssStore    *ssstore.Store
+	esClient   *esstore.Client
+	esIndex    string
+	esPageSize int
+}
+
+// runScopedReportQuery runs runReportQuery for the sip, aip and file scopes.
+func runScopedReportQuery(ctx context.Context, store *mysqlstore.Store, deps reportScopeDeps, scope string, req runReportRequest, dateFrom, dateTo time.Time, limit, offset int) (*reportQueryResult, error) {
+	if len(req.GroupBy) > 0 || len(req.Measures) > 0 {
+		return nil, badReport(errors.New("group_by and measures are only supported for transfer reports"))
+	}
+	if status := strings.ToLower(strings.TrimSpace(req.Status)); status != "" && status != "all" {
+		return nil, badReport(errors.New("status is only supported for transfer reports, use a filter expression such as status = 'FAILED'"))
+	}
+	columns := mysqlstore.NormalizeReportColumns(scope, req.Columns)
+	sorts, err := mysqlstore.NormalizeReportSort(scope, req.Sort)
+	if err != nil {
+		return nil, badReport(err)
+	}
+	filter := strings.TrimSpace(req.Filter)
+	if _, _, err := mysqlstore.CompileReportFilter(scope, filter); err != nil {
+		return nil, badReport(err)
+	}
+
+	late := mysqlstore.ReportLateColumns(scope, columns)
+	if scope == mysqlstore.ReportScopeAIP && len(late) > 0 && limit > aipReportMaxStatsRows {
+		limit = aipReportMaxStatsRows
+	}
+	// Late columns are looked up by package UUID, so query the keys even when they are
+	// not selected and drop them once the columns are filled.
+	var keys []string
+	if len(late) > 0 {
+		switch scope {
+		case mysqlstore.ReportScopeSIP:
+			keys = []string{"sip_uuid"}
+		case mysqlstore.ReportScopeAIP:
+			keys = []string{"aip_uuid", "replica_of"}
+		}
+	}
+	hidden := make([]string, 0, len(keys))
+	for _, key := range keys {
+		selected := false
+		for _, c := range columns {
+			selected = selected || c == key
+		}
+		if !selected {
+			hidden = append(hidden, key)
+		}
+	}
+	queryColumns := append(append([]string{}, columns...), hidden...)
+	opts := mysqlstore.ReportQueryOptions{
+		DateFrom:   dateFrom,
+		DateTo:     dateTo,
+		CustomerID: req.CustomerID,
+		Limit:      limit,
+		Offset:     offset,
+		Columns:    queryColumns,
+		Filter:     filter,
+		Sort:       req.Sort,
+	}
+
+	runStart := time.Now()
+	var result *mysqlstore.ReportQueryResult
+	if scope == mysqlstore.ReportScopeAIP {
+		if deps.ssStore == nil {
+			return nil, &reportError{status: nethttp.StatusServiceUnavailable, message: "storage service database integration disabled (set APP_SS_DB_ENABLED=true)"}
+		}
+		start := time.Now()
+		stored, err := deps.ssStore.ListStoredPackages(ctx, dateTo)
+		recordDBQuery("ssdb", "ListStoredPackages", time.Since(start).Seconds(), err)
+		if err != nil {
+			recordReportRun("error", time.Since(runStart).Seconds())
+			return nil, &reportError{status: nethttp.StatusInternalServerError, message: "failed to list storage service packages", backend: true}
+		}
+		start = time.Now()
+		result, err = store.RunAIPReport(ctx, storagePackagesFromSS(stored), opts)
+		recordDBQuery("mcp", "RunAIPReport", time.Since(start).Seconds(), err)
+	} else {
+		start := time.Now()
+		result, err = store.RunScopedReport(ctx, scope, opts)
+		recordDBQuery("mcp", "RunScopedReport", time.Since(start).Seconds(), err)
+	}
+	if err != nil {
+		recordReportRun("error", time.Since(runStart).Seconds())
+		return nil, &reportError{status: nethttp.StatusBadRequest, message: err.Error(), backend: true}
+	}
+
+	unavailable := make([]string, 0)
+	statsErrors := 0
+	if len(late) > 0 {
+		switch scope {
+		case mysqlstore.ReportScopeSIP:
+			if !fillSIPReportAIPSizes(ctx, deps.ssStore, result.Rows) {
+				unavailable = late
+			}
+		case mysqlstore.ReportScopeAIP:
+			var ok bool
+			ok, statsErrors = fillAIPReportStats(ctx, deps, result.Rows)
+			if !ok {
+				unavailable = late
+			}
+		}
+		for _, row := range result.Rows {
+			for _, key := range hidden {
+				delete(row, key)
+			}
+		}
+	}
+	recordReportRun("success", time.Since(runStart).Seconds())
+
+	meta := map[string]any{
+		"scope":     scope,
+		"date_from": dateFrom.Format(time.RFC3339),
+		"date_to":   dateTo.Format(time.RFC3339),
+		"customer":  strings.TrimSpace(req.CustomerID),
+		"limit":     limit,
+		"offset":    offset,
+		"columns":   columns,
+		"filter":    filter,
+		"sort":      sorts,
+		"total":     result.Total,
+		"count":     len(result.Rows),
+	}
+	if len(unavailable) > 0 {
+		meta["unavailable_columns"] = unavailable
+	}
+	if statsErrors > 0 {
+		meta["stats_errors"] = statsErrors
+	}
+	return &reportQueryResult{
+		meta:     meta,
+		rows:     result.Rows,
+		table:    reportExportTable(scope, columns, result.Rows),
+		filename: reportQueryFilename(scope, dateFrom, dateTo),
+	}, nil
+}
+
+// fillSIPReportAIPSizes sets aip_size_mb from Storage Service AIP sizes. It returns
+// false when the Storage Service is not configured or cannot be read.
+func fillSIPReportAIPSizes(ctx context.Context, ssStore *ssstore.Store, rows []map[string]any) bool {
+	for _, row := range rows {
+		row["aip_size_mb"] = nil
+	}
+	if ssStore == nil || len(rows) == 0 {
+		return ssStore != nil
+	}
+	uuids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if id, _ := row["sip_uuid"].(string); id != "" {
+			uuids = append(uuids, id)
+		}
+	}
+	start := time.Now()
+	packages, err := ssStore.LookupPackagesByUUIDs(ctx, uuids)
+	recordDBQuery("ssdb", "LookupPackagesByUUIDs", time.Since(start).Seconds(), err)
+	if err != nil {
+		return false
+	}
+	sizes := make(map[string]float64, len(packages))
+	for _, p := range packages {
+		if strings.EqualFold(p.PackageType, "AIP") || strings.EqualFold(p.PackageType, "AIC") {
+			sizes[strings.ToLower(p.UUID)] = math.Round(float64(p.SizeBytes)/1024.0/1024.0*100) / 100
+		}
+	}
+	for _, row := range rows {
+		id, _ := row["sip_uuid"].(string)
+		if size, ok := sizes[strings.ToLower(id)]; ok {
+			row["aip_size_mb"] = size
+		}
+	}
+	return true
+}
+
+// fillAIPReportStats sets the Elasticsearch columns from per-AIP stats; replicas use
+// the stats of the AIP they copy. It returns false when Elasticsearch is disabled and
+// the number of AIPs whose stats failed.
+func fillAIPReportStats(ctx context.Context, deps reportScopeDeps, rows []map[string]any) (bool, int) {
+	if deps.esClient == nil || !deps.esClient.Enabled() {
+		return false, 0
+	}
+	failures := 0
+	for _, row := range rows {
+		aipUUID, _ := row["replica_of"].(string)
+		if aipUUID == "" {
+			aipUUID, _ = row["aip_uuid"].(string)
+		}
+		start := time.Now()
+		stats, err := deps.esClient.AIPStats(ctx, deps.esIndex, aipUUID, deps.esPageSize)
+		recordExternalProbe("elasticsearch", "AIPStats", time.Since(start).Seconds(), err)
+		if err != nil || stats == nil {
+			failures++
+			continue
+		}
+		for key, value := range aipStatsReportValues(stats) {
+			if _, selected := row[key]; selected {
+				row[key] = value
+			}
+		}
+	}
+	return true, failures
+}
+
+func aipStatsReportValues(stats *esstore.AIPStats) map[string]any {
+	topFormat := ""
+	if len(stats.FormatNameCounts) > 0 {
+		topFormat = stats.FormatNameCounts[0].Key
+	}
+	return map[string]any{
+		"files_total":                   stats.FilesTotal,
+		"format_count":                  stats.UniqueFormatSignatures,
+		"top_format":                    topFormat,
+		"unknown_formats":               stats.UnknownFormats,
+		"missing_identifiers":           stats.MissingIdentifiers,
+		"extension_format_mismatch":     stats.ExtensionFormatMismatch,
+		"duplicate_filename_candidates": stats.DuplicateFilenameCandidates,
+		"originals_without_normalized":  stats.OriginalsWithoutNormalized,
+	}
+}
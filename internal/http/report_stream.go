@@ -0,0 +1,162 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"strings"
+	"time"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+	"go-am-realtime-report-ui/internal/export"
+)
+
+// streamFlushRows is how many rows a report stream buffers before flushing them to
+// the client.
+const streamFlushRows = 500
+
+// negotiateStreamFormat picks csv or ndjson from ?format= or the Accept header,
+// defaulting to ndjson.
+func negotiateStreamFormat(r *nethttp.Request) (string, error) {
+	if raw := strings.TrimSpace(r.URL.Query().Get("format")); raw != "" {
+		return export.ParseStreamFormat(raw)
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		if format, ok := export.StreamFormatFromMediaType(mediaType); ok {
+			return format, nil
+		}
+	}
+	return export.FormatNDJSON, nil
+}
+
+// streamReportQuery streams every row of an ungrouped transfer report as CSV or
+// NDJSON. limit and offset are ignored. Errors before the first row are answered
+// as JSON; later failures abort the response so a truncated export cannot be
+// mistaken for a complete one.
+func streamReportQuery(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store) {
+	format, err := negotiateStreamFormat(r)
+	if err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	var req runReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
+		return
+	}
+	dateFrom, dateTo, err := parseReportDateRange(req.DateFrom, req.DateTo)
+	if err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	scope, err := mysqlstore.NormalizeReportScope(req.Scope)
+	if err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	if scope != mysqlstore.ReportScopeTransfer {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "streaming exports support the transfer scope only"})
+		return
+	}
+	if len(req.GroupBy) > 0 {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "group_by is not supported by streaming exports, use /api/v1/reports/query"})
+		return
+	}
+	if _, err := mysqlstore.NormalizeTransferReportSort(req.Sort, nil, nil); err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	filter := strings.TrimSpace(req.Filter)
+	if _, _, err := mysqlstore.CompileTransferReportFilter(filter); err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	columns := mysqlstore.NormalizeTransferReportColumns(req.Columns)
+	table := reportExportTable(scope, columns, nil)
+
+	// The server write timeout is sized for regular responses; give the stream as
+	// long as its query may run.
+	rc := nethttp.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Now().Add(store.ExportTimeout() + 30*time.Second))
+
+	var rw export.RowWriter
+	begin := func() error {
+		w.Header().Set("Content-Type", export.ContentType(format))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s-report-%s-%s.%s", scope, dateFrom.Format("20060102"), dateTo.Format("20060102"), format)))
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(nethttp.StatusOK)
+		var err error
+		rw, err = export.NewRowWriter(w, format, table.Columns)
+		return err
+	}
+	flush := func() error {
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, nethttp.ErrNotSupported) {
+			return err
+		}
+		return nil
+	}
+
+	values := make([]any, len(table.Columns))
+	written := 0
+	start := time.Now()
+	n, err := store.StreamTransferReport(r.Context(), mysqlstore.TransferReportOptions{
+		DateFrom:   dateFrom,
+		DateTo:     dateTo,
+		Status:     req.Status,
+		CustomerID: req.CustomerID,
+		Columns:    columns,
+		Filter:     filter,
+		Sort:       req.Sort,
+	}, func(row map[string]any) error {
+		if rw == nil {
+			if err := begin(); err != nil {
+				return err
+			}
+		}
+		for i, c := range table.Columns {
+			values[i] = row[c.Key]
+		}
+		if err := rw.WriteRow(values); err != nil {
+			return err
+		}
+		written++
+		if written%streamFlushRows == 0 {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil && rw == nil {
+		err = begin()
+	}
+	if err == nil {
+		err = flush()
+	}
+	recordDBQuery("mcp", "StreamTransferReport", time.Since(start).Seconds(), err)
+
+	switch {
+	case err == nil:
+		recordReportRun("success", time.Since(start).Seconds())
+	case r.Context().Err() != nil:
+		// The client went away; there is nobody left to answer.
+		recordReportRun("canceled", time.Since(start).Seconds())
+	case rw == nil && errors.Is(err, mysqlstore.ErrReportStreamBusy):
+		recordReportRun("error", time.Since(start).Seconds())
+		w.Header().Set("Retry-After", "30")
+		writeJSON(w, nethttp.StatusTooManyRequests, map[string]any{"error": err.Error()})
+	case rw == nil:
+		// Requests are validated before the query runs; anything left is a backend
+		// failure whose details stay in the log.
+		recordReportRun("error", time.Since(start).Seconds())
+		fmt.Printf("report stream failed before the first row: %v\n", err)
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to run report"})
+	default:
+		recordReportRun("error", time.Since(start).Seconds())
+		fmt.Printf("report stream aborted after %d rows: %v\n", n, err)
+		panic(nethttp.ErrAbortHandler)
+	}
+}
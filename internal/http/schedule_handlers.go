@@ -0,0 +1,217 @@
+package http
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+	"go-am-realtime-report-ui/internal/jobs"
+	"go-am-realtime-report-ui/internal/schedule"
+)
+
+type saveReportScheduleRequest struct {
+	TemplateID int64          `json:"template_id"`
+	Name       string         `json:"name"`
+	Cron       string         `json:"cron"`
+	Timezone   string         `json:"timezone"`
+	Range      string         `json:"range"`
+	Format     string         `json:"format"`
+	Delivery   string         `json:"delivery"`
+	Target     string         `json:"target"`
+	Params     map[string]any `json:"params"`
+	Enabled    *bool          `json:"enabled"`
+}
+
+func (req saveReportScheduleRequest) toSchedule(id int64) customermap.ReportSchedule {
+	params := "{}"
+	if len(req.Params) > 0 {
+		raw, _ := json.Marshal(req.Params)
+		params = string(raw)
+	}
+	return customermap.ReportSchedule{
+		ID:         id,
+		TemplateID: req.TemplateID,
+		Name:       req.Name,
+		Cron:       req.Cron,
+		Timezone:   req.Timezone,
+		Range:      req.Range,
+		Format:     req.Format,
+		Delivery:   req.Delivery,
+		Target:     req.Target,
+		ParamsJSON: params,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+	}
+}
+
+// reportSchedulesRouter serves /api/v1/reports/schedules and
+// /api/v1/reports/schedules/{id}[/run|/runs].
+func reportSchedulesRouter(defaultLimit int, store *mysqlstore.Store, scheduler *schedule.Scheduler) nethttp.HandlerFunc {
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if store == nil {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{"error": "database integration disabled (set APP_DB_ENABLED=true)"})
+			return
+		}
+		if scheduler == nil {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{
+				"error": "template sqlite store not available",
+				"hint":  "set APP_CUSTOMER_MAP_SQLITE_PATH to enable report schedules",
+			})
+			return
+		}
+
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/reports/schedules"), "/"), "/")
+		if parts[0] == "" {
+			switch r.Method {
+			case nethttp.MethodGet:
+				limit := parseLimit(r, defaultLimit)
+				start := time.Now()
+				items, err := scheduler.List(r.Context(), limit)
+				recordDBQuery("appsqlite", "ListReportSchedules", time.Since(start).Seconds(), err)
+				if err != nil {
+					writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to list report schedules"})
+					return
+				}
+				writeJSON(w, nethttp.StatusOK, map[string]any{
+					"meta": map[string]any{"count": len(items), "limit": limit},
+					"data": items,
+				})
+			case nethttp.MethodPost:
+				saveReportSchedule(w, r, store, scheduler, 0)
+			default:
+				writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			}
+			return
+		}
+		if len(parts) > 2 {
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "not found"})
+			return
+		}
+		id, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || id <= 0 {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid report schedule id"})
+			return
+		}
+		action := ""
+		if len(parts) == 2 {
+			action = parts[1]
+		}
+
+		switch {
+		case action == "" && r.Method == nethttp.MethodGet:
+			start := time.Now()
+			item, err := scheduler.Get(r.Context(), id)
+			recordDBQuery("appsqlite", "GetReportSchedule", time.Since(start).Seconds(), err)
+			if err != nil {
+				writeScheduleError(w, err)
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{"data": item})
+		case action == "" && r.Method == nethttp.MethodPut:
+			saveReportSchedule(w, r, store, scheduler, id)
+		case action == "" && r.Method == nethttp.MethodDelete:
+			start := time.Now()
+			deleted, err := scheduler.Delete(r.Context(), id)
+			recordDBQuery("appsqlite", "DeleteReportSchedule", time.Since(start).Seconds(), err)
+			if err != nil {
+				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to delete report schedule"})
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{"meta": map[string]any{"deleted": deleted, "id": id}})
+		case action == "run" && r.Method == nethttp.MethodPost:
+			run, err := scheduler.RunNow(r.Context(), id)
+			if errors.Is(err, jobs.ErrQueueFull) {
+				w.Header().Set("Retry-After", "30")
+				writeJSON(w, nethttp.StatusTooManyRequests, map[string]any{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				writeScheduleError(w, err)
+				return
+			}
+			if run.JobID != "" {
+				w.Header().Set("Location", "/api/v1/jobs/"+run.JobID)
+			}
+			writeJSON(w, nethttp.StatusAccepted, map[string]any{"data": run})
+		case action == "runs" && r.Method == nethttp.MethodGet:
+			limit := parseLimit(r, defaultLimit)
+			start := time.Now()
+			runs, err := scheduler.Runs(r.Context(), id, limit)
+			recordDBQuery("appsqlite", "ListReportScheduleRuns", time.Since(start).Seconds(), err)
+			if err != nil {
+				writeScheduleError(w, err)
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{
+				"meta": map[string]any{"schedule_id": id, "count": len(runs), "limit": limit},
+				"data": runs,
+			})
+		case action == "" || action == "run" || action == "runs":
+			writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		default:
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "not found"})
+		}
+	}
+}
+
+func saveReportSchedule(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store, scheduler *schedule.Scheduler, id int64) {
+	var req saveReportScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
+		return
+	}
+	item := req.toSchedule(id)
+	if err := scheduler.Normalize(&item); err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	start := time.Now()
+	tpl, err := store.GetReportTemplate(r.Context(), item.TemplateID)
+	recordDBQuery("appsqlite", "GetReportTemplate", time.Since(start).Seconds(), err)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": fmt.Sprintf("report template %d not found", item.TemplateID)})
+		return
+	}
+	if err != nil {
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to load report template"})
+		return
+	}
+	// Check the parameter values now rather than on every run; the fiscal year
+	// start does not matter for that.
+	if _, _, err := mysqlstore.ResolveReportTemplateConfig(*tpl, req.Params, time.Now(), time.UTC, 1); err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	start = time.Now()
+	saved, err := scheduler.Save(r.Context(), item)
+	recordDBQuery("appsqlite", "SaveReportSchedule", time.Since(start).Seconds(), err)
+	if err != nil {
+		switch {
+		case errors.Is(err, schedule.ErrNotFound):
+			writeScheduleError(w, err)
+		case strings.Contains(strings.ToLower(err.Error()), "unique"):
+			writeJSON(w, nethttp.StatusConflict, map[string]any{"error": "a report schedule with this name already exists"})
+		default:
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		}
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, map[string]any{
+		"meta": map[string]any{"saved": true},
+		"data": saved,
+	})
+}
+
+func writeScheduleError(w nethttp.ResponseWriter, err error) {
+	if errors.Is(err, schedule.ErrNotFound) {
+		writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "report schedule not found"})
+		return
+	}
+	writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": err.Error()})
+}
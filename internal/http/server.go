@@ -13,6 +13,9 @@ import (
 	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
 	promstore "go-am-realtime-report-ui/internal/connectors/prometheus"
 	ssstore "go-am-realtime-report-ui/internal/connectors/ssdb"
+	"go-am-realtime-report-ui/internal/export"
+	"go-am-realtime-report-ui/internal/jobs"
+	"go-am-realtime-report-ui/internal/schedule"
 )
 
 // Server wraps an HTTP server and route handlers.
@@ -27,6 +30,8 @@ type Server struct {
 		interval    time.Duration
 	}
 	promCancel context.CancelFunc
+	jobs       *jobs.Manager
+	schedules  *schedule.Scheduler
 }
 
 // NewServer creates a configured HTTP server with v1 endpoints.
@@ -56,6 +61,50 @@ func NewServer(cfg config.Config) (*Server, error) {
 		esClient = esstore.NewClient(cfg.ESEndpoint, cfg.ESTimeout)
 	}
 
+	// Jobs survive restarts when the app SQLite store is configured.
+	var jobStore jobs.Store = jobs.NewMemoryStore()
+	appStore := store.AppStore()
+	if appStore != nil {
+		jobStore = jobs.NewSQLiteStore(appStore)
+	}
+	jobManager := jobs.NewManager(jobStore, jobs.Config{
+		Workers:     cfg.JobWorkers,
+		QueueSize:   cfg.JobQueueSize,
+		MaxAttempts: cfg.JobMaxAttempts,
+		RetryDelay:  cfg.JobRetryDelay,
+		Timeout:     cfg.JobTimeout,
+		ResultTTL:   cfg.JobResultTTL,
+	})
+	reportDeps := reportScopeDeps{ssStore: storageStore, esClient: esClient, esIndex: cfg.ESAIPIndex, esPageSize: cfg.ESAIPPageSize}
+	registerReportJobs(jobManager, reportJobDeps{
+		store:             store,
+		scope:             reportDeps,
+		brand:             export.Brand{Name: cfg.ReportBrandName, Color: cfg.ReportBrandColor},
+		defaultCustomerID: cfg.DefaultCustomerReport,
+		fiscalStartMonth:  cfg.FiscalYearStartMonth,
+		defaultLimit:      cfg.DefaultRunningLimit,
+		maxRows:           cfg.JobMaxRows,
+		queryTimeout:      cfg.DBExportTimeout,
+	})
+	// Schedules are stored next to their templates, so they need the app SQLite store.
+	var scheduler *schedule.Scheduler
+	if appStore != nil {
+		scheduler = schedule.New(appStore, jobManager, schedule.Config{
+			Tick:             cfg.ReportScheduleTick,
+			MaxCatchUp:       cfg.ReportScheduleMaxCatchUp,
+			FiscalStartMonth: cfg.FiscalYearStartMonth,
+			Delivery: schedule.DeliveryConfig{
+				Dir:            cfg.ReportDeliveryDir,
+				WebhookTimeout: cfg.ReportWebhookTimeout,
+				SMTPHost:       cfg.SMTPHost,
+				SMTPPort:       cfg.SMTPPort,
+				SMTPUser:       cfg.SMTPUser,
+				SMTPPassword:   cfg.SMTPPassword,
+				SMTPFrom:       cfg.SMTPFrom,
+			},
+		})
+	}
+
 	mux := nethttp.NewServeMux()
 
 	mux.HandleFunc("/", dashboardHandler)
@@ -66,22 +115,45 @@ func NewServer(cfg config.Config) (*Server, error) {
 	mux.HandleFunc("/ready", readyHandler)
 	mux.HandleFunc("/api/v1/transfers/running", runningTransfersHandler(cfg.DefaultRunningLimit, store))
 	mux.HandleFunc("/api/v1/sips/running", runningSIPsHandler(cfg.DefaultRunningLimit, store))
+	mux.HandleFunc("/api/v1/sips/", sipDetailRouter(cfg.DefaultRunningLimit, store, storageStore, esClient, cfg.ESLookupLimit))
 	mux.HandleFunc("/api/v1/transfers/completed", completedTransfersHandler(cfg.DefaultRunningLimit, store))
 	mux.HandleFunc("/api/v1/transfers/", transferDetailRouter(cfg.DefaultRunningLimit, store, storageStore, esClient, cfg.ESLookupLimit))
+	mux.HandleFunc("/api/v1/lineage/", lineageHandler(store, storageStore, esClient, cfg.ESLookupLimit))
 	mux.HandleFunc("/api/v1/troubleshooting/stalled", stalledTransfersHandler(cfg.DefaultRunningLimit, store))
 	mux.HandleFunc("/api/v1/troubleshooting/hotspots", errorHotspotsHandler(cfg.DefaultRunningLimit, store))
 	mux.HandleFunc("/api/v1/troubleshooting/failure-counts", failureCountsHandler(store))
 	mux.HandleFunc("/api/v1/transfers/failed", failedTransfersHandler(cfg.DefaultRunningLimit, store))
 	mux.HandleFunc("/api/v1/troubleshooting/failure-signatures", failureSignaturesHandler(cfg.DefaultRunningLimit, store))
-	mux.HandleFunc("/api/v1/reports/monthly", monthlyReportHandler(cfg.DefaultCustomerReport, store, storageStore))
-	mux.HandleFunc("/api/v1/reports/customers", reportRoutesRouter(cfg.DefaultRunningLimit, store))
-	mux.HandleFunc("/api/v1/reports/query", reportRoutesRouter(cfg.DefaultRunningLimit, store))
-	mux.HandleFunc("/api/v1/reports/query/options", reportRoutesRouter(cfg.DefaultRunningLimit, store))
-	mux.HandleFunc("/api/v1/reports/templates", reportRoutesRouter(cfg.DefaultRunningLimit, store))
-	mux.HandleFunc("/api/v1/reports/templates/", reportRoutesRouter(cfg.DefaultRunningLimit, store))
-	mux.HandleFunc("/api/v1/reports/customer-mappings", reportRoutesRouter(cfg.DefaultRunningLimit, store))
-	mux.HandleFunc("/api/v1/reports/customer-mappings/", reportRoutesRouter(cfg.DefaultRunningLimit, store))
-	mux.HandleFunc("/api/v1/charts/transfer-durations", transferDurationChartHandler(cfg.DefaultCustomerReport, store))
+	mux.HandleFunc("/api/v1/troubleshooting/knowledge", failureKnowledgeRouter(cfg.DefaultRunningLimit, store))
+	mux.HandleFunc("/api/v1/troubleshooting/knowledge/", failureKnowledgeRouter(cfg.DefaultRunningLimit, store))
+	mux.HandleFunc("/api/v1/reports/monthly", monthlyReportHandler(cfg.DefaultCustomerReport, cfg.FiscalYearStartMonth, store, storageStore, export.Brand{Name: cfg.ReportBrandName, Color: cfg.ReportBrandColor}))
+	mux.HandleFunc("/api/v1/reports/formats", formatAnalyticsHandler(cfg.DefaultRunningLimit, store))
+	mux.HandleFunc("/api/v1/reports/forecast", backlogForecastHandler(store))
+	mux.HandleFunc("/api/v1/reports/storage", storageReportHandler(cfg.DefaultCustomerReport, cfg.FiscalYearStartMonth, store, storageStore))
+	mux.HandleFunc("/api/v1/reports/sla", slaComplianceHandler(cfg.DefaultCustomerReport, cfg.DefaultRunningLimit, cfg.FiscalYearStartMonth, store))
+	mux.HandleFunc("/api/v1/reports/sla-policies", slaPoliciesRouter(cfg.DefaultRunningLimit, store))
+	mux.HandleFunc("/api/v1/reports/sla-policies/", slaPoliciesRouter(cfg.DefaultRunningLimit, store))
+	mux.HandleFunc("/api/v1/reports/billing/", billingRouter(cfg.DefaultRunningLimit, cfg.FiscalYearStartMonth, store, storageStore))
+	mux.HandleFunc("/api/v1/reports/customers", reportRoutesRouter(cfg.DefaultRunningLimit, store, storageStore, esClient, cfg.ESAIPIndex, cfg.ESAIPPageSize))
+	mux.HandleFunc("/api/v1/reports/customers/", reportRoutesRouter(cfg.DefaultRunningLimit, store, storageStore, esClient, cfg.ESAIPIndex, cfg.ESAIPPageSize))
+	mux.HandleFunc("/api/v1/reports/query", reportRoutesRouter(cfg.DefaultRunningLimit, store, storageStore, esClient, cfg.ESAIPIndex, cfg.ESAIPPageSize))
+	mux.HandleFunc("/api/v1/reports/query/options", reportRoutesRouter(cfg.DefaultRunningLimit, store, storageStore, esClient, cfg.ESAIPIndex, cfg.ESAIPPageSize))
+	mux.HandleFunc("/api/v1/reports/templates", reportTemplatesRouter(cfg.DefaultRunningLimit, cfg.FiscalYearStartMonth, store, reportDeps))
+	mux.HandleFunc("/api/v1/reports/templates/", reportTemplatesRouter(cfg.DefaultRunningLimit, cfg.FiscalYearStartMonth, store, reportDeps))
+	mux.HandleFunc("/api/v1/reports/snapshots", reportSnapshotsRouter(cfg.DefaultCustomerReport, cfg.DefaultRunningLimit, cfg.FiscalYearStartMonth, cfg.AppVersion, store, export.Brand{Name: cfg.ReportBrandName, Color: cfg.ReportBrandColor}))
+	mux.HandleFunc("/api/v1/reports/snapshots/", reportSnapshotsRouter(cfg.DefaultCustomerReport, cfg.DefaultRunningLimit, cfg.FiscalYearStartMonth, cfg.AppVersion, store, export.Brand{Name: cfg.ReportBrandName, Color: cfg.ReportBrandColor}))
+	mux.HandleFunc("/api/v1/reports/schedules", reportSchedulesRouter(cfg.DefaultRunningLimit, store, scheduler))
+	mux.HandleFunc("/api/v1/reports/schedules/", reportSchedulesRouter(cfg.DefaultRunningLimit, store, scheduler))
+	mux.HandleFunc("/api/v1/reports/customer-mappings", reportRoutesRouter(cfg.DefaultRunningLimit, store, storageStore, esClient, cfg.ESAIPIndex, cfg.ESAIPPageSize))
+	mux.HandleFunc("/api/v1/reports/customer-mappings/", reportRoutesRouter(cfg.DefaultRunningLimit, store, storageStore, esClient, cfg.ESAIPIndex, cfg.ESAIPPageSize))
+	mux.HandleFunc("/api/v1/reports/customer-mapping-rules", customerMappingRulesRouter(store))
+	mux.HandleFunc("/api/v1/reports/customer-mapping-rules/", customerMappingRulesRouter(store))
+	mux.HandleFunc("/api/v1/reports/unmapped-sources", unmappedSourcesRouter(cfg.DefaultRunningLimit, store))
+	mux.HandleFunc("/api/v1/reports/unmapped-sources/", unmappedSourcesRouter(cfg.DefaultRunningLimit, store))
+	mux.HandleFunc("/api/v1/jobs", jobsRouter(cfg.DefaultRunningLimit, jobManager))
+	mux.HandleFunc("/api/v1/jobs/", jobsRouter(cfg.DefaultRunningLimit, jobManager))
+	mux.HandleFunc("/api/v1/charts/transfer-durations", transferDurationChartHandler(cfg.DefaultCustomerReport, cfg.FiscalYearStartMonth, store))
+	mux.HandleFunc("/api/v1/charts/worker-utilization", workerUtilizationHandler(cfg.MCPClientWorkers, store))
 	mux.HandleFunc("/api/v1/metrics/prometheus/live", promLiveMetricsHandler(promScraper, cfg.PromMatchPrefix))
 	mux.HandleFunc("/api/v1/charts/prometheus", promChartHandler(promScraper, cfg.PromMatchPrefix))
 	mux.HandleFunc("/api/v1/status/services", servicesStatusHandler(store, storageStore, esClient, promScraper))
@@ -97,14 +169,20 @@ func NewServer(cfg config.Config) (*Server, error) {
 		WriteTimeout: cfg.WriteTimeout,
 	}
 
-	s := &Server{httpServer: httpServer, mysqlStore: store, ssStore: storageStore, esStore: esClient, promStore: promScraper}
+	s := &Server{httpServer: httpServer, mysqlStore: store, ssStore: storageStore, esStore: esClient, promStore: promScraper, jobs: jobManager, schedules: scheduler}
 	s.promConfig.matchPrefix = cfg.PromMatchPrefix
 	s.promConfig.interval = cfg.PromScrapeInterval
 	return s, nil
 }
 
-// ListenAndServe starts the HTTP server.
+// ListenAndServe starts the job workers, the report scheduler and the HTTP server.
 func (s *Server) ListenAndServe() error {
+	if err := s.jobs.Start(); err != nil {
+		return fmt.Errorf("start report jobs: %w", err)
+	}
+	if s.schedules != nil {
+		s.schedules.Start()
+	}
 	if s.promStore != nil && s.promStore.Enabled() {
 		ctx, cancel := context.WithCancel(context.Background())
 		s.promCancel = cancel
@@ -118,6 +196,11 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	if s.promCancel != nil {
 		s.promCancel()
 	}
+	// Running jobs are interrupted and stay queued for the next start.
+	if s.schedules != nil {
+		s.schedules.Stop()
+	}
+	s.jobs.Stop()
 	if s.mysqlStore != nil {
 		_ = s.mysqlStore.Close()
 	}
@@ -0,0 +1,30 @@
+package http
+
+import (
+	"context"
+	nethttp "net/http"
+
+	esstore "go-am-realtime-report-ui/internal/connectors/es"
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+	ssstore "go-am-realtime-report-ui/internal/connectors/ssdb"
+)
+
+func sipDetailRouter(defaultLimit int, store *mysqlstore.Store, ssStore *ssstore.Store, esClient *esstore.Client, esLookupLimit int) nethttp.HandlerFunc {
+	return unitDetailRouter(unitDetail{
+		prefix: "/api/v1/sips/",
+		name:   "sip",
+		op:     "SIP",
+		summary: func(ctx context.Context, uuid string) (any, error) {
+			return store.GetSIPSummary(ctx, uuid)
+		},
+		timeline:          store.GetSIPTimeline,
+		errors:            store.GetSIPErrors,
+		performance:       store.GetSIPPerformance,
+		performanceAction: true,
+		addDetails: func(summary any, payload, meta map[string]any) {
+			transfers := summary.(*mysqlstore.SIPSummary).Transfers
+			payload["transfers"] = transfers
+			meta["transfer_count"] = len(transfers)
+		},
+	}, defaultLimit, store, ssStore, esClient, esLookupLimit)
+}
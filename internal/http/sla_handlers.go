@@ -0,0 +1,201 @@
+package http
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+	"go-am-realtime-report-ui/internal/export"
+)
+
+type saveSLAPolicyRequest struct {
+	CustomerID     string   `json:"customer_id"`
+	Name           string   `json:"name"`
+	StartMilestone string   `json:"start_milestone"`
+	EndMilestone   string   `json:"end_milestone"`
+	TargetSeconds  int64    `json:"target_seconds"`
+	TargetHours    float64  `json:"target_hours"`
+	TargetPercent  float64  `json:"target_percent"`
+	Exclusions     []string `json:"exclusions"`
+}
+
+func (req saveSLAPolicyRequest) toPolicy(id int64) mysqlstore.SLAPolicy {
+	target := req.TargetSeconds
+	if target == 0 && req.TargetHours > 0 {
+		target = int64(req.TargetHours * 3600)
+	}
+	return mysqlstore.SLAPolicy{
+		ID:             id,
+		CustomerID:     req.CustomerID,
+		Name:           req.Name,
+		StartMilestone: req.StartMilestone,
+		EndMilestone:   req.EndMilestone,
+		TargetSeconds:  target,
+		TargetPercent:  req.TargetPercent,
+		Exclusions:     req.Exclusions,
+	}
+}
+
+func slaPoliciesRouter(defaultLimit int, store *mysqlstore.Store) nethttp.HandlerFunc {
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if store == nil {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{"error": "database integration disabled (set APP_DB_ENABLED=true)"})
+			return
+		}
+		if !store.HasTemplateStore() {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{
+				"error": "template sqlite store not available",
+				"hint":  "set APP_CUSTOMER_MAP_SQLITE_PATH to enable app-owned SLA policy persistence",
+			})
+			return
+		}
+
+		idRaw := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/reports/sla-policies"), "/")
+		if idRaw == "" {
+			switch r.Method {
+			case nethttp.MethodGet:
+				limit := parseLimit(r, defaultLimit)
+				start := time.Now()
+				items, err := store.ListSLAPolicies(r.Context(), limit)
+				recordDBQuery("appsqlite", "ListSLAPolicies", time.Since(start).Seconds(), err)
+				if err != nil {
+					writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to list SLA policies"})
+					return
+				}
+				writeJSON(w, nethttp.StatusOK, map[string]any{
+					"meta": map[string]any{"count": len(items), "limit": limit},
+					"data": items,
+				})
+			case nethttp.MethodPost:
+				saveSLAPolicy(w, r, store, 0)
+			default:
+				writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			}
+			return
+		}
+
+		id, err := strconv.ParseInt(idRaw, 10, 64)
+		if err != nil || id <= 0 {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid SLA policy id"})
+			return
+		}
+		switch r.Method {
+		case nethttp.MethodGet:
+			start := time.Now()
+			item, err := store.GetSLAPolicy(r.Context(), id)
+			recordDBQuery("appsqlite", "GetSLAPolicy", time.Since(start).Seconds(), err)
+			if err != nil {
+				writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "SLA policy not found"})
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{"data": item})
+		case nethttp.MethodPut:
+			saveSLAPolicy(w, r, store, id)
+		case nethttp.MethodDelete:
+			start := time.Now()
+			deleted, err := store.DeleteSLAPolicy(r.Context(), id)
+			recordDBQuery("appsqlite", "DeleteSLAPolicy", time.Since(start).Seconds(), err)
+			if err != nil {
+				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to delete SLA policy"})
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{
+				"meta": map[string]any{"deleted": deleted, "id": id},
+			})
+		default:
+			writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		}
+	}
+}
+
+func saveSLAPolicy(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store, id int64) {
+	var req saveSLAPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
+		return
+	}
+	start := time.Now()
+	savedID, err := store.SaveSLAPolicy(r.Context(), req.toPolicy(id))
+	recordDBQuery("appsqlite", "SaveSLAPolicy", time.Since(start).Seconds(), err)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "SLA policy not found"})
+		case strings.Contains(strings.ToLower(err.Error()), "unique"):
+			writeJSON(w, nethttp.StatusConflict, map[string]any{"error": "an SLA policy already exists for this customer"})
+		default:
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		}
+		return
+	}
+	startGet := time.Now()
+	item, err := store.GetSLAPolicy(r.Context(), savedID)
+	recordDBQuery("appsqlite", "GetSLAPolicy", time.Since(startGet).Seconds(), err)
+	if err != nil {
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "SLA policy saved but failed to read it back"})
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, map[string]any{
+		"meta": map[string]any{"saved": true},
+		"data": item,
+	})
+}
+
+func slaComplianceHandler(defaultCustomerID string, defaultLimit, fiscalStartMonth int, store *mysqlstore.Store) nethttp.HandlerFunc {
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if store == nil {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{"error": "database integration disabled (set APP_DB_ENABLED=true)"})
+			return
+		}
+		if r.Method != nethttp.MethodGet {
+			writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			return
+		}
+
+		format, ok := tableExportFormat(w, r)
+		if !ok {
+			return
+		}
+		customerID := strings.TrimSpace(r.URL.Query().Get("customer_id"))
+		if customerID == "" {
+			customerID = defaultCustomerID
+		}
+		period, err := parseReportPeriod(r.URL.Query(), fiscalStartMonth, time.Now())
+		if err != nil {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		limit := parseLimit(r, defaultLimit)
+
+		start := time.Now()
+		report, err := store.GetSLACompliance(r.Context(), customerID, period, limit)
+		recordDBQuery("mcp", "GetSLACompliance", time.Since(start).Seconds(), err)
+		recordReportRun(map[bool]string{true: "error", false: "success"}[err != nil], time.Since(start).Seconds())
+		if err != nil {
+			writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to build SLA compliance report"})
+			return
+		}
+
+		if format != export.FormatJSON {
+			writeExport(w, format, fmt.Sprintf("sla-breaches-%s-%s", report.Month, customerID), slaBreachesExportTable(report.Breaches))
+			return
+		}
+
+		writeJSON(w, nethttp.StatusOK, map[string]any{
+			"meta": map[string]any{
+				"customer_id":          customerID,
+				"month":                report.Month,
+				"period":               period,
+				"breach_limit":         limit,
+				"customer_filter_mode": store.CustomerMappingMode(),
+			},
+			"data": report,
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package http
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+	"go-am-realtime-report-ui/internal/export"
+)
+
+// createReportSnapshotRequest selects the report to freeze with the period
+// parameters of /api/v1/reports/monthly.
+type createReportSnapshotRequest struct {
+	CustomerID string `json:"customer_id"`
+	Period     string `json:"period"`
+	Month      string `json:"month"`
+	Date       string `json:"date"`
+	DateFrom   string `json:"date_from"`
+	DateTo     string `json:"date_to"`
+	Timezone   string `json:"tz"`
+	Note       string `json:"note"`
+	Official   bool   `json:"official"`
+}
+
+func (req createReportSnapshotRequest) periodValues() url.Values {
+	q := url.Values{}
+	for k, v := range map[string]string{"period": req.Period, "month": req.Month, "date": req.Date, "date_from": req.DateFrom, "date_to": req.DateTo, "tz": req.Timezone} {
+		if v = strings.TrimSpace(v); v != "" {
+			q.Set(k, v)
+		}
+	}
+	return q
+}
+
+// reportSnapshotsRouter serves /api/v1/reports/snapshots and
+// /api/v1/reports/snapshots/{id}[/diff|/official].
+func reportSnapshotsRouter(defaultCustomerID string, defaultLimit, fiscalStartMonth int, appVersion string, store *mysqlstore.Store, brand export.Brand) nethttp.HandlerFunc {
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if store == nil {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{"error": "database integration disabled (set APP_DB_ENABLED=true)"})
+			return
+		}
+		if !store.HasTemplateStore() {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{
+				"error": "template sqlite store not available",
+				"hint":  "set APP_CUSTOMER_MAP_SQLITE_PATH to enable report snapshots",
+			})
+			return
+		}
+
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/reports/snapshots"), "/"), "/")
+		if parts[0] == "" {
+			switch r.Method {
+			case nethttp.MethodGet:
+				q := r.URL.Query()
+				limit := parseLimit(r, defaultLimit)
+				officialOnly, _ := strconv.ParseBool(q.Get("official"))
+				start := time.Now()
+				items, err := store.ListReportSnapshots(r.Context(), q.Get("customer_id"), q.Get("label"), officialOnly, limit)
+				recordDBQuery("appsqlite", "ListReportSnapshots", time.Since(start).Seconds(), err)
+				if err != nil {
+					writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to list report snapshots"})
+					return
+				}
+				writeJSON(w, nethttp.StatusOK, map[string]any{
+					"meta": map[string]any{"count": len(items), "limit": limit},
+					"data": items,
+				})
+			case nethttp.MethodPost:
+				createReportSnapshot(w, r, store, defaultCustomerID, fiscalStartMonth, appVersion)
+			default:
+				writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			}
+			return
+		}
+		if len(parts) > 2 {
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "not found"})
+			return
+		}
+		id, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || id <= 0 {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid report snapshot id"})
+			return
+		}
+		action := ""
+		if len(parts) == 2 {
+			action = parts[1]
+		}
+
+		switch {
+		case action == "" && r.Method == nethttp.MethodGet:
+			getReportSnapshot(w, r, store, id, brand)
+		case action == "diff" && r.Method == nethttp.MethodGet:
+			start := time.Now()
+			diff, err := store.DiffReportSnapshot(r.Context(), id, appVersion)
+			recordDBQuery("mcp", "DiffReportSnapshot", time.Since(start).Seconds(), err)
+			if err != nil {
+				writeSnapshotError(w, err, "failed to recompute report")
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{"data": diff})
+		case action == "official" && (r.Method == nethttp.MethodPut || r.Method == nethttp.MethodDelete):
+			start := time.Now()
+			item, err := store.SetReportSnapshotOfficial(r.Context(), id, r.Method == nethttp.MethodPut)
+			recordDBQuery("appsqlite", "SetReportSnapshotOfficial", time.Since(start).Seconds(), err)
+			if err != nil {
+				writeSnapshotError(w, err, "failed to update report snapshot")
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{"data": item})
+		case action == "" || action == "diff" || action == "official":
+			writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		default:
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "not found"})
+		}
+	}
+}
+
+func createReportSnapshot(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store, defaultCustomerID string, fiscalStartMonth int, appVersion string) {
+	var req createReportSnapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
+		return
+	}
+	customerID := strings.TrimSpace(req.CustomerID)
+	if customerID == "" {
+		customerID = defaultCustomerID
+	}
+	period, err := parseReportPeriod(req.periodValues(), fiscalStartMonth, time.Now())
+	if err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	if period.End.After(time.Now()) {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": fmt.Sprintf("period %s has not ended yet", period.Label)})
+		return
+	}
+	start := time.Now()
+	item, created, err := store.CreateReportSnapshot(r.Context(), customerID, period, appVersion, req.Note, req.Official)
+	recordDBQuery("mcp", "CreateReportSnapshot", time.Since(start).Seconds(), err)
+	if err != nil {
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to create report snapshot"})
+		return
+	}
+	status := nethttp.StatusOK
+	if created {
+		status = nethttp.StatusCreated
+	}
+	writeJSON(w, status, map[string]any{
+		"meta": map[string]any{"created": created},
+		"data": item,
+	})
+}
+
+// getReportSnapshot serves a snapshot as JSON or, with ?format=, as the monthly
+// report export of the frozen numbers.
+func getReportSnapshot(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store, id int64, brand export.Brand) {
+	format, err := negotiateExportFormat(r)
+	if err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	start := time.Now()
+	item, err := store.GetReportSnapshot(r.Context(), id)
+	recordDBQuery("appsqlite", "GetReportSnapshot", time.Since(start).Seconds(), err)
+	if err != nil {
+		writeSnapshotError(w, err, "failed to load report snapshot")
+		return
+	}
+	if format == export.FormatJSON {
+		writeJSON(w, nethttp.StatusOK, map[string]any{"data": item})
+		return
+	}
+	filename := fmt.Sprintf("monthly-report-%s-%s-snapshot-%d", item.Report.Month, item.CustomerID, item.ID)
+	if format == export.FormatPDF {
+		createdAt, _ := time.Parse(time.RFC3339, item.CreatedAt)
+		body, err := renderMonthlyReportPDF(brand, item.Report, nil, nil, createdAt)
+		if err != nil {
+			writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to render monthly report pdf"})
+			return
+		}
+		writeAttachment(w, format, filename, body)
+		return
+	}
+	writeExport(w, format, filename, monthlyReportExportTables(item.Report, nil, nil)...)
+}
+
+func writeSnapshotError(w nethttp.ResponseWriter, err error, message string) {
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "report snapshot not found"})
+		return
+	}
+	writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": message})
+}
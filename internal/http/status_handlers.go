@@ -46,7 +46,7 @@ func ssDBStatus(ctx context.Context, store *ssstore.Store) map[string]any {
 }
 
 func customerMappingStatusHandler(store *mysqlstore.Store) nethttp.HandlerFunc {
-	return func(w nethttp.ResponseWriter, _ *nethttp.Request) {
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
 		if store == nil {
 			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{
 				"enabled": false,
@@ -65,6 +65,9 @@ func customerMappingStatusHandler(store *mysqlstore.Store) nethttp.HandlerFunc {
 		if mode == "sqlite_customer_mappings" {
 			payload["sqlite_path"] = store.CustomerMappingPath()
 		}
+		if store.HasCustomerSourceMapping() {
+			payload["coverage"] = customerMappingCoverage(r, store)
+		}
 
 		writeJSON(w, nethttp.StatusOK, payload)
 	}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"fmt"
+	nethttp "net/http"
+	"strings"
+	"time"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+	ssstore "go-am-realtime-report-ui/internal/connectors/ssdb"
+	"go-am-realtime-report-ui/internal/export"
+)
+
+// storageReportHandler serves per-customer storage consumption built from SS package
+// sizes and MCP sourceOfAcquisition. It accepts the same period parameters as the
+// monthly report.
+func storageReportHandler(defaultCustomerID string, fiscalStartMonth int, store *mysqlstore.Store, ssStore *ssstore.Store) nethttp.HandlerFunc {
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if store == nil {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{"error": "database integration disabled (set APP_DB_ENABLED=true)"})
+			return
+		}
+		if ssStore == nil {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{"error": "storage service database integration disabled (set APP_SS_DB_ENABLED=true)"})
+			return
+		}
+		if r.Method != nethttp.MethodGet {
+			writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			return
+		}
+
+		format, ok := tableExportFormat(w, r)
+		if !ok {
+			return
+		}
+		query := r.URL.Query()
+		customerID := strings.TrimSpace(query.Get("customer_id"))
+		if customerID == "" {
+			customerID = defaultCustomerID
+		}
+		period, err := parseReportPeriod(query, fiscalStartMonth, time.Now())
+		if err != nil {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+
+		runStart := time.Now()
+		start := time.Now()
+		stored, err := ssStore.ListStoredPackages(r.Context(), period.End)
+		recordDBQuery("ssdb", "ListStoredPackages", time.Since(start).Seconds(), err)
+		if err != nil {
+			recordReportRun("error", time.Since(runStart).Seconds())
+			writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to list storage service packages"})
+			return
+		}
+
+		packages := storagePackagesFromSS(stored)
+
+		start = time.Now()
+		report, err := store.GetStorageConsumption(r.Context(), packages, period, customerID)
+		recordDBQuery("mcp", "GetStorageConsumption", time.Since(start).Seconds(), err)
+		recordReportRun(map[bool]string{true: "error", false: "success"}[err != nil], time.Since(runStart).Seconds())
+		if err != nil {
+			writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to build storage report"})
+			return
+		}
+
+		if format != export.FormatJSON {
+			writeExport(w, format, fmt.Sprintf("storage-%s-%s", period.Label, customerID), storageExportTable(report))
+			return
+		}
+
+		writeJSON(w, nethttp.StatusOK, map[string]any{
+			"meta": map[string]any{
+				"customer_id":          customerID,
+				"period":               period,
+				"customer_filter_mode": store.CustomerMappingMode(),
+				"packages_scanned":     len(packages),
+			},
+			"data": report,
+		})
+	}
+}
+
+// storagePackagesFromSS links SS packages to their SIP: AIPs reuse the SIP UUID
+// and replicas point at the AIP they copy.
+func storagePackagesFromSS(stored []ssstore.StoredPackage) []mysqlstore.StoragePackage {
+	out := make([]mysqlstore.StoragePackage, 0, len(stored))
+	for _, p := range stored {
+		sipUUID := p.UUID
+		if p.ReplicaOf != "" {
+			sipUUID = p.ReplicaOf
+		}
+		out = append(out, mysqlstore.StoragePackage{
+			UUID:         p.UUID,
+			SIPUUID:      sipUUID,
+			PackageType:  p.PackageType,
+			SizeBytes:    p.SizeBytes,
+			StoredAt:     p.StoredDate,
+			LocationUUID: p.LocationUUID,
+			Location:     p.Location,
+		})
+	}
+	return out
+}
@@ -0,0 +1,355 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+	"go-am-realtime-report-ui/internal/export"
+)
+
+type saveTemplateRequest struct {
+	Name        string                           `json:"name"`
+	Description string                           `json:"description"`
+	Scope       string                           `json:"scope"`
+	Config      map[string]any                   `json:"config"`
+	Params      []mysqlstore.ReportTemplateParam `json:"params"`
+}
+
+// runTemplateRequest runs a template, or one of its earlier versions, with
+// parameter values; relative date ranges resolve in tz.
+type runTemplateRequest struct {
+	Version  int            `json:"version"`
+	Params   map[string]any `json:"params"`
+	Timezone string         `json:"tz"`
+	Limit    int            `json:"limit"`
+	Offset   int            `json:"offset"`
+}
+
+// reportTemplatesRouter serves /api/v1/reports/templates, .../templates/export,
+// .../templates/import and /api/v1/reports/templates/{id}[/run|/versions[/{v}]].
+func reportTemplatesRouter(defaultLimit, fiscalStartMonth int, store *mysqlstore.Store, deps reportScopeDeps) nethttp.HandlerFunc {
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if store == nil {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{"error": "database integration disabled (set APP_DB_ENABLED=true)"})
+			return
+		}
+		if !store.HasTemplateStore() {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{
+				"error": "template sqlite store not available",
+				"hint":  "set APP_CUSTOMER_MAP_SQLITE_PATH to enable app-owned template persistence",
+			})
+			return
+		}
+
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/reports/templates"), "/"), "/")
+		switch parts[0] {
+		case "":
+			switch r.Method {
+			case nethttp.MethodGet:
+				limit := parseLimit(r, defaultLimit)
+				start := time.Now()
+				items, err := store.ListReportTemplates(r.Context(), limit)
+				recordDBQuery("appsqlite", "ListReportTemplates", time.Since(start).Seconds(), err)
+				if err != nil {
+					writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to list report templates"})
+					return
+				}
+				writeJSON(w, nethttp.StatusOK, map[string]any{
+					"meta": map[string]any{"count": len(items), "limit": limit},
+					"data": items,
+				})
+			case nethttp.MethodPost:
+				saveReportTemplate(w, r, store)
+			default:
+				writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			}
+			return
+		case "export":
+			if r.Method != nethttp.MethodGet || len(parts) > 1 {
+				writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+				return
+			}
+			exportReportTemplates(w, r, store)
+			return
+		case "import":
+			if r.Method != nethttp.MethodPost || len(parts) > 1 {
+				writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+				return
+			}
+			importReportTemplates(w, r, store)
+			return
+		}
+
+		if len(parts) > 3 || (len(parts) == 3 && parts[1] != "versions") {
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "not found"})
+			return
+		}
+		id, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || id <= 0 {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid template id"})
+			return
+		}
+		action := ""
+		if len(parts) > 1 {
+			action = parts[1]
+		}
+
+		switch {
+		case action == "" && r.Method == nethttp.MethodGet:
+			startGet := time.Now()
+			item, err := store.GetReportTemplate(r.Context(), id)
+			recordDBQuery("appsqlite", "GetReportTemplate", time.Since(startGet).Seconds(), err)
+			if err != nil {
+				writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "template not found"})
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{"data": item})
+		case action == "" && r.Method == nethttp.MethodDelete:
+			startDelete := time.Now()
+			deleted, err := store.DeleteReportTemplate(r.Context(), id)
+			recordDBQuery("appsqlite", "DeleteReportTemplate", time.Since(startDelete).Seconds(), err)
+			if err != nil {
+				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to delete template"})
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{
+				"meta": map[string]any{"deleted": deleted, "id": id},
+			})
+		case action == "versions" && len(parts) == 2 && r.Method == nethttp.MethodGet:
+			start := time.Now()
+			items, err := store.ListReportTemplateVersions(r.Context(), id)
+			recordDBQuery("appsqlite", "ListReportTemplateVersions", time.Since(start).Seconds(), err)
+			if err != nil {
+				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to list template versions"})
+				return
+			}
+			if len(items) == 0 {
+				writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "template not found"})
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{
+				"meta": map[string]any{"template_id": id, "count": len(items)},
+				"data": items,
+			})
+		case action == "versions" && len(parts) == 3 && r.Method == nethttp.MethodGet:
+			getReportTemplateVersion(w, r, store, id, parts[2])
+		case action == "run" && len(parts) == 2 && r.Method == nethttp.MethodPost:
+			format, ok := tableExportFormat(w, r)
+			if !ok {
+				return
+			}
+			var req runTemplateRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
+				return
+			}
+			query, tpl, resolved, err := resolveTemplateRequest(r.Context(), store, id, req.Version, req.Params, time.Now(), req.Timezone, fiscalStartMonth)
+			if err != nil {
+				writeReportError(w, err)
+				return
+			}
+			if req.Limit > 0 {
+				query.Limit = req.Limit
+			}
+			if req.Offset > 0 {
+				query.Offset = req.Offset
+			}
+			result, err := runReportQuery(r.Context(), store, deps, query, defaultLimit, 1000)
+			if err != nil {
+				writeReportError(w, err)
+				return
+			}
+			if format != export.FormatJSON {
+				writeExport(w, format, result.filename, result.table)
+				return
+			}
+			result.meta["template"] = map[string]any{"id": tpl.ID, "name": tpl.Name, "version": tpl.Version, "params": resolved}
+			writeJSON(w, nethttp.StatusOK, map[string]any{
+				"meta": result.meta,
+				"data": result.rows,
+			})
+		case action == "" || action == "versions" || action == "run":
+			writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		default:
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "not found"})
+		}
+	}
+}
+
+func saveReportTemplate(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store) {
+	var req saveTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "template name is required"})
+		return
+	}
+	start := time.Now()
+	item, outcome, err := store.UpsertReportTemplate(r.Context(), req.Name, req.Description, req.Scope, req.Config, req.Params)
+	recordDBQuery("appsqlite", "UpsertReportTemplate", time.Since(start).Seconds(), err)
+	if err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, map[string]any{
+		"meta": map[string]any{"saved": true, "outcome": outcome, "version": item.Version},
+		"data": item,
+	})
+}
+
+// getReportTemplateVersion serves one version; ?diff=<v> adds the changes from
+// version v to it.
+func getReportTemplateVersion(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store, id int64, raw string) {
+	version, err := strconv.Atoi(raw)
+	if err != nil || version <= 0 {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid template version"})
+		return
+	}
+	start := time.Now()
+	item, err := store.GetReportTemplateVersion(r.Context(), id, version)
+	recordDBQuery("appsqlite", "GetReportTemplateVersion", time.Since(start).Seconds(), err)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "template version not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to load template version"})
+		return
+	}
+	resp := map[string]any{"data": item}
+	if rawDiff := strings.TrimSpace(r.URL.Query().Get("diff")); rawDiff != "" {
+		other, err := strconv.Atoi(rawDiff)
+		if err != nil || other <= 0 {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid diff, expected a version number"})
+			return
+		}
+		start = time.Now()
+		base, err := store.GetReportTemplateVersion(r.Context(), id, other)
+		recordDBQuery("appsqlite", "GetReportTemplateVersion", time.Since(start).Seconds(), err)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": fmt.Sprintf("template version %d not found", other)})
+			return
+		}
+		if err != nil {
+			writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to load template version"})
+			return
+		}
+		resp["meta"] = map[string]any{"diff_from": other, "changes": mysqlstore.DiffReportTemplates(*base, *item)}
+	}
+	writeJSON(w, nethttp.StatusOK, resp)
+}
+
+// exportReportTemplates downloads a bundle of the templates listed in ?ids=, or of
+// all templates.
+func exportReportTemplates(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store) {
+	var ids []int64
+	for _, raw := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid ids, expected comma separated template ids"})
+			return
+		}
+		ids = append(ids, id)
+	}
+	start := time.Now()
+	bundle, err := store.ExportReportTemplates(r.Context(), ids)
+	recordDBQuery("appsqlite", "ExportReportTemplates", time.Since(start).Seconds(), err)
+	if err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	body, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to encode template bundle"})
+		return
+	}
+	writeAttachment(w, export.FormatJSON, "report-templates-"+bundle.ExportedAt.Format("20060102-150405"), body)
+}
+
+// importReportTemplates saves the templates of an uploaded bundle; ?dry_run=true
+// only reports what would change.
+func importReportTemplates(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store) {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	var bundle mysqlstore.ReportTemplateBundle
+	if err := json.NewDecoder(r.Body).Decode(&bundle); err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
+		return
+	}
+	start := time.Now()
+	results, err := store.ImportReportTemplates(r.Context(), bundle, dryRun)
+	recordDBQuery("appsqlite", "ImportReportTemplates", time.Since(start).Seconds(), err)
+	if err != nil {
+		status := nethttp.StatusBadRequest
+		if len(results) > 0 {
+			// Some templates were saved before one failed.
+			status = nethttp.StatusInternalServerError
+		}
+		writeJSON(w, status, map[string]any{"error": err.Error(), "data": results})
+		return
+	}
+	counts := map[string]int{}
+	for _, res := range results {
+		counts[res.Outcome]++
+	}
+	writeJSON(w, nethttp.StatusOK, map[string]any{
+		"meta": map[string]any{"dry_run": dryRun, "count": len(results), "outcomes": counts},
+		"data": results,
+	})
+}
+
+// resolveTemplateRequest loads a template, or the given version of it, and turns it
+// into an ad-hoc report request with its parameters resolved at at in tz.
+func resolveTemplateRequest(ctx context.Context, store *mysqlstore.Store, id int64, version int, values map[string]any, at time.Time, tz string, fiscalStartMonth int) (runReportRequest, *mysqlstore.ReportTemplate, map[string]any, error) {
+	var (
+		tpl *mysqlstore.ReportTemplate
+		err error
+	)
+	start := time.Now()
+	if version > 0 {
+		tpl, err = store.GetReportTemplateVersion(ctx, id, version)
+		recordDBQuery("appsqlite", "GetReportTemplateVersion", time.Since(start).Seconds(), err)
+	} else {
+		tpl, err = store.GetReportTemplate(ctx, id)
+		recordDBQuery("appsqlite", "GetReportTemplate", time.Since(start).Seconds(), err)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		if version > 0 {
+			return runReportRequest{}, nil, nil, &reportError{status: nethttp.StatusNotFound, message: fmt.Sprintf("template %d has no version %d", id, version)}
+		}
+		return runReportRequest{}, nil, nil, &reportError{status: nethttp.StatusNotFound, message: fmt.Sprintf("template %d not found", id)}
+	}
+	if err != nil {
+		return runReportRequest{}, nil, nil, &reportError{status: nethttp.StatusInternalServerError, message: "failed to load report template", backend: true}
+	}
+
+	loc := time.UTC
+	if tz = strings.TrimSpace(tz); tz != "" {
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return runReportRequest{}, nil, nil, badReport(fmt.Errorf("invalid tz, expected an IANA timezone such as Europe/Berlin"))
+		}
+	}
+	config, resolved, err := mysqlstore.ResolveReportTemplateConfig(*tpl, values, at, loc, fiscalStartMonth)
+	if err != nil {
+		return runReportRequest{}, nil, nil, badReport(err)
+	}
+	var req runReportRequest
+	if err := json.Unmarshal(config, &req); err != nil {
+		return runReportRequest{}, nil, nil, badReport(fmt.Errorf("template %d has an invalid config: %v", id, err))
+	}
+	req.Scope = tpl.Scope
+	return req, tpl, resolved, nil
+}
@@ -858,6 +858,14 @@ const dashboardHTML = `<!doctype html>
                 <div class="panel-heading"><h3>Report Builder</h3></div>
                 <div class="panel-body">
                   <div style="display:flex;flex-wrap:wrap;gap:10px;align-items:end">
+                    <label>Scope<br>
+                      <select id="report-scope">
+                        <option value="transfer">transfer</option>
+                        <option value="sip">sip</option>
+                        <option value="aip">aip</option>
+                        <option value="file">file</option>
+                      </select>
+                    </label>
                     <label>Window Start (UTC)<br><input id="report-date-from" type="datetime-local" /></label>
                     <label>Window End (UTC)<br><input id="report-date-to" type="datetime-local" /></label>
                     <label>Status<br>
@@ -873,10 +881,16 @@ const dashboardHTML = `<!doctype html>
                     <label>Offset<br><input id="report-offset" type="number" min="0" value="0" style="width:90px" /></label>
                     <button class="tab-btn" id="report-run" type="button">Run Report</button>
                     <button class="tab-btn" id="report-export-csv" type="button">Export CSV</button>
+                    <button class="tab-btn" id="report-export-xlsx" type="button">Export XLSX</button>
+                    <button class="tab-btn" id="report-export-all" type="button" title="All matching transfers, ignoring limit and offset">Export All (CSV)</button>
                     <input id="report-template-name" type="text" placeholder="template name" style="min-width:180px" />
                     <input id="report-template-description" type="text" placeholder="description (optional)" style="min-width:220px" />
                     <button class="tab-btn" id="report-save-template" type="button">Save Template</button>
                   </div>
+                  <div style="display:flex;flex-wrap:wrap;gap:8px;align-items:end;margin-top:8px">
+                    <label style="flex:1 1 420px">Filter<br><input id="report-filter" type="text" placeholder="size_mb &gt; 100 AND (status = 'FAILED' OR source_of_acquisition LIKE 'acme%')" style="width:100%" /></label>
+                    <label>Sort<br><input id="report-sort" type="text" placeholder="failed_tasks desc, completed_at" style="min-width:240px" /></label>
+                  </div>
                   <div style="display:flex;flex-wrap:wrap;gap:8px;align-items:end;margin-top:8px">
                     <label>Templates<br>
                       <select id="report-template-select" style="min-width:280px">
@@ -891,6 +905,16 @@ const dashboardHTML = `<!doctype html>
                     <div class="hint">Columns</div>
                     <div id="report-columns" style="display:grid;grid-template-columns:repeat(auto-fit,minmax(220px,1fr));gap:6px"></div>
                   </div>
+                  <div id="report-grouping">
+                    <div style="margin-top:10px">
+                      <div class="hint">Group By (optional, returns one aggregated row per group)</div>
+                      <div id="report-group-by" style="display:grid;grid-template-columns:repeat(auto-fit,minmax(220px,1fr));gap:6px"></div>
+                    </div>
+                    <div style="margin-top:10px">
+                      <div class="hint">Measures (used with Group By)</div>
+                      <div id="report-measures" style="display:grid;grid-template-columns:repeat(auto-fit,minmax(220px,1fr));gap:6px"></div>
+                    </div>
+                  </div>
                   <div class="hint" id="report-summary" style="margin-top:8px">Run a report to see results.</div>
                   <table class="service-table">
                     <thead id="report-results-head"><tr><th>Results</th></tr></thead>
@@ -944,6 +968,8 @@ const dashboardHTML = `<!doctype html>
               <li><span class="mono">/api/v1/reports/query/options</span></li>
               <li><span class="mono">/api/v1/reports/query</span></li>
               <li><span class="mono">/api/v1/reports/templates</span></li>
+              <li><span class="mono">/api/v1/reports/schedules</span></li>
+              <li><span class="mono">/api/v1/jobs</span></li>
             </ul>
           </div>
         </aside>
@@ -1035,9 +1061,17 @@ const dashboardHTML = `<!doctype html>
     let completedFilters = { dateFrom: '', dateTo: '', query: '' };
     let failedFilters = { dateFrom: '', dateTo: '', query: '' };
     let aipFilters = { dateFrom: '', dateTo: '', query: '' };
+    let reportScope = 'transfer';
+    let reportOptionsScope = '';
     let reportColumns = [];
+    let reportGroupBy = [];
+    let reportMeasures = [];
+    let reportSelectedGroupBy = [];
+    let reportSelectedMeasures = ['count'];
+    let reportActiveColumns = [];
     let reportSelectedColumns = [];
     let reportRows = [];
+    let reportLastRequest = null;
     let reportTemplates = [];
     let riskThresholds = {
       unknown_hot_rate: 0.01,
@@ -1112,35 +1146,68 @@ const dashboardHTML = `<!doctype html>
     }
 
     function renderReportColumns(columns) {
-      const container = q('#report-columns');
+      renderReportChecklist('#report-columns', 'report-column', columns, reportSelectedColumns);
+    }
+
+    function renderReportGrouping() {
+      renderReportChecklist('#report-group-by', 'report-group-by', reportGroupBy, reportSelectedGroupBy);
+      renderReportChecklist('#report-measures', 'report-measure', reportMeasures, reportSelectedMeasures);
+    }
+
+    function selectedReportGroupBy() {
+      return qq('input[name="report-group-by"]:checked').map((el) => el.value);
+    }
+
+    function selectedReportMeasures() {
+      return qq('input[name="report-measure"]:checked').map((el) => el.value);
+    }
+
+    function renderReportChecklist(selector, name, items, selected) {
+      const container = q(selector);
       container.innerHTML = '';
-      (columns || []).forEach((c, idx) => {
-        const id = 'report-col-' + idx;
+      (items || []).forEach((c, idx) => {
+        const id = name + '-' + idx;
         const label = document.createElement('label');
         label.setAttribute('for', id);
         label.style.display = 'flex';
         label.style.alignItems = 'center';
         label.style.gap = '6px';
         label.innerHTML =
-          '<input id="' + id + '" type="checkbox" name="report-column" value="' + c.key + '"' +
-          (reportSelectedColumns.includes(c.key) ? ' checked' : '') + ' />' +
+          '<input id="' + id + '" type="checkbox" name="' + name + '" value="' + c.key + '"' +
+          (selected.includes(c.key) ? ' checked' : '') + ' />' +
           '<span>' + c.label + ' <span class="mono">(' + c.key + ')</span></span>';
         container.appendChild(label);
       });
     }
 
     async function loadReportOptions() {
-      if (reportColumns.length) return;
-      const res = await getJSON('/api/v1/reports/query/options');
+      if (reportColumns.length && reportOptionsScope === reportScope) return;
+      const res = await getJSON(buildURL('/api/v1/reports/query/options', { scope: reportScope }));
+      reportOptionsScope = reportScope;
       reportColumns = res?.data?.columns || [];
-      const defaults = ['transfer_uuid', 'name', 'status', 'completed_at', 'duration_seconds', 'files_total', 'failed_tasks'];
+      reportGroupBy = res?.data?.group_by || [];
+      reportMeasures = res?.data?.measures || [];
+      renderReportGrouping();
+      q('#report-grouping').style.display = reportGroupBy.length ? '' : 'none';
+      q('#report-status').disabled = reportScope !== 'transfer';
+      if (reportScope !== 'transfer') q('#report-status').value = 'all';
+      const defaults = res?.data?.default_columns || [];
       reportSelectedColumns = defaults.filter((k) => reportColumns.some((c) => c.key === k));
       renderReportColumns(reportColumns);
       await loadReportTemplates();
     }
 
+    async function changeReportScope(scope) {
+      reportScope = scope || 'transfer';
+      q('#report-scope').value = reportScope;
+      reportSelectedGroupBy = [];
+      reportSelectedMeasures = ['count'];
+      reportActiveColumns = [];
+      await loadReportOptions();
+    }
+
     function reportColumnLabel(key) {
-      const found = reportColumns.find((c) => c.key === key);
+      const found = reportColumns.concat(reportGroupBy, reportMeasures).find((c) => c.key === key);
       return found ? found.label : key;
     }
 
@@ -1210,29 +1277,48 @@ const dashboardHTML = `<!doctype html>
         const offset = Math.max(0, Number(q('#report-offset').value || 0));
         const columns = selectedReportColumns();
         reportSelectedColumns = columns;
-
+        const groupBy = reportScope === 'transfer' ? selectedReportGroupBy() : [];
+        const measures = selectedReportMeasures();
+        reportSelectedGroupBy = groupBy;
+        reportSelectedMeasures = measures;
+        const filter = (q('#report-filter').value || '').trim();
+        const sort = reportSortTerms(q('#report-sort').value);
+
+        const request = {
+          scope: reportScope,
+          date_from: from,
+          date_to: to,
+          status: status,
+          customer_id: customerID,
+          limit: limit,
+          offset: offset,
+          columns: columns,
+          group_by: groupBy,
+          measures: groupBy.length ? measures : [],
+          filter: filter,
+          sort: sort,
+        };
         const res = await fetch('/api/v1/reports/query', {
           method: 'POST',
           headers: { 'Content-Type': 'application/json' },
-          body: JSON.stringify({
-            date_from: from,
-            date_to: to,
-            status: status,
-            customer_id: customerID,
-            limit: limit,
-            offset: offset,
-            columns: columns,
-          }),
+          body: JSON.stringify(request),
         });
         if (!res.ok) {
           const body = await res.json().catch(() => ({}));
           throw new Error(body.error || ('HTTP ' + res.status));
         }
         const payload = await res.json();
+        reportLastRequest = request;
         reportRows = payload?.data || [];
-        const activeColumns = payload?.meta?.columns || columns;
-        renderReportResults(activeColumns, reportRows);
-        text('report-summary', 'Rows: ' + (payload?.meta?.count || 0) + ' / Total: ' + (payload?.meta?.total || 0) + ' | Window: ' + (payload?.meta?.date_from || '-') + ' -> ' + (payload?.meta?.date_to || '-'));
+        reportActiveColumns = payload?.meta?.columns || columns;
+        renderReportResults(reportActiveColumns, reportRows);
+        const grouped = groupBy.length
+          ? ' | Groups over ' + (payload?.meta?.transfers || 0) + ' transfers' + (payload?.meta?.truncated ? ' (truncated)' : '')
+          : '';
+        const unavailable = (payload?.meta?.unavailable_columns || []).length
+          ? ' | Unavailable: ' + payload.meta.unavailable_columns.join(', ')
+          : '';
+        text('report-summary', 'Rows: ' + (payload?.meta?.count || 0) + ' / Total: ' + (payload?.meta?.total || 0) + grouped + unavailable + ' | Window: ' + (payload?.meta?.date_from || '-') + ' -> ' + (payload?.meta?.date_to || '-'));
         q('#report-debug-json').textContent = JSON.stringify({
           meta: payload?.meta || {},
           preview: reportRows.slice(0, 5),
@@ -1243,11 +1329,48 @@ const dashboardHTML = `<!doctype html>
       }
     }
 
+    function reportSortTerms(value) {
+      return String(value || '').split(',').map((s) => s.trim()).filter((s) => s);
+    }
+
     function exportReportCSV() {
-      const columns = selectedReportColumns();
+      const columns = reportActiveColumns.length ? reportActiveColumns : selectedReportColumns();
       if (!columns.length || !reportRows.length) return;
       const rows = reportRows.map((r) => columns.map((c) => r[c]));
-      downloadCSV('transfer-report.csv', columns, rows);
+      downloadCSV(reportScope + '-report.csv', columns, rows);
+    }
+
+    async function exportReportXLSX() {
+      await downloadReportExport('/api/v1/reports/query?format=xlsx', reportLastRequest?.scope + '-report.xlsx');
+    }
+
+    async function exportReportAll() {
+      await downloadReportExport('/api/v1/reports/query/stream?format=csv', reportLastRequest?.scope + '-report-all.csv');
+    }
+
+    async function downloadReportExport(url, filename) {
+      if (!reportLastRequest) return;
+      try {
+        const res = await fetch(url, {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify(reportLastRequest),
+        });
+        if (!res.ok) {
+          const body = await res.json().catch(() => ({}));
+          throw new Error(body.error || ('HTTP ' + res.status));
+        }
+        const blob = await res.blob();
+        const a = document.createElement('a');
+        a.href = URL.createObjectURL(blob);
+        a.download = filename;
+        document.body.appendChild(a);
+        a.click();
+        a.remove();
+        URL.revokeObjectURL(a.href);
+      } catch (err) {
+        q('#report-debug-json').textContent = 'Export failed: ' + err.message;
+      }
     }
 
     async function saveReportTemplate() {
@@ -1260,7 +1383,7 @@ const dashboardHTML = `<!doctype html>
         const payload = {
           name: name,
           description: (q('#report-template-description').value || '').trim(),
-          scope: 'transfer',
+          scope: reportScope,
           config: {
             date_from: q('#report-date-from').value || '',
             date_to: q('#report-date-to').value || '',
@@ -1269,6 +1392,10 @@ const dashboardHTML = `<!doctype html>
             limit: Math.max(1, Math.min(1000, Number(q('#report-limit').value || 100))),
             offset: Math.max(0, Number(q('#report-offset').value || 0)),
             columns: selectedReportColumns(),
+            group_by: selectedReportGroupBy(),
+            measures: selectedReportMeasures(),
+            filter: (q('#report-filter').value || '').trim(),
+            sort: reportSortTerms(q('#report-sort').value),
           },
         };
         const res = await fetch('/api/v1/reports/templates', {
@@ -1294,6 +1421,7 @@ const dashboardHTML = `<!doctype html>
         const res = await getJSON('/api/v1/reports/templates/' + encodeURIComponent(String(id)));
         const tpl = res?.data || {};
         const cfg = JSON.parse(tpl.config_json || '{}');
+        await changeReportScope(tpl.scope);
         q('#report-template-name').value = tpl.name || '';
         q('#report-template-description').value = tpl.description || '';
         q('#report-date-from').value = cfg.date_from || '';
@@ -1302,8 +1430,13 @@ const dashboardHTML = `<!doctype html>
         q('#report-customer-id').value = cfg.customer_id || '';
         q('#report-limit').value = cfg.limit || 100;
         q('#report-offset').value = cfg.offset || 0;
+        q('#report-filter').value = cfg.filter || '';
+        q('#report-sort').value = Array.isArray(cfg.sort) ? cfg.sort.join(', ') : '';
         reportSelectedColumns = Array.isArray(cfg.columns) ? cfg.columns : [];
         renderReportColumns(reportColumns);
+        reportSelectedGroupBy = Array.isArray(cfg.group_by) ? cfg.group_by : [];
+        reportSelectedMeasures = Array.isArray(cfg.measures) && cfg.measures.length ? cfg.measures : ['count'];
+        renderReportGrouping();
         await runReportQuery();
         flashReportTemplate('[LOAD]', 'Template loaded', true);
       } catch (err) {
@@ -1896,14 +2029,20 @@ const dashboardHTML = `<!doctype html>
 	          const c1 = document.createElement('td');
 	          c1.textContent = s.microservice_group || '-';
 	          const c2 = document.createElement('td');
-	          c2.title = s.signature || '-';
-	          c2.textContent = s.signature || '-';
+	          const examples = (s.examples || []).map((e) => e.error_text || '').filter(Boolean);
+	          c2.title = [s.id ? 'Signature ' + s.id : '', s.signature || '-'].concat(examples).filter(Boolean).join('\n\n');
+	          c2.textContent = (s.signature || '-') + (Number(s.variants || 0) > 1 ? ' (' + s.variants + ' variants)' : '');
+	          if (s.knowledge) {
+	            c2.textContent = '[' + (s.knowledge.severity || 'known') + '] ' + (s.knowledge.title || '') + ' - ' + c2.textContent;
+	            c2.title = [s.knowledge.remediation || '', c2.title].filter(Boolean).join('\n\n');
+	          }
 	          const c3 = document.createElement('td');
 	          c3.textContent = String(s.failures || 0);
 	          const c4 = document.createElement('td');
 	          c4.textContent = String(s.distinct_transfers || 0);
 	          const c5 = document.createElement('td');
 	          c5.textContent = s.last_seen_at ? String(s.last_seen_at).replace('T', ' ').replace('Z', '') : '-';
+	          if (s.first_seen_at) c5.title = 'First seen in sample ' + String(s.first_seen_at).replace('T', ' ').replace('Z', '');
 	          tr.appendChild(c1);
 	          tr.appendChild(c2);
 	          tr.appendChild(c3);
@@ -2217,8 +2356,14 @@ const dashboardHTML = `<!doctype html>
     writeFilters('completed', completedFilters);
     writeFilters('failed', failedFilters);
     writeFilters('aip', aipFilters);
+    q('#report-scope').addEventListener('change', async () => {
+      await changeReportScope(q('#report-scope').value);
+      runReportQuery();
+    });
     q('#report-run').addEventListener('click', () => runReportQuery());
     q('#report-export-csv').addEventListener('click', () => exportReportCSV());
+    q('#report-export-xlsx').addEventListener('click', () => exportReportXLSX());
+    q('#report-export-all').addEventListener('click', () => exportReportAll());
     q('#report-save-template').addEventListener('click', () => saveReportTemplate());
     q('#report-load-template').addEventListener('click', () => loadSelectedTemplate());
     q('#report-delete-template').addEventListener('click', () => deleteSelectedTemplate());
@@ -0,0 +1,216 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	nethttp "net/http"
+	"strings"
+	"time"
+
+	esstore "go-am-realtime-report-ui/internal/connectors/es"
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+	ssstore "go-am-realtime-report-ui/internal/connectors/ssdb"
+)
+
+// unitDetail is what the transfer and SIP detail routers differ in.
+type unitDetail struct {
+	prefix string // path prefix, e.g. "/api/v1/transfers/"
+	name   string // "transfer" or "sip", for messages and the meta UUID key
+	op     string // "Transfer" or "SIP", for DB metric operation names
+
+	summary     func(ctx context.Context, uuid string) (any, error)
+	timeline    func(ctx context.Context, uuid string, limit int) ([]mysqlstore.TimelineEvent, error)
+	errors      func(ctx context.Context, uuid string, limit int) ([]mysqlstore.TransferError, error)
+	performance func(ctx context.Context, uuid string) (*mysqlstore.TransferPerformance, error)
+
+	// performanceAction serves {uuid}/performance.
+	performanceAction bool
+	// followRelatedSIP also looks up the SIP most of the unit's files went into:
+	// in Elasticsearch when the unit itself has no hits, and in the Storage Service.
+	followRelatedSIP bool
+	// addDetails adds unit-specific fields to the details response.
+	addDetails func(summary any, payload, meta map[string]any)
+}
+
+// unitDetailRouter serves {prefix}{uuid}/summary, details, timeline and errors
+// (and performance when enabled) for one unit kind.
+func unitDetailRouter(u unitDetail, defaultLimit int, store *mysqlstore.Store, ssStore *ssstore.Store, esClient *esstore.Client, esLookupLimit int) nethttp.HandlerFunc {
+	uuidKey := u.name + "_uuid"
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if store == nil {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{
+				"error": "database integration disabled (set APP_DB_ENABLED=true)",
+			})
+			return
+		}
+
+		trimmed := strings.TrimPrefix(r.URL.Path, u.prefix)
+		parts := strings.Split(strings.Trim(trimmed, "/"), "/")
+		if len(parts) != 2 || parts[0] == "" {
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "not found"})
+			return
+		}
+
+		unitUUID := parts[0]
+		action := parts[1]
+		limit := parseLimit(r, defaultLimit)
+
+		fetchSummary := func() (any, bool) {
+			start := time.Now()
+			item, err := u.summary(r.Context(), unitUUID)
+			recordDBQuery("mcp", "Get"+u.op+"Summary", time.Since(start).Seconds(), err)
+			if err != nil {
+				if strings.Contains(err.Error(), "no rows in result set") {
+					writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": fmt.Sprintf("%s not found: %s", u.name, unitUUID)})
+					return nil, false
+				}
+				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to fetch " + u.name + " summary"})
+				return nil, false
+			}
+			return item, true
+		}
+		fetchTimeline := func() ([]mysqlstore.TimelineEvent, bool) {
+			start := time.Now()
+			items, err := u.timeline(r.Context(), unitUUID, limit)
+			recordDBQuery("mcp", "Get"+u.op+"Timeline", time.Since(start).Seconds(), err)
+			if err != nil {
+				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to fetch " + u.name + " timeline"})
+				return nil, false
+			}
+			return items, true
+		}
+		fetchErrors := func() ([]mysqlstore.TransferError, bool) {
+			start := time.Now()
+			items, err := u.errors(r.Context(), unitUUID, limit)
+			recordDBQuery("mcp", "Get"+u.op+"Errors", time.Since(start).Seconds(), err)
+			if err != nil {
+				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to fetch " + u.name + " errors"})
+				return nil, false
+			}
+			return items, true
+		}
+
+		switch {
+		case action == "summary":
+			if item, ok := fetchSummary(); ok {
+				writeJSON(w, nethttp.StatusOK, map[string]any{"data": item})
+			}
+		case action == "details":
+			summary, ok := fetchSummary()
+			if !ok {
+				return
+			}
+			timeline, ok := fetchTimeline()
+			if !ok {
+				return
+			}
+			errs, ok := fetchErrors()
+			if !ok {
+				return
+			}
+
+			payload := map[string]any{
+				"summary":  summary,
+				"timeline": timeline,
+				"errors":   errs,
+			}
+			meta := map[string]any{
+				uuidKey:          unitUUID,
+				"timeline_count": len(timeline),
+				"error_count":    len(errs),
+			}
+			if u.addDetails != nil {
+				u.addDetails(summary, payload, meta)
+			}
+
+			startPerf := time.Now()
+			perf, perfErr := u.performance(r.Context(), unitUUID)
+			recordDBQuery("mcp", "Get"+u.op+"Performance", time.Since(startPerf).Seconds(), perfErr)
+			if perfErr != nil {
+				meta["performance_error"] = perfErr.Error()
+			} else if perf != nil {
+				payload["performance"] = perf
+				meta["microservices_count"] = perf.Summary["microservices_count"]
+			}
+			relatedSIP := ""
+			if u.followRelatedSIP && perf != nil {
+				relatedSIP = strings.TrimSpace(perf.RelatedSIPUUID)
+			}
+
+			if esClient != nil && esClient.Enabled() {
+				startES := time.Now()
+				esResult, esErr := esClient.SearchTransfer(r.Context(), unitUUID, esLookupLimit)
+				recordExternalProbe("elasticsearch", "SearchTransfer", time.Since(startES).Seconds(), esErr)
+				if esErr != nil {
+					meta["es_error"] = esErr.Error()
+				} else if esResult != nil {
+					// Archivematica reuses the SIP UUID as the AIP UUID, so a transfer
+					// without hits may be indexed under its SIP.
+					if esResult.TotalHits == 0 && relatedSIP != "" {
+						startESAlt := time.Now()
+						alt, altErr := esClient.SearchTransfer(r.Context(), relatedSIP, esLookupLimit)
+						recordExternalProbe("elasticsearch", "SearchTransfer", time.Since(startESAlt).Seconds(), altErr)
+						if altErr == nil && alt != nil {
+							esResult = alt
+							meta["es_lookup_uuid"] = relatedSIP
+						}
+					}
+					payload["elasticsearch"] = esResult
+					meta["es_hits"] = esResult.TotalHits
+				}
+			}
+
+			if ssStore != nil {
+				lookupUUIDs := []string{unitUUID}
+				if relatedSIP != "" {
+					lookupUUIDs = append(lookupUUIDs, perf.RelatedSIPUUID)
+				}
+				startSS := time.Now()
+				packages, ssErr := ssStore.LookupPackagesByUUIDs(r.Context(), lookupUUIDs)
+				recordDBQuery("ssdb", "LookupPackagesByUUIDs", time.Since(startSS).Seconds(), ssErr)
+				if ssErr != nil {
+					meta["ss_error"] = ssErr.Error()
+				} else {
+					payload["storage_service"] = map[string]any{
+						"lookup_uuids": lookupUUIDs,
+						"packages":     packages,
+					}
+					meta["ss_packages"] = len(packages)
+				}
+			}
+
+			writeJSON(w, nethttp.StatusOK, map[string]any{
+				"meta": meta,
+				"data": payload,
+			})
+		case action == "timeline":
+			if items, ok := fetchTimeline(); ok {
+				writeJSON(w, nethttp.StatusOK, map[string]any{
+					"meta": map[string]any{uuidKey: unitUUID, "limit": limit, "count": len(items)},
+					"data": items,
+				})
+			}
+		case action == "errors":
+			if items, ok := fetchErrors(); ok {
+				writeJSON(w, nethttp.StatusOK, map[string]any{
+					"meta": map[string]any{uuidKey: unitUUID, "limit": limit, "count": len(items)},
+					"data": items,
+				})
+			}
+		case action == "performance" && u.performanceAction:
+			start := time.Now()
+			perf, err := u.performance(r.Context(), unitUUID)
+			recordDBQuery("mcp", "Get"+u.op+"Performance", time.Since(start).Seconds(), err)
+			if err != nil {
+				writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to fetch " + u.name + " performance"})
+				return
+			}
+			writeJSON(w, nethttp.StatusOK, map[string]any{
+				"meta": map[string]any{uuidKey: unitUUID},
+				"data": perf,
+			})
+		default:
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "not found"})
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	nethttp "net/http"
+	"strings"
+	"time"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+)
+
+type assignUnmappedSourceRequest struct {
+	SourceOfAcquisition string `json:"source_of_acquisition"`
+	CustomerID          string `json:"customer_id"`
+	EffectiveFrom       string `json:"effective_from"`
+	EffectiveTo         string `json:"effective_to"`
+}
+
+// unmappedSourcesRouter serves /api/v1/reports/unmapped-sources and
+// /api/v1/reports/unmapped-sources/assign.
+func unmappedSourcesRouter(defaultLimit int, store *mysqlstore.Store) nethttp.HandlerFunc {
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if store == nil {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{"error": "database integration disabled (set APP_DB_ENABLED=true)"})
+			return
+		}
+		if !store.HasCustomerSourceMapping() {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{
+				"error": "customer mapping backend not available",
+				"hint":  "set APP_CUSTOMER_MAP_SQLITE_PATH or create CustomerTransferSources in MCP",
+			})
+			return
+		}
+
+		switch strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/reports/unmapped-sources"), "/") {
+		case "":
+			if r.Method != nethttp.MethodGet {
+				writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+				return
+			}
+			listUnmappedSources(w, r, store, defaultLimit)
+		case "assign":
+			if r.Method != nethttp.MethodPost {
+				writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+				return
+			}
+			assignUnmappedSource(w, r, store)
+		default:
+			writeJSON(w, nethttp.StatusNotFound, map[string]any{"error": "not found"})
+		}
+	}
+}
+
+func listUnmappedSources(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store, defaultLimit int) {
+	q := r.URL.Query()
+	from, to, err := parseReportDateRange(q.Get("date_from"), q.Get("date_to"))
+	if err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	limit := parseLimit(r, defaultLimit)
+
+	start := time.Now()
+	coverage, err := store.GetCustomerMappingCoverage(r.Context(), from, to, limit)
+	recordDBQuery("mcp", "GetCustomerMappingCoverage", time.Since(start).Seconds(), err)
+	if err != nil {
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to list unmapped sources"})
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, map[string]any{
+		"meta": map[string]any{
+			"date_from":        from.Format(time.RFC3339),
+			"date_to":          to.Format(time.RFC3339),
+			"limit":            limit,
+			"count":            len(coverage.Unmapped),
+			"unmapped_sources": coverage.UnmappedSources,
+			"transfers":        coverage.Transfers,
+			"unattributed":     coverage.Unattributed,
+			"coverage_percent": coverage.CoveragePercent,
+			"scan_truncated":   coverage.ScanTruncated,
+		},
+		"data": coverage.Unmapped,
+	})
+}
+
+// assignUnmappedSource maps a source to a customer. Only the app SQLite store is
+// writable; CustomerTransferSources in MCP stays read-only.
+func assignUnmappedSource(w nethttp.ResponseWriter, r *nethttp.Request, store *mysqlstore.Store) {
+	if !store.HasTemplateStore() {
+		writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{
+			"error": "read-only mode: customer mapping mutations are disabled",
+			"hint":  "set APP_CUSTOMER_MAP_SQLITE_PATH to assign sources",
+		})
+		return
+	}
+	var req assignUnmappedSourceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
+		return
+	}
+	source := strings.TrimSpace(req.SourceOfAcquisition)
+	customerID := strings.TrimSpace(req.CustomerID)
+	if source == "" || customerID == "" {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "source_of_acquisition and customer_id are required"})
+		return
+	}
+	from, to, err := parseMappingWindow(req.EffectiveFrom, req.EffectiveTo)
+	if err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+
+	start := time.Now()
+	created, err := store.AddCustomerMapping(r.Context(), customerID, source, from, to)
+	recordDBQuery("appsqlite", "AddCustomerMapping", time.Since(start).Seconds(), err)
+	if err != nil {
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to assign source"})
+		return
+	}
+	startGet := time.Now()
+	mappings, err := store.GetCustomerMappings(r.Context(), customerID)
+	recordDBQuery("appsqlite", "GetCustomerMappings", time.Since(startGet).Seconds(), err)
+	if err != nil {
+		writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "source assigned but failed to read mappings back"})
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, map[string]any{
+		"meta": map[string]any{"assigned": created, "customer_id": customerID, "source_of_acquisition": source},
+		"data": mappings,
+	})
+}
+
+// customerMappingCoverage is the coverage block of /api/v1/status/customer-mapping
+// over the last 30 days.
+func customerMappingCoverage(r *nethttp.Request, store *mysqlstore.Store) map[string]any {
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -30)
+	start := time.Now()
+	coverage, err := store.GetCustomerMappingCoverage(r.Context(), from, to, 0)
+	recordDBQuery("mcp", "GetCustomerMappingCoverage", time.Since(start).Seconds(), err)
+	if errors.Is(err, mysqlstore.ErrNoCustomerMapping) {
+		return nil
+	}
+	if err != nil {
+		return map[string]any{"ok": false, "error": err.Error()}
+	}
+	return map[string]any{
+		"ok":               true,
+		"date_from":        from.Format(time.RFC3339),
+		"date_to":          to.Format(time.RFC3339),
+		"transfers":        coverage.Transfers,
+		"attributed":       coverage.Attributed,
+		"unattributed":     coverage.Unattributed,
+		"coverage_percent": coverage.CoveragePercent,
+		"unmapped_sources": coverage.UnmappedSources,
+		"scan_truncated":   coverage.ScanTruncated,
+	}
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"fmt"
+	nethttp "net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+)
+
+const (
+	workerUtilizationMaxRange  = 366 * 24 * time.Hour
+	workerUtilizationMaxPoints = 2000
+)
+
+func workerUtilizationHandler(configuredWorkers int, store *mysqlstore.Store) nethttp.HandlerFunc {
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if store == nil {
+			writeJSON(w, nethttp.StatusServiceUnavailable, map[string]any{"error": "database integration disabled (set APP_DB_ENABLED=true)"})
+			return
+		}
+		if r.Method != nethttp.MethodGet {
+			writeJSON(w, nethttp.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			return
+		}
+
+		query := r.URL.Query()
+		var from, to time.Time
+		if strings.TrimSpace(query.Get("date_from")) == "" && strings.TrimSpace(query.Get("date_to")) == "" {
+			to = time.Now().UTC()
+			from = to.Add(-24 * time.Hour)
+		} else {
+			var err error
+			from, to, err = parseReportDateRange(query.Get("date_from"), query.Get("date_to"))
+			if err != nil {
+				writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": err.Error()})
+				return
+			}
+		}
+		if to.Sub(from) > workerUtilizationMaxRange {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "date range too large, maximum is 366 days"})
+			return
+		}
+
+		bucket := autoUtilizationBucket(to.Sub(from))
+		if raw := strings.TrimSpace(query.Get("bucket")); raw != "" {
+			parsed, err := time.ParseDuration(raw)
+			if err != nil || parsed < time.Minute {
+				writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid bucket, expected a duration of at least 1m (e.g. 15m, 1h)"})
+				return
+			}
+			bucket = parsed
+		}
+		if points := to.Sub(from) / bucket; points > workerUtilizationMaxPoints {
+			writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": fmt.Sprintf("bucket too small for range, at most %d points", workerUtilizationMaxPoints)})
+			return
+		}
+
+		workers := configuredWorkers
+		if raw := strings.TrimSpace(query.Get("workers")); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 0 {
+				writeJSON(w, nethttp.StatusBadRequest, map[string]any{"error": "invalid workers, expected a non-negative integer"})
+				return
+			}
+			workers = parsed
+		}
+		minIdle := int64(300)
+		if raw := strings.TrimSpace(query.Get("min_idle_seconds")); raw != "" {
+			if parsed, err := strconv.ParseInt(raw, 10, 64); err == nil && parsed > 0 {
+				minIdle = parsed
+			}
+		}
+
+		start := time.Now()
+		result, err := store.GetWorkerUtilization(r.Context(), mysqlstore.WorkerUtilizationOptions{
+			From:           from,
+			To:             to,
+			Bucket:         bucket,
+			Workers:        workers,
+			MinIdleSeconds: minIdle,
+		})
+		recordDBQuery("mcp", "GetWorkerUtilization", time.Since(start).Seconds(), err)
+		if err != nil {
+			writeJSON(w, nethttp.StatusInternalServerError, map[string]any{"error": "failed to build worker utilization"})
+			return
+		}
+
+		meta := map[string]any{
+			"date_from":        from.Format(time.RFC3339),
+			"date_to":          to.Format(time.RFC3339),
+			"bucket":           bucket.String(),
+			"workers":          workers,
+			"min_idle_seconds": minIdle,
+			"points":           len(result.Timeline),
+		}
+		if workers <= 0 {
+			meta["saturation_hint"] = "set APP_MCP_CLIENT_WORKERS or pass workers= to enable saturation detection"
+		}
+		writeJSON(w, nethttp.StatusOK, map[string]any{
+			"meta": meta,
+			"data": result,
+		})
+	}
+}
+
+// autoUtilizationBucket keeps timelines readable for any range.
+func autoUtilizationBucket(span time.Duration) time.Duration {
+	switch {
+	case span <= 6*time.Hour:
+		return time.Minute
+	case span <= 48*time.Hour:
+		return 15 * time.Minute
+	case span <= 14*24*time.Hour:
+		return time.Hour
+	default:
+		return 24 * time.Hour
+	}
+}
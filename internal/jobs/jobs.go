@@ -0,0 +1,179 @@
+// Package jobs runs heavy reports in the background. Jobs are queued, executed by a
+// bounded pool of workers with retries and cancellation, and keep their result until
+// it expires, so clients poll for completion instead of holding a request open.
+package jobs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go-am-realtime-report-ui/internal/export"
+)
+
+// Job states. Succeeded, failed and canceled jobs are finished and expire.
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+	StateCanceled  = "canceled"
+)
+
+var (
+	// ErrNotFound is returned for unknown or expired jobs and missing results.
+	ErrNotFound = errors.New("job not found")
+	// ErrQueueFull is returned by Enqueue when the queue has no room.
+	ErrQueueFull = errors.New("job queue is full, retry later")
+	// ErrFinished is returned when cancelling a job that already finished.
+	ErrFinished = errors.New("job already finished")
+	// ErrNotReady is returned when asking for the result of an unsuccessful job.
+	ErrNotReady = errors.New("job has no result yet")
+)
+
+// Job is one background run of a report kind with its parameters.
+type Job struct {
+	ID          string          `json:"id"`
+	Kind        string          `json:"kind"`
+	Params      json.RawMessage `json:"params"`
+	State       string          `json:"state"`
+	Progress    float64         `json:"progress"`
+	Message     string          `json:"message,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	Formats     []string        `json:"formats,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+	ExpiresAt   *time.Time      `json:"expires_at,omitempty"`
+}
+
+// Finished tells whether the job reached a final state.
+func (j Job) Finished() bool {
+	return j.State == StateSucceeded || j.State == StateFailed || j.State == StateCanceled
+}
+
+// Result is the output of a job: the JSON body the synchronous endpoint would have
+// returned, the tables its CSV and XLSX exports contain and, for reports that have
+// one, the rendered PDF. Name is the download file name without extension.
+type Result struct {
+	Name   string          `json:"name"`
+	Data   json.RawMessage `json:"data"`
+	Tables []export.Table  `json:"tables,omitempty"`
+	PDF    []byte          `json:"pdf,omitempty"`
+}
+
+// Formats lists the export formats the result can be downloaded in.
+func (r *Result) Formats() []string {
+	out := []string{export.FormatJSON}
+	if len(r.Tables) > 0 {
+		out = append(out, export.FormatCSV, export.FormatXLSX)
+	}
+	if len(r.PDF) > 0 {
+		out = append(out, export.FormatPDF)
+	}
+	return out
+}
+
+// Render encodes the result in one of its formats; JSON is the stored body as is.
+func (r *Result) Render(format string) ([]byte, error) {
+	var buf bytes.Buffer
+	switch {
+	case format == export.FormatJSON:
+		return r.Data, nil
+	case format == export.FormatPDF && len(r.PDF) > 0:
+		return r.PDF, nil
+	case format == export.FormatCSV && len(r.Tables) > 0:
+		if err := export.WriteCSV(&buf, r.Tables[0]); err != nil {
+			return nil, err
+		}
+	case format == export.FormatXLSX && len(r.Tables) > 0:
+		if err := export.WriteXLSX(&buf, r.Tables...); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("result cannot be rendered as %s, use one of %s", format, strings.Join(r.Formats(), ", "))
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeResult serializes a result as gzipped JSON for storage.
+func EncodeResult(r *Result) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(r); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeResult reverses EncodeResult. Table cells come back as JSON values, so
+// numbers are float64 and times RFC3339 strings, which the exporters accept.
+func DecodeResult(body []byte) (*Result, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var r Result
+	if err := json.NewDecoder(zr).Decode(&r); err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(io.Discard, zr); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Runner executes one attempt of a job. It reports progress between 0 and 1 with a
+// short message and must stop when ctx is done.
+type Runner func(ctx context.Context, params json.RawMessage, progress func(fraction float64, message string)) (*Result, error)
+
+// Kind is a runnable job type. Validate, when set, checks parameters at enqueue
+// time so invalid jobs are rejected instead of failing in the background.
+type Kind struct {
+	Description string
+	Validate    func(params json.RawMessage) error
+	Run         Runner
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error that retrying cannot fix, such as invalid parameters,
+// so the job fails without further attempts.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent tells whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+type invalidError struct{ err error }
+
+func (e invalidError) Error() string { return e.err.Error() }
+func (e invalidError) Unwrap() error { return e.err }
+
+// IsInvalid tells whether Enqueue rejected a job for its kind or parameters.
+func IsInvalid(err error) bool {
+	var v invalidError
+	return errors.As(err, &v)
+}
@@ -0,0 +1,223 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+	"go-am-realtime-report-ui/internal/export"
+)
+
+func testManager(t *testing.T, store Store) *Manager {
+	t.Helper()
+	m := NewManager(store, Config{Workers: 2, QueueSize: 4, MaxAttempts: 3, RetryDelay: time.Millisecond, Timeout: time.Second, ResultTTL: time.Hour})
+	m.Register("ok", Kind{
+		Validate: func(params json.RawMessage) error {
+			var p struct{ Rows int }
+			if err := json.Unmarshal(params, &p); err != nil || p.Rows < 0 {
+				return errors.New("invalid rows")
+			}
+			return nil
+		},
+		Run: func(ctx context.Context, params json.RawMessage, progress func(float64, string)) (*Result, error) {
+			progress(0.5, "halfway")
+			return &Result{
+				Data:   json.RawMessage(`{"ok":true}`),
+				Tables: []export.Table{{Title: "t", Columns: []export.Column{{Key: "n", Kind: export.KindNumber}}, Rows: [][]any{{int64(1)}}}},
+			}, nil
+		},
+	})
+	var flaky int32
+	m.Register("flaky", Kind{Run: func(ctx context.Context, params json.RawMessage, progress func(float64, string)) (*Result, error) {
+		if atomic.AddInt32(&flaky, 1) < 3 {
+			return nil, errors.New("database gone away")
+		}
+		return &Result{Data: json.RawMessage(`1`)}, nil
+	}})
+	m.Register("invalid", Kind{Run: func(ctx context.Context, params json.RawMessage, progress func(float64, string)) (*Result, error) {
+		return nil, Permanent(errors.New("bad params"))
+	}})
+	m.Register("block", Kind{Run: func(ctx context.Context, params json.RawMessage, progress func(float64, string)) (*Result, error) {
+		progress(0.1, "waiting")
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}})
+	return m
+}
+
+func waitForState(t *testing.T, m *Manager, id string, states ...string) *Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get(context.Background(), id)
+		if err == nil && containsState(states, job.State) {
+			return job
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	job, _ := m.Get(context.Background(), id)
+	t.Fatalf("job %s did not reach %v: %+v", id, states, job)
+	return nil
+}
+
+func TestManagerRunsRetriesAndFails(t *testing.T) {
+	m := testManager(t, NewMemoryStore())
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+	ctx := context.Background()
+
+	if _, err := m.Enqueue(ctx, "ok", json.RawMessage(`{"Rows":-1}`)); !IsInvalid(err) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if _, err := m.Enqueue(ctx, "missing", nil); !IsInvalid(err) {
+		t.Fatalf("expected unknown kind error, got %v", err)
+	}
+
+	ok, err := m.Enqueue(ctx, "ok", json.RawMessage(`{"Rows":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := waitForState(t, m, ok.ID, StateSucceeded)
+	if job.Progress != 1 || job.Attempts != 1 || job.ExpiresAt == nil {
+		t.Fatalf("unexpected succeeded job %+v", job)
+	}
+	if !reflect.DeepEqual(job.Formats, []string{"json", "csv", "xlsx"}) {
+		t.Fatalf("unexpected formats %v", job.Formats)
+	}
+	_, result, err := m.Result(ctx, ok.ID)
+	if err != nil || string(result.Data) != `{"ok":true}` || result.Tables[0].Rows[0][0] != 1.0 {
+		t.Fatalf("unexpected result %+v, %v", result, err)
+	}
+
+	flaky, _ := m.Enqueue(ctx, "flaky", nil)
+	if job := waitForState(t, m, flaky.ID, StateSucceeded); job.Attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", job.Attempts)
+	}
+
+	invalid, _ := m.Enqueue(ctx, "invalid", nil)
+	job = waitForState(t, m, invalid.ID, StateFailed)
+	if job.Attempts != 1 || job.Error != "bad params" {
+		t.Fatalf("permanent errors must not be retried: %+v", job)
+	}
+	if _, _, err := m.Result(ctx, invalid.ID); !errors.Is(err, ErrNotReady) {
+		t.Fatalf("expected ErrNotReady, got %v", err)
+	}
+}
+
+func TestManagerCancelAndTimeout(t *testing.T) {
+	m := testManager(t, NewMemoryStore())
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+	ctx := context.Background()
+
+	running, _ := m.Enqueue(ctx, "block", nil)
+	waitForState(t, m, running.ID, StateRunning)
+	if _, err := m.Cancel(ctx, running.ID); err != nil {
+		t.Fatal(err)
+	}
+	if job := waitForState(t, m, running.ID, StateCanceled); job.Attempts != 1 {
+		t.Fatalf("canceled jobs must not be retried: %+v", job)
+	}
+	if _, err := m.Cancel(ctx, running.ID); !errors.Is(err, ErrFinished) {
+		t.Fatalf("expected ErrFinished, got %v", err)
+	}
+
+	timedOut, _ := m.Enqueue(ctx, "block", nil)
+	job := waitForState(t, m, timedOut.ID, StateFailed)
+	if job.Attempts != 1 || job.Error != "timed out after 1s" {
+		t.Fatalf("unexpected timed out job %+v", job)
+	}
+
+	deleted, _ := m.Enqueue(ctx, "block", nil)
+	waitForState(t, m, deleted.ID, StateRunning)
+	if err := m.Delete(ctx, deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := m.Get(ctx, deleted.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleted job came back: %v", err)
+	}
+}
+
+func TestManagerExpiresAndQueueLimit(t *testing.T) {
+	store := NewMemoryStore()
+	m := testManager(t, store)
+	ctx := context.Background()
+
+	// Without workers the queue fills up.
+	for i := 0; i < 4; i++ {
+		if _, err := m.Enqueue(ctx, "ok", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.Enqueue(ctx, "ok", nil); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	if jobs, _ := m.List(ctx, 0); len(jobs) != 4 {
+		t.Fatalf("rejected jobs must not be kept, got %d", len(jobs))
+	}
+
+	queued, _ := m.List(ctx, 1)
+	if _, err := m.Cancel(ctx, queued[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	m.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if _, err := m.Get(ctx, queued[0].ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expired jobs must not be returned, got %v", err)
+	}
+	if n := m.deleteExpired(); n != 1 {
+		t.Fatalf("expected 1 expired job, deleted %d", n)
+	}
+}
+
+func TestSQLiteStoreRequeuesAfterRestart(t *testing.T) {
+	db, err := customermap.NewSQLiteStore(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store := NewSQLiteStore(db)
+	ctx := context.Background()
+
+	started := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	interrupted := Job{ID: "a1", Kind: "ok", Params: json.RawMessage(`{"Rows":2}`), State: StateRunning, Attempts: 1, MaxAttempts: 3, CreatedAt: started, StartedAt: &started}
+	if err := store.SaveJob(ctx, interrupted); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.GetJob(ctx, "a1")
+	if err != nil || got.State != StateRunning || !got.StartedAt.Equal(started) || string(got.Params) != `{"Rows":2}` {
+		t.Fatalf("unexpected stored job %+v, %v", got, err)
+	}
+	if _, err := store.GetJob(ctx, "nope"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	m := testManager(t, store)
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+	job := waitForState(t, m, "a1", StateSucceeded)
+	if job.Attempts != 2 || !reflect.DeepEqual(job.Formats, []string{"json", "csv", "xlsx"}) {
+		t.Fatalf("unexpected requeued job %+v", job)
+	}
+	if _, result, err := m.Result(ctx, "a1"); err != nil || len(result.Tables) != 1 {
+		t.Fatalf("unexpected result %+v, %v", result, err)
+	}
+	if err := m.Delete(ctx, "a1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.LoadResult(ctx, "a1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("result must be deleted with its job, got %v", err)
+	}
+}
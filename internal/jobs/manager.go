@@ -0,0 +1,485 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config bounds job execution. Zero values take the defaults in NewManager.
+type Config struct {
+	// Workers is the number of jobs running at once.
+	Workers int
+	// QueueSize is the number of jobs that may wait for a worker.
+	QueueSize int
+	// MaxAttempts is how often a failing job is run before it fails.
+	MaxAttempts int
+	// RetryDelay is the wait before the second attempt; it doubles per attempt.
+	RetryDelay time.Duration
+	// Timeout bounds one attempt.
+	Timeout time.Duration
+	// ResultTTL is how long finished jobs and their results are kept.
+	ResultTTL time.Duration
+}
+
+// progressStep is the smallest progress change that is persisted.
+const progressStep = 0.05
+
+// Manager queues jobs and runs them on a bounded worker pool.
+type Manager struct {
+	store Store
+	cfg   Config
+	kinds map[string]Kind
+	queue chan string
+	now   func() time.Time
+
+	mu       sync.Mutex
+	cancels  map[string]context.CancelFunc
+	canceled map[string]bool
+	deleted  map[string]bool
+
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// NewManager returns a Manager; Register kinds and call Start before use.
+func NewManager(store Store, cfg Config) *Manager {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 2
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 100
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = 30 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Minute
+	}
+	if cfg.ResultTTL <= 0 {
+		cfg.ResultTTL = 24 * time.Hour
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	return &Manager{
+		store:    store,
+		cfg:      cfg,
+		kinds:    map[string]Kind{},
+		queue:    make(chan string, cfg.QueueSize),
+		now:      time.Now,
+		cancels:  map[string]context.CancelFunc{},
+		canceled: map[string]bool{},
+		deleted:  map[string]bool{},
+		ctx:      ctx,
+		stop:     stop,
+	}
+}
+
+// Register adds a job kind. It must be called before Start.
+func (m *Manager) Register(name string, kind Kind) {
+	m.kinds[name] = kind
+}
+
+// Kinds returns the registered kinds and their descriptions.
+func (m *Manager) Kinds() map[string]string {
+	out := make(map[string]string, len(m.kinds))
+	for name, k := range m.kinds {
+		out[name] = k.Description
+	}
+	return out
+}
+
+// Config returns the effective configuration.
+func (m *Manager) Config() Config {
+	return m.cfg
+}
+
+// Start requeues jobs interrupted by a restart and starts the workers and the
+// cleanup of expired jobs.
+func (m *Manager) Start() error {
+	pending, err := m.store.ListJobs(m.ctx, 0, StateQueued, StateRunning)
+	if err != nil {
+		return err
+	}
+	// Oldest first, so jobs keep their order.
+	sort.Slice(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	for _, job := range pending {
+		if job.State == StateRunning {
+			job.State = StateQueued
+			job.Message = "requeued after restart"
+			if err := m.store.SaveJob(m.ctx, job); err != nil {
+				return err
+			}
+		}
+		m.requeue(job.ID, 0)
+	}
+
+	for i := 0; i < m.cfg.Workers; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+	m.wg.Add(1)
+	go m.janitor()
+	return nil
+}
+
+// Stop cancels running jobs and waits for the workers. Jobs interrupted by Stop stay
+// queued and run again after the next Start.
+func (m *Manager) Stop() {
+	m.stop()
+	m.wg.Wait()
+}
+
+// Enqueue validates and queues a job. Unknown kinds and invalid parameters are
+// reported with an error IsInvalid recognizes.
+func (m *Manager) Enqueue(ctx context.Context, kind string, params json.RawMessage) (*Job, error) {
+	k, ok := m.kinds[kind]
+	if !ok {
+		names := make([]string, 0, len(m.kinds))
+		for name := range m.kinds {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, invalidError{err: fmt.Errorf("unknown job kind: %s (available: %s)", kind, strings.Join(names, ", "))}
+	}
+	if len(params) == 0 {
+		params = json.RawMessage("{}")
+	}
+	if k.Validate != nil {
+		if err := k.Validate(params); err != nil {
+			return nil, invalidError{err: err}
+		}
+	}
+
+	job := Job{
+		ID:          newJobID(),
+		Kind:        kind,
+		Params:      params,
+		State:       StateQueued,
+		MaxAttempts: m.cfg.MaxAttempts,
+		CreatedAt:   m.now().UTC(),
+	}
+	if err := m.store.SaveJob(ctx, job); err != nil {
+		return nil, err
+	}
+	select {
+	case m.queue <- job.ID:
+	default:
+		_ = m.store.DeleteJob(ctx, job.ID)
+		return nil, ErrQueueFull
+	}
+	return &job, nil
+}
+
+// Get returns a job; expired jobs are reported as ErrNotFound.
+func (m *Manager) Get(ctx context.Context, id string) (*Job, error) {
+	job, err := m.store.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.ExpiresAt != nil && job.ExpiresAt.Before(m.now()) {
+		return nil, ErrNotFound
+	}
+	return job, nil
+}
+
+// List returns jobs newest first, leaving out expired ones.
+func (m *Manager) List(ctx context.Context, limit int, states ...string) ([]Job, error) {
+	items, err := m.store.ListJobs(ctx, limit, states...)
+	if err != nil {
+		return nil, err
+	}
+	now := m.now()
+	out := items[:0]
+	for _, job := range items {
+		if job.ExpiresAt == nil || !job.ExpiresAt.Before(now) {
+			out = append(out, job)
+		}
+	}
+	return out, nil
+}
+
+// Cancel stops a queued or running job. A running job is marked canceled by its
+// worker once the runner returns.
+func (m *Manager) Cancel(ctx context.Context, id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cancelLocked(ctx, id)
+}
+
+func (m *Manager) cancelLocked(ctx context.Context, id string) (*Job, error) {
+	job, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch job.State {
+	case StateQueued:
+		m.finish(job, StateCanceled, "canceled before it ran")
+		if err := m.store.SaveJob(ctx, *job); err != nil {
+			return nil, err
+		}
+	case StateRunning:
+		if cancel, ok := m.cancels[id]; ok {
+			m.canceled[id] = true
+			cancel()
+			job.Message = "canceling"
+		}
+	default:
+		return job, ErrFinished
+	}
+	return job, nil
+}
+
+// Delete cancels a job if needed and removes it with its result.
+func (m *Manager) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, err := m.cancelLocked(ctx, id)
+	if err != nil && !errors.Is(err, ErrFinished) {
+		return err
+	}
+	if job.State == StateRunning {
+		// The worker would save the job again once the runner returns.
+		m.deleted[id] = true
+	}
+	return m.store.DeleteJob(ctx, id)
+}
+
+// Result returns a succeeded job with its decoded result.
+func (m *Manager) Result(ctx context.Context, id string) (*Job, *Result, error) {
+	job, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.State != StateSucceeded {
+		return job, nil, ErrNotReady
+	}
+	body, err := m.store.LoadResult(ctx, id)
+	if err != nil {
+		return job, nil, err
+	}
+	result, err := DecodeResult(body)
+	if err != nil {
+		return job, nil, err
+	}
+	return job, result, nil
+}
+
+func (m *Manager) worker() {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case id := <-m.queue:
+			m.run(id)
+		}
+	}
+}
+
+// start moves a queued job to running; it returns nil for jobs that were canceled
+// or deleted while queued.
+func (m *Manager) start(id string) (*Job, context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, err := m.store.GetJob(m.ctx, id)
+	if err != nil || job.State != StateQueued {
+		return nil, nil
+	}
+	now := m.now().UTC()
+	job.State = StateRunning
+	job.Attempts++
+	job.StartedAt = &now
+	job.Progress = 0
+	job.Message = ""
+	if err := m.store.SaveJob(m.ctx, *job); err != nil {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(m.ctx, m.cfg.Timeout)
+	m.cancels[id] = cancel
+	return job, ctx
+}
+
+func (m *Manager) run(id string) {
+	job, ctx := m.start(id)
+	if job == nil {
+		return
+	}
+	kind, ok := m.kinds[job.Kind]
+
+	var (
+		result *Result
+		err    error
+	)
+	if ok {
+		result, err = m.attempt(ctx, kind.Run, job)
+	} else {
+		err = Permanent(fmt.Errorf("job kind %s is no longer available", job.Kind))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cancels[id]()
+	delete(m.cancels, id)
+	canceled := m.canceled[id]
+	delete(m.canceled, id)
+	if m.deleted[id] {
+		delete(m.deleted, id)
+		_ = m.store.DeleteJob(m.ctx, id)
+		return
+	}
+
+	if err == nil {
+		if err = m.saveResult(job, result); err == nil {
+			job.Formats = result.Formats()
+			job.Progress = 1
+			job.Error = ""
+			m.finish(job, StateSucceeded, "")
+			_ = m.store.SaveJob(m.ctx, *job)
+			return
+		}
+	}
+	switch {
+	case canceled:
+		m.finish(job, StateCanceled, "canceled while running")
+	case m.ctx.Err() != nil:
+		// Shutting down: leave the job for the next Start without counting the attempt.
+		job.State = StateQueued
+		job.Attempts--
+		job.Message = "interrupted by shutdown"
+		_ = m.store.SaveJob(context.Background(), *job)
+		return
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		job.Error = fmt.Sprintf("timed out after %s", m.cfg.Timeout)
+		m.finish(job, StateFailed, "")
+	case !IsPermanent(err) && job.Attempts < job.MaxAttempts:
+		delay := m.cfg.RetryDelay << (job.Attempts - 1)
+		job.State = StateQueued
+		job.Error = err.Error()
+		job.Message = fmt.Sprintf("attempt %d of %d failed, retrying in %s", job.Attempts, job.MaxAttempts, delay)
+		if m.store.SaveJob(m.ctx, *job) == nil {
+			m.requeue(job.ID, delay)
+		}
+		return
+	default:
+		job.Error = err.Error()
+		m.finish(job, StateFailed, "")
+	}
+	_ = m.store.SaveJob(m.ctx, *job)
+}
+
+// attempt runs one attempt, persisting progress and turning panics into errors.
+func (m *Manager) attempt(ctx context.Context, run Runner, job *Job) (result *Result, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = Permanent(fmt.Errorf("job panicked: %v", r))
+		}
+	}()
+	progress := *job
+	report := func(fraction float64, message string) {
+		fraction = min(max(fraction, 0), 1)
+		if message == progress.Message && fraction-progress.Progress < progressStep {
+			return
+		}
+		progress.Progress = fraction
+		progress.Message = message
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if !m.canceled[job.ID] {
+			_ = m.store.SaveJob(m.ctx, progress)
+		}
+	}
+	result, err = run(ctx, job.Params, report)
+	if err == nil && result == nil {
+		err = Permanent(errors.New("job returned no result"))
+	}
+	job.Progress = progress.Progress
+	return result, err
+}
+
+func (m *Manager) saveResult(job *Job, result *Result) error {
+	body, err := EncodeResult(result)
+	if err != nil {
+		return Permanent(err)
+	}
+	return m.store.SaveResult(m.ctx, job.ID, body)
+}
+
+func (m *Manager) finish(job *Job, state, message string) {
+	now := m.now().UTC()
+	expires := now.Add(m.cfg.ResultTTL)
+	job.State = state
+	job.Message = message
+	job.FinishedAt = &now
+	job.ExpiresAt = &expires
+}
+
+// requeue puts a job back on the queue after delay without blocking the caller.
+func (m *Manager) requeue(id string, delay time.Duration) {
+	go func() {
+		if delay > 0 {
+			t := time.NewTimer(delay)
+			defer t.Stop()
+			select {
+			case <-m.ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+		select {
+		case <-m.ctx.Done():
+		case m.queue <- id:
+		}
+	}()
+}
+
+// janitor deletes finished jobs whose results expired.
+func (m *Manager) janitor() {
+	defer m.wg.Done()
+	interval := min(m.cfg.ResultTTL/4, 10*time.Minute)
+	ticker := time.NewTicker(max(interval, time.Second))
+	defer ticker.Stop()
+	for {
+		m.deleteExpired()
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) deleteExpired() int {
+	finished, err := m.store.ListJobs(m.ctx, 0, StateSucceeded, StateFailed, StateCanceled)
+	if err != nil {
+		return 0
+	}
+	now := m.now()
+	deleted := 0
+	for _, job := range finished {
+		if job.ExpiresAt != nil && job.ExpiresAt.Before(now) {
+			if m.store.DeleteJob(m.ctx, job.ID) == nil {
+				deleted++
+			}
+		}
+	}
+	return deleted
+}
+
+func newJobID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,201 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+)
+
+// Store persists jobs and their encoded results.
+type Store interface {
+	SaveJob(ctx context.Context, job Job) error
+	// GetJob returns ErrNotFound for unknown ids.
+	GetJob(ctx context.Context, id string) (*Job, error)
+	// ListJobs returns jobs newest first, optionally only those in states.
+	ListJobs(ctx context.Context, limit int, states ...string) ([]Job, error)
+	// DeleteJob deletes a job and its result.
+	DeleteJob(ctx context.Context, id string) error
+	SaveResult(ctx context.Context, id string, body []byte) error
+	// LoadResult returns ErrNotFound when the job has no result.
+	LoadResult(ctx context.Context, id string) ([]byte, error)
+}
+
+// MemoryStore keeps jobs in memory; they are lost on restart. It is used when the
+// app SQLite database is not configured.
+type MemoryStore struct {
+	mu      sync.Mutex
+	jobs    map[string]Job
+	results map[string][]byte
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: map[string]Job{}, results: map[string][]byte{}}
+}
+
+func (s *MemoryStore) SaveJob(_ context.Context, job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.Formats = append([]string(nil), job.Formats...)
+	s.jobs[job.ID] = job
+	return nil
+}
+
+func (s *MemoryStore) GetJob(_ context.Context, id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &job, nil
+}
+
+func (s *MemoryStore) ListJobs(_ context.Context, limit int, states ...string) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		if len(states) > 0 && !containsState(states, job.State) {
+			continue
+		}
+		out = append(out, job)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.After(out[j].CreatedAt)
+		}
+		return out[i].ID > out[j].ID
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) DeleteJob(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	delete(s.results, id)
+	return nil
+}
+
+func (s *MemoryStore) SaveResult(_ context.Context, id string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[id] = body
+	return nil
+}
+
+func (s *MemoryStore) LoadResult(_ context.Context, id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, ok := s.results[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return body, nil
+}
+
+func containsState(states []string, state string) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// sqliteStore keeps jobs in the app SQLite database so they survive restarts.
+type sqliteStore struct {
+	db *customermap.Store
+}
+
+// NewSQLiteStore returns a Store backed by the app SQLite database.
+func NewSQLiteStore(db *customermap.Store) Store {
+	return sqliteStore{db: db}
+}
+
+func (s sqliteStore) SaveJob(ctx context.Context, job Job) error {
+	return s.db.SaveReportJob(ctx, customermap.ReportJob{
+		ID:          job.ID,
+		Kind:        job.Kind,
+		ParamsJSON:  string(job.Params),
+		State:       job.State,
+		Progress:    job.Progress,
+		Message:     job.Message,
+		Error:       job.Error,
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		Formats:     job.Formats,
+		CreatedAt:   job.CreatedAt,
+		StartedAt:   job.StartedAt,
+		FinishedAt:  job.FinishedAt,
+		ExpiresAt:   job.ExpiresAt,
+	})
+}
+
+func (s sqliteStore) GetJob(ctx context.Context, id string) (*Job, error) {
+	it, err := s.db.GetReportJob(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	job := jobFromStore(*it)
+	return &job, nil
+}
+
+func (s sqliteStore) ListJobs(ctx context.Context, limit int, states ...string) ([]Job, error) {
+	items, err := s.db.ListReportJobs(ctx, limit, states)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Job, 0, len(items))
+	for _, it := range items {
+		out = append(out, jobFromStore(it))
+	}
+	return out, nil
+}
+
+func (s sqliteStore) DeleteJob(ctx context.Context, id string) error {
+	_, err := s.db.DeleteReportJob(ctx, id)
+	return err
+}
+
+func (s sqliteStore) SaveResult(ctx context.Context, id string, body []byte) error {
+	return s.db.SaveReportJobResult(ctx, id, body)
+}
+
+func (s sqliteStore) LoadResult(ctx context.Context, id string) ([]byte, error) {
+	body, err := s.db.GetReportJobResult(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return body, err
+}
+
+func jobFromStore(it customermap.ReportJob) Job {
+	return Job{
+		ID:          it.ID,
+		Kind:        it.Kind,
+		Params:      []byte(it.ParamsJSON),
+		State:       it.State,
+		Progress:    it.Progress,
+		Message:     it.Message,
+		Error:       it.Error,
+		Attempts:    it.Attempts,
+		MaxAttempts: it.MaxAttempts,
+		Formats:     it.Formats,
+		CreatedAt:   it.CreatedAt,
+		StartedAt:   it.StartedAt,
+		FinishedAt:  it.FinishedAt,
+		ExpiresAt:   it.ExpiresAt,
+	}
+}
@@ -0,0 +1,239 @@
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+	"go-am-realtime-report-ui/internal/export"
+)
+
+// Delivery channels.
+const (
+	DeliveryDirectory = "directory"
+	DeliveryEmail     = "email"
+	DeliveryWebhook   = "webhook"
+)
+
+// DeliveryConfig configures where report outputs can be delivered.
+type DeliveryConfig struct {
+	// Dir is the directory schedule targets are resolved under; directory
+	// delivery cannot leave it.
+	Dir            string
+	WebhookTimeout time.Duration
+	SMTPHost       string
+	SMTPPort       int
+	SMTPUser       string
+	SMTPPassword   string
+	SMTPFrom       string
+}
+
+// report is one rendered output ready for delivery.
+type report struct {
+	schedule *customermap.ReportSchedule
+	filename string
+	format   string
+	body     []byte
+	window   string
+}
+
+// validateTarget checks a delivery channel and target before a schedule is saved
+// and returns the normalized target.
+func (c DeliveryConfig) validateTarget(delivery, target string) (string, error) {
+	target = strings.TrimSpace(target)
+	switch delivery {
+	case DeliveryDirectory:
+		if c.Dir == "" {
+			return "", fmt.Errorf("directory delivery is disabled, set APP_REPORT_DELIVERY_DIR")
+		}
+		if target == "" {
+			return "", nil
+		}
+		clean := filepath.Clean(filepath.FromSlash(target))
+		if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("directory target must be a relative path inside the delivery directory")
+		}
+		return filepath.ToSlash(clean), nil
+	case DeliveryEmail:
+		if c.SMTPHost == "" {
+			return "", fmt.Errorf("email delivery is disabled, set APP_SMTP_HOST")
+		}
+		list, err := mail.ParseAddressList(target)
+		if err != nil || len(list) == 0 {
+			return "", fmt.Errorf("email target must be a comma-separated list of addresses")
+		}
+		addrs := make([]string, 0, len(list))
+		for _, a := range list {
+			addrs = append(addrs, a.Address)
+		}
+		return strings.Join(addrs, ", "), nil
+	case DeliveryWebhook:
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("webhook target must be an http or https URL")
+		}
+		return u.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported delivery %q (expected directory, email or webhook)", delivery)
+	}
+}
+
+// deliver sends a report through its schedule's channel and returns where it went.
+func (c DeliveryConfig) deliver(ctx context.Context, r report) (string, error) {
+	switch r.schedule.Delivery {
+	case DeliveryDirectory:
+		return c.writeFile(r)
+	case DeliveryEmail:
+		return c.sendMail(r)
+	case DeliveryWebhook:
+		return c.postWebhook(ctx, r)
+	default:
+		return "", fmt.Errorf("unsupported delivery %q", r.schedule.Delivery)
+	}
+}
+
+// writeFile writes the report atomically so readers never see a partial file.
+func (c DeliveryConfig) writeFile(r report) (string, error) {
+	target, err := c.validateTarget(DeliveryDirectory, r.schedule.Target)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(c.Dir, filepath.FromSlash(target))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, ".report-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(r.body); err != nil {
+		_ = tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, r.filename)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (c DeliveryConfig) sendMail(r report) (string, error) {
+	to, err := c.validateTarget(DeliveryEmail, r.schedule.Target)
+	if err != nil {
+		return "", err
+	}
+	recipients := strings.Split(to, ", ")
+	msg, err := c.mailMessage(r, recipients)
+	if err != nil {
+		return "", err
+	}
+	var auth smtp.Auth
+	if c.SMTPUser != "" {
+		auth = smtp.PlainAuth("", c.SMTPUser, c.SMTPPassword, c.SMTPHost)
+	}
+	addr := net.JoinHostPort(c.SMTPHost, strconv.Itoa(c.SMTPPort))
+	if err := smtp.SendMail(addr, auth, c.SMTPFrom, recipients, msg); err != nil {
+		return "", fmt.Errorf("send mail via %s: %w", addr, err)
+	}
+	return "mailto:" + to, nil
+}
+
+// mailMessage builds a multipart message with a short text body and the report as
+// a base64 attachment.
+func (c DeliveryConfig) mailMessage(r report, recipients []string) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	subject := fmt.Sprintf("Report %s", r.schedule.Name)
+	if r.window != "" {
+		subject += " " + r.window
+	}
+	fmt.Fprintf(&buf, "From: %s\r\n", c.SMTPFrom)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", mw.Boundary())
+
+	text, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(text, "Scheduled report %q is attached as %s.\r\n", r.schedule.Name, r.filename)
+
+	attachment, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {export.ContentType(r.format)},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": r.filename})},
+	})
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(r.body)
+	for len(encoded) > 76 {
+		if _, err := io.WriteString(attachment, encoded[:76]+"\r\n"); err != nil {
+			return nil, err
+		}
+		encoded = encoded[76:]
+	}
+	if _, err := io.WriteString(attachment, encoded+"\r\n"); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// postWebhook posts the report body; any non-2xx answer fails the delivery.
+func (c DeliveryConfig) postWebhook(ctx context.Context, r report) (string, error) {
+	target, err := c.validateTarget(DeliveryWebhook, r.schedule.Target)
+	if err != nil {
+		return "", err
+	}
+	timeout := c.WebhookTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(r.body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", export.ContentType(r.format))
+	req.Header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": r.filename}))
+	req.Header.Set("X-Report-Schedule", r.schedule.Name)
+	if r.window != "" {
+		req.Header.Set("X-Report-Window", r.window)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return target, nil
+}
@@ -0,0 +1,56 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	mysqlstore "go-am-realtime-report-ui/internal/connectors/mysql"
+)
+
+// Report ranges, relative to the time a run is scheduled for.
+const (
+	RangeTemplate           = "template"
+	RangePreviousDay        = "previous_day"
+	RangePreviousWeek       = "previous_week"
+	RangePreviousMonth      = "previous_month"
+	RangePreviousQuarter    = "previous_quarter"
+	RangePreviousYear       = "previous_year"
+	RangePreviousFiscalYear = "previous_fiscal_year"
+)
+
+var rangeKinds = []string{
+	RangeTemplate, RangePreviousDay, RangePreviousWeek, RangePreviousMonth,
+	RangePreviousQuarter, RangePreviousYear, RangePreviousFiscalYear,
+}
+
+// NormalizeRange validates a range name; empty means the template's own dates.
+func NormalizeRange(raw string) (string, error) {
+	r := strings.ToLower(strings.TrimSpace(raw))
+	if r == "" {
+		return RangeTemplate, nil
+	}
+	for _, known := range rangeKinds {
+		if r == known {
+			return r, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported range %q (expected %s)", raw, strings.Join(rangeKinds, ", "))
+}
+
+// Window returns the [from, to) window a run scheduled at at reports on: the whole
+// day, week, month, quarter or year in loc before the one containing at. ok is false
+// for RangeTemplate, which keeps the dates saved in the template.
+func Window(rangeKind string, at time.Time, loc *time.Location, fiscalStartMonth int) (from, to time.Time, ok bool, err error) {
+	if rangeKind == RangeTemplate || rangeKind == "" {
+		return time.Time{}, time.Time{}, false, nil
+	}
+	if _, err := NormalizeRange(rangeKind); err != nil {
+		return time.Time{}, time.Time{}, false, err
+	}
+	period, err := mysqlstore.RelativeReportPeriod(rangeKind, at, loc, fiscalStartMonth)
+	if err != nil {
+		return time.Time{}, time.Time{}, false, err
+	}
+	return period.Start, period.End, true, nil
+}
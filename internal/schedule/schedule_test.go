@@ -0,0 +1,219 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+	"go-am-realtime-report-ui/internal/export"
+	"go-am-realtime-report-ui/internal/jobs"
+)
+
+func TestCronNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("tzdata not available")
+	}
+	tests := []struct {
+		expr  string
+		after string
+		loc   *time.Location
+		want  string
+	}{
+		{"0 6 1 * *", "2026-03-15T10:00:00Z", time.UTC, "2026-04-01T06:00:00Z"},
+		{"0 6 1 * *", "2026-04-01T06:00:00Z", time.UTC, "2026-05-01T06:00:00Z"},
+		{"*/15 * * * *", "2026-03-15T10:07:30Z", time.UTC, "2026-03-15T10:15:00Z"},
+		{"30 9 * * mon-fri", "2026-03-13T10:00:00Z", time.UTC, "2026-03-16T09:30:00Z"},
+		{"0 0 13 * 5", "2026-03-01T00:00:00Z", time.UTC, "2026-03-06T00:00:00Z"},
+		{"0 0 * * 7", "2026-03-16T00:00:00Z", time.UTC, "2026-03-22T00:00:00Z"},
+		{"@monthly", "2026-12-31T23:59:00Z", time.UTC, "2027-01-01T00:00:00Z"},
+		{"0 0 29 feb *", "2026-01-01T00:00:00Z", time.UTC, "2028-02-29T00:00:00Z"},
+		// 06:00 in Berlin is 05:00 UTC in winter and 04:00 UTC in summer.
+		{"0 6 1 * *", "2026-02-15T00:00:00Z", berlin, "2026-03-01T05:00:00Z"},
+		{"0 6 1 * *", "2026-03-15T00:00:00Z", berlin, "2026-04-01T04:00:00Z"},
+		// 02:30 does not exist on 29 March 2026 and happens twice on 25 October.
+		{"30 2 * * *", "2026-03-28T12:00:00Z", berlin, "2026-03-30T00:30:00Z"},
+		{"30 2 * * *", "2026-10-25T00:30:00Z", berlin, "2026-10-26T01:30:00Z"},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		after, _ := time.Parse(time.RFC3339, tt.after)
+		if got := c.Next(after, tt.loc).UTC().Format(time.RFC3339); got != tt.want {
+			t.Errorf("%s after %s in %s: got %s, want %s", tt.expr, tt.after, tt.loc, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "* * * foo *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("expected %q to be rejected", expr)
+		}
+	}
+	never, _ := ParseCron("0 0 31 2 *")
+	if next := never.Next(time.Now(), time.UTC); !next.IsZero() {
+		t.Errorf("31 February must never match, got %s", next)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	at := time.Date(2026, 4, 1, 6, 0, 0, 0, time.UTC)
+	tests := []struct {
+		rangeKind string
+		from, to  string
+	}{
+		{RangePreviousDay, "2026-03-31T00:00:00Z", "2026-04-01T00:00:00Z"},
+		{RangePreviousWeek, "2026-03-23T00:00:00Z", "2026-03-30T00:00:00Z"},
+		{RangePreviousMonth, "2026-03-01T00:00:00Z", "2026-04-01T00:00:00Z"},
+		{RangePreviousQuarter, "2026-01-01T00:00:00Z", "2026-04-01T00:00:00Z"},
+		{RangePreviousYear, "2025-01-01T00:00:00Z", "2026-01-01T00:00:00Z"},
+		{RangePreviousFiscalYear, "2024-07-01T00:00:00Z", "2025-07-01T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		from, to, ok, err := Window(tt.rangeKind, at, time.UTC, 7)
+		if err != nil || !ok {
+			t.Fatalf("%s: %v %v", tt.rangeKind, ok, err)
+		}
+		if from.Format(time.RFC3339) != tt.from || to.Format(time.RFC3339) != tt.to {
+			t.Errorf("%s: got %s..%s, want %s..%s", tt.rangeKind, from.Format(time.RFC3339), to.Format(time.RFC3339), tt.from, tt.to)
+		}
+	}
+	if _, _, ok, err := Window(RangeTemplate, at, time.UTC, 1); ok || err != nil {
+		t.Errorf("template range must keep the template dates: %v %v", ok, err)
+	}
+	if _, err := NormalizeRange("this_month"); err == nil {
+		t.Error("expected unknown range to be rejected")
+	}
+}
+
+func TestSchedulerCatchesUpAndDelivers(t *testing.T) {
+	db, err := customermap.NewSQLiteStore(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	m := jobs.NewManager(jobs.NewMemoryStore(), jobs.Config{Workers: 2, QueueSize: 10, RetryDelay: time.Millisecond})
+	params := make(chan map[string]string, 10)
+	m.Register(JobKind, jobs.Kind{Run: func(ctx context.Context, raw json.RawMessage, progress func(float64, string)) (*jobs.Result, error) {
+		var p map[string]string
+		_ = json.Unmarshal(raw, &p)
+		params <- p
+		return &jobs.Result{
+			Name:   "transfer-report-" + strings.ReplaceAll(p["date_from"], ":", ""),
+			Data:   raw,
+			Tables: []export.Table{{Columns: []export.Column{{Key: "date_from"}}, Rows: [][]any{{p["date_from"]}}}},
+		}, nil
+	}})
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	var hooked []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		hooked = append(hooked, r.Header.Get("Content-Type")+" "+string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer hook.Close()
+
+	dir := t.TempDir()
+	now := time.Date(2026, 4, 1, 6, 0, 10, 0, time.UTC)
+	s := New(db, m, Config{Tick: time.Minute, MaxCatchUp: 1, Delivery: DeliveryConfig{Dir: dir}})
+	s.now = func() time.Time { return now }
+
+	if _, err := s.Save(ctx, customermap.ReportSchedule{Name: "x", TemplateID: 1, Cron: "0 6 1 * *", Delivery: DeliveryDirectory, Target: "../etc"}); err == nil {
+		t.Fatal("expected a directory target outside the delivery directory to be rejected")
+	}
+	if _, err := s.Save(ctx, customermap.ReportSchedule{Name: "x", TemplateID: 1, Cron: "0 6 1 * *", Delivery: DeliveryEmail, Target: "ops@example.org"}); err == nil {
+		t.Fatal("expected email delivery without SMTP to be rejected")
+	}
+
+	// Saved in January, the service was down until 1 April: the February and March
+	// runs are missed, March is caught up and April runs on time.
+	s.now = func() time.Time { return time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC) }
+	monthly, err := s.Save(ctx, customermap.ReportSchedule{Name: "Monthly ops", TemplateID: 7, Cron: "0 6 1 * *", Range: RangePreviousMonth, Delivery: DeliveryDirectory, Target: "ops", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if monthly.NextRunAt == nil || !monthly.NextRunAt.Equal(time.Date(2026, 2, 1, 6, 0, 0, 0, time.UTC)) || monthly.Format != export.FormatCSV || monthly.Timezone != "UTC" {
+		t.Fatalf("unexpected saved schedule %+v", monthly)
+	}
+	hooks, err := s.Save(ctx, customermap.ReportSchedule{Name: "hook", TemplateID: 7, Cron: "@daily", Format: "json", Delivery: DeliveryWebhook, Target: hook.URL, ParamsJSON: `{"customer":"acme"}`, Enabled: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return now }
+
+	if err := s.tick(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RunNow(ctx, hooks.ID); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if err := s.tick(ctx); err != nil {
+			t.Fatal(err)
+		}
+		running, _ := db.ListReportScheduleRuns(ctx, 0, 0, []string{RunRunning})
+		if len(running) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("runs did not finish: %+v", running)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	runs, err := s.Runs(ctx, monthly.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 3 {
+		t.Fatalf("expected 3 runs, got %+v", runs)
+	}
+	if r := runs[2]; r.State != RunSkipped || !r.ScheduledFor.Equal(time.Date(2026, 2, 1, 6, 0, 0, 0, time.UTC)) || !strings.HasPrefix(r.Error, "1 missed runs") {
+		t.Fatalf("unexpected skipped run %+v", r)
+	}
+	if r := runs[1]; r.State != RunSucceeded || r.Trigger != TriggerCatchUp || r.DateFrom != "2026-02-01T00:00:00Z" || r.DateTo != "2026-03-01T00:00:00Z" {
+		t.Fatalf("unexpected catch-up run %+v", r)
+	}
+	if r := runs[0]; r.State != RunSucceeded || r.Trigger != TriggerSchedule || r.DateFrom != "2026-03-01T00:00:00Z" {
+		t.Fatalf("unexpected scheduled run %+v", r)
+	}
+	body, err := os.ReadFile(runs[0].DeliveredTo)
+	if err != nil || string(body) != "date_from\n2026-03-01T00:00:00Z\n" {
+		t.Fatalf("unexpected delivered file %s: %q %v", runs[0].DeliveredTo, body, err)
+	}
+	if filepath.Dir(runs[0].DeliveredTo) != filepath.Join(dir, "ops") || filepath.Base(runs[0].DeliveredTo) != "Monthly-ops-transfer-report-2026-03-01T000000Z.csv" {
+		t.Fatalf("unexpected delivery path %s", runs[0].DeliveredTo)
+	}
+
+	monthly, _ = s.Get(ctx, monthly.ID)
+	if !monthly.NextRunAt.Equal(time.Date(2026, 5, 1, 6, 0, 0, 0, time.UTC)) || !monthly.LastRunAt.Equal(time.Date(2026, 4, 1, 6, 0, 0, 0, time.UTC)) {
+		t.Fatalf("schedule not advanced: %+v", monthly)
+	}
+
+	hookRuns, _ := s.Runs(ctx, hooks.ID, 0)
+	if len(hookRuns) != 1 || hookRuns[0].State != RunSucceeded || hookRuns[0].Trigger != TriggerManual {
+		t.Fatalf("unexpected manual runs %+v", hookRuns)
+	}
+	if len(hooked) != 1 || hooked[0] != `application/json {"at":"2026-04-01T06:00:10Z","params":{"customer":"acme"},"template_id":7,"timezone":"UTC"}` {
+		t.Fatalf("unexpected webhook calls %q", hooked)
+	}
+	if len(params) != 3 {
+		t.Fatalf("expected 3 jobs, got %d", len(params))
+	}
+}
@@ -0,0 +1,461 @@
+// Package schedule runs saved report templates on cron schedules. Each due run is
+// queued as a report job; once the job succeeds its output is rendered and
+// delivered to a directory, email recipients or a webhook. Runs missed while the
+// service was down are caught up on the next start.
+package schedule
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	customermap "go-am-realtime-report-ui/internal/connectors/customermap"
+	"go-am-realtime-report-ui/internal/export"
+	"go-am-realtime-report-ui/internal/jobs"
+)
+
+// JobKind is the job kind scheduled runs are queued as.
+const JobKind = "report_template"
+
+// Run triggers.
+const (
+	TriggerSchedule = "schedule"
+	TriggerCatchUp  = "catch_up"
+	TriggerManual   = "manual"
+)
+
+// Run states. Running covers both the queued job and the delivery.
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	RunSkipped   = "skipped"
+)
+
+// ErrNotFound is returned for unknown schedules.
+var ErrNotFound = errors.New("schedule not found")
+
+var nonFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Config tunes the scheduler.
+type Config struct {
+	// Tick is how often due schedules and running deliveries are checked.
+	Tick time.Duration
+	// MaxCatchUp is how many missed runs per schedule are executed after downtime;
+	// older ones are recorded as skipped.
+	MaxCatchUp       int
+	FiscalStartMonth int
+	Delivery         DeliveryConfig
+}
+
+// Scheduler queues due schedule runs and delivers their results.
+type Scheduler struct {
+	store *customermap.Store
+	jobs  *jobs.Manager
+	cfg   Config
+	now   func() time.Time
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New returns a scheduler; it does nothing until Start.
+func New(store *customermap.Store, m *jobs.Manager, cfg Config) *Scheduler {
+	if cfg.Tick <= 0 {
+		cfg.Tick = 30 * time.Second
+	}
+	if cfg.MaxCatchUp < 0 {
+		cfg.MaxCatchUp = 0
+	}
+	return &Scheduler{store: store, jobs: m, cfg: cfg, now: time.Now}
+}
+
+// Start checks schedules immediately, catching up missed runs, and then every tick.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.cfg.Tick)
+		defer ticker.Stop()
+		for {
+			if err := s.tick(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("report schedules: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends the loop. Runs whose jobs are still going are delivered after the
+// next start.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel = nil
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// Normalize validates a schedule and fills in defaults before it is saved.
+func (s *Scheduler) Normalize(item *customermap.ReportSchedule) error {
+	item.Name = strings.TrimSpace(item.Name)
+	if item.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if item.TemplateID <= 0 {
+		return fmt.Errorf("template_id is required")
+	}
+	cron, err := ParseCron(item.Cron)
+	if err != nil {
+		return err
+	}
+	item.Cron = strings.Join(strings.Fields(item.Cron), " ")
+	item.Timezone = strings.TrimSpace(item.Timezone)
+	if item.Timezone == "" {
+		item.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(item.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q", item.Timezone)
+	}
+	if cron.Next(s.now(), loc).IsZero() {
+		return fmt.Errorf("cron expression %q never matches", item.Cron)
+	}
+	if item.Range, err = NormalizeRange(item.Range); err != nil {
+		return err
+	}
+	item.Format = strings.ToLower(strings.TrimSpace(item.Format))
+	switch item.Format {
+	case "":
+		item.Format = export.FormatCSV
+	case export.FormatCSV, export.FormatXLSX, export.FormatJSON:
+	default:
+		return fmt.Errorf("unsupported format %q (expected csv, xlsx or json)", item.Format)
+	}
+	item.ParamsJSON = strings.TrimSpace(item.ParamsJSON)
+	if item.ParamsJSON == "" || item.ParamsJSON == "null" {
+		item.ParamsJSON = "{}"
+	}
+	var values map[string]any
+	if err := json.Unmarshal([]byte(item.ParamsJSON), &values); err != nil {
+		return fmt.Errorf("params must be an object of template parameter values")
+	}
+	item.Delivery = strings.ToLower(strings.TrimSpace(item.Delivery))
+	item.Target, err = s.cfg.Delivery.validateTarget(item.Delivery, item.Target)
+	return err
+}
+
+// Save validates and stores a schedule; the next run is computed from now, so
+// editing a schedule does not trigger catch-up runs.
+func (s *Scheduler) Save(ctx context.Context, item customermap.ReportSchedule) (*customermap.ReportSchedule, error) {
+	if err := s.Normalize(&item); err != nil {
+		return nil, err
+	}
+	item.NextRunAt = nil
+	if item.Enabled {
+		next, err := s.nextRun(item, s.now())
+		if err != nil {
+			return nil, err
+		}
+		item.NextRunAt = &next
+	}
+	id, err := s.store.SaveReportSchedule(ctx, item)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// Get returns one schedule.
+func (s *Scheduler) Get(ctx context.Context, id int64) (*customermap.ReportSchedule, error) {
+	item, err := s.store.GetReportSchedule(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return item, err
+}
+
+// RunNow queues a manual run for the time it is called, whether or not the
+// schedule is enabled.
+func (s *Scheduler) RunNow(ctx context.Context, id int64) (*customermap.ReportScheduleRun, error) {
+	item, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.startRun(ctx, item, s.now().UTC(), TriggerManual)
+}
+
+func (s *Scheduler) location(item customermap.ReportSchedule) (*time.Location, error) {
+	loc, err := time.LoadLocation(item.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("schedule %d has an invalid timezone %q", item.ID, item.Timezone)
+	}
+	return loc, nil
+}
+
+func (s *Scheduler) nextRun(item customermap.ReportSchedule, after time.Time) (time.Time, error) {
+	cron, err := ParseCron(item.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := s.location(item)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := cron.Next(after, loc)
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron expression %q never matches", item.Cron)
+	}
+	return next.UTC(), nil
+}
+
+// tick queues due runs and delivers the results of finished ones.
+func (s *Scheduler) tick(ctx context.Context) error {
+	var errs []error
+	items, err := s.store.ListReportSchedules(ctx, 0)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := s.queueDue(ctx, item); err != nil {
+			errs = append(errs, fmt.Errorf("schedule %d: %w", item.ID, err))
+		}
+	}
+	if err := s.collect(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// queueDue starts the runs of a schedule that became due since its next run time.
+// After downtime the most recent MaxCatchUp occurrences run oldest first and the
+// older ones are recorded as one skipped run.
+func (s *Scheduler) queueDue(ctx context.Context, item customermap.ReportSchedule) error {
+	now := s.now().UTC()
+	if !item.Enabled || item.NextRunAt == nil || item.NextRunAt.After(now) {
+		return nil
+	}
+	keep := s.cfg.MaxCatchUp + 1
+	var (
+		due     []time.Time
+		skipped int
+		first   time.Time
+		last    time.Time
+	)
+	for at := *item.NextRunAt; !at.After(now); {
+		due = append(due, at)
+		if len(due) > keep {
+			if skipped == 0 {
+				first = due[0]
+			}
+			last = due[0]
+			skipped++
+			due = due[1:]
+		}
+		next, err := s.nextRun(item, at)
+		if err != nil {
+			return err
+		}
+		at = next
+	}
+	next, err := s.nextRun(item, now)
+	if err != nil {
+		return err
+	}
+
+	if skipped > 0 {
+		finished := now
+		if _, err := s.store.SaveReportScheduleRun(ctx, customermap.ReportScheduleRun{
+			ScheduleID:   item.ID,
+			Trigger:      TriggerCatchUp,
+			ScheduledFor: last,
+			State:        RunSkipped,
+			Error:        fmt.Sprintf("%d missed runs from %s skipped, catch-up is limited to %d", skipped, first.Format(time.RFC3339), s.cfg.MaxCatchUp),
+			CreatedAt:    now,
+			FinishedAt:   &finished,
+		}); err != nil {
+			return err
+		}
+	}
+
+	for i, at := range due {
+		trigger := TriggerSchedule
+		if i < len(due)-1 || now.Sub(at) > 2*s.cfg.Tick {
+			trigger = TriggerCatchUp
+		}
+		if _, err := s.startRun(ctx, &item, at, trigger); errors.Is(err, jobs.ErrQueueFull) {
+			// Try the remaining occurrences on the next tick.
+			if i > 0 {
+				return s.store.AdvanceReportSchedule(ctx, item.ID, &due[i-1], &at)
+			}
+			return err
+		} else if err != nil {
+			return err
+		}
+	}
+	return s.store.AdvanceReportSchedule(ctx, item.ID, &due[len(due)-1], &next)
+}
+
+// startRun queues the job of one run and records it. Runs the job queue rejects
+// as invalid are recorded as failed.
+func (s *Scheduler) startRun(ctx context.Context, item *customermap.ReportSchedule, at time.Time, trigger string) (*customermap.ReportScheduleRun, error) {
+	run := customermap.ReportScheduleRun{
+		ScheduleID:   item.ID,
+		Trigger:      trigger,
+		ScheduledFor: at,
+		State:        RunRunning,
+		CreatedAt:    s.now().UTC(),
+	}
+	params := map[string]any{"template_id": item.TemplateID, "at": at.UTC().Format(time.RFC3339), "timezone": item.Timezone}
+	if values := map[string]any{}; json.Unmarshal([]byte(item.ParamsJSON), &values) == nil && len(values) > 0 {
+		params["params"] = values
+	}
+	loc, err := s.location(*item)
+	if err != nil {
+		return nil, err
+	}
+	from, to, ok, err := Window(item.Range, at, loc, s.cfg.FiscalStartMonth)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		run.DateFrom = from.Format(time.RFC3339)
+		run.DateTo = to.Format(time.RFC3339)
+		params["date_from"] = run.DateFrom
+		params["date_to"] = run.DateTo
+	}
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	job, err := s.jobs.Enqueue(ctx, JobKind, raw)
+	switch {
+	case errors.Is(err, jobs.ErrQueueFull):
+		return nil, err
+	case jobs.IsInvalid(err):
+		finished := s.now().UTC()
+		run.State = RunFailed
+		run.Error = err.Error()
+		run.FinishedAt = &finished
+	case err != nil:
+		return nil, err
+	default:
+		run.JobID = job.ID
+	}
+	if run.ID, err = s.store.SaveReportScheduleRun(ctx, run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// collect checks the jobs of running runs and delivers the finished ones.
+func (s *Scheduler) collect(ctx context.Context) error {
+	runs, err := s.store.ListReportScheduleRuns(ctx, 0, 0, []string{RunRunning})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, run := range runs {
+		job, err := s.jobs.Get(ctx, run.JobID)
+		switch {
+		case errors.Is(err, jobs.ErrNotFound):
+			err = s.finish(ctx, run, "", errors.New("report job expired or was deleted before delivery"))
+		case err != nil:
+		case !job.Finished():
+			continue
+		case job.State != jobs.StateSucceeded:
+			err = s.finish(ctx, run, "", fmt.Errorf("report job %s: %s", job.State, job.Error))
+		default:
+			dest, deliverErr := s.deliver(ctx, run)
+			err = s.finish(ctx, run, dest, deliverErr)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("run %d: %w", run.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Scheduler) deliver(ctx context.Context, run customermap.ReportScheduleRun) (string, error) {
+	item, err := s.Get(ctx, run.ScheduleID)
+	if err != nil {
+		return "", err
+	}
+	_, result, err := s.jobs.Result(ctx, run.JobID)
+	if err != nil {
+		return "", err
+	}
+	body, err := result.Render(item.Format)
+	if err != nil {
+		return "", err
+	}
+	window := ""
+	if run.DateFrom != "" {
+		window = run.DateFrom + ".." + run.DateTo
+	}
+	name := nonFileChars.ReplaceAllString(item.Name, "-")
+	return s.cfg.Delivery.deliver(ctx, report{
+		schedule: item,
+		filename: strings.Trim(name, "-") + "-" + result.Name + "." + item.Format,
+		format:   item.Format,
+		body:     body,
+		window:   window,
+	})
+}
+
+func (s *Scheduler) finish(ctx context.Context, run customermap.ReportScheduleRun, deliveredTo string, runErr error) error {
+	finished := s.now().UTC()
+	run.FinishedAt = &finished
+	run.DeliveredTo = deliveredTo
+	run.State = RunSucceeded
+	if runErr != nil {
+		run.State = RunFailed
+		run.Error = runErr.Error()
+	}
+	_, err := s.store.SaveReportScheduleRun(ctx, run)
+	return err
+}
+
+// List returns all schedules by name.
+func (s *Scheduler) List(ctx context.Context, limit int) ([]customermap.ReportSchedule, error) {
+	return s.store.ListReportSchedules(ctx, limit)
+}
+
+// Delete removes a schedule and its run history; queued jobs are left to finish.
+func (s *Scheduler) Delete(ctx context.Context, id int64) (bool, error) {
+	n, err := s.store.DeleteReportSchedule(ctx, id)
+	return n > 0, err
+}
+
+// Runs returns the run history of a schedule, newest first.
+func (s *Scheduler) Runs(ctx context.Context, id int64, limit int) ([]customermap.ReportScheduleRun, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.store.ListReportScheduleRuns(ctx, id, limit, nil)
+}